@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	seedservice "github.com/rohit/bulk-import-export/internal/service/seed"
+)
+
+// File names written by generate and read by run
+const (
+	usersFile    = "users.csv"
+	articlesFile = "articles.ndjson"
+	commentsFile = "comments.ndjson"
+)
+
+func generateCmd(args []string) error {
+	var out string
+	var opts seedservice.Options
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.StringVar(&out, "out", "./bench", "directory to write the files to")
+	fs.IntVar(&opts.Users, "users", 10000, "user rows")
+	fs.IntVar(&opts.Articles, "articles", 10000, "article rows")
+	fs.IntVar(&opts.Comments, "comments", 50000, "comment rows")
+	fs.IntVar(&opts.Authors, "authors", 1000, "distinct users that author articles and comments (0 = all users)")
+	fs.IntVar(&opts.Commented, "commented-articles", 0, "distinct articles that get comments (0 = all articles)")
+	fs.IntVar(&opts.Tags, "tags", 50, "distinct article tags")
+	fs.IntVar(&opts.BodyWords, "body-words", 60, "words per article body; comment bodies are a fifth of it")
+	fs.Float64Var(&opts.InvalidPct, "invalid-pct", 1, "percent of rows that fail validation")
+	fs.Float64Var(&opts.DuplicatePct, "duplicate-pct", 1, "percent of rows that repeat an earlier row's id")
+	fs.Int64Var(&opts.Seed, "seed", 1, "random seed; the same seed and sizes give the same files")
+	fs.Parse(args)
+
+	if (opts.Articles > 0 || opts.Comments > 0) && opts.Users == 0 {
+		return fmt.Errorf("articles and comments need at least one user")
+	}
+	if opts.Comments > 0 && opts.Articles == 0 {
+		return fmt.Errorf("comments need at least one article")
+	}
+
+	g, err := seedservice.NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	start := time.Now()
+	for _, f := range []struct {
+		name  string
+		rows  int
+		write func(io.Writer) error
+	}{
+		{usersFile, opts.Users, g.WriteUsers},
+		{articlesFile, opts.Articles, g.WriteArticles},
+		{commentsFile, opts.Comments, g.WriteComments},
+	} {
+		path := filepath.Join(out, f.name)
+		size, err := writeFile(path, f.write)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+		fmt.Printf("%-16s %10d rows %12d bytes\n", f.name, f.rows, size)
+	}
+	fmt.Printf("generated in %s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
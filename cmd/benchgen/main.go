@@ -0,0 +1,50 @@
+// Command benchgen generates synthetic import files and measures how fast the
+// import and export pipelines process them against a test database.
+//
+//	benchgen generate -out ./bench -users 100000 -articles 50000 -comments 200000
+//	benchgen run -dir ./bench -report report.json -baseline last.json
+//
+// run reads the database settings from the same environment variables as the
+// server. It writes to the configured database, so point it at a test one.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "generate":
+		err = generateCmd(os.Args[2:])
+	case "run":
+		err = runCmd(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "benchgen: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: benchgen <command> [flags]
+
+commands:
+  generate  write synthetic users.csv, articles.ndjson and comments.ndjson
+  run       import and export the generated files and report rows/sec and peak RSS
+
+Run "benchgen <command> -h" for the flags of a command.`)
+}
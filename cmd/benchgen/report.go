@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// phase is one import or export timed by run
+type phase struct {
+	Name       string        `json:"name"`
+	Rows       int64         `json:"rows"`
+	Failed     int64         `json:"failed,omitempty"`
+	Bytes      int64         `json:"bytes,omitempty"`
+	Duration   time.Duration `json:"duration_ns"`
+	RowsPerSec float64       `json:"rows_per_sec"`
+}
+
+// report is the outcome of a run, written as JSON so a later run can be
+// compared against it
+type report struct {
+	StartedAt       time.Time `json:"started_at"`
+	GoVersion       string    `json:"go_version"`
+	ImportBatchSize int       `json:"import_batch_size"`
+	Phases          []phase   `json:"phases"`
+	PeakRSSBytes    int64     `json:"peak_rss_bytes"`
+}
+
+func (r *report) add(p phase) {
+	if secs := p.Duration.Seconds(); secs > 0 {
+		p.RowsPerSec = float64(p.Rows) / secs
+	}
+	r.Phases = append(r.Phases, p)
+}
+
+func (r *report) print(w io.Writer) {
+	fmt.Fprintf(w, "%-18s %10s %10s %12s %12s\n", "phase", "rows", "failed", "seconds", "rows/sec")
+	for _, p := range r.Phases {
+		fmt.Fprintf(w, "%-18s %10d %10d %12.2f %12.0f\n", p.Name, p.Rows, p.Failed, p.Duration.Seconds(), p.RowsPerSec)
+	}
+	fmt.Fprintf(w, "peak RSS: %.1f MiB\n", float64(r.PeakRSSBytes)/(1<<20))
+}
+
+func (r *report) write(path string) error {
+	r.GoVersion = runtime.Version()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func readReport(path string) (*report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// compare lists the phases that got slower, and a peak RSS that grew, by
+// more than tolerance percent over the baseline. Phases missing from either
+// report are skipped.
+func (r *report) compare(baseline *report, tolerance float64) []string {
+	before := make(map[string]phase, len(baseline.Phases))
+	for _, p := range baseline.Phases {
+		before[p.Name] = p
+	}
+
+	var regressions []string
+	for _, p := range r.Phases {
+		b, ok := before[p.Name]
+		if !ok || b.RowsPerSec == 0 {
+			continue
+		}
+		if p.RowsPerSec < b.RowsPerSec*(1-tolerance/100) {
+			regressions = append(regressions, fmt.Sprintf("%s: %.0f rows/sec, baseline %.0f (%+.1f%%)",
+				p.Name, p.RowsPerSec, b.RowsPerSec, change(p.RowsPerSec, b.RowsPerSec)))
+		}
+	}
+	if baseline.PeakRSSBytes > 0 && float64(r.PeakRSSBytes) > float64(baseline.PeakRSSBytes)*(1+tolerance/100) {
+		regressions = append(regressions, fmt.Sprintf("peak RSS: %d bytes, baseline %d (%+.1f%%)",
+			r.PeakRSSBytes, baseline.PeakRSSBytes, change(float64(r.PeakRSSBytes), float64(baseline.PeakRSSBytes))))
+	}
+	return regressions
+}
+
+func change(now, before float64) float64 {
+	return (now - before) / before * 100
+}
+
+// peakRSS returns the largest resident set size of the process so far
+func peakRSS() int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	// Linux reports kilobytes, macOS bytes
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
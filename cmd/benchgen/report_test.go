@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReport_CompareFlagsSlowerPhasesAndRSS(t *testing.T) {
+	baseline := &report{PeakRSSBytes: 100 << 20}
+	baseline.add(phase{Name: "import_users", Rows: 1000, Duration: time.Second})
+	baseline.add(phase{Name: "export_users", Rows: 1000, Duration: time.Second})
+
+	current := &report{PeakRSSBytes: 105 << 20}
+	current.add(phase{Name: "import_users", Rows: 1000, Duration: 1050 * time.Millisecond})
+	current.add(phase{Name: "export_users", Rows: 1000, Duration: 2 * time.Second})
+	current.add(phase{Name: "import_comments", Rows: 1000, Duration: time.Second})
+
+	got := current.compare(baseline, 10)
+	if len(got) != 1 {
+		t.Fatalf("compare() = %q, want only export_users", got)
+	}
+
+	current.PeakRSSBytes = 200 << 20
+	if got := current.compare(baseline, 10); len(got) != 2 {
+		t.Errorf("compare() = %q, want export_users and peak RSS", got)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rohit/bulk-import-export/internal/storage"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+)
+
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	dir := fs.String("dir", "./bench", "directory holding the generated files")
+	reportPath := fs.String("report", "", "write the report as JSON to this file")
+	baselinePath := fs.String("baseline", "", "compare against a report from an earlier run")
+	tolerance := fs.Float64("tolerance", 10, "percent a phase may be slower, or peak RSS higher, than the baseline")
+	skipExport := fs.Bool("skip-export", false, "only run the imports")
+	logLevel := fs.String("log-level", "warn", "level of the pipeline's own logs")
+	fs.Parse(args)
+
+	var baseline *report
+	if *baselinePath != "" {
+		b, err := readReport(*baselinePath)
+		if err != nil {
+			return fmt.Errorf("failed to read baseline: %w", err)
+		}
+		baseline = b
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	logs, err := logger.Configure(logger.Config{Outputs: cfg.Log.Outputs, Level: *logLevel})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	db, err := postgres.NewConnection(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	userRepo := postgres.NewUserRepository(db)
+	articleRepo := postgres.NewArticleRepository(db)
+	commentRepo := postgres.NewCommentRepository(db)
+	jobRepo := postgres.NewJobRepository(db)
+	metricsCollector := metrics.NewCollector()
+
+	importSvc := importservice.NewService(
+		userRepo,
+		articleRepo,
+		commentRepo,
+		jobRepo,
+		postgres.NewStagingRepository(db),
+		postgres.NewProfileRepository(db),
+		db,
+		store,
+		metricsCollector,
+		logs.Component("import"),
+		cfg.Import,
+	)
+	exportSvc := exportservice.NewService(
+		db,
+		userRepo,
+		articleRepo,
+		commentRepo,
+		postgres.NewTombstoneRepository(db),
+		jobRepo,
+		store,
+		cfg.Storage.SignedURLTTL,
+		metricsCollector,
+		logs.Component("export"),
+		cfg.Export,
+	)
+
+	b := &bench{dir: *dir, importSvc: importSvc, exportSvc: exportSvc, jobRepo: jobRepo}
+	ctx := context.Background()
+	rep := &report{StartedAt: time.Now().UTC(), ImportBatchSize: cfg.Import.BatchSize}
+
+	// Imports run in dependency order so articles and comments find their
+	// users and articles
+	for _, imp := range []struct {
+		resource models.ResourceType
+		file     string
+		format   string
+	}{
+		{models.ResourceTypeUsers, usersFile, "csv"},
+		{models.ResourceTypeArticles, articlesFile, "ndjson"},
+		{models.ResourceTypeComments, commentsFile, "ndjson"},
+	} {
+		p, err := b.runImport(ctx, imp.resource, imp.file, imp.format)
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			rep.add(*p)
+		}
+	}
+
+	if !*skipExport {
+		for _, resource := range []models.ResourceType{models.ResourceTypeUsers, models.ResourceTypeArticles, models.ResourceTypeComments} {
+			p, err := b.runExport(ctx, resource)
+			if err != nil {
+				return err
+			}
+			rep.add(*p)
+		}
+	}
+
+	rep.PeakRSSBytes = peakRSS()
+	rep.print(os.Stdout)
+
+	if *reportPath != "" {
+		if err := rep.write(*reportPath); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	if baseline != nil {
+		if regressions := rep.compare(baseline, *tolerance); len(regressions) > 0 {
+			for _, r := range regressions {
+				fmt.Fprintln(os.Stderr, "regression:", r)
+			}
+			return fmt.Errorf("%d regression(s) against %s", len(regressions), *baselinePath)
+		}
+		fmt.Printf("no regressions against %s (tolerance %.0f%%)\n", *baselinePath, *tolerance)
+	}
+	return nil
+}
+
+type bench struct {
+	dir       string
+	importSvc *importservice.Service
+	exportSvc *exportservice.Service
+	jobRepo   *postgres.JobRepository
+}
+
+// runImport imports one generated file, or returns nil if it wasn't generated
+func (b *bench) runImport(ctx context.Context, resource models.ResourceType, name, format string) (*phase, error) {
+	path := filepath.Join(b.dir, name)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	job := &models.Job{
+		ID:       uuid.New(),
+		Type:     models.JobTypeImport,
+		Resource: resource,
+		Status:   models.JobStatusPending,
+		TenantID: "benchgen",
+		FilePath: &path,
+		Params:   &models.JobParams{Format: format, FileName: name},
+	}
+	if err := b.jobRepo.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create %s import job: %w", resource, err)
+	}
+
+	start := time.Now()
+	if err := b.importSvc.ProcessImport(ctx, file, job, format); err != nil {
+		return nil, fmt.Errorf("%s import failed: %w", resource, err)
+	}
+	elapsed := time.Since(start)
+
+	done, err := b.jobRepo.GetByID(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &phase{
+		Name:     "import_" + string(resource),
+		Rows:     int64(done.SuccessfulRecords + done.FailedRecords),
+		Failed:   int64(done.FailedRecords),
+		Duration: elapsed,
+	}, nil
+}
+
+// runExport streams every stored record of resource and discards the output
+func (b *bench) runExport(ctx context.Context, resource models.ResourceType) (*phase, error) {
+	var out countingWriter
+	filters := &models.ExportFilters{}
+
+	start := time.Now()
+	var err error
+	switch resource {
+	case models.ResourceTypeUsers:
+		err = b.exportSvc.StreamUsers(ctx, &out, filters)
+	case models.ResourceTypeArticles:
+		err = b.exportSvc.StreamArticles(ctx, &out, filters)
+	case models.ResourceTypeComments:
+		err = b.exportSvc.StreamComments(ctx, &out, filters)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s export failed: %w", resource, err)
+	}
+	return &phase{
+		Name:     "export_" + string(resource),
+		Rows:     out.lines,
+		Bytes:    out.bytes,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// countingWriter counts the NDJSON lines and bytes written to it
+type countingWriter struct {
+	lines int64
+	bytes int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.bytes += int64(len(p))
+	for _, c := range p {
+		if c == '\n' {
+			w.lines++
+		}
+	}
+	return len(p), nil
+}
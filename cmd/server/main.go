@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	stderrors "errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,24 +13,72 @@ import (
 
 	"github.com/rohit/bulk-import-export/internal/api"
 	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/events"
 	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository/cache"
 	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/search"
+	deliveryservice "github.com/rohit/bulk-import-export/internal/service/delivery"
 	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
 	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	reportservice "github.com/rohit/bulk-import-export/internal/service/report"
+	searchservice "github.com/rohit/bulk-import-export/internal/service/search"
+	seedservice "github.com/rohit/bulk-import-export/internal/service/seed"
+	signingservice "github.com/rohit/bulk-import-export/internal/service/signing"
+	"github.com/rohit/bulk-import-export/internal/storage"
 	"github.com/rohit/bulk-import-export/internal/worker"
 	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
 )
 
 func main() {
-	// Initialize logger
+	checkConfig := flag.Bool("check-config", false, "validate the configuration, print any problems and exit")
+	flag.Parse()
+
+	// Initialize logger; it is replaced once the log settings are loaded
 	log := logger.New()
 
 	// Load configuration
 	cfg, err := config.Load()
+	if *checkConfig {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("configuration OK")
+		return
+	}
+	var invalid *config.ValidationError
+	if stderrors.As(err, &invalid) {
+		log.Fatal().Strs("problems", invalid.Problems).Msg("Invalid configuration")
+	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load configuration")
 	}
 
+	logs, err := logger.Configure(logger.Config{
+		Outputs:         cfg.Log.Outputs,
+		FilePath:        cfg.Log.FilePath,
+		SyslogNetwork:   cfg.Log.SyslogNetwork,
+		SyslogAddr:      cfg.Log.SyslogAddr,
+		SyslogTag:       cfg.Log.SyslogTag,
+		Level:           cfg.Log.Level,
+		ComponentLevels: cfg.Log.ComponentLevels,
+		Sampling: logger.Sampling{
+			Burst:  uint32(cfg.Log.SampleBurst),
+			Period: cfg.Log.SamplePeriod,
+			Every:  uint32(cfg.Log.SampleEvery),
+		},
+		JobLogLines: cfg.Log.JobLines,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure logging")
+	}
+	defer logs.Close()
+	log = logs.Logger()
+
 	// Initialize metrics
 	metricsCollector := metrics.NewCollector()
 
@@ -39,13 +89,24 @@ func main() {
 	}
 	defer db.Close()
 
+	// Initialize storage driver for upload archives and export outputs
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize storage")
+	}
+
 	// Initialize repositories
 	userRepo := postgres.NewUserRepository(db)
 	articleRepo := postgres.NewArticleRepository(db)
 	commentRepo := postgres.NewCommentRepository(db)
-	jobRepo := postgres.NewJobRepository(db)
+	// Status polls are served from a short-lived cache of jobs
+	jobRepo := cache.NewJobRepository(postgres.NewJobRepository(db), cfg.App.StatusCacheTTL, metricsCollector)
 	stagingRepo := postgres.NewStagingRepository(db)
 	idempotencyRepo := postgres.NewIdempotencyRepository(db)
+	quotaRepo := postgres.NewQuotaRepository(db)
+	tombstoneRepo := postgres.NewTombstoneRepository(db)
+	profileRepo := postgres.NewProfileRepository(db)
+	usageRepo := postgres.NewUsageRepository(db)
 
 	// Initialize services
 	importSvc := importservice.NewService(
@@ -54,46 +115,147 @@ func main() {
 		commentRepo,
 		jobRepo,
 		stagingRepo,
+		profileRepo,
+		db,
+		store,
 		metricsCollector,
-		log,
+		logs.Component("import"),
 		cfg.Import,
 	)
 
+	importSvc.SetLedger(postgres.NewLedgerRepository(db))
+	importSvc.SetUploadSessions(postgres.NewUploadSessionRepository(db))
+	importSvc.SetBatchLog(postgres.NewBatchLogRepository(db))
+
 	exportSvc := exportservice.NewService(
+		db,
 		userRepo,
 		articleRepo,
 		commentRepo,
+		tombstoneRepo,
 		jobRepo,
+		store,
+		cfg.Storage.SignedURLTTL,
 		metricsCollector,
-		log,
+		logs.Component("export"),
 		cfg.Export,
 	)
 
+	quotaSvc := quotaservice.NewService(quotaRepo, logs.Component("quota"), cfg.Quota)
+	reportSvc := reportservice.NewService(usageRepo, logs.Component("report"), cfg.Report)
+	seedSvc := seedservice.NewService(userRepo, articleRepo, logs.Component("seed"))
+
+	// Sign export manifests and download URLs when enabled
+	var signingSvc *signingservice.Service
+	if cfg.Signing.Enabled {
+		signingSvc = signingservice.NewService(postgres.NewSigningKeyRepository(db), logs.Component("signing"), cfg.Signing)
+		if err := signingSvc.Init(context.Background()); err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize signing keys")
+		}
+	}
+
+	// Publish cache invalidation events as imports write records
+	if cfg.Events.Driver != "" {
+		publisher, err := events.New(cfg.Events)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize event publisher")
+		}
+		emitter := events.NewEmitter(publisher, metricsCollector, logs.Component("events"), cfg.Events)
+		defer emitter.Close()
+		importSvc.RegisterHooks(emitter)
+	}
+
+	// Send notifications through a queue that retries failed deliveries,
+	// signing webhooks when signing is enabled
+	deliverySvc := deliveryservice.NewService(postgres.NewDeliveryRepository(db), metricsCollector, logs.Component("delivery"), cfg.Delivery)
+	var webhookSigner events.Signer
+	if signingSvc != nil {
+		webhookSigner = signingSvc
+	}
+	deliverySvc.RegisterChannel(events.ChannelWebhook, events.NewWebhook(webhookSigner))
+
+	// Alert when the circuit breaker pauses an import
+	if cfg.Import.AlertWebhookURL != "" {
+		importSvc.RegisterHooks(events.NewAlertWebhook(cfg.Import.AlertWebhookURL, deliverySvc, logs.Component("events")))
+	}
+
+	// Sync imported articles into the search index when enabled
+	var searchSvc *searchservice.Service
+	if cfg.Search.Enabled {
+		searchClient, err := search.NewClient(cfg.Search)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize search client")
+		}
+		searchSvc = searchservice.NewService(searchClient, articleRepo, jobRepo, logs.Component("search"), cfg.Search)
+		importSvc.RegisterHooks(searchSvc)
+	}
+
 	// Initialize worker pool
 	workerPool := worker.NewPool(
 		importSvc,
 		exportSvc,
 		jobRepo,
 		metricsCollector,
-		log,
+		logs.Component("worker"),
 		cfg.Worker,
 	)
+	if searchSvc != nil {
+		// Index jobs record only their parent import, not the articles to
+		// index, so they have no Payload loader and aren't recovered
+		if err := workerPool.Register(models.JobTypeIndex, worker.JobTypeConfig{Handler: searchSvc.RunIndexJob, Workers: 1}); err != nil {
+			log.Fatal().Err(err).Msg("Failed to register search index jobs")
+		}
+		searchSvc.SetQueue(workerPool)
+	}
+	workerPool.SetLogCapture(logs.Capture())
+	workerPool.SetIdentity(cfg.App.InstanceID, cfg.App.Version)
 
 	// Start worker pool
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	workerPool.Start(ctx)
 
+	// Send queued notifications and retry the ones that failed
+	go deliverySvc.Run(ctx)
+
+	// Roll up daily usage for reports when enabled
+	if cfg.Report.RollupEnabled {
+		go reportSvc.Run(ctx)
+	}
+
+	// Remove expired export files, releasing their storage quota
+	if cfg.Export.FileExpiry > 0 {
+		go exportSvc.RunExpiry(ctx)
+	}
+
+	// Apply tunable settings changed on SIGHUP or through the admin API
+	reloader := config.NewReloader(cfg)
+	reloader.OnChange(func(cfg *config.Config) {
+		importSvc.SetConfig(cfg.Import)
+		exportSvc.SetConfig(cfg.Export, cfg.Storage.SignedURLTTL)
+		quotaSvc.SetConfig(cfg.Quota)
+		workerPool.Resize(cfg.Worker.ImportWorkers, cfg.Worker.ExportWorkers)
+	})
+	go reloadOnSignal(ctx, reloader, log)
+
 	// Initialize router
 	router := api.NewRouter(
 		db.DB,
 		importSvc,
 		exportSvc,
+		quotaSvc,
+		reportSvc,
+		seedSvc,
+		signingSvc,
+		deliverySvc,
 		jobRepo,
 		idempotencyRepo,
 		workerPool,
+		reloader,
 		metricsCollector,
-		log,
+		logs.Levels(),
+		logs.Capture(),
+		logs.Component("http"),
 		cfg,
 	)
 
@@ -104,6 +266,8 @@ func main() {
 		ReadTimeout:  time.Duration(cfg.App.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.App.WriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(cfg.App.IdleTimeout) * time.Second,
+		// Headers sent a byte at a time can't hold a connection open
+		ReadHeaderTimeout: time.Duration(cfg.App.ReadHeaderTimeout) * time.Second,
 	}
 
 	// Start server in goroutine
@@ -111,12 +275,26 @@ func main() {
 		log.Info().
 			Int("port", cfg.App.Port).
 			Str("env", cfg.App.Env).
+			Str("instance", cfg.App.InstanceID).
+			Str("version", cfg.App.Version).
 			Msg("Starting server")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Server failed")
 		}
 	}()
 
+	// Serve metrics on their own port so they can be firewalled from the API
+	var metricsSrv *http.Server
+	if cfg.Prometheus.Enabled && cfg.Prometheus.Port != cfg.App.Port {
+		metricsSrv = metrics.NewServer(cfg.Prometheus.Port)
+		go func() {
+			log.Info().Int("port", cfg.Prometheus.Port).Msg("Starting metrics server")
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Metrics server failed")
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -138,6 +316,40 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Error().Err(err).Msg("Server forced to shutdown")
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Metrics server forced to shutdown")
+		}
+	}
 
 	log.Info().Msg("Server exited")
 }
+
+// reloadOnSignal reloads the tunable settings each time the process gets
+// SIGHUP, until ctx ends
+func reloadOnSignal(ctx context.Context, reloader *config.Reloader, log zerolog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			changes, err := reloader.Reload()
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to reload configuration")
+				continue
+			}
+			for _, change := range changes {
+				log.Info().
+					Str("setting", change.Key).
+					Str("from", change.From).
+					Str("to", change.To).
+					Msg("Setting changed")
+			}
+			log.Info().Int("changed", len(changes)).Msg("Configuration reloaded")
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// AdminHandler handles operational requests under /admin
+type AdminHandler struct {
+	levels *logger.Levels
+	logger zerolog.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(levels *logger.Levels, logger zerolog.Logger) *AdminHandler {
+	return &AdminHandler{
+		levels: levels,
+		logger: logger,
+	}
+}
+
+// SetLogLevelRequest changes the level of one component. Component "default"
+// sets the level of components without their own; Reset drops a
+// component's own level.
+type SetLogLevelRequest struct {
+	Component string `json:"component" binding:"required"`
+	Level     string `json:"level,omitempty"`
+	Reset     bool   `json:"reset,omitempty"`
+}
+
+// GetLogLevels handles GET /admin/log-levels
+func (h *AdminHandler) GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"levels": h.levels.All()})
+}
+
+// SetLogLevel handles PUT /admin/log-levels
+func (h *AdminHandler) SetLogLevel(c *gin.Context) {
+	var req SetLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
+		return
+	}
+
+	if req.Reset {
+		if req.Component == logger.DefaultComponent {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the default level can't be reset, set it instead"})
+			return
+		}
+		h.levels.Reset(req.Component)
+	} else {
+		level, err := logger.ParseLevel(req.Level)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.levels.Set(req.Component, level)
+	}
+
+	h.logger.Info().
+		Str("log_component", req.Component).
+		Str("level", h.levels.Get(req.Component).String()).
+		Msg("Log level changed")
+	c.JSON(http.StatusOK, gin.H{"levels": h.levels.All()})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+)
+
+// Headers carrying a client-computed digest of an upload. Either may be set
+// on the request or, for multipart uploads, on the file part itself.
+const (
+	ContentMD5Header    = "Content-MD5"
+	ContentSHA256Header = "X-Content-SHA256"
+)
+
+// headerGetter is satisfied by http.Header and textproto.MIMEHeader
+type headerGetter interface {
+	Get(key string) string
+}
+
+// uploadChecksum verifies an upload against the digests its client sent
+type uploadChecksum struct {
+	checks []digestCheck
+}
+
+type digestCheck struct {
+	name string
+	want []byte
+	hash hash.Hash
+}
+
+// newUploadChecksum reads the digest headers, taking the first of headers
+// that sets each one. Digests may be base64 or hex encoded.
+func newUploadChecksum(headers ...headerGetter) (*uploadChecksum, error) {
+	u := &uploadChecksum{}
+	for _, d := range []struct {
+		header string
+		name   string
+		newFn  func() hash.Hash
+	}{
+		{ContentMD5Header, "md5", md5.New},
+		{ContentSHA256Header, "sha256", sha256.New},
+	} {
+		value := ""
+		for _, h := range headers {
+			if value = h.Get(d.header); value != "" {
+				break
+			}
+		}
+		if value == "" {
+			continue
+		}
+		h := d.newFn()
+		want, err := decodeDigest(value, h.Size())
+		if err != nil {
+			return nil, errors.ErrInvalidRequest(fmt.Sprintf("invalid %s header: %v", d.header, err))
+		}
+		u.checks = append(u.checks, digestCheck{name: d.name, want: want, hash: h})
+	}
+	return u, nil
+}
+
+// Reader returns r with everything read from it also hashed
+func (u *uploadChecksum) Reader(r io.Reader) io.Reader {
+	if len(u.checks) == 0 {
+		return r
+	}
+	writers := make([]io.Writer, len(u.checks))
+	for i, c := range u.checks {
+		writers[i] = c.hash
+	}
+	return io.TeeReader(r, io.MultiWriter(writers...))
+}
+
+// Verify compares the digests of what was read with the ones sent
+func (u *uploadChecksum) Verify() error {
+	for _, c := range u.checks {
+		if got := c.hash.Sum(nil); !bytes.Equal(got, c.want) {
+			return errors.ErrChecksumMismatch(fmt.Sprintf("%s of the upload is %s, expected %s",
+				c.name, hex.EncodeToString(got), hex.EncodeToString(c.want)))
+		}
+	}
+	return nil
+}
+
+// decodeDigest decodes a hex or base64 digest of size bytes
+func decodeDigest(value string, size int) ([]byte, error) {
+	if len(value) == hex.EncodedLen(size) {
+		if b, err := hex.DecodeString(value); err == nil {
+			return b, nil
+		}
+	}
+	if b, err := base64.StdEncoding.DecodeString(value); err == nil && len(b) == size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("expected a %d-byte digest in hex or base64", size)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// ConfigHandler shows the running configuration and changes its tunable
+// settings without a restart
+type ConfigHandler struct {
+	reloader *config.Reloader
+	logger   zerolog.Logger
+}
+
+// NewConfigHandler creates a new config handler
+func NewConfigHandler(reloader *config.Reloader, logger zerolog.Logger) *ConfigHandler {
+	return &ConfigHandler{
+		reloader: reloader,
+		logger:   logger,
+	}
+}
+
+// UpdateConfigRequest changes tunable settings. Reload first re-reads the
+// environment and CONFIG_FILE, as SIGHUP does, dropping earlier overrides;
+// Settings then overrides settings by their environment variable name.
+type UpdateConfigRequest struct {
+	Reload   bool                       `json:"reload,omitempty"`
+	Settings map[string]json.RawMessage `json:"settings,omitempty"`
+}
+
+// ConfigResponse is the configuration in effect, by section, with secrets
+// redacted. Overrides are the settings changed through this API since the
+// last reload.
+type ConfigResponse struct {
+	Settings   map[string]map[string]interface{} `json:"settings"`
+	Reloadable []string                          `json:"reloadable"`
+	Overrides  map[string]string                 `json:"overrides,omitempty"`
+	ChangedAt  *time.Time                        `json:"changed_at,omitempty"`
+	Changes    []config.Change                   `json:"changes,omitempty"`
+}
+
+// GetConfig handles GET /v1/admin/config
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, h.response(nil))
+}
+
+// UpdateConfig handles PATCH /v1/admin/config
+func (h *ConfigHandler) UpdateConfig(c *gin.Context) {
+	var req UpdateConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
+		return
+	}
+	if !req.Reload && len(req.Settings) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "set reload or at least one setting"})
+		return
+	}
+
+	// Numbers and strings are both accepted, as in "IMPORT_BATCH_SIZE": 500
+	values := make(map[string]string, len(req.Settings))
+	for key, raw := range req.Settings {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		values[key] = s
+	}
+	if err := config.ValidateTunables(values); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var changes []config.Change
+	if req.Reload {
+		reloaded, err := h.reloader.Reload()
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to reload configuration")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reload configuration: " + err.Error()})
+			return
+		}
+		changes = append(changes, reloaded...)
+	}
+	if len(values) > 0 {
+		set, err := h.reloader.Set(values)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		changes = append(changes, set...)
+	}
+
+	for _, change := range changes {
+		h.logger.Info().
+			Str("setting", change.Key).
+			Str("from", change.From).
+			Str("to", change.To).
+			Msg("Setting changed")
+	}
+	c.JSON(http.StatusOK, h.response(changes))
+}
+
+func (h *ConfigHandler) response(changes []config.Change) ConfigResponse {
+	cfg, changedAt := h.reloader.Current()
+	resp := ConfigResponse{
+		Settings:   cfg.Redacted(),
+		Reloadable: config.TunableKeys(),
+		Overrides:  h.reloader.Overrides(),
+		Changes:    changes,
+	}
+	if !changedAt.IsZero() {
+		resp.ChangedAt = &changedAt
+	}
+	return resp
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rs/zerolog"
+)
+
+func TestConfigHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("UPLOAD_PATH", filepath.Join(dir, "uploads"))
+	t.Setenv("EXPORT_PATH", filepath.Join(dir, "exports"))
+	t.Setenv("STORAGE_PATH", filepath.Join(dir, "storage"))
+	t.Setenv("DB_PASSWORD", "hunter2")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	reloader := config.NewReloader(cfg)
+	// Overrides are process-wide; don't leave them for other tests
+	t.Cleanup(func() { reloader.Reload() })
+
+	var applied int
+	reloader.OnChange(func(c *config.Config) { applied = c.Import.BatchSize })
+
+	h := NewConfigHandler(reloader, zerolog.Nop())
+	router := gin.New()
+	router.GET("/v1/admin/config", h.GetConfig)
+	router.PATCH("/v1/admin/config", h.UpdateConfig)
+	do := func(method, body string) (*httptest.ResponseRecorder, ConfigResponse) {
+		req := httptest.NewRequest(method, "/v1/admin/config", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp ConfigResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := do(http.MethodGet, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, body %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Error("GET shows the database password")
+	}
+	if resp.Settings["import"]["BatchSize"] != float64(1000) || len(resp.Reloadable) == 0 {
+		t.Errorf("settings = %v, reloadable %v", resp.Settings["import"], resp.Reloadable)
+	}
+
+	for _, body := range []string{
+		`{}`,
+		`{"settings": {"DB_HOST": "elsewhere"}}`,
+		`{"settings": {"IMPORT_BATCH_SIZE": 0}}`,
+	} {
+		if w, _ := do(http.MethodPatch, body); w.Code != http.StatusBadRequest {
+			t.Errorf("PATCH %s: status = %d, want 400", body, w.Code)
+		}
+	}
+
+	w, resp = do(http.MethodPatch, `{"settings": {"IMPORT_BATCH_SIZE": 200, "EXPORT_BATCH_SIZE": "800"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body %s", w.Code, w.Body.String())
+	}
+	if len(resp.Changes) != 2 || resp.Overrides["IMPORT_BATCH_SIZE"] != "200" || resp.ChangedAt == nil {
+		t.Errorf("response = %+v, want two changes and the overrides", resp)
+	}
+	if resp.Settings["export"]["BatchSize"] != float64(800) || applied != 200 {
+		t.Errorf("export batch size %v, applied import batch size %d; want 800 and 200", resp.Settings["export"]["BatchSize"], applied)
+	}
+
+	// A reload goes back to the environment
+	w, resp = do(http.MethodPatch, `{"reload": true}`)
+	if w.Code != http.StatusOK || applied != 1000 || len(resp.Overrides) != 0 {
+		t.Errorf("reload status %d, applied %d, overrides %v; want 200, 1000 and none", w.Code, applied, resp.Overrides)
+	}
+}
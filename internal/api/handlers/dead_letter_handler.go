@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+// DeadLetterHandler lists jobs that failed every retry and queues them again
+type DeadLetterHandler struct {
+	jobRepo    repository.JobRepository
+	workerPool *worker.Pool
+	logger     zerolog.Logger
+}
+
+// NewDeadLetterHandler creates a new dead letter handler
+func NewDeadLetterHandler(jobRepo repository.JobRepository, workerPool *worker.Pool, logger zerolog.Logger) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		jobRepo:    jobRepo,
+		workerPool: workerPool,
+		logger:     logger,
+	}
+}
+
+// ListDeadLettersResponse represents the response for listing dead letters
+type ListDeadLettersResponse struct {
+	Jobs       []DeadLetterItem         `json:"jobs"`
+	Pagination DeadLetterPaginationInfo `json:"pagination"`
+}
+
+// DeadLetterItem represents a dead-lettered job
+type DeadLetterItem struct {
+	JobID        string     `json:"job_id"`
+	Type         string     `json:"type"`
+	Resource     string     `json:"resource"`
+	TenantID     string     `json:"tenant_id"`
+	Attempts     int        `json:"attempts"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FailedAt     *time.Time `json:"failed_at,omitempty"`
+	// Worker is what ran the last failed attempt
+	Worker *JobWorkerInfo `json:"worker,omitempty"`
+}
+
+// DeadLetterPaginationInfo represents pagination information for dead letters
+type DeadLetterPaginationInfo struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	TotalJobs  int64 `json:"total_jobs"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// ListDeadLetters handles GET /v1/admin/dead-letters
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "100"))
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	jobs, total, err := h.jobRepo.ListByStatus(c.Request.Context(), models.JobStatusDeadLetter, page, perPage)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list dead letters")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list dead letters"})
+		return
+	}
+
+	items := make([]DeadLetterItem, 0, len(jobs))
+	for _, job := range jobs {
+		item := DeadLetterItem{
+			JobID:     job.ID.String(),
+			Type:      string(job.Type),
+			Resource:  string(job.Resource),
+			TenantID:  job.TenantID,
+			Attempts:  job.Attempts,
+			CreatedAt: job.CreatedAt,
+			FailedAt:  job.CompletedAt,
+			Worker:    jobWorker(job),
+		}
+		if job.ErrorMessage != nil {
+			item.ErrorMessage = *job.ErrorMessage
+		}
+		items = append(items, item)
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, ListDeadLettersResponse{
+		Jobs: items,
+		Pagination: DeadLetterPaginationInfo{
+			Page:       page,
+			PerPage:    perPage,
+			TotalJobs:  total,
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// RequeueDeadLetter handles POST /v1/admin/dead-letters/:job_id/requeue. The
+// job keeps its ID and starts again with a fresh set of attempts.
+func (h *DeadLetterHandler) RequeueDeadLetter(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if job.Status != models.JobStatusDeadLetter {
+		c.JSON(http.StatusConflict, gin.H{"error": "job is not dead-lettered"})
+		return
+	}
+
+	if err := h.workerPool.Requeue(c.Request.Context(), job); err != nil {
+		switch {
+		case errors.Is(err, worker.ErrUploadMissing):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, worker.ErrNotRequeueable):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to requeue job")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "failed to requeue job: " + err.Error()})
+		return
+	}
+
+	h.logger.Info().Str("job_id", job.ID.String()).Msg("Dead-lettered job requeued")
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":   job.ID.String(),
+		"type":     string(job.Type),
+		"status":   string(job.Status),
+		"attempts": job.Attempts,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	deliveryservice "github.com/rohit/bulk-import-export/internal/service/delivery"
+	"github.com/rs/zerolog"
+)
+
+// DeliveryHandler lists queued notification deliveries and requeues the
+// dead-lettered ones
+type DeliveryHandler struct {
+	deliverySvc *deliveryservice.Service
+	logger      zerolog.Logger
+}
+
+// NewDeliveryHandler creates a new delivery handler
+func NewDeliveryHandler(deliverySvc *deliveryservice.Service, logger zerolog.Logger) *DeliveryHandler {
+	return &DeliveryHandler{
+		deliverySvc: deliverySvc,
+		logger:      logger,
+	}
+}
+
+// ListDeliveriesResponse represents the response for listing deliveries
+type ListDeliveriesResponse struct {
+	Deliveries []DeliveryItem         `json:"deliveries"`
+	Pagination DeliveryPaginationInfo `json:"pagination"`
+}
+
+// DeliveryItem represents a queued delivery. Its target isn't shown, as
+// webhook URLs often carry a token.
+type DeliveryItem struct {
+	DeliveryID    string          `json:"delivery_id"`
+	Channel       string          `json:"channel"`
+	Event         string          `json:"event"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	MaxAttempts   int             `json:"max_attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// DeliveryPaginationInfo represents pagination information for deliveries
+type DeliveryPaginationInfo struct {
+	Page            int   `json:"page"`
+	PerPage         int   `json:"per_page"`
+	TotalDeliveries int64 `json:"total_deliveries"`
+	TotalPages      int   `json:"total_pages"`
+}
+
+// ListDeliveries handles GET /v1/admin/deliveries. It lists the
+// dead-lettered deliveries unless status asks for pending or delivered ones.
+func (h *DeliveryHandler) ListDeliveries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "100"))
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	status := models.DeliveryStatus(c.DefaultQuery("status", string(models.DeliveryDeadLetter)))
+	switch status {
+	case models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDeadLetter:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or dead_letter"})
+		return
+	}
+
+	deliveries, total, err := h.deliverySvc.List(c.Request.Context(), status, page, perPage)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list deliveries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list deliveries"})
+		return
+	}
+
+	items := make([]DeliveryItem, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		items = append(items, deliveryItem(delivery))
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, ListDeliveriesResponse{
+		Deliveries: items,
+		Pagination: DeliveryPaginationInfo{
+			Page:            page,
+			PerPage:         perPage,
+			TotalDeliveries: total,
+			TotalPages:      totalPages,
+		},
+	})
+}
+
+// RequeueDelivery handles POST /v1/admin/deliveries/:delivery_id/requeue.
+// The delivery gets a fresh set of attempts, the first of them at once.
+func (h *DeliveryHandler) RequeueDelivery(c *gin.Context) {
+	deliveryID, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery_id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	requeued, err := h.deliverySvc.Requeue(ctx, deliveryID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("delivery_id", deliveryID.String()).Msg("Failed to requeue delivery")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to requeue delivery"})
+		return
+	}
+	if !requeued {
+		delivery, err := h.deliverySvc.Get(ctx, deliveryID)
+		switch {
+		case err != nil:
+			h.logger.Error().Err(err).Msg("Failed to get delivery")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get delivery"})
+		case delivery == nil:
+			c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": "delivery is not dead-lettered"})
+		}
+		return
+	}
+
+	delivery, err := h.deliverySvc.Get(ctx, deliveryID)
+	if err != nil || delivery == nil {
+		h.logger.Error().Err(err).Msg("Failed to get delivery")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get delivery"})
+		return
+	}
+	h.logger.Info().Str("delivery_id", deliveryID.String()).Msg("Dead-lettered delivery requeued")
+	c.JSON(http.StatusAccepted, deliveryItem(delivery))
+}
+
+// deliveryItem reports a delivery, with its next attempt only while one is
+// due
+func deliveryItem(delivery *models.Delivery) DeliveryItem {
+	item := DeliveryItem{
+		DeliveryID:  delivery.ID.String(),
+		Channel:     delivery.Channel,
+		Event:       delivery.Event,
+		Status:      string(delivery.Status),
+		Attempts:    delivery.Attempts,
+		MaxAttempts: delivery.MaxAttempts,
+		Payload:     delivery.Payload,
+		CreatedAt:   delivery.CreatedAt,
+		DeliveredAt: delivery.DeliveredAt,
+	}
+	if delivery.LastError != nil {
+		item.LastError = *delivery.LastError
+	}
+	if delivery.Status == models.DeliveryPending {
+		next := delivery.NextAttemptAt
+		item.NextAttemptAt = &next
+	}
+	return item
+}
@@ -1,51 +1,118 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
 	"github.com/rohit/bulk-import-export/internal/config"
+	domainerrors "github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository"
 	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
+	"github.com/rohit/bulk-import-export/internal/service/export/avro"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	signingservice "github.com/rohit/bulk-import-export/internal/service/signing"
+	"github.com/rohit/bulk-import-export/internal/storage"
 	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rohit/bulk-import-export/pkg/signing"
 	"github.com/rs/zerolog"
 )
 
 // ExportHandler handles export-related HTTP requests
 type ExportHandler struct {
 	exportSvc  *exportservice.Service
-	jobRepo    *postgres.JobRepository
+	jobRepo    repository.JobRepository
+	quotaSvc   *quotaservice.Service
+	signingSvc *signingservice.Service
 	workerPool *worker.Pool
+	metrics    *metrics.Collector
 	logger     zerolog.Logger
-	config     config.ExportConfig
+	config     atomic.Pointer[config.ExportConfig]
+	streamSem  chan struct{}
 }
 
 // NewExportHandler creates a new export handler
 func NewExportHandler(
 	exportSvc *exportservice.Service,
-	jobRepo *postgres.JobRepository,
+	jobRepo repository.JobRepository,
+	quotaSvc *quotaservice.Service,
 	workerPool *worker.Pool,
+	metricsCollector *metrics.Collector,
 	logger zerolog.Logger,
 	cfg config.ExportConfig,
 ) *ExportHandler {
-	return &ExportHandler{
+	h := &ExportHandler{
 		exportSvc:  exportSvc,
 		jobRepo:    jobRepo,
+		quotaSvc:   quotaSvc,
 		workerPool: workerPool,
+		metrics:    metricsCollector,
 		logger:     logger,
-		config:     cfg,
+	}
+	h.config.Store(&cfg)
+	if cfg.MaxConcurrentStreams > 0 {
+		h.streamSem = make(chan struct{}, cfg.MaxConcurrentStreams)
+	}
+	return h
+}
+
+// SetConfig replaces the export settings when they are reloaded. The
+// number of concurrent streams is fixed when the handler is created.
+func (h *ExportHandler) SetConfig(cfg config.ExportConfig) {
+	h.config.Store(&cfg)
+}
+
+// SetSigner signs download URLs and manifests with signingSvc, and checks
+// the signature of downloads
+func (h *ExportHandler) SetSigner(signingSvc *signingservice.Service) {
+	h.signingSvc = signingSvc
+}
+
+// acquireStream reserves a streaming export slot, returning false when all slots are in use
+func (h *ExportHandler) acquireStream() bool {
+	if h.streamSem != nil {
+		select {
+		case h.streamSem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	if h.metrics != nil {
+		h.metrics.SetActiveExportStreams(1)
+	}
+	return true
+}
+
+// releaseStream frees a slot reserved by acquireStream
+func (h *ExportHandler) releaseStream() {
+	if h.streamSem != nil {
+		<-h.streamSem
+	}
+	if h.metrics != nil {
+		h.metrics.SetActiveExportStreams(-1)
 	}
 }
 
 // StreamExport handles GET /v1/exports (streaming export)
 func (h *ExportHandler) StreamExport(c *gin.Context) {
+	cfg := h.config.Load()
 	// Get parameters
 	resourceStr := c.Query("resource")
 	if resourceStr == "" {
@@ -62,53 +129,222 @@ func (h *ExportHandler) StreamExport(c *gin.Context) {
 	}
 
 	format := c.DefaultQuery("format", "ndjson")
-	if format != "ndjson" && format != "json" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'ndjson' or 'json'"})
+	if format != "ndjson" && format != "json" && format != exportservice.FormatAvro {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'ndjson', 'json' or 'avro'"})
+		return
+	}
+
+	groupBy, err := parseGroupBy(resource, c.Query("group_by"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if groupBy != "" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by requires format 'ndjson'"})
+		return
+	}
+	withCounts := c.Query("with_counts") == "true"
+	if withCounts && resource != models.ResourceTypeUsers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "with_counts is only supported for user exports"})
+		return
+	}
+	if withCounts && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "with_counts requires format 'ndjson'"})
 		return
 	}
 
 	// Parse filters
 	filters := h.parseFilters(c)
 
+	// Limit concurrent streams so they can't exhaust DB connections
+	if !h.acquireStream() {
+		if cfg.StreamOverflowMode == "async" {
+			h.enqueueExport(c, resource, &models.JobParams{Format: format, Filters: filters, GroupBy: groupBy, WithCounts: withCounts}, nil)
+			return
+		}
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many concurrent streaming exports, retry later or use POST /v1/exports"})
+		return
+	}
+	release := sync.OnceFunc(h.releaseStream)
+	defer release()
+
+	// Record the watermark before any rows are read
+	ctx, snapshot, err := h.exportSvc.BeginSnapshot(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to begin export snapshot")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start export"})
+		return
+	}
+	defer snapshot.Close()
+	c.Header("X-Data-As-Of", snapshot.AsOf.Format(time.RFC3339Nano))
+
 	// Set appropriate content type
-	if format == "ndjson" {
+	switch format {
+	case "ndjson":
 		c.Header("Content-Type", "application/x-ndjson")
-	} else {
+	case exportservice.FormatAvro:
+		c.Header("Content-Type", exportservice.ExportContentType(".avro"))
+	default:
 		c.Header("Content-Type", "application/json")
 	}
 	c.Header("Transfer-Encoding", "chunked")
+	// Declared up front so they can be sent after the body
+	c.Header("Trailer", "X-Export-Status, X-Export-Record-Count")
 
-	// Get the response writer
-	w := c.Writer
+	// A client too slow to keep up gets the rest of the export from disk,
+	// so the export doesn't hold its database connection for as long as the
+	// client takes to read it
+	spill := newSpillWriter(c.Writer, cfg.StreamSpillAfter, "", cfg.StreamSpillMaxBytes)
 
-	var err error
-	if format == "json" {
-		err = h.exportSvc.StreamJSON(c.Request.Context(), w, resource, filters)
-	} else {
+	// Compress for clients that accept it. Keepalives go through the
+	// compressor, so they stay valid within the compressed stream. Avro
+	// compresses its own blocks.
+	var out io.Writer = spill
+	var compressed *exportservice.CompressedWriter
+	if cfg.StreamCompression && format != exportservice.FormatAvro {
+		c.Header("Vary", "Accept-Encoding")
+		if compression, encoding := exportservice.NegotiateEncoding(c.GetHeader("Accept-Encoding")); compression != "" {
+			// EXPORT_COMPRESSION_LEVEL is a level of EXPORT_COMPRESSION's
+			// codec; any other codec gets its default
+			level := 0
+			if string(compression) == cfg.Compression {
+				level = cfg.CompressionLevel
+			}
+			if compressed, err = exportservice.NewCompressedWriter(spill, compression, level); err != nil {
+				h.logger.Warn().Err(err).Msg("Failed to compress export stream")
+			} else {
+				c.Header("Content-Encoding", encoding)
+				out = compressed
+			}
+		}
+	}
+
+	// Buffer the response, flushing per batch and keeping it alive while
+	// selective filters scan. A newline would corrupt an Avro file, so Avro
+	// streams go without keepalives.
+	keepalive := cfg.StreamKeepalive
+	if format == exportservice.FormatAvro {
+		keepalive = 0
+	}
+	stream := newStreamWriter(out, cfg.StreamBufferSize, keepalive)
+	w := &ndjsonCounter{w: stream}
+
+	var recordCount int
+	switch format {
+	case "json":
+		recordCount, err = h.exportSvc.StreamJSON(ctx, w, resource, filters)
+	case exportservice.FormatAvro:
+		recordCount, err = h.exportSvc.StreamAvro(ctx, stream, resource, filters)
+	default:
 		// Stream NDJSON
 		switch resource {
 		case models.ResourceTypeUsers:
-			err = h.exportSvc.StreamUsers(c.Request.Context(), w, filters)
+			if withCounts {
+				err = h.exportSvc.StreamUsersWithCounts(ctx, w, filters)
+			} else {
+				err = h.exportSvc.StreamUsers(ctx, w, filters)
+			}
 		case models.ResourceTypeArticles:
-			err = h.exportSvc.StreamArticles(c.Request.Context(), w, filters)
+			err = h.exportSvc.StreamArticles(ctx, w, filters)
 		case models.ResourceTypeComments:
-			err = h.exportSvc.StreamComments(c.Request.Context(), w, filters)
+			if groupBy == models.ExportGroupByArticle {
+				err = h.exportSvc.StreamCommentsByArticle(ctx, w, filters)
+			} else {
+				err = h.exportSvc.StreamComments(ctx, w, filters)
+			}
 		}
+		recordCount = w.lines
 	}
+	stream.Close()
 
+	trailer := StreamTrailer{
+		Status:      StreamStatusComplete,
+		RecordCount: recordCount,
+		DataAsOf:    snapshot.AsOf.Format(time.RFC3339Nano),
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Export streaming failed")
-		// Can't send error response after streaming started
-		return
+		// Can't send error response after streaming started; the trailer
+		// tells the client the body is incomplete
+		trailer.Status = StreamStatusFailed
+		trailer.Error = "export stream failed before all records were sent"
+	}
+
+	if format == "ndjson" && c.Query("metadata") == "true" {
+		if data, err := json.Marshal(gin.H{"_meta": trailer}); err == nil {
+			out.Write(append(data, '\n'))
+		}
+	}
+	if compressed != nil {
+		compressed.Close()
+	}
+
+	// Every record has been read, so a spilled stream drains without the
+	// snapshot or a stream slot
+	snapshot.Close()
+	release()
+	if spill.Spilled() {
+		h.logger.Info().Str("resource", string(resource)).Int("records", recordCount).Msg("Serving slow export stream from disk")
+	}
+	if err := spill.Close(); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to send spilled export stream")
+	}
+	c.Writer.Header().Set("X-Export-Status", trailer.Status)
+	c.Writer.Header().Set("X-Export-Record-Count", strconv.Itoa(trailer.RecordCount))
+}
+
+// Stream trailer statuses
+const (
+	StreamStatusComplete = "complete"
+	StreamStatusFailed   = "failed"
+)
+
+// StreamTrailer describes how a streaming export ended. It is sent as the
+// X-Export-Status and X-Export-Record-Count HTTP trailers and, with
+// metadata=true, as a final {"_meta": ...} NDJSON line.
+type StreamTrailer struct {
+	Status      string `json:"status"`
+	RecordCount int    `json:"record_count"`
+	DataAsOf    string `json:"data_as_of"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ndjsonCounter counts the NDJSON lines written through it
+type ndjsonCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (n *ndjsonCounter) Write(p []byte) (int, error) {
+	written, err := n.w.Write(p)
+	n.lines += bytes.Count(p[:written], []byte{'\n'})
+	return written, err
+}
+
+// Flush passes batch-boundary flushes through to the stream
+func (n *ndjsonCounter) Flush() {
+	if f, ok := n.w.(http.Flusher); ok {
+		f.Flush()
 	}
 }
 
 // CreateAsyncExportRequest represents the request for async export
 type CreateAsyncExportRequest struct {
-	Resource string                 `json:"resource" binding:"required"`
-	Format   string                 `json:"format,omitempty"`
-	Filters  map[string]interface{} `json:"filters,omitempty"`
-	Fields   []string               `json:"fields,omitempty"`
+	Resource   string                 `json:"resource" binding:"required"`
+	Format     string                 `json:"format,omitempty"`
+	Filters    map[string]interface{} `json:"filters,omitempty"`
+	Fields     []string               `json:"fields,omitempty"`
+	GroupBy    string                 `json:"group_by,omitempty"`
+	WithCounts bool                   `json:"with_counts,omitempty"`
+	// FieldAliases renames fields in the exported records, keyed by field
+	FieldAliases map[string]string `json:"field_aliases,omitempty"`
+	// Priority is low, normal (default) or high
+	Priority string `json:"priority,omitempty"`
+	// Compression is none, gzip or zstd, overriding EXPORT_COMPRESSION, at
+	// CompressionLevel (0 for the codec's default)
+	Compression      string `json:"compression,omitempty"`
+	CompressionLevel int    `json:"compression_level,omitempty"`
 }
 
 // CreateAsyncExportResponse represents the response for creating async export
@@ -117,13 +353,45 @@ type CreateAsyncExportResponse struct {
 	Status    string `json:"status"`
 	Resource  string `json:"resource"`
 	CreatedAt string `json:"created_at"`
+	RerunOf   string `json:"rerun_of,omitempty"`
+	// DownloadURL and ErrorMessage are set when the export was small enough
+	// to run within the request
+	DownloadURL  *string `json:"download_url,omitempty"`
+	ErrorMessage *string `json:"error_message,omitempty"`
 }
 
-// CreateAsyncExport handles POST /v1/exports
+// cohortValueBytes allows for the longest email in a cohort file and its
+// line break
+const cohortValueBytes = 256
+
+// MaxRequestBytes is the largest request body CreateAsyncExport accepts: a
+// cohort file of EXPORT_COHORT_FILE_MAX values and its multipart framing
+func (h *ExportHandler) MaxRequestBytes() int64 {
+	return int64(h.config.Load().CohortFileMax)*cohortValueBytes + multipartOverhead
+}
+
+// CreateAsyncExport handles POST /v1/exports. The request is JSON, or a
+// multipart form with the same fields and a cohort_file of users to export.
 func (h *ExportHandler) CreateAsyncExport(c *gin.Context) {
+	cfg := h.config.Load()
 	var req CreateAsyncExportRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var cohortFile *multipart.FileHeader
+	if c.ContentType() == "multipart/form-data" {
+		var err error
+		if req, err = bindExportForm(c); err != nil {
+			if appErr := bodyError(err); appErr != nil {
+				respondError(c, h.logger, appErr)
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if cohortFile, err = c.FormFile("cohort_file"); err != nil && err != http.ErrMissingFile {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cohort_file"})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
 		return
 	}
 
@@ -139,17 +407,222 @@ func (h *ExportHandler) CreateAsyncExport(c *gin.Context) {
 	if format == "" {
 		format = "ndjson"
 	}
-	if format != "ndjson" && format != "json" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'ndjson' or 'json'"})
+	if format != "ndjson" && format != "json" && format != exportservice.FormatAvro && format != exportservice.FormatBodies && format != exportservice.FormatBundle {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'ndjson', 'json', 'avro', 'bodies' or 'bundle'"})
+		return
+	}
+	fileFormat := format == exportservice.FormatAvro || format == exportservice.FormatBodies || format == exportservice.FormatBundle
+	if fileFormat && (req.GroupBy != "" || req.WithCounts || req.Compression != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("group_by, with_counts and compression are not supported for %s exports", format)})
+		return
+	}
+	// Avro records follow their registered schema, and bodies exports name
+	// their own metadata fields
+	if fileFormat && (len(req.Fields) > 0 || len(req.FieldAliases) > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("fields and field_aliases are not supported for %s exports", format)})
+		return
+	}
+	if format == exportservice.FormatBodies && resource != models.ResourceTypeArticles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the bodies format is only supported for article exports"})
+		return
+	}
+	// A filtered bundle could hold comments without their articles
+	if format == exportservice.FormatBundle && (len(req.Filters) > 0 || cohortFile != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filters are not supported for bundle exports"})
+		return
+	}
+
+	groupBy, err := parseGroupBy(resource, req.GroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.WithCounts && resource != models.ResourceTypeUsers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "with_counts is only supported for user exports"})
+		return
+	}
+	if err := exportservice.ValidateFieldShape(resource, groupBy, req.WithCounts, req.Fields, req.FieldAliases); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.JobPriority(req.Priority).Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "priority must be 'low', 'normal' or 'high'"})
+		return
+	}
+	compression := models.ExportCompression(req.Compression)
+	if err := exportservice.ValidateCompression(compression, req.CompressionLevel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters := h.parseFiltersFromMap(req.Filters)
+	ids, emails, err := parseCohort(req.Filters, cohortFile, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(ids) > 0 || len(emails) > 0 {
+		if resource != models.ResourceTypeUsers {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ids, emails and cohort_file are only supported for user exports"})
+			return
+		}
+		if filters == nil {
+			filters = &models.ExportFilters{}
+		}
+		filters.IDs, filters.Emails = ids, emails
+		if err := h.exportSvc.SaveCohort(c.Request.Context(), filters); err != nil {
+			h.logger.Error().Err(err).Msg("Failed to save export cohort")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save cohort"})
+			return
+		}
+	}
+
+	h.enqueueExport(c, resource, &models.JobParams{
+		Format:           format,
+		Filters:          filters,
+		Fields:           req.Fields,
+		FieldAliases:     req.FieldAliases,
+		GroupBy:          groupBy,
+		WithCounts:       req.WithCounts,
+		Priority:         models.JobPriority(req.Priority),
+		Compression:      compression,
+		CompressionLevel: req.CompressionLevel,
+	}, nil)
+}
+
+// bindExportForm reads a CreateAsyncExportRequest from multipart form fields,
+// with filters and field_aliases as JSON objects and fields separated by
+// commas
+func bindExportForm(c *gin.Context) (CreateAsyncExportRequest, error) {
+	// PostForm swallows parse errors, which may be a body limit
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		return CreateAsyncExportRequest{}, err
+	}
+	req := CreateAsyncExportRequest{
+		Resource:    c.PostForm("resource"),
+		Format:      c.PostForm("format"),
+		GroupBy:     c.PostForm("group_by"),
+		WithCounts:  strings.EqualFold(c.PostForm("with_counts"), "true"),
+		Priority:    c.PostForm("priority"),
+		Compression: c.PostForm("compression"),
+	}
+	if req.Resource == "" {
+		return req, fmt.Errorf("resource is required")
+	}
+	if filters := c.PostForm("filters"); filters != "" {
+		if err := json.Unmarshal([]byte(filters), &req.Filters); err != nil {
+			return req, fmt.Errorf("filters must be a JSON object")
+		}
+	}
+	if fields := c.PostForm("fields"); fields != "" {
+		req.Fields = strings.Split(fields, ",")
+	}
+	if aliases := c.PostForm("field_aliases"); aliases != "" {
+		if err := json.Unmarshal([]byte(aliases), &req.FieldAliases); err != nil {
+			return req, fmt.Errorf("field_aliases must be a JSON object of field to name")
+		}
+	}
+	if level := c.PostForm("compression_level"); level != "" {
+		n, err := strconv.Atoi(level)
+		if err != nil {
+			return req, fmt.Errorf("compression_level must be a number")
+		}
+		req.CompressionLevel = n
+	}
+	return req, nil
+}
+
+// parseCohort returns the users listed in the ids or emails filters, or in an
+// uploaded cohort file. Only one of the three may be given.
+func parseCohort(m map[string]interface{}, file *multipart.FileHeader, cfg *config.ExportConfig) ([]uuid.UUID, []string, error) {
+	ids, hasIDs := m["ids"]
+	emails, hasEmails := m["emails"]
+	given := 0
+	for _, ok := range []bool{hasIDs, hasEmails, file != nil} {
+		if ok {
+			given++
+		}
+	}
+	if given == 0 {
+		return nil, nil, nil
+	}
+	if given > 1 {
+		return nil, nil, fmt.Errorf("only one of ids, emails and cohort_file may be given")
+	}
+
+	if file != nil {
+		f, err := file.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read cohort_file")
+		}
+		defer f.Close()
+		return exportservice.ParseCohort(f, cfg.CohortFileMax)
+	}
+
+	values, ok := stringList(ids)
+	if hasEmails {
+		values, ok = stringList(emails)
+	}
+	if !ok || len(values) == 0 {
+		return nil, nil, fmt.Errorf("ids and emails must be non-empty lists of strings")
+	}
+	if len(values) > cfg.CohortInlineMax {
+		return nil, nil, fmt.Errorf("at most %d ids or emails may be listed, upload a cohort_file for more", cfg.CohortInlineMax)
+	}
+	if hasIDs {
+		parsed, err := exportservice.CohortIDs(values, cfg.CohortInlineMax)
+		return parsed, nil, err
+	}
+	parsed, err := exportservice.CohortEmails(values, cfg.CohortInlineMax)
+	return nil, parsed, err
+}
+
+// stringList converts a decoded JSON array of strings
+func stringList(v interface{}) ([]string, bool) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		if values[i], ok = item.(string); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// parseGroupBy validates a group_by option, which only comment exports accept
+func parseGroupBy(resource models.ResourceType, value string) (models.ExportGroupBy, error) {
+	switch {
+	case value == "":
+		return "", nil
+	case models.ExportGroupBy(value) != models.ExportGroupByArticle:
+		return "", fmt.Errorf("group_by must be '%s'", models.ExportGroupByArticle)
+	case resource != models.ResourceTypeComments:
+		return "", fmt.Errorf("group_by is only supported for comment exports")
+	}
+	return models.ExportGroupByArticle, nil
+}
+
+// enqueueExport creates an async export job for params and responds with 202
+// Accepted. rerunOf is the job being re-run, if any.
+func (h *ExportHandler) enqueueExport(c *gin.Context, resource models.ResourceType, params *models.JobParams, rerunOf *uuid.UUID) {
+	tenantID := middleware.GetTenantID(c)
+	if err := h.quotaSvc.CheckJobCreation(c.Request.Context(), tenantID, models.JobTypeExport); err != nil {
+		respondError(c, h.logger, err)
 		return
 	}
 
 	// Create job
 	job := &models.Job{
-		ID:       uuid.New(),
-		Type:     models.JobTypeExport,
-		Resource: resource,
-		Status:   models.JobStatusPending,
+		ID:          uuid.New(),
+		Type:        models.JobTypeExport,
+		Resource:    resource,
+		Status:      models.JobStatusPending,
+		TenantID:    tenantID,
+		ParentJobID: rerunOf,
+		Params:      params,
 	}
 
 	if err := h.jobRepo.Create(c.Request.Context(), job); err != nil {
@@ -158,29 +631,251 @@ func (h *ExportHandler) CreateAsyncExport(c *gin.Context) {
 		return
 	}
 
-	// Parse filters
-	filters := h.parseFiltersFromMap(req.Filters)
+	// Exports small enough to finish quickly don't wait behind queued ones.
+	// A bundle holds every resource, so counting one doesn't size it.
+	if params.Diff == nil && params.Format != exportservice.FormatBundle && h.runsInline(c.Request.Context(), resource, params.Filters) {
+		// Finish the job even if the client goes away mid-request
+		ctx := context.WithoutCancel(c.Request.Context())
+		h.workerPool.RunExportJob(ctx, job, params.Filters)
+		h.respondInlineExport(c, job.ID, rerunOf)
+		return
+	}
 
 	// Submit to worker pool
-	h.workerPool.SubmitExportJob(job, filters)
+	var err error
+	if params.Diff != nil {
+		err = h.workerPool.SubmitExportDiffJob(job, params.Diff)
+	} else {
+		err = h.workerPool.SubmitExportJob(job, params.Filters)
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to submit export job")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusAccepted, CreateAsyncExportResponse{
+	response := CreateAsyncExportResponse{
 		JobID:     job.ID.String(),
 		Status:    string(job.Status),
 		Resource:  string(job.Resource),
 		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
-	})
+	}
+	if rerunOf != nil {
+		response.RerunOf = rerunOf.String()
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// runsInline reports whether an export of resource with filters matches at
+// most EXPORT_INLINE_MAX_ROWS records, so it can run within the request.
+// Exports that can't be counted are queued.
+func (h *ExportHandler) runsInline(ctx context.Context, resource models.ResourceType, filters *models.ExportFilters) bool {
+	limit := h.config.Load().InlineMaxRows
+	if limit <= 0 {
+		return false
+	}
+	count, err := h.exportSvc.Count(ctx, resource, filters)
+	if err != nil {
+		h.logger.Warn().Err(err).Str("resource", string(resource)).Msg("Failed to count export records")
+		return false
+	}
+	return count <= int64(limit)
+}
+
+// respondInlineExport writes the final state of an export run within the
+// request. A failed job is answered with 422, as for sync imports.
+func (h *ExportHandler) respondInlineExport(c *gin.Context, jobID uuid.UUID, rerunOf *uuid.UUID) {
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil || job == nil {
+		h.logger.Error().Err(err).Str("job_id", jobID.String()).Msg("Failed to get inline export job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	response := CreateAsyncExportResponse{
+		JobID:        job.ID.String(),
+		Status:       string(job.Status),
+		Resource:     string(job.Resource),
+		CreatedAt:    job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		ErrorMessage: job.ErrorMessage,
+	}
+	if rerunOf != nil {
+		response.RerunOf = rerunOf.String()
+	}
+	if job.Status == models.JobStatusFailed {
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+	if job.Status == models.JobStatusCompleted && job.FilePath != nil {
+		downloadURL, err := h.downloadURL(c.Request.Context(), job.ID)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to sign export download URL")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create download URL"})
+			return
+		}
+		response.DownloadURL = &downloadURL
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// downloadURL is the download link of a completed export, signed when
+// downloads are
+func (h *ExportHandler) downloadURL(ctx context.Context, jobID uuid.UUID) (string, error) {
+	downloadURL := fmt.Sprintf("/v1/exports/%s/download", jobID.String())
+	if h.signingSvc != nil {
+		return h.signingSvc.SignURL(ctx, downloadURL)
+	}
+	return downloadURL, nil
+}
+
+// CreateDiffExportRequest represents the request for a diff export. The range
+// is given either as timestamps or as the watermarks of earlier exports.
+type CreateDiffExportRequest struct {
+	Resource string     `json:"resource" binding:"required"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	FromJob  *uuid.UUID `json:"from_job_id,omitempty"`
+	ToJob    *uuid.UUID `json:"to_job_id,omitempty"`
+}
+
+// CreateDiffExport handles POST /v1/exports/diff
+func (h *ExportHandler) CreateDiffExport(c *gin.Context) {
+	var req CreateDiffExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
+		return
+	}
+
+	resource := models.ResourceType(req.Resource)
+	if resource != models.ResourceTypeUsers &&
+		resource != models.ResourceTypeArticles &&
+		resource != models.ResourceTypeComments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource type"})
+		return
+	}
+
+	from, ok := h.resolveDiffBound(c, req.From, req.FromJob, "from")
+	if !ok {
+		return
+	}
+	if from == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from or from_job_id is required"})
+		return
+	}
+	to, ok := h.resolveDiffBound(c, req.To, req.ToJob, "to")
+	if !ok {
+		return
+	}
+	if to == nil {
+		now := time.Now().UTC()
+		to = &now
+	}
+	if !from.Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	h.enqueueExport(c, resource, &models.JobParams{
+		Format: "ndjson",
+		Diff:   &models.DiffRange{From: *from, To: *to},
+	}, nil)
+}
+
+// GetExportSchema handles GET /v1/exports/schemas/:resource, returning the
+// Avro schema of the resource's records
+func (h *ExportHandler) GetExportSchema(c *gin.Context) {
+	schema, err := avro.Schema(models.ResourceType(c.Param("resource")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(schema))
+}
+
+// CancelExport handles DELETE /v1/exports/:job_id. It stops a pending or
+// running export and removes its partial file.
+func (h *ExportHandler) CancelExport(c *gin.Context) {
+	cancelJob(c, h.jobRepo, h.workerPool, models.JobTypeExport, h.logger)
+}
+
+// RerunExport handles POST /v1/exports/:job_id/rerun. It queues a new export
+// job with the resource and parameters of a finished one.
+func (h *ExportHandler) RerunExport(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil || job.Type != models.JobTypeExport || !ownedByCaller(c, job) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export job not found"})
+		return
+	}
+	if job.Status != models.JobStatusCompleted && job.Status != models.JobStatusFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "export job has not finished"})
+		return
+	}
+	if job.Params == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "export parameters were not recorded for this job"})
+		return
+	}
+
+	h.enqueueExport(c, job.Resource, job.Params, &job.ID)
+}
+
+// resolveDiffBound returns the timestamp given directly or the watermark of
+// the referenced export job. It writes an error response and returns false
+// when the job cannot be used.
+func (h *ExportHandler) resolveDiffBound(c *gin.Context, at *time.Time, jobID *uuid.UUID, name string) (*time.Time, bool) {
+	if at != nil && jobID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("only one of %s and %s_job_id may be set", name, name)})
+		return nil, false
+	}
+	if jobID == nil {
+		return at, true
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), *jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return nil, false
+	}
+	if job == nil || job.Type != models.JobTypeExport {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s_job_id: export job not found", name)})
+		return nil, false
+	}
+	if job.Status != models.JobStatusCompleted || job.DataAsOf == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s_job_id: export has no recorded watermark", name)})
+		return nil, false
+	}
+	return job.DataAsOf, true
 }
 
 // GetExportStatusResponse represents the response for export status
 type GetExportStatusResponse struct {
-	JobID       string      `json:"job_id"`
-	Status      string      `json:"status"`
-	Resource    string      `json:"resource"`
-	Progress    JobProgress `json:"progress"`
-	DownloadURL *string     `json:"download_url,omitempty"`
-	ExpiresAt   *string     `json:"expires_at,omitempty"`
-	CompletedAt *string     `json:"completed_at,omitempty"`
+	JobID       string            `json:"job_id"`
+	Status      string            `json:"status"`
+	Resource    string            `json:"resource"`
+	Progress    JobProgress       `json:"progress"`
+	DownloadURL *string           `json:"download_url,omitempty"`
+	ManifestURL *string           `json:"manifest_url,omitempty"`
+	DataAsOf    *string           `json:"data_as_of,omitempty"`
+	ExpiresAt   *string           `json:"expires_at,omitempty"`
+	CompletedAt *string           `json:"completed_at,omitempty"`
+	Params      *models.JobParams `json:"params,omitempty"`
+	Queue       *QueueStatus      `json:"queue,omitempty"`
+	Worker      *JobWorkerInfo    `json:"worker,omitempty"`
+
+	// CurrentRowsPerSecond is the rate of a processing job, known only
+	// to the instance running it
+	CurrentRowsPerSecond *float64 `json:"current_rows_per_second,omitempty"`
 }
 
 // GetExportStatus handles GET /v1/exports/:job_id
@@ -191,7 +886,7 @@ func (h *ExportHandler) GetExportStatus(c *gin.Context) {
 		return
 	}
 
-	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	job, err := getJobStatus(c.Request.Context(), h.jobRepo, jobID)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get job")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
@@ -220,15 +915,25 @@ func (h *ExportHandler) GetExportStatus(c *gin.Context) {
 			FailedRecords:     progress.FailedRecords,
 			Percentage:        progress.Percentage,
 		},
+		Params: job.Params,
+		Queue:  queueStatus(h.workerPool, job),
+		Worker: jobWorker(job),
 	}
 
 	if job.Status == models.JobStatusCompleted && job.FilePath != nil {
-		downloadURL := fmt.Sprintf("/v1/exports/%s/download", job.ID.String())
+		downloadURL, err := h.downloadURL(c.Request.Context(), job.ID)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to sign export download URL")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create download URL"})
+			return
+		}
 		response.DownloadURL = &downloadURL
+		manifestURL := fmt.Sprintf("/v1/exports/%s/manifest", job.ID.String())
+		response.ManifestURL = &manifestURL
 
-		// Set expiry (24 hours from completion)
-		if job.CompletedAt != nil {
-			expiresAt := job.CompletedAt.Add(24 * time.Hour).Format("2006-01-02T15:04:05Z")
+		// The file is removed EXPORT_FILE_EXPIRY_HOURS after completion
+		if expiry := h.config.Load().FileExpiry; job.CompletedAt != nil && expiry > 0 {
+			expiresAt := job.CompletedAt.Add(expiry).Format("2006-01-02T15:04:05Z")
 			response.ExpiresAt = &expiresAt
 		}
 	}
@@ -238,6 +943,17 @@ func (h *ExportHandler) GetExportStatus(c *gin.Context) {
 		response.CompletedAt = &completedAt
 	}
 
+	if job.DataAsOf != nil {
+		dataAsOf := job.DataAsOf.Format(time.RFC3339Nano)
+		response.DataAsOf = &dataAsOf
+	}
+
+	if job.Status == models.JobStatusProcessing {
+		if rate, ok := h.exportSvc.CurrentRate(job.ID); ok {
+			response.CurrentRowsPerSecond = &rate
+		}
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -248,26 +964,260 @@ func (h *ExportHandler) DownloadExport(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
 		return
 	}
+	if !h.checkDownloadSignature(c) {
+		return
+	}
+
+	// A followed export that hasn't finished is streamed as it is written
+	if strings.EqualFold(c.Query("follow"), "true") {
+		job, err := getJobStatus(c.Request.Context(), h.jobRepo, jobID)
+		if err == nil && job != nil && !jobFinished(job.Status) {
+			h.followExport(c, job)
+			return
+		}
+	}
 
 	filePath, err := h.exportSvc.GetExportFilePath(c.Request.Context(), jobID)
+	if errors.Is(err, exportservice.ErrExportExpired) {
+		h.respondExpired(c, jobID)
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get export file")
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check file exists
+	// Send clients straight to remote storage when the file lives there
+	if signedURL, err := h.exportSvc.SignedDownloadURL(c.Request.Context(), filePath); err == nil {
+		c.Redirect(http.StatusFound, signedURL)
+		return
+	} else if !errors.Is(err, storage.ErrSignedURLUnsupported) {
+		h.logger.Error().Err(err).Msg("Failed to sign export download URL")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create download URL"})
+		return
+	}
+
+	// The file may have been cleaned up since the export finished
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "export file not found"})
+		h.respondExpired(c, jobID)
 		return
 	}
 
 	filename := filepath.Base(filePath)
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Type", exportservice.ExportContentType(filePath))
 	c.File(filePath)
 }
 
+// exportFollowPoll is how often a followed download reads the job and the
+// new end of its file
+var exportFollowPoll = time.Second
+
+// exportCompleteTrailer is the trailer of a followed download: true when
+// the export completed and the whole file was sent, false when it failed or
+// was cancelled and the file stops short
+const exportCompleteTrailer = "X-Export-Complete"
+
+// followExport streams the file of an unfinished export as it is written,
+// until the job finishes or the client goes away. Nothing is sent until the
+// export has created its file, so an export that fails first is answered
+// with an error as an unfollowed download is. A retried export writes a new
+// file, so the download ends incomplete when the job moves on to one.
+func (h *ExportHandler) followExport(c *gin.Context, job *models.Job) {
+	ctx := c.Request.Context()
+	ticker := time.NewTicker(exportFollowPoll)
+	defer ticker.Stop()
+
+	var file *os.File
+	var path string
+	for {
+		// Watch before reading, so a run ending in between isn't missed
+		var ended <-chan struct{}
+		stop := func() {}
+		if h.workerPool != nil {
+			ended, stop = h.workerPool.WatchJob(job.ID)
+		}
+		latest, err := getJobStatus(ctx, h.jobRepo, job.ID)
+		if err != nil || latest == nil {
+			stop()
+			if file == nil {
+				h.logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to get export to follow")
+				c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+				return
+			}
+			c.Writer.Header().Set(exportCompleteTrailer, "false")
+			return
+		}
+		finished := jobFinished(latest.Status)
+		if file != nil && (latest.FilePath == nil || *latest.FilePath != path) {
+			stop()
+			c.Writer.Header().Set(exportCompleteTrailer, "false")
+			return
+		}
+
+		if file == nil && latest.FilePath != nil {
+			path = *latest.FilePath
+			if file, err = os.Open(path); err != nil {
+				stop()
+				h.logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to open export file to follow")
+				c.JSON(http.StatusNotFound, gin.H{"error": "export file not available"})
+				return
+			}
+			defer file.Close()
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(path)))
+			c.Header("Content-Type", exportservice.ExportContentType(path))
+			c.Header("Cache-Control", "no-cache")
+			c.Header("X-Accel-Buffering", "no")
+			c.Header("Trailer", exportCompleteTrailer)
+			c.Status(http.StatusOK)
+		}
+		if file == nil && finished {
+			stop()
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not completed"})
+			return
+		}
+
+		// Send what has been written since the last read. The job was read
+		// first, so a finished job's file is sent to its end.
+		if file != nil {
+			if _, err := io.Copy(c.Writer, file); err != nil {
+				stop()
+				return
+			}
+			c.Writer.Flush()
+			if finished {
+				stop()
+				c.Writer.Header().Set(exportCompleteTrailer, strconv.FormatBool(latest.Status == models.JobStatusCompleted))
+				return
+			}
+		}
+
+		select {
+		case <-ended:
+		case <-ticker.C:
+		case <-ctx.Done():
+			stop()
+			return
+		}
+		stop()
+	}
+}
+
+// checkDownloadSignature verifies the signature of a download URL made by
+// GetExportStatus. Unsigned downloads pass unless signed downloads are
+// required; a signature that is present must verify.
+func (h *ExportHandler) checkDownloadSignature(c *gin.Context) bool {
+	if h.signingSvc == nil {
+		return true
+	}
+	if c.Query(signing.ParamSig) == "" {
+		if h.signingSvc.RequireSignedDownloads() {
+			respondError(c, h.logger, domainerrors.ErrInvalidSignature("download URL must be signed"))
+			return false
+		}
+		return true
+	}
+	if err := h.signingSvc.VerifyURL(c.Request.Context(), c.Request.URL); err != nil {
+		respondError(c, h.logger, err)
+		return false
+	}
+	return true
+}
+
+// ExportExpiredResponse is returned with 410 when a finished export's file is
+// gone. Rerun is the POST that regenerates it, absent when the export's
+// parameters were not recorded.
+type ExportExpiredResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	JobID string `json:"job_id"`
+	Rerun string `json:"rerun,omitempty"`
+}
+
+// respondExpired answers a download of a removed export file. With
+// regenerate=true the export is queued again from its recorded parameters
+// and the new job is returned; otherwise the client gets 410 with a link to
+// do so.
+func (h *ExportHandler) respondExpired(c *gin.Context, jobID uuid.UUID) {
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil || job == nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	if strings.EqualFold(c.Query("regenerate"), "true") {
+		// Regenerating spends the caller's quota, so only on its own exports
+		if !ownedByCaller(c, job) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "export job not found"})
+			return
+		}
+		if job.Params == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "export parameters were not recorded for this job"})
+			return
+		}
+		h.enqueueExport(c, job.Resource, job.Params, &job.ID)
+		return
+	}
+
+	resp := ExportExpiredResponse{
+		Error: "export file has expired",
+		Code:  domainerrors.ErrCodeExportExpired,
+		JobID: job.ID.String(),
+	}
+	if job.Params != nil {
+		resp.Rerun = fmt.Sprintf("/v1/exports/%s/rerun", job.ID.String())
+	}
+	c.JSON(http.StatusGone, resp)
+}
+
+// GetExportManifest handles GET /v1/exports/:job_id/manifest
+func (h *ExportHandler) GetExportManifest(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	filePath, err := h.exportSvc.GetExportFilePath(c.Request.Context(), jobID)
+	if errors.Is(err, exportservice.ErrExportExpired) {
+		c.JSON(http.StatusGone, gin.H{"error": err.Error(), "code": domainerrors.ErrCodeExportExpired})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	manifestPath := exportservice.ManifestPath(filePath)
+	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export manifest not found"})
+		return
+	}
+	if h.signingSvc == nil {
+		c.Header("Content-Type", "application/json")
+		c.File(manifestPath)
+		return
+	}
+
+	// Sign the exact bytes served, so clients verify what they received
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to read export manifest")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read export manifest"})
+		return
+	}
+	signature, err := h.signingSvc.Sign(c.Request.Context(), manifest)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to sign export manifest")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign export manifest"})
+		return
+	}
+	c.Header(signing.Header, signature)
+	c.Data(http.StatusOK, "application/json", manifest)
+}
+
 func (h *ExportHandler) parseFilters(c *gin.Context) *models.ExportFilters {
 	filters := &models.ExportFilters{}
 
@@ -277,10 +1227,17 @@ func (h *ExportHandler) parseFilters(c *gin.Context) *models.ExportFilters {
 	if role := c.Query("role"); role != "" {
 		filters.Role = &role
 	}
+	if lang := c.Query("lang"); lang != "" {
+		filters.Lang = &lang
+	}
 	if activeStr := c.Query("active"); activeStr != "" {
 		active := strings.ToLower(activeStr) == "true"
 		filters.Active = &active
 	}
+	if placeholderStr := c.Query("placeholder"); placeholderStr != "" {
+		placeholder := strings.ToLower(placeholderStr) == "true"
+		filters.Placeholder = &placeholder
+	}
 	if createdAfter := c.Query("created_after"); createdAfter != "" {
 		if t, err := time.Parse(time.RFC3339, createdAfter); err == nil {
 			filters.CreatedAfter = &t
@@ -291,6 +1248,30 @@ func (h *ExportHandler) parseFilters(c *gin.Context) *models.ExportFilters {
 			filters.CreatedBefore = &t
 		}
 	}
+	if updatedAfter := c.Query("updated_after"); updatedAfter != "" {
+		if t, err := time.Parse(time.RFC3339, updatedAfter); err == nil {
+			filters.UpdatedAfter = &t
+		}
+	}
+	if updatedBefore := c.Query("updated_before"); updatedBefore != "" {
+		if t, err := time.Parse(time.RFC3339, updatedBefore); err == nil {
+			filters.UpdatedBefore = &t
+		}
+	}
+	if publishedAfter := c.Query("published_after"); publishedAfter != "" {
+		if t, err := time.Parse(time.RFC3339, publishedAfter); err == nil {
+			filters.PublishedAfter = &t
+		}
+	}
+	if publishedBefore := c.Query("published_before"); publishedBefore != "" {
+		if t, err := time.Parse(time.RFC3339, publishedBefore); err == nil {
+			filters.PublishedBefore = &t
+		}
+	}
+	if hasTagsStr := c.Query("has_tags"); hasTagsStr != "" {
+		hasTags := strings.ToLower(hasTagsStr) == "true"
+		filters.HasTags = &hasTags
+	}
 	if authorID := c.Query("author_id"); authorID != "" {
 		if id, err := uuid.Parse(authorID); err == nil {
 			filters.AuthorID = &id
@@ -306,6 +1287,11 @@ func (h *ExportHandler) parseFilters(c *gin.Context) *models.ExportFilters {
 			filters.UserID = &id
 		}
 	}
+	if jobID := c.Query("imported_by_job"); jobID != "" {
+		if id, err := uuid.Parse(jobID); err == nil {
+			filters.ImportedByJob = &id
+		}
+	}
 
 	return filters
 }
@@ -323,9 +1309,15 @@ func (h *ExportHandler) parseFiltersFromMap(m map[string]interface{}) *models.Ex
 	if role, ok := m["role"].(string); ok {
 		filters.Role = &role
 	}
+	if lang, ok := m["lang"].(string); ok {
+		filters.Lang = &lang
+	}
 	if active, ok := m["active"].(bool); ok {
 		filters.Active = &active
 	}
+	if placeholder, ok := m["placeholder"].(bool); ok {
+		filters.Placeholder = &placeholder
+	}
 	if createdAfter, ok := m["created_after"].(string); ok {
 		if t, err := time.Parse(time.RFC3339, createdAfter); err == nil {
 			filters.CreatedAfter = &t
@@ -336,6 +1328,34 @@ func (h *ExportHandler) parseFiltersFromMap(m map[string]interface{}) *models.Ex
 			filters.CreatedBefore = &t
 		}
 	}
+	if updatedAfter, ok := m["updated_after"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, updatedAfter); err == nil {
+			filters.UpdatedAfter = &t
+		}
+	}
+	if updatedBefore, ok := m["updated_before"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, updatedBefore); err == nil {
+			filters.UpdatedBefore = &t
+		}
+	}
+	if publishedAfter, ok := m["published_after"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, publishedAfter); err == nil {
+			filters.PublishedAfter = &t
+		}
+	}
+	if publishedBefore, ok := m["published_before"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, publishedBefore); err == nil {
+			filters.PublishedBefore = &t
+		}
+	}
+	if hasTags, ok := m["has_tags"].(bool); ok {
+		filters.HasTags = &hasTags
+	}
+	if jobID, ok := m["imported_by_job"].(string); ok {
+		if id, err := uuid.Parse(jobID); err == nil {
+			filters.ImportedByJob = &id
+		}
+	}
 
 	return filters
 }
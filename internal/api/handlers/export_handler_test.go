@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/config"
+	domainerrors "github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	signingservice "github.com/rohit/bulk-import-export/internal/service/signing"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rohit/bulk-import-export/pkg/signing"
+	"github.com/rs/zerolog"
+)
+
+func TestExportHandler_DownloadExpired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+
+	exportSvc := exportservice.NewService(db, memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, nil, zerolog.Nop(), config.ExportConfig{})
+	pool := worker.NewPool(nil, exportSvc, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), pool, nil, zerolog.Nop(), config.ExportConfig{})
+
+	router := gin.New()
+	router.GET("/v1/exports/:job_id/download", h.DownloadExport)
+	// other acts as another tenant
+	other := gin.New()
+	other.Use(func(c *gin.Context) { c.Set(middleware.TenantContextKey, "acme") })
+	other.GET("/v1/exports/:job_id/download", h.DownloadExport)
+	other.POST("/v1/exports/:job_id/rerun", h.RerunExport)
+
+	// A finished export whose file has been removed
+	removed := filepath.Join(t.TempDir(), "export.ndjson")
+	job := &models.Job{
+		Type:     models.JobTypeExport,
+		Resource: models.ResourceTypeUsers,
+		Status:   models.JobStatusCompleted,
+		FilePath: &removed,
+		Params:   &models.JobParams{Format: "ndjson"},
+	}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/exports/"+job.ID.String()+"/download", nil))
+	if w.Code != http.StatusGone {
+		t.Fatalf("status = %d, body %s; want 410", w.Code, w.Body.String())
+	}
+	var expired ExportExpiredResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &expired); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if expired.Code != domainerrors.ErrCodeExportExpired || expired.Rerun != "/v1/exports/"+job.ID.String()+"/rerun" {
+		t.Errorf("response = %+v", expired)
+	}
+
+	// Another tenant can't spend its quota regenerating the export
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/exports/"+job.ID.String()+"/download?regenerate=true", nil),
+		httptest.NewRequest(http.MethodPost, "/v1/exports/"+job.ID.String()+"/rerun", nil),
+	} {
+		w = httptest.NewRecorder()
+		other.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s by another tenant status = %d, want 404", req.Method, req.URL.Path, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/exports/"+job.ID.String()+"/download?regenerate=true", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("regenerate status = %d, body %s; want 202", w.Code, w.Body.String())
+	}
+	var created CreateAsyncExportResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if created.RerunOf != job.ID.String() {
+		t.Errorf("rerun_of = %q, want %s", created.RerunOf, job.ID)
+	}
+	rerun, _ := jobs.GetByID(ctx, uuid.MustParse(created.JobID))
+	if rerun == nil || rerun.Params == nil || rerun.Params.Format != "ndjson" {
+		t.Errorf("regenerated job = %+v, want the original parameters", rerun)
+	}
+}
+
+// testMetrics is shared as a collector registers itself once per process
+var testMetrics = metrics.NewCollector()
+
+func TestExportHandler_StreamCompression(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{Email: email, Name: email, Role: "reader"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	cfg := config.ExportConfig{BatchSize: 1, StreamBufferSize: 4096, StreamCompression: true}
+	exportSvc := exportservice.NewService(db, memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, testMetrics, zerolog.Nop(), cfg)
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), nil, nil, zerolog.Nop(), cfg)
+
+	router := gin.New()
+	router.GET("/v1/exports", h.StreamExport)
+
+	get := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/exports?resource=users&metadata=true", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("gzip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status = %d, Content-Encoding = %q; want 200, gzip", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error: %v", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("ReadAll() error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], `"_meta"`) {
+		t.Errorf("decompressed body = %q, want 2 records and the _meta line", body)
+	}
+
+	// zstd is sent to clients that prefer it
+	w = get("zstd, gzip;q=0.5")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "zstd" {
+		t.Fatalf("status = %d, Content-Encoding = %q; want 200, zstd", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	zd, err := zstd.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("zstd.NewReader() error: %v", err)
+	}
+	defer zd.Close()
+	if body, err := io.ReadAll(zd); err != nil || strings.Count(string(body), "\n") != 3 {
+		t.Errorf("zstd body = %q, %v; want 2 records and the _meta line", body, err)
+	}
+
+	// Clients that don't ask get plain NDJSON
+	w = get("")
+	if w.Header().Get("Content-Encoding") != "" || strings.Count(w.Body.String(), "\n") != 3 {
+		t.Errorf("plain: Content-Encoding = %q, body %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
+
+func TestExportHandler_Avro(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{Email: email, Name: email, Role: "reader"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	cfg := config.ExportConfig{BatchSize: 1, StreamBufferSize: 4096, StreamCompression: true, AvroCodec: "null"}
+	exportSvc := exportservice.NewService(db, memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, testMetrics, zerolog.Nop(), cfg)
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), nil, nil, zerolog.Nop(), cfg)
+
+	router := gin.New()
+	router.GET("/v1/exports", h.StreamExport)
+	router.POST("/v1/exports", h.CreateAsyncExport)
+	router.GET("/v1/exports/schemas/:resource", h.GetExportSchema)
+
+	// Avro streams aren't gzipped even when the client accepts it
+	req := httptest.NewRequest(http.MethodGet, "/v1/exports?resource=users&format=avro", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/avro" || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("status = %d, headers = %v; want 200 application/avro without Content-Encoding", w.Code, w.Header())
+	}
+	if !strings.HasPrefix(w.Body.String(), "Obj\x01") || !strings.Contains(w.Body.String(), "b@example.com") {
+		t.Errorf("body isn't an Avro file with both users: %q", w.Body.String())
+	}
+	if got := w.Header().Get("X-Export-Record-Count"); got != "2" {
+		t.Errorf("X-Export-Record-Count = %q, want 2", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/exports", strings.NewReader(`{"resource":"users","format":"avro","compression":"gzip"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("avro with compression: status = %d, want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/exports", strings.NewReader(`{"resource":"users","format":"avro","field_aliases":{"email":"emailAddress"}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("avro with field_aliases: status = %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/exports/schemas/comments", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Comment"`) {
+		t.Errorf("schema: status = %d, body %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/exports/schemas/widgets", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown schema: status = %d, want 404", w.Code)
+	}
+}
+
+func TestExportHandler_Cohort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+
+	cfg := config.ExportConfig{CohortInlineMax: 2, CohortFileMax: 3}
+	exportSvc := exportservice.NewService(db, memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, nil, zerolog.Nop(), cfg)
+	pool := worker.NewPool(nil, exportSvc, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 10})
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), pool, nil, zerolog.Nop(), cfg)
+
+	router := gin.New()
+	router.POST("/v1/exports", h.CreateAsyncExport)
+
+	post := func(contentType string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/exports", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	filtersOf := func(w *httptest.ResponseRecorder) *models.ExportFilters {
+		t.Helper()
+		var created CreateAsyncExportResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Unmarshal() error: %v", err)
+		}
+		job, _ := jobs.GetByID(context.Background(), uuid.MustParse(created.JobID))
+		if job == nil || job.Params == nil || job.Params.Filters == nil {
+			t.Fatalf("job = %+v, want its filters recorded", job)
+		}
+		return job.Params.Filters
+	}
+
+	id := uuid.New()
+	w := post("application/json", strings.NewReader(`{"resource":"users","filters":{"role":"admin","ids":["`+id.String()+`"]}}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("ids status = %d, body %s; want 202", w.Code, w.Body.String())
+	}
+	if f := filtersOf(w); len(f.IDs) != 1 || f.IDs[0] != id || f.Role == nil {
+		t.Errorf("filters = %+v, want the ID alongside the role", f)
+	}
+
+	for name, body := range map[string]string{
+		"articles":     `{"resource":"articles","filters":{"ids":["` + id.String() + `"]}}`,
+		"too many":     `{"resource":"users","filters":{"emails":["a@example.com","b@example.com","c@example.com"]}}`,
+		"both":         `{"resource":"users","filters":{"ids":["` + id.String() + `"],"emails":["a@example.com"]}}`,
+		"bad id":       `{"resource":"users","filters":{"ids":["nope"]}}`,
+		"not a list":   `{"resource":"users","filters":{"emails":"a@example.com"}}`,
+		"empty emails": `{"resource":"users","filters":{"emails":[]}}`,
+	} {
+		if w := post("application/json", strings.NewReader(body)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, body %s; want 400", name, w.Code, w.Body.String())
+		}
+	}
+
+	upload := func(file string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("resource", "users")
+		mw.WriteField("filters", `{"active":true}`)
+		part, _ := mw.CreateFormFile("cohort_file", "cohort.csv")
+		part.Write([]byte(file))
+		mw.Close()
+		return post(mw.FormDataContentType(), &body)
+	}
+	w = upload("email\nA@example.com\nb@example.com\nc@example.com\n")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("cohort_file status = %d, body %s; want 202", w.Code, w.Body.String())
+	}
+	if f := filtersOf(w); len(f.Emails) != 3 || f.Emails[0] != "a@example.com" || f.Active == nil {
+		t.Errorf("filters = %+v, want the file's emails alongside active", f)
+	}
+	if w := upload("a@example.com\nb@example.com\nc@example.com\nd@example.com\n"); w.Code != http.StatusBadRequest {
+		t.Errorf("oversized cohort_file status = %d, want 400", w.Code)
+	}
+}
+
+func TestExportHandler_InlineSmallExports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+	users := memory.NewUserRepository(db)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := users.Create(ctx, &models.User{Email: email, Name: email, Role: "reader"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	cfg := config.ExportConfig{BatchSize: 10, OutputPath: t.TempDir(), InlineMaxRows: 2}
+	exportSvc := exportservice.NewService(db, users, memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, testMetrics, zerolog.Nop(), cfg)
+	// The pool's workers aren't started, so only inline exports run
+	pool := worker.NewPool(nil, exportSvc, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 10})
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), pool, nil, zerolog.Nop(), cfg)
+
+	router := gin.New()
+	router.POST("/v1/exports", h.CreateAsyncExport)
+	post := func() (*httptest.ResponseRecorder, CreateAsyncExportResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/exports", strings.NewReader(`{"resource":"users"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var created CreateAsyncExportResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Unmarshal() error: %v", err)
+		}
+		return w, created
+	}
+
+	w, created := post()
+	if w.Code != http.StatusOK || created.Status != string(models.JobStatusCompleted) {
+		t.Fatalf("status = %d, body %s; want 200 and a completed job", w.Code, w.Body.String())
+	}
+	if created.DownloadURL == nil || *created.DownloadURL != "/v1/exports/"+created.JobID+"/download" {
+		t.Errorf("download_url = %v, want the job's download link", created.DownloadURL)
+	}
+	job, _ := jobs.GetByID(ctx, uuid.MustParse(created.JobID))
+	if job == nil || job.SuccessfulRecords != 2 || job.WorkerID == nil || *job.WorkerID != "sync" {
+		t.Errorf("job = %+v, want 2 records exported by the sync worker", job)
+	}
+
+	// Past the limit the export is queued
+	if err := users.Create(ctx, &models.User{Email: "c@example.com", Name: "c", Role: "reader"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w, created = post()
+	if w.Code != http.StatusAccepted || created.Status != string(models.JobStatusPending) || created.DownloadURL != nil {
+		t.Errorf("status = %d, body %s; want 202 and a pending job", w.Code, w.Body.String())
+	}
+}
+
+func TestExportHandler_SignedDownloads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+
+	exportSvc := exportservice.NewService(db, memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, nil, zerolog.Nop(), config.ExportConfig{})
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), nil, nil, zerolog.Nop(), config.ExportConfig{})
+	signingSvc := signingservice.NewService(memory.NewSigningKeyRepository(db), zerolog.Nop(),
+		config.SigningConfig{Enabled: true, URLTTL: time.Hour, RequireSignedDownloads: true, KeyRefresh: time.Minute})
+	if err := signingSvc.Init(ctx); err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+	h.SetSigner(signingSvc)
+
+	router := gin.New()
+	router.GET("/v1/exports/:job_id", h.GetExportStatus)
+	router.GET("/v1/exports/:job_id/download", h.DownloadExport)
+	router.GET("/v1/exports/:job_id/manifest", h.GetExportManifest)
+
+	path := filepath.Join(t.TempDir(), "export.ndjson")
+	manifest := []byte(`{"record_count":0}`)
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := os.WriteFile(exportservice.ManifestPath(path), manifest, 0o644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusCompleted, FilePath: &path}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/exports/"+job.ID.String(), nil))
+	var status GetExportStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || status.DownloadURL == nil {
+		t.Fatalf("status response = %s, want a download_url", w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"signed", *status.DownloadURL, http.StatusOK},
+		{"unsigned", "/v1/exports/" + job.ID.String() + "/download", http.StatusForbidden},
+		{"tampered", strings.Replace(*status.DownloadURL, "expires=", "expires=9", 1), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, body %s; want %d", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+
+	// The manifest carries a signature over the bytes served
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/exports/"+job.ID.String()+"/manifest", nil))
+	keys, _ := signingSvc.Keys(ctx)
+	secrets := map[string][]byte{keys[0].ID: keys[0].Secret}
+	if err := signing.Verify(w.Header().Get(signing.Header), w.Body.Bytes(), secrets, time.Minute, time.Now()); err != nil {
+		t.Errorf("manifest signature: %v", err)
+	}
+}
+
+func TestExportHandler_FollowDownload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(poll time.Duration) { exportFollowPoll = poll }(exportFollowPoll)
+	exportFollowPoll = 10 * time.Millisecond
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+
+	exportSvc := exportservice.NewService(db, memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), memory.NewTombstoneRepository(db), jobs, nil, time.Minute, nil, zerolog.Nop(), config.ExportConfig{})
+	h := NewExportHandler(exportSvc, jobs, quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), nil, nil, zerolog.Nop(), config.ExportConfig{})
+	router := gin.New()
+	router.GET("/v1/exports/:job_id/download", h.DownloadExport)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// follow starts an export's download and returns its response once the
+	// first line has arrived, with the file open for more
+	follow := func(job *models.Job) (*http.Response, *os.File) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "export.ndjson")
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		t.Cleanup(func() { file.Close() })
+		file.WriteString("{\"id\":1}\n")
+		if err := jobs.SetFilePath(ctx, job.ID, path); err != nil {
+			t.Fatalf("SetFilePath() error: %v", err)
+		}
+		resp, err := http.Get(server.URL + "/v1/exports/" + job.ID.String() + "/download?follow=true")
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		line := make([]byte, len("{\"id\":1}\n"))
+		if _, err := io.ReadFull(resp.Body, line); err != nil {
+			t.Fatalf("first line: %v", err)
+		}
+		return resp, file
+	}
+	newJob := func() *models.Job {
+		job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusProcessing}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		return job
+	}
+
+	// A completed export is sent to its end, then marked complete
+	job := newJob()
+	resp, file := follow(job)
+	file.WriteString("{\"id\":2}\n")
+	if err := jobs.SetCompleted(ctx, job.ID, 2, 0); err != nil {
+		t.Fatalf("SetCompleted() error: %v", err)
+	}
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() error: %v", err)
+	}
+	if string(rest) != "{\"id\":2}\n" {
+		t.Errorf("rest of body = %q, want the second line", rest)
+	}
+	if got := resp.Trailer.Get("X-Export-Complete"); got != "true" {
+		t.Errorf("X-Export-Complete = %q, want true", got)
+	}
+
+	// A failed export ends the download short
+	job = newJob()
+	resp, _ = follow(job)
+	if err := jobs.SetFailed(ctx, job.ID, "connection reset"); err != nil {
+		t.Fatalf("SetFailed() error: %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("ReadAll() error: %v", err)
+	}
+	if got := resp.Trailer.Get("X-Export-Complete"); got != "false" {
+		t.Errorf("X-Export-Complete = %q, want false", got)
+	}
+
+	// Without follow an unfinished export can't be downloaded
+	resp, err = http.Get(server.URL + "/v1/exports/" + newJob().ID.String() + "/download")
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unfollowed status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -1,56 +1,160 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
 	"github.com/rohit/bulk-import-export/internal/config"
 	"github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/repository"
 	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
 	"github.com/rohit/bulk-import-export/internal/worker"
 	"github.com/rs/zerolog"
 )
 
+const (
+	// ndjsonContentType marks an import whose request body is the data
+	ndjsonContentType = "application/x-ndjson"
+	// ndjsonBodyFileName names the upload saved from such a body
+	ndjsonBodyFileName = "body.ndjson"
+)
+
+// statusWaitPoll is how often a waiting status request reads the job, for
+// runs on other instances that this one isn't told about
+var statusWaitPoll = 2 * time.Second
+
 // ImportHandler handles import-related HTTP requests
 type ImportHandler struct {
 	importSvc       *importservice.Service
-	jobRepo         *postgres.JobRepository
-	idempotencyRepo *postgres.IdempotencyRepository
+	jobRepo         repository.JobRepository
+	idempotencyRepo repository.IdempotencyRepository
+	quotaSvc        *quotaservice.Service
 	workerPool      *worker.Pool
+	adminToken      string
 	logger          zerolog.Logger
-	config          config.ImportConfig
+	config          atomic.Pointer[config.ImportConfig]
 }
 
-// NewImportHandler creates a new import handler
+// NewImportHandler creates a new import handler. Only requests carrying
+// adminToken may allow an import to create admin users.
 func NewImportHandler(
 	importSvc *importservice.Service,
-	jobRepo *postgres.JobRepository,
-	idempotencyRepo *postgres.IdempotencyRepository,
+	jobRepo repository.JobRepository,
+	idempotencyRepo repository.IdempotencyRepository,
+	quotaSvc *quotaservice.Service,
 	workerPool *worker.Pool,
+	adminToken string,
 	logger zerolog.Logger,
 	cfg config.ImportConfig,
 ) *ImportHandler {
-	return &ImportHandler{
+	h := &ImportHandler{
 		importSvc:       importSvc,
 		jobRepo:         jobRepo,
 		idempotencyRepo: idempotencyRepo,
+		quotaSvc:        quotaSvc,
 		workerPool:      workerPool,
+		adminToken:      adminToken,
 		logger:          logger,
-		config:          cfg,
 	}
+	h.config.Store(&cfg)
+	return h
+}
+
+// SetConfig replaces the import settings when they are reloaded
+func (h *ImportHandler) SetConfig(cfg config.ImportConfig) {
+	h.config.Store(&cfg)
+}
+
+// StatusMaxWait is the longest a status request may wait for its job
+func (h *ImportHandler) StatusMaxWait() time.Duration {
+	return h.config.Load().StatusMaxWait
+}
+
+// multipartOverhead allows for the form fields and part headers sent
+// alongside an uploaded file
+const multipartOverhead = 1024 * 1024
+
+// MaxUploadBytes is the largest request body CreateImport accepts: a file
+// of MaxFileSizeMB and its multipart framing
+func (h *ImportHandler) MaxUploadBytes() int64 {
+	return int64(h.config.Load().MaxFileSizeMB)*1024*1024 + multipartOverhead
 }
 
 // CreateImportRequest represents the request body for creating an import
 type CreateImportRequest struct {
-	Resource string `json:"resource" binding:"required"`
+	Resource string `json:"resource,omitempty"`
 	FileURL  string `json:"file_url,omitempty"`
+	Profile  bool   `json:"profile,omitempty"`
+	Preview  bool   `json:"preview,omitempty"`
+	// CommentDedup is "id" (default) or "natural_key"
+	CommentDedup string `json:"comment_dedup,omitempty"`
+	// Sanitize strips scripts and control characters from article and
+	// comment bodies and normalizes their line breaks
+	Sanitize bool `json:"sanitize,omitempty"`
+	// Analyze reports the inserts and updates the file would make without
+	// writing them
+	Analyze bool `json:"analyze,omitempty"`
+	// DetectLang tags article and comment rows with the language of their body
+	DetectLang bool `json:"detect_lang,omitempty"`
+	// Sync processes the file within the request and returns the result
+	Sync bool `json:"sync,omitempty"`
+	// Priority is low, normal (default) or high
+	Priority string `json:"priority,omitempty"`
+	// AllowAdminRoles lets a user import create admin users whatever
+	// IMPORT_ADMIN_ROLE_POLICY says; it needs the admin token
+	AllowAdminRoles bool `json:"allow_admin_roles,omitempty"`
+	// FuzzyDedup is "warn" or "review" to check a user import for
+	// near-duplicate users
+	FuzzyDedup string `json:"fuzzy_dedup,omitempty"`
+	// CreateMissingAuthors creates an inactive placeholder user for each
+	// article author_id that isn't a user instead of rejecting the article
+	CreateMissingAuthors bool `json:"create_missing_authors,omitempty"`
+	// UpsertKey is the column rows update stored records on: "id"
+	// (default), "email" for users or "slug" for articles
+	UpsertKey string `json:"upsert_key,omitempty"`
+	// OnConflict is what a row whose email or slug belongs to another
+	// stored record does: "skip" (default) rejects it, "upsert" updates the
+	// fields it gives and "replace" overwrites the record
+	OnConflict string `json:"on_conflict,omitempty"`
+	// IgnoreLedger writes rows the import ledger recorded as already
+	// imported instead of skipping them
+	IgnoreLedger bool `json:"ignore_ledger,omitempty"`
+	// FieldPaths reads fields of nested NDJSON documents from JSON paths,
+	// e.g. {"email": "user.email"}
+	FieldPaths map[string]string `json:"field_paths,omitempty"`
+}
+
+// jobParams returns the job parameters of the options in r
+func (r *CreateImportRequest) jobParams() *models.JobParams {
+	return &models.JobParams{
+		CommentDedup:         models.CommentDedup(r.CommentDedup),
+		Sanitize:             r.Sanitize,
+		Analyze:              r.Analyze,
+		DetectLang:           r.DetectLang,
+		Priority:             models.JobPriority(r.Priority),
+		AllowAdminRoles:      r.AllowAdminRoles,
+		FuzzyDedup:           models.UserFuzzyDedup(r.FuzzyDedup),
+		CreateMissingAuthors: r.CreateMissingAuthors,
+		UpsertKey:            models.UpsertKey(r.UpsertKey),
+		OnConflict:           models.OnConflict(r.OnConflict),
+		IgnoreLedger:         r.IgnoreLedger,
+		FieldPaths:           r.FieldPaths,
+	}
 }
 
 // CreateImportResponse represents the response for creating an import
@@ -60,16 +164,45 @@ type CreateImportResponse struct {
 	Resource  string `json:"resource"`
 	CreatedAt string `json:"created_at"`
 	Links     Links  `json:"links"`
+
+	Detection *parsers.ResourceDetection `json:"detection,omitempty"`
+}
+
+// SyncImportResponse is the result of an import run with sync=true. Errors
+// and Warnings hold up to syncResultLimit entries; the totals count all.
+type SyncImportResponse struct {
+	CreateImportResponse
+	Progress      JobProgress        `json:"progress"`
+	ErrorMessage  *string            `json:"error_message,omitempty"`
+	Errors        []JobErrorItem     `json:"errors"`
+	TotalErrors   int64              `json:"total_errors"`
+	Warnings      []JobWarningItem   `json:"warnings"`
+	TotalWarnings int64              `json:"total_warnings"`
+	Summary       *models.JobSummary `json:"summary,omitempty"`
+}
+
+// syncResultLimit caps the errors and warnings returned by a sync import
+const syncResultLimit = 1000
+
+// ImportPreviewResponse describes what an import would do without running it
+type ImportPreviewResponse struct {
+	Resource  string                     `json:"resource"`
+	Detection *parsers.ResourceDetection `json:"detection"`
 }
 
 // Links represents HATEOAS links
 type Links struct {
-	Self   string `json:"self"`
-	Errors string `json:"errors,omitempty"`
+	Self     string `json:"self"`
+	Errors   string `json:"errors,omitempty"`
+	Warnings string `json:"warnings,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	// SearchIndex is the follow-up job syncing the import into the search index
+	SearchIndex string `json:"search_index,omitempty"`
 }
 
 // CreateImport handles POST /v1/imports
 func (h *ImportHandler) CreateImport(c *gin.Context) {
+	cfg := h.config.Load()
 	// Check idempotency key
 	idempotencyKey := c.GetHeader("Idempotency-Key")
 	if idempotencyKey != "" {
@@ -96,23 +229,52 @@ func (h *ImportHandler) CreateImport(c *gin.Context) {
 		}
 	}
 
+	// Enforce tenant quotas before accepting any upload
+	tenantID := middleware.GetTenantID(c)
+	if err := h.quotaSvc.CheckJobCreation(c.Request.Context(), tenantID, models.JobTypeImport); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	// The upload is saved in a directory named after the job
+	jobID := uuid.New()
+
 	// Get resource type from form or JSON
 	var resource models.ResourceType
 	var filePath string
+	var opts worker.ImportOptions
+	var preview, sync bool
+	params := &models.JobParams{}
 
 	// Check if this is a multipart form upload
 	contentType := c.ContentType()
 	if contentType == "multipart/form-data" || c.Request.MultipartForm != nil {
 		// Handle file upload
-		resourceStr := c.PostForm("resource")
-		if resourceStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "resource is required"})
+		resource = models.ResourceType(c.PostForm("resource"))
+		opts.Profile = strings.EqualFold(c.PostForm("profile"), "true")
+		preview = strings.EqualFold(c.PostForm("preview"), "true")
+		sync = strings.EqualFold(c.PostForm("sync"), "true")
+		params.CommentDedup = models.CommentDedup(c.PostForm("comment_dedup"))
+		params.Sanitize = strings.EqualFold(c.PostForm("sanitize"), "true")
+		params.Analyze = strings.EqualFold(c.PostForm("analyze"), "true")
+		params.DetectLang = strings.EqualFold(c.PostForm("detect_lang"), "true")
+		params.Priority = models.JobPriority(c.PostForm("priority"))
+		params.AllowAdminRoles = strings.EqualFold(c.PostForm("allow_admin_roles"), "true")
+		params.FuzzyDedup = models.UserFuzzyDedup(c.PostForm("fuzzy_dedup"))
+		params.CreateMissingAuthors = strings.EqualFold(c.PostForm("create_missing_authors"), "true")
+		params.UpsertKey = models.UpsertKey(c.PostForm("upsert_key"))
+		params.OnConflict = models.OnConflict(c.PostForm("on_conflict"))
+		params.IgnoreLedger = strings.EqualFold(c.PostForm("ignore_ledger"), "true")
+		fieldPaths, err := parseFieldPaths(c.PostForm("field_paths"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		resource = models.ResourceType(resourceStr)
+		params.FieldPaths = fieldPaths
 
-		// Validate resource type
-		if resource != models.ResourceTypeUsers &&
+		// Validate resource type; an empty one is detected from the file
+		if resource != "" &&
+			resource != models.ResourceTypeUsers &&
 			resource != models.ResourceTypeArticles &&
 			resource != models.ResourceTypeComments {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource type"})
@@ -122,34 +284,126 @@ func (h *ImportHandler) CreateImport(c *gin.Context) {
 		// Get uploaded file
 		file, header, err := c.Request.FormFile("file")
 		if err != nil {
+			if appErr := bodyError(err); appErr != nil {
+				respondError(c, h.logger, appErr)
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
 			return
 		}
 		defer file.Close()
 
 		// Check file size
-		if header.Size > int64(h.config.MaxFileSizeMB)*1024*1024 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file too large, max %dMB", h.config.MaxFileSizeMB)})
+		if header.Size > int64(cfg.MaxFileSizeMB)*1024*1024 {
+			respondError(c, h.logger, errors.ErrFileTooLarge(fmt.Sprintf("file too large, max %dMB", cfg.MaxFileSizeMB)))
+			return
+		}
+		if header.Size == 0 {
+			respondError(c, h.logger, errors.ErrEmptyFile("uploaded file is empty"))
+			return
+		}
+
+		// A digest on the file part wins over one on the request
+		checksum, err := newUploadChecksum(header.Header, c.Request.Header)
+		if err != nil {
+			respondError(c, h.logger, err)
 			return
 		}
 
 		// Save file
-		filePath, err = h.importSvc.SaveUploadedFile(file, header.Filename)
+		params.FileName = header.Filename
+		filePath, err = h.importSvc.SaveUploadedFile(jobID, checksum.Reader(file), header.Filename)
 		if err != nil {
 			h.logger.Error().Err(err).Msg("Failed to save uploaded file")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
 			return
 		}
+		if err := checksum.Verify(); err != nil {
+			h.importSvc.RemoveUpload(filePath)
+			respondError(c, h.logger, err)
+			return
+		}
+	} else if contentType == ndjsonContentType {
+		// The body is the NDJSON data itself, so options come from the query
+		resource = models.ResourceType(c.Query("resource"))
+		opts.Profile = strings.EqualFold(c.Query("profile"), "true")
+		preview = strings.EqualFold(c.Query("preview"), "true")
+		sync = strings.EqualFold(c.Query("sync"), "true")
+		params.CommentDedup = models.CommentDedup(c.Query("comment_dedup"))
+		params.Sanitize = strings.EqualFold(c.Query("sanitize"), "true")
+		params.Analyze = strings.EqualFold(c.Query("analyze"), "true")
+		params.DetectLang = strings.EqualFold(c.Query("detect_lang"), "true")
+		params.Priority = models.JobPriority(c.Query("priority"))
+		params.AllowAdminRoles = strings.EqualFold(c.Query("allow_admin_roles"), "true")
+		params.FuzzyDedup = models.UserFuzzyDedup(c.Query("fuzzy_dedup"))
+		params.CreateMissingAuthors = strings.EqualFold(c.Query("create_missing_authors"), "true")
+		params.UpsertKey = models.UpsertKey(c.Query("upsert_key"))
+		params.OnConflict = models.OnConflict(c.Query("on_conflict"))
+		params.IgnoreLedger = strings.EqualFold(c.Query("ignore_ledger"), "true")
+		fieldPaths, err := parseFieldPaths(c.Query("field_paths"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		params.FieldPaths = fieldPaths
+
+		if resource != "" &&
+			resource != models.ResourceTypeUsers &&
+			resource != models.ResourceTypeArticles &&
+			resource != models.ResourceTypeComments {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource type"})
+			return
+		}
+
+		checksum, err := newUploadChecksum(c.Request.Header)
+		if err != nil {
+			respondError(c, h.logger, err)
+			return
+		}
+
+		maxBytes := int64(cfg.MaxFileSizeMB) * 1024 * 1024
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		params.FileName = ndjsonBodyFileName
+		filePath, err = h.importSvc.SaveUploadedFile(jobID, checksum.Reader(body), ndjsonBodyFileName)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if stderrors.As(err, &tooLarge) {
+				respondError(c, h.logger, errors.ErrFileTooLarge(fmt.Sprintf("file too large, max %dMB", cfg.MaxFileSizeMB)))
+				return
+			}
+			if stderrors.Is(err, middleware.ErrSlowClient) {
+				respondError(c, h.logger, bodyError(err))
+				return
+			}
+			h.logger.Error().Err(err).Msg("Failed to save request body")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+			return
+		}
+		if err := checksum.Verify(); err != nil {
+			h.importSvc.RemoveUpload(filePath)
+			respondError(c, h.logger, err)
+			return
+		}
+		if info, err := os.Stat(filePath); err == nil && info.Size() == 0 {
+			h.importSvc.RemoveUpload(filePath)
+			respondError(c, h.logger, errors.ErrEmptyFile("request body is empty"))
+			return
+		}
 	} else {
 		// Handle JSON body with URL
 		var req CreateImportRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, h.logger, err)
 			return
 		}
 
 		resource = models.ResourceType(req.Resource)
-		if resource != models.ResourceTypeUsers &&
+		opts.Profile = req.Profile
+		preview = req.Preview
+		sync = req.Sync
+		params = req.jobParams()
+		if resource != "" &&
+			resource != models.ResourceTypeUsers &&
 			resource != models.ResourceTypeArticles &&
 			resource != models.ResourceTypeComments {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource type"})
@@ -158,32 +412,253 @@ func (h *ImportHandler) CreateImport(c *gin.Context) {
 
 		// Download file from URL
 		if req.FileURL != "" {
+			params.FileURL = req.FileURL
 			var err error
-			filePath, err = h.importSvc.DownloadFileFromURL(req.FileURL)
+			filePath, err = h.importSvc.DownloadFileFromURL(jobID, req.FileURL)
 			if err != nil {
 				h.logger.Error().Err(err).Str("url", req.FileURL).Msg("Failed to download file from URL")
 				c.JSON(http.StatusBadRequest, gin.H{"error": "failed to download file from URL: " + err.Error()})
 				return
 			}
+			if info, err := os.Stat(filePath); err == nil && info.Size() == 0 {
+				h.importSvc.RemoveUpload(filePath)
+				respondError(c, h.logger, errors.ErrEmptyFile("downloaded file is empty"))
+				return
+			}
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "file or file_url is required"})
 			return
 		}
 	}
 
+	h.startImport(c, importRequest{
+		jobID:          jobID,
+		tenantID:       tenantID,
+		idempotencyKey: idempotencyKey,
+		resource:       resource,
+		filePath:       filePath,
+		params:         params,
+		opts:           opts,
+		preview:        preview,
+		sync:           sync,
+	})
+}
+
+// importRequest is an import whose file has been saved, with the options
+// it was requested with
+type importRequest struct {
+	jobID          uuid.UUID
+	tenantID       string
+	idempotencyKey string
+	// resource is detected from the file when empty
+	resource models.ResourceType
+	filePath string
+	params   *models.JobParams
+	opts     worker.ImportOptions
+	preview  bool
+	sync     bool
+}
+
+// startImport checks the options of req against its file, then creates
+// its job and runs or queues it. The file is removed if the options are
+// refused.
+func (h *ImportHandler) startImport(c *gin.Context, req importRequest) {
+	job, detection, ok := h.prepareImport(c, req)
+	if !ok {
+		return
+	}
+	h.runImport(c, job, req, detection)
+}
+
+// prepareImport checks the options of req against its file and returns the
+// job to create, or responds and returns false when they are refused or
+// req is only a preview
+func (h *ImportHandler) prepareImport(c *gin.Context, req importRequest) (*models.Job, *parsers.ResourceDetection, bool) {
+	resource, filePath, params := req.resource, req.filePath, req.params
+
+	// Nested documents don't show their fields to resource detection
+	if resource == "" && len(params.FieldPaths) > 0 {
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "field_paths needs the resource set explicitly"})
+		return nil, nil, false
+	}
+
+	// Infer the resource when it was omitted, or report it for a preview
+	var detection *parsers.ResourceDetection
+	if resource == "" || req.preview {
+		var err error
+		detection, err = h.importSvc.DetectResource(filePath)
+		if err != nil {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file: " + err.Error()})
+			return nil, nil, false
+		}
+		if resource == "" {
+			if detection.Ambiguous {
+				h.importSvc.RemoveUpload(filePath)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":     "could not determine resource from file fields; set resource explicitly",
+					"code":      errors.ErrCodeResourceAmbiguous,
+					"detection": detection,
+				})
+				return nil, nil, false
+			}
+			resource = detection.Resource
+		}
+	}
+
+	switch params.CommentDedup {
+	case "", models.CommentDedupID:
+	case models.CommentDedupNaturalKey:
+		if resource != models.ResourceTypeComments {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "comment_dedup natural_key applies to comment imports only"})
+			return nil, nil, false
+		}
+	default:
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment_dedup, expected id or natural_key"})
+		return nil, nil, false
+	}
+
+	switch params.FuzzyDedup {
+	case "":
+	case models.UserFuzzyDedupWarn, models.UserFuzzyDedupReview:
+		if resource != models.ResourceTypeUsers {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fuzzy_dedup applies to user imports only"})
+			return nil, nil, false
+		}
+	default:
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fuzzy_dedup, expected warn or review"})
+		return nil, nil, false
+	}
+
+	if params.UpsertKey != "" && !slices.Contains(models.UpsertKeys[resource], params.UpsertKey) {
+		h.importSvc.RemoveUpload(filePath)
+		keys := make([]string, len(models.UpsertKeys[resource]))
+		for i, key := range models.UpsertKeys[resource] {
+			keys[i] = string(key)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid upsert_key for %s, expected %s", resource, strings.Join(keys, " or "))})
+		return nil, nil, false
+	}
+
+	switch params.OnConflict {
+	case "", models.OnConflictSkip:
+	case models.OnConflictUpsert, models.OnConflictReplace:
+		if resource != models.ResourceTypeUsers && resource != models.ResourceTypeArticles {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "on_conflict upsert and replace apply to user and article imports only"})
+			return nil, nil, false
+		}
+		if params.UpsertKey != "" && params.UpsertKey != models.UpsertKeyID {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "on_conflict upsert and replace apply to imports matched on id"})
+			return nil, nil, false
+		}
+	default:
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid on_conflict, expected skip, upsert or replace"})
+		return nil, nil, false
+	}
+
+	if len(params.FieldPaths) > 0 {
+		if !parsers.DetectFormat(filePath).IsNDJSON() {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "field_paths applies to NDJSON files only"})
+			return nil, nil, false
+		}
+		if _, err := parsers.NewFlattener(params.FieldPaths, resource); err != nil {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid field_paths: " + err.Error()})
+			return nil, nil, false
+		}
+	}
+
+	if !params.Priority.Valid() {
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid priority, expected low, normal or high"})
+		return nil, nil, false
+	}
+
+	if params.Sanitize && resource == models.ResourceTypeUsers {
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sanitize applies to article and comment imports only"})
+		return nil, nil, false
+	}
+
+	if params.DetectLang && resource == models.ResourceTypeUsers {
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "detect_lang applies to article and comment imports only"})
+		return nil, nil, false
+	}
+
+	if params.CreateMissingAuthors && resource != models.ResourceTypeArticles {
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "create_missing_authors applies to article imports only"})
+		return nil, nil, false
+	}
+
+	if params.AllowAdminRoles {
+		if resource != models.ResourceTypeUsers {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "allow_admin_roles applies to user imports only"})
+			return nil, nil, false
+		}
+		if !middleware.IsAdmin(c, h.adminToken) {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusForbidden, gin.H{"error": "allow_admin_roles requires the admin token", "code": errors.ErrCodeRoleNotPermitted})
+			return nil, nil, false
+		}
+	}
+
+	if req.preview {
+		h.importSvc.RemoveUpload(filePath)
+		c.JSON(http.StatusOK, ImportPreviewResponse{
+			Resource:  string(resource),
+			Detection: detection,
+		})
+		return nil, nil, false
+	}
+
+	if err := h.checkRowLimit(filePath); err != nil {
+		h.importSvc.RemoveUpload(filePath)
+		respondError(c, h.logger, err)
+		return nil, nil, false
+	}
+
+	if req.sync {
+		if msg := h.checkSyncLimits(filePath); msg != "" {
+			h.importSvc.RemoveUpload(filePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return nil, nil, false
+		}
+	}
+
 	// Create job
+	params.Format = string(parsers.DetectFormat(filePath))
+	params.Profile = req.opts.Profile
 	job := &models.Job{
-		ID:       uuid.New(),
+		ID:       req.jobID,
 		Type:     models.JobTypeImport,
 		Resource: resource,
 		Status:   models.JobStatusPending,
+		TenantID: req.tenantID,
 		FilePath: &filePath,
+		Params:   params,
 	}
 
-	if idempotencyKey != "" {
-		job.IdempotencyKey = &idempotencyKey
+	if req.idempotencyKey != "" {
+		job.IdempotencyKey = &req.idempotencyKey
 	}
+	return job, detection, true
+}
 
+// runImport creates the job of a prepared import and runs it within the
+// request when it is a sync import, or queues it
+func (h *ImportHandler) runImport(c *gin.Context, job *models.Job, req importRequest, detection *parsers.ResourceDetection) {
 	if err := h.jobRepo.Create(c.Request.Context(), job); err != nil {
 		h.logger.Error().Err(err).Msg("Failed to create job")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job"})
@@ -191,9 +666,9 @@ func (h *ImportHandler) CreateImport(c *gin.Context) {
 	}
 
 	// Store idempotency key
-	if idempotencyKey != "" {
+	if job.IdempotencyKey != nil {
 		idKey := &models.IdempotencyKey{
-			Key:       idempotencyKey,
+			Key:       *job.IdempotencyKey,
 			JobID:     job.ID,
 			ExpiresAt: job.CreatedAt.Add(config.IdempotencyTTL()),
 		}
@@ -202,40 +677,207 @@ func (h *ImportHandler) CreateImport(c *gin.Context) {
 		}
 	}
 
-	// Submit job to worker pool
+	filePath := *job.FilePath
 	source := worker.JobSource{FilePath: filePath}
 	cleanup := func() {
 		// Cleanup uploaded file after processing
-		if filePath != "" && !strings.HasPrefix(filePath, "http") {
-			os.Remove(filePath)
+		if err := h.importSvc.RemoveUpload(filePath); err != nil {
+			h.logger.Warn().Err(err).Str("job_id", job.ID.String()).Msg("Failed to remove import upload")
 		}
 	}
-	h.workerPool.SubmitImportJob(job, source, cleanup)
+
+	links := Links{
+		Self:   fmt.Sprintf("/v1/imports/%s", job.ID.String()),
+		Errors: fmt.Sprintf("/v1/imports/%s/errors", job.ID.String()),
+	}
+	if req.opts.Profile {
+		links.Profile = fmt.Sprintf("/v1/imports/%s/profile", job.ID.String())
+	}
+
+	if req.sync {
+		// Finish the job even if the client goes away mid-request
+		ctx := context.WithoutCancel(c.Request.Context())
+		h.workerPool.RunImportJob(ctx, job, source, req.opts, cleanup)
+		links.Warnings = fmt.Sprintf("/v1/imports/%s/warnings", job.ID.String())
+		h.respondSyncImport(c, job.ID, links, detection)
+		return
+	}
+
+	// Submit job to worker pool
+	h.workerPool.SubmitImportJob(job, source, req.opts, cleanup)
 
 	c.JSON(http.StatusAccepted, CreateImportResponse{
 		JobID:     job.ID.String(),
 		Status:    string(job.Status),
 		Resource:  string(job.Resource),
 		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		Links: Links{
-			Self:   fmt.Sprintf("/v1/imports/%s", job.ID.String()),
-			Errors: fmt.Sprintf("/v1/imports/%s/errors", job.ID.String()),
+		Links:     links,
+		Detection: detection,
+	})
+}
+
+// checkSyncLimits returns why a file is too large to import with sync=true,
+// or "" if it isn't
+func (h *ImportHandler) checkSyncLimits(filePath string) string {
+	cfg := h.config.Load()
+	if info, err := os.Stat(filePath); err == nil && info.Size() > cfg.SyncMaxBytes {
+		return fmt.Sprintf("file too large for a sync import, max %d bytes; omit sync to import it asynchronously", cfg.SyncMaxBytes)
+	}
+	rows, err := h.importSvc.CountRows(filePath)
+	if err != nil {
+		return "failed to read file: " + err.Error()
+	}
+	if rows > cfg.SyncMaxRows {
+		return fmt.Sprintf("file has too many rows for a sync import, max %d; omit sync to import it asynchronously", cfg.SyncMaxRows)
+	}
+	return ""
+}
+
+// checkRowLimit rejects a file with more data rows than one import may have,
+// using a line count so an oversized file never reaches a worker. The count
+// is an upper bound for CSV fields spanning lines. In truncate mode the file
+// is accepted and cut off at the limit while it is imported.
+func (h *ImportHandler) checkRowLimit(filePath string) error {
+	cfg := h.config.Load()
+	if cfg.MaxRows <= 0 || cfg.RowLimitMode == "truncate" {
+		return nil
+	}
+	rows, err := h.importSvc.CountRows(filePath)
+	if err != nil {
+		return errors.ErrInvalidRequest("failed to read file: " + err.Error())
+	}
+	if rows > cfg.MaxRows {
+		return errors.ErrRowLimitExceeded(fmt.Sprintf("file has %d data rows, max %d per import", rows, cfg.MaxRows))
+	}
+	return nil
+}
+
+// respondSyncImport writes the final state of a job run with sync=true. A
+// failed job is answered with 422 so scripted callers can check the status.
+func (h *ImportHandler) respondSyncImport(c *gin.Context, jobID uuid.UUID, links Links, detection *parsers.ResourceDetection) {
+	ctx := c.Request.Context()
+	job, err := h.jobRepo.GetByID(ctx, jobID)
+	if err != nil || job == nil {
+		h.logger.Error().Err(err).Str("job_id", jobID.String()).Msg("Failed to get sync import job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	jobErrors, totalErrors, err := h.importSvc.GetJobErrors(ctx, jobID, 1, syncResultLimit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job errors")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get errors"})
+		return
+	}
+	jobWarnings, totalWarnings, err := h.importSvc.GetJobWarnings(ctx, jobID, 1, syncResultLimit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job warnings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get warnings"})
+		return
+	}
+
+	status := http.StatusOK
+	if job.Status == models.JobStatusFailed {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, SyncImportResponse{
+		CreateImportResponse: CreateImportResponse{
+			JobID:     job.ID.String(),
+			Status:    string(job.Status),
+			Resource:  string(job.Resource),
+			CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			Links:     links,
+			Detection: detection,
 		},
+		Progress:      jobProgress(job),
+		ErrorMessage:  job.ErrorMessage,
+		Errors:        jobErrorItems(jobErrors, h.config.Load().ErrorDocsURL),
+		TotalErrors:   totalErrors,
+		Warnings:      jobWarningItems(jobWarnings),
+		TotalWarnings: totalWarnings,
+		Summary:       job.Summary,
 	})
 }
 
+// CancelImport handles DELETE /v1/imports/:job_id. It stops a pending or
+// running import, keeping the rows written so far and their counts, and
+// removes what the import staged.
+func (h *ImportHandler) CancelImport(c *gin.Context) {
+	cancelJob(c, h.jobRepo, h.workerPool, models.JobTypeImport, h.logger)
+}
+
 // GetImportStatusResponse represents the response for getting import status
 type GetImportStatusResponse struct {
-	JobID           string      `json:"job_id"`
-	Status          string      `json:"status"`
-	Resource        string      `json:"resource"`
-	Progress        JobProgress `json:"progress"`
-	StartedAt       *string     `json:"started_at,omitempty"`
-	CompletedAt     *string     `json:"completed_at,omitempty"`
-	DurationSeconds float64     `json:"duration_seconds,omitempty"`
-	RowsPerSecond   float64     `json:"rows_per_second,omitempty"`
-	ErrorMessage    *string     `json:"error_message,omitempty"`
-	Links           Links       `json:"links"`
+	JobID           string             `json:"job_id"`
+	Status          string             `json:"status"`
+	Resource        string             `json:"resource"`
+	Progress        JobProgress        `json:"progress"`
+	StartedAt       *string            `json:"started_at,omitempty"`
+	CompletedAt     *string            `json:"completed_at,omitempty"`
+	DurationSeconds float64            `json:"duration_seconds,omitempty"`
+	RowsPerSecond   float64            `json:"rows_per_second,omitempty"`
+	ErrorMessage    *string            `json:"error_message,omitempty"`
+	Params          *models.JobParams  `json:"params,omitempty"`
+	Summary         *models.JobSummary `json:"summary,omitempty"`
+	Queue           *QueueStatus       `json:"queue,omitempty"`
+	Worker          *JobWorkerInfo     `json:"worker,omitempty"`
+	Links           Links              `json:"links"`
+
+	// CurrentRowsPerSecond is the rate of a processing job's current stage,
+	// known only to the instance running it
+	CurrentRowsPerSecond *float64 `json:"current_rows_per_second,omitempty"`
+}
+
+// QueueStatus tells the caller of a pending job when it is likely to start.
+// The estimate is left out until a job of the same type has finished.
+type QueueStatus struct {
+	Position             int      `json:"position"`
+	JobsAhead            int      `json:"jobs_ahead"`
+	EstimatedWaitSeconds *float64 `json:"estimated_wait_seconds,omitempty"`
+	EstimatedStartAt     *string  `json:"estimated_start_at,omitempty"`
+}
+
+// queueStatus reports where a pending job waits in the worker pool, or nil
+// if it isn't waiting
+func queueStatus(pool *worker.Pool, job *models.Job) *QueueStatus {
+	if pool == nil || job.Status != models.JobStatusPending {
+		return nil
+	}
+	pos, ok := pool.QueuePosition(job.Type, job.ID)
+	if !ok {
+		return nil
+	}
+
+	status := &QueueStatus{Position: pos.Position, JobsAhead: pos.JobsAhead}
+	if pos.Known {
+		wait := pos.EstimatedWait.Seconds()
+		startAt := time.Now().UTC().Add(pos.EstimatedWait).Format("2006-01-02T15:04:05Z")
+		status.EstimatedWaitSeconds = &wait
+		status.EstimatedStartAt = &startAt
+	}
+	return status
+}
+
+// JobWorkerInfo names the instance, worker and version that last ran a job
+type JobWorkerInfo struct {
+	Instance string `json:"instance"`
+	WorkerID string `json:"worker_id"`
+	Version  string `json:"version"`
+}
+
+// jobWorker reports what last ran job, or nil if no worker has taken it yet
+func jobWorker(job *models.Job) *JobWorkerInfo {
+	if job.WorkerInstance == nil {
+		return nil
+	}
+	info := &JobWorkerInfo{Instance: *job.WorkerInstance}
+	if job.WorkerID != nil {
+		info.WorkerID = *job.WorkerID
+	}
+	if job.WorkerVersion != nil {
+		info.Version = *job.WorkerVersion
+	}
+	return info
 }
 
 // JobProgress represents job progress
@@ -244,18 +886,62 @@ type JobProgress struct {
 	ProcessedRecords  int     `json:"processed_records"`
 	SuccessfulRecords int     `json:"successful_records"`
 	FailedRecords     int     `json:"failed_records"`
+	WarningCount      int     `json:"warning_count"`
+	DuplicateRecords  int     `json:"duplicate_records"`
 	Percentage        float64 `json:"percentage"`
+	// InsertedRecords and UpdatedRecords split the successful records of a
+	// finished import into those it created and those it overwrote
+	InsertedRecords int `json:"inserted_records,omitempty"`
+	UpdatedRecords  int `json:"updated_records,omitempty"`
 }
 
-// GetImportStatus handles GET /v1/imports/:job_id
+// jobProgress reports the progress of an import job
+func jobProgress(job *models.Job) JobProgress {
+	progress := job.CalculateProgress()
+	p := JobProgress{
+		TotalRecords:      progress.TotalRecords,
+		ProcessedRecords:  progress.ProcessedRecords,
+		SuccessfulRecords: progress.SuccessfulRecords,
+		FailedRecords:     progress.FailedRecords,
+		WarningCount:      job.WarningCount,
+		DuplicateRecords:  job.DuplicateRecords,
+		Percentage:        progress.Percentage,
+	}
+	// A completed import without a summary overwrote no record
+	switch {
+	case job.Type != models.JobTypeImport:
+	case job.Summary != nil:
+		p.InsertedRecords = job.Summary.Inserted
+		p.UpdatedRecords = job.Summary.Updated
+	case job.Status == models.JobStatusCompleted:
+		p.InsertedRecords = progress.SuccessfulRecords
+	}
+	return p
+}
+
+// GetImportStatus handles GET /v1/imports/:job_id. With wait, such as
+// ?wait=30s, it holds the request until the job finishes or the wait runs
+// out, then reports the job as it is.
 func (h *ImportHandler) GetImportStatus(c *gin.Context) {
+	cfg := h.config.Load()
 	jobID, err := uuid.Parse(c.Param("job_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
 		return
 	}
+	wait, err := parseWait(c.Query("wait"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if wait > cfg.StatusMaxWait {
+		wait = cfg.StatusMaxWait
+	}
 
-	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	job, err := getJobStatus(c.Request.Context(), h.jobRepo, jobID)
+	if err == nil && job != nil && wait > 0 && !jobFinished(job.Status) {
+		job, err = h.waitForJob(c.Request.Context(), jobID, wait)
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get job")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
@@ -266,26 +952,32 @@ func (h *ImportHandler) GetImportStatus(c *gin.Context) {
 		return
 	}
 
-	progress := job.CalculateProgress()
-
 	response := GetImportStatusResponse{
-		JobID:    job.ID.String(),
-		Status:   string(job.Status),
-		Resource: string(job.Resource),
-		Progress: JobProgress{
-			TotalRecords:      progress.TotalRecords,
-			ProcessedRecords:  progress.ProcessedRecords,
-			SuccessfulRecords: progress.SuccessfulRecords,
-			FailedRecords:     progress.FailedRecords,
-			Percentage:        progress.Percentage,
-		},
+		JobID:        job.ID.String(),
+		Status:       string(job.Status),
+		Resource:     string(job.Resource),
+		Progress:     jobProgress(job),
 		ErrorMessage: job.ErrorMessage,
+		Params:       job.Params,
+		Summary:      job.Summary,
+		Queue:        queueStatus(h.workerPool, job),
+		Worker:       jobWorker(job),
 		Links: Links{
-			Self:   fmt.Sprintf("/v1/imports/%s", job.ID.String()),
-			Errors: fmt.Sprintf("/v1/imports/%s/errors", job.ID.String()),
+			Self:     fmt.Sprintf("/v1/imports/%s", job.ID.String()),
+			Errors:   fmt.Sprintf("/v1/imports/%s/errors", job.ID.String()),
+			Warnings: fmt.Sprintf("/v1/imports/%s/warnings", job.ID.String()),
 		},
 	}
 
+	if job.Type == models.JobTypeImport && job.Resource == models.ResourceTypeArticles {
+		indexJob, err := h.jobRepo.GetFollowUp(c.Request.Context(), job.ID, models.JobTypeIndex)
+		if err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to get search index job")
+		} else if indexJob != nil {
+			response.Links.SearchIndex = fmt.Sprintf("/v1/imports/%s", indexJob.ID.String())
+		}
+	}
+
 	if job.StartedAt != nil {
 		startedAt := job.StartedAt.Format("2006-01-02T15:04:05Z")
 		response.StartedAt = &startedAt
@@ -312,14 +1004,92 @@ func (h *ImportHandler) GetImportStatus(c *gin.Context) {
 		}
 	}
 
+	if job.Status == models.JobStatusProcessing {
+		if rate, ok := h.importSvc.CurrentRate(job.ID); ok {
+			response.CurrentRowsPerSecond = &rate
+		}
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
+// parseWait parses the wait parameter of a status request: a duration such
+// as 30s, or a number of seconds
+func parseWait(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("wait must be a duration such as 30s")
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, fmt.Errorf("wait can't be negative")
+	}
+	return wait, nil
+}
+
+// parseFieldPaths reads the field_paths form or query value, a JSON object
+// of import field to JSON path
+func parseFieldPaths(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var paths map[string]string
+	if err := json.Unmarshal([]byte(value), &paths); err != nil {
+		return nil, fmt.Errorf("field_paths must be a JSON object of field to path")
+	}
+	return paths, nil
+}
+
+// waitForJob reads the job until it finishes or wait runs out. A run ending
+// on this instance wakes it at once; runs elsewhere are seen when it next
+// reads the job.
+func (h *ImportHandler) waitForJob(ctx context.Context, jobID uuid.UUID, wait time.Duration) (*models.Job, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(statusWaitPoll)
+	defer ticker.Stop()
+
+	expired := false
+	for {
+		// Watch before reading, so a run ending in between isn't missed
+		var ended <-chan struct{}
+		stop := func() {}
+		if h.workerPool != nil {
+			ended, stop = h.workerPool.WatchJob(jobID)
+		}
+		job, err := getJobStatus(ctx, h.jobRepo, jobID)
+		if err != nil || job == nil || jobFinished(job.Status) || expired {
+			stop()
+			return job, err
+		}
+
+		select {
+		case <-ended:
+		case <-ticker.C:
+		case <-timer.C:
+			expired = true
+		case <-ctx.Done():
+			stop()
+			return job, nil
+		}
+		stop()
+	}
+}
+
 // GetImportErrorsResponse represents the response for getting import errors
 type GetImportErrorsResponse struct {
 	JobID      string         `json:"job_id"`
 	Errors     []JobErrorItem `json:"errors"`
 	Pagination PaginationInfo `json:"pagination"`
+	// SuppressedErrors counts by code the errors beyond
+	// IMPORT_MAX_ERRORS_PER_CODE, which aren't listed
+	SuppressedErrors map[string]int `json:"suppressed_errors,omitempty"`
 }
 
 // JobErrorItem represents an error item
@@ -330,6 +1100,10 @@ type JobErrorItem struct {
 	ErrorCode        string  `json:"error_code"`
 	ErrorMessage     string  `json:"error_message"`
 	RawData          *string `json:"raw_data,omitempty"`
+	// Hint and DocsURL explain the rule the row broke, for codes that have
+	// rule documentation
+	Hint    *string `json:"hint,omitempty"`
+	DocsURL *string `json:"docs_url,omitempty"`
 }
 
 // PaginationInfo represents pagination information
@@ -340,6 +1114,36 @@ type PaginationInfo struct {
 	TotalPages  int   `json:"total_pages"`
 }
 
+// jobErrorItems converts job errors to their response format, adding the
+// documentation of their rules with links to anchors on docsURL
+func jobErrorItems(jobErrors []*models.JobError, docsURL string) []JobErrorItem {
+	items := make([]JobErrorItem, 0, len(jobErrors))
+	for _, e := range jobErrors {
+		item := JobErrorItem{
+			RowNumber:        e.RowNumber,
+			RecordIdentifier: e.RecordIdentifier,
+			FieldName:        e.FieldName,
+			ErrorCode:        e.ErrorCode,
+			ErrorMessage:     e.ErrorMessage,
+			RawData:          e.RawData,
+		}
+		if doc, ok := errors.LookupRuleDoc(e.ErrorCode); ok {
+			if doc.Hint != "" {
+				item.Hint = &doc.Hint
+			}
+			link := doc.DocsURL
+			if link == "" && docsURL != "" {
+				link = docsURL + "#" + errors.RuleAnchor(e.ErrorCode)
+			}
+			if link != "" {
+				item.DocsURL = &link
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
 // GetImportErrors handles GET /v1/imports/:job_id/errors
 func (h *ImportHandler) GetImportErrors(c *gin.Context) {
 	jobID, err := uuid.Parse(c.Param("job_id"))
@@ -382,25 +1186,14 @@ func (h *ImportHandler) GetImportErrors(c *gin.Context) {
 		return
 	}
 
-	// Convert to response format
-	errorItems := make([]JobErrorItem, 0, len(jobErrors))
-	for _, e := range jobErrors {
-		errorItems = append(errorItems, JobErrorItem{
-			RowNumber:        e.RowNumber,
-			RecordIdentifier: e.RecordIdentifier,
-			FieldName:        e.FieldName,
-			ErrorCode:        e.ErrorCode,
-			ErrorMessage:     e.ErrorMessage,
-			RawData:          e.RawData,
-		})
-	}
+	errorItems := jobErrorItems(jobErrors, h.config.Load().ErrorDocsURL)
 
 	totalPages := int(total) / perPage
 	if int(total)%perPage > 0 {
 		totalPages++
 	}
 
-	c.JSON(http.StatusOK, GetImportErrorsResponse{
+	response := GetImportErrorsResponse{
 		JobID:  jobID.String(),
 		Errors: errorItems,
 		Pagination: PaginationInfo{
@@ -409,10 +1202,228 @@ func (h *ImportHandler) GetImportErrors(c *gin.Context) {
 			TotalErrors: total,
 			TotalPages:  totalPages,
 		},
+	}
+	if job.Summary != nil {
+		response.SuppressedErrors = job.Summary.SuppressedErrors
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetImportWarningsResponse represents the response for getting import warnings
+type GetImportWarningsResponse struct {
+	JobID      string                `json:"job_id"`
+	Warnings   []JobWarningItem      `json:"warnings"`
+	Pagination WarningPaginationInfo `json:"pagination"`
+}
+
+// JobWarningItem represents a warning item
+type JobWarningItem struct {
+	RowNumber        int     `json:"row_number"`
+	RecordIdentifier *string `json:"record_identifier,omitempty"`
+	FieldName        *string `json:"field_name,omitempty"`
+	WarningCode      string  `json:"warning_code"`
+	WarningMessage   string  `json:"warning_message"`
+}
+
+// WarningPaginationInfo represents pagination information for warnings
+type WarningPaginationInfo struct {
+	Page          int   `json:"page"`
+	PerPage       int   `json:"per_page"`
+	TotalWarnings int64 `json:"total_warnings"`
+	TotalPages    int   `json:"total_pages"`
+}
+
+// jobWarningItems converts job warnings to their response format
+func jobWarningItems(jobWarnings []*models.JobWarning) []JobWarningItem {
+	items := make([]JobWarningItem, 0, len(jobWarnings))
+	for _, w := range jobWarnings {
+		items = append(items, JobWarningItem{
+			RowNumber:        w.RowNumber,
+			RecordIdentifier: w.RecordIdentifier,
+			FieldName:        w.FieldName,
+			WarningCode:      w.WarningCode,
+			WarningMessage:   w.WarningMessage,
+		})
+	}
+	return items
+}
+
+// GetImportWarnings handles GET /v1/imports/:job_id/warnings
+func (h *ImportHandler) GetImportWarnings(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	// Get pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "100"))
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	// Check job exists
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	jobWarnings, total, err := h.importSvc.GetJobWarnings(c.Request.Context(), jobID, page, perPage)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job warnings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get warnings"})
+		return
+	}
+
+	warningItems := jobWarningItems(jobWarnings)
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, GetImportWarningsResponse{
+		JobID:    jobID.String(),
+		Warnings: warningItems,
+		Pagination: WarningPaginationInfo{
+			Page:          page,
+			PerPage:       perPage,
+			TotalWarnings: total,
+			TotalPages:    totalPages,
+		},
 	})
 }
 
+// GetImportProfile handles GET /v1/imports/:job_id/profile
+func (h *ImportHandler) GetImportProfile(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	profile, err := h.importSvc.GetProfile(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get import profile")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get profile"})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "profile not found; create the import with profile=true"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetImportBatchesResponse lists the batches an import wrote
+type GetImportBatchesResponse struct {
+	JobID    string                `json:"job_id"`
+	Batches  []*models.BatchCommit `json:"batches"`
+	Inserted int                   `json:"inserted"`
+	Updated  int                   `json:"updated"`
+}
+
+// GetImportBatches handles GET /v1/imports/:job_id/batches. The totals add
+// the earlier attempts whose staged rows the later ones skipped to the last
+// attempt; an attempt that wrote on the fast path had its rows written again.
+func (h *ImportHandler) GetImportBatches(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil || job.Type != models.JobTypeImport {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	batches, err := h.importSvc.GetBatchCommits(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get import batches")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get batches"})
+		return
+	}
+
+	resp := GetImportBatchesResponse{JobID: jobID.String(), Batches: batches}
+	if resp.Batches == nil {
+		resp.Batches = []*models.BatchCommit{}
+	}
+	if n := len(batches); n > 0 {
+		from := batches[n-1].Attempt
+		for i := n - 1; i >= 0 && batches[i].FirstStagingID != nil; i-- {
+			from = batches[i].Attempt
+		}
+		for _, b := range batches {
+			if b.Attempt >= from {
+				resp.Inserted += b.Inserted
+				resp.Updated += b.Updated
+			}
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 // ErrorResponse creates a standard error response
 func ErrorResponse(code, message string) *errors.AppError {
 	return errors.NewAppError(code, message, http.StatusInternalServerError)
 }
+
+// respondError writes an AppError with its status code and error code,
+// falling back to a generic 500 for any other error
+func respondError(c *gin.Context, logger zerolog.Logger, err error) {
+	if appErr, ok := err.(*errors.AppError); ok {
+		c.JSON(appErr.StatusCode, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+	if appErr := bodyError(err); appErr != nil {
+		respondError(c, logger, appErr)
+		return
+	}
+	logger.Error().Err(err).Msg("Request failed")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
+// respondBindError answers a request whose body couldn't be bound, with 413
+// or 408 when the body was cut off by the limits on request bodies
+func respondBindError(c *gin.Context, logger zerolog.Logger, err error) {
+	if appErr := bodyError(err); appErr != nil {
+		respondError(c, logger, appErr)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// bodyError returns the AppError for a failed read of the request body that
+// hit its size limit or arrived too slowly, or nil for any other error
+func bodyError(err error) *errors.AppError {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case stderrors.As(err, &tooLarge):
+		return errors.ErrRequestTooLarge(tooLarge.Limit)
+	case stderrors.Is(err, middleware.ErrSlowClient):
+		return errors.ErrRequestTimeout("request body arrived too slowly")
+	}
+	return nil
+}
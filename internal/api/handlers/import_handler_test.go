@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+const usersNDJSON = `{"email":"ann@example.com","name":"Ann","role":"admin","active":true}
+{"email":"bob@example.com","name":"Bob","role":"reader","active":false}
+`
+
+// testAdminToken is the admin token of the handler newImportRouter serves
+const testAdminToken = "test-admin-token"
+
+// newImportRouter serves CreateImport with uploads saved under uploads.
+// configure may change the import settings from their test defaults.
+func newImportRouter(uploads string, configure ...func(*config.ImportConfig)) *gin.Engine {
+	h := newImportHandler(uploads, configure...)
+	router := gin.New()
+	router.POST("/v1/imports", h.CreateImport)
+	return router
+}
+
+// newImportHandler returns the handler newImportRouter serves
+func newImportHandler(uploads string, configure ...func(*config.ImportConfig)) *ImportHandler {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	cfg := config.ImportConfig{UploadPath: uploads, MaxFileSizeMB: 1}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+
+	importSvc := importservice.NewService(memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), jobs, memory.NewStagingRepository(db), memory.NewProfileRepository(db),
+		db, nil, nil, zerolog.Nop(), cfg)
+	return NewImportHandler(importSvc, jobs, memory.NewIdempotencyRepository(db),
+		quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), nil, testAdminToken, zerolog.Nop(), cfg)
+}
+
+func TestImportHandler_NDJSONBody(t *testing.T) {
+	uploads := t.TempDir()
+	router := newImportRouter(uploads)
+
+	post := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The resource is detected from the streamed rows
+	w := post("?preview=true", usersNDJSON)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var preview ImportPreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if preview.Resource != "users" {
+		t.Errorf("resource = %q, want users", preview.Resource)
+	}
+
+	if w := post("?resource=users", ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "EMPTY_FILE") {
+		t.Errorf("empty body status = %d, body %s; want 400 EMPTY_FILE", w.Code, w.Body.String())
+	}
+	if w := post("?resource=users", strings.Repeat("x", 1024*1024+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body status = %d, want 413", w.Code)
+	}
+
+	// Nothing is left behind in the upload area
+	if entries, _ := os.ReadDir(uploads); len(entries) != 0 {
+		t.Errorf("upload area has %d files, want none", len(entries))
+	}
+}
+
+func TestImportHandler_FieldPaths(t *testing.T) {
+	router := newImportRouter(t.TempDir())
+
+	post := func(query string) *httptest.ResponseRecorder {
+		body := `{"user":{"email":"ann@example.com","name":"Ann"}}` + "\n"
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	paths := func(rules string) string {
+		return "&field_paths=" + url.QueryEscape(rules)
+	}
+
+	if w := post("?preview=true&resource=users" + paths(`{"email":"user.email","name":"user.name"}`)); w.Code != http.StatusOK {
+		t.Errorf("status = %d, body %s; want 200", w.Code, w.Body.String())
+	}
+	for name, query := range map[string]string{
+		"not an object":  "?preview=true&resource=users" + paths(`["user.email"]`),
+		"unknown field":  "?preview=true&resource=users" + paths(`{"password":"user.password"}`),
+		"bad path":       "?preview=true&resource=users" + paths(`{"email":"user..email"}`),
+		"no resource":    "?preview=true" + paths(`{"email":"user.email"}`),
+		"other resource": "?preview=true&resource=comments" + paths(`{"email":"user.email"}`),
+	} {
+		if w := post(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestImportHandler_Checksums(t *testing.T) {
+	uploads := t.TempDir()
+	router := newImportRouter(uploads)
+
+	md5Sum := md5.Sum([]byte(usersNDJSON))
+	shaSum := sha256.Sum256([]byte(usersNDJSON))
+	bodyRequest := func(header, value string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports?preview=true", strings.NewReader(usersNDJSON))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set(header, value)
+		return req
+	}
+	// The digest is set on the file part, as a client uploading several
+	// files would
+	multipartRequest := func(header, value string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("preview", "true")
+		part, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {`form-data; name="file"; filename="users.ndjson"`},
+			"Content-Type":        {"application/x-ndjson"},
+			header:                {value},
+		})
+		part.Write([]byte(usersNDJSON))
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		wantCode int
+	}{
+		{"body md5 base64", bodyRequest(ContentMD5Header, base64.StdEncoding.EncodeToString(md5Sum[:])), http.StatusOK},
+		{"body sha256 hex", bodyRequest(ContentSHA256Header, hex.EncodeToString(shaSum[:])), http.StatusOK},
+		{"body sha256 mismatch", bodyRequest(ContentSHA256Header, strings.Repeat("0", 64)), http.StatusBadRequest},
+		{"body malformed digest", bodyRequest(ContentMD5Header, "not-a-digest"), http.StatusBadRequest},
+		{"part md5", multipartRequest(ContentMD5Header, hex.EncodeToString(md5Sum[:])), http.StatusOK},
+		{"part md5 mismatch", multipartRequest(ContentMD5Header, base64.StdEncoding.EncodeToString(make([]byte, 16))), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, body %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+			if strings.HasSuffix(tt.name, "mismatch") && !strings.Contains(w.Body.String(), "CHECKSUM_MISMATCH") {
+				t.Errorf("body = %s, want CHECKSUM_MISMATCH", w.Body.String())
+			}
+		})
+	}
+
+	if entries, _ := os.ReadDir(uploads); len(entries) != 0 {
+		t.Errorf("upload area has %d files, want none", len(entries))
+	}
+}
+
+func TestImportHandler_RowLimit(t *testing.T) {
+	uploads := t.TempDir()
+	router := newImportRouter(uploads, func(cfg *config.ImportConfig) {
+		cfg.MaxRows = 1
+		cfg.RowLimitMode = "reject"
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/imports?resource=users", strings.NewReader(usersNDJSON))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "ROW_LIMIT_EXCEEDED") {
+		t.Fatalf("status = %d, body %s; want 400 ROW_LIMIT_EXCEEDED", w.Code, w.Body.String())
+	}
+	if entries, _ := os.ReadDir(uploads); len(entries) != 0 {
+		t.Errorf("upload area has %d files, want none", len(entries))
+	}
+}
+
+func TestImportHandler_AllowAdminRoles(t *testing.T) {
+	uploads := t.TempDir()
+	router := newImportRouter(uploads)
+
+	post := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports"+query, strings.NewReader(usersNDJSON))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		w := post("?resource=users&allow_admin_roles=true", token)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ROLE_NOT_PERMITTED") {
+			t.Errorf("token %q: status = %d, body %s; want 403 ROLE_NOT_PERMITTED", token, w.Code, w.Body.String())
+		}
+	}
+	if entries, _ := os.ReadDir(uploads); len(entries) != 0 {
+		t.Errorf("upload area has %d files after refusals, want none", len(entries))
+	}
+
+	if w := post("?resource=articles&allow_admin_roles=true", testAdminToken); w.Code != http.StatusBadRequest {
+		t.Errorf("article import status = %d, want 400", w.Code)
+	}
+	if w := post("?resource=users&allow_admin_roles=true&preview=true", testAdminToken); w.Code != http.StatusOK {
+		t.Errorf("admin status = %d, body %s; want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestImportHandler_FuzzyDedup(t *testing.T) {
+	router := newImportRouter(t.TempDir())
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?resource=users&fuzzy_dedup=review&preview=true", http.StatusOK},
+		{"?resource=users&fuzzy_dedup=merge", http.StatusBadRequest},
+		{"?resource=articles&fuzzy_dedup=warn", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports"+tt.query, strings.NewReader(usersNDJSON))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, body %s; want %d", tt.query, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestImportHandler_UpsertKey(t *testing.T) {
+	router := newImportRouter(t.TempDir())
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?resource=users&upsert_key=email&preview=true", http.StatusOK},
+		{"?resource=users&upsert_key=slug", http.StatusBadRequest},
+		{"?resource=comments&upsert_key=email", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports"+tt.query, strings.NewReader(usersNDJSON))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, body %s; want %d", tt.query, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestImportHandler_OnConflict(t *testing.T) {
+	router := newImportRouter(t.TempDir())
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?resource=users&on_conflict=upsert&preview=true", http.StatusOK},
+		{"?resource=users&on_conflict=skip&preview=true", http.StatusOK},
+		{"?resource=users&on_conflict=merge", http.StatusBadRequest},
+		{"?resource=users&on_conflict=replace&upsert_key=email", http.StatusBadRequest},
+		{"?resource=comments&on_conflict=upsert", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports"+tt.query, strings.NewReader(usersNDJSON))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, body %s; want %d", tt.query, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestImportHandler_StatusWait(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	cfg := config.ImportConfig{UploadPath: t.TempDir(), BatchSize: 100, StatusMaxWait: 5 * time.Second}
+	importSvc := importservice.NewService(memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), jobs, memory.NewStagingRepository(db), memory.NewProfileRepository(db),
+		db, nil, testMetrics, zerolog.Nop(), cfg)
+	pool := worker.NewPool(importSvc, nil, jobs, testMetrics, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	h := NewImportHandler(importSvc, jobs, memory.NewIdempotencyRepository(db),
+		quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), pool, testAdminToken, zerolog.Nop(), cfg)
+	router := gin.New()
+	router.GET("/v1/imports/:job_id", h.GetImportStatus)
+
+	newJob := func() *models.Job {
+		job := &models.Job{ID: uuid.New(), Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		return job
+	}
+	get := func(job *models.Job, query string) (*httptest.ResponseRecorder, time.Duration) {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/imports/"+job.ID.String()+query, nil))
+		return w, time.Since(start)
+	}
+	status := func(w *httptest.ResponseRecorder) string {
+		var resp GetImportStatusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal() error: %v, body %s", err, w.Body.String())
+		}
+		return resp.Status
+	}
+
+	job := newJob()
+	if w, _ := get(job, "?wait=soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad wait status = %d, want 400", w.Code)
+	}
+
+	// A job that doesn't finish is reported once the wait runs out
+	w, took := get(job, "?wait=100ms")
+	if w.Code != http.StatusOK || status(w) != "pending" {
+		t.Fatalf("status = %d %s, want 200 pending", w.Code, w.Body.String())
+	}
+	if took < 100*time.Millisecond {
+		t.Errorf("request returned after %s, want it to wait 100ms", took)
+	}
+
+	// A run ending on this instance wakes the request before the next poll
+	source := worker.JobSource{FilePath: writeUpload(t, cfg.UploadPath, "users.ndjson", usersNDJSON)}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pool.RunImportJob(ctx, job, source, worker.ImportOptions{}, nil)
+	}()
+	w, took = get(job, "?wait=5")
+	if status(w) != "completed" {
+		t.Errorf("status = %s, want completed", w.Body.String())
+	}
+	if took >= statusWaitPoll {
+		t.Errorf("request returned after %s, want it woken by the run", took)
+	}
+
+	// Runs elsewhere are seen on the next poll
+	defer func(poll time.Duration) { statusWaitPoll = poll }(statusWaitPoll)
+	statusWaitPoll = 20 * time.Millisecond
+	other := newJob()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		other.Status = models.JobStatusFailed
+		jobs.Update(ctx, other)
+	}()
+	if w, _ := get(other, "?wait=5s"); status(w) != "failed" {
+		t.Errorf("status = %s, want failed", w.Body.String())
+	}
+}
+
+func writeUpload(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	return path
+}
+
+// slowBody sends a few bytes of a body per read, pausing before each
+type slowBody struct {
+	data  []byte
+	pause time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(b.pause)
+	n := copy(p[:min(len(p), 8)], b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func TestImportHandler_BodyLimits(t *testing.T) {
+	h := newImportHandler(t.TempDir())
+	router := gin.New()
+	router.Use(middleware.BodyLimit(middleware.BodyLimitConfig{
+		MaxBytes:      1024,
+		RouteMaxBytes: map[string]func() int64{"POST /v1/imports": h.MaxUploadBytes},
+		MinReadRate:   1024,
+		Grace:         50 * time.Millisecond,
+	}))
+	router.POST("/v1/imports", h.CreateImport)
+	router.POST("/echo", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondBindError(c, zerolog.Nop(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	send := func(path, contentType string, body io.Reader, length int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", contentType)
+		req.ContentLength = length
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	expect := func(name string, w *httptest.ResponseRecorder, status int, code string) {
+		t.Helper()
+		if w.Code != status || !strings.Contains(w.Body.String(), code) {
+			t.Errorf("%s: status = %d, body %s; want %d %s", name, w.Code, w.Body.String(), status, code)
+		}
+	}
+
+	// A declared length over the route's limit is refused before reading
+	expect("declared", send("/v1/imports", ndjsonContentType, strings.NewReader("{}"), h.MaxUploadBytes()+1),
+		http.StatusRequestEntityTooLarge, errors.ErrCodeRequestTooLarge)
+
+	// Bodies without a length are cut off at the limit
+	big := `{"pad":"` + strings.Repeat("x", 2048) + `"}`
+	expect("undeclared", send("/echo", "application/json", strings.NewReader(big), -1),
+		http.StatusRequestEntityTooLarge, errors.ErrCodeRequestTooLarge)
+	file := strings.Repeat(`{"email":"a@example.com","name":"A","role":"admin"}`+"\n", 1024*1024/50)
+	expect("file", send("/v1/imports?resource=users", ndjsonContentType, strings.NewReader(file), -1),
+		http.StatusRequestEntityTooLarge, errors.ErrCodeFileTooLarge)
+
+	// Imports may be larger than other requests
+	w := send("/v1/imports?resource=users&preview=true", ndjsonContentType, strings.NewReader(strings.Repeat(usersNDJSON, 20)), -1)
+	if w.Code != http.StatusOK {
+		t.Errorf("import: status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	// A body trickling in under the minimum rate is dropped
+	slow := &slowBody{data: []byte(usersNDJSON), pause: 20 * time.Millisecond}
+	expect("slow", send("/v1/imports?resource=users", ndjsonContentType, slow, -1),
+		http.StatusRequestTimeout, errors.ErrCodeRequestTimeout)
+}
+
+func TestImportHandler_ErrorRuleDocs(t *testing.T) {
+	h := newImportHandler(t.TempDir(), func(cfg *config.ImportConfig) {
+		cfg.ErrorDocsURL = "https://docs.example.com/import-rules"
+	})
+	router := gin.New()
+	router.GET("/v1/imports/:job_id/errors", h.GetImportErrors)
+
+	ctx := context.Background()
+	job := &models.Job{ID: uuid.New(), Type: models.JobTypeImport, Resource: models.ResourceTypeArticles, Status: models.JobStatusCompleted}
+	if err := h.jobRepo.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	// A deployment's own rule with a page of its own
+	errors.RegisterRuleDoc("TEST_HOUSE_STYLE", errors.RuleDoc{Hint: "Follow the house style", DocsURL: "https://wiki.example.com/style"})
+	if err := h.jobRepo.AddErrors(ctx, []*models.JobError{
+		{JobID: job.ID, RowNumber: 1, ErrorCode: errors.ErrCodeInvalidSlug, ErrorMessage: "Slug must be in kebab-case format"},
+		{JobID: job.ID, RowNumber: 2, ErrorCode: "TEST_HOUSE_STYLE", ErrorMessage: "Not house style"},
+		{JobID: job.ID, RowNumber: 3, ErrorCode: "TEST_UNDOCUMENTED", ErrorMessage: "Undocumented"},
+	}); err != nil {
+		t.Fatalf("AddErrors() error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/imports/"+job.ID.String()+"/errors", nil))
+	var resp GetImportErrorsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Errors) != 3 {
+		t.Fatalf("response %d %s, want 3 errors", w.Code, w.Body.String())
+	}
+
+	want := map[string]string{
+		errors.ErrCodeInvalidSlug: "https://docs.example.com/import-rules#invalid-slug",
+		"TEST_HOUSE_STYLE":        "https://wiki.example.com/style",
+	}
+	for _, item := range resp.Errors {
+		link, documented := want[item.ErrorCode]
+		if !documented {
+			if item.Hint != nil || item.DocsURL != nil {
+				t.Errorf("%s has hint %v and docs_url %v, want neither", item.ErrorCode, item.Hint, item.DocsURL)
+			}
+			continue
+		}
+		if item.Hint == nil || *item.Hint == "" {
+			t.Errorf("%s has no hint", item.ErrorCode)
+		}
+		if item.DocsURL == nil || *item.DocsURL != link {
+			t.Errorf("%s docs_url = %v, want %s", item.ErrorCode, item.DocsURL, link)
+		}
+	}
+}
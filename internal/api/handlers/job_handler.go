@@ -0,0 +1,626 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Job event stream timing
+const (
+	// jobEventInterval is how often a job event stream checks the job
+	jobEventInterval = time.Second
+	// jobEventKeepAlive is the longest a job event stream stays silent, so
+	// proxies don't drop it while a job is queued
+	jobEventKeepAlive = 15 * time.Second
+)
+
+// JobHandler handles requests that apply to any kind of job
+type JobHandler struct {
+	jobRepo       repository.JobRepository
+	capture       *logger.Capture
+	adminToken    string
+	logger        zerolog.Logger
+	eventInterval time.Duration
+}
+
+// NewJobHandler creates a new job handler. capture may be nil when job log
+// capture is off. Only requests carrying adminToken may fail or cancel a
+// job by hand.
+func NewJobHandler(jobRepo repository.JobRepository, capture *logger.Capture, adminToken string, logger zerolog.Logger) *JobHandler {
+	return &JobHandler{
+		jobRepo:       jobRepo,
+		capture:       capture,
+		adminToken:    adminToken,
+		logger:        logger,
+		eventInterval: jobEventInterval,
+	}
+}
+
+// ListJobsResponse represents the response for listing jobs
+type ListJobsResponse struct {
+	Jobs       []JobListItem     `json:"jobs"`
+	Pagination JobPaginationInfo `json:"pagination"`
+}
+
+// JobListItem represents a job in a listing
+type JobListItem struct {
+	JobID        string         `json:"job_id"`
+	Type         string         `json:"type"`
+	Resource     string         `json:"resource"`
+	Status       string         `json:"status"`
+	Phase        string         `json:"phase,omitempty"`
+	Progress     JobProgress    `json:"progress"`
+	ErrorMessage string         `json:"error_message,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	StartedAt    *time.Time     `json:"started_at,omitempty"`
+	CompletedAt  *time.Time     `json:"completed_at,omitempty"`
+	Worker       *JobWorkerInfo `json:"worker,omitempty"`
+}
+
+// JobPaginationInfo represents pagination information for jobs
+type JobPaginationInfo struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	TotalJobs  int64 `json:"total_jobs"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// ListJobs handles GET /v1/jobs. It lists the calling tenant's jobs, newest
+// first, optionally only those of one type or status.
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "50"))
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 50
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	filter := models.JobListFilter{
+		TenantID: middleware.GetTenantID(c),
+		Type:     models.JobType(c.Query("type")),
+		Status:   models.JobStatus(c.Query("status")),
+	}
+	switch filter.Type {
+	case "", models.JobTypeImport, models.JobTypeExport, models.JobTypeIndex:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be import, export or index"})
+		return
+	}
+
+	jobs, total, err := h.jobRepo.List(c.Request.Context(), filter, page, perPage)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+		return
+	}
+
+	items := make([]JobListItem, 0, len(jobs))
+	for _, job := range jobs {
+		item := JobListItem{
+			JobID:       job.ID.String(),
+			Type:        string(job.Type),
+			Resource:    string(job.Resource),
+			Status:      string(job.Status),
+			Progress:    jobProgress(job),
+			CreatedAt:   job.CreatedAt,
+			StartedAt:   job.StartedAt,
+			CompletedAt: job.CompletedAt,
+			Worker:      jobWorker(job),
+		}
+		if job.Phase != nil {
+			item.Phase = *job.Phase
+		}
+		if job.ErrorMessage != nil {
+			item.ErrorMessage = *job.ErrorMessage
+		}
+		items = append(items, item)
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, ListJobsResponse{
+		Jobs: items,
+		Pagination: JobPaginationInfo{
+			Page:       page,
+			PerPage:    perPage,
+			TotalJobs:  total,
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// JobEvent is the data of a job event stream's events
+type JobEvent struct {
+	JobID        string      `json:"job_id"`
+	Type         string      `json:"type"`
+	Resource     string      `json:"resource"`
+	Status       string      `json:"status"`
+	Phase        string      `json:"phase,omitempty"`
+	Progress     JobProgress `json:"progress"`
+	ErrorMessage string      `json:"error_message,omitempty"`
+}
+
+// StreamJobEvents handles GET /v1/jobs/:job_id/events. It is a server-sent
+// event stream with a "progress" event whenever the job's status, phase or
+// progress changes, ending with a "done" event once the job has finished.
+func (h *JobHandler) StreamJobEvents(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	job, err := h.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(h.eventInterval)
+	defer ticker.Stop()
+
+	var last []byte
+	lastWrite := time.Now()
+	for {
+		payload, _ := json.Marshal(jobEvent(job))
+		if jobFinished(job.Status) {
+			c.SSEvent("done", string(payload))
+			c.Writer.Flush()
+			return
+		}
+		if !bytes.Equal(payload, last) {
+			c.SSEvent("progress", string(payload))
+			c.Writer.Flush()
+			last, lastWrite = payload, time.Now()
+		} else if time.Since(lastWrite) >= jobEventKeepAlive {
+			c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		job, err = getJobStatus(ctx, h.jobRepo, jobID)
+		if err != nil || job == nil {
+			if ctx.Err() == nil {
+				h.logger.Error().Err(err).Str("job_id", jobID.String()).Msg("Failed to get job for event stream")
+			}
+			return
+		}
+	}
+}
+
+// jobEvent reports job's state for its event stream
+func jobEvent(job *models.Job) JobEvent {
+	event := JobEvent{
+		JobID:    job.ID.String(),
+		Type:     string(job.Type),
+		Resource: string(job.Resource),
+		Status:   string(job.Status),
+		Progress: jobProgress(job),
+	}
+	if job.Phase != nil {
+		event.Phase = *job.Phase
+	}
+	if job.ErrorMessage != nil {
+		event.ErrorMessage = *job.ErrorMessage
+	}
+	return event
+}
+
+// jobFinished reports whether a job in status will not run again by itself
+func jobFinished(status models.JobStatus) bool {
+	switch status {
+	case models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled, models.JobStatusDeadLetter, models.JobStatusPaused:
+		return true
+	}
+	return false
+}
+
+// defaultCancelReason is the error message of a job cancelled without a
+// reason
+const defaultCancelReason = "cancelled by request"
+
+// CancelJobResponse is a job just cancelled, with the counts it had reached
+type CancelJobResponse struct {
+	JobID    string      `json:"job_id"`
+	Type     string      `json:"type"`
+	Status   string      `json:"status"`
+	Reason   string      `json:"reason"`
+	Progress JobProgress `json:"progress"`
+}
+
+// ownedByCaller reports whether job belongs to the tenant making the
+// request. Jobs of other tenants are answered as not found, so their IDs
+// can't be probed.
+func ownedByCaller(c *gin.Context, job *models.Job) bool {
+	return job.TenantID == middleware.GetTenantID(c)
+}
+
+// cancelJob cancels the pending or processing job of jobType named in the
+// request through pool, recording the change on its timeline. The optional
+// reason query parameter becomes its error message.
+func cancelJob(c *gin.Context, jobRepo repository.JobRepository, pool *worker.Pool, jobType models.JobType, log zerolog.Logger) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		reason = defaultCancelReason
+	}
+
+	ctx := c.Request.Context()
+	job, err := jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil || job.Type != jobType || !ownedByCaller(c, job) {
+		c.JSON(http.StatusNotFound, gin.H{"error": string(jobType) + " job not found"})
+		return
+	}
+
+	from := job.Status
+	cancelled, err := pool.Cancel(ctx, jobID, reason)
+	if err != nil {
+		log.Error().Err(err).Str("job_id", jobID.String()).Msg("Failed to cancel job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "only a pending or processing job can be cancelled"})
+		return
+	}
+	to := models.JobStatusCancelled
+	if err := jobRepo.AddTimelineEntry(ctx, &models.JobTimelineEntry{
+		JobID:      jobID,
+		Kind:       models.JobTimelineStatus,
+		Message:    reason,
+		FromStatus: &from,
+		ToStatus:   &to,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to record job status change")
+	}
+	log.Info().Str("job_id", jobID.String()).Str("from", string(from)).Str("reason", reason).Msg("Job cancelled")
+
+	if stored, err := jobRepo.GetByID(ctx, jobID); err == nil && stored != nil {
+		job = stored
+	}
+	c.JSON(http.StatusOK, CancelJobResponse{
+		JobID:    jobID.String(),
+		Type:     string(job.Type),
+		Status:   string(to),
+		Reason:   reason,
+		Progress: jobProgress(job),
+	})
+}
+
+// GetJobLogsResponse represents the response for getting job logs. Live is
+// true while the job runs and its lines are still being captured.
+type GetJobLogsResponse struct {
+	JobID        string            `json:"job_id"`
+	Status       string            `json:"status"`
+	Live         bool              `json:"live"`
+	Logs         []JobLogItem      `json:"logs"`
+	LinesDropped int               `json:"lines_dropped"`
+	Pagination   LogPaginationInfo `json:"pagination"`
+}
+
+// JobLogItem represents a log line
+type JobLogItem struct {
+	LineNumber int             `json:"line_number"`
+	Time       time.Time       `json:"time"`
+	Level      string          `json:"level"`
+	Message    string          `json:"message"`
+	Fields     json.RawMessage `json:"fields,omitempty"`
+}
+
+// LogPaginationInfo represents pagination information for job logs
+type LogPaginationInfo struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	TotalLines int64 `json:"total_lines"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// GetJobLogs handles GET /v1/jobs/:job_id/logs
+func (h *JobHandler) GetJobLogs(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	// Get pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "100"))
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil || !ownedByCaller(c, job) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	resp := GetJobLogsResponse{
+		JobID:  jobID.String(),
+		Status: string(job.Status),
+		Logs:   []JobLogItem{},
+	}
+	var total int64
+
+	// A running job's lines are only in memory until it finishes
+	if entries, dropped, ok := h.capture.Entries(jobID.String()); ok {
+		resp.Live = true
+		resp.LinesDropped = dropped
+		total = int64(len(entries))
+		start := (page - 1) * perPage
+		if start < len(entries) {
+			end := start + perPage
+			if end > len(entries) {
+				end = len(entries)
+			}
+			for i, e := range entries[start:end] {
+				resp.Logs = append(resp.Logs, JobLogItem{
+					LineNumber: dropped + start + i + 1,
+					Time:       e.Time,
+					Level:      e.Level,
+					Message:    e.Message,
+					Fields:     e.Fields,
+				})
+			}
+		}
+	} else {
+		jobLogs, n, err := h.jobRepo.GetLogs(c.Request.Context(), jobID, page, perPage)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to get job logs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get logs"})
+			return
+		}
+		resp.LinesDropped = job.LogLinesDropped
+		total = n
+		for _, l := range jobLogs {
+			item := JobLogItem{
+				LineNumber: l.LineNumber,
+				Time:       l.LoggedAt,
+				Level:      l.Level,
+				Message:    l.Message,
+			}
+			if l.Fields != nil {
+				item.Fields = json.RawMessage(*l.Fields)
+			}
+			resp.Logs = append(resp.Logs, item)
+		}
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+	resp.Pagination = LogPaginationInfo{
+		Page:       page,
+		PerPage:    perPage,
+		TotalLines: total,
+		TotalPages: totalPages,
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateJobRequest adds a note to a job, marks a stuck job failed or
+// cancelled, or both. Reason is required with Status; Author names the
+// operator on the timeline.
+type UpdateJobRequest struct {
+	Note   string `json:"note,omitempty"`
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+// JobTimelineResponse is a job's status and its timeline, oldest first
+type JobTimelineResponse struct {
+	JobID    string                     `json:"job_id"`
+	Status   string                     `json:"status"`
+	Timeline []*models.JobTimelineEntry `json:"timeline"`
+}
+
+// UpdateJob handles PATCH /v1/jobs/:job_id. A status change only applies to
+// a pending or processing job. A worker running a job marked failed isn't
+// stopped, so it is meant for jobs that are stuck; one marked cancelled stops
+// at its next heartbeat, and DELETE on the import or export stops it at once.
+func (h *JobHandler) UpdateJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	var req UpdateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	status := models.JobStatus(req.Status)
+	switch {
+	case req.Note == "" && status == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note or status is required"})
+		return
+	case status != "" && status != models.JobStatusFailed && status != models.JobStatusCancelled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be failed or cancelled"})
+		return
+	case status != "" && req.Reason == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required to change the status"})
+		return
+	case status != "" && !middleware.IsAdmin(c, h.adminToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "admin token required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	job, err := h.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	var author *string
+	if req.Author != "" {
+		author = &req.Author
+	}
+
+	if status != "" {
+		from := job.Status
+		changed, err := h.jobRepo.FinishManually(ctx, jobID, status, req.Reason)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to update job status")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update job"})
+			return
+		}
+		if !changed {
+			c.JSON(http.StatusConflict, gin.H{"error": "only a pending or processing job can be marked " + req.Status})
+			return
+		}
+		if err := h.jobRepo.AddTimelineEntry(ctx, &models.JobTimelineEntry{
+			JobID:      jobID,
+			Kind:       models.JobTimelineStatus,
+			Message:    req.Reason,
+			FromStatus: &from,
+			ToStatus:   &status,
+			Author:     author,
+		}); err != nil {
+			h.logger.Error().Err(err).Msg("Failed to record job status change")
+		}
+		h.logger.Warn().
+			Str("job_id", jobID.String()).
+			Str("from", string(from)).
+			Str("to", req.Status).
+			Str("reason", req.Reason).
+			Msg("Job status changed by hand")
+	}
+
+	if req.Note != "" {
+		if err := h.jobRepo.AddTimelineEntry(ctx, &models.JobTimelineEntry{
+			JobID:   jobID,
+			Kind:    models.JobTimelineNote,
+			Message: req.Note,
+			Author:  author,
+		}); err != nil {
+			h.logger.Error().Err(err).Msg("Failed to add job note")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add note"})
+			return
+		}
+	}
+
+	h.respondTimeline(c, jobID)
+}
+
+// GetJobTimeline handles GET /v1/jobs/:job_id/timeline
+func (h *JobHandler) GetJobTimeline(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	h.respondTimeline(c, jobID)
+}
+
+func (h *JobHandler) respondTimeline(c *gin.Context, jobID uuid.UUID) {
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	timeline, err := h.jobRepo.GetTimeline(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job timeline")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get timeline"})
+		return
+	}
+	if timeline == nil {
+		timeline = []*models.JobTimelineEntry{}
+	}
+	c.JSON(http.StatusOK, JobTimelineResponse{
+		JobID:    jobID.String(),
+		Status:   string(job.Status),
+		Timeline: timeline,
+	})
+}
+
+// statusReader is a job repository that can serve status polls from a
+// short-lived cache
+type statusReader interface {
+	GetStatus(ctx context.Context, id uuid.UUID) (*models.Job, error)
+}
+
+// getJobStatus reads a job for a status response, from the status cache
+// when jobs has one
+func getJobStatus(ctx context.Context, jobs repository.JobRepository, id uuid.UUID) (*models.Job, error) {
+	if cached, ok := jobs.(statusReader); ok {
+		return cached.GetStatus(ctx, id)
+	}
+	return jobs.GetByID(ctx, id)
+}
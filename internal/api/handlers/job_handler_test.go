@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+func TestJobHandler_GetJobLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	ctx := context.Background()
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusCompleted}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	var logs []*models.JobLog
+	for i := 1; i <= 3; i++ {
+		logs = append(logs, &models.JobLog{LineNumber: i + 2, Level: "info", Message: fmt.Sprintf("line %d", i), LoggedAt: time.Now()})
+	}
+	if err := jobs.AddLogs(ctx, job.ID, logs, 2); err != nil {
+		t.Fatalf("AddLogs() error: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/v1/jobs/:job_id/logs", NewJobHandler(jobs, nil, testAdminToken, zerolog.Nop()).GetJobLogs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+job.ID.String()+"/logs?page=2&per_page=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp GetJobLogsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if resp.Live || resp.LinesDropped != 2 || resp.Pagination.TotalLines != 3 || resp.Pagination.TotalPages != 2 {
+		t.Errorf("response = %+v, want stored logs with 2 dropped lines over 2 pages", resp)
+	}
+	if len(resp.Logs) != 1 || resp.Logs[0].LineNumber != 5 || resp.Logs[0].Message != "line 3" {
+		t.Errorf("logs = %+v, want only the last line", resp.Logs)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+uuid.NewString()+"/logs", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want 404", w.Code)
+	}
+
+	// Another tenant's job is not found
+	other := gin.New()
+	other.Use(func(c *gin.Context) { c.Set(middleware.TenantContextKey, "acme") })
+	other.GET("/v1/jobs/:job_id/logs", NewJobHandler(jobs, nil, testAdminToken, zerolog.Nop()).GetJobLogs)
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+job.ID.String()+"/logs", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("other tenant's job status = %d, want 404", w.Code)
+	}
+}
+
+func TestJobHandler_ListJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jobs := memory.NewJobRepository(memory.NewDB())
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	for i, job := range []*models.Job{
+		{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusCompleted},
+		{Type: models.JobTypeExport, Resource: models.ResourceTypeArticles, Status: models.JobStatusPending},
+		{Type: models.JobTypeImport, Resource: models.ResourceTypeComments, Status: models.JobStatusProcessing},
+		{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusCompleted, TenantID: "acme"},
+	} {
+		job.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.Use(middleware.Tenant(config.QuotaConfig{}))
+	router.GET("/v1/jobs", NewJobHandler(jobs, nil, testAdminToken, zerolog.Nop()).ListJobs)
+	list := func(query string) ListJobsResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /v1/jobs%s status = %d, body %s", query, w.Code, w.Body.String())
+		}
+		var resp ListJobsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal() error: %v", err)
+		}
+		return resp
+	}
+
+	// The other tenant's job is left out, and the newest job comes first
+	resp := list("")
+	if resp.Pagination.TotalJobs != 3 || len(resp.Jobs) != 3 || resp.Jobs[0].Resource != "comments" {
+		t.Errorf("jobs = %+v, want the default tenant's 3 jobs newest first", resp.Jobs)
+	}
+	resp = list("?type=import&per_page=1&page=2")
+	if resp.Pagination.TotalJobs != 2 || resp.Pagination.TotalPages != 2 || len(resp.Jobs) != 1 || resp.Jobs[0].Resource != "users" {
+		t.Errorf("second page of imports = %+v %+v", resp.Jobs, resp.Pagination)
+	}
+	resp = list("?status=pending")
+	if len(resp.Jobs) != 1 || resp.Jobs[0].Type != "export" {
+		t.Errorf("pending jobs = %+v, want the export", resp.Jobs)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs?type=backup", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown type status = %d, want 400", w.Code)
+	}
+}
+
+func TestJobHandler_StreamJobEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jobs := memory.NewJobRepository(memory.NewDB())
+	ctx := context.Background()
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusProcessing, TotalRecords: 10}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	h := NewJobHandler(jobs, nil, testAdminToken, zerolog.Nop())
+	h.eventInterval = time.Millisecond
+	router := gin.New()
+	router.GET("/v1/jobs/:job_id/events", h.StreamJobEvents)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		jobs.UpdateProgress(ctx, job.ID, 5, 5, 0)
+		time.Sleep(20 * time.Millisecond)
+		jobs.SetCompleted(ctx, job.ID, 10, 0)
+	}()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+job.ID.String()+"/events", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// Unchanged polls send nothing, so there is one event per change
+	body := w.Body.String()
+	if n := strings.Count(body, "event:progress"); n != 2 {
+		t.Errorf("got %d progress events, want 2:\n%s", n, body)
+	}
+	if !strings.Contains(body, `"processed_records":5`) {
+		t.Errorf("no event for the progress update:\n%s", body)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(body), "}") || !strings.Contains(body, "event:done") || !strings.Contains(body, `"status":"completed"`) {
+		t.Errorf("stream didn't end with the completed job:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+uuid.NewString()+"/events", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want 404", w.Code)
+	}
+}
+
+func TestJobHandler_UpdateJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jobs := memory.NewJobRepository(memory.NewDB())
+	ctx := context.Background()
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusProcessing}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	h := NewJobHandler(jobs, nil, testAdminToken, zerolog.Nop())
+	router := gin.New()
+	router.PATCH("/v1/jobs/:job_id", h.UpdateJob)
+	router.GET("/v1/jobs/:job_id/timeline", h.GetJobTimeline)
+	patch := func(body string, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/v1/jobs/"+job.ID.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for body, want := range map[string]int{
+		`{}`:                                     http.StatusBadRequest,
+		`{"status": "completed", "reason": "x"}`: http.StatusBadRequest,
+		`{"status": "failed"}`:                   http.StatusBadRequest,
+	} {
+		if w := patch(body, true); w.Code != want {
+			t.Errorf("%s: status = %d, want %d", body, w.Code, want)
+		}
+	}
+
+	// Anyone may add a note; changing the status takes the admin token
+	if w := patch(`{"note": "source file regenerated, see OPS-12", "author": "dana"}`, false); w.Code != http.StatusOK {
+		t.Fatalf("note status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := patch(`{"status": "failed", "reason": "worker host lost"}`, false); w.Code != http.StatusUnauthorized {
+		t.Errorf("status change without admin token = %d, want 401", w.Code)
+	}
+	w := patch(`{"status": "failed", "reason": "worker host lost", "author": "dana"}`, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status change = %d, body %s", w.Code, w.Body.String())
+	}
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusFailed || stored.ErrorMessage == nil || *stored.ErrorMessage != "worker host lost" || stored.CompletedAt == nil {
+		t.Errorf("job = %s (%v), want failed with the reason", stored.Status, stored.ErrorMessage)
+	}
+
+	// A finished job can't be marked again
+	if w := patch(`{"status": "cancelled", "reason": "again"}`, true); w.Code != http.StatusConflict {
+		t.Errorf("second status change = %d, want 409", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+job.ID.String()+"/timeline", nil))
+	var resp JobTimelineResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if resp.Status != "failed" || len(resp.Timeline) != 2 {
+		t.Fatalf("timeline = %+v, want a note and a status change on a failed job", resp)
+	}
+	note, change := resp.Timeline[0], resp.Timeline[1]
+	if note.Kind != models.JobTimelineNote || note.Message != "source file regenerated, see OPS-12" || note.Author == nil || *note.Author != "dana" {
+		t.Errorf("first entry = %+v, want the note by dana", note)
+	}
+	if change.Kind != models.JobTimelineStatus || *change.FromStatus != models.JobStatusProcessing || *change.ToStatus != models.JobStatusFailed {
+		t.Errorf("second entry = %+v, want processing -> failed", change)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	pool := worker.NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	router := gin.New()
+	router.DELETE("/v1/imports/:job_id", (&ImportHandler{jobRepo: jobs, workerPool: pool, logger: zerolog.Nop()}).CancelImport)
+	router.DELETE("/v1/exports/:job_id", (&ExportHandler{jobRepo: jobs, workerPool: pool, logger: zerolog.Nop()}).CancelExport)
+
+	newJob := func(jobType models.JobType, status models.JobStatus) *models.Job {
+		job := &models.Job{Type: jobType, Resource: models.ResourceTypeUsers, Status: status, ProcessedRecords: 7, SuccessfulRecords: 5}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		return job
+	}
+	cancel := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, path, nil))
+		return w
+	}
+
+	// Another tenant's job is not found and keeps running
+	foreign := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusProcessing, TenantID: "acme"}
+	if err := jobs.Create(ctx, foreign); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if w := cancel("/v1/imports/" + foreign.ID.String()); w.Code != http.StatusNotFound {
+		t.Errorf("cancel of another tenant's job status = %d, want 404", w.Code)
+	}
+	if stored, _ := jobs.GetByID(ctx, foreign.ID); stored.Status != models.JobStatusProcessing {
+		t.Errorf("another tenant's job = %s, want it still processing", stored.Status)
+	}
+
+	job := newJob(models.JobTypeImport, models.JobStatusProcessing)
+	w := cancel("/v1/imports/" + job.ID.String() + "?reason=wrong+file")
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp CancelJobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if resp.Status != "cancelled" || resp.Reason != "wrong file" || resp.Progress.SuccessfulRecords != 5 {
+		t.Errorf("response = %+v, want cancelled for the reason with 5 written", resp)
+	}
+	timeline, _ := jobs.GetTimeline(ctx, job.ID)
+	if len(timeline) != 1 || *timeline[0].FromStatus != models.JobStatusProcessing || *timeline[0].ToStatus != models.JobStatusCancelled {
+		t.Errorf("timeline = %+v, want the change from processing to cancelled", timeline)
+	}
+
+	// Finished jobs can't be cancelled, and each endpoint only takes its own
+	// kind of job
+	if w := cancel("/v1/imports/" + job.ID.String()); w.Code != http.StatusConflict {
+		t.Errorf("cancel of a cancelled job status = %d, want 409", w.Code)
+	}
+	export := newJob(models.JobTypeExport, models.JobStatusPending)
+	if w := cancel("/v1/imports/" + export.ID.String()); w.Code != http.StatusNotFound {
+		t.Errorf("cancel of an export as an import status = %d, want 404", w.Code)
+	}
+	if w := cancel("/v1/exports/" + export.ID.String()); w.Code != http.StatusOK {
+		t.Errorf("cancel of a pending export status = %d, body %s", w.Code, w.Body.String())
+	}
+	stored, _ := jobs.GetByID(ctx, export.ID)
+	if stored.Status != models.JobStatusCancelled || *stored.ErrorMessage != defaultCancelReason {
+		t.Errorf("export = %s, %q; want cancelled with the default reason", stored.Status, *stored.ErrorMessage)
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+// Limits on the jobs listed in an overview
+const (
+	overviewActiveJobs     = 100
+	overviewRecentFailures = 10
+	overviewPausedJobs     = 10
+)
+
+// OverviewHandler reports the runtime state of the service in one payload
+type OverviewHandler struct {
+	jobRepo    repository.JobRepository
+	workerPool *worker.Pool
+	importSvc  *importservice.Service
+	db         *sqlx.DB
+	cfg        *config.Config
+	logger     zerolog.Logger
+}
+
+// NewOverviewHandler creates a new overview handler. importSvc and db may be
+// nil, which leave the import circuit breaker and the pool stats out.
+func NewOverviewHandler(jobRepo repository.JobRepository, workerPool *worker.Pool, importSvc *importservice.Service, db *sqlx.DB, cfg *config.Config, logger zerolog.Logger) *OverviewHandler {
+	return &OverviewHandler{
+		jobRepo:    jobRepo,
+		workerPool: workerPool,
+		importSvc:  importSvc,
+		db:         db,
+		cfg:        cfg,
+		logger:     logger,
+	}
+}
+
+// OverviewResponse is the runtime picture returned by GET /v1/admin/overview.
+// Jobs come from the database and cover every instance; queues, storage and
+// the connection pool are those of the instance that answered.
+type OverviewResponse struct {
+	GeneratedAt    string                   `json:"generated_at"`
+	Instance       string                   `json:"instance"`
+	Version        string                   `json:"version"`
+	ActiveJobs     []ActiveJobItem          `json:"active_jobs"`
+	TotalActive    int64                    `json:"total_active"`
+	Queues         map[string]QueueOverview `json:"queues"`
+	RecentFailures []FailedJobItem          `json:"recent_failures"`
+	TotalFailed    int64                    `json:"total_failed"`
+	TotalDead      int64                    `json:"total_dead_letter"`
+	PausedJobs     []PausedJobItem          `json:"paused_jobs"`
+	TotalPaused    int64                    `json:"total_paused"`
+	ImportBreaker  *BreakerOverview         `json:"import_breaker,omitempty"`
+	Storage        []StorageUsage           `json:"storage"`
+	Database       *DBPoolStats             `json:"database,omitempty"`
+}
+
+// ActiveJobItem is a processing job with how far it has got
+type ActiveJobItem struct {
+	JobID            string         `json:"job_id"`
+	Type             string         `json:"type"`
+	Resource         string         `json:"resource"`
+	TenantID         string         `json:"tenant_id"`
+	Phase            string         `json:"phase,omitempty"`
+	StartedAt        *time.Time     `json:"started_at,omitempty"`
+	ElapsedSeconds   float64        `json:"elapsed_seconds"`
+	ProcessedRecords int            `json:"processed_records"`
+	TotalRecords     int            `json:"total_records"`
+	RowsPerSecond    float64        `json:"rows_per_second"`
+	HeartbeatAt      *time.Time     `json:"heartbeat_at,omitempty"`
+	Worker           *JobWorkerInfo `json:"worker,omitempty"`
+}
+
+// QueueOverview describes one of the answering instance's job queues
+type QueueOverview struct {
+	Depth          int     `json:"depth"`
+	Capacity       int     `json:"capacity"`
+	Workers        int     `json:"workers"`
+	MaxWaitSeconds float64 `json:"max_wait_seconds"`
+}
+
+// FailedJobItem is a recently failed job
+type FailedJobItem struct {
+	JobID        string         `json:"job_id"`
+	Type         string         `json:"type"`
+	Resource     string         `json:"resource"`
+	TenantID     string         `json:"tenant_id"`
+	Attempts     int            `json:"attempts"`
+	ErrorMessage string         `json:"error_message,omitempty"`
+	FailedAt     *time.Time     `json:"failed_at,omitempty"`
+	Worker       *JobWorkerInfo `json:"worker,omitempty"`
+}
+
+// PausedJobItem is an import the circuit breaker paused, waiting to be
+// resumed
+type PausedJobItem struct {
+	JobID             string         `json:"job_id"`
+	Resource          string         `json:"resource"`
+	TenantID          string         `json:"tenant_id"`
+	SuccessfulRecords int            `json:"successful_records"`
+	ErrorMessage      string         `json:"error_message,omitempty"`
+	PausedAt          time.Time      `json:"paused_at"`
+	Worker            *JobWorkerInfo `json:"worker,omitempty"`
+}
+
+// BreakerOverview is the state of the answering instance's import circuit
+// breaker: disabled, closed, or open once Threshold batch inserts in a row
+// have failed
+type BreakerOverview struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Threshold           int        `json:"threshold"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	Trips               int        `json:"trips"`
+}
+
+// StorageUsage is the space taken by one of the local data directories
+type StorageUsage struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+// DBPoolStats reports the database connection pool
+type DBPoolStats struct {
+	MaxOpen             int     `json:"max_open"`
+	Open                int     `json:"open"`
+	InUse               int     `json:"in_use"`
+	Idle                int     `json:"idle"`
+	WaitCount           int64   `json:"wait_count"`
+	WaitDurationSeconds float64 `json:"wait_duration_seconds"`
+	MaxIdleClosed       int64   `json:"max_idle_closed"`
+	MaxLifetimeClosed   int64   `json:"max_lifetime_closed"`
+}
+
+// GetOverview handles GET /v1/admin/overview
+func (h *OverviewHandler) GetOverview(c *gin.Context) {
+	ctx := c.Request.Context()
+	now := time.Now().UTC()
+
+	active, totalActive, err := h.jobRepo.ListByStatus(ctx, models.JobStatusProcessing, 1, overviewActiveJobs)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list active jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list active jobs"})
+		return
+	}
+	failed, totalFailed, err := h.jobRepo.ListByStatus(ctx, models.JobStatusFailed, 1, overviewRecentFailures)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list failed jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list failed jobs"})
+		return
+	}
+	_, totalDead, err := h.jobRepo.ListByStatus(ctx, models.JobStatusDeadLetter, 1, 1)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to count dead letters")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count dead letters"})
+		return
+	}
+	paused, totalPaused, err := h.jobRepo.ListByStatus(ctx, models.JobStatusPaused, 1, overviewPausedJobs)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list paused jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list paused jobs"})
+		return
+	}
+
+	response := OverviewResponse{
+		GeneratedAt:    now.Format(time.RFC3339),
+		Instance:       h.cfg.App.InstanceID,
+		Version:        h.cfg.App.Version,
+		ActiveJobs:     make([]ActiveJobItem, 0, len(active)),
+		TotalActive:    totalActive,
+		Queues:         make(map[string]QueueOverview),
+		RecentFailures: make([]FailedJobItem, 0, len(failed)),
+		TotalFailed:    totalFailed,
+		TotalDead:      totalDead,
+		PausedJobs:     make([]PausedJobItem, 0, len(paused)),
+		TotalPaused:    totalPaused,
+		Storage: []StorageUsage{
+			dirUsage("uploads", h.cfg.Import.UploadPath),
+			dirUsage("exports", h.cfg.Export.OutputPath),
+		},
+	}
+
+	for _, job := range active {
+		response.ActiveJobs = append(response.ActiveJobs, activeJobItem(job, now))
+	}
+	for _, job := range failed {
+		item := FailedJobItem{
+			JobID:    job.ID.String(),
+			Type:     string(job.Type),
+			Resource: string(job.Resource),
+			TenantID: job.TenantID,
+			Attempts: job.Attempts,
+			FailedAt: job.CompletedAt,
+			Worker:   jobWorker(job),
+		}
+		if job.ErrorMessage != nil {
+			item.ErrorMessage = *job.ErrorMessage
+		}
+		response.RecentFailures = append(response.RecentFailures, item)
+	}
+
+	for _, job := range paused {
+		item := PausedJobItem{
+			JobID:             job.ID.String(),
+			Resource:          string(job.Resource),
+			TenantID:          job.TenantID,
+			SuccessfulRecords: job.SuccessfulRecords,
+			PausedAt:          job.UpdatedAt,
+			Worker:            jobWorker(job),
+		}
+		if job.ErrorMessage != nil {
+			item.ErrorMessage = *job.ErrorMessage
+		}
+		response.PausedJobs = append(response.PausedJobs, item)
+	}
+
+	if h.importSvc != nil {
+		b := h.importSvc.Breaker()
+		response.ImportBreaker = &BreakerOverview{
+			State:               b.State,
+			ConsecutiveFailures: b.ConsecutiveFailures,
+			Threshold:           b.Threshold,
+			OpenedAt:            b.OpenedAt,
+			LastError:           b.LastError,
+			LastFailureAt:       b.LastFailureAt,
+			Trips:               b.Trips,
+		}
+	}
+
+	if h.workerPool != nil {
+		for jobType, q := range h.workerPool.Queues() {
+			response.Queues[string(jobType)] = QueueOverview{
+				Depth:          q.Depth,
+				Capacity:       q.Capacity,
+				Workers:        q.Workers,
+				MaxWaitSeconds: q.MaxWait.Seconds(),
+			}
+		}
+	}
+
+	if h.db != nil {
+		stats := h.db.Stats()
+		response.Database = &DBPoolStats{
+			MaxOpen:             stats.MaxOpenConnections,
+			Open:                stats.OpenConnections,
+			InUse:               stats.InUse,
+			Idle:                stats.Idle,
+			WaitCount:           stats.WaitCount,
+			WaitDurationSeconds: stats.WaitDuration.Seconds(),
+			MaxIdleClosed:       stats.MaxIdleClosed,
+			MaxLifetimeClosed:   stats.MaxLifetimeClosed,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// activeJobItem reports a processing job's phase and throughput at now
+func activeJobItem(job *models.Job, now time.Time) ActiveJobItem {
+	item := ActiveJobItem{
+		JobID:            job.ID.String(),
+		Type:             string(job.Type),
+		Resource:         string(job.Resource),
+		TenantID:         job.TenantID,
+		StartedAt:        job.StartedAt,
+		ProcessedRecords: job.ProcessedRecords,
+		TotalRecords:     job.TotalRecords,
+		HeartbeatAt:      job.HeartbeatAt,
+		Worker:           jobWorker(job),
+	}
+	if job.Phase != nil {
+		item.Phase = *job.Phase
+	}
+	if job.StartedAt != nil {
+		item.ElapsedSeconds = now.Sub(*job.StartedAt).Seconds()
+		if item.ElapsedSeconds > 0 {
+			item.RowsPerSecond = float64(job.ProcessedRecords) / item.ElapsedSeconds
+		}
+	}
+	return item
+}
+
+// dirUsage adds up the files under path. A directory that can't be read is
+// reported with the error rather than failing the overview.
+func dirUsage(name, path string) StorageUsage {
+	usage := StorageUsage{Name: name, Path: path}
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Removed since the directory was read
+			return nil
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		usage.Error = err.Error()
+	}
+	return usage
+}
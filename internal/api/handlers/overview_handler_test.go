@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+func TestOverviewHandler_GetOverview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jobs := memory.NewJobRepository(memory.NewDB())
+	ctx := context.Background()
+
+	started := time.Now().Add(-10 * time.Second)
+	active := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusProcessing, StartedAt: &started, ProcessedRecords: 500}
+	failureMsg := "parse failed"
+	failed := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeArticles, Status: models.JobStatusFailed, ErrorMessage: &failureMsg}
+	dead := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeArticles, Status: models.JobStatusDeadLetter}
+	pauseMsg := "import paused by the circuit breaker"
+	paused := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeComments, Status: models.JobStatusPaused, ErrorMessage: &pauseMsg}
+	for _, job := range []*models.Job{active, failed, dead, paused} {
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	if err := jobs.SetPhase(ctx, active.ID, "insert"); err != nil {
+		t.Fatalf("SetPhase() error: %v", err)
+	}
+
+	uploads := t.TempDir()
+	os.MkdirAll(filepath.Join(uploads, "job"), 0755)
+	os.WriteFile(filepath.Join(uploads, "job", "users.csv"), []byte("id\n1\n"), 0644)
+
+	cfg := &config.Config{
+		App:    config.AppConfig{InstanceID: "api-1", Version: "v1.0.0"},
+		Import: config.ImportConfig{UploadPath: uploads},
+		Export: config.ExportConfig{OutputPath: filepath.Join(uploads, "missing")},
+	}
+	pool := worker.NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{ImportWorkers: 2, ExportWorkers: 1, QueueSize: 4})
+
+	router := gin.New()
+	router.GET("/v1/admin/overview", NewOverviewHandler(jobs, pool, nil, nil, cfg, zerolog.Nop()).GetOverview)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/overview", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp OverviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+
+	if resp.Instance != "api-1" || resp.Version != "v1.0.0" {
+		t.Errorf("instance = %s %s, want api-1 v1.0.0", resp.Instance, resp.Version)
+	}
+	if resp.TotalActive != 1 || len(resp.ActiveJobs) != 1 {
+		t.Fatalf("active jobs = %+v, want one", resp.ActiveJobs)
+	}
+	if job := resp.ActiveJobs[0]; job.Phase != "insert" || job.ElapsedSeconds < 10 || job.RowsPerSecond <= 0 || job.RowsPerSecond > 50 {
+		t.Errorf("active job = %+v, want phase insert at about 50 rows/s", job)
+	}
+	if resp.TotalFailed != 1 || len(resp.RecentFailures) != 1 || resp.RecentFailures[0].ErrorMessage != failureMsg {
+		t.Errorf("recent failures = %+v, want the failed export", resp.RecentFailures)
+	}
+	if resp.TotalDead != 1 {
+		t.Errorf("total dead letters = %d, want 1", resp.TotalDead)
+	}
+	if resp.TotalPaused != 1 || len(resp.PausedJobs) != 1 || resp.PausedJobs[0].ErrorMessage != pauseMsg {
+		t.Errorf("paused jobs = %+v, want the paused import", resp.PausedJobs)
+	}
+	if resp.ImportBreaker != nil {
+		t.Errorf("import breaker = %+v, want none without an import service", resp.ImportBreaker)
+	}
+	if q := resp.Queues["import"]; q.Capacity != 4 || q.Workers != 2 {
+		t.Errorf("import queue = %+v, want capacity 4 with 2 workers", q)
+	}
+	if len(resp.Storage) != 2 || resp.Storage[0].Files != 1 || resp.Storage[0].Bytes != 5 {
+		t.Fatalf("storage = %+v, want one 5 byte upload", resp.Storage)
+	}
+	if resp.Storage[1].Error == "" {
+		t.Errorf("missing export directory reported no error")
+	}
+	if resp.Database != nil {
+		t.Errorf("database = %+v, want none without a DB", resp.Database)
+	}
+}
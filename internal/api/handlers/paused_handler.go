@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+// PausedJobHandler resumes imports the circuit breaker paused
+type PausedJobHandler struct {
+	jobRepo    repository.JobRepository
+	workerPool *worker.Pool
+	logger     zerolog.Logger
+}
+
+// NewPausedJobHandler creates a new paused job handler
+func NewPausedJobHandler(jobRepo repository.JobRepository, workerPool *worker.Pool, logger zerolog.Logger) *PausedJobHandler {
+	return &PausedJobHandler{
+		jobRepo:    jobRepo,
+		workerPool: workerPool,
+		logger:     logger,
+	}
+}
+
+// ResumeJob handles POST /v1/admin/jobs/:job_id/resume. The job keeps its ID
+// and attempts and reads its upload again, skipping the rows already written.
+func (h *PausedJobHandler) ResumeJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if job.Status != models.JobStatusPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "job is not paused"})
+		return
+	}
+
+	if err := h.workerPool.Resume(c.Request.Context(), job); err != nil {
+		if errors.Is(err, worker.ErrUploadMissing) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to resume job")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "failed to resume job: " + err.Error()})
+		return
+	}
+
+	h.logger.Info().Str("job_id", job.ID.String()).Msg("Paused job resumed")
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":   job.ID.String(),
+		"type":     string(job.Type),
+		"status":   string(job.Status),
+		"attempts": job.Attempts,
+	})
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	"github.com/rs/zerolog"
+)
+
+// QuotaHandler handles quota-related HTTP requests
+type QuotaHandler struct {
+	quotaSvc *quotaservice.Service
+	logger   zerolog.Logger
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(quotaSvc *quotaservice.Service, logger zerolog.Logger) *QuotaHandler {
+	return &QuotaHandler{
+		quotaSvc: quotaSvc,
+		logger:   logger,
+	}
+}
+
+// GetQuota handles GET /v1/quota
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+	status, err := h.quotaSvc.Status(c.Request.Context(), middleware.GetTenantID(c))
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get quota status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
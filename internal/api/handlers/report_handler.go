@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	reportservice "github.com/rohit/bulk-import-export/internal/service/report"
+	"github.com/rs/zerolog"
+)
+
+// ReportHandler handles usage report HTTP requests
+type ReportHandler struct {
+	reportSvc  *reportservice.Service
+	adminToken string
+	logger     zerolog.Logger
+}
+
+// NewReportHandler creates a new report handler. Requests carrying
+// adminToken may report on every tenant; others see only their own.
+func NewReportHandler(reportSvc *reportservice.Service, adminToken string, logger zerolog.Logger) *ReportHandler {
+	return &ReportHandler{
+		reportSvc:  reportSvc,
+		adminToken: adminToken,
+		logger:     logger,
+	}
+}
+
+// GetUsage handles GET /v1/reports/usage
+func (h *ReportHandler) GetUsage(c *gin.Context) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = parseReportTime(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: expected RFC3339 or YYYY-MM-DD"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = parseReportTime(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: expected RFC3339 or YYYY-MM-DD"})
+			return
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	// Admins see every tenant, or the one they ask for
+	tenantID := middleware.GetTenantID(c)
+	if middleware.IsAdmin(c, h.adminToken) {
+		tenantID = c.Query("tenant_id")
+	}
+
+	report, err := h.reportSvc.Usage(c.Request.Context(), from, to, tenantID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get usage report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get usage report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// parseReportTime parses an RFC3339 time or a YYYY-MM-DD date (midnight UTC)
+func parseReportTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	seedservice "github.com/rohit/bulk-import-export/internal/service/seed"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+// Seed outputs
+const (
+	seedOutputImport = "import"
+	seedOutputFile   = "file"
+)
+
+// SeedHandler generates synthetic data for load testing
+type SeedHandler struct {
+	seedSvc    *seedservice.Service
+	importSvc  *importservice.Service
+	jobRepo    repository.JobRepository
+	workerPool *worker.Pool
+	logger     zerolog.Logger
+	config     atomic.Pointer[config.ImportConfig]
+}
+
+// NewSeedHandler creates a new seed handler
+func NewSeedHandler(
+	seedSvc *seedservice.Service,
+	importSvc *importservice.Service,
+	jobRepo repository.JobRepository,
+	workerPool *worker.Pool,
+	logger zerolog.Logger,
+	cfg config.ImportConfig,
+) *SeedHandler {
+	h := &SeedHandler{
+		seedSvc:    seedSvc,
+		importSvc:  importSvc,
+		jobRepo:    jobRepo,
+		workerPool: workerPool,
+		logger:     logger,
+	}
+	h.config.Store(&cfg)
+	return h
+}
+
+// SetConfig replaces the import settings when they are reloaded
+func (h *SeedHandler) SetConfig(cfg config.ImportConfig) {
+	h.config.Store(&cfg)
+}
+
+// SeedRequest asks for synthetic rows. InvalidPct of the rows fail
+// validation and DuplicatePct repeat an earlier row's id. Output import
+// (the default) imports the rows; file returns them as an import file,
+// which must then be of one resource.
+type SeedRequest struct {
+	Users        int     `json:"users"`
+	Articles     int     `json:"articles"`
+	Comments     int     `json:"comments"`
+	InvalidPct   float64 `json:"invalid_pct"`
+	DuplicatePct float64 `json:"duplicate_pct"`
+	Seed         *int64  `json:"seed,omitempty"`
+	Output       string  `json:"output,omitempty"`
+}
+
+// SeedResponse lists the import jobs of a seed
+type SeedResponse struct {
+	Seed int64           `json:"seed"`
+	Jobs []SeedJobResult `json:"jobs"`
+}
+
+// SeedJobResult is one import job of a seed
+type SeedJobResult struct {
+	JobID    string `json:"job_id"`
+	Resource string `json:"resource"`
+	Rows     int    `json:"rows"`
+	Links    Links  `json:"links"`
+}
+
+// seedFile is the generated file of one resource
+type seedFile struct {
+	resource models.ResourceType
+	name     string
+	rows     int
+	write    func(io.Writer) error
+}
+
+// seedFiles returns the files a request generates, in the order they have
+// to be written and imported: users, then articles, then comments
+func seedFiles(req *SeedRequest, g *seedservice.Generator) []seedFile {
+	all := []seedFile{
+		{models.ResourceTypeUsers, "users.csv", req.Users, g.WriteUsers},
+		{models.ResourceTypeArticles, "articles.ndjson", req.Articles, g.WriteArticles},
+		{models.ResourceTypeComments, "comments.ndjson", req.Comments, g.WriteComments},
+	}
+	files := all[:0]
+	for _, f := range all {
+		if f.rows > 0 {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Seed handles POST /v1/admin/seed. Rows are named after the seed, so the
+// same seed generates the same rows and another seed doesn't collide with
+// them.
+func (h *SeedHandler) Seed(c *gin.Context) {
+	cfg := h.config.Load()
+	var req SeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
+		return
+	}
+	if req.Output == "" {
+		req.Output = seedOutputImport
+	}
+	if req.Output != seedOutputImport && req.Output != seedOutputFile {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "output must be 'import' or 'file'"})
+		return
+	}
+	total := req.Users + req.Articles + req.Comments
+	if total <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ask for at least one user, article or comment"})
+		return
+	}
+	if cfg.SeedMaxRows > 0 && total > cfg.SeedMaxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d rows can be seeded at once", cfg.SeedMaxRows)})
+		return
+	}
+	seed := time.Now().UnixNano()
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+
+	g, err := h.seedSvc.NewGenerator(c.Request.Context(), seedservice.Options{
+		Users:        req.Users,
+		Articles:     req.Articles,
+		Comments:     req.Comments,
+		InvalidPct:   req.InvalidPct,
+		DuplicatePct: req.DuplicatePct,
+		Seed:         seed,
+		Label:        fmt.Sprintf("seed%d", uint64(seed)),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	files := seedFiles(&req, g)
+
+	if req.Output == seedOutputFile {
+		if len(files) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a seed file holds one resource; ask for users, articles or comments"})
+			return
+		}
+		h.streamFile(c, files[0])
+		return
+	}
+	h.importFiles(c, seed, files)
+}
+
+// streamFile sends a generated file as a download
+func (h *SeedHandler) streamFile(c *gin.Context, f seedFile) {
+	contentType := "application/x-ndjson"
+	if f.resource == models.ResourceTypeUsers {
+		contentType = "text/csv"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.name))
+	c.Status(http.StatusOK)
+	if err := f.write(c.Writer); err != nil {
+		// The status is already sent; the client sees a short file
+		h.logger.Error().Err(err).Str("resource", string(f.resource)).Msg("Failed to stream seed file")
+	}
+}
+
+// importFiles saves the generated files as uploads and imports them one
+// after another, so articles and comments find the rows they reference.
+// The imports run outside the queue, like sync imports.
+func (h *SeedHandler) importFiles(c *gin.Context, seed int64, files []seedFile) {
+	ctx := c.Request.Context()
+	tenantID := middleware.GetTenantID(c)
+
+	jobs := make([]*models.Job, 0, len(files))
+	removeAll := func() {
+		for _, job := range jobs {
+			h.importSvc.RemoveUpload(*job.FilePath)
+		}
+	}
+	for _, f := range files {
+		jobID := uuid.New()
+		filePath, err := h.saveFile(jobID, f)
+		if err != nil {
+			removeAll()
+			h.logger.Error().Err(err).Str("resource", string(f.resource)).Msg("Failed to save seed file")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate seed file"})
+			return
+		}
+		jobs = append(jobs, &models.Job{
+			ID:       jobID,
+			Type:     models.JobTypeImport,
+			Resource: f.resource,
+			Status:   models.JobStatusPending,
+			TenantID: tenantID,
+			FilePath: &filePath,
+			Params:   &models.JobParams{Format: string(parsers.DetectFormat(filePath))},
+		})
+	}
+	for _, job := range jobs {
+		if err := h.jobRepo.Create(ctx, job); err != nil {
+			removeAll()
+			h.logger.Error().Err(err).Msg("Failed to create job")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job"})
+			return
+		}
+	}
+
+	resp := SeedResponse{Seed: seed, Jobs: make([]SeedJobResult, len(jobs))}
+	for i, job := range jobs {
+		resp.Jobs[i] = SeedJobResult{
+			JobID:    job.ID.String(),
+			Resource: string(job.Resource),
+			Rows:     files[i].rows,
+			Links: Links{
+				Self:   fmt.Sprintf("/v1/imports/%s", job.ID.String()),
+				Errors: fmt.Sprintf("/v1/imports/%s/errors", job.ID.String()),
+			},
+		}
+	}
+
+	h.logger.Info().Int64("seed", seed).Int("jobs", len(jobs)).Msg("Seeding synthetic data")
+	go h.runJobs(context.WithoutCancel(ctx), jobs)
+
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// saveFile writes a generated file to the upload directory of jobID
+func (h *SeedHandler) saveFile(jobID uuid.UUID, f seedFile) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(f.write(pw))
+	}()
+	filePath, err := h.importSvc.SaveUploadedFile(jobID, pr, f.name)
+	// Unblocks the writer if the save stopped early
+	pr.Close()
+	return filePath, err
+}
+
+// runJobs imports the seed files in order, removing each once imported
+func (h *SeedHandler) runJobs(ctx context.Context, jobs []*models.Job) {
+	for _, job := range jobs {
+		filePath := *job.FilePath
+		cleanup := func() {
+			if err := h.importSvc.RemoveUpload(filePath); err != nil {
+				h.logger.Warn().Err(err).Str("job_id", job.ID.String()).Msg("Failed to remove seed upload")
+			}
+		}
+		h.workerPool.RunImportJob(ctx, job, worker.JobSource{FilePath: filePath}, worker.ImportOptions{}, cleanup)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	seedservice "github.com/rohit/bulk-import-export/internal/service/seed"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+func newSeedRouter(t *testing.T) (*gin.Engine, *memory.JobRepository, *memory.DB) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	users, articles := memory.NewUserRepository(db), memory.NewArticleRepository(db)
+	cfg := config.ImportConfig{UploadPath: t.TempDir(), BatchSize: 100, SeedMaxRows: 1000}
+
+	importSvc := importservice.NewService(users, articles, memory.NewCommentRepository(db), jobs,
+		memory.NewStagingRepository(db), memory.NewProfileRepository(db), db, nil, testMetrics, zerolog.Nop(), cfg)
+	pool := worker.NewPool(importSvc, nil, jobs, testMetrics, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	h := NewSeedHandler(seedservice.NewService(users, articles, zerolog.Nop()), importSvc, jobs, pool, zerolog.Nop(), cfg)
+
+	router := gin.New()
+	router.POST("/v1/admin/seed", h.Seed)
+	return router, jobs, db
+}
+
+func postSeed(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/admin/seed", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSeedHandler_File(t *testing.T) {
+	router, _, _ := newSeedRouter(t)
+
+	w := postSeed(router, `{"users": 10, "invalid_pct": 100, "seed": 3, "output": "file"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "users.csv") {
+		t.Errorf("Content-Disposition = %q, want users.csv", cd)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 11 {
+		t.Fatalf("lines = %d, want a header and 10 rows", len(lines))
+	}
+	if !strings.Contains(lines[1], "-at-seed3") {
+		t.Errorf("row %q has a valid email, want every row invalid", lines[1])
+	}
+
+	// The same seed gives the same file
+	if again := postSeed(router, `{"users": 10, "invalid_pct": 100, "seed": 3, "output": "file"}`); again.Body.String() != w.Body.String() {
+		t.Error("the same seed gave a different file")
+	}
+}
+
+func TestSeedHandler_RejectsBadRequests(t *testing.T) {
+	router, _, _ := newSeedRouter(t)
+
+	for _, body := range []string{
+		`{}`,
+		`{"users": 10, "output": "tape"}`,
+		`{"users": 1001}`,
+		`{"users": 10, "invalid_pct": 150}`,
+		`{"users": 10, "articles": 10, "output": "file"}`,
+		`{"articles": 10}`,
+	} {
+		if w := postSeed(router, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestSeedHandler_Import(t *testing.T) {
+	router, jobs, db := newSeedRouter(t)
+
+	w := postSeed(router, `{"users": 20, "articles": 10, "invalid_pct": 0, "duplicate_pct": 0, "seed": 1}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp SeedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if resp.Seed != 1 || len(resp.Jobs) != 2 || resp.Jobs[0].Resource != "users" || resp.Jobs[1].Resource != "articles" {
+		t.Fatalf("response = %+v, want a users then an articles job for seed 1", resp)
+	}
+
+	// The articles job runs after the users it references are imported
+	deadline := time.Now().Add(5 * time.Second)
+	for _, item := range resp.Jobs {
+		for {
+			job, err := jobs.GetByID(context.Background(), uuid.MustParse(item.JobID))
+			if err != nil {
+				t.Fatalf("GetByID() error: %v", err)
+			}
+			if job.Status == models.JobStatusCompleted || job.Status == models.JobStatusFailed {
+				if job.Status != models.JobStatusCompleted || job.SuccessfulRecords != item.Rows {
+					t.Errorf("%s job = %s with %d rows imported, want completed with %d", item.Resource, job.Status, job.SuccessfulRecords, item.Rows)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s job still %s", item.Resource, job.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	count, err := memory.NewArticleRepository(db).Count(context.Background(), &models.ExportFilters{})
+	if err != nil || count != 10 {
+		t.Errorf("articles = %d (%v), want 10", count, err)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	signingservice "github.com/rohit/bulk-import-export/internal/service/signing"
+	"github.com/rs/zerolog"
+)
+
+// SigningKeyHandler lists, rotates and retires the keys export manifests and
+// download URLs are signed with
+type SigningKeyHandler struct {
+	signingSvc *signingservice.Service
+	logger     zerolog.Logger
+}
+
+// NewSigningKeyHandler creates a new signing key handler
+func NewSigningKeyHandler(signingSvc *signingservice.Service, logger zerolog.Logger) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		signingSvc: signingSvc,
+		logger:     logger,
+	}
+}
+
+// SigningKeyItem describes a signing key. Secret is the base64 of the HMAC
+// secret, given only when a single key is asked for.
+type SigningKeyItem struct {
+	ID        string    `json:"id"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+// ListSigningKeysResponse represents the response for listing signing keys
+type ListSigningKeysResponse struct {
+	Keys []SigningKeyItem `json:"keys"`
+}
+
+// ListSigningKeys handles GET /v1/admin/signing-keys
+func (h *SigningKeyHandler) ListSigningKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	keys, err := h.signingSvc.Keys(ctx)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	active, err := h.signingSvc.ActiveKeyID(ctx)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	resp := ListSigningKeysResponse{Keys: make([]SigningKeyItem, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, signingKeyItem(key, active, false))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetSigningKey handles GET /v1/admin/signing-keys/:key_id, giving the
+// secret clients verify signatures of the key with
+func (h *SigningKeyHandler) GetSigningKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	key, err := h.signingSvc.Key(ctx, c.Param("key_id"))
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	active, err := h.signingSvc.ActiveKeyID(ctx)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, signingKeyItem(key, active, true))
+}
+
+// RotateSigningKey handles POST /v1/admin/signing-keys, creating the key
+// that signs from now on
+func (h *SigningKeyHandler) RotateSigningKey(c *gin.Context) {
+	key, err := h.signingSvc.Rotate(c.Request.Context())
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	h.logger.Info().Str("key_id", key.ID).Msg("Rotated signing key")
+	c.JSON(http.StatusCreated, signingKeyItem(key, key.ID, true))
+}
+
+// RetireSigningKey handles DELETE /v1/admin/signing-keys/:key_id. What the
+// key signed no longer verifies.
+func (h *SigningKeyHandler) RetireSigningKey(c *gin.Context) {
+	keyID := c.Param("key_id")
+	if err := h.signingSvc.Retire(c.Request.Context(), keyID); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	h.logger.Info().Str("key_id", keyID).Msg("Retired signing key")
+	c.Status(http.StatusNoContent)
+}
+
+func signingKeyItem(key *models.SigningKey, active string, withSecret bool) SigningKeyItem {
+	item := SigningKeyItem{ID: key.ID, Active: key.ID == active, CreatedAt: key.CreatedAt}
+	if withSecret {
+		item.Secret = base64.StdEncoding.EncodeToString(key.Secret)
+	}
+	return item
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// spillWriter passes a streaming export through to the client until a write
+// or flush to it takes longer than the spill threshold. From then on the
+// export is appended to a temporary file that a background goroutine copies
+// to the client, so the export finishes reading from the database at disk
+// speed and releases its connection while the slow client is still reading.
+// Once maxBytes are waiting on disk, writes block until the client catches
+// up.
+type spillWriter struct {
+	w         io.Writer
+	flusher   http.Flusher
+	threshold time.Duration
+	dir       string
+	maxBytes  int64
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	file    *os.File // nil until the stream spills
+	written int64    // bytes appended to file
+	sent    int64    // bytes of file copied to the client
+	closed  bool
+	err     error // the first failed write to the client
+	done    chan struct{}
+}
+
+// newSpillWriter wraps the client w, spilling to a file in dir once a write
+// takes longer than threshold; 0 disables spilling. maxBytes bounds the
+// spill file; 0 leaves it unbounded.
+func newSpillWriter(w io.Writer, threshold time.Duration, dir string, maxBytes int64) *spillWriter {
+	s := &spillWriter{w: w, threshold: threshold, dir: dir, maxBytes: maxBytes, done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	s.flusher, _ = w.(http.Flusher)
+	return s
+}
+
+func (s *spillWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.file == nil {
+		start := time.Now()
+		n, err := s.w.Write(p)
+		if err != nil {
+			s.err = err
+			return n, err
+		}
+		s.spillIfSlow(start)
+		return n, nil
+	}
+
+	for s.maxBytes > 0 && s.written-s.sent >= s.maxBytes && s.err == nil {
+		s.cond.Wait()
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.file.WriteAt(p, s.written)
+	s.written += int64(n)
+	s.cond.Broadcast()
+	return n, err
+}
+
+// Flush implements http.Flusher. Once the stream spills, the goroutine
+// copying the file flushes the client instead.
+func (s *spillWriter) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil || s.flusher == nil {
+		return
+	}
+	start := time.Now()
+	s.flusher.Flush()
+	s.spillIfSlow(start)
+}
+
+// Spilled reports whether the stream is being served from disk
+func (s *spillWriter) Spilled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file != nil
+}
+
+// spillIfSlow starts spilling when the client took longer than the
+// threshold over a write that began at start. A spill file that can't be
+// created leaves the stream writing to the client.
+func (s *spillWriter) spillIfSlow(start time.Time) {
+	if s.threshold <= 0 || time.Since(start) <= s.threshold {
+		return
+	}
+	file, err := os.CreateTemp(s.dir, "export-spill-*")
+	if err != nil {
+		return
+	}
+	s.file = file
+	go s.drain()
+}
+
+// drain copies the spill file to the client as it grows, until Close
+func (s *spillWriter) drain() {
+	defer close(s.done)
+	buf := make([]byte, 32*1024)
+	for {
+		s.mu.Lock()
+		for s.sent == s.written && !s.closed {
+			s.cond.Wait()
+		}
+		if s.sent == s.written {
+			s.mu.Unlock()
+			return
+		}
+		offset, end := s.sent, s.written
+		s.mu.Unlock()
+
+		for offset < end {
+			n, err := s.file.ReadAt(buf[:min(int64(len(buf)), end-offset)], offset)
+			if err == nil {
+				_, err = s.w.Write(buf[:n])
+			}
+			if err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.cond.Broadcast()
+				s.mu.Unlock()
+				return
+			}
+			offset += int64(n)
+		}
+		if s.flusher != nil {
+			s.flusher.Flush()
+		}
+
+		s.mu.Lock()
+		s.sent = offset
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// Close waits for a spilled stream to reach the client and removes its
+// file. It returns the first error writing to the client.
+func (s *spillWriter) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	file := s.file
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if file != nil {
+		<-s.done
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
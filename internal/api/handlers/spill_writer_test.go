@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowClient is a client that takes delay over every write, and fails once
+// it has been sent failAfter writes when that is set
+type slowClient struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	delay     time.Duration
+	writes    int
+	failAfter int
+}
+
+func (c *slowClient) Write(p []byte) (int, error) {
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes++
+	if c.failAfter > 0 && c.writes > c.failAfter {
+		return 0, errors.New("connection reset")
+	}
+	return c.buf.Write(p)
+}
+
+func (c *slowClient) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+func TestSpillWriter_SpillsForSlowClient(t *testing.T) {
+	dir := t.TempDir()
+	client := &slowClient{delay: 20 * time.Millisecond}
+	s := newSpillWriter(client, 5*time.Millisecond, dir, 0)
+
+	var want bytes.Buffer
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		line := fmt.Sprintf("{\"id\":%d}\n", i)
+		want.WriteString(line)
+		if _, err := s.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+		s.Flush()
+	}
+	// Only the first write waited for the client
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("writes took %s, want them spilled to disk", elapsed)
+	}
+	if !s.Spilled() {
+		t.Fatal("Spilled() = false, want the stream spilled")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if client.String() != want.String() {
+		t.Errorf("client got %q, want %q", client.String(), want.String())
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("spill files left behind: %v", files)
+	}
+}
+
+func TestSpillWriter_FastClientNeverSpills(t *testing.T) {
+	var out bytes.Buffer
+	s := newSpillWriter(&out, time.Second, t.TempDir(), 0)
+	s.Write([]byte("{\"id\":1}\n"))
+	if s.Spilled() || out.String() != "{\"id\":1}\n" {
+		t.Errorf("spilled = %v, output %q; want the record written through", s.Spilled(), out.String())
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+}
+
+func TestSpillWriter_ClientErrorStopsWrites(t *testing.T) {
+	client := &slowClient{delay: 10 * time.Millisecond, failAfter: 2}
+	s := newSpillWriter(client, time.Millisecond, t.TempDir(), 0)
+
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		_, err = s.Write(bytes.Repeat([]byte("x"), 1024))
+		time.Sleep(time.Millisecond)
+	}
+	if err == nil {
+		t.Error("Write() kept succeeding after the client failed")
+	}
+	if err := s.Close(); err == nil {
+		t.Error("Close() = nil, want the client's error")
+	}
+}
+
+func TestSpillWriter_MaxBytesWaitsForClient(t *testing.T) {
+	dir := t.TempDir()
+	client := &slowClient{delay: 10 * time.Millisecond}
+	s := newSpillWriter(client, time.Millisecond, dir, 64)
+
+	for i := 0; i < 10; i++ {
+		if _, err := s.Write(bytes.Repeat([]byte("y"), 32)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+		s.mu.Lock()
+		waiting := s.written - s.sent
+		s.mu.Unlock()
+		if waiting > 64+32 {
+			t.Fatalf("%d bytes waiting on disk, want at most a write past the 64 byte limit", waiting)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if len(client.String()) != 320 {
+		t.Errorf("client got %d bytes, want 320", len(client.String()))
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("spill files left behind: %v", files)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamWriter buffers a streaming export response and flushes it to the
+// client on demand, typically once per database batch. While the export is
+// quiet it also sends keepalives so proxies don't drop the connection during
+// long scans. The keepalive is a bare newline: NDJSON readers skip blank
+// lines and it is insignificant whitespace inside a JSON array, since writes
+// only ever land between records.
+type streamWriter struct {
+	mu        sync.Mutex
+	buf       *bufio.Writer
+	flusher   http.Flusher
+	lastFlush time.Time
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newStreamWriter wraps w in a bufferSize buffer and sends keepalives after
+// each keepalive interval without output; 0 disables them. Call Close before
+// writing anything else to w directly.
+func newStreamWriter(w io.Writer, bufferSize int, keepalive time.Duration) *streamWriter {
+	s := &streamWriter{
+		buf:       bufio.NewWriterSize(w, bufferSize),
+		lastFlush: time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	s.flusher, _ = w.(http.Flusher)
+
+	if keepalive <= 0 {
+		close(s.done)
+		return s
+	}
+	go s.keepalive(keepalive)
+	return s
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+// Flush implements http.Flusher, sending buffered records to the client
+func (s *streamWriter) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+}
+
+func (s *streamWriter) flushLocked() error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	s.lastFlush = time.Now()
+	return nil
+}
+
+func (s *streamWriter) keepalive(interval time.Duration) {
+	defer close(s.done)
+
+	// Check at a finer grain than interval so a ping goes out roughly
+	// interval after the last flush rather than up to twice that
+	tick := interval / 4
+	if tick <= 0 {
+		tick = interval
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			var err error
+			if time.Since(s.lastFlush) >= interval {
+				// Buffered records keep the connection alive on their own
+				if s.buf.Buffered() == 0 {
+					_, err = s.buf.Write([]byte("\n"))
+				}
+				if err == nil {
+					err = s.flushLocked()
+				}
+			}
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close stops keepalives and flushes anything still buffered
+func (s *streamWriter) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.stop)
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushLocked()
+}
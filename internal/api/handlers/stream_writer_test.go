@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamWriter_KeepaliveOnlyWhenIdle(t *testing.T) {
+	var out bytes.Buffer
+	s := newStreamWriter(&out, 4096, 20*time.Millisecond)
+
+	s.Write([]byte("{\"id\":1}\n"))
+	s.Flush()
+	time.Sleep(70 * time.Millisecond)
+	s.Write([]byte("{\"id\":2}\n"))
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != `{"id":1}` {
+		t.Errorf("first line = %q, want the first record", lines[0])
+	}
+	if !strings.HasSuffix(out.String(), "{\"id\":2}\n") {
+		t.Errorf("output %q does not end with the second record", out.String())
+	}
+	if !strings.Contains(out.String(), "}\n\n") {
+		t.Errorf("output %q has no keepalive newline", out.String())
+	}
+}
+
+func TestStreamWriter_BuffersUntilFlush(t *testing.T) {
+	var out bytes.Buffer
+	s := newStreamWriter(&out, 4096, 0)
+
+	s.Write([]byte("{\"id\":1}\n"))
+	if out.Len() != 0 {
+		t.Errorf("wrote %d bytes before Flush, want 0", out.Len())
+	}
+	s.Flush()
+	if out.String() != "{\"id\":1}\n" {
+		t.Errorf("after Flush output = %q", out.String())
+	}
+}
+
+// benchmarkStream sends records through a real HTTP connection, flushing
+// every batchSize records as the export service does per database batch.
+// bufferSize 0 writes straight to the ResponseWriter.
+func benchmarkStream(b *testing.B, bufferSize int) {
+	const records, batchSize = 10000, 1000
+	record := []byte(`{"id":"5864905b-ec8c-4fa6-8ba7-545d13f29b4e","email":"user@example.com","name":"Test User","role":"admin","active":true,"created_at":"2024-01-01T00:00:00Z"}` + "\n")
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var w io.Writer = rw
+		var s *streamWriter
+		if bufferSize > 0 {
+			s = newStreamWriter(rw, bufferSize, 0)
+			w = s
+		}
+		for i := 1; i <= records; i++ {
+			w.Write(record)
+			if i%batchSize == 0 {
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+		}
+		if s != nil {
+			s.Close()
+		}
+	}))
+	defer server.Close()
+
+	b.SetBytes(int64(records * len(record)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func BenchmarkStreamWriter(b *testing.B) {
+	for _, size := range []int{0, 4 * 1024, 64 * 1024, 256 * 1024} {
+		b.Run(fmt.Sprintf("buffer=%dKB", size/1024), func(b *testing.B) {
+			benchmarkStream(b, size)
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rohit/bulk-import-export/internal/worker"
+)
+
+// StartUploadRequest represents the request body for starting a resumable
+// upload
+type StartUploadRequest struct {
+	// FileName names the file, whose extension picks its format
+	FileName string `json:"file_name" binding:"required"`
+}
+
+// UploadSessionResponse describes a resumable upload and the parts received
+// for it
+type UploadSessionResponse struct {
+	UploadID      string               `json:"upload_id"`
+	FileName      string               `json:"file_name"`
+	Status        string               `json:"status"`
+	Parts         []*models.UploadPart `json:"parts"`
+	ReceivedBytes int64                `json:"received_bytes"`
+	MaxPartBytes  int64                `json:"max_part_bytes"`
+	MaxFileBytes  int64                `json:"max_file_bytes"`
+	CreatedAt     string               `json:"created_at"`
+	ExpiresAt     string               `json:"expires_at"`
+	// JobID is the import the upload was completed into
+	JobID string      `json:"job_id,omitempty"`
+	Links UploadLinks `json:"links"`
+}
+
+// UploadLinks represents the links of a resumable upload. Part is a
+// template, with {part_number} to be filled in.
+type UploadLinks struct {
+	Self     string `json:"self"`
+	Part     string `json:"part"`
+	Complete string `json:"complete"`
+	Import   string `json:"import,omitempty"`
+}
+
+// MaxPartBytes is the largest request body PutUploadPart accepts
+func (h *ImportHandler) MaxPartBytes() int64 {
+	return int64(h.config.Load().UploadPartMaxMB) * 1024 * 1024
+}
+
+// uploadSessionResponse describes session and its parts
+func (h *ImportHandler) uploadSessionResponse(session *models.UploadSession, parts []*models.UploadPart) UploadSessionResponse {
+	self := fmt.Sprintf("/v1/imports/uploads/%s", session.ID)
+	resp := UploadSessionResponse{
+		UploadID:     session.ID.String(),
+		FileName:     session.FileName,
+		Status:       string(session.Status),
+		Parts:        parts,
+		MaxPartBytes: h.MaxPartBytes(),
+		MaxFileBytes: int64(h.config.Load().MaxFileSizeMB) * 1024 * 1024,
+		CreatedAt:    session.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:    session.ExpiresAt.Format(time.RFC3339),
+		Links: UploadLinks{
+			Self:     self,
+			Part:     self + "/parts/{part_number}",
+			Complete: self + "/complete",
+		},
+	}
+	if resp.Parts == nil {
+		resp.Parts = []*models.UploadPart{}
+	}
+	for _, part := range parts {
+		resp.ReceivedBytes += part.SizeBytes
+	}
+	if session.JobID != nil {
+		resp.JobID = session.JobID.String()
+		resp.Links.Import = fmt.Sprintf("/v1/imports/%s", session.JobID)
+	}
+	return resp
+}
+
+// respondUploadError answers a resumable upload request that failed
+func (h *ImportHandler) respondUploadError(c *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, importservice.ErrUploadSessionsDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case stderrors.Is(err, importservice.ErrUploadSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case stderrors.Is(err, importservice.ErrUploadSessionClosed), stderrors.Is(err, importservice.ErrUploadIncomplete):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case stderrors.Is(err, importservice.ErrUploadPartTooLarge):
+		cfg := h.config.Load()
+		respondError(c, h.logger, errors.ErrFileTooLarge(fmt.Sprintf("part too large, max %dMB per part and %dMB per file", cfg.UploadPartMaxMB, cfg.MaxFileSizeMB)))
+	case stderrors.Is(err, importservice.ErrUploadPartEmpty):
+		respondError(c, h.logger, errors.ErrEmptyFile(err.Error()))
+	default:
+		respondError(c, h.logger, err)
+	}
+}
+
+// parseUploadID reads the upload_id path parameter, answering 400 when it
+// isn't a UUID
+func parseUploadID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid upload_id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// StartUpload handles POST /v1/imports/uploads, opening a resumable upload
+// whose parts are sent to PutUploadPart
+func (h *ImportHandler) StartUpload(c *gin.Context) {
+	var req StartUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, h.logger, err)
+		return
+	}
+
+	tenantID := middleware.GetTenantID(c)
+	if err := h.quotaSvc.CheckJobCreation(c.Request.Context(), tenantID, models.JobTypeImport); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	session, err := h.importSvc.StartUpload(c.Request.Context(), tenantID, req.FileName)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, h.uploadSessionResponse(session, nil))
+}
+
+// GetUpload handles GET /v1/imports/uploads/:upload_id. A client resuming an
+// upload reads the parts received to know which to send again.
+func (h *ImportHandler) GetUpload(c *gin.Context) {
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+	session, parts, err := h.importSvc.GetUpload(c.Request.Context(), middleware.GetTenantID(c), id)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.uploadSessionResponse(session, parts))
+}
+
+// PutUploadPart handles PUT /v1/imports/uploads/:upload_id/parts/:part_number.
+// The body is the part's data; sending a part again replaces it. A
+// Content-MD5 or X-Content-SHA256 header is checked against the part.
+func (h *ImportHandler) PutUploadPart(c *gin.Context) {
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(c.Param("part_number"))
+	if err != nil || n < 1 || n > importservice.MaxUploadParts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("part_number must be between 1 and %d", importservice.MaxUploadParts)})
+		return
+	}
+
+	checksum, err := newUploadChecksum(c.Request.Header)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	part, err := h.importSvc.PutUploadPart(c.Request.Context(), middleware.GetTenantID(c), id, n, checksum.Reader(c.Request.Body), checksum.Verify)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, part)
+}
+
+// CompleteUpload handles POST /v1/imports/uploads/:upload_id/complete. It
+// assembles the parts into the import file and creates the import, taking
+// the options of POST /v1/imports as JSON except file_url. Completing an
+// upload again answers with the import it was completed into.
+func (h *ImportHandler) CompleteUpload(c *gin.Context) {
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+	var req CreateImportRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, h.logger, err)
+			return
+		}
+	}
+	if req.FileURL != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file_url does not apply to uploads"})
+		return
+	}
+	resource := models.ResourceType(req.Resource)
+	if resource != "" &&
+		resource != models.ResourceTypeUsers &&
+		resource != models.ResourceTypeArticles &&
+		resource != models.ResourceTypeComments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource type"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantID := middleware.GetTenantID(c)
+	session, parts, err := h.importSvc.GetUpload(ctx, tenantID, id)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+	if session.Status == models.UploadSessionCompleted {
+		c.JSON(http.StatusOK, h.uploadSessionResponse(session, parts))
+		return
+	}
+	if err := h.quotaSvc.CheckJobCreation(ctx, tenantID, models.JobTypeImport); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	session, upload, err := h.importSvc.OpenUpload(ctx, tenantID, id)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+	jobID := uuid.New()
+	filePath, err := h.importSvc.SaveUploadedFile(jobID, upload, session.FileName)
+	upload.Close()
+	if err != nil {
+		h.logger.Error().Err(err).Str("upload_id", id.String()).Msg("Failed to assemble upload")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+		return
+	}
+
+	params := req.jobParams()
+	params.FileName = session.FileName
+	imp := importRequest{
+		jobID:    jobID,
+		tenantID: tenantID,
+		resource: resource,
+		filePath: filePath,
+		params:   params,
+		opts:     worker.ImportOptions{Profile: req.Profile},
+		preview:  req.Preview,
+		sync:     req.Sync,
+	}
+	job, detection, ok := h.prepareImport(c, imp)
+	if !ok {
+		return
+	}
+
+	// Only one request completes the session; another racing it answers
+	// with the import this one creates
+	completed, err := h.importSvc.CompleteUpload(ctx, id, jobID)
+	if err != nil || !completed {
+		h.importSvc.RemoveUpload(filePath)
+		if err != nil {
+			h.logger.Error().Err(err).Str("upload_id", id.String()).Msg("Failed to complete upload")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to complete upload"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": importservice.ErrUploadSessionClosed.Error()})
+		return
+	}
+	h.runImport(c, job, imp, detection)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rs/zerolog"
+)
+
+func TestImportHandler_ResumableUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	uploads := t.TempDir()
+	cfg := config.ImportConfig{UploadPath: uploads, BatchSize: 100, MaxFileSizeMB: 1, UploadPartMaxMB: 1,
+		UploadSessionTTL: time.Hour, SyncMaxBytes: 1 << 20, SyncMaxRows: 100}
+	importSvc := importservice.NewService(memory.NewUserRepository(db), memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db), jobs, memory.NewStagingRepository(db), memory.NewProfileRepository(db),
+		db, nil, testMetrics, zerolog.Nop(), cfg)
+	importSvc.SetUploadSessions(memory.NewUploadSessionRepository(db))
+	pool := worker.NewPool(importSvc, nil, jobs, testMetrics, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	h := NewImportHandler(importSvc, jobs, memory.NewIdempotencyRepository(db),
+		quotaservice.NewService(nil, zerolog.Nop(), config.QuotaConfig{}), pool, testAdminToken, zerolog.Nop(), cfg)
+
+	router := gin.New()
+	router.POST("/v1/imports/uploads", h.StartUpload)
+	router.GET("/v1/imports/uploads/:upload_id", h.GetUpload)
+	router.PUT("/v1/imports/uploads/:upload_id/parts/:part_number", h.PutUploadPart)
+	router.POST("/v1/imports/uploads/:upload_id/complete", h.CompleteUpload)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	session := func(w *httptest.ResponseRecorder) UploadSessionResponse {
+		t.Helper()
+		var resp UploadSessionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal() error: %v, body %s", err, w.Body.String())
+		}
+		return resp
+	}
+
+	w := do(http.MethodPost, "/v1/imports/uploads", `{"file_name":"users.ndjson"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("start status = %d, body %s", w.Code, w.Body.String())
+	}
+	self := session(w).Links.Self
+
+	// Parts arrive out of order, and one is sent again after a failure
+	content := `{"email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"email":"bob@example.com","name":"Bob","role":"reader","active":"false"}
+`
+	lines := strings.SplitAfter(content, "\n")
+	if w := do(http.MethodPut, self+"/parts/2", lines[1]); w.Code != http.StatusOK {
+		t.Fatalf("part 2 status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, self+"/complete", `{"sync":true}`); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "part 1 is missing") {
+		t.Errorf("incomplete status = %d, body %s; want 409 naming part 1", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, self+"/parts/1", "garbage"); w.Code != http.StatusOK {
+		t.Fatalf("part 1 status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, self+"/parts/1", lines[0]); w.Code != http.StatusOK {
+		t.Fatalf("resent part 1 status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, self+"/parts/3", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("empty part status = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPut, self+"/parts/3", strings.Repeat("x", 1024*1024)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("part over the file limit status = %d, want 413", w.Code)
+	}
+	if w := do(http.MethodPut, self+"/parts/0", "x"); w.Code != http.StatusBadRequest {
+		t.Errorf("part 0 status = %d, want 400", w.Code)
+	}
+
+	resp := session(do(http.MethodGet, self, ""))
+	if len(resp.Parts) != 2 || resp.ReceivedBytes != int64(len(content)) {
+		t.Fatalf("session = %+v, want both parts of %d bytes", resp, len(usersNDJSON))
+	}
+
+	// Completing imports the assembled file and closes the session
+	w = do(http.MethodPost, self+"/complete", `{"sync":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("complete status = %d, body %s", w.Code, w.Body.String())
+	}
+	users, _ := memory.NewUserRepository(db).Count(context.Background(), nil)
+	if users != 2 {
+		t.Errorf("imported %d users, want 2", users)
+	}
+	resp = session(do(http.MethodGet, self, ""))
+	if resp.Status != "completed" || resp.JobID == "" {
+		t.Errorf("session = %+v, want it completed with its job", resp)
+	}
+	if w := do(http.MethodPost, self+"/complete", ""); w.Code != http.StatusOK || session(w).JobID != resp.JobID {
+		t.Errorf("completing again status = %d, body %s; want the same job", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, self+"/parts/3", "x"); w.Code != http.StatusConflict {
+		t.Errorf("part after completion status = %d, want 409", w.Code)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(uploads, "sessions")); len(entries) != 0 {
+		t.Errorf("sessions directory has %d entries, want the parts removed", len(entries))
+	}
+
+	if w := do(http.MethodGet, "/v1/imports/uploads/"+uuid.NewString(), ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rs/zerolog"
+)
+
+// VerifyHandler compares import files with the stored records
+type VerifyHandler struct {
+	importSvc *importservice.Service
+	logger    zerolog.Logger
+	config    atomic.Pointer[config.ImportConfig]
+}
+
+// NewVerifyHandler creates a new verify handler
+func NewVerifyHandler(importSvc *importservice.Service, logger zerolog.Logger, cfg config.ImportConfig) *VerifyHandler {
+	h := &VerifyHandler{
+		importSvc: importSvc,
+		logger:    logger,
+	}
+	h.config.Store(&cfg)
+	return h
+}
+
+// SetConfig replaces the import settings when they are reloaded
+func (h *VerifyHandler) SetConfig(cfg config.ImportConfig) {
+	h.config.Store(&cfg)
+}
+
+// MaxUploadBytes is the largest request body Verify accepts, the same as
+// for an import
+func (h *VerifyHandler) MaxUploadBytes() int64 {
+	return int64(h.config.Load().MaxFileSizeMB)*1024*1024 + multipartOverhead
+}
+
+// Verify handles POST /v1/verify. It takes a file in any import format and
+// reports which of its records are missing from or stored differently in
+// the database, and which stored records the file doesn't have.
+func (h *VerifyHandler) Verify(c *gin.Context) {
+	cfg := h.config.Load()
+
+	resource := models.ResourceType(c.PostForm("resource"))
+	if resource != models.ResourceTypeUsers &&
+		resource != models.ResourceTypeArticles &&
+		resource != models.ResourceTypeComments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource type"})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		if appErr := bodyError(err); appErr != nil {
+			respondError(c, h.logger, appErr)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > int64(cfg.MaxFileSizeMB)*1024*1024 {
+		respondError(c, h.logger, errors.ErrFileTooLarge(fmt.Sprintf("file too large, max %dMB", cfg.MaxFileSizeMB)))
+		return
+	}
+	if header.Size == 0 {
+		respondError(c, h.logger, errors.ErrEmptyFile("uploaded file is empty"))
+		return
+	}
+
+	filePath, err := h.importSvc.SaveUploadedFile(uuid.New(), file, header.Filename)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to save uploaded file")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+		return
+	}
+	defer func() {
+		if err := h.importSvc.RemoveUpload(filePath); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to remove verify upload")
+		}
+	}()
+
+	rows, err := h.importSvc.CountRows(filePath)
+	if err != nil {
+		respondError(c, h.logger, errors.ErrInvalidRequest("failed to read file: "+err.Error()))
+		return
+	}
+	if rows > cfg.VerifyMaxRows {
+		respondError(c, h.logger, errors.ErrRowLimitExceeded(fmt.Sprintf("file has %d data rows, max %d per verification", rows, cfg.VerifyMaxRows)))
+		return
+	}
+
+	saved, err := os.Open(filePath)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to open uploaded file")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer saved.Close()
+
+	report, err := h.importSvc.Verify(c.Request.Context(), saved, resource)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
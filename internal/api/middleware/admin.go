@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth returns a gin middleware that admits requests carrying token as
+// a bearer token
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether the request carries token as a bearer token. An
+// empty token admits no one.
+func IsAdmin(c *gin.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+	given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	stderrors "errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+)
+
+// ErrSlowClient is returned by reads of a request body that arrives slower
+// than the minimum read rate, or stalls for longer than the grace period
+var ErrSlowClient = stderrors.New("request body arrived too slowly")
+
+// BodyLimitConfig bounds request bodies
+type BodyLimitConfig struct {
+	// MaxBytes caps every request body; 0 is unlimited
+	MaxBytes int64
+	// RouteMaxBytes overrides MaxBytes for routes such as "POST /v1/imports".
+	// It is called per request, so reloaded limits apply at once.
+	RouteMaxBytes map[string]func() int64
+	// MinReadRate is the slowest a body may arrive, in bytes per second,
+	// once Grace has passed; 0 turns the check off
+	MinReadRate int64
+	// Grace is how long a body may take before MinReadRate applies, and
+	// how long a read may wait for the client to send anything
+	Grace time.Duration
+	// ReadTimeout is the server's own limit on reading a request, which
+	// read deadlines set here never extend past; 0 is none
+	ReadTimeout time.Duration
+}
+
+// BodyLimit returns a gin middleware that refuses bodies declared larger than
+// the route's limit with 413, caps the bytes read from bodies sent without a
+// length, and drops clients that send too slowly
+func BodyLimit(cfg BodyLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		limit := cfg.MaxBytes
+		if fn, ok := cfg.RouteMaxBytes[c.Request.Method+" "+c.FullPath()]; ok {
+			limit = fn()
+		}
+		if limit > 0 {
+			if c.Request.ContentLength > limit {
+				appErr := errors.ErrRequestTooLarge(limit)
+				c.Header("Connection", "close")
+				c.AbortWithStatusJSON(appErr.StatusCode, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
+		if cfg.MinReadRate > 0 && cfg.Grace > 0 {
+			start := time.Now()
+			r := &slowReader{
+				body:    c.Request.Body,
+				rc:      http.NewResponseController(c.Writer),
+				header:  c.Writer.Header(),
+				minRate: cfg.MinReadRate,
+				grace:   cfg.Grace,
+				start:   start,
+			}
+			if cfg.ReadTimeout > 0 {
+				r.deadline = start.Add(cfg.ReadTimeout)
+			}
+			c.Request.Body = r
+		}
+		c.Next()
+	}
+}
+
+// slowReader fails reads of a body arriving under minRate bytes per second
+type slowReader struct {
+	body     io.ReadCloser
+	rc       *http.ResponseController
+	header   http.Header
+	minRate  int64
+	grace    time.Duration
+	start    time.Time
+	deadline time.Time
+	read     int64
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	// A client that stops sending altogether is cut off after grace, which
+	// the rate check alone can't do while a read is blocked. The deadline is
+	// lifted again once the read returns, so it can't cancel the request
+	// while the handler works on what it read.
+	deadline := time.Now().Add(r.grace)
+	if !r.deadline.IsZero() && r.deadline.Before(deadline) {
+		deadline = r.deadline
+	}
+	r.rc.SetReadDeadline(deadline)
+	n, err := r.body.Read(p)
+	r.rc.SetReadDeadline(r.deadline)
+
+	r.read += int64(n)
+	if err != nil && stderrors.Is(err, os.ErrDeadlineExceeded) {
+		return n, r.drop()
+	}
+	if elapsed := time.Since(r.start); elapsed > r.grace &&
+		float64(r.read) < float64(r.minRate)*elapsed.Seconds() {
+		return n, r.drop()
+	}
+	return n, err
+}
+
+// drop gives up on the client: the connection is closed after the response
+// rather than waiting for the rest of the body
+func (r *slowReader) drop() error {
+	r.header.Set("Connection", "close")
+	r.rc.SetReadDeadline(time.Now())
+	return ErrSlowClient
+}
+
+func (r *slowReader) Close() error {
+	return r.body.Close()
+}
@@ -5,14 +5,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/repository"
 )
 
 // IdempotencyKey header name
 const IdempotencyKeyHeader = "Idempotency-Key"
 
 // Idempotency returns a gin middleware for handling idempotent requests
-func Idempotency(idempotencyRepo *postgres.IdempotencyRepository) gin.HandlerFunc {
+func Idempotency(idempotencyRepo repository.IdempotencyRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Only check POST requests
 		if c.Request.Method != http.MethodPost {
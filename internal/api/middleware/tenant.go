@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// Tenant header names
+const (
+	TenantIDHeader = "X-Tenant-ID"
+	APIKeyHeader   = "X-API-Key"
+)
+
+// TenantContextKey is the gin context key holding the resolved tenant ID
+const TenantContextKey = "tenant_id"
+
+// Tenant returns a gin middleware that resolves the calling tenant. An
+// X-API-Key listed in TENANT_API_KEYS names its tenant. With quotas on that
+// is the only way to pick one: other keys are refused with 401 and
+// X-Tenant-ID is ignored, so callers can't start over on a fresh quota by
+// changing a header. With quotas off an explicit X-Tenant-ID wins, and any
+// other key is hashed into a tenant so raw keys are never persisted.
+func Tenant(cfg config.QuotaConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(APIKeyHeader)
+		tenantID, known := cfg.TenantAPIKeys[apiKey]
+		switch {
+		case apiKey != "" && known:
+		case cfg.Enabled && apiKey != "":
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unknown API key"})
+			return
+		case cfg.Enabled:
+			tenantID = models.DefaultTenantID
+		default:
+			tenantID = c.GetHeader(TenantIDHeader)
+			if len(tenantID) > models.MaxTenantIDLength {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("%s must be at most %d characters", TenantIDHeader, models.MaxTenantIDLength),
+				})
+				return
+			}
+			if tenantID == "" && apiKey != "" {
+				sum := sha256.Sum256([]byte(apiKey))
+				tenantID = "key_" + hex.EncodeToString(sum[:8])
+			}
+			if tenantID == "" {
+				tenantID = models.DefaultTenantID
+			}
+		}
+
+		c.Set(TenantContextKey, tenantID)
+		c.Next()
+	}
+}
+
+// GetTenantID returns the tenant resolved by the Tenant middleware
+func GetTenantID(c *gin.Context) string {
+	if tenantID := c.GetString(TenantContextKey); tenantID != "" {
+		return tenantID
+	}
+	return models.DefaultTenantID
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := map[string]string{"secret": "acme"}
+
+	tests := []struct {
+		name       string
+		cfg        config.QuotaConfig
+		apiKey     string
+		tenantID   string
+		wantStatus int
+		wantTenant string
+	}{
+		{"configured key", config.QuotaConfig{Enabled: true, TenantAPIKeys: keys}, "secret", "", http.StatusOK, "acme"},
+		{"unknown key with quotas", config.QuotaConfig{Enabled: true, TenantAPIKeys: keys}, "invented", "", http.StatusUnauthorized, ""},
+		{"header ignored with quotas", config.QuotaConfig{Enabled: true, TenantAPIKeys: keys}, "", "other", http.StatusOK, models.DefaultTenantID},
+		{"header over configured key", config.QuotaConfig{Enabled: true, TenantAPIKeys: keys}, "secret", "other", http.StatusOK, "acme"},
+		{"header without quotas", config.QuotaConfig{}, "", "other", http.StatusOK, "other"},
+		{"header too long", config.QuotaConfig{}, "", strings.Repeat("x", models.MaxTenantIDLength+1), http.StatusBadRequest, ""},
+		{"unknown key hashed without quotas", config.QuotaConfig{}, "invented", "", http.StatusOK, "key_"},
+		{"no headers", config.QuotaConfig{}, "", "", http.StatusOK, models.DefaultTenantID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tenant string
+			router := gin.New()
+			router.Use(Tenant(tt.cfg))
+			router.GET("/", func(c *gin.Context) { tenant = GetTenantID(c) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.tenantID != "" {
+				req.Header.Set(TenantIDHeader, tt.tenantID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.HasPrefix(tenant, tt.wantTenant) || (tt.wantTenant == "") != (tenant == "") {
+				t.Errorf("tenant = %q, want %q", tenant, tt.wantTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutConfig sets how long each route has to write its response
+type TimeoutConfig struct {
+	// Default applies to routes not in Routes; 0 leaves the server's own
+	// write timeout in place
+	Default time.Duration
+	// Routes overrides Default for routes such as "GET /v1/exports". Each
+	// func is called per request, so one that reads a reloadable setting
+	// follows reloads; 0 means no limit, for responses that stream for as
+	// long as they need.
+	Routes map[string]func() time.Duration
+}
+
+// Timeouts returns a gin middleware that sets the write deadline of each
+// response by its route, so that streaming endpoints can run long without
+// every other request being allowed to
+func Timeouts(cfg TimeoutConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fn, ok := cfg.Routes[c.Request.Method+" "+c.FullPath()]
+		switch {
+		case ok:
+			setWriteTimeout(c, fn())
+		case cfg.Default > 0:
+			setWriteTimeout(c, cfg.Default)
+		}
+		c.Next()
+	}
+}
+
+// setWriteTimeout lets the response be written for d from now, or for as
+// long as it takes when d is 0
+func setWriteTimeout(c *gin.Context, d time.Duration) {
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	http.NewResponseController(c.Writer).SetWriteDeadline(deadline)
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeouts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const serverTimeout = 50 * time.Millisecond
+	statusMaxWait := 200 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"default route cut off", "/v1/jobs", true},
+		{"streaming route outlives server timeout", "/v1/exports", false},
+		{"status route waits for its job", "/v1/imports/job-1", false},
+	}
+
+	router := gin.New()
+	router.Use(Timeouts(TimeoutConfig{
+		Default: serverTimeout,
+		Routes: map[string]func() time.Duration{
+			"GET /v1/exports": func() time.Duration { return 0 },
+			"GET /v1/imports/:job_id": func() time.Duration {
+				return serverTimeout + statusMaxWait
+			},
+		},
+	}))
+	// Each handler answers after the server's write timeout has passed
+	slow := func(c *gin.Context) {
+		time.Sleep(3 * serverTimeout)
+		c.String(http.StatusOK, "done")
+	}
+	router.GET("/v1/jobs", slow)
+	router.GET("/v1/exports", slow)
+	router.GET("/v1/imports/:job_id", slow)
+
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.WriteTimeout = serverTimeout
+	srv.Start()
+	defer srv.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := srv.Client().Get(srv.URL + tt.path)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GET %s = %q, want the response cut off", tt.path, body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET %s error: %v", tt.path, err)
+			}
+			if resp.StatusCode != http.StatusOK || string(body) != "done" {
+				t.Errorf("GET %s = %d %q, want 200 \"done\"", tt.path, resp.StatusCode, body)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -8,10 +10,17 @@ import (
 	"github.com/rohit/bulk-import-export/internal/api/middleware"
 	"github.com/rohit/bulk-import-export/internal/config"
 	"github.com/rohit/bulk-import-export/internal/metrics"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	deliveryservice "github.com/rohit/bulk-import-export/internal/service/delivery"
 	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
 	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	reportservice "github.com/rohit/bulk-import-export/internal/service/report"
+	seedservice "github.com/rohit/bulk-import-export/internal/service/seed"
+	signingservice "github.com/rohit/bulk-import-export/internal/service/signing"
+	"github.com/rohit/bulk-import-export/internal/ui"
 	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rohit/bulk-import-export/pkg/logger"
 	"github.com/rs/zerolog"
 )
 
@@ -29,11 +38,19 @@ func NewRouter(
 	db *sqlx.DB,
 	importSvc *importservice.Service,
 	exportSvc *exportservice.Service,
-	jobRepo *postgres.JobRepository,
-	idempotencyRepo *postgres.IdempotencyRepository,
+	quotaSvc *quotaservice.Service,
+	reportSvc *reportservice.Service,
+	seedSvc *seedservice.Service,
+	signingSvc *signingservice.Service,
+	deliverySvc *deliveryservice.Service,
+	jobRepo repository.JobRepository,
+	idempotencyRepo repository.IdempotencyRepository,
 	workerPool *worker.Pool,
+	reloader *config.Reloader,
 	metricsCollector *metrics.Collector,
-	logger zerolog.Logger,
+	logLevels *logger.Levels,
+	logCapture *logger.Capture,
+	log zerolog.Logger,
 	cfg *config.Config,
 ) *Router {
 	// Set gin mode
@@ -44,9 +61,10 @@ func NewRouter(
 	engine := gin.New()
 
 	// Global middleware
-	engine.Use(middleware.Recovery(logger))
-	engine.Use(middleware.Logger(logger))
+	engine.Use(middleware.Recovery(log))
+	engine.Use(middleware.Logger(log))
 	engine.Use(middleware.CORS())
+	engine.Use(middleware.Tenant(cfg.Quota))
 
 	if metricsCollector != nil {
 		engine.Use(middleware.Metrics(metricsCollector))
@@ -58,38 +76,138 @@ func NewRouter(
 		importSvc,
 		jobRepo,
 		idempotencyRepo,
+		quotaSvc,
 		workerPool,
-		logger,
+		cfg.App.AdminToken,
+		log,
 		cfg.Import,
 	)
 	exportHandler := handlers.NewExportHandler(
 		exportSvc,
 		jobRepo,
+		quotaSvc,
 		workerPool,
-		logger,
+		metricsCollector,
+		log,
 		cfg.Export,
 	)
+	if signingSvc != nil {
+		exportHandler.SetSigner(signingSvc)
+	}
+	quotaHandler := handlers.NewQuotaHandler(quotaSvc, log)
+	reportHandler := handlers.NewReportHandler(reportSvc, cfg.App.AdminToken, log)
+	jobHandler := handlers.NewJobHandler(jobRepo, logCapture, cfg.App.AdminToken, log)
+	verifyHandler := handlers.NewVerifyHandler(importSvc, log, cfg.Import)
+
+	// Bound request bodies; imports take files up to MAX_FILE_SIZE_MB and
+	// exports take cohort files of up to EXPORT_COHORT_FILE_MAX users
+	engine.Use(middleware.BodyLimit(middleware.BodyLimitConfig{
+		MaxBytes: cfg.App.MaxBodyBytes,
+		RouteMaxBytes: map[string]func() int64{
+			"POST /v1/imports": importHandler.MaxUploadBytes,
+			"PUT /v1/imports/uploads/:upload_id/parts/:part_number": importHandler.MaxPartBytes,
+			"POST /v1/exports": exportHandler.MaxRequestBytes,
+			"POST /v1/verify":  verifyHandler.MaxUploadBytes,
+		},
+		MinReadRate: cfg.App.MinReadRate,
+		Grace:       cfg.App.SlowReadGrace,
+		ReadTimeout: time.Duration(cfg.App.ReadTimeout) * time.Second,
+	}))
+
+	engine.Use(middleware.Timeouts(writeTimeouts(cfg.App, importHandler.StatusMaxWait)))
+
+	// Hand reloaded settings to the handlers that read them per request
+	if reloader != nil {
+		reloader.OnChange(func(cfg *config.Config) {
+			importHandler.SetConfig(cfg.Import)
+			verifyHandler.SetConfig(cfg.Import)
+			exportHandler.SetConfig(cfg.Export)
+		})
+	}
 
 	// Health routes (no version prefix)
 	engine.GET("/health", healthHandler.Health)
 	engine.GET("/ready", healthHandler.Ready)
 	engine.GET("/live", healthHandler.Live)
 
-	// Metrics endpoint
-	if cfg.Prometheus.Enabled {
+	// Job monitoring UI
+	if cfg.App.UIEnabled {
+		engine.StaticFS("/ui", ui.FS())
+	}
+
+	// Metrics endpoint, unless it has a listener of its own
+	if cfg.Prometheus.Enabled && cfg.Prometheus.Port == cfg.App.Port {
 		engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	}
 
+	// Admin routes, enabled by ADMIN_TOKEN
+	if cfg.App.AdminToken != "" {
+		adminHandler := handlers.NewAdminHandler(logLevels, log)
+		admin := engine.Group("/admin")
+		admin.Use(middleware.AdminAuth(cfg.App.AdminToken))
+		{
+			admin.GET("/log-levels", adminHandler.GetLogLevels)
+			admin.PUT("/log-levels", adminHandler.SetLogLevel)
+		}
+	}
+
 	// API v1 routes
 	v1 := engine.Group("/v1")
 	{
+		// Admin routes, enabled by ADMIN_TOKEN
+		if cfg.App.AdminToken != "" {
+			deadLetterHandler := handlers.NewDeadLetterHandler(jobRepo, workerPool, log)
+			pausedJobHandler := handlers.NewPausedJobHandler(jobRepo, workerPool, log)
+			overviewHandler := handlers.NewOverviewHandler(jobRepo, workerPool, importSvc, db, cfg, log)
+			seedHandler := handlers.NewSeedHandler(seedSvc, importSvc, jobRepo, workerPool, log, cfg.Import)
+			if reloader != nil {
+				reloader.OnChange(func(cfg *config.Config) {
+					seedHandler.SetConfig(cfg.Import)
+				})
+			}
+			v1Admin := v1.Group("/admin")
+			v1Admin.Use(middleware.AdminAuth(cfg.App.AdminToken))
+			{
+				v1Admin.GET("/dead-letters", deadLetterHandler.ListDeadLetters)
+				v1Admin.POST("/dead-letters/:job_id/requeue", deadLetterHandler.RequeueDeadLetter)
+				v1Admin.POST("/jobs/:job_id/resume", pausedJobHandler.ResumeJob)
+				v1Admin.GET("/overview", overviewHandler.GetOverview)
+				v1Admin.POST("/seed", seedHandler.Seed)
+				if reloader != nil {
+					configHandler := handlers.NewConfigHandler(reloader, log)
+					v1Admin.GET("/config", configHandler.GetConfig)
+					v1Admin.PATCH("/config", configHandler.UpdateConfig)
+				}
+				if signingSvc != nil {
+					signingKeyHandler := handlers.NewSigningKeyHandler(signingSvc, log)
+					v1Admin.GET("/signing-keys", signingKeyHandler.ListSigningKeys)
+					v1Admin.POST("/signing-keys", signingKeyHandler.RotateSigningKey)
+					v1Admin.GET("/signing-keys/:key_id", signingKeyHandler.GetSigningKey)
+					v1Admin.DELETE("/signing-keys/:key_id", signingKeyHandler.RetireSigningKey)
+				}
+				if deliverySvc != nil {
+					deliveryHandler := handlers.NewDeliveryHandler(deliverySvc, log)
+					v1Admin.GET("/deliveries", deliveryHandler.ListDeliveries)
+					v1Admin.POST("/deliveries/:delivery_id/requeue", deliveryHandler.RequeueDelivery)
+				}
+			}
+		}
+
 		// Import routes
 		imports := v1.Group("/imports")
 		imports.Use(middleware.Idempotency(idempotencyRepo))
 		{
 			imports.POST("", importHandler.CreateImport)
 			imports.GET("/:job_id", importHandler.GetImportStatus)
+			imports.DELETE("/:job_id", importHandler.CancelImport)
 			imports.GET("/:job_id/errors", importHandler.GetImportErrors)
+			imports.GET("/:job_id/warnings", importHandler.GetImportWarnings)
+			imports.GET("/:job_id/profile", importHandler.GetImportProfile)
+			imports.GET("/:job_id/batches", importHandler.GetImportBatches)
+			imports.POST("/uploads", importHandler.StartUpload)
+			imports.GET("/uploads/:upload_id", importHandler.GetUpload)
+			imports.PUT("/uploads/:upload_id/parts/:part_number", importHandler.PutUploadPart)
+			imports.POST("/uploads/:upload_id/complete", importHandler.CompleteUpload)
 		}
 
 		// Export routes
@@ -97,14 +215,35 @@ func NewRouter(
 		{
 			exports.GET("", exportHandler.StreamExport)
 			exports.POST("", exportHandler.CreateAsyncExport)
+			exports.POST("/diff", exportHandler.CreateDiffExport)
+			exports.GET("/schemas/:resource", exportHandler.GetExportSchema)
 			exports.GET("/:job_id", exportHandler.GetExportStatus)
+			exports.DELETE("/:job_id", exportHandler.CancelExport)
 			exports.GET("/:job_id/download", exportHandler.DownloadExport)
+			exports.GET("/:job_id/manifest", exportHandler.GetExportManifest)
+			exports.POST("/:job_id/rerun", exportHandler.RerunExport)
 		}
+
+		// Routes shared by all job types
+		v1.GET("/jobs", jobHandler.ListJobs)
+		v1.GET("/jobs/:job_id/logs", jobHandler.GetJobLogs)
+		v1.GET("/jobs/:job_id/events", jobHandler.StreamJobEvents)
+		v1.GET("/jobs/:job_id/timeline", jobHandler.GetJobTimeline)
+		v1.PATCH("/jobs/:job_id", jobHandler.UpdateJob)
+
+		// Quota routes
+		v1.GET("/quota", quotaHandler.GetQuota)
+
+		// Report routes
+		v1.GET("/reports/usage", reportHandler.GetUsage)
+
+		// Verify routes
+		v1.POST("/verify", verifyHandler.Verify)
 	}
 
 	return &Router{
 		engine:           engine,
-		logger:           logger,
+		logger:           log,
 		db:               db,
 		cfg:              cfg,
 		metricsCollector: metricsCollector,
@@ -121,3 +260,35 @@ func (r *Router) Run(addr string) error {
 	r.logger.Info().Str("addr", addr).Msg("Starting HTTP server")
 	return r.engine.Run(addr)
 }
+
+// writeTimeouts gives streaming responses as long as they need and
+// everything else APP_WRITE_TIMEOUT. The APP_* timeouts are read once at
+// startup; statusMaxWait is read per request, so a reloaded
+// IMPORT_STATUS_MAX_WAIT_SECONDS applies to the next status request.
+func writeTimeouts(app config.AppConfig, statusMaxWait func() time.Duration) middleware.TimeoutConfig {
+	writeTimeout := time.Duration(app.WriteTimeout) * time.Second
+	streamTimeout := func() time.Duration { return time.Duration(app.StreamWriteTimeout) * time.Second }
+	uploadTimeout := func() time.Duration { return time.Duration(app.UploadWriteTimeout) * time.Second }
+	return middleware.TimeoutConfig{
+		Default: writeTimeout,
+		Routes: map[string]func() time.Duration{
+			"GET /v1/exports":                  streamTimeout,
+			"GET /v1/exports/:job_id/download": streamTimeout,
+			"GET /v1/jobs/:job_id/events":      streamTimeout,
+			"POST /v1/admin/seed":              streamTimeout,
+			// Verifying reads the whole table of the resource
+			"POST /v1/verify":  streamTimeout,
+			"POST /v1/imports": uploadTimeout,
+			"PUT /v1/imports/uploads/:upload_id/parts/:part_number": uploadTimeout,
+			// Completing an upload assembles its parts into one file
+			"POST /v1/imports/uploads/:upload_id/complete": uploadTimeout,
+			// A status request may wait for its job before answering
+			"GET /v1/imports/:job_id": func() time.Duration {
+				if writeTimeout == 0 {
+					return 0
+				}
+				return writeTimeout + statusMaxWait()
+			},
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+)
+
+func TestWriteTimeouts(t *testing.T) {
+	statusMaxWait := 10 * time.Second
+	cfg := writeTimeouts(config.AppConfig{WriteTimeout: 30, StreamWriteTimeout: 0, UploadWriteTimeout: 600},
+		func() time.Duration { return statusMaxWait })
+
+	if cfg.Default != 30*time.Second {
+		t.Errorf("Default = %v, want 30s", cfg.Default)
+	}
+	for route, want := range map[string]time.Duration{
+		"GET /v1/exports":         0,
+		"POST /v1/imports":        600 * time.Second,
+		"GET /v1/imports/:job_id": 40 * time.Second,
+	} {
+		if got := cfg.Routes[route](); got != want {
+			t.Errorf("%s = %v, want %v", route, got, want)
+		}
+	}
+
+	// A reloaded status wait applies to the next request
+	statusMaxWait = time.Minute
+	if got := cfg.Routes["GET /v1/imports/:job_id"](); got != 90*time.Second {
+		t.Errorf("GET /v1/imports/:job_id after reload = %v, want 1m30s", got)
+	}
+
+	// Without a write timeout the status route has no limit either
+	cfg = writeTimeouts(config.AppConfig{}, func() time.Duration { return statusMaxWait })
+	if got := cfg.Routes["GET /v1/imports/:job_id"](); got != 0 {
+		t.Errorf("GET /v1/imports/:job_id without write timeout = %v, want 0", got)
+	}
+}
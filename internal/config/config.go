@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,16 +18,55 @@ type Config struct {
 	Worker     WorkerConfig
 	Storage    StorageConfig
 	Prometheus PrometheusConfig
+	Quota      QuotaConfig
+	Search     SearchConfig
+	Events     EventsConfig
+	Log        LogConfig
+	Report     ReportConfig
+	Signing    SigningConfig
+	Delivery   DeliveryConfig
 }
 
 // AppConfig holds application settings
 type AppConfig struct {
-	Env          string
-	Port         int
-	Name         string
-	ReadTimeout  int
+	Env         string
+	Port        int
+	Name        string
+	ReadTimeout int
+	// WriteTimeout is how long, in seconds, most requests have to write
+	// their response; the routes below have their own
 	WriteTimeout int
-	IdleTimeout  int
+	// StreamWriteTimeout bounds streaming exports, export downloads, job
+	// event streams and seeding, in seconds; 0 is unlimited
+	StreamWriteTimeout int
+	// UploadWriteTimeout bounds import creation, which includes the upload
+	// and, for sync imports, the import itself, in seconds
+	UploadWriteTimeout int
+	IdleTimeout        int
+	// ReadHeaderTimeout is how long a client has to send its request
+	// headers, in seconds
+	ReadHeaderTimeout int
+	// MaxBodyBytes caps request bodies other than import and verify
+	// uploads, which are capped by MaxFileSizeMB; 0 is unlimited
+	MaxBodyBytes int64
+	// MinReadRate is the slowest, in bytes per second, a request body may
+	// arrive once SlowReadGrace has passed; 0 turns the check off
+	MinReadRate int64
+	// SlowReadGrace is how long a body may take before MinReadRate applies,
+	// and how long the server waits for a client that sends nothing
+	SlowReadGrace time.Duration
+	// StatusCacheTTL is how long a job read for a status request is reused
+	// by later ones; 0 turns the cache off
+	StatusCacheTTL time.Duration
+	// AdminToken guards the /admin endpoints; they are off when it is empty
+	AdminToken string
+	// InstanceID names this replica on the jobs it runs and in its logs and
+	// metrics; it defaults to the hostname
+	InstanceID string
+	// Version is the build running on this instance
+	Version string
+	// UIEnabled serves the job monitoring UI at /ui
+	UIEnabled bool
 }
 
 // DatabaseConfig holds database settings
@@ -38,6 +79,9 @@ type DatabaseConfig struct {
 	SSLMode      string
 	MaxOpenConns int
 	MaxIdleConns int
+	// MaxStatementBytes bounds the text bound into one multi-row INSERT;
+	// larger batches are split across statements
+	MaxStatementBytes int
 }
 
 // ImportConfig holds import settings
@@ -46,13 +90,161 @@ type ImportConfig struct {
 	WorkerCount   int
 	MaxFileSizeMB int
 	UploadPath    string
+	// UploadPartition groups the per-job upload directories: date (by day),
+	// job (by job ID prefix) or none
+	UploadPartition string
+	// Encoding of uploaded files: auto, utf-8, utf-16le, utf-16be or latin1
+	Encoding string
+	// MinRows is the fewest data rows an import file may have; 0 allows empty files
+	MinRows int
+	// MaxRows is the most data rows one import may have; 0 is unlimited
+	MaxRows int
+	// RowLimitMode is what happens to a longer file: reject refuses it up
+	// front, truncate imports the first MaxRows rows and warns
+	RowLimitMode string
+	// AdminRolePolicy governs user rows with the admin role in imports not
+	// allowed to create admins: allow imports them, flag imports them with
+	// a warning and block rejects them
+	AdminRolePolicy string
+	// EmailAllowDomains, when set, are the only email domains (and their
+	// subdomains) user imports accept
+	EmailAllowDomains []string
+	// EmailDenyDomains are email domains user imports reject, taking
+	// precedence over EmailAllowDomains
+	EmailDenyDomains []string
+	// EmailDenyDisposable also rejects well-known disposable email providers
+	EmailDenyDisposable bool
+	// DedupExpectedRows sizes the in-memory filter that estimates duplicates
+	// while a file is staged
+	DedupExpectedRows int
+	// MaxDuplicatePercent fails an import once more than this share of its
+	// rows look like duplicates; 0 disables the check
+	MaxDuplicatePercent int
+	// DuplicateCheckMinRows is how many rows are staged before
+	// MaxDuplicatePercent applies
+	DuplicateCheckMinRows int
+	// FastPathMaxRows is the largest file, in rows, that is deduplicated and
+	// inserted from memory without the staging tables; 0 always stages
+	FastPathMaxRows int
+	// SyncMaxRows and SyncMaxBytes bound the files a sync=true import will
+	// process inside the request
+	SyncMaxRows  int
+	SyncMaxBytes int64
+	// MaxErrorsPerCode is the most errors of one code stored for a job; the
+	// rest are only counted. 0 stores every error.
+	MaxErrorsPerCode int
+	// VerifyMaxRows is the most rows a file checked by POST /v1/verify may
+	// have
+	VerifyMaxRows int
+	// SeedMaxRows is the most rows one POST /v1/admin/seed may generate
+	SeedMaxRows int
+	// StatusMaxWait caps the wait parameter of the import status endpoint;
+	// 0 turns long polling off
+	StatusMaxWait time.Duration
+	// MaxLineSize is the longest NDJSON line read, in bytes; longer lines
+	// are rejected with LINE_TOO_LONG
+	MaxLineSize int
+	// ArticleMaxBodyBytes caps article bodies; 0 means unlimited
+	ArticleMaxBodyBytes int
+	// ArticleBodyOverflow is what happens to a longer body: reject fails the
+	// row, truncate cuts the body to the limit and warns
+	ArticleBodyOverflow string
+	// DetectLanguage tags every article and comment import with the
+	// language of its bodies, not only jobs that ask for it
+	DetectLanguage bool
+	// ErrorDocsURL is the page documenting the validation rules; import
+	// errors link to the rule's anchor on it. "" leaves the links out.
+	ErrorDocsURL string
+	// Ledger records the natural key and content hash of every record
+	// imports write, and skips rows whose content is already recorded for a
+	// stored record
+	Ledger bool
+	// BreakerThreshold is how many batch inserts in a row, across the
+	// imports of an instance, may fail before the import whose batch failed
+	// is paused instead of failed. A failed batch is retried until then. 0
+	// turns the breaker off, so the first failed batch fails the import.
+	BreakerThreshold int
+	// BreakerRetryDelay is the wait before retrying a failed batch insert
+	BreakerRetryDelay time.Duration
+	// AlertWebhookURL is POSTed an alert when the breaker pauses an import;
+	// "" sends none
+	AlertWebhookURL string
+	// ScreenMode is what happens to an article or comment body containing a
+	// banned term or personal data: off skips screening, reject fails the
+	// row, redact replaces what was found and warns, flag imports the row as
+	// it is and warns
+	ScreenMode string
+	// ScreenBannedTerms are the words and phrases screening looks for, in
+	// any case
+	ScreenBannedTerms []string
+	// ScreenPII also screens for credit card and social security numbers
+	ScreenPII bool
+	// UploadPartMaxMB caps each part of a resumable upload; the assembled
+	// file is still capped by MaxFileSizeMB
+	UploadPartMaxMB int
+	// UploadSessionTTL is how long a resumable upload may stay open before
+	// it expires and its parts are removed
+	UploadSessionTTL time.Duration
 }
 
 // ExportConfig holds export settings
 type ExportConfig struct {
-	BatchSize   int
-	WorkerCount int
-	OutputPath  string
+	BatchSize            int
+	WorkerCount          int
+	OutputPath           string
+	MaxConcurrentStreams int    // 0 means unlimited
+	StreamOverflowMode   string // reject, async
+	ConsistentSnapshot   bool   // run exports in a REPEATABLE READ transaction
+	// StreamKeepalive is how long a streaming export may go without writing
+	// before a keepalive is sent; 0 disables keepalives
+	StreamKeepalive time.Duration
+	// StreamBufferSize is the write buffer for streaming exports, in bytes
+	StreamBufferSize int
+	// StreamSpillAfter is how long a write to a streaming export's client
+	// may take before the rest of the export is spilled to a temporary file
+	// and served from disk; 0 never spills. StreamSpillMaxBytes bounds the
+	// spill file, after which the export waits for the client again.
+	StreamSpillAfter    time.Duration
+	StreamSpillMaxBytes int64
+	// Compression is the codec async exports are written with unless the
+	// request picks one: none, gzip or zstd
+	Compression string
+	// CompressionLevel is the codec's compression level; 0 is its default
+	CompressionLevel int
+	// StreamCompression compresses streaming exports for clients whose
+	// Accept-Encoding allows it
+	StreamCompression bool
+	// AvroCodec compresses the blocks of Avro exports: null or deflate
+	AvroCodec string
+	// SchemaRegistryURL, when set, is the Confluent-compatible schema
+	// registry async Avro exports register their schema with
+	SchemaRegistryURL      string
+	SchemaRegistrySubject  string // subject prefix, before "<resource>-value"
+	SchemaRegistryUsername string
+	SchemaRegistryPassword string
+	SchemaRegistryTimeout  time.Duration
+	// CohortInlineMax is the most user IDs or emails an export request may
+	// list in its filters, and the most kept with the job itself; larger
+	// uploaded cohorts go to storage
+	CohortInlineMax int
+	// CohortFileMax is the most IDs or emails an uploaded cohort file may list
+	CohortFileMax int
+	// InlineMaxRows is the most records an async export may match to be run
+	// within the request instead of queued; 0 queues every export
+	InlineMaxRows int
+	// FileExpiry is how long an export file is kept after its job completes;
+	// expired files are removed every ExpiryInterval, releasing their
+	// tenant's storage quota. 0 keeps files.
+	FileExpiry     time.Duration
+	ExpiryInterval time.Duration
+
+	// VerifyCount counts the records of an async export's consistent
+	// snapshot again once it is written, failing the export when they
+	// differ from the records it wrote
+	VerifyCount bool
+	// EnforceOrder fails an async export whose records don't follow the
+	// export ordering key: creation time, then ID
+	EnforceOrder bool
 }
 
 // WorkerConfig holds worker pool settings
@@ -60,71 +252,391 @@ type WorkerConfig struct {
 	ImportWorkers int
 	ExportWorkers int
 	QueueSize     int
+	// RecoverPanics keeps a worker alive when a job panics, failing or
+	// retrying the job instead of crashing the process
+	RecoverPanics bool
+	// MaxJobPanics is how many times a job may panic before it is
+	// quarantined rather than retried
+	MaxJobPanics int
+	// MaxAttempts is how many times a failed import or export job runs
+	// before it is moved to dead_letter; 1 turns retries off
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry, doubled for each
+	// later one up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// PriorityAging is how long a queued job waits before it is raised one
+	// priority level, so low priority jobs aren't starved; 0 turns it off
+	PriorityAging time.Duration
+	// HeartbeatInterval is how often a processing job's heartbeat is stamped
+	// and stale jobs are looked for; 0 turns both off
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout is how old a processing job's heartbeat may get before
+	// the job is treated as orphaned by a worker that died
+	HeartbeatTimeout time.Duration
 }
 
 // StorageConfig holds file storage settings
 type StorageConfig struct {
-	Type       string // local, s3
-	LocalPath  string
-	S3Endpoint string
-	S3Region   string
-	S3Bucket   string
+	Type         string // local, s3, azure, gcs
+	LocalPath    string
+	SignedURLTTL time.Duration
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+
+	AzureAccount   string
+	AzureKey       string // base64 account key
+	AzureContainer string
+	AzureEndpoint  string // empty means https://<account>.blob.core.windows.net
+
+	GCSEndpoint string
+	GCSBucket   string
+	GCSAccessID string // HMAC key for the XML API
+	GCSSecret   string
 }
 
 // PrometheusConfig holds Prometheus settings
 type PrometheusConfig struct {
 	Enabled bool
-	Port    int
+	// Port is where /metrics is served; the API port serves it alongside
+	// the API instead of on a listener of its own
+	Port int
+}
+
+// QuotaConfig holds per-tenant quota settings (0 means unlimited)
+type QuotaConfig struct {
+	Enabled            bool
+	JobsPerDay         int
+	RowsPerMonth       int64
+	ExportStorageBytes int64
+	// TenantAPIKeys maps the API keys callers send as X-API-Key to their
+	// tenant. With quotas on, tenants come only from these keys.
+	TenantAPIKeys map[string]string
+}
+
+// SearchConfig holds settings for syncing imported articles into an
+// Elasticsearch or OpenSearch index
+type SearchConfig struct {
+	Enabled     bool
+	Endpoint    string
+	Index       string
+	MappingFile string // JSON index body used when the index is created; empty means the built-in mapping
+	Username    string
+	Password    string
+	BatchSize   int
+	Timeout     time.Duration
+}
+
+// EventsConfig holds settings for publishing cache invalidation events when
+// imports write records
+type EventsConfig struct {
+	Driver         string // empty (disabled), redis or nats
+	URL            string // redis://[:password@]host:port, rediss://... or nats://[user:pass@]host:port
+	SubjectPrefix  string // events for a resource go to <prefix>.<resource>
+	ResourceLevel  bool
+	EntityLevel    bool
+	MaxIDsPerEvent int
+	Timeout        time.Duration
+}
+
+// LogConfig holds log output, level and sampling settings
+type LogConfig struct {
+	Outputs       []string // console, json, file, syslog
+	FilePath      string
+	SyslogNetwork string // empty for the local syslog daemon, or udp/tcp
+	SyslogAddr    string
+	SyslogTag     string
+	Level         string
+	// ComponentLevels overrides Level per component, from
+	// LOG_COMPONENT_LEVELS=import=debug,worker=warn
+	ComponentLevels map[string]string
+	// Hot-path statements log the first SampleBurst events per SamplePeriod,
+	// then one in SampleEvery; SampleEvery of 0 or 1 logs them all
+	SampleBurst  int
+	SamplePeriod time.Duration
+	SampleEvery  int
+	// JobLines is how many log lines are kept per job for
+	// GET /v1/jobs/:job_id/logs; 0 turns job log capture off
+	JobLines int
+}
+
+// ReportConfig holds settings for the nightly usage rollup behind
+// GET /v1/reports/usage
+type ReportConfig struct {
+	RollupEnabled  bool
+	RollupInterval time.Duration
+	// RollupDelay is how long after a day ends it is rolled up, so jobs
+	// created that day have finished
+	RollupDelay time.Duration
+}
+
+// SigningConfig holds settings for signing export manifests and download
+// URLs with the keys managed under /v1/admin/signing-keys
+type SigningConfig struct {
+	Enabled bool
+	// URLTTL is how long a signed download URL stays valid
+	URLTTL time.Duration
+	// RequireSignedDownloads refuses export downloads without a valid
+	// signature
+	RequireSignedDownloads bool
+	// KeyRefresh is how often the keys are reloaded, so keys rotated on
+	// another instance are picked up
+	KeyRefresh time.Duration
 }
 
-// Load loads configuration from environment variables
+// DeliveryConfig holds settings for the queue that sends notifications,
+// such as alert webhooks, and retries the ones that fail
+type DeliveryConfig struct {
+	// MaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered
+	MaxAttempts int
+	// BackoffBase is the wait after the first failed attempt; it doubles
+	// with each further failure up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// PollInterval is how often the queue is checked for due deliveries
+	PollInterval time.Duration
+	// BatchSize is how many due deliveries are claimed at a time
+	BatchSize int
+	// Timeout bounds each attempt
+	Timeout time.Duration
+}
+
+// Load loads configuration from environment variables, overridden by the
+// file CONFIG_FILE names when it is set. Settings that can't be parsed or
+// are out of range are returned together as a *ValidationError.
 func Load() (*Config, error) {
+	// Values in CONFIG_FILE take precedence over the environment
+	if err := readConfigFile(); err != nil {
+		return nil, err
+	}
+
+	// Unparsable values and out of range settings are collected so all of
+	// them are reported at once
+	l := &loader{}
 	cfg := &Config{
 		App: AppConfig{
 			Env:          getEnv("APP_ENV", "development"),
-			Port:         getEnvAsInt("APP_PORT", 8080),
+			Port:         l.getEnvAsInt("APP_PORT", 8080),
 			Name:         getEnv("APP_NAME", "bulk-import-export"),
-			ReadTimeout:  getEnvAsInt("APP_READ_TIMEOUT", 30),
-			WriteTimeout: getEnvAsInt("APP_WRITE_TIMEOUT", 300), // Long timeout for exports
-			IdleTimeout:  getEnvAsInt("APP_IDLE_TIMEOUT", 120),
+			ReadTimeout:  l.getEnvAsInt("APP_READ_TIMEOUT", 30),
+			WriteTimeout: l.getEnvAsInt("APP_WRITE_TIMEOUT", 30),
+			IdleTimeout:  l.getEnvAsInt("APP_IDLE_TIMEOUT", 120),
+
+			StreamWriteTimeout: l.getEnvAsInt("APP_STREAM_WRITE_TIMEOUT", 0),
+			UploadWriteTimeout: l.getEnvAsInt("APP_UPLOAD_WRITE_TIMEOUT", 300),
+			ReadHeaderTimeout:  l.getEnvAsInt("APP_READ_HEADER_TIMEOUT", 10),
+			MaxBodyBytes:       l.getEnvAsInt64("APP_MAX_BODY_KB", 1024) * 1024,
+			MinReadRate:        l.getEnvAsInt64("APP_MIN_READ_BYTES_PER_SECOND", 1024),
+			SlowReadGrace:      time.Duration(l.getEnvAsInt("APP_SLOW_READ_GRACE_SECONDS", 10)) * time.Second,
+			StatusCacheTTL:     time.Duration(l.getEnvAsInt("JOB_STATUS_CACHE_TTL_MS", 1000)) * time.Millisecond,
+
+			AdminToken: getEnv("ADMIN_TOKEN", ""),
+			InstanceID: getEnv("APP_INSTANCE_ID", hostname()),
+			Version:    getEnv("APP_VERSION", buildVersion()),
+			UIEnabled:  l.getEnvAsBool("UI_ENABLED", true),
 		},
 		Database: DatabaseConfig{
 			Host:         getEnv("DB_HOST", "localhost"),
-			Port:         getEnvAsInt("DB_PORT", 5432),
+			Port:         l.getEnvAsInt("DB_PORT", 5432),
 			User:         getEnv("DB_USER", "postgres"),
 			Password:     getEnv("DB_PASSWORD", "postgres"),
 			Name:         getEnv("DB_NAME", "bulk_import_export"),
 			SSLMode:      getEnv("DB_SSL_MODE", "disable"),
-			MaxOpenConns: getEnvAsInt("DB_MAX_OPEN_CONNS", 50),
-			MaxIdleConns: getEnvAsInt("DB_MAX_IDLE_CONNS", 10),
+			MaxOpenConns: l.getEnvAsInt("DB_MAX_OPEN_CONNS", 50),
+			MaxIdleConns: l.getEnvAsInt("DB_MAX_IDLE_CONNS", 10),
+
+			MaxStatementBytes: l.getEnvAsInt("DB_MAX_STATEMENT_KB", 16384) * 1024,
 		},
 		Import: ImportConfig{
-			BatchSize:     getEnvAsInt("IMPORT_BATCH_SIZE", 1000),
-			WorkerCount:   getEnvAsInt("IMPORT_WORKER_COUNT", 4),
-			MaxFileSizeMB: getEnvAsInt("MAX_FILE_SIZE_MB", 500),
-			UploadPath:    getEnv("UPLOAD_PATH", "./uploads"),
+			BatchSize:       l.getEnvAsInt("IMPORT_BATCH_SIZE", 1000),
+			WorkerCount:     l.getEnvAsInt("IMPORT_WORKER_COUNT", 4),
+			MaxFileSizeMB:   l.getEnvAsInt("MAX_FILE_SIZE_MB", 500),
+			UploadPath:      getEnv("UPLOAD_PATH", "./uploads"),
+			UploadPartition: getEnv("UPLOAD_PARTITION", "date"),
+			Encoding:        getEnv("IMPORT_ENCODING", "auto"),
+			MinRows:         l.getEnvAsInt("IMPORT_MIN_ROWS", 1),
+			MaxRows:         l.getEnvAsInt("IMPORT_MAX_ROWS", 0),
+			RowLimitMode:    getEnv("IMPORT_ROW_LIMIT_MODE", "reject"),
+			AdminRolePolicy: getEnv("IMPORT_ADMIN_ROLE_POLICY", "allow"),
+
+			EmailAllowDomains:   splitList(getEnv("IMPORT_EMAIL_ALLOW_DOMAINS", "")),
+			EmailDenyDomains:    splitList(getEnv("IMPORT_EMAIL_DENY_DOMAINS", "")),
+			EmailDenyDisposable: l.getEnvAsBool("IMPORT_EMAIL_DENY_DISPOSABLE", false),
+
+			DedupExpectedRows:     l.getEnvAsInt("IMPORT_DEDUP_EXPECTED_ROWS", 1000000),
+			MaxDuplicatePercent:   l.getEnvAsInt("IMPORT_MAX_DUPLICATE_PERCENT", 0),
+			DuplicateCheckMinRows: l.getEnvAsInt("IMPORT_DUPLICATE_CHECK_MIN_ROWS", 10000),
+			FastPathMaxRows:       l.getEnvAsInt("IMPORT_FAST_PATH_MAX_ROWS", 10000),
+			SyncMaxRows:           l.getEnvAsInt("IMPORT_SYNC_MAX_ROWS", 1000),
+			SyncMaxBytes:          l.getEnvAsInt64("IMPORT_SYNC_MAX_BYTES", 1048576),
+			VerifyMaxRows:         l.getEnvAsInt("IMPORT_VERIFY_MAX_ROWS", 100000),
+			MaxErrorsPerCode:      l.getEnvAsInt("IMPORT_MAX_ERRORS_PER_CODE", 1000),
+			SeedMaxRows:           l.getEnvAsInt("IMPORT_SEED_MAX_ROWS", 100000),
+			StatusMaxWait:         time.Duration(l.getEnvAsInt("IMPORT_STATUS_MAX_WAIT_SECONDS", 60)) * time.Second,
+			MaxLineSize:           l.getEnvAsInt("IMPORT_MAX_LINE_KB", 10240) * 1024,
+			ArticleMaxBodyBytes:   l.getEnvAsInt("IMPORT_ARTICLE_MAX_BODY_KB", 5120) * 1024,
+			ArticleBodyOverflow:   getEnv("IMPORT_ARTICLE_BODY_OVERFLOW", "reject"),
+			DetectLanguage:        l.getEnvAsBool("IMPORT_DETECT_LANGUAGE", false),
+			ErrorDocsURL:          getEnv("IMPORT_ERROR_DOCS_URL", ""),
+			Ledger:                l.getEnvAsBool("IMPORT_LEDGER", false),
+			BreakerThreshold:      l.getEnvAsInt("IMPORT_BREAKER_THRESHOLD", 0),
+			BreakerRetryDelay:     time.Duration(l.getEnvAsInt("IMPORT_BREAKER_RETRY_SECONDS", 5)) * time.Second,
+			AlertWebhookURL:       getEnv("IMPORT_ALERT_WEBHOOK_URL", ""),
+
+			ScreenMode:        getEnv("IMPORT_SCREEN_MODE", "off"),
+			ScreenBannedTerms: splitList(getEnv("IMPORT_SCREEN_BANNED_TERMS", "")),
+			ScreenPII:         l.getEnvAsBool("IMPORT_SCREEN_PII", true),
+
+			UploadPartMaxMB:  l.getEnvAsInt("IMPORT_UPLOAD_PART_MAX_MB", 64),
+			UploadSessionTTL: time.Duration(l.getEnvAsInt("IMPORT_UPLOAD_SESSION_TTL_HOURS", 24)) * time.Hour,
 		},
 		Export: ExportConfig{
-			BatchSize:   getEnvAsInt("EXPORT_BATCH_SIZE", 5000),
-			WorkerCount: getEnvAsInt("EXPORT_WORKER_COUNT", 2),
-			OutputPath:  getEnv("EXPORT_PATH", "./exports"),
+			BatchSize:            l.getEnvAsInt("EXPORT_BATCH_SIZE", 5000),
+			WorkerCount:          l.getEnvAsInt("EXPORT_WORKER_COUNT", 2),
+			OutputPath:           getEnv("EXPORT_PATH", "./exports"),
+			MaxConcurrentStreams: l.getEnvAsInt("EXPORT_MAX_CONCURRENT_STREAMS", 10),
+			StreamOverflowMode:   getEnv("EXPORT_STREAM_OVERFLOW_MODE", "reject"),
+			ConsistentSnapshot:   l.getEnvAsBool("EXPORT_CONSISTENT_SNAPSHOT", false),
+			StreamKeepalive:      time.Duration(l.getEnvAsInt("EXPORT_STREAM_KEEPALIVE_SECONDS", 15)) * time.Second,
+			StreamBufferSize:     l.getEnvAsInt("EXPORT_STREAM_BUFFER_KB", 64) * 1024,
+			StreamSpillAfter:     time.Duration(l.getEnvAsInt("EXPORT_STREAM_SPILL_AFTER_MS", 2000)) * time.Millisecond,
+			StreamSpillMaxBytes:  l.getEnvAsInt64("EXPORT_STREAM_SPILL_MAX_MB", 1024) * 1024 * 1024,
+			Compression:          getEnv("EXPORT_COMPRESSION", "none"),
+			CompressionLevel:     l.getEnvAsInt("EXPORT_COMPRESSION_LEVEL", 0),
+			StreamCompression:    l.getEnvAsBool("EXPORT_STREAM_COMPRESSION", true),
+			AvroCodec:            getEnv("EXPORT_AVRO_CODEC", "deflate"),
+
+			SchemaRegistryURL:      getEnv("SCHEMA_REGISTRY_URL", ""),
+			SchemaRegistrySubject:  getEnv("SCHEMA_REGISTRY_SUBJECT_PREFIX", "bulk-export-"),
+			SchemaRegistryUsername: getEnv("SCHEMA_REGISTRY_USERNAME", ""),
+			SchemaRegistryPassword: getEnv("SCHEMA_REGISTRY_PASSWORD", ""),
+			SchemaRegistryTimeout:  time.Duration(l.getEnvAsInt("SCHEMA_REGISTRY_TIMEOUT_SECONDS", 10)) * time.Second,
+
+			CohortInlineMax: l.getEnvAsInt("EXPORT_COHORT_INLINE_MAX", 1000),
+			CohortFileMax:   l.getEnvAsInt("EXPORT_COHORT_FILE_MAX", 100000),
+			InlineMaxRows:   l.getEnvAsInt("EXPORT_INLINE_MAX_ROWS", 1000),
+
+			FileExpiry:     time.Duration(l.getEnvAsInt("EXPORT_FILE_EXPIRY_HOURS", 24)) * time.Hour,
+			ExpiryInterval: time.Duration(l.getEnvAsInt("EXPORT_EXPIRY_INTERVAL_MINUTES", 10)) * time.Minute,
+
+			VerifyCount:  l.getEnvAsBool("EXPORT_VERIFY_COUNT", true),
+			EnforceOrder: l.getEnvAsBool("EXPORT_ENFORCE_ORDER", false),
 		},
 		Worker: WorkerConfig{
-			ImportWorkers: getEnvAsInt("IMPORT_WORKER_COUNT", 4),
-			ExportWorkers: getEnvAsInt("EXPORT_WORKER_COUNT", 2),
-			QueueSize:     getEnvAsInt("WORKER_QUEUE_SIZE", 100),
+			ImportWorkers:     l.getEnvAsInt("IMPORT_WORKER_COUNT", 4),
+			ExportWorkers:     l.getEnvAsInt("EXPORT_WORKER_COUNT", 2),
+			QueueSize:         l.getEnvAsInt("WORKER_QUEUE_SIZE", 100),
+			RecoverPanics:     l.getEnvAsBool("WORKER_RECOVER_PANICS", true),
+			MaxJobPanics:      l.getEnvAsInt("WORKER_MAX_JOB_PANICS", 3),
+			MaxAttempts:       l.getEnvAsInt("WORKER_MAX_ATTEMPTS", 3),
+			RetryBackoff:      time.Duration(l.getEnvAsInt("WORKER_RETRY_BACKOFF_SECONDS", 30)) * time.Second,
+			RetryMaxBackoff:   time.Duration(l.getEnvAsInt("WORKER_RETRY_MAX_BACKOFF_SECONDS", 900)) * time.Second,
+			PriorityAging:     time.Duration(l.getEnvAsInt("WORKER_PRIORITY_AGING_SECONDS", 300)) * time.Second,
+			HeartbeatInterval: time.Duration(l.getEnvAsInt("WORKER_HEARTBEAT_INTERVAL_SECONDS", 15)) * time.Second,
+			HeartbeatTimeout:  time.Duration(l.getEnvAsInt("WORKER_HEARTBEAT_TIMEOUT_SECONDS", 120)) * time.Second,
 		},
 		Storage: StorageConfig{
-			Type:       getEnv("STORAGE_TYPE", "local"),
-			LocalPath:  getEnv("STORAGE_PATH", "./storage"),
-			S3Endpoint: getEnv("AWS_ENDPOINT", "http://localhost:4566"),
-			S3Region:   getEnv("AWS_REGION", "us-east-1"),
-			S3Bucket:   getEnv("AWS_BUCKET", "bulk-imports"),
+			Type:           getEnv("STORAGE_TYPE", "local"),
+			LocalPath:      getEnv("STORAGE_PATH", "./storage"),
+			SignedURLTTL:   time.Duration(l.getEnvAsInt("STORAGE_SIGNED_URL_TTL_MINUTES", 15)) * time.Minute,
+			S3Endpoint:     getEnv("AWS_ENDPOINT", "http://localhost:4566"),
+			S3Region:       getEnv("AWS_REGION", "us-east-1"),
+			S3Bucket:       getEnv("AWS_BUCKET", "bulk-imports"),
+			S3AccessKey:    getEnv("AWS_ACCESS_KEY_ID", ""),
+			S3SecretKey:    getEnv("AWS_SECRET_ACCESS_KEY", ""),
+			AzureAccount:   getEnv("AZURE_STORAGE_ACCOUNT", ""),
+			AzureKey:       getEnv("AZURE_STORAGE_KEY", ""),
+			AzureContainer: getEnv("AZURE_STORAGE_CONTAINER", "bulk-imports"),
+			AzureEndpoint:  getEnv("AZURE_STORAGE_ENDPOINT", ""),
+			GCSEndpoint:    getEnv("GCS_ENDPOINT", "https://storage.googleapis.com"),
+			GCSBucket:      getEnv("GCS_BUCKET", "bulk-imports"),
+			GCSAccessID:    getEnv("GCS_HMAC_ACCESS_ID", ""),
+			GCSSecret:      getEnv("GCS_HMAC_SECRET", ""),
 		},
 		Prometheus: PrometheusConfig{
-			Enabled: getEnvAsBool("PROMETHEUS_ENABLED", true),
-			Port:    getEnvAsInt("PROMETHEUS_PORT", 9090),
+			Enabled: l.getEnvAsBool("PROMETHEUS_ENABLED", true),
+			Port:    l.getEnvAsInt("PROMETHEUS_PORT", 2112),
+		},
+		Quota: QuotaConfig{
+			Enabled:            l.getEnvAsBool("QUOTA_ENABLED", false),
+			JobsPerDay:         l.getEnvAsInt("QUOTA_JOBS_PER_DAY", 0),
+			RowsPerMonth:       l.getEnvAsInt64("QUOTA_ROWS_PER_MONTH", 0),
+			ExportStorageBytes: l.getEnvAsInt64("QUOTA_EXPORT_STORAGE_BYTES", 0),
+		},
+		Search: SearchConfig{
+			Enabled:     l.getEnvAsBool("SEARCH_ENABLED", false),
+			Endpoint:    getEnv("SEARCH_ENDPOINT", "http://localhost:9200"),
+			Index:       getEnv("SEARCH_INDEX", "articles"),
+			MappingFile: getEnv("SEARCH_MAPPING_FILE", ""),
+			Username:    getEnv("SEARCH_USERNAME", ""),
+			Password:    getEnv("SEARCH_PASSWORD", ""),
+			BatchSize:   l.getEnvAsInt("SEARCH_BATCH_SIZE", 500),
+			Timeout:     time.Duration(l.getEnvAsInt("SEARCH_TIMEOUT_SECONDS", 30)) * time.Second,
+		},
+		Events: EventsConfig{
+			Driver:         getEnv("EVENTS_DRIVER", ""),
+			URL:            getEnv("EVENTS_URL", ""),
+			SubjectPrefix:  getEnv("EVENTS_SUBJECT_PREFIX", "bulk.invalidate"),
+			ResourceLevel:  l.getEnvAsBool("EVENTS_RESOURCE_LEVEL", true),
+			EntityLevel:    l.getEnvAsBool("EVENTS_ENTITY_LEVEL", true),
+			MaxIDsPerEvent: l.getEnvAsInt("EVENTS_MAX_IDS_PER_EVENT", 1000),
+			Timeout:        time.Duration(l.getEnvAsInt("EVENTS_TIMEOUT_SECONDS", 5)) * time.Second,
+		},
+		Log: LogConfig{
+			FilePath:      getEnv("LOG_FILE", "./logs/app.log"),
+			SyslogNetwork: getEnv("LOG_SYSLOG_NETWORK", ""),
+			SyslogAddr:    getEnv("LOG_SYSLOG_ADDR", ""),
+			SyslogTag:     getEnv("LOG_SYSLOG_TAG", "bulk-import-export"),
+			Level:         getEnv("LOG_LEVEL", "info"),
+			SampleBurst:   l.getEnvAsInt("LOG_SAMPLE_BURST", 10),
+			SamplePeriod:  time.Duration(l.getEnvAsInt("LOG_SAMPLE_PERIOD_SECONDS", 1)) * time.Second,
+			SampleEvery:   l.getEnvAsInt("LOG_SAMPLE_EVERY", 100),
+			JobLines:      l.getEnvAsInt("LOG_JOB_LINES", 500),
+		},
+		Report: ReportConfig{
+			RollupEnabled:  l.getEnvAsBool("REPORT_ROLLUP_ENABLED", false),
+			RollupInterval: time.Duration(l.getEnvAsInt("REPORT_ROLLUP_INTERVAL_MINUTES", 60)) * time.Minute,
+			RollupDelay:    time.Duration(l.getEnvAsInt("REPORT_ROLLUP_DELAY_HOURS", 6)) * time.Hour,
 		},
+		Signing: SigningConfig{
+			Enabled:                l.getEnvAsBool("SIGNING_ENABLED", false),
+			URLTTL:                 time.Duration(l.getEnvAsInt("SIGNING_URL_TTL_SECONDS", 3600)) * time.Second,
+			RequireSignedDownloads: l.getEnvAsBool("SIGNING_REQUIRE_SIGNED_DOWNLOADS", false),
+			KeyRefresh:             time.Duration(l.getEnvAsInt("SIGNING_KEY_REFRESH_SECONDS", 60)) * time.Second,
+		},
+		Delivery: DeliveryConfig{
+			MaxAttempts:  l.getEnvAsInt("DELIVERY_MAX_ATTEMPTS", 8),
+			BackoffBase:  time.Duration(l.getEnvAsInt("DELIVERY_BACKOFF_BASE_SECONDS", 30)) * time.Second,
+			BackoffMax:   time.Duration(l.getEnvAsInt("DELIVERY_BACKOFF_MAX_SECONDS", 3600)) * time.Second,
+			PollInterval: time.Duration(l.getEnvAsInt("DELIVERY_POLL_INTERVAL_SECONDS", 5)) * time.Second,
+			BatchSize:    l.getEnvAsInt("DELIVERY_BATCH_SIZE", 50),
+			Timeout:      time.Duration(l.getEnvAsInt("DELIVERY_TIMEOUT_SECONDS", 10)) * time.Second,
+		},
+	}
+
+	// Console logs for development, JSON in production, unless set
+	defaultOutput := "console"
+	if cfg.App.Env == "production" {
+		defaultOutput = "json"
+	}
+	cfg.Log.Outputs = splitList(getEnv("LOG_OUTPUT", defaultOutput))
+
+	componentLevels, err := parseComponentLevels(getEnv("LOG_COMPONENT_LEVELS", ""))
+	l.check(err)
+	cfg.Log.ComponentLevels = componentLevels
+
+	tenantKeys, err := parseTenantAPIKeys(getEnv("TENANT_API_KEYS", ""))
+	l.check(err)
+	cfg.Quota.TenantAPIKeys = tenantKeys
+
+	l.validate(cfg)
+	if len(l.problems) > 0 {
+		return nil, &ValidationError{Problems: l.problems}
 	}
 
 	// Ensure directories exist
@@ -157,7 +669,75 @@ func IdempotencyTTL() time.Duration {
 	return time.Duration(hours) * time.Hour
 }
 
+// splitList splits a comma-separated setting, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseComponentLevels parses component=level pairs separated by commas
+func parseComponentLevels(value string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, pair := range splitList(value) {
+		component, level, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(component) == "" {
+			return nil, fmt.Errorf("invalid LOG_COMPONENT_LEVELS entry %q, expected component=level", pair)
+		}
+		levels[strings.TrimSpace(component)] = strings.TrimSpace(level)
+	}
+	return levels, nil
+}
+
+// parseTenantAPIKeys parses key=tenant pairs separated by commas
+func parseTenantAPIKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range splitList(value) {
+		key, tenant, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" || strings.TrimSpace(tenant) == "" {
+			// The entry holds a key, so it is left out of the error
+			return nil, fmt.Errorf("invalid TENANT_API_KEYS entry, expected key=tenant")
+		}
+		keys[strings.TrimSpace(key)] = strings.TrimSpace(tenant)
+	}
+	return keys, nil
+}
+
+// hostname returns the machine's hostname, or "unknown" if it can't be read
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// buildVersion returns the module version or VCS revision the binary was
+// built from, or "dev" when neither was recorded
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return setting.Value[:12]
+		}
+	}
+	return "dev"
+}
+
 func getEnv(key, defaultValue string) string {
+	if value, ok := lookupSource(key); ok {
+		return value
+	}
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
@@ -165,25 +745,47 @@ func getEnv(key, defaultValue string) string {
 }
 
 func getEnvAsInt(key string, defaultValue int) int {
+	value, _ := lookupInt(key, defaultValue)
+	return value
+}
+
+func getEnvAsInt64(key string, defaultValue int64) int64 {
+	value, _ := lookupInt64(key, defaultValue)
+	return value
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value, _ := lookupBool(key, defaultValue)
+	return value
+}
+
+// lookupInt returns the setting for key as an int, or defaultValue with an
+// error when it is set to something else
+func lookupInt(key string, defaultValue int) (int, error) {
+	value, err := lookupInt64(key, int64(defaultValue))
+	return int(value), err
+}
+
+func lookupInt64(key string, defaultValue int64) (int64, error) {
 	strValue := getEnv(key, "")
 	if strValue == "" {
-		return defaultValue
+		return defaultValue, nil
 	}
-	intValue, err := strconv.Atoi(strValue)
+	intValue, err := strconv.ParseInt(strings.TrimSpace(strValue), 10, 64)
 	if err != nil {
-		return defaultValue
+		return defaultValue, fmt.Errorf("%s=%q is not a whole number", key, strValue)
 	}
-	return intValue
+	return intValue, nil
 }
 
-func getEnvAsBool(key string, defaultValue bool) bool {
+func lookupBool(key string, defaultValue bool) (bool, error) {
 	strValue := getEnv(key, "")
 	if strValue == "" {
-		return defaultValue
+		return defaultValue, nil
 	}
-	boolValue, err := strconv.ParseBool(strValue)
+	boolValue, err := strconv.ParseBool(strings.TrimSpace(strValue))
 	if err != nil {
-		return defaultValue
+		return defaultValue, fmt.Errorf("%s=%q is not true or false", key, strValue)
 	}
-	return boolValue
+	return boolValue, nil
 }
@@ -0,0 +1,396 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces the value of a secret setting
+const redacted = "[redacted]"
+
+// sources holds the settings that take precedence over the environment:
+// values set through the admin API, then values read from CONFIG_FILE
+var sources struct {
+	mu   sync.RWMutex
+	set  map[string]string
+	file map[string]string
+}
+
+// lookupSource returns the value of key from the admin API or CONFIG_FILE
+func lookupSource(key string) (string, bool) {
+	sources.mu.RLock()
+	defer sources.mu.RUnlock()
+	if value, ok := sources.set[key]; ok {
+		return value, true
+	}
+	value, ok := sources.file[key]
+	return value, ok
+}
+
+// readConfigFile loads the KEY=VALUE lines of CONFIG_FILE, in the format of
+// .env.example, as the settings that override the environment
+func readConfigFile() error {
+	path, ok := os.LookupEnv("CONFIG_FILE")
+	if !ok || path == "" {
+		sources.mu.Lock()
+		sources.file = nil
+		sources.mu.Unlock()
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("CONFIG_FILE line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+	}
+
+	sources.mu.Lock()
+	sources.file = values
+	sources.mu.Unlock()
+	return nil
+}
+
+// tunable is a setting that can change while the server runs. Settings
+// read from more than one field, such as IMPORT_WORKER_COUNT, have an
+// entry per field.
+type tunable struct {
+	key string
+	min int64
+	// field points at the setting in c: an *int, *int64 or *time.Duration.
+	// It is nil for settings read from the environment on each use, which
+	// have live instead.
+	field func(c *Config) interface{}
+	live  func() string
+}
+
+var tunables = []tunable{
+	{"IMPORT_BATCH_SIZE", 1, func(c *Config) interface{} { return &c.Import.BatchSize }, nil},
+	{"IMPORT_WORKER_COUNT", 1, func(c *Config) interface{} { return &c.Import.WorkerCount }, nil},
+	{"IMPORT_WORKER_COUNT", 1, func(c *Config) interface{} { return &c.Worker.ImportWorkers }, nil},
+	{"MAX_FILE_SIZE_MB", 1, func(c *Config) interface{} { return &c.Import.MaxFileSizeMB }, nil},
+	{"IMPORT_MIN_ROWS", 0, func(c *Config) interface{} { return &c.Import.MinRows }, nil},
+	{"IMPORT_MAX_ROWS", 0, func(c *Config) interface{} { return &c.Import.MaxRows }, nil},
+	{"IMPORT_MAX_DUPLICATE_PERCENT", 0, func(c *Config) interface{} { return &c.Import.MaxDuplicatePercent }, nil},
+	{"IMPORT_DUPLICATE_CHECK_MIN_ROWS", 0, func(c *Config) interface{} { return &c.Import.DuplicateCheckMinRows }, nil},
+	{"IMPORT_FAST_PATH_MAX_ROWS", 0, func(c *Config) interface{} { return &c.Import.FastPathMaxRows }, nil},
+	{"IMPORT_SYNC_MAX_ROWS", 0, func(c *Config) interface{} { return &c.Import.SyncMaxRows }, nil},
+	{"IMPORT_SYNC_MAX_BYTES", 0, func(c *Config) interface{} { return &c.Import.SyncMaxBytes }, nil},
+	{"IMPORT_MAX_ERRORS_PER_CODE", 0, func(c *Config) interface{} { return &c.Import.MaxErrorsPerCode }, nil},
+	{"IMPORT_VERIFY_MAX_ROWS", 1, func(c *Config) interface{} { return &c.Import.VerifyMaxRows }, nil},
+	{"IMPORT_SEED_MAX_ROWS", 0, func(c *Config) interface{} { return &c.Import.SeedMaxRows }, nil},
+	{"IMPORT_STATUS_MAX_WAIT_SECONDS", 0, func(c *Config) interface{} { return &c.Import.StatusMaxWait }, nil},
+	{"IMPORT_BREAKER_THRESHOLD", 0, func(c *Config) interface{} { return &c.Import.BreakerThreshold }, nil},
+	{"IMPORT_BREAKER_RETRY_SECONDS", 0, func(c *Config) interface{} { return &c.Import.BreakerRetryDelay }, nil},
+	{"EXPORT_BATCH_SIZE", 1, func(c *Config) interface{} { return &c.Export.BatchSize }, nil},
+	{"EXPORT_WORKER_COUNT", 1, func(c *Config) interface{} { return &c.Export.WorkerCount }, nil},
+	{"EXPORT_WORKER_COUNT", 1, func(c *Config) interface{} { return &c.Worker.ExportWorkers }, nil},
+	{"EXPORT_STREAM_KEEPALIVE_SECONDS", 0, func(c *Config) interface{} { return &c.Export.StreamKeepalive }, nil},
+	{"EXPORT_STREAM_SPILL_AFTER_MS", 0, func(c *Config) interface{} { return &c.Export.StreamSpillAfter }, nil},
+	{"EXPORT_STREAM_SPILL_MAX_MB", 0, func(c *Config) interface{} { return &c.Export.StreamSpillMaxBytes }, nil},
+	{"EXPORT_COHORT_INLINE_MAX", 1, func(c *Config) interface{} { return &c.Export.CohortInlineMax }, nil},
+	{"EXPORT_COHORT_FILE_MAX", 1, func(c *Config) interface{} { return &c.Export.CohortFileMax }, nil},
+	{"EXPORT_INLINE_MAX_ROWS", 0, func(c *Config) interface{} { return &c.Export.InlineMaxRows }, nil},
+	{"EXPORT_FILE_EXPIRY_HOURS", 0, func(c *Config) interface{} { return &c.Export.FileExpiry }, nil},
+	{"QUOTA_JOBS_PER_DAY", 0, func(c *Config) interface{} { return &c.Quota.JobsPerDay }, nil},
+	{"QUOTA_ROWS_PER_MONTH", 0, func(c *Config) interface{} { return &c.Quota.RowsPerMonth }, nil},
+	{"QUOTA_EXPORT_STORAGE_BYTES", 0, func(c *Config) interface{} { return &c.Quota.ExportStorageBytes }, nil},
+	{"STORAGE_SIGNED_URL_TTL_MINUTES", 1, func(c *Config) interface{} { return &c.Storage.SignedURLTTL }, nil},
+	{"IDEMPOTENCY_TTL_HOURS", 1, nil, func() string { return IdempotencyTTL().String() }},
+}
+
+// TunableKeys returns the names of the settings that can change while the
+// server runs
+func TunableKeys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, t := range tunables {
+		if !seen[t.key] {
+			seen[t.key] = true
+			keys = append(keys, t.key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateTunables checks values for Reloader.Set: each must name a tunable
+// setting and be a whole number no smaller than the setting allows
+func ValidateTunables(values map[string]string) error {
+	minimums := make(map[string]int64, len(tunables))
+	for _, t := range tunables {
+		minimums[t.key] = t.min
+	}
+	for key, value := range values {
+		minimum, ok := minimums[key]
+		if !ok {
+			return fmt.Errorf("%s can't be changed while the server runs", key)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || n < minimum {
+			return fmt.Errorf("%s must be a whole number of at least %d", key, minimum)
+		}
+	}
+	return nil
+}
+
+// Change is a tunable setting that took a new value
+type Change struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// copyTunables sets the tunable settings of dst to those of src and
+// returns the ones that changed
+func copyTunables(dst, src *Config) []Change {
+	var changes []Change
+	seen := make(map[string]bool)
+	for _, t := range tunables {
+		if t.field == nil {
+			continue
+		}
+		from, to := fieldString(t.field(dst)), fieldString(t.field(src))
+		switch d := t.field(dst).(type) {
+		case *int:
+			*d = *t.field(src).(*int)
+		case *int64:
+			*d = *t.field(src).(*int64)
+		case *time.Duration:
+			*d = *t.field(src).(*time.Duration)
+		}
+		if from != to && !seen[t.key] {
+			seen[t.key] = true
+			changes = append(changes, Change{Key: t.key, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+func fieldString(field interface{}) string {
+	switch v := field.(type) {
+	case *int:
+		return strconv.Itoa(*v)
+	case *int64:
+		return strconv.FormatInt(*v, 10)
+	case *time.Duration:
+		return v.String()
+	}
+	return ""
+}
+
+// liveValues returns the settings read from the environment on each use
+func liveValues() map[string]string {
+	values := make(map[string]string)
+	for _, t := range tunables {
+		if t.live != nil {
+			values[t.key] = t.live()
+		}
+	}
+	return values
+}
+
+// Reloader replaces the tunable settings of a running server, from the
+// environment and CONFIG_FILE on Reload or by name on Set, and hands the
+// new configuration to the components that use them. Other settings keep
+// the values they started with until a restart.
+type Reloader struct {
+	mu        sync.Mutex
+	current   *Config
+	changedAt time.Time
+	listeners []func(*Config)
+}
+
+// NewReloader creates a reloader starting from cfg
+func NewReloader(cfg *Config) *Reloader {
+	return &Reloader{current: cfg}
+}
+
+// OnChange registers fn to be called with the configuration after each
+// change to a tunable setting
+func (r *Reloader) OnChange(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Current returns the configuration in effect and when a tunable setting
+// last changed, zero if none has
+func (r *Reloader) Current() (*Config, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current, r.changedAt
+}
+
+// Overrides returns the values set with Set since the last reload
+func (r *Reloader) Overrides() map[string]string {
+	sources.mu.RLock()
+	defer sources.mu.RUnlock()
+	overrides := make(map[string]string, len(sources.set))
+	for key, value := range sources.set {
+		overrides[key] = value
+	}
+	return overrides
+}
+
+// Reload re-reads the tunable settings from the environment and
+// CONFIG_FILE, dropping values set with Set
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := liveValues()
+	sources.mu.Lock()
+	previous := sources.set
+	sources.set = nil
+	sources.mu.Unlock()
+
+	changes, err := r.apply(before)
+	if err != nil {
+		sources.mu.Lock()
+		sources.set = previous
+		sources.mu.Unlock()
+	}
+	return changes, err
+}
+
+// Set overrides tunable settings by name until the next reload. Values are
+// written as in the environment and checked with ValidateTunables.
+func (r *Reloader) Set(values map[string]string) ([]Change, error) {
+	if err := ValidateTunables(values); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := liveValues()
+	sources.mu.Lock()
+	previous := sources.set
+	set := make(map[string]string, len(previous)+len(values))
+	for key, value := range previous {
+		set[key] = value
+	}
+	for key, value := range values {
+		set[key] = strings.TrimSpace(value)
+	}
+	sources.set = set
+	sources.mu.Unlock()
+
+	changes, err := r.apply(before)
+	if err != nil {
+		sources.mu.Lock()
+		sources.set = previous
+		sources.mu.Unlock()
+	}
+	return changes, err
+}
+
+// apply loads the settings again and copies the tunable ones into a new
+// current configuration. before holds the settings read on each use as
+// they were before the change. r.mu is held.
+func (r *Reloader) apply(before map[string]string) ([]Change, error) {
+	fresh, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	next := *r.current
+	changes := copyTunables(&next, fresh)
+	for key, to := range liveValues() {
+		if from := before[key]; from != to {
+			changes = append(changes, Change{Key: key, From: from, To: to})
+		}
+	}
+	r.current = &next
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	r.changedAt = time.Now()
+	for _, fn := range r.listeners {
+		fn(&next)
+	}
+	return changes, nil
+}
+
+// Redacted returns the settings by section and field name with secrets
+// replaced, for display. Durations are written like 1m30s and credentials
+// in URLs are masked.
+func (c *Config) Redacted() map[string]map[string]interface{} {
+	out := make(map[string]map[string]interface{})
+	cv := reflect.ValueOf(c).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		section := cv.Field(i)
+		fields := make(map[string]interface{}, section.NumField())
+		for j := 0; j < section.NumField(); j++ {
+			name := section.Type().Field(j).Name
+			fields[name] = displayValue(name, section.Field(j).Interface())
+		}
+		out[strings.ToLower(cv.Type().Field(i).Name)] = fields
+	}
+	return out
+}
+
+// secretFields are the settings shown only as set or unset
+var secretFields = map[string]bool{
+	"AdminToken":             true,
+	"Password":               true,
+	"S3AccessKey":            true,
+	"S3SecretKey":            true,
+	"AzureKey":               true,
+	"GCSAccessID":            true,
+	"GCSSecret":              true,
+	"SchemaRegistryPassword": true,
+	// Webhook URLs commonly carry their token in the path
+	"AlertWebhookURL": true,
+	"TenantAPIKeys":   true,
+}
+
+func displayValue(name string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String()
+	case map[string]string:
+		if secretFields[name] && len(v) > 0 {
+			return redacted
+		}
+	case string:
+		if secretFields[name] && v != "" {
+			return redacted
+		}
+		if strings.Contains(v, "://") {
+			if u, err := url.Parse(v); err == nil && u.User != nil {
+				return u.Redacted()
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupReload points the directories Load creates at a temporary one and
+// writes CONFIG_FILE with content
+func setupReload(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("UPLOAD_PATH", filepath.Join(dir, "uploads"))
+	t.Setenv("EXPORT_PATH", filepath.Join(dir, "exports"))
+	t.Setenv("STORAGE_PATH", filepath.Join(dir, "storage"))
+
+	path := filepath.Join(dir, "app.env")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Cleanup(func() {
+		sources.mu.Lock()
+		sources.set, sources.file = nil, nil
+		sources.mu.Unlock()
+	})
+	return path
+}
+
+func TestLoad_ConfigFileOverridesEnvironment(t *testing.T) {
+	setupReload(t, "# tuning\nIMPORT_BATCH_SIZE=250\nexport DB_NAME=\"from_file\"\n")
+	t.Setenv("IMPORT_BATCH_SIZE", "500")
+	t.Setenv("EXPORT_BATCH_SIZE", "700")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Import.BatchSize != 250 || cfg.Database.Name != "from_file" {
+		t.Errorf("batch size %d, db %q; want the file's 250 and from_file", cfg.Import.BatchSize, cfg.Database.Name)
+	}
+	if cfg.Export.BatchSize != 700 {
+		t.Errorf("export batch size = %d, want the environment's 700", cfg.Export.BatchSize)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	path := setupReload(t, "IMPORT_BATCH_SIZE=250\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	r := NewReloader(cfg)
+	var seen []*Config
+	r.OnChange(func(c *Config) { seen = append(seen, c) })
+
+	// Only tunable settings, as whole numbers within their bounds
+	for _, values := range []map[string]string{
+		{"DB_HOST": "elsewhere"},
+		{"IMPORT_WORKER_COUNT": "0"},
+		{"IMPORT_BATCH_SIZE": "many"},
+	} {
+		if _, err := r.Set(values); err == nil {
+			t.Errorf("Set(%v) succeeded, want an error", values)
+		}
+	}
+
+	changes, err := r.Set(map[string]string{"IMPORT_WORKER_COUNT": "9", "IDEMPOTENCY_TTL_HOURS": "48"})
+	if err != nil {
+		t.Fatalf("Set() error: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("changes = %+v, want the worker count and idempotency TTL", changes)
+	}
+	current, changedAt := r.Current()
+	if current.Import.WorkerCount != 9 || current.Worker.ImportWorkers != 9 || changedAt.IsZero() {
+		t.Errorf("worker counts = %d and %d, want 9", current.Import.WorkerCount, current.Worker.ImportWorkers)
+	}
+	if IdempotencyTTL() != 48*time.Hour {
+		t.Errorf("IdempotencyTTL() = %v, want 48h", IdempotencyTTL())
+	}
+	if cfg.Import.WorkerCount == 9 {
+		t.Error("Set() changed the starting configuration in place")
+	}
+	if len(seen) != 1 || seen[0] != current {
+		t.Errorf("listener called %d times, want once with the new configuration", len(seen))
+	}
+
+	// A reload reads the file again and drops the overrides
+	if err := os.WriteFile(path, []byte("IMPORT_BATCH_SIZE=100\nAPP_PORT=9999\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if _, err := r.Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	current, _ = r.Current()
+	if current.Import.BatchSize != 100 || current.Worker.ImportWorkers != 4 {
+		t.Errorf("batch size %d, workers %d; want 100 and the default 4", current.Import.BatchSize, current.Worker.ImportWorkers)
+	}
+	if current.App.Port != cfg.App.Port {
+		t.Errorf("port = %d, want %d until a restart", current.App.Port, cfg.App.Port)
+	}
+	if len(r.Overrides()) != 0 {
+		t.Errorf("overrides = %v, want none after a reload", r.Overrides())
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := &Config{
+		App:      AppConfig{AdminToken: "s3cret", Name: "bulk"},
+		Database: DatabaseConfig{Password: "hunter2"},
+		Events:   EventsConfig{URL: "redis://:pa55@cache:6379"},
+		Import:   ImportConfig{StatusMaxWait: time.Minute},
+		Quota:    QuotaConfig{TenantAPIKeys: map[string]string{"k3y": "acme"}},
+	}
+	settings := cfg.Redacted()
+
+	if got := settings["app"]["AdminToken"]; got != redacted {
+		t.Errorf("admin token = %v, want it redacted", got)
+	}
+	if got := settings["database"]["Password"]; got != redacted {
+		t.Errorf("password = %v, want it redacted", got)
+	}
+	if got := settings["quota"]["TenantAPIKeys"]; got != redacted {
+		t.Errorf("tenant API keys = %v, want them redacted", got)
+	}
+	if got := settings["storage"]["S3SecretKey"]; got != "" {
+		t.Errorf("unset secret = %v, want it shown empty", got)
+	}
+	if got := settings["events"]["URL"].(string); strings.Contains(got, "pa55") {
+		t.Errorf("events URL = %s, want its password masked", got)
+	}
+	if got := settings["import"]["StatusMaxWait"]; got != "1m0s" {
+		t.Errorf("status max wait = %v, want 1m0s", got)
+	}
+	if got := settings["app"]["Name"]; got != "bulk" {
+		t.Errorf("name = %v, want bulk", got)
+	}
+}
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// ValidationError lists every setting Load found unusable, each naming the
+// variable to fix
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// loader reads the typed settings for Load, noting values that can't be
+// parsed rather than quietly using the default
+type loader struct {
+	problems []string
+}
+
+func (l *loader) getEnvAsInt(key string, defaultValue int) int {
+	value, err := lookupInt(key, defaultValue)
+	l.check(err)
+	return value
+}
+
+func (l *loader) getEnvAsInt64(key string, defaultValue int64) int64 {
+	value, err := lookupInt64(key, defaultValue)
+	l.check(err)
+	return value
+}
+
+func (l *loader) getEnvAsBool(key string, defaultValue bool) bool {
+	value, err := lookupBool(key, defaultValue)
+	l.check(err)
+	return value
+}
+
+func (l *loader) check(err error) {
+	if err != nil {
+		l.problems = append(l.problems, err.Error())
+	}
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+func (l *loader) atLeast(key string, value, min int64) {
+	if value < min {
+		l.addf("%s must be at least %d, got %d", key, min, value)
+	}
+}
+
+func (l *loader) between(key string, value, min, max int64) {
+	if value < min || value > max {
+		l.addf("%s must be between %d and %d, got %d", key, min, max, value)
+	}
+}
+
+// oneOf checks value is one of allowed; an empty entry in allowed lets the
+// setting be left empty without listing it as a choice
+func (l *loader) oneOf(key, value string, allowed ...string) {
+	choices := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+		if a != "" {
+			choices = append(choices, a)
+		}
+	}
+	l.addf("%s must be one of %s, got %q", key, strings.Join(choices, ", "), value)
+}
+
+func (l *loader) required(key, value, reason string) {
+	if strings.TrimSpace(value) == "" {
+		l.addf("%s must be set %s", key, reason)
+	}
+}
+
+func (l *loader) validURL(key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		l.addf("%s must be an absolute URL such as http://host:port, got %q", key, value)
+	}
+}
+
+// seconds, minutes and hours give a duration in the unit of its variable
+func seconds(d time.Duration) int64 { return int64(d / time.Second) }
+func minutes(d time.Duration) int64 { return int64(d / time.Minute) }
+func hours(d time.Duration) int64   { return int64(d / time.Hour) }
+
+// logLevels are the level names LOG_LEVEL and LOG_COMPONENT_LEVELS accept
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic", "disabled"}
+
+// validate checks the ranges and choices of cfg's settings
+func (l *loader) validate(cfg *Config) {
+	app := cfg.App
+	l.between("APP_PORT", int64(app.Port), 1, 65535)
+	l.atLeast("APP_READ_TIMEOUT", int64(app.ReadTimeout), 0)
+	l.atLeast("APP_WRITE_TIMEOUT", int64(app.WriteTimeout), 0)
+	l.atLeast("APP_STREAM_WRITE_TIMEOUT", int64(app.StreamWriteTimeout), 0)
+	l.atLeast("APP_UPLOAD_WRITE_TIMEOUT", int64(app.UploadWriteTimeout), 0)
+	l.atLeast("APP_IDLE_TIMEOUT", int64(app.IdleTimeout), 0)
+	l.atLeast("APP_READ_HEADER_TIMEOUT", int64(app.ReadHeaderTimeout), 0)
+	l.atLeast("APP_MAX_BODY_KB", app.MaxBodyBytes/1024, 0)
+	l.atLeast("APP_MIN_READ_BYTES_PER_SECOND", app.MinReadRate, 0)
+	l.atLeast("APP_SLOW_READ_GRACE_SECONDS", seconds(app.SlowReadGrace), 0)
+	l.atLeast("JOB_STATUS_CACHE_TTL_MS", app.StatusCacheTTL.Milliseconds(), 0)
+	if app.MinReadRate > 0 && app.SlowReadGrace <= 0 {
+		l.addf("APP_SLOW_READ_GRACE_SECONDS must be at least 1 when APP_MIN_READ_BYTES_PER_SECOND is set")
+	}
+
+	db := cfg.Database
+	l.between("DB_PORT", int64(db.Port), 1, 65535)
+	l.oneOf("DB_SSL_MODE", db.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	l.atLeast("DB_MAX_OPEN_CONNS", int64(db.MaxOpenConns), 0)
+	l.atLeast("DB_MAX_IDLE_CONNS", int64(db.MaxIdleConns), 0)
+	if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		l.addf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", db.MaxIdleConns, db.MaxOpenConns)
+	}
+	l.atLeast("DB_MAX_STATEMENT_KB", int64(db.MaxStatementBytes/1024), 1)
+
+	imp := cfg.Import
+	l.atLeast("IMPORT_BATCH_SIZE", int64(imp.BatchSize), 1)
+	l.atLeast("IMPORT_WORKER_COUNT", int64(imp.WorkerCount), 1)
+	l.atLeast("MAX_FILE_SIZE_MB", int64(imp.MaxFileSizeMB), 1)
+	l.required("UPLOAD_PATH", imp.UploadPath, "to the directory uploads are saved in")
+	l.oneOf("UPLOAD_PARTITION", imp.UploadPartition, "date", "job", "none")
+	l.oneOf("IMPORT_ENCODING", strings.ToLower(strings.ReplaceAll(strings.TrimSpace(imp.Encoding), "_", "-")),
+		"", "auto", "utf-8", "utf8", "utf-16le", "utf16le", "utf-16", "utf-16be", "utf16be",
+		"latin1", "latin-1", "iso-8859-1", "iso8859-1")
+	l.atLeast("IMPORT_MIN_ROWS", int64(imp.MinRows), 0)
+	l.atLeast("IMPORT_MAX_ROWS", int64(imp.MaxRows), 0)
+	if imp.MaxRows > 0 && imp.MinRows > imp.MaxRows {
+		l.addf("IMPORT_MIN_ROWS (%d) must not exceed IMPORT_MAX_ROWS (%d)", imp.MinRows, imp.MaxRows)
+	}
+	l.oneOf("IMPORT_ROW_LIMIT_MODE", imp.RowLimitMode, "reject", "truncate")
+	l.oneOf("IMPORT_ADMIN_ROLE_POLICY", imp.AdminRolePolicy, "allow", "flag", "block")
+	l.atLeast("IMPORT_DEDUP_EXPECTED_ROWS", int64(imp.DedupExpectedRows), 1)
+	l.between("IMPORT_MAX_DUPLICATE_PERCENT", int64(imp.MaxDuplicatePercent), 0, 100)
+	l.atLeast("IMPORT_DUPLICATE_CHECK_MIN_ROWS", int64(imp.DuplicateCheckMinRows), 0)
+	l.atLeast("IMPORT_FAST_PATH_MAX_ROWS", int64(imp.FastPathMaxRows), 0)
+	l.atLeast("IMPORT_SYNC_MAX_ROWS", int64(imp.SyncMaxRows), 0)
+	l.atLeast("IMPORT_SYNC_MAX_BYTES", imp.SyncMaxBytes, 0)
+	l.atLeast("IMPORT_VERIFY_MAX_ROWS", int64(imp.VerifyMaxRows), 1)
+	l.atLeast("IMPORT_MAX_ERRORS_PER_CODE", int64(imp.MaxErrorsPerCode), 0)
+	l.atLeast("IMPORT_SEED_MAX_ROWS", int64(imp.SeedMaxRows), 1)
+	l.atLeast("IMPORT_STATUS_MAX_WAIT_SECONDS", seconds(imp.StatusMaxWait), 0)
+	l.atLeast("IMPORT_MAX_LINE_KB", int64(imp.MaxLineSize/1024), 1)
+	l.atLeast("IMPORT_ARTICLE_MAX_BODY_KB", int64(imp.ArticleMaxBodyBytes/1024), 0)
+	l.oneOf("IMPORT_ARTICLE_BODY_OVERFLOW", imp.ArticleBodyOverflow, "reject", "truncate")
+	if imp.ErrorDocsURL != "" {
+		l.validURL("IMPORT_ERROR_DOCS_URL", imp.ErrorDocsURL)
+	}
+	l.oneOf("IMPORT_SCREEN_MODE", imp.ScreenMode, "off", "reject", "redact", "flag")
+	l.atLeast("IMPORT_UPLOAD_PART_MAX_MB", int64(imp.UploadPartMaxMB), 1)
+	l.atLeast("IMPORT_UPLOAD_SESSION_TTL_HOURS", int64(imp.UploadSessionTTL/time.Hour), 1)
+	l.atLeast("IMPORT_BREAKER_THRESHOLD", int64(imp.BreakerThreshold), 0)
+	l.atLeast("IMPORT_BREAKER_RETRY_SECONDS", seconds(imp.BreakerRetryDelay), 0)
+	if imp.AlertWebhookURL != "" {
+		l.validURL("IMPORT_ALERT_WEBHOOK_URL", imp.AlertWebhookURL)
+	}
+
+	exp := cfg.Export
+	l.atLeast("EXPORT_BATCH_SIZE", int64(exp.BatchSize), 1)
+	l.atLeast("EXPORT_WORKER_COUNT", int64(exp.WorkerCount), 1)
+	l.required("EXPORT_PATH", exp.OutputPath, "to the directory exports are written to")
+	l.atLeast("EXPORT_MAX_CONCURRENT_STREAMS", int64(exp.MaxConcurrentStreams), 0)
+	l.oneOf("EXPORT_STREAM_OVERFLOW_MODE", exp.StreamOverflowMode, "reject", "async")
+	l.atLeast("EXPORT_STREAM_KEEPALIVE_SECONDS", seconds(exp.StreamKeepalive), 0)
+	l.atLeast("EXPORT_STREAM_BUFFER_KB", int64(exp.StreamBufferSize/1024), 1)
+	l.atLeast("EXPORT_STREAM_SPILL_AFTER_MS", exp.StreamSpillAfter.Milliseconds(), 0)
+	l.atLeast("EXPORT_STREAM_SPILL_MAX_MB", exp.StreamSpillMaxBytes/(1024*1024), 0)
+	l.oneOf("EXPORT_COMPRESSION", exp.Compression, "", "none", "gzip", "zstd")
+	switch {
+	case exp.Compression == "gzip":
+		l.between("EXPORT_COMPRESSION_LEVEL", int64(exp.CompressionLevel), 0, 9)
+	case exp.Compression == "zstd":
+		l.between("EXPORT_COMPRESSION_LEVEL", int64(exp.CompressionLevel), 0, 22)
+	case exp.CompressionLevel != 0:
+		l.addf("EXPORT_COMPRESSION_LEVEL needs EXPORT_COMPRESSION to name a codec, got level %d with %q", exp.CompressionLevel, exp.Compression)
+	}
+	l.oneOf("EXPORT_AVRO_CODEC", exp.AvroCodec, "null", "deflate")
+	l.atLeast("EXPORT_COHORT_INLINE_MAX", int64(exp.CohortInlineMax), 1)
+	l.atLeast("EXPORT_COHORT_FILE_MAX", int64(exp.CohortFileMax), 1)
+	l.atLeast("EXPORT_INLINE_MAX_ROWS", int64(exp.InlineMaxRows), 0)
+	l.atLeast("EXPORT_FILE_EXPIRY_HOURS", hours(exp.FileExpiry), 0)
+	if exp.FileExpiry > 0 {
+		l.atLeast("EXPORT_EXPIRY_INTERVAL_MINUTES", minutes(exp.ExpiryInterval), 1)
+	}
+	if exp.SchemaRegistryURL != "" {
+		l.validURL("SCHEMA_REGISTRY_URL", exp.SchemaRegistryURL)
+		l.atLeast("SCHEMA_REGISTRY_TIMEOUT_SECONDS", seconds(exp.SchemaRegistryTimeout), 1)
+	}
+
+	w := cfg.Worker
+	l.atLeast("WORKER_QUEUE_SIZE", int64(w.QueueSize), 1)
+	l.atLeast("WORKER_MAX_JOB_PANICS", int64(w.MaxJobPanics), 1)
+	l.atLeast("WORKER_MAX_ATTEMPTS", int64(w.MaxAttempts), 1)
+	l.atLeast("WORKER_RETRY_BACKOFF_SECONDS", seconds(w.RetryBackoff), 0)
+	if w.RetryMaxBackoff < w.RetryBackoff {
+		l.addf("WORKER_RETRY_MAX_BACKOFF_SECONDS (%d) must be at least WORKER_RETRY_BACKOFF_SECONDS (%d)",
+			seconds(w.RetryMaxBackoff), seconds(w.RetryBackoff))
+	}
+	l.atLeast("WORKER_PRIORITY_AGING_SECONDS", seconds(w.PriorityAging), 0)
+	l.atLeast("WORKER_HEARTBEAT_INTERVAL_SECONDS", seconds(w.HeartbeatInterval), 0)
+	if w.HeartbeatInterval > 0 && w.HeartbeatTimeout <= w.HeartbeatInterval {
+		l.addf("WORKER_HEARTBEAT_TIMEOUT_SECONDS (%d) must be longer than WORKER_HEARTBEAT_INTERVAL_SECONDS (%d), or live jobs will look orphaned",
+			seconds(w.HeartbeatTimeout), seconds(w.HeartbeatInterval))
+	}
+
+	st := cfg.Storage
+	l.oneOf("STORAGE_TYPE", st.Type, "", "local", "s3", "azure", "gcs")
+	l.atLeast("STORAGE_SIGNED_URL_TTL_MINUTES", minutes(st.SignedURLTTL), 1)
+	switch st.Type {
+	case "", "local":
+		l.required("STORAGE_PATH", st.LocalPath, "when STORAGE_TYPE is local")
+	case "s3":
+		l.required("AWS_BUCKET", st.S3Bucket, "when STORAGE_TYPE is s3")
+	case "azure":
+		l.required("AZURE_STORAGE_ACCOUNT", st.AzureAccount, "when STORAGE_TYPE is azure")
+		l.required("AZURE_STORAGE_KEY", st.AzureKey, "when STORAGE_TYPE is azure")
+	case "gcs":
+		l.required("GCS_BUCKET", st.GCSBucket, "when STORAGE_TYPE is gcs")
+		l.required("GCS_HMAC_ACCESS_ID", st.GCSAccessID, "when STORAGE_TYPE is gcs")
+		l.required("GCS_HMAC_SECRET", st.GCSSecret, "when STORAGE_TYPE is gcs")
+	}
+
+	if cfg.Prometheus.Enabled {
+		l.between("PROMETHEUS_PORT", int64(cfg.Prometheus.Port), 1, 65535)
+	}
+
+	q := cfg.Quota
+	l.atLeast("QUOTA_JOBS_PER_DAY", int64(q.JobsPerDay), 0)
+	l.atLeast("QUOTA_ROWS_PER_MONTH", q.RowsPerMonth, 0)
+	l.atLeast("QUOTA_EXPORT_STORAGE_BYTES", q.ExportStorageBytes, 0)
+	for _, tenant := range q.TenantAPIKeys {
+		if len(tenant) > models.MaxTenantIDLength {
+			l.addf("TENANT_API_KEYS names a tenant longer than %d characters", models.MaxTenantIDLength)
+			break
+		}
+	}
+
+	if s := cfg.Search; s.Enabled {
+		l.validURL("SEARCH_ENDPOINT", s.Endpoint)
+		l.required("SEARCH_INDEX", s.Index, "when SEARCH_ENABLED is true")
+		l.atLeast("SEARCH_BATCH_SIZE", int64(s.BatchSize), 1)
+		l.atLeast("SEARCH_TIMEOUT_SECONDS", seconds(s.Timeout), 1)
+	}
+
+	if ev := cfg.Events; ev.Driver != "" {
+		l.oneOf("EVENTS_DRIVER", ev.Driver, "redis", "nats")
+		l.required("EVENTS_URL", ev.URL, "when EVENTS_DRIVER is set")
+		l.atLeast("EVENTS_MAX_IDS_PER_EVENT", int64(ev.MaxIDsPerEvent), 1)
+		l.atLeast("EVENTS_TIMEOUT_SECONDS", seconds(ev.Timeout), 1)
+	}
+
+	lg := cfg.Log
+	if len(lg.Outputs) == 0 {
+		l.addf("LOG_OUTPUT must name at least one of console, json, file, syslog")
+	}
+	for _, output := range lg.Outputs {
+		l.oneOf("LOG_OUTPUT", output, "console", "json", "file", "syslog")
+		if output == "file" {
+			l.required("LOG_FILE", lg.FilePath, "when LOG_OUTPUT includes file")
+		}
+	}
+	if lg.Level != "" {
+		l.oneOf("LOG_LEVEL", strings.ToLower(lg.Level), logLevels...)
+	}
+	components := make([]string, 0, len(lg.ComponentLevels))
+	for component := range lg.ComponentLevels {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		l.oneOf("LOG_COMPONENT_LEVELS "+component, strings.ToLower(lg.ComponentLevels[component]), logLevels...)
+	}
+	l.atLeast("LOG_SAMPLE_BURST", int64(lg.SampleBurst), 0)
+	l.atLeast("LOG_SAMPLE_PERIOD_SECONDS", seconds(lg.SamplePeriod), 0)
+	l.atLeast("LOG_SAMPLE_EVERY", int64(lg.SampleEvery), 0)
+	l.atLeast("LOG_JOB_LINES", int64(lg.JobLines), 0)
+
+	if r := cfg.Report; r.RollupEnabled {
+		l.atLeast("REPORT_ROLLUP_INTERVAL_MINUTES", minutes(r.RollupInterval), 1)
+		l.atLeast("REPORT_ROLLUP_DELAY_HOURS", hours(r.RollupDelay), 0)
+	}
+
+	if s := cfg.Signing; s.Enabled {
+		l.atLeast("SIGNING_URL_TTL_SECONDS", seconds(s.URLTTL), 1)
+		l.atLeast("SIGNING_KEY_REFRESH_SECONDS", seconds(s.KeyRefresh), 1)
+	} else if s.RequireSignedDownloads {
+		l.addf("SIGNING_REQUIRE_SIGNED_DOWNLOADS requires SIGNING_ENABLED=true")
+	}
+
+	d := cfg.Delivery
+	l.atLeast("DELIVERY_MAX_ATTEMPTS", int64(d.MaxAttempts), 1)
+	l.atLeast("DELIVERY_BACKOFF_BASE_SECONDS", seconds(d.BackoffBase), 1)
+	l.atLeast("DELIVERY_BACKOFF_MAX_SECONDS", seconds(d.BackoffMax), seconds(d.BackoffBase))
+	l.atLeast("DELIVERY_POLL_INTERVAL_SECONDS", seconds(d.PollInterval), 1)
+	l.atLeast("DELIVERY_BATCH_SIZE", int64(d.BatchSize), 1)
+	l.atLeast("DELIVERY_TIMEOUT_SECONDS", seconds(d.Timeout), 1)
+
+	ttl, err := lookupInt("IDEMPOTENCY_TTL_HOURS", 24)
+	l.check(err)
+	l.atLeast("IDEMPOTENCY_TTL_HOURS", int64(ttl), 1)
+}
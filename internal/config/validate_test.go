@@ -0,0 +1,72 @@
+package config
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+)
+
+func TestLoad_ReportsEveryInvalidSetting(t *testing.T) {
+	setupReload(t, "IMPORT_BATCH_SIZE=0\n")
+	t.Setenv("IMPORT_WORKER_COUNT", "-2")
+	t.Setenv("EXPORT_BATCH_SIZE", "lots")
+	t.Setenv("UI_ENABLED", "maybe")
+	t.Setenv("STORAGE_TYPE", "ftp")
+	t.Setenv("IMPORT_MIN_ROWS", "10")
+	t.Setenv("IMPORT_MAX_ROWS", "5")
+
+	_, err := Load()
+	var verr *ValidationError
+	if !stderrors.As(err, &verr) {
+		t.Fatalf("Load() error = %v, want a *ValidationError", err)
+	}
+
+	want := []string{
+		`UI_ENABLED="maybe" is not true or false`,
+		`EXPORT_BATCH_SIZE="lots" is not a whole number`,
+		"IMPORT_BATCH_SIZE must be at least 1, got 0",
+		"IMPORT_WORKER_COUNT must be at least 1, got -2",
+		"IMPORT_MIN_ROWS (10) must not exceed IMPORT_MAX_ROWS (5)",
+		`STORAGE_TYPE must be one of local, s3, azure, gcs, got "ftp"`,
+	}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("Problems = %q, want %d entries", verr.Problems, len(want))
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("Load() error missing %q:\n%v", w, err)
+		}
+	}
+}
+
+func TestLoad_DefaultsAreValid(t *testing.T) {
+	setupReload(t, "")
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+}
+
+func TestLoad_ConditionalSettings(t *testing.T) {
+	setupReload(t, "")
+	t.Setenv("SEARCH_ENABLED", "true")
+	t.Setenv("SEARCH_ENDPOINT", "localhost:9200")
+	t.Setenv("EVENTS_DRIVER", "kafka")
+	t.Setenv("LOG_OUTPUT", "json,stderr")
+	t.Setenv("LOG_COMPONENT_LEVELS", "import=loud")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load() expected an error")
+	}
+	for _, w := range []string{
+		`SEARCH_ENDPOINT must be an absolute URL`,
+		`EVENTS_DRIVER must be one of redis, nats, got "kafka"`,
+		"EVENTS_URL must be set when EVENTS_DRIVER is set",
+		`LOG_OUTPUT must be one of console, json, file, syslog, got "stderr"`,
+		`LOG_COMPONENT_LEVELS import must be one of`,
+	} {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("Load() error missing %q:\n%v", w, err)
+		}
+	}
+}
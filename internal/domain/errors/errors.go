@@ -12,6 +12,14 @@ const (
 	ErrCodeNotFound            = "NOT_FOUND"
 	ErrCodeConflict            = "CONFLICT"
 	ErrCodeIdempotencyConflict = "IDEMPOTENCY_CONFLICT"
+	ErrCodeRequestTooLarge     = "REQUEST_TOO_LARGE"
+	ErrCodeRequestTimeout      = "REQUEST_TIMEOUT"
+	// ErrCodeDuplicateID rejects a row matched on a key besides its ID
+	// whose ID belongs to another record
+	ErrCodeDuplicateID = "DUPLICATE_ID"
+	// ErrCodeAlreadyImported marks a staged row whose content the import
+	// ledger recorded for a stored record. The row is skipped, not reported.
+	ErrCodeAlreadyImported = "ALREADY_IMPORTED"
 
 	// Validation errors - User
 	ErrCodeInvalidUUID      = "INVALID_UUID"
@@ -22,6 +30,14 @@ const (
 	ErrCodeInvalidBoolean   = "INVALID_BOOLEAN"
 	ErrCodeInvalidTimestamp = "INVALID_TIMESTAMP"
 	ErrCodeMissingField     = "MISSING_FIELD"
+	// ErrCodeDomainNotAllowed rejects a user whose email domain the domain
+	// policy doesn't admit
+	ErrCodeDomainNotAllowed = "DOMAIN_NOT_ALLOWED"
+	// ErrCodeNeedsReview holds back a user that probably duplicates an
+	// earlier row, for review
+	ErrCodeNeedsReview = "NEEDS_REVIEW"
+	// ErrCodeRoleNotPermitted rejects a user whose role the import may not grant
+	ErrCodeRoleNotPermitted = "ROLE_NOT_PERMITTED"
 
 	// Validation errors - Article
 	ErrCodeInvalidSlug        = "INVALID_SLUG"
@@ -35,10 +51,15 @@ const (
 	ErrCodeMissingPublishedAt = "MISSING_PUBLISHED_AT"
 
 	// Validation errors - Comment
-	ErrCodeInvalidArticle = "INVALID_ARTICLE"
-	ErrCodeInvalidUser    = "INVALID_USER"
-	ErrCodeBodyTooLong    = "BODY_TOO_LONG"
-	ErrCodeBodyEmpty      = "BODY_EMPTY"
+	ErrCodeInvalidArticle   = "INVALID_ARTICLE"
+	ErrCodeInvalidUser      = "INVALID_USER"
+	ErrCodeBodyTooLong      = "BODY_TOO_LONG"
+	ErrCodeBodyEmpty        = "BODY_EMPTY"
+	ErrCodeDuplicateComment = "DUPLICATE_COMMENT"
+
+	// Content screening errors - Article and Comment bodies
+	ErrCodeBannedTerm  = "BANNED_TERM"
+	ErrCodePIIDetected = "PII_DETECTED"
 
 	// Foreign key errors
 	ErrCodeFKViolation     = "FK_VIOLATION"
@@ -47,15 +68,57 @@ const (
 	ErrCodeUserNotFound    = "USER_NOT_FOUND"
 
 	// File errors
-	ErrCodeInvalidFileType = "INVALID_FILE_TYPE"
-	ErrCodeFileTooLarge    = "FILE_TOO_LARGE"
-	ErrCodeFileReadError   = "FILE_READ_ERROR"
-	ErrCodeFileParseError  = "FILE_PARSE_ERROR"
+	ErrCodeInvalidFileType   = "INVALID_FILE_TYPE"
+	ErrCodeFileTooLarge      = "FILE_TOO_LARGE"
+	ErrCodeFileReadError     = "FILE_READ_ERROR"
+	ErrCodeFileParseError    = "FILE_PARSE_ERROR"
+	ErrCodeLineTooLong       = "LINE_TOO_LONG"
+	ErrCodeEmptyFile         = "EMPTY_FILE"
+	ErrCodeTooManyDuplicates = "TOO_MANY_DUPLICATES"
+	ErrCodeResourceAmbiguous = "RESOURCE_AMBIGUOUS"
+	ErrCodeChecksumMismatch  = "CHECKSUM_MISMATCH"
+	ErrCodeRowLimitExceeded  = "ROW_LIMIT_EXCEEDED"
+	// ErrCodeUnresolvedPath rejects a nested NDJSON row missing a value one
+	// of the job's field paths points to
+	ErrCodeUnresolvedPath = "UNRESOLVED_PATH"
 
 	// Job errors
 	ErrCodeJobNotFound      = "JOB_NOT_FOUND"
 	ErrCodeJobAlreadyExists = "JOB_ALREADY_EXISTS"
 	ErrCodeJobFailed        = "JOB_FAILED"
+	ErrCodePanic            = "PANIC"
+	ErrCodeJobQuarantined   = "JOB_QUARANTINED"
+	ErrCodeJobOrphaned      = "JOB_ORPHANED"
+	ErrCodeExportExpired    = "EXPORT_EXPIRED"
+
+	// Quota errors
+	ErrCodeQuotaExceeded = "QUOTA_EXCEEDED"
+
+	// Signing errors
+	ErrCodeInvalidSignature = "INVALID_SIGNATURE"
+)
+
+// Warning codes for rows that were imported with a value filled in or cut
+// short
+const (
+	WarnCodeActiveDefaulted    = "ACTIVE_DEFAULTED"
+	WarnCodeCreatedAtDefaulted = "CREATED_AT_DEFAULTED"
+	WarnCodeBodyTruncated      = "BODY_TRUNCATED"
+	WarnCodeBodySanitized      = "BODY_SANITIZED"
+	// WarnCodeRowLimitReached marks where a file was cut off at the row limit
+	WarnCodeRowLimitReached = "ROW_LIMIT_REACHED"
+	// WarnCodeAdminRoleFlagged marks an admin user created by an import that
+	// wasn't elevated, under the flag admin role policy
+	WarnCodeAdminRoleFlagged = "ADMIN_ROLE_FLAGGED"
+	// WarnCodeProbableDuplicate marks a user that probably duplicates an
+	// earlier row under a different email
+	WarnCodeProbableDuplicate = "PROBABLE_DUPLICATE"
+	// WarnCodeContentRedacted marks a body whose banned terms or personal
+	// data content screening replaced
+	WarnCodeContentRedacted = "CONTENT_REDACTED"
+	// WarnCodeContentFlagged marks a body imported as it is although content
+	// screening found banned terms or personal data in it
+	WarnCodeContentFlagged = "CONTENT_FLAGGED"
 )
 
 // AppError represents an application error
@@ -134,7 +197,47 @@ func ErrConflict(message string) *AppError {
 	return NewAppError(ErrCodeConflict, message, 409)
 }
 
+// ErrRequestTooLarge refuses a request body over limit bytes
+func ErrRequestTooLarge(limit int64) *AppError {
+	return NewAppError(ErrCodeRequestTooLarge, fmt.Sprintf("request body too large, max %d bytes", limit), 413)
+}
+
+// ErrRequestTimeout drops a client that sent its request body too slowly
+func ErrRequestTimeout(message string) *AppError {
+	return NewAppError(ErrCodeRequestTimeout, message, 408)
+}
+
+func ErrFileTooLarge(message string) *AppError {
+	return NewAppError(ErrCodeFileTooLarge, message, 413)
+}
+
+func ErrEmptyFile(message string) *AppError {
+	return NewAppError(ErrCodeEmptyFile, message, 400)
+}
+
+func ErrChecksumMismatch(message string) *AppError {
+	return NewAppError(ErrCodeChecksumMismatch, message, 400)
+}
+
+func ErrRowLimitExceeded(message string) *AppError {
+	return NewAppError(ErrCodeRowLimitExceeded, message, 400)
+}
+
+func ErrTooManyDuplicates(message string) *AppError {
+	return NewAppError(ErrCodeTooManyDuplicates, message, 422)
+}
+
 func ErrIdempotencyConflict(existingJobID string) *AppError {
 	return NewAppError(ErrCodeIdempotencyConflict,
 		fmt.Sprintf("Request with this idempotency key already exists (job_id: %s)", existingJobID), 409)
 }
+
+func ErrQuotaExceeded(message string) *AppError {
+	return NewAppError(ErrCodeQuotaExceeded, message, 429)
+}
+
+// ErrInvalidSignature refuses a signed URL that is missing, altered,
+// expired or signed with a retired key
+func ErrInvalidSignature(message string) *AppError {
+	return NewAppError(ErrCodeInvalidSignature, message, 403)
+}
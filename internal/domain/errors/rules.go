@@ -0,0 +1,80 @@
+package errors
+
+import (
+	"strings"
+	"sync"
+)
+
+// RuleDoc tells whoever is fixing an import file what the rule behind an
+// error code expects
+type RuleDoc struct {
+	// Hint explains the rule, with examples of values it accepts
+	Hint string
+	// DocsURL links to a page documenting the rule, overriding the link
+	// built from the configured docs URL; "" keeps that link
+	DocsURL string
+}
+
+var (
+	ruleDocsMu sync.RWMutex
+	ruleDocs   = map[string]RuleDoc{
+		ErrCodeInvalidUUID:        {Hint: "IDs must be UUIDs such as 3f2b8c1e-9a4d-4e5f-8b6a-1c2d3e4f5a6b. Leave the id empty to have one generated."},
+		ErrCodeMissingField:       {Hint: "The field named in field_name is required. Check the column header or JSON key is spelled as in the import format, and that the value isn't blank."},
+		ErrCodeInvalidEmail:       {Hint: "Emails need a local part, an @ and a domain with a dot, such as jane.doe@example.com. Spaces, quotes and display names (Jane <jane@example.com>) aren't accepted."},
+		ErrCodeDuplicateEmail:     {Hint: "The email repeats an earlier row of the file or belongs to another stored user. Keep one row per email, or import with upsert_key=email or on_conflict=upsert to update the stored user."},
+		ErrCodeDuplicateID:        {Hint: "The row's id belongs to a stored record with another email or slug. Remove the id to import the row as a new record, or correct it to the record being updated."},
+		ErrCodeInvalidName:        {Hint: "Names are at most 255 characters, counted as characters rather than bytes, on one line without control characters."},
+		ErrCodeInvalidRole:        {Hint: "Role must be admin, author or reader, in any case."},
+		ErrCodeInvalidBoolean:     {Hint: "Use true or false, in any case. Leave the field empty to default to true."},
+		ErrCodeInvalidTimestamp:   {Hint: "Timestamps are RFC 3339, with a date, time and offset, such as 2024-03-01T12:00:00Z or 2024-03-01T14:00:00+02:00."},
+		ErrCodeDomainNotAllowed:   {Hint: "This service only accepts emails from some domains. Ask the service's operators which domains are allowed."},
+		ErrCodeNeedsReview:        {Hint: "The row probably duplicates the row named in the message. Merge the two, or re-import the row without fuzzy_dedup=review if they are different people."},
+		ErrCodeRoleNotPermitted:   {Hint: "This import may not create admins. Change the role, or ask an administrator to run the import with allow_admin_roles=true."},
+		ErrCodeInvalidSlug:        {Hint: "Slugs are kebab-case: lowercase letters and digits in words joined by single hyphens, at most 255 characters. my-first-post and release-2-0 are valid; My Post, my_post and -draft- are not."},
+		ErrCodeDuplicateSlug:      {Hint: "The slug repeats an earlier row of the file or belongs to another stored article. Give each article its own slug, or import with upsert_key=slug or on_conflict=upsert to update the stored article."},
+		ErrCodeInvalidTitle:       {Hint: "Titles are at most 500 characters on one line without control characters."},
+		ErrCodeInvalidBody:        {Hint: "Bodies may contain line breaks and tabs but no other control characters. Import with sanitize=true to strip them."},
+		ErrCodeInvalidAuthor:      {Hint: "author_id must be the UUID of a user, such as 3f2b8c1e-9a4d-4e5f-8b6a-1c2d3e4f5a6b."},
+		ErrCodeInvalidTags:        {Hint: "Tags are a list of at most 100 strings, each at most 50 characters on one line, such as [\"go\", \"databases\"]."},
+		ErrCodeInvalidStatus:      {Hint: "Status must be draft, published or archived."},
+		ErrCodeDraftWithPublished: {Hint: "Drafts haven't been published, so leave published_at empty or set the status to published."},
+		ErrCodeMissingPublishedAt: {Hint: "Published articles need a published_at timestamp, such as 2024-03-01T12:00:00Z."},
+		ErrCodeInvalidArticle:     {Hint: "article_id must be the UUID of an article, such as 3f2b8c1e-9a4d-4e5f-8b6a-1c2d3e4f5a6b."},
+		ErrCodeInvalidUser:        {Hint: "user_id must be the UUID of a user, such as 3f2b8c1e-9a4d-4e5f-8b6a-1c2d3e4f5a6b."},
+		ErrCodeBodyTooLong:        {Hint: "The body is longer than this service accepts. Shorten it, or split it across several records."},
+		ErrCodeBodyEmpty:          {Hint: "Comments need a body with some text besides whitespace."},
+		ErrCodeDuplicateComment:   {Hint: "The comment repeats an earlier row or a stored comment with the same article, user, body and created_at. Remove the repeat, or give the row the stored comment's id to update it."},
+		ErrCodeBannedTerm:         {Hint: "The body contains a term this service doesn't accept. Reword it; the message counts the terms found without repeating them."},
+		ErrCodePIIDetected:        {Hint: "The body looks like it contains a credit card number or social security number. Remove the personal data before importing."},
+		ErrCodeFKViolation:        {Hint: "The row refers to a record that doesn't exist. Import the referenced records first."},
+		ErrCodeAuthorNotFound:     {Hint: "No user has this author_id. Import users before their articles, or create the articles with create_missing_authors=true."},
+		ErrCodeArticleNotFound:    {Hint: "No article has this article_id. Import articles before their comments."},
+		ErrCodeUserNotFound:       {Hint: "No user has this user_id. Import users before their comments."},
+		ErrCodeFileParseError:     {Hint: "The row couldn't be read. Check its quoting in CSV, or that it is one complete JSON object on its own line in NDJSON."},
+		ErrCodeLineTooLong:        {Hint: "The line is longer than this service reads. Check for a missing line break or an unclosed quote joining several rows."},
+		ErrCodeUnresolvedPath:     {Hint: "A path in field_paths leads to a nested value the row doesn't have. Check the path against the row, or give the row the missing object."},
+	}
+)
+
+// RegisterRuleDoc sets the documentation shown with errors of code,
+// replacing any it had, so deployments can explain their own rules or link
+// their own guides
+func RegisterRuleDoc(code string, doc RuleDoc) {
+	ruleDocsMu.Lock()
+	defer ruleDocsMu.Unlock()
+	ruleDocs[code] = doc
+}
+
+// LookupRuleDoc returns the documentation of the rule behind code
+func LookupRuleDoc(code string) (RuleDoc, bool) {
+	ruleDocsMu.RLock()
+	defer ruleDocsMu.RUnlock()
+	doc, ok := ruleDocs[code]
+	return doc, ok
+}
+
+// RuleAnchor is the fragment naming code on a rules docs page, such as
+// invalid-slug for INVALID_SLUG
+func RuleAnchor(code string) string {
+	return strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
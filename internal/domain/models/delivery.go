@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeliveryStatus is the state of a queued notification delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending waits for its next attempt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered was accepted by its target
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDeadLetter failed every attempt and waits to be requeued
+	DeliveryDeadLetter DeliveryStatus = "dead_letter"
+)
+
+// Delivery is a notification queued for a channel, such as an alert POSTed
+// to a webhook. Failed attempts are retried with backoff until MaxAttempts.
+type Delivery struct {
+	ID      uuid.UUID `json:"id" db:"id"`
+	Channel string    `json:"channel" db:"channel"`
+	// Target is where the channel sends the payload, such as a webhook
+	// URL. URLs often carry a token, so it isn't shown.
+	Target        string          `json:"-" db:"target"`
+	Event         string          `json:"event" db:"event"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Status        DeliveryStatus  `json:"status" db:"status"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	MaxAttempts   int             `json:"max_attempts" db:"max_attempts"`
+	LastError     *string         `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+}
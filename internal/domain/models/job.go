@@ -1,6 +1,11 @@
 package models
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +17,8 @@ type JobType string
 const (
 	JobTypeImport JobType = "import"
 	JobTypeExport JobType = "export"
+	// JobTypeIndex syncs records written by an import into the search index
+	JobTypeIndex JobType = "index"
 )
 
 // JobStatus represents the status of a job
@@ -23,8 +30,24 @@ const (
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
 	JobStatusCancelled  JobStatus = "cancelled"
+	// JobStatusDeadLetter is a job that failed on every attempt it was
+	// allowed; it waits for an operator to requeue it
+	JobStatusDeadLetter JobStatus = "dead_letter"
+	// JobStatusPaused is an import the circuit breaker stopped after its
+	// batch inserts kept failing; it waits for an operator to resume it
+	JobStatusPaused JobStatus = "paused"
 )
 
+// ErrJobCancelled is the cause of the context of a job run that was
+// cancelled. The job is already recorded as cancelled, so the run stops
+// without recording a failure.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// JobCancelled reports whether ctx was cancelled because its job was
+func JobCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrJobCancelled)
+}
+
 // ResourceType represents the resource being imported/exported
 type ResourceType string
 
@@ -34,27 +57,156 @@ const (
 	ResourceTypeComments ResourceType = "comments"
 )
 
+// CommentDedup selects how a comment import detects duplicate rows
+type CommentDedup string
+
+const (
+	// CommentDedupID treats rows sharing an ID as duplicates (default)
+	CommentDedupID CommentDedup = "id"
+	// CommentDedupNaturalKey also treats rows with the same article, user,
+	// normalised body and created_at as duplicates, within the file and
+	// against stored comments
+	CommentDedupNaturalKey CommentDedup = "natural_key"
+)
+
+// UserFuzzyDedup selects what a user import does with rows that probably
+// repeat an earlier row under a slightly different email
+type UserFuzzyDedup string
+
+const (
+	// UserFuzzyDedupWarn imports probable duplicates with a warning
+	UserFuzzyDedupWarn UserFuzzyDedup = "warn"
+	// UserFuzzyDedupReview holds probable duplicates back as NEEDS_REVIEW
+	// errors instead of importing them
+	UserFuzzyDedupReview UserFuzzyDedup = "review"
+)
+
+// UpsertKey is the column an import matches its rows to stored records on.
+// A row whose key is taken updates that record; the rest are inserted.
+type UpsertKey string
+
+const (
+	// UpsertKeyID matches rows on their ID (default)
+	UpsertKeyID UpsertKey = "id"
+	// UpsertKeyEmail matches user rows on their email, keeping the stored
+	// user's ID
+	UpsertKeyEmail UpsertKey = "email"
+	// UpsertKeySlug matches article rows on their slug, keeping the stored
+	// article's ID
+	UpsertKeySlug UpsertKey = "slug"
+)
+
+// UpsertKeys are the keys each resource can be upserted on
+var UpsertKeys = map[ResourceType][]UpsertKey{
+	ResourceTypeUsers:    {UpsertKeyID, UpsertKeyEmail},
+	ResourceTypeArticles: {UpsertKeyID, UpsertKeySlug},
+	ResourceTypeComments: {UpsertKeyID},
+}
+
+// OnConflict selects what an import does with a row whose natural key, a
+// user's email or an article's slug, belongs to another stored record
+type OnConflict string
+
+const (
+	// OnConflictSkip rejects the row as a duplicate (default)
+	OnConflictSkip OnConflict = "skip"
+	// OnConflictUpsert updates the stored record with the fields the row
+	// gives, keeping its values for the rest
+	OnConflictUpsert OnConflict = "upsert"
+	// OnConflictReplace overwrites the stored record with the row, resetting
+	// the fields the row leaves out
+	OnConflictReplace OnConflict = "replace"
+)
+
+// Overwrites reports whether conflicting rows update the record they
+// conflict with instead of being rejected
+func (o OnConflict) Overwrites() bool {
+	return o == OnConflictUpsert || o == OnConflictReplace
+}
+
+// ExportGroupBy selects how an export nests its records
+type ExportGroupBy string
+
+const (
+	// ExportGroupByArticle exports comments as one record per article with
+	// the article's comments embedded
+	ExportGroupByArticle ExportGroupBy = "article"
+)
+
+// ExportCompression is the codec an export file is compressed with
+type ExportCompression string
+
+const (
+	// ExportCompressionNone writes plain NDJSON (default)
+	ExportCompressionNone ExportCompression = "none"
+	// ExportCompressionGzip writes gzip-compressed NDJSON
+	ExportCompressionGzip ExportCompression = "gzip"
+	// ExportCompressionZstd writes zstd-compressed NDJSON
+	ExportCompressionZstd ExportCompression = "zstd"
+)
+
+// JobPriority orders queued jobs of the same type
+type JobPriority string
+
+const (
+	JobPriorityLow    JobPriority = "low"
+	JobPriorityNormal JobPriority = "normal"
+	JobPriorityHigh   JobPriority = "high"
+)
+
+// Valid reports whether p is a known priority. Empty means normal.
+func (p JobPriority) Valid() bool {
+	switch p {
+	case "", JobPriorityLow, JobPriorityNormal, JobPriorityHigh:
+		return true
+	}
+	return false
+}
+
 // Job represents an import or export job
 type Job struct {
 	ID                uuid.UUID    `json:"id" db:"id"`
 	Type              JobType      `json:"type" db:"type"`
 	Resource          ResourceType `json:"resource" db:"resource"`
 	Status            JobStatus    `json:"status" db:"status"`
+	TenantID          string       `json:"tenant_id" db:"tenant_id"`
+	ParentJobID       *uuid.UUID   `json:"parent_job_id,omitempty" db:"parent_job_id"`
+	Params            *JobParams   `json:"params,omitempty" db:"params"`
+	Summary           *JobSummary  `json:"summary,omitempty" db:"summary"`
 	IdempotencyKey    *string      `json:"idempotency_key,omitempty" db:"idempotency_key"`
 	FilePath          *string      `json:"file_path,omitempty" db:"file_path"`
 	FileURL           *string      `json:"file_url,omitempty" db:"file_url"`
 	FileFormat        *string      `json:"file_format,omitempty" db:"file_format"`
+	FileSizeBytes     int64        `json:"file_size_bytes" db:"file_size_bytes"`
 	TotalRecords      int          `json:"total_records" db:"total_records"`
 	ProcessedRecords  int          `json:"processed_records" db:"processed_records"`
 	SuccessfulRecords int          `json:"successful_records" db:"successful_records"`
 	FailedRecords     int          `json:"failed_records" db:"failed_records"`
+	WarningCount      int          `json:"warning_count" db:"warning_count"`
+	DuplicateRecords  int          `json:"duplicate_records" db:"duplicate_records"`
+	LogLinesDropped   int          `json:"log_lines_dropped" db:"log_lines_dropped"`
+	Attempts          int          `json:"attempts" db:"attempts"`
 	ErrorMessage      *string      `json:"error_message,omitempty" db:"error_message"`
+	Phase             *string      `json:"phase,omitempty" db:"phase"`
+	DataAsOf          *time.Time   `json:"data_as_of,omitempty" db:"data_as_of"`
 	StartedAt         *time.Time   `json:"started_at,omitempty" db:"started_at"`
+	HeartbeatAt       *time.Time   `json:"heartbeat_at,omitempty" db:"heartbeat_at"`
+	WorkerInstance    *string      `json:"worker_instance,omitempty" db:"worker_instance"`
+	WorkerID          *string      `json:"worker_id,omitempty" db:"worker_id"`
+	WorkerVersion     *string      `json:"worker_version,omitempty" db:"worker_version"`
 	CompletedAt       *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
 	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time    `json:"updated_at" db:"updated_at"`
 }
 
+// JobWorker identifies what ran a job: the instance, the worker on it and
+// the build it was running
+type JobWorker struct {
+	Instance string
+	WorkerID string
+	Version  string
+}
+
 // JobError represents an error that occurred during job processing
 type JobError struct {
 	ID               uuid.UUID `json:"id" db:"id"`
@@ -69,6 +221,55 @@ type JobError struct {
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 }
 
+// JobWarning records a row that was imported but not exactly as supplied,
+// such as a defaulted field
+type JobWarning struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	JobID            uuid.UUID `json:"job_id" db:"job_id"`
+	RowNumber        int       `json:"row_number" db:"row_number"`
+	RecordIdentifier *string   `json:"record_identifier,omitempty" db:"record_identifier"`
+	FieldName        *string   `json:"field_name,omitempty" db:"field_name"`
+	WarningCode      string    `json:"warning_code" db:"warning_code"`
+	WarningMessage   string    `json:"warning_message" db:"warning_message"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// JobLog is a log line written while the job ran
+type JobLog struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	JobID      uuid.UUID `json:"job_id" db:"job_id"`
+	LineNumber int       `json:"line_number" db:"line_number"`
+	Level      string    `json:"level" db:"level"`
+	Message    string    `json:"message" db:"message"`
+	// Fields holds the line's other fields as a JSON object
+	Fields   *string   `json:"fields,omitempty" db:"fields"`
+	LoggedAt time.Time `json:"logged_at" db:"logged_at"`
+}
+
+// JobTimelineKind is what a job timeline entry records
+type JobTimelineKind string
+
+const (
+	// JobTimelineNote is a note an operator added to the job
+	JobTimelineNote JobTimelineKind = "note"
+	// JobTimelineStatus is an operator marking the job failed or cancelled
+	JobTimelineStatus JobTimelineKind = "status_change"
+)
+
+// JobTimelineEntry is an operator's note on a job or manual change of its
+// status. FromStatus and ToStatus are set for status changes, where Message
+// is the reason.
+type JobTimelineEntry struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	JobID      uuid.UUID       `json:"job_id" db:"job_id"`
+	Kind       JobTimelineKind `json:"kind" db:"kind"`
+	Message    string          `json:"message" db:"message"`
+	FromStatus *JobStatus      `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   *JobStatus      `json:"to_status,omitempty" db:"to_status"`
+	Author     *string         `json:"author,omitempty" db:"author"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
 // IdempotencyKey represents an idempotency key record
 type IdempotencyKey struct {
 	Key          string    `json:"key" db:"idempotency_key"`
@@ -79,6 +280,40 @@ type IdempotencyKey struct {
 	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
 }
 
+// LedgerEntry records the content an import last wrote under the natural key
+// of a record, so importing the same content again can be skipped
+type LedgerEntry struct {
+	Resource    ResourceType `json:"resource" db:"resource"`
+	NaturalKey  string       `json:"natural_key" db:"natural_key"`
+	ContentHash string       `json:"content_hash" db:"content_hash"`
+	JobID       uuid.UUID    `json:"job_id" db:"job_id"`
+	RecordedAt  time.Time    `json:"recorded_at" db:"recorded_at"`
+}
+
+// BatchCommit records a batch an import wrote to the main table. It is
+// written in the batch's transaction, so the log of a job holds exactly the
+// rows that committed.
+type BatchCommit struct {
+	JobID uuid.UUID `json:"-" db:"job_id"`
+	// Attempt is the run of the job that wrote the batch, from 1. A job run
+	// again, after a retry, requeue, resume or recovery, writes its batches
+	// under the next attempt.
+	Attempt int `json:"attempt" db:"attempt"`
+	// BatchNo numbers the batches of an attempt from 1
+	BatchNo int `json:"batch_no" db:"batch_no"`
+	// FirstStagingID and LastStagingID bound the staged rows of the batch.
+	// They are nil for rows the fast path kept in memory.
+	FirstStagingID *int64 `json:"first_staging_id,omitempty" db:"first_staging_id"`
+	LastStagingID  *int64 `json:"last_staging_id,omitempty" db:"last_staging_id"`
+	// Rows counts the staged rows of the batch, and Inserted and Updated the
+	// records it created and overwrote. Rows dropped as duplicates while the
+	// batch was written are in neither.
+	Rows        int       `json:"rows" db:"rows"`
+	Inserted    int       `json:"inserted" db:"inserted"`
+	Updated     int       `json:"updated" db:"updated"`
+	CommittedAt time.Time `json:"committed_at" db:"committed_at"`
+}
+
 // JobProgress represents the progress of a job
 type JobProgress struct {
 	TotalRecords      int     `json:"total_records"`
@@ -119,16 +354,122 @@ type CreateJobRequest struct {
 	FileURL        *string      `json:"file_url,omitempty"`
 }
 
+// JobListFilter selects the jobs returned by a job listing. Empty fields
+// match any job.
+type JobListFilter struct {
+	TenantID string
+	Type     JobType
+	Status   JobStatus
+}
+
+// JobSummary breaks down the outcome of an import
+type JobSummary struct {
+	// RejectedDomains counts the users rejected with DOMAIN_NOT_ALLOWED by
+	// the domain of their email
+	RejectedDomains map[string]int `json:"rejected_domains,omitempty"`
+	// ProbableDuplicates counts users flagged or held back by fuzzy dedup
+	ProbableDuplicates int `json:"probable_duplicates,omitempty"`
+	// Analysis is what an analyze import found it would write
+	Analysis *ImportAnalysis `json:"analysis,omitempty"`
+	// SuppressedErrors counts, by code, the errors not stored because the
+	// job already had IMPORT_MAX_ERRORS_PER_CODE of that code
+	SuppressedErrors map[string]int `json:"suppressed_errors,omitempty"`
+	// PlaceholderAuthors counts the placeholder users an article import
+	// with CreateMissingAuthors created, or would create when it analyzes
+	PlaceholderAuthors int `json:"placeholder_authors,omitempty"`
+	// AlreadyImported counts the rows skipped because the import ledger
+	// recorded their content for a stored record. AllAlreadyImported is set
+	// when that was every row of the file, so the import wrote nothing.
+	AlreadyImported    int  `json:"already_imported,omitempty"`
+	AllAlreadyImported bool `json:"all_already_imported,omitempty"`
+	// Inserted and Updated split the records the import wrote into those it
+	// created and those it overwrote. They alone make a summary only once a
+	// record was overwritten.
+	Inserted int `json:"inserted,omitempty"`
+	Updated  int `json:"updated,omitempty"`
+}
+
+// ImportAnalysis reports what an import run with JobParams.Analyze would
+// have done to the main table, which it leaves untouched
+type ImportAnalysis struct {
+	// Inserts and Updates count the rows that would create a record or
+	// overwrite the record with their ID; Skipped counts the rest, rejected
+	// by validation, duplicate or foreign key checks
+	Inserts int `json:"inserts"`
+	Updates int `json:"updates"`
+	Skipped int `json:"skipped"`
+	// Table is the table's size now and Estimated its size after the
+	// inserts, scaled from its current bytes per row. Estimated is nil for
+	// an empty table.
+	Table     *TableSize `json:"table,omitempty"`
+	Estimated *TableSize `json:"estimated,omitempty"`
+}
+
+// TableSize is the size of a main table
+type TableSize struct {
+	Rows       int64 `json:"rows"`
+	TableBytes int64 `json:"table_bytes"`
+	IndexBytes int64 `json:"index_bytes"`
+}
+
+// Grow estimates the size of the table with rows more rows of the average
+// size, or returns nil when the table has no rows to average
+func (t *TableSize) Grow(rows int) *TableSize {
+	if t == nil || t.Rows <= 0 {
+		return nil
+	}
+	return &TableSize{
+		Rows:       t.Rows + int64(rows),
+		TableBytes: t.TableBytes + t.TableBytes*int64(rows)/t.Rows,
+		IndexBytes: t.IndexBytes + t.IndexBytes*int64(rows)/t.Rows,
+	}
+}
+
+// Value implements driver.Valuer, storing the summary as JSON
+func (s JobSummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements sql.Scanner
+func (s *JobSummary) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into JobSummary", src)
+	}
+}
+
 // ExportFilters represents filters for export
 type ExportFilters struct {
-	Status        *string    `json:"status,omitempty"`
-	Role          *string    `json:"role,omitempty"`
-	Active        *bool      `json:"active,omitempty"`
-	CreatedAfter  *time.Time `json:"created_after,omitempty"`
-	CreatedBefore *time.Time `json:"created_before,omitempty"`
-	AuthorID      *uuid.UUID `json:"author_id,omitempty"`
-	ArticleID     *uuid.UUID `json:"article_id,omitempty"`
-	UserID        *uuid.UUID `json:"user_id,omitempty"`
+	Status          *string    `json:"status,omitempty"`
+	Role            *string    `json:"role,omitempty"`
+	Active          *bool      `json:"active,omitempty"`
+	CreatedAfter    *time.Time `json:"created_after,omitempty"`
+	CreatedBefore   *time.Time `json:"created_before,omitempty"`
+	UpdatedAfter    *time.Time `json:"updated_after,omitempty"`
+	UpdatedBefore   *time.Time `json:"updated_before,omitempty"`
+	PublishedAfter  *time.Time `json:"published_after,omitempty"`
+	PublishedBefore *time.Time `json:"published_before,omitempty"`
+	HasTags         *bool      `json:"has_tags,omitempty"`
+	AuthorID        *uuid.UUID `json:"author_id,omitempty"`
+	ArticleID       *uuid.UUID `json:"article_id,omitempty"`
+	UserID          *uuid.UUID `json:"user_id,omitempty"`
+	Lang            *string    `json:"lang,omitempty"`
+	// ImportedByJob limits an export to the records the import job last wrote
+	ImportedByJob *uuid.UUID `json:"imported_by_job,omitempty"`
+	// Placeholder limits a user export to placeholder authors, or to real
+	// users when false
+	Placeholder *bool `json:"placeholder,omitempty"`
+	// IDs and Emails limit a user export to a cohort of users; at most one
+	// is set. Emails are lowercase.
+	IDs    []uuid.UUID `json:"ids,omitempty"`
+	Emails []string    `json:"emails,omitempty"`
+	// CohortFile is the storage key of a cohort too large to keep with the
+	// job, read into IDs or Emails when the export runs
+	CohortFile string `json:"cohort_file,omitempty"`
 }
 
 // ExportRequest represents a request to create an export job
@@ -138,3 +479,157 @@ type ExportRequest struct {
 	Filters  *ExportFilters `json:"filters,omitempty"`
 	Fields   []string       `json:"fields,omitempty"`
 }
+
+// JobParams records the request a job was created from
+type JobParams struct {
+	Format string `json:"format,omitempty"`
+	// Priority orders the job among queued jobs of its type
+	Priority JobPriority `json:"priority,omitempty"`
+
+	// Import parameters
+	FileName     string       `json:"file_name,omitempty"`
+	FileURL      string       `json:"file_url,omitempty"`
+	Profile      bool         `json:"profile,omitempty"`
+	CommentDedup CommentDedup `json:"comment_dedup,omitempty"`
+	// Sanitize cleans article and comment bodies before they are validated
+	Sanitize bool `json:"sanitize,omitempty"`
+	// Analyze runs the import through its duplicate and foreign key checks
+	// and reports what it would write instead of writing it
+	Analyze bool `json:"analyze,omitempty"`
+	// DetectLang tags article and comment bodies with their language
+	DetectLang bool `json:"detect_lang,omitempty"`
+	// AllowAdminRoles lets a user import create admin users whatever the
+	// admin role policy; only admin-token requests may set it
+	AllowAdminRoles bool `json:"allow_admin_roles,omitempty"`
+	// FuzzyDedup checks user imports for near-duplicate users; off when empty
+	FuzzyDedup UserFuzzyDedup `json:"fuzzy_dedup,omitempty"`
+	// FieldPaths reads import fields from JSON paths in nested NDJSON
+	// documents, keyed by field
+	FieldPaths map[string]string `json:"field_paths,omitempty"`
+	// CreateMissingAuthors creates an inactive placeholder user for each
+	// article author_id that isn't a user, instead of rejecting the article
+	CreateMissingAuthors bool `json:"create_missing_authors,omitempty"`
+	// UpsertKey is the column rows are matched to stored records on; ID
+	// when empty
+	UpsertKey UpsertKey `json:"upsert_key,omitempty"`
+	// OnConflict is what rows whose email or slug belongs to another stored
+	// record do; skip when empty
+	OnConflict OnConflict `json:"on_conflict,omitempty"`
+	// IgnoreLedger writes rows the import ledger has already recorded
+	// instead of skipping them; they are still recorded again
+	IgnoreLedger bool `json:"ignore_ledger,omitempty"`
+
+	// Export parameters
+	Filters *ExportFilters `json:"filters,omitempty"`
+	Fields  []string       `json:"fields,omitempty"`
+	Diff    *DiffRange     `json:"diff,omitempty"`
+	GroupBy ExportGroupBy  `json:"group_by,omitempty"`
+	// FieldAliases renames exported fields, keyed by field
+	FieldAliases map[string]string `json:"field_aliases,omitempty"`
+	// WithCounts adds article and comment counts to user exports
+	WithCounts bool `json:"with_counts,omitempty"`
+	// Compression and CompressionLevel choose how the export file is
+	// compressed; a zero level is the codec's default
+	Compression      ExportCompression `json:"compression,omitempty"`
+	CompressionLevel int               `json:"compression_level,omitempty"`
+}
+
+// Value implements driver.Valuer, storing the params as JSON
+func (p JobParams) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner
+func (p *JobParams) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("cannot scan %T into JobParams", src)
+	}
+}
+
+// ExportManifest describes a completed export file
+type ExportManifest struct {
+	JobID       uuid.UUID    `json:"job_id"`
+	Resource    ResourceType `json:"resource"`
+	Format      string       `json:"format"`
+	FileName    string       `json:"file_name"`
+	RecordCount int          `json:"record_count"`
+	SizeBytes   int64        `json:"size_bytes"`
+	// SHA256 is the hex digest of FileName, so a signed manifest vouches
+	// for the file too
+	SHA256     string         `json:"sha256,omitempty"`
+	DataAsOf   time.Time      `json:"data_as_of"`
+	Consistent bool           `json:"consistent"`
+	Filters    *ExportFilters `json:"filters,omitempty"`
+	Diff       *DiffRange     `json:"diff,omitempty"`
+	GroupBy    ExportGroupBy  `json:"group_by,omitempty"`
+	WithCounts bool           `json:"with_counts,omitempty"`
+	// Fields and FieldAliases are the fields the records were cut down to
+	// and the names they were renamed to
+	Fields       []string          `json:"fields,omitempty"`
+	FieldAliases map[string]string `json:"field_aliases,omitempty"`
+	// Compression is the codec FileName is compressed with, if any
+	Compression ExportCompression `json:"compression,omitempty"`
+	// SchemaSubject and SchemaID identify the schema of an Avro export in
+	// the schema registry, when one is configured
+	SchemaSubject string `json:"schema_subject,omitempty"`
+	SchemaID      int    `json:"schema_id,omitempty"`
+	// Bundle describes the resources of a bundle export
+	Bundle    *BundleManifest `json:"bundle,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// BundleManifest counts the records of each resource in a bundle export and
+// reports whether the references between them resolve within it
+type BundleManifest struct {
+	Counts  map[ResourceType]int `json:"counts"`
+	Closure BundleClosure        `json:"closure"`
+}
+
+// BundleClosure counts the references in a bundle to records it doesn't
+// hold. Closed is set when there are none.
+type BundleClosure struct {
+	Closed bool `json:"closed"`
+	// MissingAuthors counts articles whose author isn't in the bundle
+	MissingAuthors int `json:"missing_authors"`
+	// MissingArticles and MissingUsers count comments whose article or
+	// user isn't in the bundle
+	MissingArticles int `json:"missing_articles"`
+	MissingUsers    int `json:"missing_users"`
+}
+
+// DiffOp describes how a record changed between two points in time
+type DiffOp string
+
+const (
+	DiffOpAdded   DiffOp = "added"
+	DiffOpUpdated DiffOp = "updated"
+	DiffOpDeleted DiffOp = "deleted"
+)
+
+// DiffRange bounds a diff export: changes in (From, To]
+type DiffRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// DiffRecord is a single line of a diff export
+type DiffRecord struct {
+	Op       DiffOp       `json:"op"`
+	Resource ResourceType `json:"resource"`
+	ID       uuid.UUID    `json:"id"`
+	Record   interface{}  `json:"record,omitempty"`
+	At       time.Time    `json:"at"`
+}
+
+// Tombstone records the deletion of a row
+type Tombstone struct {
+	ID        int64        `db:"id"`
+	Resource  ResourceType `db:"resource"`
+	RecordID  uuid.UUID    `db:"record_id"`
+	DeletedAt time.Time    `db:"deleted_at"`
+}
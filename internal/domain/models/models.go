@@ -9,13 +9,23 @@ import (
 
 // User represents a user entity
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Name      string    `json:"name" db:"name"`
-	Role      string    `json:"role" db:"role"`
-	Active    bool      `json:"active" db:"active"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Email       string     `json:"email" db:"email"`
+	Name        string     `json:"name" db:"name"`
+	Role        string     `json:"role" db:"role"`
+	Active      bool       `json:"active" db:"active"`
+	Placeholder bool       `json:"placeholder,omitempty" db:"placeholder"`     // created for a missing article author, to be reconciled
+	ImportJobID *uuid.UUID `json:"import_job_id,omitempty" db:"import_job_id"` // import job that last wrote the user
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// UserWithCounts is a user exported with the number of articles and comments
+// they have written
+type UserWithCounts struct {
+	User
+	ArticleCount int64 `json:"article_count" db:"article_count"`
+	CommentCount int64 `json:"comment_count" db:"comment_count"`
 }
 
 // UserImport represents user data during import (before validation)
@@ -29,6 +39,9 @@ type UserImport struct {
 	UpdatedAt string `json:"updated_at" csv:"updated_at"`
 }
 
+// UserRoleAdmin is the role with administrative rights
+const UserRoleAdmin = "admin"
+
 // AllowedUserRoles defines valid user roles
 var AllowedUserRoles = map[string]bool{
 	"admin":  true,
@@ -46,6 +59,8 @@ type Article struct {
 	Tags        json.RawMessage `json:"tags" db:"tags"`
 	PublishedAt *time.Time      `json:"published_at,omitempty" db:"published_at"`
 	Status      string          `json:"status" db:"status"`
+	Lang        *string         `json:"lang,omitempty" db:"lang"`                   // detected ISO 639-1 language of the body
+	ImportJobID *uuid.UUID      `json:"import_job_id,omitempty" db:"import_job_id"` // import job that last wrote the article
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
 }
@@ -71,12 +86,20 @@ var AllowedArticleStatuses = map[string]bool{
 
 // Comment represents a comment entity
 type Comment struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	ArticleID uuid.UUID `json:"article_id" db:"article_id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Body      string    `json:"body" db:"body"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ArticleID   uuid.UUID  `json:"article_id" db:"article_id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Body        string     `json:"body" db:"body"`
+	Lang        *string    `json:"lang,omitempty" db:"lang"`                   // detected ISO 639-1 language of the body
+	ImportJobID *uuid.UUID `json:"import_job_id,omitempty" db:"import_job_id"` // import job that last wrote the comment
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ArticleComments is an article's comments exported as a single record
+type ArticleComments struct {
+	ArticleID uuid.UUID  `json:"article_id"`
+	Comments  []*Comment `json:"comments"`
 }
 
 // CommentImport represents comment data during import
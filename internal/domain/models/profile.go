@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportProfile holds per-column statistics gathered from an import file
+type ImportProfile struct {
+	JobID     uuid.UUID        `json:"job_id"`
+	Format    string           `json:"format"`
+	Rows      int              `json:"rows"`
+	Columns   []*ColumnProfile `json:"columns"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ColumnProfile describes the values seen in one column. DistinctEstimate and
+// TopValues are approximate for high-cardinality columns.
+type ColumnProfile struct {
+	Name             string       `json:"name"`
+	NullCount        int          `json:"null_count"`
+	NullRate         float64      `json:"null_rate"`
+	DistinctEstimate int64        `json:"distinct_estimate"`
+	MinLength        int          `json:"min_length"`
+	MaxLength        int          `json:"max_length"`
+	TopValues        []ValueCount `json:"top_values"`
+	MinTimestamp     *time.Time   `json:"min_timestamp,omitempty"`
+	MaxTimestamp     *time.Time   `json:"max_timestamp,omitempty"`
+	TimestampCount   int          `json:"timestamp_count,omitempty"`
+}
+
+// ValueCount is a value and how often it occurred
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
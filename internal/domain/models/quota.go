@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// DefaultTenantID is used when a request carries no tenant or API key
+const DefaultTenantID = "default"
+
+// MaxTenantIDLength is the longest tenant ID, the size of jobs.tenant_id
+const MaxTenantIDLength = 255
+
+// QuotaLimits represents the configured limits for a tenant (0 means unlimited)
+type QuotaLimits struct {
+	JobsPerDay         int   `json:"jobs_per_day"`
+	RowsPerMonth       int64 `json:"rows_per_month"`
+	ExportStorageBytes int64 `json:"export_storage_bytes"`
+}
+
+// QuotaUsage represents the current consumption of a tenant
+type QuotaUsage struct {
+	JobsToday          int   `json:"jobs_today" db:"jobs_today"`
+	RowsThisMonth      int64 `json:"rows_this_month" db:"rows_this_month"`
+	ExportStorageBytes int64 `json:"export_storage_bytes" db:"export_storage_bytes"`
+}
+
+// QuotaStatus combines limits and usage for a tenant
+type QuotaStatus struct {
+	TenantID string      `json:"tenant_id"`
+	Enabled  bool        `json:"enabled"`
+	Limits   QuotaLimits `json:"limits"`
+	Usage    QuotaUsage  `json:"usage"`
+	ResetsAt struct {
+		Jobs time.Time `json:"jobs"`
+		Rows time.Time `json:"rows"`
+	} `json:"resets_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SigningKey is an HMAC key the service signs artifacts with. The newest key
+// signs; every stored key verifies.
+type SigningKey struct {
+	ID        string    `json:"id" db:"id"`
+	Secret    []byte    `json:"-" db:"secret"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
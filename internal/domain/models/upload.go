@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadSessionStatus is the state of a resumable upload
+type UploadSessionStatus string
+
+const (
+	// UploadSessionOpen accepts parts until it is completed or expires
+	UploadSessionOpen UploadSessionStatus = "open"
+	// UploadSessionCompleted has been assembled into the file of an import
+	// job
+	UploadSessionCompleted UploadSessionStatus = "completed"
+)
+
+// UploadSession is a file uploaded in numbered parts, which completing the
+// session assembles in order and imports
+type UploadSession struct {
+	ID       uuid.UUID           `json:"id" db:"id"`
+	TenantID string              `json:"tenant_id" db:"tenant_id"`
+	FileName string              `json:"file_name" db:"file_name"`
+	Status   UploadSessionStatus `json:"status" db:"status"`
+	// JobID is the import job created when the session was completed
+	JobID     *uuid.UUID `json:"job_id,omitempty" db:"job_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+}
+
+// UploadPart is a received part of an upload session. A part sent again
+// replaces it.
+type UploadPart struct {
+	SessionID  uuid.UUID `json:"-" db:"session_id"`
+	PartNumber int       `json:"part_number" db:"part_number"`
+	SizeBytes  int64     `json:"size_bytes" db:"size_bytes"`
+	// SHA256 is the hex digest of the part as received
+	SHA256     string    `json:"sha256" db:"sha256"`
+	ReceivedAt time.Time `json:"received_at" db:"received_at"`
+}
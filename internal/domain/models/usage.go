@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// TenantUsage is what a tenant consumed over a report period. Rows and bytes
+// count jobs created in the period; bytes are those of export files still
+// stored.
+type TenantUsage struct {
+	TenantID     string `json:"tenant_id" db:"tenant_id"`
+	Jobs         int64  `json:"jobs" db:"jobs"`
+	ImportJobs   int64  `json:"import_jobs" db:"import_jobs"`
+	ExportJobs   int64  `json:"export_jobs" db:"export_jobs"`
+	FailedJobs   int64  `json:"failed_jobs" db:"failed_jobs"`
+	RowsImported int64  `json:"rows_imported" db:"rows_imported"`
+	RowsExported int64  `json:"rows_exported" db:"rows_exported"`
+	RowsFailed   int64  `json:"rows_failed" db:"rows_failed"`
+	BytesStored  int64  `json:"bytes_stored" db:"bytes_stored"`
+}
+
+// Add adds other's counts to u
+func (u *TenantUsage) Add(other *TenantUsage) {
+	u.Jobs += other.Jobs
+	u.ImportJobs += other.ImportJobs
+	u.ExportJobs += other.ExportJobs
+	u.FailedJobs += other.FailedJobs
+	u.RowsImported += other.RowsImported
+	u.RowsExported += other.RowsExported
+	u.RowsFailed += other.RowsFailed
+	u.BytesStored += other.BytesStored
+}
+
+// UsageReport aggregates job usage per tenant over [From, To). RolledUpUntil
+// is set when whole days before it were read from the daily rollup.
+type UsageReport struct {
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	RolledUpUntil *time.Time     `json:"rolled_up_until,omitempty"`
+	Tenants       []*TenantUsage `json:"tenants"`
+	Totals        TenantUsage    `json:"totals"`
+}
@@ -0,0 +1,47 @@
+package models
+
+// VerifyReport compares the records of an import file with the stored
+// records of its resource. File rows are matched to stored records by ID, or
+// for rows without one by the natural key: a user's email or an article's
+// slug. Comments can only be matched by ID.
+type VerifyReport struct {
+	Resource ResourceType `json:"resource"`
+	// FileRecords counts the data rows read from the file
+	FileRecords int `json:"file_records"`
+	// Unmatchable counts rows that could not be read or have neither an ID
+	// nor a natural key, and Repeated rows whose key an earlier row had
+	Unmatchable int `json:"unmatchable"`
+	Repeated    int `json:"repeated"`
+	// Matched counts rows stored as the file has them, Differing rows stored
+	// with other values and Missing rows with no stored record
+	Matched   int `json:"matched"`
+	Differing int `json:"differing"`
+	Missing   int `json:"missing"`
+	// Extra counts stored records no row of the file matches
+	Extra int `json:"extra"`
+	// Differences, MissingRecords and ExtraRecords list up to the sample
+	// limit of each; the counts above cover all of them
+	Differences    []RecordDifference `json:"differences"`
+	MissingRecords []VerifyRecord     `json:"missing_records"`
+	ExtraRecords   []VerifyRecord     `json:"extra_records"`
+}
+
+// VerifyRecord identifies a record in a VerifyReport. Row is the file row,
+// 0 for a stored record no row matched.
+type VerifyRecord struct {
+	Row int    `json:"row,omitempty"`
+	Key string `json:"key"`
+}
+
+// RecordDifference is a file row whose stored record has other values
+type RecordDifference struct {
+	VerifyRecord
+	Fields []FieldDifference `json:"fields"`
+}
+
+// FieldDifference is a field a file row and its stored record disagree on
+type FieldDifference struct {
+	Field  string `json:"field"`
+	File   string `json:"file"`
+	Stored string `json:"stored"`
+}
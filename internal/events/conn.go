@@ -0,0 +1,64 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+)
+
+// conn is a line-oriented connection to a Redis or NATS server
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dial(ctx context.Context, addr string, useTLS bool, timeout time.Duration) (*conn, error) {
+	d := &net.Dialer{Timeout: timeout}
+	var (
+		c   net.Conn
+		err error
+	)
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		c, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		c, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, r: bufio.NewReader(c)}, nil
+}
+
+// setDeadline bounds the next exchange by the earlier of ctx's deadline and
+// timeout from now
+func (c *conn) setDeadline(ctx context.Context, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return c.SetDeadline(deadline)
+}
+
+// readLine reads one CRLF-terminated line without its terminator
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// withPort adds port to host when it has none
+func withPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
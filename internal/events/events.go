@@ -0,0 +1,160 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
+	pkglogger "github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Event publisher drivers accepted in EventsConfig.Driver
+const (
+	DriverRedis = "redis"
+	DriverNATS  = "nats"
+)
+
+// Invalidation levels
+const (
+	// LevelResource means any cached data for the resource may be stale
+	LevelResource = "resource"
+	// LevelEntity lists the records that changed
+	LevelEntity = "entity"
+)
+
+// Publisher delivers a payload to every subscriber of a subject
+type Publisher interface {
+	Publish(ctx context.Context, subject string, payload []byte) error
+	Close() error
+}
+
+// New creates the publisher selected by cfg.Driver
+func New(cfg config.EventsConfig) (Publisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid EVENTS_URL: %q", cfg.URL)
+	}
+	switch cfg.Driver {
+	case DriverRedis:
+		return NewRedis(u, cfg.Timeout)
+	case DriverNATS:
+		return NewNATS(u, cfg.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown events driver: %s", cfg.Driver)
+	}
+}
+
+// Invalidation is the payload of a cache invalidation event
+type Invalidation struct {
+	Level      string      `json:"level"`
+	Resource   string      `json:"resource"`
+	IDs        []uuid.UUID `json:"ids,omitempty"`
+	JobID      uuid.UUID   `json:"job_id"`
+	TenantID   string      `json:"tenant_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Emitter publishes invalidation events as imports commit batches. Register
+// it as an import hook.
+type Emitter struct {
+	hooks.Base
+	publisher Publisher
+	metrics   *metrics.Collector
+	logger    zerolog.Logger
+	// hot samples the per-batch publish failures so an outage doesn't
+	// flood the logs
+	hot    zerolog.Logger
+	config config.EventsConfig
+}
+
+// NewEmitter creates an emitter publishing through p
+func NewEmitter(p Publisher, metricsCollector *metrics.Collector, logger zerolog.Logger, cfg config.EventsConfig) *Emitter {
+	return &Emitter{
+		publisher: p,
+		metrics:   metricsCollector,
+		logger:    logger,
+		hot:       pkglogger.Hot(logger),
+		config:    cfg,
+	}
+}
+
+// Subject returns the subject events for resource are published on
+func (e *Emitter) Subject(resource models.ResourceType) string {
+	return e.config.SubjectPrefix + "." + string(resource)
+}
+
+// OnBatchInserted implements hooks.Hooks. It publishes a resource-level event
+// and entity-level events listing the written IDs, split into events of at
+// most MaxIDsPerEvent IDs. Failures are logged and counted but never fail the
+// import.
+func (e *Emitter) OnBatchInserted(ctx context.Context, job *models.Job, resource models.ResourceType, ids []uuid.UUID) {
+	if len(ids) == 0 {
+		return
+	}
+	base := Invalidation{
+		Resource:   string(resource),
+		JobID:      job.ID,
+		TenantID:   job.TenantID,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	if e.config.ResourceLevel {
+		event := base
+		event.Level = LevelResource
+		e.publish(ctx, resource, event)
+	}
+
+	if e.config.EntityLevel {
+		size := e.config.MaxIDsPerEvent
+		if size <= 0 {
+			size = len(ids)
+		}
+		for start := 0; start < len(ids); start += size {
+			end := start + size
+			if end > len(ids) {
+				end = len(ids)
+			}
+			event := base
+			event.Level = LevelEntity
+			event.IDs = ids[start:end]
+			e.publish(ctx, resource, event)
+		}
+	}
+}
+
+func (e *Emitter) publish(ctx context.Context, resource models.ResourceType, event Invalidation) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("Failed to encode invalidation event")
+		return
+	}
+
+	start := time.Now()
+	err = e.publisher.Publish(ctx, e.Subject(resource), payload)
+	status := "success"
+	if err != nil {
+		status = "error"
+		e.hot.Warn().
+			Err(err).
+			Str("job_id", event.JobID.String()).
+			Str("resource", event.Resource).
+			Str("level", event.Level).
+			Msg("Failed to publish invalidation event")
+	}
+	if e.metrics != nil {
+		e.metrics.RecordInvalidationEvent(e.config.Driver, event.Resource, event.Level, status, time.Since(start).Seconds())
+	}
+}
+
+// Close closes the underlying publisher
+func (e *Emitter) Close() error {
+	return e.publisher.Close()
+}
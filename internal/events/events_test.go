@@ -0,0 +1,174 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rs/zerolog"
+)
+
+// serve accepts one connection and hands it to handle
+func serve(t *testing.T, handle func(r *bufio.Reader, w io.Writer)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		handle(bufio.NewReader(c), c)
+	}()
+	return ln.Addr().String()
+}
+
+func readLine(r *bufio.Reader) string {
+	line, _ := r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func TestRedis_Publish(t *testing.T) {
+	got := make(chan []string, 1)
+	addr := serve(t, func(r *bufio.Reader, w io.Writer) {
+		// *3, then a length line and value for each argument
+		var lines []string
+		for i := 0; i < 7; i++ {
+			lines = append(lines, readLine(r))
+		}
+		got <- lines
+		io.WriteString(w, ":2\r\n")
+	})
+
+	u, _ := url.Parse("redis://" + addr)
+	pub, err := NewRedis(u, time.Second)
+	if err != nil {
+		t.Fatalf("NewRedis() error: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.Publish(context.Background(), "bulk.invalidate.users", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	want := []string{"*3", "$7", "PUBLISH", "$21", "bulk.invalidate.users", "$7", `{"a":1}`}
+	if lines := <-got; strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Publish() sent %q, want %q", lines, want)
+	}
+}
+
+func TestRedis_PublishError(t *testing.T) {
+	addr := serve(t, func(r *bufio.Reader, w io.Writer) {
+		for i := 0; i < 7; i++ {
+			readLine(r)
+		}
+		io.WriteString(w, "-NOAUTH Authentication required.\r\n")
+	})
+
+	u, _ := url.Parse("redis://" + addr)
+	pub, _ := NewRedis(u, time.Second)
+	defer pub.Close()
+
+	err := pub.Publish(context.Background(), "bulk.invalidate.users", []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+		t.Errorf("Publish() error = %v, want NOAUTH", err)
+	}
+}
+
+func TestNATS_Publish(t *testing.T) {
+	got := make(chan string, 1)
+	addr := serve(t, func(r *bufio.Reader, w io.Writer) {
+		io.WriteString(w, "INFO {\"server_id\":\"test\"}\r\n")
+		if !strings.HasPrefix(readLine(r), "CONNECT ") || readLine(r) != "PING" {
+			return
+		}
+		io.WriteString(w, "PONG\r\n")
+
+		pub := readLine(r)
+		payload := readLine(r)
+		if readLine(r) != "PING" {
+			return
+		}
+		got <- pub + " " + payload
+		// A server PING while we wait must be answered
+		io.WriteString(w, "PING\r\n")
+		if readLine(r) != "PONG" {
+			return
+		}
+		io.WriteString(w, "PONG\r\n")
+	})
+
+	u, _ := url.Parse("nats://" + addr)
+	pub, err := NewNATS(u, time.Second)
+	if err != nil {
+		t.Fatalf("NewNATS() error: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.Publish(context.Background(), "bulk.invalidate.articles", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if msg := <-got; msg != `PUB bulk.invalidate.articles 7 {"a":1}` {
+		t.Errorf("Publish() sent %q", msg)
+	}
+}
+
+type fakePublisher struct {
+	subjects []string
+	events   []Invalidation
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, subject string, payload []byte) error {
+	var event Invalidation
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	f.subjects = append(f.subjects, subject)
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakePublisher) Close() error { return nil }
+
+func TestEmitter_OnBatchInserted(t *testing.T) {
+	pub := &fakePublisher{}
+	emitter := NewEmitter(pub, nil, zerolog.Nop(), config.EventsConfig{
+		Driver:         DriverNATS,
+		SubjectPrefix:  "bulk.invalidate",
+		ResourceLevel:  true,
+		EntityLevel:    true,
+		MaxIDsPerEvent: 2,
+	})
+
+	job := &models.Job{ID: uuid.New(), TenantID: "acme"}
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	emitter.OnBatchInserted(context.Background(), job, models.ResourceTypeUsers, ids)
+
+	if len(pub.events) != 3 {
+		t.Fatalf("published %d events, want 1 resource + 2 entity", len(pub.events))
+	}
+	if pub.events[0].Level != LevelResource || len(pub.events[0].IDs) != 0 {
+		t.Errorf("first event = %+v, want resource level without IDs", pub.events[0])
+	}
+	if len(pub.events[1].IDs) != 2 || len(pub.events[2].IDs) != 1 || pub.events[2].IDs[0] != ids[2] {
+		t.Errorf("entity events split IDs as %d and %d, want 2 and 1", len(pub.events[1].IDs), len(pub.events[2].IDs))
+	}
+	for i, e := range pub.events {
+		if pub.subjects[i] != "bulk.invalidate.users" || e.JobID != job.ID || e.TenantID != "acme" {
+			t.Errorf("event %d = %+v on %s", i, e, pub.subjects[i])
+		}
+	}
+}
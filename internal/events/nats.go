@@ -0,0 +1,149 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS publishes events with the NATS PUB command over a single, lazily
+// opened connection. Each publish is followed by a PING so it only returns
+// once the server has processed the message.
+type NATS struct {
+	addr     string
+	username string
+	password string
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// NewNATS creates a NATS publisher for a nats:// URL
+func NewNATS(u *url.URL, timeout time.Duration) (*NATS, error) {
+	if u.Scheme != "nats" {
+		return nil, fmt.Errorf("nats events URL must use nats://, got %q", u.Scheme)
+	}
+	n := &NATS{
+		addr:    withPort(u.Host, "4222"),
+		timeout: timeout,
+	}
+	if u.User != nil {
+		n.username = u.User.Username()
+		n.password, _ = u.User.Password()
+	}
+	return n, nil
+}
+
+// Publish implements Publisher
+func (n *NATS) Publish(ctx context.Context, subject string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return fmt.Errorf("connect to nats %s: %w", n.addr, err)
+		}
+	}
+	if err := n.conn.setDeadline(ctx, n.timeout); err != nil {
+		n.closeLocked()
+		return err
+	}
+
+	msg := "PUB " + subject + " " + strconv.Itoa(len(payload)) + "\r\n" + string(payload) + "\r\nPING\r\n"
+	if _, err := n.conn.Write([]byte(msg)); err != nil {
+		n.closeLocked()
+		return fmt.Errorf("publish to %s: %w", subject, err)
+	}
+	if err := n.awaitPong(); err != nil {
+		n.closeLocked()
+		return fmt.Errorf("publish to %s: %w", subject, err)
+	}
+	return nil
+}
+
+func (n *NATS) connect(ctx context.Context) error {
+	c, err := dial(ctx, n.addr, false, n.timeout)
+	if err != nil {
+		return err
+	}
+	n.conn = c
+	if err := n.conn.setDeadline(ctx, n.timeout); err != nil {
+		n.closeLocked()
+		return err
+	}
+
+	// The server greets every client with INFO
+	info, err := n.conn.readLine()
+	if err != nil {
+		n.closeLocked()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO") {
+		n.closeLocked()
+		return fmt.Errorf("unexpected greeting: %q", info)
+	}
+
+	opts := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "bulk-import-export",
+		"lang":     "go",
+	}
+	if n.username != "" {
+		opts["user"] = n.username
+		opts["pass"] = n.password
+	}
+	connect, _ := json.Marshal(opts)
+	if _, err := n.conn.Write([]byte("CONNECT " + string(connect) + "\r\nPING\r\n")); err != nil {
+		n.closeLocked()
+		return err
+	}
+	if err := n.awaitPong(); err != nil {
+		n.closeLocked()
+		return err
+	}
+	return nil
+}
+
+// awaitPong reads until the server answers our PING, replying to any PING
+// of its own
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := n.conn.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// Close implements Publisher
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.closeLocked()
+}
+
+func (n *NATS) closeLocked() error {
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Redis publishes events with the Redis PUBLISH command over a single,
+// lazily opened connection
+type Redis struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// NewRedis creates a Redis publisher for a redis:// or rediss:// URL
+func NewRedis(u *url.URL, timeout time.Duration) (*Redis, error) {
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis events URL must use redis:// or rediss://, got %q", u.Scheme)
+	}
+	r := &Redis{
+		addr:    withPort(u.Host, "6379"),
+		useTLS:  u.Scheme == "rediss",
+		timeout: timeout,
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	return r, nil
+}
+
+// Publish implements Publisher
+func (r *Redis) Publish(ctx context.Context, subject string, payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return fmt.Errorf("connect to redis %s: %w", r.addr, err)
+		}
+	}
+	if err := r.conn.setDeadline(ctx, r.timeout); err != nil {
+		r.closeLocked()
+		return err
+	}
+	if err := r.do("PUBLISH", []byte(subject), payload); err != nil {
+		r.closeLocked()
+		return fmt.Errorf("publish to %s: %w", subject, err)
+	}
+	return nil
+}
+
+func (r *Redis) connect(ctx context.Context) error {
+	c, err := dial(ctx, r.addr, r.useTLS, r.timeout)
+	if err != nil {
+		return err
+	}
+	r.conn = c
+
+	if r.password != "" {
+		if err := r.conn.setDeadline(ctx, r.timeout); err != nil {
+			r.closeLocked()
+			return err
+		}
+		args := [][]byte{[]byte(r.password)}
+		if r.username != "" {
+			args = append([][]byte{[]byte(r.username)}, args...)
+		}
+		if err := r.do("AUTH", args...); err != nil {
+			r.closeLocked()
+			return err
+		}
+	}
+	return nil
+}
+
+// do sends a command as a RESP array of bulk strings and reads its reply
+func (r *Redis) do(cmd string, args ...[]byte) error {
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(len(args)+1) + "\r\n")
+	for _, arg := range append([][]byte{[]byte(cmd)}, args...) {
+		buf.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		buf.Write(arg)
+		buf.WriteString("\r\n")
+	}
+	if _, err := r.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	reply, err := r.conn.readLine()
+	if err != nil {
+		return err
+	}
+	switch {
+	case reply == "":
+		return fmt.Errorf("empty reply to %s", cmd)
+	case reply[0] == '+' || reply[0] == ':':
+		return nil
+	case reply[0] == '-':
+		return fmt.Errorf("%s: %s", cmd, reply[1:])
+	default:
+		return fmt.Errorf("unexpected reply to %s: %q", cmd, reply)
+	}
+}
+
+// Close implements Publisher
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeLocked()
+}
+
+func (r *Redis) closeLocked() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
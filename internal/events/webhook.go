@@ -0,0 +1,125 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
+	"github.com/rohit/bulk-import-export/pkg/signing"
+	"github.com/rs/zerolog"
+)
+
+// AlertImportPaused is the event of an alert sent when the circuit breaker
+// pauses an import
+const AlertImportPaused = "import.paused"
+
+// ChannelWebhook is the delivery channel that POSTs to webhook URLs
+const ChannelWebhook = "webhook"
+
+// Alert is the payload POSTed to the alert webhook
+type Alert struct {
+	Event      string    `json:"event"`
+	JobID      uuid.UUID `json:"job_id"`
+	Resource   string    `json:"resource"`
+	TenantID   string    `json:"tenant_id"`
+	Error      string    `json:"error"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Deliveries queues notifications, which are sent and retried until
+// delivered
+type Deliveries interface {
+	Enqueue(ctx context.Context, channel, target, event string, payload any) (*models.Delivery, error)
+}
+
+// AlertWebhook queues an alert to a webhook when the circuit breaker pauses
+// an import. Register it as an import hook.
+type AlertWebhook struct {
+	hooks.Base
+	url    string
+	queue  Deliveries
+	logger zerolog.Logger
+}
+
+// NewAlertWebhook creates an alert webhook queueing its alerts to url
+func NewAlertWebhook(url string, queue Deliveries, logger zerolog.Logger) *AlertWebhook {
+	return &AlertWebhook{
+		url:    url,
+		queue:  queue,
+		logger: logger,
+	}
+}
+
+// OnJobPaused implements hooks.Hooks. The alert is queued rather than sent,
+// so the worker that paused the import isn't held up and a failed delivery
+// is retried. The job stays paused either way.
+func (w *AlertWebhook) OnJobPaused(ctx context.Context, job *models.Job, err error) {
+	alert := Alert{
+		Event:      AlertImportPaused,
+		JobID:      job.ID,
+		Resource:   string(job.Resource),
+		TenantID:   job.TenantID,
+		Error:      err.Error(),
+		OccurredAt: time.Now().UTC(),
+	}
+	if _, err := w.queue.Enqueue(context.WithoutCancel(ctx), ChannelWebhook, w.url, alert.Event, alert); err != nil {
+		w.logger.Error().Err(err).Str("job_id", job.ID.String()).Str("event", alert.Event).Msg("Failed to queue alert")
+	}
+}
+
+// Signer signs webhook payloads, returning the signature and the ID of the
+// key it was made with
+type Signer interface {
+	SignWithKeyID(ctx context.Context, payload []byte) (string, string, error)
+}
+
+// Webhook is the delivery channel that POSTs a delivery's payload to its
+// target URL. Receivers can drop a retried delivery they have already
+// accepted by its X-Delivery-ID header.
+type Webhook struct {
+	client *http.Client
+	signer Signer
+}
+
+// NewWebhook creates the webhook channel. Each attempt is bounded by the
+// context it is sent with. With a signer, payloads are signed in the
+// signing.Header and signing.KeyIDHeader headers; nil sends them unsigned.
+func NewWebhook(signer Signer) *Webhook {
+	return &Webhook{client: &http.Client{}, signer: signer}
+}
+
+// Send implements deliveryservice.Channel. Any response other than 2xx
+// fails the attempt.
+func (w *Webhook) Send(ctx context.Context, delivery *models.Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Target, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event", delivery.Event)
+	req.Header.Set("X-Delivery-ID", delivery.ID.String())
+	if w.signer != nil {
+		// Signed at each attempt, so a retry carries a fresh timestamp
+		signature, keyID, err := w.signer.SignWithKeyID(ctx, delivery.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to sign webhook: %w", err)
+		}
+		req.Header.Set(signing.Header, signature)
+		req.Header.Set(signing.KeyIDHeader, keyID)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
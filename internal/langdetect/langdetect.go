@@ -0,0 +1,142 @@
+// Package langdetect guesses the language of a text well enough to segment
+// imported content. Non-Latin scripts are told apart by their Unicode
+// script; Latin-script languages by how many of their most common words the
+// text uses. It returns ISO 639-1 codes, or "" when the text gives too
+// little evidence.
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxWords bounds how much of a long text is examined
+const maxWords = 2000
+
+// scripts maps Unicode scripts to the language they are taken to mean.
+// Japanese is Han mixed with kana, so kana wins over Han.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// ukrainianLetters are Cyrillic letters Russian doesn't use
+const ukrainianLetters = "іїєґІЇЄҐ"
+
+// stopwords are common words of the Latin-script languages detected
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "was", "for", "with", "as", "on", "be", "this", "are", "have", "not", "but", "they", "you", "at", "by", "from", "which", "or", "an", "will", "would", "there", "their", "what"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "por", "con", "para", "no", "se", "del", "al", "lo", "como", "más", "pero", "sus", "le", "ya", "este", "porque", "esta", "cuando", "muy", "sin", "sobre", "también"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "du", "que", "qui", "dans", "en", "pour", "pas", "sur", "au", "avec", "ce", "il", "elle", "sont", "ne", "se", "plus", "mais", "nous", "vous", "ou", "été", "être", "cette", "aux"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "von", "mit", "sich", "des", "auf", "für", "im", "dem", "auch", "es", "an", "als", "nach", "wie", "aus", "bei", "oder", "wird", "sind", "noch", "über", "werden"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "e", "che", "è", "un", "una", "per", "non", "con", "sono", "del", "della", "nel", "si", "da", "come", "anche", "ma", "più", "questo", "alla", "dei", "delle", "ha"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "é", "por", "se", "dos", "das", "no", "na", "mais", "como", "mas", "ao", "ele", "ela", "são", "foi", "também", "você"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "in", "niet", "zijn", "met", "voor", "die", "er", "aan", "ook", "als", "maar", "om", "bij", "door", "naar", "wordt", "nog", "dan", "zo", "of", "wat"},
+}
+
+// wordLangs maps each stopword to the languages using it
+var wordLangs = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// Detect returns the ISO 639-1 code of the language text is most likely
+// written in, or "" if it can't tell
+func Detect(text string) string {
+	if lang := detectScript(text); lang != "" {
+		return lang
+	}
+	return detectLatin(text)
+}
+
+// detectScript returns the language of the non-Latin script most letters
+// are written in, if more than half of them are
+func detectScript(text string) string {
+	counts := make(map[string]int)
+	letters := 0
+	ukrainian := false
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+		if strings.ContainsRune(ukrainianLetters, r) {
+			ukrainian = true
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	// Any kana makes Han text Japanese
+	if best == "zh" && counts["ja"] > 0 {
+		best, bestCount = "ja", bestCount+counts["ja"]
+	}
+	if bestCount*2 <= letters {
+		return ""
+	}
+	if best == "ru" && ukrainian {
+		return "uk"
+	}
+	return best
+}
+
+// detectLatin scores the text's words against each language's stopwords.
+// The best language needs at least two hits, a tenth of the words and a
+// strictly higher score than the runner-up.
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) > maxWords {
+		words = words[:maxWords]
+	}
+
+	scores := make(map[string]int)
+	for _, w := range words {
+		for _, lang := range wordLangs[w] {
+			scores[lang]++
+		}
+	}
+
+	best, bestScore, second := "", 0, 0
+	for lang, score := range scores {
+		if score > bestScore {
+			best, bestScore, second = lang, score, bestScore
+		} else if score > second {
+			second = score
+		}
+	}
+	if bestScore < 2 || bestScore*10 < len(words) || bestScore == second {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,33 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The quick brown fox jumps over the lazy dog, and it was not the first time that this happened.", "en"},
+		{"El perro corre por el parque y los niños juegan con la pelota cuando hace sol.", "es"},
+		{"Le chat dort sur le canapé et les enfants jouent dans le jardin avec leurs amis.", "fr"},
+		{"Der Hund läuft durch den Park und die Kinder spielen mit dem Ball, wenn die Sonne scheint.", "de"},
+		{"Il gatto dorme sul divano e i bambini giocano nel giardino con gli amici della scuola.", "it"},
+		{"O cachorro corre pelo parque e as crianças brincam com a bola quando faz sol, mas não hoje.", "pt"},
+		{"De hond rent door het park en de kinderen spelen met een bal, maar het is niet warm.", "nl"},
+		{"Быстрая коричневая лиса прыгает через ленивую собаку.", "ru"},
+		{"Швидка руда лисиця перестрибує через лінивого собаку.", "uk"},
+		{"敏捷的棕色狐狸跳过了懒狗。", "zh"},
+		{"素早い茶色の狐がのろまな犬を飛び越えた。", "ja"},
+		{"빠른 갈색 여우가 게으른 개를 뛰어넘었다.", "ko"},
+		{"Η γρήγορη καφέ αλεπού πηδάει πάνω από τον τεμπέλη σκύλο.", "el"},
+		{"Great post!", ""},
+		{"12345 !!! ---", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// jobStats holds the current rate of each running import and export. Job IDs
+// would give Prometheus a series per job, so the rows-per-second gauges are
+// labelled by resource only and carry the total of that resource's running
+// jobs; a single job's rate is read from here by the status API instead.
+type jobStats struct {
+	mu   sync.Mutex
+	jobs map[string]jobRate
+}
+
+type jobRate struct {
+	gauge         *prometheus.GaugeVec
+	resource      string
+	rowsPerSecond float64
+}
+
+func newJobStats() *jobStats {
+	return &jobStats{jobs: make(map[string]jobRate)}
+}
+
+// set records the rate of jobID and updates the gauge of its resource
+func (s *jobStats) set(gauge *prometheus.GaugeVec, resource, jobID string, rowsPerSecond float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID] = jobRate{gauge: gauge, resource: resource, rowsPerSecond: rowsPerSecond}
+	s.update(gauge, resource)
+}
+
+// rate returns the last rate recorded for jobID, if it is running
+func (s *jobStats) rate(jobID string) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.jobs[jobID]
+	return r.rowsPerSecond, ok
+}
+
+// remove forgets jobID and takes its rate out of its resource's gauge
+func (s *jobStats) remove(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.jobs[jobID]
+	if !ok {
+		return
+	}
+	delete(s.jobs, jobID)
+	s.update(r.gauge, r.resource)
+}
+
+// update sets the gauge of resource to the total rate of its running jobs,
+// deleting the series once none are left so finished jobs leave no label
+// sets behind. s.mu must be held.
+func (s *jobStats) update(gauge *prometheus.GaugeVec, resource string) {
+	total, running := 0.0, false
+	for _, r := range s.jobs {
+		if r.gauge == gauge && r.resource == resource {
+			total += r.rowsPerSecond
+			running = true
+		}
+	}
+	if !running {
+		gauge.DeleteLabelValues(resource)
+		return
+	}
+	gauge.WithLabelValues(resource).Set(total)
+}
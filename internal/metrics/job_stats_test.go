@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestJobStats_SumsRunningJobsPerResource(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_rows_per_second"}, []string{"resource"})
+	stats := newJobStats()
+
+	stats.set(gauge, "users", "job-1", 100)
+	stats.set(gauge, "users", "job-2", 50)
+	stats.set(gauge, "articles", "job-3", 10)
+	stats.set(gauge, "users", "job-1", 120)
+
+	if got := testutil.ToFloat64(gauge.WithLabelValues("users")); got != 170 {
+		t.Errorf("users rate = %v, want 170", got)
+	}
+	if rate, ok := stats.rate("job-2"); !ok || rate != 50 {
+		t.Errorf("rate(job-2) = %v, %v; want 50, true", rate, ok)
+	}
+
+	stats.remove("job-1")
+	if got := testutil.ToFloat64(gauge.WithLabelValues("users")); got != 50 {
+		t.Errorf("users rate after job-1 finished = %v, want 50", got)
+	}
+	if _, ok := stats.rate("job-1"); ok {
+		t.Error("rate(job-1) still known after remove")
+	}
+
+	stats.remove("job-2")
+	stats.remove("job-3")
+	if n := testutil.CollectAndCount(gauge); n != 0 {
+		t.Errorf("%d series left once every job finished, want 0", n)
+	}
+}
@@ -16,7 +16,9 @@ type Collector struct {
 	ImportJobsActive    *prometheus.GaugeVec
 	ImportJobDuration   *prometheus.HistogramVec
 	ImportBatchDuration *prometheus.HistogramVec
+	ImportStageDuration *prometheus.HistogramVec
 	ImportRowsPerSecond *prometheus.GaugeVec
+	ImportBreakerOpen   prometheus.Gauge
 
 	// Export metrics
 	ExportJobsTotal     *prometheus.CounterVec
@@ -24,6 +26,29 @@ type Collector struct {
 	ExportJobsActive    *prometheus.GaugeVec
 	ExportJobDuration   *prometheus.HistogramVec
 	ExportRowsPerSecond *prometheus.GaugeVec
+	ExportStreamsActive prometheus.Gauge
+
+	// Job retry metrics
+	JobRetriesTotal *prometheus.CounterVec
+
+	// Job queue metrics
+	JobQueueMaxWait *prometheus.GaugeVec
+
+	// Job status cache metrics
+	JobStatusCacheTotal *prometheus.CounterVec
+
+	// Instance metrics
+	InstanceInfo    *prometheus.GaugeVec
+	WorkerJobsTotal *prometheus.CounterVec
+
+	// Invalidation event metrics
+	InvalidationEventsTotal     *prometheus.CounterVec
+	InvalidationPublishDuration *prometheus.HistogramVec
+
+	// Notification delivery metrics
+	DeliveryAttemptsTotal   *prometheus.CounterVec
+	DeliveryAttemptDuration *prometheus.HistogramVec
+	DeliveryLatency         *prometheus.HistogramVec
 
 	// HTTP metrics
 	HTTPRequestsTotal   *prometheus.CounterVec
@@ -32,6 +57,9 @@ type Collector struct {
 	// Database metrics
 	DBConnectionsActive prometheus.Gauge
 	DBQueryDuration     *prometheus.HistogramVec
+
+	// Current rate of each running job, behind the rows-per-second gauges
+	jobs *jobStats
 }
 
 // NewCollector creates a new metrics collector
@@ -82,12 +110,26 @@ func NewCollector() *Collector {
 			},
 			[]string{"resource"},
 		),
+		ImportBreakerOpen: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "import_breaker_open",
+				Help: "1 while the import circuit breaker is open after batch inserts kept failing",
+			},
+		),
+		ImportStageDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "import_stage_duration_seconds",
+				Help:    "Time an import job spent in each pipeline stage in seconds",
+				Buckets: prometheus.ExponentialBuckets(0.01, 2, 16), // 10ms to ~5m
+			},
+			[]string{"resource", "stage"},
+		),
 		ImportRowsPerSecond: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "import_rows_per_second",
-				Help: "Current import processing rate",
+				Help: "Current processing rate of the running imports of each resource",
 			},
-			[]string{"resource", "job_id"},
+			[]string{"resource"},
 		),
 
 		// Export metrics
@@ -123,9 +165,100 @@ func NewCollector() *Collector {
 		ExportRowsPerSecond: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "export_rows_per_second",
-				Help: "Current export processing rate",
+				Help: "Current processing rate of the running exports of each resource",
+			},
+			[]string{"resource"},
+		),
+		ExportStreamsActive: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "export_streams_active",
+				Help: "Number of currently active streaming exports",
+			},
+		),
+
+		// Job retry metrics
+		JobRetriesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "job_retries_total",
+				Help: "Failed jobs by what happened next: retried or dead_letter",
+			},
+			[]string{"job_type", "outcome"},
+		),
+
+		// Job queue metrics
+		JobQueueMaxWait: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "job_queue_max_wait_seconds",
+				Help: "How long the longest waiting queued job has waited",
 			},
-			[]string{"resource", "job_id"},
+			[]string{"job_type"},
+		),
+
+		// Job status cache metrics
+		JobStatusCacheTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "job_status_cache_requests_total",
+				Help: "Job status reads served from the status cache (hit) or the database (miss)",
+			},
+			[]string{"result"},
+		),
+
+		// Instance metrics
+		InstanceInfo: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "instance_info",
+				Help: "Always 1, labelled with this instance's ID and version",
+			},
+			[]string{"instance", "version"},
+		),
+		WorkerJobsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_jobs_total",
+				Help: "Jobs started by each worker of each instance",
+			},
+			[]string{"instance", "worker_id", "job_type"},
+		),
+
+		// Invalidation event metrics
+		InvalidationEventsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "invalidation_events_total",
+				Help: "Total number of cache invalidation events published",
+			},
+			[]string{"driver", "resource", "level", "status"},
+		),
+		InvalidationPublishDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "invalidation_publish_duration_seconds",
+				Help:    "Duration of cache invalidation event publishes in seconds",
+				Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to ~4s
+			},
+			[]string{"driver"},
+		),
+
+		// Notification delivery metrics
+		DeliveryAttemptsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_attempts_total",
+				Help: "Notification delivery attempts by outcome: delivered, retry or dead_letter",
+			},
+			[]string{"channel", "outcome"},
+		),
+		DeliveryAttemptDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "delivery_attempt_duration_seconds",
+				Help:    "Duration of notification delivery attempts in seconds",
+				Buckets: prometheus.ExponentialBuckets(0.005, 2, 12), // 5ms to ~10s
+			},
+			[]string{"channel"},
+		),
+		DeliveryLatency: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "delivery_latency_seconds",
+				Help:    "Time from queueing a notification to its delivery in seconds",
+				Buckets: prometheus.ExponentialBuckets(0.05, 4, 10), // 50ms to ~3.6h
+			},
+			[]string{"channel"},
 		),
 
 		// HTTP metrics
@@ -160,6 +293,8 @@ func NewCollector() *Collector {
 			},
 			[]string{"operation"},
 		),
+
+		jobs: newJobStats(),
 	}
 }
 
@@ -190,9 +325,24 @@ func (c *Collector) RecordImportBatch(resource string, duration float64) {
 	c.ImportBatchDuration.WithLabelValues(resource).Observe(duration)
 }
 
-// RecordImportRate records the current import rate
+// SetImportBreakerOpen records whether the import circuit breaker is open
+func (c *Collector) SetImportBreakerOpen(open bool) {
+	if open {
+		c.ImportBreakerOpen.Set(1)
+	} else {
+		c.ImportBreakerOpen.Set(0)
+	}
+}
+
+// RecordImportStage records the time an import job spent in one pipeline stage
+func (c *Collector) RecordImportStage(resource, stage string, duration float64) {
+	c.ImportStageDuration.WithLabelValues(resource, stage).Observe(duration)
+}
+
+// RecordImportRate records the current rate of a running import, adding it
+// to the rate of its resource
 func (c *Collector) RecordImportRate(resource, jobID string, rowsPerSecond float64) {
-	c.ImportRowsPerSecond.WithLabelValues(resource, jobID).Set(rowsPerSecond)
+	c.jobs.set(c.ImportRowsPerSecond, resource, jobID, rowsPerSecond)
 }
 
 // RecordExportJobStarted records when an export job starts
@@ -212,9 +362,45 @@ func (c *Collector) RecordExportRecords(resource string, count int) {
 	c.ExportRecordsTotal.WithLabelValues(resource).Add(float64(count))
 }
 
-// RecordExportRate records the current export rate
+// RecordExportRate records the current rate of a running export, adding it
+// to the rate of its resource
 func (c *Collector) RecordExportRate(resource, jobID string, rowsPerSecond float64) {
-	c.ExportRowsPerSecond.WithLabelValues(resource, jobID).Set(rowsPerSecond)
+	c.jobs.set(c.ExportRowsPerSecond, resource, jobID, rowsPerSecond)
+}
+
+// JobRate returns the current rate of a job running on this instance
+func (c *Collector) JobRate(jobID string) (float64, bool) {
+	return c.jobs.rate(jobID)
+}
+
+// ClearJobRate forgets the rate of a finished job, deleting the series of
+// its resource once no other job of the resource is running
+func (c *Collector) ClearJobRate(jobID string) {
+	c.jobs.remove(jobID)
+}
+
+// SetActiveExportStreams adjusts the number of active streaming exports
+func (c *Collector) SetActiveExportStreams(delta int) {
+	c.ExportStreamsActive.Add(float64(delta))
+}
+
+// RecordInvalidationEvent records a published cache invalidation event
+func (c *Collector) RecordInvalidationEvent(driver, resource, level, status string, duration float64) {
+	c.InvalidationEventsTotal.WithLabelValues(driver, resource, level, status).Inc()
+	c.InvalidationPublishDuration.WithLabelValues(driver).Observe(duration)
+}
+
+// RecordDeliveryAttempt records an attempt to send a notification and its
+// outcome
+func (c *Collector) RecordDeliveryAttempt(channel, outcome string, duration float64) {
+	c.DeliveryAttemptsTotal.WithLabelValues(channel, outcome).Inc()
+	c.DeliveryAttemptDuration.WithLabelValues(channel).Observe(duration)
+}
+
+// RecordDeliveryLatency records how long a delivered notification waited
+// from being queued, retries included
+func (c *Collector) RecordDeliveryLatency(channel string, seconds float64) {
+	c.DeliveryLatency.WithLabelValues(channel).Observe(seconds)
 }
 
 // RecordHTTPRequest records an HTTP request
@@ -233,6 +419,35 @@ func (c *Collector) SetDBConnections(count int) {
 	c.DBConnectionsActive.Set(float64(count))
 }
 
+// RecordJobRetry records a failed job being retried or moved to dead letters
+func (c *Collector) RecordJobRetry(jobType, outcome string) {
+	c.JobRetriesTotal.WithLabelValues(jobType, outcome).Inc()
+}
+
+// SetQueueMaxWait records the longest wait among jobs queued for a job type
+func (c *Collector) SetQueueMaxWait(jobType string, seconds float64) {
+	c.JobQueueMaxWait.WithLabelValues(jobType).Set(seconds)
+}
+
+// RecordJobStatusCache records a job status read served from the cache or not
+func (c *Collector) RecordJobStatusCache(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.JobStatusCacheTotal.WithLabelValues(result).Inc()
+}
+
+// SetInstanceInfo records the ID and version of this instance
+func (c *Collector) SetInstanceInfo(instance, version string) {
+	c.InstanceInfo.WithLabelValues(instance, version).Set(1)
+}
+
+// RecordWorkerJob records a worker of instance starting a job
+func (c *Collector) RecordWorkerJob(instance, workerID, jobType string) {
+	c.WorkerJobsTotal.WithLabelValues(instance, workerID, jobType).Inc()
+}
+
 // SetActiveJobs adjusts the number of active jobs for a job type
 func (c *Collector) SetActiveJobs(jobType interface{}, delta int) {
 	// Convert jobType to string
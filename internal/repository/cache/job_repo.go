@@ -0,0 +1,241 @@
+// Package cache holds short-lived caches in front of the repositories
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+// JobRepository wraps a JobRepository, serving GetStatus from a cache of
+// jobs at most ttl old so that clients polling job status don't each cost a
+// query. Writes made through it drop the job from the cache at once; writes
+// by other instances show once the entry expires. Every other read goes to
+// the wrapped repository.
+type JobRepository struct {
+	repository.JobRepository
+	ttl     time.Duration
+	metrics *metrics.Collector
+
+	mu        sync.Mutex
+	entries   map[uuid.UUID]jobEntry
+	loading   map[uuid.UUID]*load
+	lastSweep time.Time
+}
+
+type jobEntry struct {
+	job     *models.Job
+	expires time.Time
+}
+
+// load is a read of a job that concurrent misses wait on
+type load struct {
+	done chan struct{}
+	job  *models.Job
+	err  error
+}
+
+// NewJobRepository caches status reads of jobs for ttl; a ttl of 0 turns
+// the cache off. metrics may be nil.
+func NewJobRepository(jobs repository.JobRepository, ttl time.Duration, metrics *metrics.Collector) *JobRepository {
+	return &JobRepository{
+		JobRepository: jobs,
+		ttl:           ttl,
+		metrics:       metrics,
+		entries:       make(map[uuid.UUID]jobEntry),
+		loading:       make(map[uuid.UUID]*load),
+	}
+}
+
+// GetStatus returns the job like GetByID, from the cache when it was read in
+// the last ttl. Concurrent misses for the same job share one read.
+func (r *JobRepository) GetStatus(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	if r.ttl <= 0 {
+		return r.JobRepository.GetByID(ctx, id)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	if e, ok := r.entries[id]; ok && now.Before(e.expires) {
+		r.mu.Unlock()
+		r.record(true)
+		return copyJob(e.job), nil
+	}
+	if l, ok := r.loading[id]; ok {
+		r.mu.Unlock()
+		r.record(true)
+		select {
+		case <-l.done:
+			return copyJob(l.job), l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	l := &load{done: make(chan struct{})}
+	r.loading[id] = l
+	r.mu.Unlock()
+	r.record(false)
+
+	l.job, l.err = r.JobRepository.GetByID(ctx, id)
+
+	r.mu.Lock()
+	// A write while the read ran removed the load, so its result may
+	// already be stale and isn't kept
+	if r.loading[id] == l {
+		delete(r.loading, id)
+		if l.err == nil && l.job != nil {
+			r.entries[id] = jobEntry{job: l.job, expires: time.Now().Add(r.ttl)}
+		}
+	}
+	r.sweep(now)
+	r.mu.Unlock()
+	close(l.done)
+
+	return copyJob(l.job), l.err
+}
+
+// Invalidate drops the cached copy of a job
+func (r *JobRepository) Invalidate(id uuid.UUID) {
+	r.mu.Lock()
+	delete(r.entries, id)
+	delete(r.loading, id)
+	r.mu.Unlock()
+}
+
+// sweep drops expired entries, at most once per ttl. r.mu must be held.
+func (r *JobRepository) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.ttl {
+		return
+	}
+	r.lastSweep = now
+	for id, e := range r.entries {
+		if !now.Before(e.expires) {
+			delete(r.entries, id)
+		}
+	}
+}
+
+func (r *JobRepository) record(hit bool) {
+	if r.metrics != nil {
+		r.metrics.RecordJobStatusCache(hit)
+	}
+}
+
+// copyJob keeps callers from changing the cached job
+func copyJob(job *models.Job) *models.Job {
+	if job == nil {
+		return nil
+	}
+	j := *job
+	return &j
+}
+
+func (r *JobRepository) Update(ctx context.Context, job *models.Job) error {
+	defer r.Invalidate(job.ID)
+	return r.JobRepository.Update(ctx, job)
+}
+
+func (r *JobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.JobStatus) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.UpdateStatus(ctx, id, status)
+}
+
+func (r *JobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, processed, successful, failed int) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.UpdateProgress(ctx, id, processed, successful, failed)
+}
+
+func (r *JobRepository) IncrementProgress(ctx context.Context, id uuid.UUID, successDelta, failedDelta int) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.IncrementProgress(ctx, id, successDelta, failedDelta)
+}
+
+func (r *JobRepository) SetTotalRecords(ctx context.Context, id uuid.UUID, total int) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetTotalRecords(ctx, id, total)
+}
+
+func (r *JobRepository) SetDuplicateRecords(ctx context.Context, id uuid.UUID, duplicates int) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetDuplicateRecords(ctx, id, duplicates)
+}
+
+func (r *JobRepository) SetStarted(ctx context.Context, id uuid.UUID) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetStarted(ctx, id)
+}
+
+func (r *JobRepository) SetWorker(ctx context.Context, id uuid.UUID, worker models.JobWorker) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetWorker(ctx, id, worker)
+}
+
+func (r *JobRepository) SetPhase(ctx context.Context, id uuid.UUID, phase string) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetPhase(ctx, id, phase)
+}
+
+func (r *JobRepository) SetFilePath(ctx context.Context, id uuid.UUID, path string) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetFilePath(ctx, id, path)
+}
+
+func (r *JobRepository) ClearFilePath(ctx context.Context, id uuid.UUID) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.ClearFilePath(ctx, id)
+}
+
+func (r *JobRepository) SetSummary(ctx context.Context, id uuid.UUID, summary *models.JobSummary) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetSummary(ctx, id, summary)
+}
+
+func (r *JobRepository) SetCompleted(ctx context.Context, id uuid.UUID, successful, failed int) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetCompleted(ctx, id, successful, failed)
+}
+
+func (r *JobRepository) SetFailed(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetFailed(ctx, id, errorMessage)
+}
+
+func (r *JobRepository) ResetForRetry(ctx context.Context, id uuid.UUID, attempts int) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.ResetForRetry(ctx, id, attempts)
+}
+
+func (r *JobRepository) SetDeadLetter(ctx context.Context, id uuid.UUID, attempts int, errorMessage string) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetDeadLetter(ctx, id, attempts, errorMessage)
+}
+
+func (r *JobRepository) SetPaused(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetPaused(ctx, id, errorMessage)
+}
+
+func (r *JobRepository) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	defer r.Invalidate(id)
+	return r.JobRepository.Heartbeat(ctx, id)
+}
+
+func (r *JobRepository) ClaimStale(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	defer r.Invalidate(id)
+	return r.JobRepository.ClaimStale(ctx, id, staleBefore)
+}
+
+func (r *JobRepository) SetErrorMessage(ctx context.Context, id uuid.UUID, msg string) (bool, error) {
+	defer r.Invalidate(id)
+	return r.JobRepository.SetErrorMessage(ctx, id, msg)
+}
+
+func (r *JobRepository) FinishManually(ctx context.Context, id uuid.UUID, status models.JobStatus, reason string) (bool, error) {
+	defer r.Invalidate(id)
+	return r.JobRepository.FinishManually(ctx, id, status, reason)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+// countingJobs counts the reads that reach the wrapped repository
+type countingJobs struct {
+	repository.JobRepository
+	reads atomic.Int32
+	delay time.Duration
+}
+
+func (c *countingJobs) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	c.reads.Add(1)
+	time.Sleep(c.delay)
+	return c.JobRepository.GetByID(ctx, id)
+}
+
+func newCachedJob(t *testing.T, ttl time.Duration) (*JobRepository, *countingJobs, uuid.UUID) {
+	t.Helper()
+	inner := &countingJobs{JobRepository: memory.NewJobRepository(memory.NewDB())}
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers}
+	if err := inner.Create(context.Background(), job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	return NewJobRepository(inner, ttl, nil), inner, job.ID
+}
+
+func TestJobRepository_GetStatus(t *testing.T) {
+	ctx := context.Background()
+	jobs, inner, id := newCachedJob(t, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		job, err := jobs.GetStatus(ctx, id)
+		if err != nil || job == nil || job.ID != id {
+			t.Fatalf("GetStatus() = %v, %v", job, err)
+		}
+		job.Status = models.JobStatusFailed // callers get their own copy
+	}
+	if n := inner.reads.Load(); n != 1 {
+		t.Errorf("reads = %d, want 1 for repeated polls", n)
+	}
+
+	// Writes through the cache are seen at once
+	if err := jobs.UpdateProgress(ctx, id, 10, 9, 1); err != nil {
+		t.Fatalf("UpdateProgress() error: %v", err)
+	}
+	job, _ := jobs.GetStatus(ctx, id)
+	if job.ProcessedRecords != 10 || job.Status == models.JobStatusFailed {
+		t.Errorf("after write: processed = %d, status = %s", job.ProcessedRecords, job.Status)
+	}
+	if n := inner.reads.Load(); n != 2 {
+		t.Errorf("reads = %d, want 2 after an invalidating write", n)
+	}
+
+	// Other reads aren't cached
+	jobs.GetByID(ctx, id)
+	if n := inner.reads.Load(); n != 3 {
+		t.Errorf("reads = %d, want GetByID to go to the repository", n)
+	}
+
+	// Unknown jobs aren't cached either
+	missing := uuid.New()
+	jobs.GetStatus(ctx, missing)
+	jobs.GetStatus(ctx, missing)
+	if n := inner.reads.Load(); n != 5 {
+		t.Errorf("reads = %d, want both reads of a missing job to reach the repository", n)
+	}
+}
+
+func TestJobRepository_GetStatusExpires(t *testing.T) {
+	ctx := context.Background()
+	jobs, inner, id := newCachedJob(t, 20*time.Millisecond)
+
+	jobs.GetStatus(ctx, id)
+	time.Sleep(30 * time.Millisecond)
+	jobs.GetStatus(ctx, id)
+	if n := inner.reads.Load(); n != 2 {
+		t.Errorf("reads = %d, want an expired entry to be read again", n)
+	}
+
+	off, inner, id := newCachedJob(t, 0)
+	off.GetStatus(ctx, id)
+	off.GetStatus(ctx, id)
+	if n := inner.reads.Load(); n != 2 {
+		t.Errorf("reads = %d, want no caching with a ttl of 0", n)
+	}
+}
+
+func TestJobRepository_GetStatusSharesMisses(t *testing.T) {
+	jobs, inner, id := newCachedJob(t, time.Minute)
+	inner.delay = 20 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if job, err := jobs.GetStatus(context.Background(), id); err != nil || job == nil {
+				t.Errorf("GetStatus() = %v, %v", job, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := inner.reads.Load(); n != 1 {
+		t.Errorf("reads = %d, want concurrent misses to share one read", n)
+	}
+}
@@ -2,25 +2,50 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
+// Snapshotter pins the point in time export reads see
+type Snapshotter interface {
+	// Now returns the current database time
+	Now(ctx context.Context) (time.Time, error)
+	// Snapshot returns a context whose repository reads all see the data as
+	// of the returned time, and a func that releases the snapshot
+	Snapshot(ctx context.Context) (context.Context, time.Time, func(), error)
+}
+
 // UserRepository defines operations for user data access
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	CreateBatch(ctx context.Context, users []*models.User) (int, error)
+	// CreateMissing inserts the users whose ID and email aren't taken,
+	// leaving existing users untouched, and returns how many it inserted
+	CreateMissing(ctx context.Context, users []*models.User) (int, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.User, error)
 	GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.User) error) error
+	// GetAllWithCountsWithCursor streams users with their article and
+	// comment counts
+	GetAllWithCountsWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.UserWithCounts) error) error
 	Update(ctx context.Context, user *models.User) error
-	Upsert(ctx context.Context, user *models.User) error
-	UpsertBatch(ctx context.Context, users []*models.User) (int, int, error) // returns inserted, updated counts
+	// Upsert and UpsertBatch update the user matching each one on key, id
+	// or email, and insert the rest; an updated user takes the stored ID.
+	// UpsertBatch returns the inserted and updated counts.
+	Upsert(ctx context.Context, user *models.User, key models.UpsertKey) error
+	UpsertBatch(ctx context.Context, users []*models.User, key models.UpsertKey) (int, int, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	Exists(ctx context.Context, id uuid.UUID) (bool, error)
 	EmailExists(ctx context.Context, email string, excludeID *uuid.UUID) (bool, error)
+	ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	// GetByEmails returns the users with any of emails, keyed by their
+	// lowercased email
+	GetByEmails(ctx context.Context, emails []string) (map[string]*models.User, error)
+	ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error)
 	Count(ctx context.Context, filters *models.ExportFilters) (int64, error)
 }
 
@@ -33,11 +58,20 @@ type ArticleRepository interface {
 	GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.Article, error)
 	GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Article) error) error
 	Update(ctx context.Context, article *models.Article) error
-	Upsert(ctx context.Context, article *models.Article) error
-	UpsertBatch(ctx context.Context, articles []*models.Article) (int, int, error)
+	// Upsert and UpsertBatch update the article matching each one on key,
+	// id or slug, and insert the rest; an updated article takes the stored
+	// ID. UpsertBatch returns the inserted and updated counts.
+	Upsert(ctx context.Context, article *models.Article, key models.UpsertKey) error
+	UpsertBatch(ctx context.Context, articles []*models.Article, key models.UpsertKey) (int, int, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	Exists(ctx context.Context, id uuid.UUID) (bool, error)
 	SlugExists(ctx context.Context, slug string, excludeID *uuid.UUID) (bool, error)
+	ExistingSlugs(ctx context.Context, slugs []string) (map[string]bool, error)
+	// GetBySlugs returns the articles with any of slugs, keyed by their
+	// lowercased slug
+	GetBySlugs(ctx context.Context, slugs []string) (map[string]*models.Article, error)
+	ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.Article, error)
 	Count(ctx context.Context, filters *models.ExportFilters) (int64, error)
 }
 
@@ -48,28 +82,112 @@ type CommentRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Comment, error)
 	GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.Comment, error)
 	GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Comment) error) error
+	// GetAllByArticleWithCursor streams comments ordered by article, then
+	// creation
+	GetAllByArticleWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Comment) error) error
 	Update(ctx context.Context, comment *models.Comment) error
 	Upsert(ctx context.Context, comment *models.Comment) error
 	UpsertBatch(ctx context.Context, comments []*models.Comment) (int, int, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error)
 	Count(ctx context.Context, filters *models.ExportFilters) (int64, error)
 }
 
+// TableSizer reports how large the main tables are
+type TableSizer interface {
+	// TableSize returns the estimated rows and the bytes of the table and
+	// indexes resource is stored in
+	TableSize(ctx context.Context, resource models.ResourceType) (*models.TableSize, error)
+}
+
+// Transactor groups repository calls into one transaction
+type Transactor interface {
+	// WithTx calls fn with a context whose repository calls share one
+	// transaction, committed when fn returns nil and rolled back otherwise.
+	// Under a context that already has a transaction, fn joins it.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// Lock takes an exclusive lock on key that is held until the transaction
+	// of ctx ends, so transactions locking the same key run one at a time
+	Lock(ctx context.Context, key string) error
+}
+
+// Database is what services need of the database itself, besides its
+// repositories
+type Database interface {
+	TableSizer
+	Transactor
+}
+
 // JobRepository defines operations for job data access
 type JobRepository interface {
 	Create(ctx context.Context, job *models.Job) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error)
 	GetByIdempotencyKey(ctx context.Context, key string) (*models.Job, error)
+	GetFollowUp(ctx context.Context, parentID uuid.UUID, jobType models.JobType) (*models.Job, error)
 	Update(ctx context.Context, job *models.Job) error
 	UpdateStatus(ctx context.Context, id uuid.UUID, status models.JobStatus) error
 	UpdateProgress(ctx context.Context, id uuid.UUID, processed, successful, failed int) error
+	IncrementProgress(ctx context.Context, id uuid.UUID, successDelta, failedDelta int) error
+	SetTotalRecords(ctx context.Context, id uuid.UUID, total int) error
+	SetDuplicateRecords(ctx context.Context, id uuid.UUID, duplicates int) error
 	SetStarted(ctx context.Context, id uuid.UUID) error
+	// SetWorker records which instance and worker are running the job
+	SetWorker(ctx context.Context, id uuid.UUID, worker models.JobWorker) error
+	// SetPhase records the step a processing job has reached
+	SetPhase(ctx context.Context, id uuid.UUID, phase string) error
+	// SetFilePath records the file a job reads or writes, so an export can
+	// be downloaded while it is still being written
+	SetFilePath(ctx context.Context, id uuid.UUID, path string) error
+	// ClearFilePath forgets a job's file once it is removed, which also
+	// releases the storage it counted against its tenant's quota
+	ClearFilePath(ctx context.Context, id uuid.UUID) error
+	// SetSummary records the breakdown of an import's outcome
+	SetSummary(ctx context.Context, id uuid.UUID, summary *models.JobSummary) error
 	SetCompleted(ctx context.Context, id uuid.UUID, successful, failed int) error
 	SetFailed(ctx context.Context, id uuid.UUID, errorMessage string) error
+	// ResetForRetry puts a failed job back to pending with attempts failed
+	// runs recorded, clearing the counts, errors and warnings of the last run.
+	// The error message is kept until the job runs again.
+	ResetForRetry(ctx context.Context, id uuid.UUID, attempts int) error
+	// SetDeadLetter parks a job that failed attempts times
+	SetDeadLetter(ctx context.Context, id uuid.UUID, attempts int, errorMessage string) error
+	// SetPaused parks a job the circuit breaker stopped, with the failure
+	// that tripped it
+	SetPaused(ctx context.Context, id uuid.UUID, errorMessage string) error
+	// ListByStatus returns jobs in status, most recently updated first
+	ListByStatus(ctx context.Context, status models.JobStatus, page, perPage int) ([]*models.Job, int64, error)
+	// List returns the jobs matching filter, newest first
+	List(ctx context.Context, filter models.JobListFilter, page, perPage int) ([]*models.Job, int64, error)
+	// Heartbeat records that a worker is still processing the job
+	Heartbeat(ctx context.Context, id uuid.UUID) error
+	// ListStale returns up to limit processing jobs whose last heartbeat, or
+	// start when they have none, is before staleBefore
+	ListStale(ctx context.Context, staleBefore time.Time, limit int) ([]*models.Job, error)
+	// ListExpiredExports returns up to limit completed exports that still
+	// have a file and completed before completedBefore, oldest first
+	ListExpiredExports(ctx context.Context, completedBefore time.Time, limit int) ([]*models.Job, error)
+	// ClaimStale refreshes the heartbeat of a job that is still processing
+	// and stale, reporting whether it did, so only one monitor recovers it
+	ClaimStale(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error)
+	// SetErrorMessage records msg on a job that is still processing without
+	// touching the rest of its row, reporting whether the job was processing
+	SetErrorMessage(ctx context.Context, id uuid.UUID, msg string) (bool, error)
 	AddErrors(ctx context.Context, errors []*models.JobError) error
 	GetErrors(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobError, int64, error)
+	AddWarnings(ctx context.Context, warnings []*models.JobWarning) error
+	GetWarnings(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobWarning, int64, error)
+	AddLogs(ctx context.Context, jobID uuid.UUID, logs []*models.JobLog, dropped int) error
+	GetLogs(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobLog, int64, error)
 	GetPendingJobs(ctx context.Context, jobType models.JobType, limit int) ([]*models.Job, error)
+	// FinishManually moves a pending or processing job to status, failed or
+	// cancelled, with reason as its error message. It reports whether the
+	// job was still unfinished.
+	FinishManually(ctx context.Context, id uuid.UUID, status models.JobStatus, reason string) (bool, error)
+	// AddTimelineEntry records a note or manual status change on a job
+	AddTimelineEntry(ctx context.Context, entry *models.JobTimelineEntry) error
+	// GetTimeline returns a job's timeline entries, oldest first
+	GetTimeline(ctx context.Context, jobID uuid.UUID) ([]*models.JobTimelineEntry, error)
 }
 
 // StagingRepository defines operations for staging table data access
@@ -87,6 +205,9 @@ type StagingRepository interface {
 	MarkDuplicateArticlesInBatch(ctx context.Context, jobID uuid.UUID) (int, error)
 	MarkDuplicateArticlesAgainstExisting(ctx context.Context, jobID uuid.UUID) (int, error)
 	MarkInvalidAuthorFKArticles(ctx context.Context, jobID uuid.UUID) (int, error)
+	// MissingArticleAuthors returns the distinct author IDs of the job's
+	// valid staged articles that aren't users
+	MissingArticleAuthors(ctx context.Context, jobID uuid.UUID) ([]string, error)
 	GetValidStagingArticles(ctx context.Context, jobID uuid.UUID, batchSize int, callback func([]StagingArticle) error) error
 	UpdateStagingArticleValidation(ctx context.Context, stagingID int64, isValid bool, errorMsg string) error
 	CleanupStagingArticles(ctx context.Context, jobID uuid.UUID) error
@@ -94,10 +215,29 @@ type StagingRepository interface {
 	// Comment staging
 	CreateStagingComments(ctx context.Context, jobID uuid.UUID, comments []StagingComment) error
 	MarkDuplicateCommentsInBatch(ctx context.Context, jobID uuid.UUID) (int, error)
+	SetCommentNaturalKeys(ctx context.Context, jobID uuid.UUID) error
+	MarkDuplicateCommentsByNaturalKeyInBatch(ctx context.Context, jobID uuid.UUID) (int, error)
+	MarkDuplicateCommentsByNaturalKeyAgainstExisting(ctx context.Context, jobID uuid.UUID) (int, error)
 	MarkInvalidFKComments(ctx context.Context, jobID uuid.UUID) (int, error)
 	GetValidStagingComments(ctx context.Context, jobID uuid.UUID, batchSize int, callback func([]StagingComment) error) error
 	UpdateStagingCommentValidation(ctx context.Context, stagingID int64, isValid bool, errorMsg string) error
 	CleanupStagingComments(ctx context.Context, jobID uuid.UUID) error
+
+	// KeepCommittedUsers and its article and comment forms keep the rows an
+	// earlier run of jobID wrote, its valid rows with staging IDs up to
+	// through, marking them processed so they are neither checked nor
+	// written again, and delete the job's other unprocessed rows. They
+	// return every processed row of the job.
+	KeepCommittedUsers(ctx context.Context, jobID uuid.UUID, through int64) ([]CommittedRow, error)
+	KeepCommittedArticles(ctx context.Context, jobID uuid.UUID, through int64) ([]CommittedRow, error)
+	KeepCommittedComments(ctx context.Context, jobID uuid.UUID, through int64) ([]CommittedRow, error)
+}
+
+// CommittedRow is a staging row an earlier run of its job wrote to the main
+// table
+type CommittedRow struct {
+	StagingID int64 `db:"staging_id"`
+	RowNumber int   `db:"row_number"`
 }
 
 // StagingUser represents a user in the staging table
@@ -131,6 +271,7 @@ type StagingArticle struct {
 	Tags            *string   `db:"tags"`
 	PublishedAt     *string   `db:"published_at"`
 	Status          *string   `db:"status"`
+	Lang            *string   `db:"lang"`
 	ValidationError *string   `db:"validation_error"`
 	IsValid         bool      `db:"is_valid"`
 	IsDuplicate     bool      `db:"is_duplicate"`
@@ -147,6 +288,8 @@ type StagingComment struct {
 	UserID          *string   `db:"user_id"`
 	Body            *string   `db:"body"`
 	CreatedAt       *string   `db:"created_at"`
+	NaturalKey      *string   `db:"natural_key"`
+	Lang            *string   `db:"lang"`
 	ValidationError *string   `db:"validation_error"`
 	IsValid         bool      `db:"is_valid"`
 	IsDuplicate     bool      `db:"is_duplicate"`
@@ -160,3 +303,114 @@ type IdempotencyRepository interface {
 	Delete(ctx context.Context, key string) error
 	CleanupExpired(ctx context.Context) (int64, error)
 }
+
+// LedgerRepository defines operations for the import ledger, the content
+// hash each import last wrote under a record's natural key
+type LedgerRepository interface {
+	// Hashes returns the content hash recorded under each of keys that has one
+	Hashes(ctx context.Context, resource models.ResourceType, keys []string) (map[string]string, error)
+	// Record stores entries, replacing any recorded under the same resource
+	// and natural key
+	Record(ctx context.Context, entries []*models.LedgerEntry) error
+}
+
+// BatchLogRepository defines operations for the batch commit log, a row per
+// batch an import wrote to the main table
+type BatchLogRepository interface {
+	// Record stores commit. Inside a caller's transaction it commits with
+	// the batch.
+	Record(ctx context.Context, commit *models.BatchCommit) error
+	// ListByJob returns the batches jobID committed, by attempt and batch
+	ListByJob(ctx context.Context, jobID uuid.UUID) ([]*models.BatchCommit, error)
+	// NextAttempt returns the attempt the next run of jobID records its
+	// batches under, 1 for a job that recorded none
+	NextAttempt(ctx context.Context, jobID uuid.UUID) (int, error)
+	// Totals sums the records the batches of jobID inserted and updated in
+	// fromAttempt and the attempts after it
+	Totals(ctx context.Context, jobID uuid.UUID, fromAttempt int) (inserted, updated int, err error)
+}
+
+// QuotaRepository defines operations for tenant quota usage
+type QuotaRepository interface {
+	GetUsage(ctx context.Context, tenantID string, dayStart, monthStart time.Time) (*models.QuotaUsage, error)
+}
+
+// UsageRepository defines operations for per-tenant usage reports. An
+// empty tenantID means every tenant.
+type UsageRepository interface {
+	// GetUsage aggregates the jobs created in [from, to)
+	GetUsage(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error)
+	// GetRollups sums the daily rollups of the days in [from, to)
+	GetRollups(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error)
+	// RolledUpUntil returns the end of the last rolled-up day, or nil
+	RolledUpUntil(ctx context.Context) (*time.Time, error)
+	// FirstJobAt returns when the oldest job was created, or nil
+	FirstJobAt(ctx context.Context) (*time.Time, error)
+	// RollupDay stores the usage of the jobs created on the UTC day
+	RollupDay(ctx context.Context, day time.Time) error
+}
+
+// TombstoneRepository defines operations for deleted-record tombstones
+type TombstoneRepository interface {
+	GetDeletedWithCursor(ctx context.Context, resource models.ResourceType, from, to time.Time, batchSize int, callback func([]*models.Tombstone) error) error
+}
+
+// SigningKeyRepository defines operations for the keys artifacts are
+// signed with
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *models.SigningKey) error
+	// List returns every key, oldest first
+	List(ctx context.Context) ([]*models.SigningKey, error)
+	// Delete removes a key, returning false when there is none with id
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// UploadSessionRepository defines operations for resumable upload sessions
+// and the parts received for them
+type UploadSessionRepository interface {
+	Create(ctx context.Context, session *models.UploadSession) error
+	// GetByID returns nil when there is no session with id
+	GetByID(ctx context.Context, id uuid.UUID) (*models.UploadSession, error)
+	// PutPart records a received part, replacing one with the same number
+	PutPart(ctx context.Context, part *models.UploadPart) error
+	// ListParts returns the parts of a session by part number
+	ListParts(ctx context.Context, sessionID uuid.UUID) ([]*models.UploadPart, error)
+	// Complete marks an open session completed by jobID, returning false
+	// when it was no longer open
+	Complete(ctx context.Context, id, jobID uuid.UUID) (bool, error)
+	// DeleteExpired removes the sessions that expired before now, with
+	// their parts, and returns their IDs
+	DeleteExpired(ctx context.Context, now time.Time) ([]uuid.UUID, error)
+}
+
+// DeliveryRepository defines operations for the queue of notification
+// deliveries shared by every channel
+type DeliveryRepository interface {
+	Create(ctx context.Context, delivery *models.Delivery) error
+	// GetByID returns nil when there is no delivery with id
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Delivery, error)
+	// ClaimDue returns up to limit pending deliveries due by now, oldest
+	// due first, and moves their next attempt to leaseUntil so that no
+	// other instance claims them while they are sent
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.Delivery, error)
+	// MarkDelivered records the attempt that delivered a delivery. It
+	// returns false, recording nothing, unless the delivery is still held
+	// under lease, the next_attempt_at its claim set.
+	MarkDelivered(ctx context.Context, id uuid.UUID, lease time.Time, attempts int, at time.Time) (bool, error)
+	// MarkFailed records a failed attempt of a delivery still held under
+	// lease. The delivery is tried again at next, or dead-lettered when next
+	// is nil. It returns false when the lease was lost.
+	MarkFailed(ctx context.Context, id uuid.UUID, lease time.Time, attempts int, lastError string, next *time.Time) (bool, error)
+	// ListByStatus returns a page of the deliveries in status, newest
+	// first, and their total count
+	ListByStatus(ctx context.Context, status models.DeliveryStatus, page, perPage int) ([]*models.Delivery, int64, error)
+	// Requeue puts a dead-lettered delivery back to pending, due at now
+	// with no attempts made, returning false when it wasn't dead-lettered
+	Requeue(ctx context.Context, id uuid.UUID, now time.Time) (bool, error)
+}
+
+// ProfileRepository defines operations for import column profiles
+type ProfileRepository interface {
+	Save(ctx context.Context, profile *models.ImportProfile) error
+	GetByJobID(ctx context.Context, jobID uuid.UUID) (*models.ImportProfile, error)
+}
@@ -0,0 +1,408 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.ArticleRepository = (*ArticleRepository)(nil)
+
+// ArticleRepository implements repository.ArticleRepository in memory
+type ArticleRepository struct {
+	db *DB
+}
+
+// NewArticleRepository creates a new ArticleRepository
+func NewArticleRepository(db *DB) *ArticleRepository {
+	return &ArticleRepository{db: db}
+}
+
+// Create inserts a new article
+func (r *ArticleRepository) Create(ctx context.Context, article *models.Article) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.defaults(article)
+	if _, ok := r.db.articles[article.ID]; ok {
+		return errUnique("articles", "id", article.ID.String())
+	}
+	if err := r.check(article); err != nil {
+		return err
+	}
+	r.db.articles[article.ID] = cloneArticle(article)
+	return nil
+}
+
+// CreateBatch inserts articles, updating any whose ID already exists. Either
+// every row is written or none is.
+func (r *ArticleRepository) CreateBatch(ctx context.Context, articles []*models.Article) (int, error) {
+	inserted, updated, err := r.UpsertBatch(ctx, articles, models.UpsertKeyID)
+	return inserted + updated, err
+}
+
+// GetByID retrieves an article by ID
+func (r *ArticleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Article, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if article, ok := r.db.articles[id]; ok {
+		return cloneArticle(article), nil
+	}
+	return nil, nil
+}
+
+// GetBySlug retrieves an article by slug
+func (r *ArticleRepository) GetBySlug(ctx context.Context, slug string) (*models.Article, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, article := range r.db.articles {
+		if article.Slug == slug {
+			return cloneArticle(article), nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAll retrieves all articles with optional filters
+func (r *ArticleRepository) GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.Article, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.selectArticles(filters), nil
+}
+
+// GetAllWithCursor streams articles in batches. The rows are copied before
+// the first callback, so callbacks may use the repositories.
+func (r *ArticleRepository) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Article) error) error {
+	articles, _ := r.GetAll(ctx, filters)
+	return streamBatches(articles, batchSize, callback)
+}
+
+// Update updates an existing article
+func (r *ArticleRepository) Update(ctx context.Context, article *models.Article) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.articles[article.ID]
+	if !ok {
+		return nil
+	}
+	if err := r.check(article); err != nil {
+		return err
+	}
+	article.UpdatedAt = r.db.now()
+	updated := cloneArticle(article)
+	updated.CreatedAt = existing.CreatedAt
+	r.db.articles[article.ID] = updated
+	return nil
+}
+
+// Upsert inserts an article or updates the one matching it on key
+func (r *ArticleRepository) Upsert(ctx context.Context, article *models.Article, key models.UpsertKey) error {
+	article.UpdatedAt = r.db.now()
+	_, _, err := r.UpsertBatch(ctx, []*models.Article{article}, key)
+	return err
+}
+
+// UpsertBatch inserts articles, updating those matching a stored article on
+// key. Articles matched on slug take the stored article's ID. Either every
+// row is written or none is.
+func (r *ArticleRepository) UpsertBatch(ctx context.Context, articles []*models.Article, key models.UpsertKey) (int, int, error) {
+	if len(articles) == 0 {
+		return 0, 0, nil
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, article := range articles {
+		r.defaults(article)
+	}
+	switch key {
+	case models.UpsertKeyID, "":
+	case models.UpsertKeySlug:
+		if err := r.matchSlugs(articles); err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("articles cannot be upserted on %q", key)
+	}
+
+	slugs := make(map[string]uuid.UUID, len(articles))
+	for _, article := range articles {
+		if other, ok := slugs[article.Slug]; ok && other != article.ID {
+			return 0, 0, errUnique("articles", "slug", article.Slug)
+		}
+		slugs[article.Slug] = article.ID
+		if err := r.check(article, articles...); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	inserted := 0
+	for _, article := range articles {
+		if existing, ok := r.db.articles[article.ID]; ok {
+			updated := cloneArticle(article)
+			updated.CreatedAt = existing.CreatedAt
+			if updated.Lang == nil {
+				updated.Lang = cloneString(existing.Lang)
+			}
+			if updated.ImportJobID == nil {
+				updated.ImportJobID = cloneUUID(existing.ImportJobID)
+			}
+			r.db.articles[article.ID] = updated
+			continue
+		}
+		r.db.articles[article.ID] = cloneArticle(article)
+		inserted++
+	}
+	return inserted, len(articles) - inserted, nil
+}
+
+// matchSlugs gives the articles whose slug is stored that article's ID. Like
+// the primary key, it refuses a new slug under a stored article's ID.
+func (r *ArticleRepository) matchSlugs(articles []*models.Article) error {
+	stored := make(map[string]uuid.UUID, len(r.db.articles))
+	for id, article := range r.db.articles {
+		stored[article.Slug] = id
+	}
+	for _, article := range articles {
+		if _, ok := stored[article.Slug]; ok {
+			continue
+		}
+		if _, ok := r.db.articles[article.ID]; ok {
+			return errUnique("articles", "id", article.ID.String())
+		}
+	}
+	for _, article := range articles {
+		if id, ok := stored[article.Slug]; ok {
+			article.ID = id
+		}
+	}
+	return nil
+}
+
+// Delete deletes an article by ID. Like the foreign key, it refuses while
+// comments still reference the article.
+func (r *ArticleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.articles[id]; !ok {
+		return nil
+	}
+	for _, comment := range r.db.comments {
+		if comment.ArticleID == id {
+			return errReferenced("articles", "comments", id)
+		}
+	}
+	delete(r.db.articles, id)
+	r.db.addTombstone(models.ResourceTypeArticles, id)
+	return nil
+}
+
+// Exists checks if an article exists by ID
+func (r *ArticleRepository) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	_, ok := r.db.articles[id]
+	return ok, nil
+}
+
+// SlugExists checks if a slug exists, optionally excluding a specific article
+func (r *ArticleRepository) SlugExists(ctx context.Context, slug string, excludeID *uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, article := range r.db.articles {
+		if article.Slug == slug && (excludeID == nil || article.ID != *excludeID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ExistingSlugs returns which of the lowercased slugs belong to an article
+func (r *ArticleRepository) ExistingSlugs(ctx context.Context, slugs []string) (map[string]bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	wanted := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		wanted[slug] = true
+	}
+	found := make(map[string]bool)
+	for _, article := range r.db.articles {
+		if slug := strings.ToLower(article.Slug); wanted[slug] {
+			found[slug] = true
+		}
+	}
+	return found, nil
+}
+
+// GetBySlugs returns the articles with any of slugs, keyed by their
+// lowercased slug
+func (r *ArticleRepository) GetBySlugs(ctx context.Context, slugs []string) (map[string]*models.Article, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	wanted := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		wanted[slug] = true
+	}
+	result := make(map[string]*models.Article)
+	for _, article := range r.db.articles {
+		if slug := strings.ToLower(article.Slug); wanted[slug] {
+			result[slug] = cloneArticle(article)
+		}
+	}
+	return result, nil
+}
+
+// ExistingIDs returns which of ids are articles, keyed by their canonical
+// text form. Malformed IDs are never found.
+func (r *ArticleRepository) ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	found := make(map[string]bool)
+	for _, id := range parseIDs(ids) {
+		if _, ok := r.db.articles[id]; ok {
+			found[id.String()] = true
+		}
+	}
+	return found, nil
+}
+
+// GetByIDs retrieves multiple articles by their IDs
+func (r *ArticleRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.Article, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	result := make(map[uuid.UUID]*models.Article)
+	for _, id := range ids {
+		if article, ok := r.db.articles[id]; ok {
+			result[id] = cloneArticle(article)
+		}
+	}
+	return result, nil
+}
+
+// Count returns the number of articles matching the filters
+func (r *ArticleRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return int64(len(r.selectArticles(filters))), nil
+}
+
+func (r *ArticleRepository) defaults(article *models.Article) {
+	if article.ID == uuid.Nil {
+		article.ID = uuid.New()
+	}
+	if article.CreatedAt.IsZero() {
+		article.CreatedAt = r.db.now()
+	}
+	if article.UpdatedAt.IsZero() {
+		article.UpdatedAt = r.db.now()
+	}
+	if article.Tags == nil {
+		article.Tags = json.RawMessage("[]")
+	}
+}
+
+// check enforces the author foreign key and the unique slug constraint,
+// ignoring the article itself and any articles written alongside it
+func (r *ArticleRepository) check(article *models.Article, batch ...*models.Article) error {
+	if _, ok := r.db.users[article.AuthorID]; !ok {
+		return errForeignKey("articles", "author_id", article.AuthorID)
+	}
+	for _, existing := range r.db.articles {
+		if existing.Slug != article.Slug || existing.ID == article.ID {
+			continue
+		}
+		if rewritten(existing.ID, batch, func(a *models.Article) uuid.UUID { return a.ID }, func(a *models.Article) bool { return a.Slug != existing.Slug }) {
+			continue
+		}
+		return errUnique("articles", "slug", article.Slug)
+	}
+	return nil
+}
+
+func (r *ArticleRepository) selectArticles(filters *models.ExportFilters) []*models.Article {
+	articles := make([]*models.Article, 0, len(r.db.articles))
+	for _, article := range r.db.articles {
+		if filters != nil {
+			if filters.Status != nil && article.Status != *filters.Status {
+				continue
+			}
+			if filters.AuthorID != nil && article.AuthorID != *filters.AuthorID {
+				continue
+			}
+			if filters.Lang != nil && (article.Lang == nil || *article.Lang != *filters.Lang) {
+				continue
+			}
+			if !importedBy(filters, article.ImportJobID) {
+				continue
+			}
+			if !publishedFilter(filters, article.PublishedAt) {
+				continue
+			}
+			if filters.HasTags != nil && hasTags(article.Tags) != *filters.HasTags {
+				continue
+			}
+		}
+		if !timeFilter(filters, article.CreatedAt, article.UpdatedAt) {
+			continue
+		}
+		articles = append(articles, cloneArticle(article))
+	}
+	byCreatedAt(articles, func(a *models.Article) time.Time { return a.CreatedAt }, func(a *models.Article) uuid.UUID { return a.ID })
+	return articles
+}
+
+// publishedFilter reports whether publishedAt is within the publication range
+// of filters. Unpublished articles never match a range.
+func publishedFilter(filters *models.ExportFilters, publishedAt *time.Time) bool {
+	if filters.PublishedAfter == nil && filters.PublishedBefore == nil {
+		return true
+	}
+	if publishedAt == nil {
+		return false
+	}
+	if filters.PublishedAfter != nil && publishedAt.Before(*filters.PublishedAfter) {
+		return false
+	}
+	if filters.PublishedBefore != nil && publishedAt.After(*filters.PublishedBefore) {
+		return false
+	}
+	return true
+}
+
+// hasTags reports whether tags is a non-empty JSON array
+func hasTags(tags json.RawMessage) bool {
+	var list []json.RawMessage
+	if err := json.Unmarshal(tags, &list); err != nil {
+		return false
+	}
+	return len(list) > 0
+}
+
+func cloneArticle(article *models.Article) *models.Article {
+	clone := *article
+	clone.Tags = append(json.RawMessage(nil), article.Tags...)
+	if article.PublishedAt != nil {
+		publishedAt := *article.PublishedAt
+		clone.PublishedAt = &publishedAt
+	}
+	clone.Lang = cloneString(article.Lang)
+	clone.ImportJobID = cloneUUID(article.ImportJobID)
+	return &clone
+}
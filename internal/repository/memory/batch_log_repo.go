@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.BatchLogRepository = (*BatchLogRepository)(nil)
+
+// BatchLogRepository implements repository.BatchLogRepository in memory
+type BatchLogRepository struct {
+	db *DB
+}
+
+// NewBatchLogRepository creates a new BatchLogRepository
+func NewBatchLogRepository(db *DB) *BatchLogRepository {
+	return &BatchLogRepository{db: db}
+}
+
+// Record stores commit, failing if its batch was already recorded
+func (r *BatchLogRepository) Record(ctx context.Context, commit *models.BatchCommit) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, c := range r.db.batchCommits {
+		if c.JobID == commit.JobID && c.Attempt == commit.Attempt && c.BatchNo == commit.BatchNo {
+			return fmt.Errorf("batch %d of attempt %d of job %s is already recorded", commit.BatchNo, commit.Attempt, commit.JobID)
+		}
+	}
+	if commit.CommittedAt.IsZero() {
+		commit.CommittedAt = r.db.now()
+	}
+	stored := *commit
+	r.db.batchCommits = append(r.db.batchCommits, &stored)
+	return nil
+}
+
+// ListByJob returns the batches jobID committed, by attempt and batch
+func (r *BatchLogRepository) ListByJob(ctx context.Context, jobID uuid.UUID) ([]*models.BatchCommit, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var commits []*models.BatchCommit
+	for _, c := range r.db.batchCommits {
+		if c.JobID == jobID {
+			commit := *c
+			commits = append(commits, &commit)
+		}
+	}
+	sort.Slice(commits, func(i, j int) bool {
+		if commits[i].Attempt != commits[j].Attempt {
+			return commits[i].Attempt < commits[j].Attempt
+		}
+		return commits[i].BatchNo < commits[j].BatchNo
+	})
+	return commits, nil
+}
+
+// NextAttempt returns the attempt the next run of jobID records its batches
+// under, 1 for a job that recorded none
+func (r *BatchLogRepository) NextAttempt(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	last := 0
+	for _, c := range r.db.batchCommits {
+		if c.JobID == jobID {
+			last = max(last, c.Attempt)
+		}
+	}
+	return last + 1, nil
+}
+
+// Totals sums the records the batches of jobID inserted and updated in
+// fromAttempt and the attempts after it
+func (r *BatchLogRepository) Totals(ctx context.Context, jobID uuid.UUID, fromAttempt int) (int, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	inserted, updated := 0, 0
+	for _, c := range r.db.batchCommits {
+		if c.JobID == jobID && c.Attempt >= fromAttempt {
+			inserted += c.Inserted
+			updated += c.Updated
+		}
+	}
+	return inserted, updated, nil
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.CommentRepository = (*CommentRepository)(nil)
+
+// CommentRepository implements repository.CommentRepository in memory
+type CommentRepository struct {
+	db *DB
+}
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository(db *DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+// Create inserts a new comment
+func (r *CommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.defaults(comment)
+	if _, ok := r.db.comments[comment.ID]; ok {
+		return errUnique("comments", "id", comment.ID.String())
+	}
+	if err := r.check(comment); err != nil {
+		return err
+	}
+	r.db.comments[comment.ID] = cloneComment(comment)
+	return nil
+}
+
+// CreateBatch inserts comments, updating the article, user and body of any
+// whose ID already exists. Either every row is written or none is.
+func (r *CommentRepository) CreateBatch(ctx context.Context, comments []*models.Comment) (int, error) {
+	if len(comments) == 0 {
+		return 0, nil
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, comment := range comments {
+		r.defaults(comment)
+		if err := r.check(comment); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, comment := range comments {
+		if existing, ok := r.db.comments[comment.ID]; ok {
+			if comment.Lang == nil {
+				comment.Lang = cloneString(existing.Lang)
+			}
+			if comment.ImportJobID == nil {
+				comment.ImportJobID = cloneUUID(existing.ImportJobID)
+			}
+		}
+		r.write(comment)
+	}
+	return len(comments), nil
+}
+
+// GetByID retrieves a comment by ID
+func (r *CommentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if comment, ok := r.db.comments[id]; ok {
+		return cloneComment(comment), nil
+	}
+	return nil, nil
+}
+
+// GetAll retrieves all comments with optional filters
+func (r *CommentRepository) GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.selectComments(filters), nil
+}
+
+// GetAllWithCursor streams comments in batches. The rows are copied before
+// the first callback, so callbacks may use the repositories.
+func (r *CommentRepository) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Comment) error) error {
+	comments, _ := r.GetAll(ctx, filters)
+	return streamBatches(comments, batchSize, callback)
+}
+
+// GetAllByArticleWithCursor streams comments like GetAllWithCursor, ordered
+// by article and then creation
+func (r *CommentRepository) GetAllByArticleWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Comment) error) error {
+	comments, _ := r.GetAll(ctx, filters)
+	// The sort is stable, so comments keep their creation order
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].ArticleID.String() < comments[j].ArticleID.String()
+	})
+	return streamBatches(comments, batchSize, callback)
+}
+
+// Update updates an existing comment
+func (r *CommentRepository) Update(ctx context.Context, comment *models.Comment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.comments[comment.ID]; !ok {
+		return nil
+	}
+	if err := r.check(comment); err != nil {
+		return err
+	}
+	r.write(comment)
+	return nil
+}
+
+// Upsert inserts or updates a comment
+func (r *CommentRepository) Upsert(ctx context.Context, comment *models.Comment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.defaults(comment)
+	if err := r.check(comment); err != nil {
+		return err
+	}
+	r.write(comment)
+	return nil
+}
+
+// UpsertBatch upserts multiple comments
+func (r *CommentRepository) UpsertBatch(ctx context.Context, comments []*models.Comment) (int, int, error) {
+	count, err := r.CreateBatch(ctx, comments)
+	return count, 0, err
+}
+
+// Delete deletes a comment by ID
+func (r *CommentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.comments[id]; !ok {
+		return nil
+	}
+	delete(r.db.comments, id)
+	r.db.addTombstone(models.ResourceTypeComments, id)
+	return nil
+}
+
+// Exists checks if a comment exists by ID
+func (r *CommentRepository) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	_, ok := r.db.comments[id]
+	return ok, nil
+}
+
+// ExistingIDs returns which of ids are comments, keyed by their canonical
+// text form. Malformed IDs are never found.
+func (r *CommentRepository) ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	found := make(map[string]bool)
+	for _, id := range parseIDs(ids) {
+		if _, ok := r.db.comments[id]; ok {
+			found[id.String()] = true
+		}
+	}
+	return found, nil
+}
+
+// Count returns the number of comments matching the filters
+func (r *CommentRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return int64(len(r.selectComments(filters))), nil
+}
+
+func (r *CommentRepository) defaults(comment *models.Comment) {
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = r.db.now()
+	}
+}
+
+// check enforces the article and user foreign keys
+func (r *CommentRepository) check(comment *models.Comment) error {
+	if _, ok := r.db.articles[comment.ArticleID]; !ok {
+		return errForeignKey("comments", "article_id", comment.ArticleID)
+	}
+	if _, ok := r.db.users[comment.UserID]; !ok {
+		return errForeignKey("comments", "user_id", comment.UserID)
+	}
+	return nil
+}
+
+// write stores comment, keeping the creation time of an existing row and
+// bumping its updated_at the way the update trigger does
+func (r *CommentRepository) write(comment *models.Comment) {
+	comment.UpdatedAt = r.db.now()
+	stored := cloneComment(comment)
+	if existing, ok := r.db.comments[comment.ID]; ok {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	r.db.comments[comment.ID] = stored
+}
+
+func (r *CommentRepository) selectComments(filters *models.ExportFilters) []*models.Comment {
+	comments := make([]*models.Comment, 0, len(r.db.comments))
+	for _, comment := range r.db.comments {
+		if filters != nil {
+			if filters.ArticleID != nil && comment.ArticleID != *filters.ArticleID {
+				continue
+			}
+			if filters.UserID != nil && comment.UserID != *filters.UserID {
+				continue
+			}
+			if filters.Lang != nil && (comment.Lang == nil || *comment.Lang != *filters.Lang) {
+				continue
+			}
+			if !importedBy(filters, comment.ImportJobID) {
+				continue
+			}
+		}
+		if !timeFilter(filters, comment.CreatedAt, comment.UpdatedAt) {
+			continue
+		}
+		comments = append(comments, cloneComment(comment))
+	}
+	byCreatedAt(comments, func(c *models.Comment) time.Time { return c.CreatedAt }, func(c *models.Comment) uuid.UUID { return c.ID })
+	return comments
+}
+
+func cloneComment(comment *models.Comment) *models.Comment {
+	clone := *comment
+	clone.Lang = cloneString(comment.Lang)
+	clone.ImportJobID = cloneUUID(comment.ImportJobID)
+	return &clone
+}
@@ -0,0 +1,279 @@
+// Package memory implements the repository interfaces in memory, for unit
+// tests of the services and handlers that don't need a real database. It
+// keeps the constraints the services rely on: unique user emails and article
+// slugs, foreign keys from articles and comments, and tombstones on delete.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+// DB holds the tables shared by the in-memory repositories, the way a
+// postgres.DB is shared by the PostgreSQL ones
+type DB struct {
+	mu sync.Mutex
+	// txMu runs transactions one at a time
+	txMu sync.Mutex
+
+	users    map[uuid.UUID]*models.User
+	articles map[uuid.UUID]*models.Article
+	comments map[uuid.UUID]*models.Comment
+
+	jobs        map[uuid.UUID]*models.Job
+	jobErrors   []*models.JobError
+	jobWarnings []*models.JobWarning
+	jobLogs     []*models.JobLog
+	jobTimeline []*models.JobTimelineEntry
+
+	stagingUsers    []*repository.StagingUser
+	stagingArticles []*repository.StagingArticle
+	stagingComments []*repository.StagingComment
+	nextStagingID   int64
+
+	idempotencyKeys map[string]*models.IdempotencyKey
+	profiles        map[uuid.UUID]*models.ImportProfile
+	tombstones      []*models.Tombstone
+	signingKeys     map[string]*models.SigningKey
+	ledger          map[ledgerKey]*models.LedgerEntry
+	uploadSessions  map[uuid.UUID]*models.UploadSession
+	uploadParts     map[uuid.UUID]map[int]*models.UploadPart
+	batchCommits    []*models.BatchCommit
+	deliveries      map[uuid.UUID]*models.Delivery
+
+	// usageDaily holds the usage rollups by UTC day and tenant
+	usageDaily map[string]map[string]*models.TenantUsage
+
+	// clock returns the database time; tests may replace it with SetClock
+	clock func() time.Time
+}
+
+// NewDB creates an empty in-memory database
+func NewDB() *DB {
+	return &DB{
+		users:           make(map[uuid.UUID]*models.User),
+		articles:        make(map[uuid.UUID]*models.Article),
+		comments:        make(map[uuid.UUID]*models.Comment),
+		jobs:            make(map[uuid.UUID]*models.Job),
+		idempotencyKeys: make(map[string]*models.IdempotencyKey),
+		profiles:        make(map[uuid.UUID]*models.ImportProfile),
+		signingKeys:     make(map[string]*models.SigningKey),
+		ledger:          make(map[ledgerKey]*models.LedgerEntry),
+		uploadSessions:  make(map[uuid.UUID]*models.UploadSession),
+		uploadParts:     make(map[uuid.UUID]map[int]*models.UploadPart),
+		deliveries:      make(map[uuid.UUID]*models.Delivery),
+		usageDaily:      make(map[string]map[string]*models.TenantUsage),
+		clock:           func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// SetClock replaces the database time, for tests that depend on it
+func (db *DB) SetClock(clock func() time.Time) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.clock = clock
+}
+
+func (db *DB) now() time.Time {
+	return db.clock()
+}
+
+// Now returns the current database time
+func (db *DB) Now(ctx context.Context) (time.Time, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.now(), nil
+}
+
+// Snapshot implements repository.Snapshotter. Reads aren't isolated from
+// later writes; tests that need a stable view shouldn't write while
+// exporting.
+func (db *DB) Snapshot(ctx context.Context) (context.Context, time.Time, func(), error) {
+	now, _ := db.Now(ctx)
+	return ctx, now, func() {}, nil
+}
+
+// txContextKey marks a context running inside WithTx
+type txContextKey struct{}
+
+// WithTx implements repository.Transactor. Transactions run one at a time,
+// but writes made before fn fails are not rolled back.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txContextKey{}) != nil {
+		return fn(ctx)
+	}
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+	return fn(context.WithValue(ctx, txContextKey{}, true))
+}
+
+// Lock implements repository.Transactor. Transactions already run one at a
+// time, so it only checks that ctx has one.
+func (db *DB) Lock(ctx context.Context, key string) error {
+	if ctx.Value(txContextKey{}) == nil {
+		return fmt.Errorf("lock %s: no transaction", key)
+	}
+	return nil
+}
+
+// TableSize implements repository.TableSizer. Rows are exact; nothing is
+// stored on disk, so the byte counts are 0.
+func (db *DB) TableSize(ctx context.Context, resource models.ResourceType) (*models.TableSize, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch resource {
+	case models.ResourceTypeUsers:
+		return &models.TableSize{Rows: int64(len(db.users))}, nil
+	case models.ResourceTypeArticles:
+		return &models.TableSize{Rows: int64(len(db.articles))}, nil
+	case models.ResourceTypeComments:
+		return &models.TableSize{Rows: int64(len(db.comments))}, nil
+	}
+	return nil, fmt.Errorf("unknown resource type: %s", resource)
+}
+
+// errForeignKey mirrors an insert that points at a missing row
+func errForeignKey(table, column string, id uuid.UUID) error {
+	return fmt.Errorf("insert on %s violates foreign key %s: %s not found", table, column, id)
+}
+
+func errUnique(table, column, value string) error {
+	return fmt.Errorf("duplicate key value violates unique constraint on %s.%s: %s", table, column, value)
+}
+
+// errReferenced mirrors a delete refused because other rows point at it
+func errReferenced(table, referencedBy string, id uuid.UUID) error {
+	return fmt.Errorf("delete on %s violates foreign key from %s: %s is still referenced", table, referencedBy, id)
+}
+
+// rewritten reports whether batch rewrites the stored row id so that it no
+// longer holds the conflicting value, which a single statement allows
+func rewritten[T any](id uuid.UUID, batch []T, idOf func(T) uuid.UUID, changed func(T) bool) bool {
+	for _, row := range batch {
+		if idOf(row) == id {
+			return changed(row)
+		}
+	}
+	return false
+}
+
+// paginate applies page and per_page the way the PostgreSQL repositories do
+func paginate(n, page, perPage int) (start, end int) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+	start = min((page-1)*perPage, n)
+	end = min(start+perPage, n)
+	return start, end
+}
+
+// streamBatches calls callback with rows in batches of batchSize
+func streamBatches[T any](rows []T, batchSize int, callback func([]T) error) error {
+	if batchSize < 1 {
+		batchSize = len(rows)
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := min(start+batchSize, len(rows))
+		if err := callback(rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byCreatedAt sorts rows oldest first like the export queries, with the ID
+// as a tie-breaker so the order is stable
+func byCreatedAt[T any](rows []T, created func(T) time.Time, id func(T) uuid.UUID) {
+	sort.Slice(rows, func(i, j int) bool {
+		ci, cj := created(rows[i]), created(rows[j])
+		if !ci.Equal(cj) {
+			return ci.Before(cj)
+		}
+		return id(rows[i]).String() < id(rows[j]).String()
+	})
+}
+
+// timeFilter reports whether created and updated fall within the filters'
+// time bounds, which match the SQL conditions
+func timeFilter(filters *models.ExportFilters, created, updated time.Time) bool {
+	if filters == nil {
+		return true
+	}
+	if filters.CreatedAfter != nil && created.Before(*filters.CreatedAfter) {
+		return false
+	}
+	if filters.CreatedBefore != nil && created.After(*filters.CreatedBefore) {
+		return false
+	}
+	if filters.UpdatedAfter != nil && !updated.After(*filters.UpdatedAfter) {
+		return false
+	}
+	if filters.UpdatedBefore != nil && updated.After(*filters.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// importedBy reports whether a record last written by the import job
+// jobID matches the filters' job, when they name one
+func importedBy(filters *models.ExportFilters, jobID *uuid.UUID) bool {
+	return filters.ImportedByJob == nil || (jobID != nil && *jobID == *filters.ImportedByJob)
+}
+
+// parseIDs returns the IDs that parse as UUIDs, dropping the rest
+func parseIDs(ids []string) []uuid.UUID {
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if u, err := uuid.Parse(id); err == nil {
+			parsed = append(parsed, u)
+		}
+	}
+	return parsed
+}
+
+func (db *DB) addTombstone(resource models.ResourceType, id uuid.UUID) {
+	db.tombstones = append(db.tombstones, &models.Tombstone{
+		ID:        int64(len(db.tombstones) + 1),
+		Resource:  resource,
+		RecordID:  id,
+		DeletedAt: db.now(),
+	})
+}
+
+func cloneString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+	return &clone
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
+func cloneUUID(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+	clone := *id
+	return &clone
+}
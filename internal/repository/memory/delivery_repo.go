@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.DeliveryRepository = (*DeliveryRepository)(nil)
+
+// DeliveryRepository implements repository.DeliveryRepository in memory
+type DeliveryRepository struct {
+	db *DB
+}
+
+// NewDeliveryRepository creates a new DeliveryRepository
+func NewDeliveryRepository(db *DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+// Create queues a new delivery
+func (r *DeliveryRepository) Create(ctx context.Context, delivery *models.Delivery) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	now := r.db.now()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = now
+	}
+	if delivery.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = delivery.CreatedAt
+	}
+	if delivery.Status == "" {
+		delivery.Status = models.DeliveryPending
+	}
+	delivery.UpdatedAt = now
+	r.db.deliveries[delivery.ID] = cloneDelivery(delivery)
+	return nil
+}
+
+// GetByID returns the delivery with id, or nil if there is none
+func (r *DeliveryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Delivery, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delivery, ok := r.db.deliveries[id]
+	if !ok {
+		return nil, nil
+	}
+	return cloneDelivery(delivery), nil
+}
+
+// ClaimDue returns up to limit pending deliveries due by now and leases them
+// until leaseUntil
+func (r *DeliveryRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.Delivery, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var due []*models.Delivery
+	for _, delivery := range r.db.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.Delivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = leaseUntil
+		delivery.UpdatedAt = now
+		claimed = append(claimed, cloneDelivery(delivery))
+	}
+	return claimed, nil
+}
+
+// MarkDelivered records the attempt that delivered a delivery, unless its
+// lease was lost
+func (r *DeliveryRepository) MarkDelivered(ctx context.Context, id uuid.UUID, lease time.Time, attempts int, at time.Time) (bool, error) {
+	return r.update(id, lease, func(delivery *models.Delivery) {
+		delivery.Status = models.DeliveryDelivered
+		delivery.Attempts = attempts
+		delivery.DeliveredAt = &at
+		delivery.UpdatedAt = at
+	})
+}
+
+// MarkFailed records a failed attempt, scheduling the next one at next or
+// dead-lettering the delivery when next is nil, unless its lease was lost
+func (r *DeliveryRepository) MarkFailed(ctx context.Context, id uuid.UUID, lease time.Time, attempts int, lastError string, next *time.Time) (bool, error) {
+	return r.update(id, lease, func(delivery *models.Delivery) {
+		delivery.Attempts = attempts
+		delivery.LastError = &lastError
+		if next == nil {
+			delivery.Status = models.DeliveryDeadLetter
+		} else {
+			delivery.NextAttemptAt = *next
+		}
+	})
+}
+
+// ListByStatus returns a page of the deliveries in status, newest first
+func (r *DeliveryRepository) ListByStatus(ctx context.Context, status models.DeliveryStatus, page, perPage int) ([]*models.Delivery, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var deliveries []*models.Delivery
+	for _, delivery := range r.db.deliveries {
+		if delivery.Status == status {
+			deliveries = append(deliveries, cloneDelivery(delivery))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+
+	start, end := paginate(len(deliveries), page, perPage)
+	return deliveries[start:end], int64(len(deliveries)), nil
+}
+
+// Requeue puts a dead-lettered delivery back to pending with no attempts
+// made, returning false when it wasn't dead-lettered
+func (r *DeliveryRepository) Requeue(ctx context.Context, id uuid.UUID, now time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delivery, ok := r.db.deliveries[id]
+	if !ok || delivery.Status != models.DeliveryDeadLetter {
+		return false, nil
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	return true, nil
+}
+
+// update applies fn to a pending delivery still held under lease,
+// reporting whether it did
+func (r *DeliveryRepository) update(id uuid.UUID, lease time.Time, fn func(delivery *models.Delivery)) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delivery, ok := r.db.deliveries[id]
+	if !ok || delivery.Status != models.DeliveryPending || !delivery.NextAttemptAt.Equal(lease) {
+		return false, nil
+	}
+	delivery.UpdatedAt = r.db.now()
+	fn(delivery)
+	return true, nil
+}
+
+func cloneDelivery(delivery *models.Delivery) *models.Delivery {
+	clone := *delivery
+	clone.Payload = append(json.RawMessage(nil), delivery.Payload...)
+	clone.LastError = cloneString(delivery.LastError)
+	if delivery.DeliveredAt != nil {
+		at := *delivery.DeliveredAt
+		clone.DeliveredAt = &at
+	}
+	return &clone
+}
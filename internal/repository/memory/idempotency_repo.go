@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.IdempotencyRepository = (*IdempotencyRepository)(nil)
+
+// IdempotencyRepository implements repository.IdempotencyRepository in memory
+type IdempotencyRepository struct {
+	db *DB
+}
+
+// NewIdempotencyRepository creates a new IdempotencyRepository
+func NewIdempotencyRepository(db *DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Create inserts a new idempotency key
+func (r *IdempotencyRepository) Create(ctx context.Context, key *models.IdempotencyKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = r.db.now()
+	}
+	if _, ok := r.db.idempotencyKeys[key.Key]; ok {
+		return errUnique("idempotency_keys", "key", key.Key)
+	}
+	stored := *key
+	stored.ResponseBody = cloneString(key.ResponseBody)
+	r.db.idempotencyKeys[key.Key] = &stored
+	return nil
+}
+
+// GetByKey retrieves an unexpired idempotency key record
+func (r *IdempotencyRepository) GetByKey(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	record, ok := r.db.idempotencyKeys[key]
+	if !ok || !record.ExpiresAt.After(r.db.now()) {
+		return nil, nil
+	}
+	clone := *record
+	clone.ResponseBody = cloneString(record.ResponseBody)
+	return &clone, nil
+}
+
+// Delete removes an idempotency key
+func (r *IdempotencyRepository) Delete(ctx context.Context, key string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.idempotencyKeys, key)
+	return nil
+}
+
+// CleanupExpired removes expired idempotency keys
+func (r *IdempotencyRepository) CleanupExpired(ctx context.Context) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var removed int64
+	now := r.db.now()
+	for key, record := range r.db.idempotencyKeys {
+		if record.ExpiresAt.Before(now) {
+			delete(r.db.idempotencyKeys, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,671 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.JobRepository = (*JobRepository)(nil)
+
+// JobRepository implements repository.JobRepository in memory
+type JobRepository struct {
+	db *DB
+}
+
+// NewJobRepository creates a new JobRepository
+func NewJobRepository(db *DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// Create inserts a new job
+func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = r.db.now()
+	}
+	if job.TenantID == "" {
+		job.TenantID = models.DefaultTenantID
+	}
+	job.UpdatedAt = r.db.now()
+
+	if _, ok := r.db.jobs[job.ID]; ok {
+		return errUnique("jobs", "id", job.ID.String())
+	}
+	if job.IdempotencyKey != nil {
+		for _, existing := range r.db.jobs {
+			if existing.IdempotencyKey != nil && *existing.IdempotencyKey == *job.IdempotencyKey {
+				return errUnique("jobs", "idempotency_key", *job.IdempotencyKey)
+			}
+		}
+	}
+	if job.ParentJobID != nil {
+		if _, ok := r.db.jobs[*job.ParentJobID]; !ok {
+			return errForeignKey("jobs", "parent_job_id", *job.ParentJobID)
+		}
+	}
+	r.db.jobs[job.ID] = cloneJob(job)
+	return nil
+}
+
+// GetByID retrieves a job by ID
+func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if job, ok := r.db.jobs[id]; ok {
+		return cloneJob(job), nil
+	}
+	return nil, nil
+}
+
+// GetFollowUp retrieves the latest job of jobType started from parentID
+func (r *JobRepository) GetFollowUp(ctx context.Context, parentID uuid.UUID, jobType models.JobType) (*models.Job, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var latest *models.Job
+	for _, job := range r.db.jobs {
+		if job.ParentJobID == nil || *job.ParentJobID != parentID || job.Type != jobType {
+			continue
+		}
+		if latest == nil || job.CreatedAt.After(latest.CreatedAt) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	return cloneJob(latest), nil
+}
+
+// GetByIdempotencyKey retrieves a job by idempotency key
+func (r *JobRepository) GetByIdempotencyKey(ctx context.Context, key string) (*models.Job, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, job := range r.db.jobs {
+		if job.IdempotencyKey != nil && *job.IdempotencyKey == key {
+			return cloneJob(job), nil
+		}
+	}
+	return nil, nil
+}
+
+// Update updates the mutable fields of an existing job
+func (r *JobRepository) Update(ctx context.Context, job *models.Job) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	job.UpdatedAt = r.db.now()
+	stored, ok := r.db.jobs[job.ID]
+	if !ok {
+		return nil
+	}
+	stored.Status = job.Status
+	stored.TotalRecords = job.TotalRecords
+	stored.ProcessedRecords = job.ProcessedRecords
+	stored.SuccessfulRecords = job.SuccessfulRecords
+	stored.FailedRecords = job.FailedRecords
+	stored.ErrorMessage = cloneString(job.ErrorMessage)
+	stored.StartedAt = cloneTime(job.StartedAt)
+	stored.CompletedAt = cloneTime(job.CompletedAt)
+	stored.UpdatedAt = job.UpdatedAt
+	stored.FilePath = cloneString(job.FilePath)
+	stored.FileSizeBytes = job.FileSizeBytes
+	stored.DataAsOf = cloneTime(job.DataAsOf)
+	return nil
+}
+
+// UpdateStatus updates the job status
+func (r *JobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.JobStatus) error {
+	return r.update(id, func(job *models.Job) {
+		job.Status = status
+	})
+}
+
+// UpdateProgress updates the job progress counters
+func (r *JobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, processed, successful, failed int) error {
+	return r.update(id, func(job *models.Job) {
+		job.ProcessedRecords = processed
+		job.SuccessfulRecords = successful
+		job.FailedRecords = failed
+	})
+}
+
+// IncrementProgress increments the processed records count
+func (r *JobRepository) IncrementProgress(ctx context.Context, id uuid.UUID, successDelta, failedDelta int) error {
+	return r.update(id, func(job *models.Job) {
+		job.ProcessedRecords += successDelta + failedDelta
+		job.SuccessfulRecords += successDelta
+		job.FailedRecords += failedDelta
+	})
+}
+
+// SetTotalRecords sets the total records count for a job
+func (r *JobRepository) SetTotalRecords(ctx context.Context, id uuid.UUID, total int) error {
+	return r.update(id, func(job *models.Job) {
+		job.TotalRecords = total
+	})
+}
+
+// SetDuplicateRecords records how many rows of the job are duplicates
+func (r *JobRepository) SetDuplicateRecords(ctx context.Context, id uuid.UUID, duplicates int) error {
+	return r.update(id, func(job *models.Job) {
+		job.DuplicateRecords = duplicates
+	})
+}
+
+// SetStarted sets the job as started
+func (r *JobRepository) SetStarted(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(job *models.Job) {
+		now := job.UpdatedAt
+		job.Status = models.JobStatusProcessing
+		job.StartedAt = &now
+		heartbeat := now
+		job.HeartbeatAt = &heartbeat
+	})
+}
+
+// SetWorker records which instance and worker are running the job
+func (r *JobRepository) SetWorker(ctx context.Context, id uuid.UUID, worker models.JobWorker) error {
+	return r.update(id, func(job *models.Job) {
+		job.WorkerInstance = &worker.Instance
+		job.WorkerID = &worker.WorkerID
+		job.WorkerVersion = &worker.Version
+	})
+}
+
+// SetPhase records the step a processing job has reached
+func (r *JobRepository) SetPhase(ctx context.Context, id uuid.UUID, phase string) error {
+	return r.update(id, func(job *models.Job) {
+		job.Phase = &phase
+	})
+}
+
+// SetFilePath records the file a job reads or writes
+func (r *JobRepository) SetFilePath(ctx context.Context, id uuid.UUID, path string) error {
+	return r.update(id, func(job *models.Job) {
+		job.FilePath = &path
+	})
+}
+
+// ClearFilePath forgets a job's file once it is removed
+func (r *JobRepository) ClearFilePath(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(job *models.Job) {
+		job.FilePath = nil
+	})
+}
+
+// SetSummary records the breakdown of an import's outcome
+func (r *JobRepository) SetSummary(ctx context.Context, id uuid.UUID, summary *models.JobSummary) error {
+	return r.update(id, func(job *models.Job) {
+		job.Summary = nil
+		if summary != nil {
+			clone := *summary
+			clone.RejectedDomains = maps.Clone(summary.RejectedDomains)
+			job.Summary = &clone
+		}
+	})
+}
+
+// SetCompleted sets the job as completed
+func (r *JobRepository) SetCompleted(ctx context.Context, id uuid.UUID, successful, failed int) error {
+	return r.update(id, func(job *models.Job) {
+		now := job.UpdatedAt
+		job.Status = models.JobStatusCompleted
+		job.SuccessfulRecords = successful
+		job.FailedRecords = failed
+		job.CompletedAt = &now
+	})
+}
+
+// SetFailed sets the job as failed
+func (r *JobRepository) SetFailed(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	return r.update(id, func(job *models.Job) {
+		now := job.UpdatedAt
+		job.Status = models.JobStatusFailed
+		job.ErrorMessage = &errorMessage
+		job.CompletedAt = &now
+	})
+}
+
+// ResetForRetry puts a failed job back to pending for another attempt
+func (r *JobRepository) ResetForRetry(ctx context.Context, id uuid.UUID, attempts int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	job, ok := r.db.jobs[id]
+	if !ok {
+		return nil
+	}
+	job.UpdatedAt = r.db.now()
+	job.Status = models.JobStatusPending
+	job.Attempts = attempts
+	job.TotalRecords = 0
+	job.ProcessedRecords = 0
+	job.SuccessfulRecords = 0
+	job.FailedRecords = 0
+	job.WarningCount = 0
+	job.DuplicateRecords = 0
+	job.StartedAt = nil
+	job.HeartbeatAt = nil
+	job.CompletedAt = nil
+	job.Phase = nil
+	job.Summary = nil
+
+	errors := r.db.jobErrors[:0]
+	for _, e := range r.db.jobErrors {
+		if e.JobID != id {
+			errors = append(errors, e)
+		}
+	}
+	r.db.jobErrors = errors
+	warnings := r.db.jobWarnings[:0]
+	for _, w := range r.db.jobWarnings {
+		if w.JobID != id {
+			warnings = append(warnings, w)
+		}
+	}
+	r.db.jobWarnings = warnings
+	return nil
+}
+
+// SetDeadLetter parks a job that failed attempts times
+func (r *JobRepository) SetDeadLetter(ctx context.Context, id uuid.UUID, attempts int, errorMessage string) error {
+	return r.update(id, func(job *models.Job) {
+		now := job.UpdatedAt
+		job.Status = models.JobStatusDeadLetter
+		job.Attempts = attempts
+		job.ErrorMessage = &errorMessage
+		job.CompletedAt = &now
+	})
+}
+
+// SetPaused parks a job the circuit breaker stopped
+func (r *JobRepository) SetPaused(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	return r.update(id, func(job *models.Job) {
+		job.Status = models.JobStatusPaused
+		job.ErrorMessage = &errorMessage
+		job.HeartbeatAt = nil
+	})
+}
+
+// Heartbeat records that a worker is still processing the job
+func (r *JobRepository) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if job, ok := r.db.jobs[id]; ok && job.Status == models.JobStatusProcessing {
+		now := r.db.now()
+		job.HeartbeatAt = &now
+	}
+	return nil
+}
+
+// ListStale returns up to limit processing jobs whose last heartbeat, or
+// start when they have none, is before staleBefore
+func (r *JobRepository) ListStale(ctx context.Context, staleBefore time.Time, limit int) ([]*models.Job, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var jobs []*models.Job
+	for _, job := range r.db.jobs {
+		if isStale(job, staleBefore) {
+			jobs = append(jobs, cloneJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return lastHeartbeat(jobs[i]).Before(lastHeartbeat(jobs[j])) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// ListExpiredExports returns up to limit completed exports that still have a
+// file and completed before completedBefore, oldest first
+func (r *JobRepository) ListExpiredExports(ctx context.Context, completedBefore time.Time, limit int) ([]*models.Job, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var jobs []*models.Job
+	for _, job := range r.db.jobs {
+		if job.Type == models.JobTypeExport && job.Status == models.JobStatusCompleted && job.FilePath != nil &&
+			job.CompletedAt != nil && job.CompletedAt.Before(completedBefore) {
+			jobs = append(jobs, cloneJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CompletedAt.Before(*jobs[j].CompletedAt) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// ClaimStale refreshes the heartbeat of a job that is still processing and
+// stale, reporting whether it did
+func (r *JobRepository) ClaimStale(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	job, ok := r.db.jobs[id]
+	if !ok || !isStale(job, staleBefore) {
+		return false, nil
+	}
+	now := r.db.now()
+	job.HeartbeatAt = &now
+	return true, nil
+}
+
+// lastHeartbeat is when a job was last known to be running
+func lastHeartbeat(job *models.Job) time.Time {
+	switch {
+	case job.HeartbeatAt != nil:
+		return *job.HeartbeatAt
+	case job.StartedAt != nil:
+		return *job.StartedAt
+	default:
+		return job.UpdatedAt
+	}
+}
+
+func isStale(job *models.Job, staleBefore time.Time) bool {
+	return job.Status == models.JobStatusProcessing && lastHeartbeat(job).Before(staleBefore)
+}
+
+// ListByStatus returns jobs in status, most recently updated first
+func (r *JobRepository) ListByStatus(ctx context.Context, status models.JobStatus, page, perPage int) ([]*models.Job, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var jobs []*models.Job
+	for _, job := range r.db.jobs {
+		if job.Status == status {
+			jobs = append(jobs, cloneJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].UpdatedAt.After(jobs[j].UpdatedAt) })
+
+	start, end := paginate(len(jobs), page, perPage)
+	return jobs[start:end], int64(len(jobs)), nil
+}
+
+// List returns the jobs matching filter, newest first
+func (r *JobRepository) List(ctx context.Context, filter models.JobListFilter, page, perPage int) ([]*models.Job, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var jobs []*models.Job
+	for _, job := range r.db.jobs {
+		if (filter.TenantID == "" || job.TenantID == filter.TenantID) &&
+			(filter.Type == "" || job.Type == filter.Type) &&
+			(filter.Status == "" || job.Status == filter.Status) {
+			jobs = append(jobs, cloneJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+
+	start, end := paginate(len(jobs), page, perPage)
+	return jobs[start:end], int64(len(jobs)), nil
+}
+
+// AddErrors adds job errors in batch
+func (r *JobRepository) AddErrors(ctx context.Context, errors []*models.JobError) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, e := range errors {
+		if _, ok := r.db.jobs[e.JobID]; !ok {
+			return errForeignKey("job_errors", "job_id", e.JobID)
+		}
+	}
+	for _, e := range errors {
+		if e.ID == uuid.Nil {
+			e.ID = uuid.New()
+		}
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = r.db.now()
+		}
+		stored := *e
+		r.db.jobErrors = append(r.db.jobErrors, &stored)
+	}
+	return nil
+}
+
+// GetErrors retrieves job errors with pagination
+func (r *JobRepository) GetErrors(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobError, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var errors []*models.JobError
+	for _, e := range r.db.jobErrors {
+		if e.JobID == jobID {
+			stored := *e
+			errors = append(errors, &stored)
+		}
+	}
+	sort.SliceStable(errors, func(i, j int) bool { return errors[i].RowNumber < errors[j].RowNumber })
+
+	start, end := paginate(len(errors), page, perPage)
+	return errors[start:end], int64(len(errors)), nil
+}
+
+// AddWarnings adds job warnings in batch and bumps the jobs' warning counts
+func (r *JobRepository) AddWarnings(ctx context.Context, warnings []*models.JobWarning) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, w := range warnings {
+		if _, ok := r.db.jobs[w.JobID]; !ok {
+			return errForeignKey("job_warnings", "job_id", w.JobID)
+		}
+	}
+	for _, w := range warnings {
+		if w.ID == uuid.Nil {
+			w.ID = uuid.New()
+		}
+		if w.CreatedAt.IsZero() {
+			w.CreatedAt = r.db.now()
+		}
+		stored := *w
+		r.db.jobWarnings = append(r.db.jobWarnings, &stored)
+		r.db.jobs[w.JobID].WarningCount++
+	}
+	return nil
+}
+
+// GetWarnings retrieves job warnings with pagination
+func (r *JobRepository) GetWarnings(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobWarning, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var warnings []*models.JobWarning
+	for _, w := range r.db.jobWarnings {
+		if w.JobID == jobID {
+			stored := *w
+			warnings = append(warnings, &stored)
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].RowNumber < warnings[j].RowNumber })
+
+	start, end := paginate(len(warnings), page, perPage)
+	return warnings[start:end], int64(len(warnings)), nil
+}
+
+// AddLogs stores the captured log lines of a job and how many were dropped
+func (r *JobRepository) AddLogs(ctx context.Context, jobID uuid.UUID, logs []*models.JobLog, dropped int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	job, ok := r.db.jobs[jobID]
+	if !ok {
+		if len(logs) > 0 {
+			return errForeignKey("job_logs", "job_id", jobID)
+		}
+		return nil
+	}
+	for _, l := range logs {
+		if l.ID == uuid.Nil {
+			l.ID = uuid.New()
+		}
+		l.JobID = jobID
+		stored := *l
+		stored.Fields = cloneString(l.Fields)
+		r.db.jobLogs = append(r.db.jobLogs, &stored)
+	}
+	job.LogLinesDropped = dropped
+	return nil
+}
+
+// GetLogs retrieves the stored log lines of a job with pagination
+func (r *JobRepository) GetLogs(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobLog, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var logs []*models.JobLog
+	for _, l := range r.db.jobLogs {
+		if l.JobID == jobID {
+			stored := *l
+			logs = append(logs, &stored)
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool { return logs[i].LineNumber < logs[j].LineNumber })
+
+	start, end := paginate(len(logs), page, perPage)
+	return logs[start:end], int64(len(logs)), nil
+}
+
+// FinishManually moves a pending or processing job to status with reason
+// as its error message, reporting whether the job was still unfinished
+func (r *JobRepository) FinishManually(ctx context.Context, id uuid.UUID, status models.JobStatus, reason string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	job, ok := r.db.jobs[id]
+	if !ok || (job.Status != models.JobStatusPending && job.Status != models.JobStatusProcessing) {
+		return false, nil
+	}
+	now := r.db.now()
+	job.Status = status
+	job.ErrorMessage = &reason
+	job.CompletedAt = &now
+	job.UpdatedAt = now
+	return true, nil
+}
+
+// SetErrorMessage sets only the error message of a job that is still processing
+func (r *JobRepository) SetErrorMessage(ctx context.Context, id uuid.UUID, msg string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	job, ok := r.db.jobs[id]
+	if !ok || job.Status != models.JobStatusProcessing {
+		return false, nil
+	}
+	job.ErrorMessage = &msg
+	job.UpdatedAt = r.db.now()
+	return true, nil
+}
+
+// AddTimelineEntry records a note or manual status change on a job
+func (r *JobRepository) AddTimelineEntry(ctx context.Context, entry *models.JobTimelineEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.jobs[entry.JobID]; !ok {
+		return errForeignKey("job_timeline", "job_id", entry.JobID)
+	}
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = r.db.now()
+	}
+	stored := *entry
+	r.db.jobTimeline = append(r.db.jobTimeline, &stored)
+	return nil
+}
+
+// GetTimeline returns a job's timeline entries, oldest first
+func (r *JobRepository) GetTimeline(ctx context.Context, jobID uuid.UUID) ([]*models.JobTimelineEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var entries []*models.JobTimelineEntry
+	for _, e := range r.db.jobTimeline {
+		if e.JobID == jobID {
+			stored := *e
+			entries = append(entries, &stored)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	return entries, nil
+}
+
+// GetPendingJobs retrieves pending jobs of a specific type, oldest first
+func (r *JobRepository) GetPendingJobs(ctx context.Context, jobType models.JobType, limit int) ([]*models.Job, error) {
+	if limit < 1 {
+		limit = 10
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var jobs []*models.Job
+	for _, job := range r.db.jobs {
+		if job.Type == jobType && job.Status == models.JobStatusPending {
+			jobs = append(jobs, cloneJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// update applies fn to the stored job after bumping its updated_at. Like an
+// UPDATE matching no rows, a missing job is not an error.
+func (r *JobRepository) update(id uuid.UUID, fn func(job *models.Job)) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if job, ok := r.db.jobs[id]; ok {
+		job.UpdatedAt = r.db.now()
+		fn(job)
+	}
+	return nil
+}
+
+func cloneJob(job *models.Job) *models.Job {
+	clone := *job
+	clone.ParentJobID = cloneUUID(job.ParentJobID)
+	clone.IdempotencyKey = cloneString(job.IdempotencyKey)
+	clone.FilePath = cloneString(job.FilePath)
+	clone.FileURL = cloneString(job.FileURL)
+	clone.FileFormat = cloneString(job.FileFormat)
+	clone.ErrorMessage = cloneString(job.ErrorMessage)
+	clone.DataAsOf = cloneTime(job.DataAsOf)
+	clone.StartedAt = cloneTime(job.StartedAt)
+	clone.HeartbeatAt = cloneTime(job.HeartbeatAt)
+	clone.CompletedAt = cloneTime(job.CompletedAt)
+	clone.Phase = cloneString(job.Phase)
+	clone.WorkerInstance = cloneString(job.WorkerInstance)
+	clone.WorkerID = cloneString(job.WorkerID)
+	clone.WorkerVersion = cloneString(job.WorkerVersion)
+	if job.Params != nil {
+		params := *job.Params
+		clone.Params = &params
+	}
+	if job.Summary != nil {
+		summary := *job.Summary
+		summary.RejectedDomains = maps.Clone(job.Summary.RejectedDomains)
+		clone.Summary = &summary
+	}
+	return &clone
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.LedgerRepository = (*LedgerRepository)(nil)
+
+// LedgerRepository implements repository.LedgerRepository in memory
+type LedgerRepository struct {
+	db *DB
+}
+
+// NewLedgerRepository creates a new LedgerRepository
+func NewLedgerRepository(db *DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// ledgerKey is the primary key of a ledger entry
+type ledgerKey struct {
+	resource   models.ResourceType
+	naturalKey string
+}
+
+// Hashes returns the content hash recorded under each of keys that has one
+func (r *LedgerRepository) Hashes(ctx context.Context, resource models.ResourceType, keys []string) (map[string]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	hashes := make(map[string]string)
+	for _, key := range keys {
+		if entry, ok := r.db.ledger[ledgerKey{resource, key}]; ok {
+			hashes[key] = entry.ContentHash
+		}
+	}
+	return hashes, nil
+}
+
+// Record stores entries, replacing any recorded under the same resource and
+// natural key
+func (r *LedgerRepository) Record(ctx context.Context, entries []*models.LedgerEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.RecordedAt.IsZero() {
+			entry.RecordedAt = r.db.now()
+		}
+		stored := *entry
+		r.db.ledger[ledgerKey{entry.Resource, entry.NaturalKey}] = &stored
+	}
+	return nil
+}
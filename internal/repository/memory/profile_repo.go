@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.ProfileRepository = (*ProfileRepository)(nil)
+
+// ProfileRepository implements repository.ProfileRepository in memory
+type ProfileRepository struct {
+	db *DB
+}
+
+// NewProfileRepository creates a new ProfileRepository
+func NewProfileRepository(db *DB) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+// Save stores the profile for a job, replacing any earlier one
+func (r *ProfileRepository) Save(ctx context.Context, profile *models.ImportProfile) error {
+	// Round-trip through JSON like the profile column, which also copies it
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	var stored models.ImportProfile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.jobs[profile.JobID]; !ok {
+		return errForeignKey("job_profiles", "job_id", profile.JobID)
+	}
+	r.db.profiles[profile.JobID] = &stored
+	return nil
+}
+
+// GetByJobID retrieves the profile for a job
+func (r *ProfileRepository) GetByJobID(ctx context.Context, jobID uuid.UUID) (*models.ImportProfile, error) {
+	r.db.mu.Lock()
+	profile, ok := r.db.profiles[jobID]
+	r.db.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return nil, err
+	}
+	var clone models.ImportProfile
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.QuotaRepository = (*QuotaRepository)(nil)
+
+// QuotaRepository implements repository.QuotaRepository in memory
+type QuotaRepository struct {
+	db *DB
+}
+
+// NewQuotaRepository creates a new QuotaRepository
+func NewQuotaRepository(db *DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// GetUsage computes the current quota usage of a tenant from its jobs
+func (r *QuotaRepository) GetUsage(ctx context.Context, tenantID string, dayStart, monthStart time.Time) (*models.QuotaUsage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var usage models.QuotaUsage
+	for _, job := range r.db.jobs {
+		if job.TenantID != tenantID {
+			continue
+		}
+		if !job.CreatedAt.Before(dayStart) {
+			usage.JobsToday++
+		}
+		if !job.CreatedAt.Before(monthStart) {
+			usage.RowsThisMonth += int64(job.TotalRecords)
+		}
+		if job.Type == models.JobTypeExport && job.FilePath != nil {
+			usage.ExportStorageBytes += job.FileSizeBytes
+		}
+	}
+	return &usage, nil
+}
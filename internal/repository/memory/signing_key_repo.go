@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.SigningKeyRepository = (*SigningKeyRepository)(nil)
+
+// SigningKeyRepository implements repository.SigningKeyRepository in memory
+type SigningKeyRepository struct {
+	db *DB
+}
+
+// NewSigningKeyRepository creates a new SigningKeyRepository
+func NewSigningKeyRepository(db *DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// Create stores a new key
+func (r *SigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.signingKeys[key.ID]; ok {
+		return errUnique("signing_keys", "id", key.ID)
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = r.db.now()
+	}
+	r.db.signingKeys[key.ID] = cloneSigningKey(key)
+	return nil
+}
+
+// List returns every key, oldest first
+func (r *SigningKeyRepository) List(ctx context.Context) ([]*models.SigningKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	keys := make([]*models.SigningKey, 0, len(r.db.signingKeys))
+	for _, key := range r.db.signingKeys {
+		keys = append(keys, cloneSigningKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// Delete removes a key
+func (r *SigningKeyRepository) Delete(ctx context.Context, id string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.signingKeys[id]; !ok {
+		return false, nil
+	}
+	delete(r.db.signingKeys, id)
+	return true, nil
+}
+
+func cloneSigningKey(key *models.SigningKey) *models.SigningKey {
+	clone := *key
+	clone.Secret = append([]byte(nil), key.Secret...)
+	return &clone
+}
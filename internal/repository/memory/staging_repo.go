@@ -0,0 +1,598 @@
+package memory
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.StagingRepository = (*StagingRepository)(nil)
+
+// StagingRepository implements repository.StagingRepository in memory,
+// following the marking rules of the PostgreSQL staging queries
+type StagingRepository struct {
+	db *DB
+}
+
+// NewStagingRepository creates a new StagingRepository
+func NewStagingRepository(db *DB) *StagingRepository {
+	return &StagingRepository{db: db}
+}
+
+// CreateStagingUsers inserts users into the staging table
+func (r *StagingRepository) CreateStagingUsers(ctx context.Context, jobID uuid.UUID, users []repository.StagingUser) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range users {
+		r.db.nextStagingID++
+		user.StagingID = r.db.nextStagingID
+		user.JobID = jobID
+		user.IsDuplicate = false
+		user.Processed = false
+		r.db.stagingUsers = append(r.db.stagingUsers, &user)
+	}
+	return nil
+}
+
+// MarkDuplicateUsersInBatch marks every row of the job whose email, compared
+// case-insensitively, was already used by an earlier row
+func (r *StagingRepository) MarkDuplicateUsersInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	seen := make(map[string]bool)
+	marked := 0
+	for _, user := range r.db.stagingUsers {
+		if user.JobID != jobID || user.Email == nil {
+			continue
+		}
+		email := strings.ToLower(*user.Email)
+		if seen[email] {
+			markDuplicate(&user.IsDuplicate, &user.IsValid, &user.ValidationError, "DUPLICATE_EMAIL")
+			marked++
+		}
+		seen[email] = true
+	}
+	return marked, nil
+}
+
+// MarkDuplicateUsersAgainstExisting marks valid rows whose email belongs to
+// a stored user, unless the row updates a stored user by ID
+func (r *StagingRepository) MarkDuplicateUsersAgainstExisting(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	emails := make(map[string]bool, len(r.db.users))
+	for _, user := range r.db.users {
+		emails[strings.ToLower(user.Email)] = true
+	}
+
+	marked := 0
+	for _, user := range r.db.stagingUsers {
+		if user.JobID != jobID || !user.IsValid || user.Processed || user.Email == nil || !emails[strings.ToLower(*user.Email)] {
+			continue
+		}
+		if user.ID != nil && r.userExists(*user.ID) {
+			continue
+		}
+		markDuplicate(&user.IsDuplicate, &user.IsValid, &user.ValidationError, "DUPLICATE_EMAIL")
+		marked++
+	}
+	return marked, nil
+}
+
+// GetValidStagingUsers retrieves valid staging users in batches
+func (r *StagingRepository) GetValidStagingUsers(ctx context.Context, jobID uuid.UUID, batchSize int, callback func([]repository.StagingUser) error) error {
+	r.db.mu.Lock()
+	var users []repository.StagingUser
+	for _, user := range r.db.stagingUsers {
+		if user.JobID == jobID && user.IsValid && !user.IsDuplicate && !user.Processed {
+			users = append(users, *user)
+		}
+	}
+	r.db.mu.Unlock()
+
+	return streamBatches(users, batchSize, callback)
+}
+
+// UpdateStagingUserValidation updates the validation status of a staging user
+func (r *StagingRepository) UpdateStagingUserValidation(ctx context.Context, stagingID int64, isValid bool, errorMsg string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range r.db.stagingUsers {
+		if user.StagingID == stagingID {
+			user.IsValid = isValid
+			user.ValidationError = &errorMsg
+		}
+	}
+	return nil
+}
+
+// CleanupStagingUsers removes staging users for a completed job
+func (r *StagingRepository) CleanupStagingUsers(ctx context.Context, jobID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.stagingUsers = removeJob(r.db.stagingUsers, func(u *repository.StagingUser) bool { return u.JobID == jobID })
+	return nil
+}
+
+// CreateStagingArticles inserts articles into the staging table
+func (r *StagingRepository) CreateStagingArticles(ctx context.Context, jobID uuid.UUID, articles []repository.StagingArticle) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, article := range articles {
+		r.db.nextStagingID++
+		article.StagingID = r.db.nextStagingID
+		article.JobID = jobID
+		article.IsDuplicate = false
+		article.Processed = false
+		r.db.stagingArticles = append(r.db.stagingArticles, &article)
+	}
+	return nil
+}
+
+// MarkDuplicateArticlesInBatch marks every row of the job whose slug,
+// compared case-insensitively, was already used by an earlier row
+func (r *StagingRepository) MarkDuplicateArticlesInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	seen := make(map[string]bool)
+	marked := 0
+	for _, article := range r.db.stagingArticles {
+		if article.JobID != jobID || article.Slug == nil {
+			continue
+		}
+		slug := strings.ToLower(*article.Slug)
+		if seen[slug] {
+			markDuplicate(&article.IsDuplicate, &article.IsValid, &article.ValidationError, "DUPLICATE_SLUG")
+			marked++
+		}
+		seen[slug] = true
+	}
+	return marked, nil
+}
+
+// MarkDuplicateArticlesAgainstExisting marks valid rows whose slug belongs
+// to a stored article, unless the row updates a stored article by ID
+func (r *StagingRepository) MarkDuplicateArticlesAgainstExisting(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	slugs := make(map[string]bool, len(r.db.articles))
+	for _, article := range r.db.articles {
+		slugs[strings.ToLower(article.Slug)] = true
+	}
+
+	marked := 0
+	for _, article := range r.db.stagingArticles {
+		if article.JobID != jobID || !article.IsValid || article.Processed || article.Slug == nil || !slugs[strings.ToLower(*article.Slug)] {
+			continue
+		}
+		if article.ID != nil && r.articleExists(*article.ID) {
+			continue
+		}
+		markDuplicate(&article.IsDuplicate, &article.IsValid, &article.ValidationError, "DUPLICATE_SLUG")
+		marked++
+	}
+	return marked, nil
+}
+
+// MarkInvalidAuthorFKArticles marks valid rows whose author is not a user
+func (r *StagingRepository) MarkInvalidAuthorFKArticles(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	marked := 0
+	for _, article := range r.db.stagingArticles {
+		if article.JobID != jobID || !article.IsValid || article.Processed || article.AuthorID == nil || r.userExists(*article.AuthorID) {
+			continue
+		}
+		markInvalid(&article.IsValid, &article.ValidationError, "INVALID_AUTHOR_FK")
+		marked++
+	}
+	return marked, nil
+}
+
+// MissingArticleAuthors returns the distinct author IDs of valid staged
+// articles that aren't users
+func (r *StagingRepository) MissingArticleAuthors(ctx context.Context, jobID uuid.UUID) ([]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var authors []string
+	for _, article := range r.db.stagingArticles {
+		if article.JobID != jobID || !article.IsValid || article.Processed || article.AuthorID == nil || seen[*article.AuthorID] || r.userExists(*article.AuthorID) {
+			continue
+		}
+		seen[*article.AuthorID] = true
+		authors = append(authors, *article.AuthorID)
+	}
+	return authors, nil
+}
+
+// GetValidStagingArticles retrieves valid staging articles in batches
+func (r *StagingRepository) GetValidStagingArticles(ctx context.Context, jobID uuid.UUID, batchSize int, callback func([]repository.StagingArticle) error) error {
+	r.db.mu.Lock()
+	var articles []repository.StagingArticle
+	for _, article := range r.db.stagingArticles {
+		if article.JobID == jobID && article.IsValid && !article.IsDuplicate && !article.Processed {
+			articles = append(articles, *article)
+		}
+	}
+	r.db.mu.Unlock()
+
+	return streamBatches(articles, batchSize, callback)
+}
+
+// UpdateStagingArticleValidation updates the validation status of a staging article
+func (r *StagingRepository) UpdateStagingArticleValidation(ctx context.Context, stagingID int64, isValid bool, errorMsg string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, article := range r.db.stagingArticles {
+		if article.StagingID == stagingID {
+			article.IsValid = isValid
+			article.ValidationError = &errorMsg
+		}
+	}
+	return nil
+}
+
+// CleanupStagingArticles removes staging articles for a completed job
+func (r *StagingRepository) CleanupStagingArticles(ctx context.Context, jobID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.stagingArticles = removeJob(r.db.stagingArticles, func(a *repository.StagingArticle) bool { return a.JobID == jobID })
+	return nil
+}
+
+// CreateStagingComments inserts comments into the staging table
+func (r *StagingRepository) CreateStagingComments(ctx context.Context, jobID uuid.UUID, comments []repository.StagingComment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, comment := range comments {
+		r.db.nextStagingID++
+		comment.StagingID = r.db.nextStagingID
+		comment.JobID = jobID
+		comment.IsDuplicate = false
+		comment.Processed = false
+		r.db.stagingComments = append(r.db.stagingComments, &comment)
+	}
+	return nil
+}
+
+// MarkDuplicateCommentsInBatch marks every row of the job whose ID was
+// already used by an earlier row
+func (r *StagingRepository) MarkDuplicateCommentsInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	seen := make(map[string]bool)
+	marked := 0
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID != jobID || comment.ID == nil {
+			continue
+		}
+		if seen[*comment.ID] {
+			markDuplicate(&comment.IsDuplicate, &comment.IsValid, &comment.ValidationError, "DUPLICATE_ID")
+			marked++
+		}
+		seen[*comment.ID] = true
+	}
+	return marked, nil
+}
+
+// SetCommentNaturalKeys computes the natural key of the job's valid rows
+func (r *StagingRepository) SetCommentNaturalKeys(ctx context.Context, jobID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID != jobID || !comment.IsValid {
+			continue
+		}
+		comment.NaturalKey = stagingNaturalKey(comment)
+	}
+	return nil
+}
+
+// MarkDuplicateCommentsByNaturalKeyInBatch marks every row of the job whose
+// natural key was already used by an earlier row
+func (r *StagingRepository) MarkDuplicateCommentsByNaturalKeyInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	seen := make(map[string]bool)
+	marked := 0
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID != jobID || comment.NaturalKey == nil {
+			continue
+		}
+		if seen[*comment.NaturalKey] {
+			markDuplicate(&comment.IsDuplicate, &comment.IsValid, &comment.ValidationError, "DUPLICATE_COMMENT")
+			marked++
+		}
+		seen[*comment.NaturalKey] = true
+	}
+	return marked, nil
+}
+
+// MarkDuplicateCommentsByNaturalKeyAgainstExisting marks valid rows whose
+// natural key matches a stored comment other than the row itself
+func (r *StagingRepository) MarkDuplicateCommentsByNaturalKeyAgainstExisting(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// natural key -> IDs of the stored comments with that key
+	existing := make(map[string][]string, len(r.db.comments))
+	for _, c := range r.db.comments {
+		t := c.CreatedAt
+		key := commentNaturalKey(c.ArticleID.String(), c.UserID.String(), c.Body, &t)
+		existing[key] = append(existing[key], c.ID.String())
+	}
+
+	marked := 0
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID != jobID || !comment.IsValid || comment.Processed || comment.NaturalKey == nil {
+			continue
+		}
+		for _, id := range existing[*comment.NaturalKey] {
+			if comment.ID == nil || id != *comment.ID {
+				markDuplicate(&comment.IsDuplicate, &comment.IsValid, &comment.ValidationError, "DUPLICATE_COMMENT")
+				marked++
+				break
+			}
+		}
+	}
+	return marked, nil
+}
+
+// MarkInvalidFKComments marks valid rows whose article or user doesn't exist
+func (r *StagingRepository) MarkInvalidFKComments(ctx context.Context, jobID uuid.UUID) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	marked := 0
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID != jobID || !comment.IsValid || comment.Processed {
+			continue
+		}
+		switch {
+		case comment.ArticleID != nil && !r.articleExists(*comment.ArticleID):
+			markInvalid(&comment.IsValid, &comment.ValidationError, "INVALID_ARTICLE_FK")
+		case comment.UserID != nil && !r.userExists(*comment.UserID):
+			markInvalid(&comment.IsValid, &comment.ValidationError, "INVALID_USER_FK")
+		default:
+			continue
+		}
+		marked++
+	}
+	return marked, nil
+}
+
+// GetValidStagingComments retrieves valid staging comments in batches
+func (r *StagingRepository) GetValidStagingComments(ctx context.Context, jobID uuid.UUID, batchSize int, callback func([]repository.StagingComment) error) error {
+	r.db.mu.Lock()
+	var comments []repository.StagingComment
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID == jobID && comment.IsValid && !comment.IsDuplicate && !comment.Processed {
+			comments = append(comments, *comment)
+		}
+	}
+	r.db.mu.Unlock()
+
+	return streamBatches(comments, batchSize, callback)
+}
+
+// UpdateStagingCommentValidation updates the validation status of a staging comment
+func (r *StagingRepository) UpdateStagingCommentValidation(ctx context.Context, stagingID int64, isValid bool, errorMsg string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, comment := range r.db.stagingComments {
+		if comment.StagingID == stagingID {
+			comment.IsValid = isValid
+			comment.ValidationError = &errorMsg
+		}
+	}
+	return nil
+}
+
+// CleanupStagingComments removes staging comments for a completed job
+func (r *StagingRepository) CleanupStagingComments(ctx context.Context, jobID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.stagingComments = removeJob(r.db.stagingComments, func(c *repository.StagingComment) bool { return c.JobID == jobID })
+	return nil
+}
+
+// KeepCommittedUsers implements repository.StagingRepository
+func (r *StagingRepository) KeepCommittedUsers(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var kept []repository.CommittedRow
+	r.db.stagingUsers = removeJob(r.db.stagingUsers, func(u *repository.StagingUser) bool {
+		return u.JobID == jobID && !keepCommitted(&kept, u.StagingID, u.RowNumber, through, u.IsValid && !u.IsDuplicate, &u.Processed)
+	})
+	return kept, nil
+}
+
+// KeepCommittedArticles implements repository.StagingRepository
+func (r *StagingRepository) KeepCommittedArticles(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var kept []repository.CommittedRow
+	r.db.stagingArticles = removeJob(r.db.stagingArticles, func(a *repository.StagingArticle) bool {
+		return a.JobID == jobID && !keepCommitted(&kept, a.StagingID, a.RowNumber, through, a.IsValid && !a.IsDuplicate, &a.Processed)
+	})
+	return kept, nil
+}
+
+// KeepCommittedComments implements repository.StagingRepository
+func (r *StagingRepository) KeepCommittedComments(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var kept []repository.CommittedRow
+	r.db.stagingComments = removeJob(r.db.stagingComments, func(c *repository.StagingComment) bool {
+		return c.JobID == jobID && !keepCommitted(&kept, c.StagingID, c.RowNumber, through, c.IsValid && !c.IsDuplicate, &c.Processed)
+	})
+	return kept, nil
+}
+
+// keepCommitted marks a staging row processed when it is valid and within
+// through, and adds it to kept if it is processed, reporting whether the
+// row stays
+func keepCommitted(kept *[]repository.CommittedRow, stagingID int64, row int, through int64, valid bool, processed *bool) bool {
+	if !*processed && valid && stagingID <= through {
+		*processed = true
+	}
+	if *processed {
+		*kept = append(*kept, repository.CommittedRow{StagingID: stagingID, RowNumber: row})
+	}
+	return *processed
+}
+
+// StagingUsers returns a copy of the staging users of a job in insert order,
+// for tests that inspect how rows were marked
+func (r *StagingRepository) StagingUsers(jobID uuid.UUID) []repository.StagingUser {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var users []repository.StagingUser
+	for _, user := range r.db.stagingUsers {
+		if user.JobID == jobID {
+			users = append(users, *user)
+		}
+	}
+	return users
+}
+
+// StagingArticles returns a copy of the staging articles of a job in insert
+// order, for tests that inspect how rows were marked
+func (r *StagingRepository) StagingArticles(jobID uuid.UUID) []repository.StagingArticle {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var articles []repository.StagingArticle
+	for _, article := range r.db.stagingArticles {
+		if article.JobID == jobID {
+			articles = append(articles, *article)
+		}
+	}
+	return articles
+}
+
+// StagingComments returns a copy of the staging comments of a job in insert
+// order, for tests that inspect how rows were marked
+func (r *StagingRepository) StagingComments(jobID uuid.UUID) []repository.StagingComment {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var comments []repository.StagingComment
+	for _, comment := range r.db.stagingComments {
+		if comment.JobID == jobID {
+			comments = append(comments, *comment)
+		}
+	}
+	return comments
+}
+
+// userExists matches a staging ID against stored users by text, like
+// u.id::text = s.id
+func (r *StagingRepository) userExists(id string) bool {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.String() != id {
+		return false
+	}
+	_, ok := r.db.users[parsed]
+	return ok
+}
+
+// articleExists matches a staging ID against stored articles by text
+func (r *StagingRepository) articleExists(id string) bool {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.String() != id {
+		return false
+	}
+	_, ok := r.db.articles[parsed]
+	return ok
+}
+
+func markDuplicate(isDuplicate, isValid *bool, validationError **string, code string) {
+	*isDuplicate = true
+	markInvalid(isValid, validationError, code)
+}
+
+func markInvalid(isValid *bool, validationError **string, code string) {
+	*isValid = false
+	*validationError = &code
+}
+
+func removeJob[T any](rows []T, ofJob func(T) bool) []T {
+	kept := rows[:0]
+	for _, row := range rows {
+		if !ofJob(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// stagingNaturalKey computes the natural key of a staging row. Like the SQL
+// function it is NULL when the article, user or body is missing.
+func stagingNaturalKey(comment *repository.StagingComment) *string {
+	if comment.ArticleID == nil || comment.UserID == nil || comment.Body == nil {
+		return nil
+	}
+	articleID, err := uuid.Parse(*comment.ArticleID)
+	if err != nil {
+		return nil
+	}
+	userID, err := uuid.Parse(*comment.UserID)
+	if err != nil {
+		return nil
+	}
+	var createdAt *time.Time
+	if comment.CreatedAt != nil {
+		t, err := time.Parse(time.RFC3339Nano, *comment.CreatedAt)
+		if err != nil {
+			return nil
+		}
+		createdAt = &t
+	}
+	key := commentNaturalKey(articleID.String(), userID.String(), *comment.Body, createdAt)
+	return &key
+}
+
+// commentNaturalKey mirrors the comment_natural_key SQL function: the md5 of
+// the article, user, normalized body and creation second
+func commentNaturalKey(articleID, userID, body string, createdAt *time.Time) string {
+	created := ""
+	if createdAt != nil {
+		created = strconv.FormatInt(createdAt.Unix(), 10)
+	}
+	normalized := strings.ToLower(strings.TrimSpace(whitespace.ReplaceAllString(body, " ")))
+	sum := md5.Sum([]byte(articleID + "|" + userID + "|" + normalized + "|" + created))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.TombstoneRepository = (*TombstoneRepository)(nil)
+
+// TombstoneRepository implements repository.TombstoneRepository in memory.
+// Tombstones are recorded by the Delete methods of the other repositories,
+// as the delete triggers do in PostgreSQL.
+type TombstoneRepository struct {
+	db *DB
+}
+
+// NewTombstoneRepository creates a new TombstoneRepository
+func NewTombstoneRepository(db *DB) *TombstoneRepository {
+	return &TombstoneRepository{db: db}
+}
+
+// GetDeletedWithCursor streams tombstones for a resource deleted in (from, to]
+func (r *TombstoneRepository) GetDeletedWithCursor(ctx context.Context, resource models.ResourceType, from, to time.Time, batchSize int, callback func([]*models.Tombstone) error) error {
+	r.db.mu.Lock()
+	var tombstones []*models.Tombstone
+	for _, tombstone := range r.db.tombstones {
+		if tombstone.Resource == resource && tombstone.DeletedAt.After(from) && !tombstone.DeletedAt.After(to) {
+			clone := *tombstone
+			tombstones = append(tombstones, &clone)
+		}
+	}
+	r.db.mu.Unlock()
+
+	// Tombstones are appended in deletion order, which is also ID order
+	return streamBatches(tombstones, batchSize, callback)
+}
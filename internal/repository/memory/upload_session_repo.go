@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.UploadSessionRepository = (*UploadSessionRepository)(nil)
+
+// UploadSessionRepository implements repository.UploadSessionRepository in
+// memory
+type UploadSessionRepository struct {
+	db *DB
+}
+
+// NewUploadSessionRepository creates a new UploadSessionRepository
+func NewUploadSessionRepository(db *DB) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+// Create stores a new session
+func (r *UploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = r.db.now()
+	}
+	if session.Status == "" {
+		session.Status = models.UploadSessionOpen
+	}
+	stored := *session
+	r.db.uploadSessions[session.ID] = &stored
+	return nil
+}
+
+// GetByID returns the session with id, or nil if there is none
+func (r *UploadSessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UploadSession, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	session, ok := r.db.uploadSessions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+// PutPart records a received part, replacing one with the same number
+func (r *UploadSessionRepository) PutPart(ctx context.Context, part *models.UploadPart) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.uploadSessions[part.SessionID]; !ok {
+		return errForeignKey("upload_parts", "session_id", part.SessionID)
+	}
+	if part.ReceivedAt.IsZero() {
+		part.ReceivedAt = r.db.now()
+	}
+	if r.db.uploadParts[part.SessionID] == nil {
+		r.db.uploadParts[part.SessionID] = make(map[int]*models.UploadPart)
+	}
+	stored := *part
+	r.db.uploadParts[part.SessionID][part.PartNumber] = &stored
+	return nil
+}
+
+// ListParts returns the parts of a session by part number
+func (r *UploadSessionRepository) ListParts(ctx context.Context, sessionID uuid.UUID) ([]*models.UploadPart, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	parts := make([]*models.UploadPart, 0, len(r.db.uploadParts[sessionID]))
+	for _, part := range r.db.uploadParts[sessionID] {
+		copied := *part
+		parts = append(parts, &copied)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+// Complete marks an open session completed by jobID, returning false when it
+// was no longer open
+func (r *UploadSessionRepository) Complete(ctx context.Context, id, jobID uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	session, ok := r.db.uploadSessions[id]
+	if !ok || session.Status != models.UploadSessionOpen {
+		return false, nil
+	}
+	session.Status = models.UploadSessionCompleted
+	session.JobID = &jobID
+	return true, nil
+}
+
+// DeleteExpired removes the sessions that expired before now, with their
+// parts, and returns their IDs
+func (r *UploadSessionRepository) DeleteExpired(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var ids []uuid.UUID
+	for id, session := range r.db.uploadSessions {
+		if session.ExpiresAt.Before(now) {
+			delete(r.db.uploadSessions, id)
+			delete(r.db.uploadParts, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.UsageRepository = (*UsageRepository)(nil)
+
+const dayLayout = "2006-01-02"
+
+// UsageRepository implements repository.UsageRepository in memory
+type UsageRepository struct {
+	db *DB
+}
+
+// NewUsageRepository creates a new UsageRepository
+func NewUsageRepository(db *DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// GetUsage aggregates the jobs created in [from, to) per tenant
+func (r *UsageRepository) GetUsage(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return sortedUsage(r.db.aggregateUsage(from, to, tenantID)), nil
+}
+
+// GetRollups sums the daily rollups of the days in [from, to) per tenant
+func (r *UsageRepository) GetRollups(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	first, last := from.UTC().Format(dayLayout), to.UTC().Format(dayLayout)
+	byTenant := make(map[string]*models.TenantUsage)
+	for day, tenants := range r.db.usageDaily {
+		if day < first || day >= last {
+			continue
+		}
+		for id, usage := range tenants {
+			if tenantID != "" && id != tenantID {
+				continue
+			}
+			sum, ok := byTenant[id]
+			if !ok {
+				sum = &models.TenantUsage{TenantID: id}
+				byTenant[id] = sum
+			}
+			sum.Add(usage)
+		}
+	}
+	return sortedUsage(byTenant), nil
+}
+
+// RolledUpUntil returns the end of the last rolled-up day, or nil if no day
+// has been rolled up yet
+func (r *UsageRepository) RolledUpUntil(ctx context.Context) (*time.Time, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	last := ""
+	for day := range r.db.usageDaily {
+		if day > last {
+			last = day
+		}
+	}
+	if last == "" {
+		return nil, nil
+	}
+	day, err := time.Parse(dayLayout, last)
+	if err != nil {
+		return nil, err
+	}
+	until := day.AddDate(0, 0, 1)
+	return &until, nil
+}
+
+// FirstJobAt returns when the oldest job was created, or nil without jobs
+func (r *UsageRepository) FirstJobAt(ctx context.Context) (*time.Time, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var first *time.Time
+	for _, job := range r.db.jobs {
+		if first == nil || job.CreatedAt.Before(*first) {
+			createdAt := job.CreatedAt
+			first = &createdAt
+		}
+	}
+	return first, nil
+}
+
+// RollupDay replaces the rollup of the UTC day starting at day with an
+// aggregate of the jobs created on it
+func (r *UsageRepository) RollupDay(ctx context.Context, day time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	day = day.UTC()
+	r.db.usageDaily[day.Format(dayLayout)] = r.db.aggregateUsage(day, day.AddDate(0, 0, 1), "")
+	return nil
+}
+
+// aggregateUsage mirrors the postgres usage aggregate; callers hold db.mu
+func (db *DB) aggregateUsage(from, to time.Time, tenantID string) map[string]*models.TenantUsage {
+	byTenant := make(map[string]*models.TenantUsage)
+	for _, job := range db.jobs {
+		if job.CreatedAt.Before(from) || !job.CreatedAt.Before(to) {
+			continue
+		}
+		if tenantID != "" && job.TenantID != tenantID {
+			continue
+		}
+		usage, ok := byTenant[job.TenantID]
+		if !ok {
+			usage = &models.TenantUsage{TenantID: job.TenantID}
+			byTenant[job.TenantID] = usage
+		}
+		usage.Jobs++
+		if job.Status == models.JobStatusFailed || job.Status == models.JobStatusDeadLetter {
+			usage.FailedJobs++
+		}
+		switch job.Type {
+		case models.JobTypeImport:
+			usage.ImportJobs++
+			usage.RowsImported += int64(job.SuccessfulRecords)
+			usage.RowsFailed += int64(job.FailedRecords)
+		case models.JobTypeExport:
+			usage.ExportJobs++
+			usage.RowsExported += int64(job.SuccessfulRecords)
+			if job.FilePath != nil {
+				usage.BytesStored += job.FileSizeBytes
+			}
+		}
+	}
+	return byTenant
+}
+
+func sortedUsage(byTenant map[string]*models.TenantUsage) []*models.TenantUsage {
+	usage := make([]*models.TenantUsage, 0, len(byTenant))
+	for _, u := range byTenant {
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].TenantID < usage[j].TenantID })
+	return usage
+}
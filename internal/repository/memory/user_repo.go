@@ -0,0 +1,427 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+// UserRepository implements repository.UserRepository in memory
+type UserRepository struct {
+	db *DB
+}
+
+// NewUserRepository creates a new UserRepository
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// Create inserts a new user
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.defaults(user)
+	if _, ok := r.db.users[user.ID]; ok {
+		return errUnique("users", "id", user.ID.String())
+	}
+	if err := r.checkEmail(user); err != nil {
+		return err
+	}
+	r.db.users[user.ID] = cloneUser(user)
+	return nil
+}
+
+// CreateBatch inserts users, updating any whose ID already exists. Like the
+// single INSERT it replaces, either every row is written or none is.
+func (r *UserRepository) CreateBatch(ctx context.Context, users []*models.User) (int, error) {
+	inserted, updated, err := r.UpsertBatch(ctx, users, models.UpsertKeyID)
+	return inserted + updated, err
+}
+
+// CreateMissing inserts the users whose ID and email aren't taken
+func (r *UserRepository) CreateMissing(ctx context.Context, users []*models.User) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	created := 0
+	for _, user := range users {
+		r.defaults(user)
+		if _, ok := r.db.users[user.ID]; ok {
+			continue
+		}
+		if r.checkEmail(user) != nil {
+			continue
+		}
+		r.db.users[user.ID] = cloneUser(user)
+		created++
+	}
+	return created, nil
+}
+
+// GetByID retrieves a user by ID
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if user, ok := r.db.users[id]; ok {
+		return cloneUser(user), nil
+	}
+	return nil, nil
+}
+
+// GetByEmail retrieves a user by email
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range r.db.users {
+		if user.Email == email {
+			return cloneUser(user), nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAll retrieves all users with optional filters
+func (r *UserRepository) GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.selectUsers(filters), nil
+}
+
+// GetAllWithCursor streams users in batches. The rows are copied before the
+// first callback, so callbacks may use the repositories.
+func (r *UserRepository) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.User) error) error {
+	users, _ := r.GetAll(ctx, filters)
+	return streamBatches(users, batchSize, callback)
+}
+
+// GetAllWithCountsWithCursor streams users like GetAllWithCursor, along with
+// the number of articles and comments each has written
+func (r *UserRepository) GetAllWithCountsWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.UserWithCounts) error) error {
+	r.db.mu.Lock()
+	users := r.selectUsers(filters)
+	articles := make(map[uuid.UUID]int64)
+	for _, article := range r.db.articles {
+		articles[article.AuthorID]++
+	}
+	comments := make(map[uuid.UUID]int64)
+	for _, comment := range r.db.comments {
+		comments[comment.UserID]++
+	}
+	r.db.mu.Unlock()
+
+	counted := make([]*models.UserWithCounts, len(users))
+	for i, user := range users {
+		counted[i] = &models.UserWithCounts{User: *user, ArticleCount: articles[user.ID], CommentCount: comments[user.ID]}
+	}
+	return streamBatches(counted, batchSize, callback)
+}
+
+// Update updates an existing user
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.users[user.ID]
+	if !ok {
+		return nil
+	}
+	if err := r.checkEmail(user); err != nil {
+		return err
+	}
+	user.UpdatedAt = r.db.now()
+	updated := cloneUser(user)
+	updated.CreatedAt = existing.CreatedAt
+	r.db.users[user.ID] = updated
+	return nil
+}
+
+// Upsert inserts a user or updates the one matching it on key
+func (r *UserRepository) Upsert(ctx context.Context, user *models.User, key models.UpsertKey) error {
+	user.UpdatedAt = r.db.now()
+	_, _, err := r.UpsertBatch(ctx, []*models.User{user}, key)
+	return err
+}
+
+// UpsertBatch inserts users, updating those matching a stored user on key.
+// Users matched on email take the stored user's ID. Either every row is
+// written or none is.
+func (r *UserRepository) UpsertBatch(ctx context.Context, users []*models.User, key models.UpsertKey) (int, int, error) {
+	if len(users) == 0 {
+		return 0, 0, nil
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range users {
+		r.defaults(user)
+	}
+	switch key {
+	case models.UpsertKeyID, "":
+	case models.UpsertKeyEmail:
+		if err := r.matchEmails(users); err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("users cannot be upserted on %q", key)
+	}
+
+	emails := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		if other, ok := emails[user.Email]; ok && other != user.ID {
+			return 0, 0, errUnique("users", "email", user.Email)
+		}
+		emails[user.Email] = user.ID
+		if err := r.checkEmail(user, users...); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	inserted := 0
+	for _, user := range users {
+		if existing, ok := r.db.users[user.ID]; ok {
+			updated := cloneUser(user)
+			updated.CreatedAt = existing.CreatedAt
+			if updated.ImportJobID == nil {
+				updated.ImportJobID = cloneUUID(existing.ImportJobID)
+			}
+			r.db.users[user.ID] = updated
+			continue
+		}
+		r.db.users[user.ID] = cloneUser(user)
+		inserted++
+	}
+	return inserted, len(users) - inserted, nil
+}
+
+// matchEmails gives the users whose email is stored that user's ID. Like the
+// primary key, it refuses a new email under a stored user's ID.
+func (r *UserRepository) matchEmails(users []*models.User) error {
+	stored := make(map[string]uuid.UUID, len(r.db.users))
+	for id, user := range r.db.users {
+		stored[user.Email] = id
+	}
+	for _, user := range users {
+		if _, ok := stored[user.Email]; ok {
+			continue
+		}
+		if _, ok := r.db.users[user.ID]; ok {
+			return errUnique("users", "id", user.ID.String())
+		}
+	}
+	for _, user := range users {
+		if id, ok := stored[user.Email]; ok {
+			user.ID = id
+		}
+	}
+	return nil
+}
+
+// Delete deletes a user by ID. Like the foreign keys, it refuses while
+// articles or comments still reference the user.
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[id]; !ok {
+		return nil
+	}
+	for _, article := range r.db.articles {
+		if article.AuthorID == id {
+			return errReferenced("users", "articles", id)
+		}
+	}
+	for _, comment := range r.db.comments {
+		if comment.UserID == id {
+			return errReferenced("users", "comments", id)
+		}
+	}
+	delete(r.db.users, id)
+	r.db.addTombstone(models.ResourceTypeUsers, id)
+	return nil
+}
+
+// Exists checks if a user exists by ID
+func (r *UserRepository) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	_, ok := r.db.users[id]
+	return ok, nil
+}
+
+// EmailExists checks if an email exists, optionally excluding a specific user
+func (r *UserRepository) EmailExists(ctx context.Context, email string, excludeID *uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range r.db.users {
+		if user.Email == email && (excludeID == nil || user.ID != *excludeID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ExistingEmails returns which of the lowercased emails belong to a user
+func (r *UserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	wanted := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		wanted[email] = true
+	}
+	found := make(map[string]bool)
+	for _, user := range r.db.users {
+		if email := strings.ToLower(user.Email); wanted[email] {
+			found[email] = true
+		}
+	}
+	return found, nil
+}
+
+// GetByEmails returns the users with any of emails, keyed by their
+// lowercased email
+func (r *UserRepository) GetByEmails(ctx context.Context, emails []string) (map[string]*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	wanted := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		wanted[email] = true
+	}
+	result := make(map[string]*models.User)
+	for _, user := range r.db.users {
+		if email := strings.ToLower(user.Email); wanted[email] {
+			result[email] = cloneUser(user)
+		}
+	}
+	return result, nil
+}
+
+// ExistingIDs returns which of ids are users, keyed by their canonical text
+// form. Malformed IDs are never found.
+func (r *UserRepository) ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	found := make(map[string]bool)
+	for _, id := range parseIDs(ids) {
+		if _, ok := r.db.users[id]; ok {
+			found[id.String()] = true
+		}
+	}
+	return found, nil
+}
+
+// GetByIDs retrieves multiple users by their IDs
+func (r *UserRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	result := make(map[uuid.UUID]*models.User)
+	for _, id := range ids {
+		if user, ok := r.db.users[id]; ok {
+			result[id] = cloneUser(user)
+		}
+	}
+	return result, nil
+}
+
+// Count returns the number of users matching the filters
+func (r *UserRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return int64(len(r.selectUsers(filters))), nil
+}
+
+func (r *UserRepository) defaults(user *models.User) {
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = r.db.now()
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = r.db.now()
+	}
+}
+
+// checkEmail enforces the unique email constraint against stored users,
+// ignoring the user itself and any users being written alongside it
+func (r *UserRepository) checkEmail(user *models.User, batch ...*models.User) error {
+	for _, existing := range r.db.users {
+		if existing.Email != user.Email || existing.ID == user.ID {
+			continue
+		}
+		if rewritten(existing.ID, batch, func(u *models.User) uuid.UUID { return u.ID }, func(u *models.User) bool { return u.Email != existing.Email }) {
+			continue
+		}
+		return errUnique("users", "email", user.Email)
+	}
+	return nil
+}
+
+func (r *UserRepository) selectUsers(filters *models.ExportFilters) []*models.User {
+	var ids map[uuid.UUID]bool
+	var emails map[string]bool
+	if filters != nil && len(filters.IDs) > 0 {
+		ids = make(map[uuid.UUID]bool, len(filters.IDs))
+		for _, id := range filters.IDs {
+			ids[id] = true
+		}
+	}
+	if filters != nil && len(filters.Emails) > 0 {
+		emails = make(map[string]bool, len(filters.Emails))
+		for _, email := range filters.Emails {
+			emails[email] = true
+		}
+	}
+
+	users := make([]*models.User, 0, len(r.db.users))
+	for _, user := range r.db.users {
+		if filters != nil {
+			if filters.Role != nil && user.Role != *filters.Role {
+				continue
+			}
+			if filters.Active != nil && user.Active != *filters.Active {
+				continue
+			}
+			if filters.Placeholder != nil && user.Placeholder != *filters.Placeholder {
+				continue
+			}
+			if !importedBy(filters, user.ImportJobID) {
+				continue
+			}
+		}
+		if ids != nil && !ids[user.ID] {
+			continue
+		}
+		if emails != nil && !emails[strings.ToLower(user.Email)] {
+			continue
+		}
+		if !timeFilter(filters, user.CreatedAt, user.UpdatedAt) {
+			continue
+		}
+		users = append(users, cloneUser(user))
+	}
+	byCreatedAt(users, func(u *models.User) time.Time { return u.CreatedAt }, func(u *models.User) uuid.UUID { return u.ID })
+	return users
+}
+
+func cloneUser(user *models.User) *models.User {
+	clone := *user
+	clone.ImportJobID = cloneUUID(user.ImportJobID)
+	return &clone
+}
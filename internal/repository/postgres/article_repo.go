@@ -39,31 +39,54 @@ func (r *ArticleRepository) Create(ctx context.Context, article *models.Article)
 	}
 
 	query := `
-		INSERT INTO articles (id, slug, title, body, author_id, tags, published_at, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO articles (id, slug, title, body, author_id, tags, published_at, status, lang, import_job_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		article.ID, article.Slug, article.Title, article.Body, article.AuthorID,
-		article.Tags, article.PublishedAt, article.Status, article.CreatedAt, article.UpdatedAt)
+		article.Tags, article.PublishedAt, article.Status, article.Lang, article.ImportJobID, article.CreatedAt, article.UpdatedAt)
 	return err
 }
 
-// CreateBatch inserts multiple articles
+// CreateBatch inserts multiple articles, updating any whose ID already exists
 func (r *ArticleRepository) CreateBatch(ctx context.Context, articles []*models.Article) (int, error) {
+	inserted, updated, err := r.UpsertBatch(ctx, articles, models.UpsertKeyID)
+	return inserted + updated, err
+}
+
+// articleUpsertSet overwrites the columns of an article an upsert matched,
+// all but its identity. An import without language detection keeps the
+// stored language, and a write outside an import the job that last imported
+// the article.
+const articleUpsertSet = `
+	title = EXCLUDED.title,
+	body = EXCLUDED.body,
+	author_id = EXCLUDED.author_id,
+	tags = EXCLUDED.tags,
+	published_at = EXCLUDED.published_at,
+	status = EXCLUDED.status,
+	lang = COALESCE(EXCLUDED.lang, articles.lang),
+	import_job_id = COALESCE(EXCLUDED.import_job_id, articles.import_job_id),
+	updated_at = EXCLUDED.updated_at`
+
+// UpsertBatch upserts articles in one transaction. Articles matched on slug
+// take the stored article's ID.
+func (r *ArticleRepository) UpsertBatch(ctx context.Context, articles []*models.Article, key models.UpsertKey) (int, int, error) {
 	if len(articles) == 0 {
-		return 0, nil
+		return 0, 0, nil
 	}
 
-	tx, err := r.db.BeginTx(ctx)
-	if err != nil {
-		return 0, err
+	var conflict string
+	switch key {
+	case models.UpsertKeyID, "":
+		conflict = "ON CONFLICT (id) DO UPDATE SET slug = EXCLUDED.slug," + articleUpsertSet
+	case models.UpsertKeySlug:
+		conflict = "ON CONFLICT (slug) DO UPDATE SET" + articleUpsertSet
+	default:
+		return 0, 0, fmt.Errorf("articles cannot be upserted on %q", key)
 	}
-	defer tx.Rollback()
-
-	valueStrings := make([]string, 0, len(articles))
-	valueArgs := make([]interface{}, 0, len(articles)*10)
 
-	for i, article := range articles {
+	for _, article := range articles {
 		if article.ID == uuid.Nil {
 			article.ID = uuid.New()
 		}
@@ -76,39 +99,74 @@ func (r *ArticleRepository) CreateBatch(ctx context.Context, articles []*models.
 		if article.Tags == nil {
 			article.Tags = json.RawMessage("[]")
 		}
+	}
+
+	// Bodies can be large, so the batch is split into statements by size
+	size := func(i int) int {
+		return len(articles[i].Body) + len(articles[i].Title) + len(articles[i].Tags)
+	}
+	inserted, updated := 0, 0
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		for _, chunk := range r.db.chunkBySize(len(articles), size) {
+			ins, upd, err := r.upsertChunk(ctx, articles[chunk[0]:chunk[1]], conflict)
+			if err != nil {
+				return err
+			}
+			inserted += ins
+			updated += upd
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return inserted, updated, nil
+}
+
+// upsertChunk upserts articles with one multi-row INSERT
+func (r *ArticleRepository) upsertChunk(ctx context.Context, articles []*models.Article, conflict string) (int, int, error) {
+	valueStrings := make([]string, 0, len(articles))
+	valueArgs := make([]interface{}, 0, len(articles)*12)
 
-		base := i * 10
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10))
+	for i, article := range articles {
+		base := i * 12
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10, base+11, base+12))
 		valueArgs = append(valueArgs, article.ID, article.Slug, article.Title, article.Body, article.AuthorID,
-			article.Tags, article.PublishedAt, article.Status, article.CreatedAt, article.UpdatedAt)
+			article.Tags, article.PublishedAt, article.Status, article.Lang, article.ImportJobID, article.CreatedAt, article.UpdatedAt)
 	}
 
+	// xmax is zero for the rows the statement inserted
 	query := fmt.Sprintf(`
-		INSERT INTO articles (id, slug, title, body, author_id, tags, published_at, status, created_at, updated_at)
+		INSERT INTO articles (id, slug, title, body, author_id, tags, published_at, status, lang, import_job_id, created_at, updated_at)
 		VALUES %s
-		ON CONFLICT (id) DO UPDATE SET
-			slug = EXCLUDED.slug,
-			title = EXCLUDED.title,
-			body = EXCLUDED.body,
-			author_id = EXCLUDED.author_id,
-			tags = EXCLUDED.tags,
-			published_at = EXCLUDED.published_at,
-			status = EXCLUDED.status,
-			updated_at = EXCLUDED.updated_at
-	`, strings.Join(valueStrings, ","))
-
-	result, err := tx.ExecContext(ctx, query, valueArgs...)
-	if err != nil {
-		return 0, err
+		%s
+		RETURNING id, slug, xmax = 0 AS inserted
+	`, strings.Join(valueStrings, ","), conflict)
+
+	var written []struct {
+		ID       uuid.UUID `db:"id"`
+		Slug     string    `db:"slug"`
+		Inserted bool      `db:"inserted"`
 	}
-
-	if err := tx.Commit(); err != nil {
-		return 0, err
+	if err := sqlx.SelectContext(ctx, r.db.conn(ctx), &written, query, valueArgs...); err != nil {
+		return 0, 0, err
 	}
 
-	affected, _ := result.RowsAffected()
-	return int(affected), nil
+	inserted := 0
+	ids := make(map[string]uuid.UUID, len(written))
+	for _, w := range written {
+		if w.Inserted {
+			inserted++
+		}
+		ids[w.Slug] = w.ID
+	}
+	for _, article := range articles {
+		if id, ok := ids[article.Slug]; ok {
+			article.ID = id
+		}
+	}
+	return inserted, len(written) - inserted, nil
 }
 
 // GetByID retrieves an article by ID
@@ -143,7 +201,7 @@ func (r *ArticleRepository) GetAll(ctx context.Context, filters *models.ExportFi
 func (r *ArticleRepository) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Article) error) error {
 	query, args := r.buildSelectQuery(filters)
 
-	rows, err := r.db.QueryxContext(ctx, query, args...)
+	rows, err := r.db.conn(ctx).QueryxContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -180,54 +238,22 @@ func (r *ArticleRepository) Update(ctx context.Context, article *models.Article)
 	query := `
 		UPDATE articles 
 		SET slug = $2, title = $3, body = $4, author_id = $5, tags = $6, 
-		    published_at = $7, status = $8, updated_at = $9
+		    published_at = $7, status = $8, lang = $9, import_job_id = $10, updated_at = $11
 		WHERE id = $1
 	`
 	_, err := r.db.ExecContext(ctx, query, article.ID, article.Slug, article.Title,
-		article.Body, article.AuthorID, article.Tags, article.PublishedAt, article.Status, article.UpdatedAt)
+		article.Body, article.AuthorID, article.Tags, article.PublishedAt, article.Status, article.Lang,
+		article.ImportJobID, article.UpdatedAt)
 	return err
 }
 
-// Upsert inserts or updates an article
-func (r *ArticleRepository) Upsert(ctx context.Context, article *models.Article) error {
-	if article.ID == uuid.Nil {
-		article.ID = uuid.New()
-	}
-	if article.CreatedAt.IsZero() {
-		article.CreatedAt = time.Now().UTC()
-	}
+// Upsert inserts an article or updates the one matching it on key
+func (r *ArticleRepository) Upsert(ctx context.Context, article *models.Article, key models.UpsertKey) error {
 	article.UpdatedAt = time.Now().UTC()
-	if article.Tags == nil {
-		article.Tags = json.RawMessage("[]")
-	}
-
-	query := `
-		INSERT INTO articles (id, slug, title, body, author_id, tags, published_at, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		ON CONFLICT (slug) DO UPDATE SET
-			title = EXCLUDED.title,
-			body = EXCLUDED.body,
-			author_id = EXCLUDED.author_id,
-			tags = EXCLUDED.tags,
-			published_at = EXCLUDED.published_at,
-			status = EXCLUDED.status,
-			updated_at = EXCLUDED.updated_at
-	`
-	_, err := r.db.ExecContext(ctx, query,
-		article.ID, article.Slug, article.Title, article.Body, article.AuthorID,
-		article.Tags, article.PublishedAt, article.Status, article.CreatedAt, article.UpdatedAt)
+	_, _, err := r.UpsertBatch(ctx, []*models.Article{article}, key)
 	return err
 }
 
-// UpsertBatch upserts multiple articles
-func (r *ArticleRepository) UpsertBatch(ctx context.Context, articles []*models.Article) (int, int, error) {
-	if len(articles) == 0 {
-		return 0, 0, nil
-	}
-	count, err := r.CreateBatch(ctx, articles)
-	return count, 0, err
-}
-
 // Delete deletes an article by ID
 func (r *ArticleRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM articles WHERE id = $1", id)
@@ -255,6 +281,40 @@ func (r *ArticleRepository) SlugExists(ctx context.Context, slug string, exclude
 	return exists, err
 }
 
+// ExistingSlugs returns which of the lowercased slugs belong to an article
+func (r *ArticleRepository) ExistingSlugs(ctx context.Context, slugs []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.db, "SELECT LOWER(slug) FROM articles WHERE LOWER(slug) IN (?)", slugs)
+}
+
+// GetBySlugs returns the articles with any of slugs, keyed by their
+// lowercased slug. Inside a caller's transaction it reads what the
+// transaction wrote.
+func (r *ArticleRepository) GetBySlugs(ctx context.Context, slugs []string) (map[string]*models.Article, error) {
+	result := make(map[string]*models.Article)
+	for start := 0; start < len(slugs); start += existingKeysChunk {
+		end := min(start+existingKeysChunk, len(slugs))
+		query, args, err := sqlx.In("SELECT * FROM articles WHERE LOWER(slug) IN (?)", slugs[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		var articles []*models.Article
+		if err := sqlx.SelectContext(ctx, r.db.conn(ctx), &articles, r.db.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+		for _, article := range articles {
+			result[strings.ToLower(article.Slug)] = article
+		}
+	}
+	return result, nil
+}
+
+// ExistingIDs returns which of ids are articles, keyed by their canonical
+// text form. Malformed IDs are never found.
+func (r *ArticleRepository) ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.db, "SELECT id::text FROM articles WHERE id IN (?)", parseIDs(ids))
+}
+
 // Count returns the number of articles matching the filters
 func (r *ArticleRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
 	query := "SELECT COUNT(*) FROM articles"
@@ -270,6 +330,14 @@ func (r *ArticleRepository) Count(ctx context.Context, filters *models.ExportFil
 			conditions = append(conditions, fmt.Sprintf("author_id = $%d", len(args)+1))
 			args = append(args, *filters.AuthorID)
 		}
+		if filters.Lang != nil {
+			conditions = append(conditions, fmt.Sprintf("lang = $%d", len(args)+1))
+			args = append(args, *filters.Lang)
+		}
+		if filters.ImportedByJob != nil {
+			conditions = append(conditions, fmt.Sprintf("import_job_id = $%d", len(args)+1))
+			args = append(args, *filters.ImportedByJob)
+		}
 		if filters.CreatedAfter != nil {
 			conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)+1))
 			args = append(args, *filters.CreatedAfter)
@@ -278,6 +346,29 @@ func (r *ArticleRepository) Count(ctx context.Context, filters *models.ExportFil
 			conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)+1))
 			args = append(args, *filters.CreatedBefore)
 		}
+		if filters.UpdatedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at > $%d", len(args)+1))
+			args = append(args, *filters.UpdatedAfter)
+		}
+		if filters.UpdatedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at <= $%d", len(args)+1))
+			args = append(args, *filters.UpdatedBefore)
+		}
+		if filters.PublishedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("published_at >= $%d", len(args)+1))
+			args = append(args, *filters.PublishedAfter)
+		}
+		if filters.PublishedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("published_at <= $%d", len(args)+1))
+			args = append(args, *filters.PublishedBefore)
+		}
+		if filters.HasTags != nil {
+			if *filters.HasTags {
+				conditions = append(conditions, "jsonb_array_length(COALESCE(tags, '[]'::jsonb)) > 0")
+			} else {
+				conditions = append(conditions, "jsonb_array_length(COALESCE(tags, '[]'::jsonb)) = 0")
+			}
+		}
 	}
 
 	if len(conditions) > 0 {
@@ -285,7 +376,7 @@ func (r *ArticleRepository) Count(ctx context.Context, filters *models.ExportFil
 	}
 
 	var count int64
-	err := r.db.GetContext(ctx, &count, query, args...)
+	err := sqlx.GetContext(ctx, r.db.conn(ctx), &count, query, args...)
 	return count, err
 }
 
@@ -303,6 +394,14 @@ func (r *ArticleRepository) buildSelectQuery(filters *models.ExportFilters) (str
 			conditions = append(conditions, fmt.Sprintf("author_id = $%d", len(args)+1))
 			args = append(args, *filters.AuthorID)
 		}
+		if filters.Lang != nil {
+			conditions = append(conditions, fmt.Sprintf("lang = $%d", len(args)+1))
+			args = append(args, *filters.Lang)
+		}
+		if filters.ImportedByJob != nil {
+			conditions = append(conditions, fmt.Sprintf("import_job_id = $%d", len(args)+1))
+			args = append(args, *filters.ImportedByJob)
+		}
 		if filters.CreatedAfter != nil {
 			conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)+1))
 			args = append(args, *filters.CreatedAfter)
@@ -311,13 +410,36 @@ func (r *ArticleRepository) buildSelectQuery(filters *models.ExportFilters) (str
 			conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)+1))
 			args = append(args, *filters.CreatedBefore)
 		}
+		if filters.UpdatedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at > $%d", len(args)+1))
+			args = append(args, *filters.UpdatedAfter)
+		}
+		if filters.UpdatedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at <= $%d", len(args)+1))
+			args = append(args, *filters.UpdatedBefore)
+		}
+		if filters.PublishedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("published_at >= $%d", len(args)+1))
+			args = append(args, *filters.PublishedAfter)
+		}
+		if filters.PublishedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("published_at <= $%d", len(args)+1))
+			args = append(args, *filters.PublishedBefore)
+		}
+		if filters.HasTags != nil {
+			if *filters.HasTags {
+				conditions = append(conditions, "jsonb_array_length(COALESCE(tags, '[]'::jsonb)) > 0")
+			} else {
+				conditions = append(conditions, "jsonb_array_length(COALESCE(tags, '[]'::jsonb)) = 0")
+			}
+		}
 	}
 
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY created_at ASC"
+	query += " ORDER BY created_at ASC, id ASC"
 
 	return query, args
 }
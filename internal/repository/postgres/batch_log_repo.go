@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// BatchLogRepository implements repository.BatchLogRepository for PostgreSQL
+type BatchLogRepository struct {
+	db *DB
+}
+
+// NewBatchLogRepository creates a new BatchLogRepository
+func NewBatchLogRepository(db *DB) *BatchLogRepository {
+	return &BatchLogRepository{db: db}
+}
+
+// Record stores commit. Inside a caller's transaction it commits with it.
+func (r *BatchLogRepository) Record(ctx context.Context, commit *models.BatchCommit) error {
+	if commit.CommittedAt.IsZero() {
+		commit.CommittedAt = time.Now().UTC()
+	}
+	_, err := r.db.conn(ctx).ExecContext(ctx, `
+		INSERT INTO import_batch_commits (job_id, attempt, batch_no, first_staging_id, last_staging_id, rows, inserted, updated, committed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		commit.JobID, commit.Attempt, commit.BatchNo, commit.FirstStagingID, commit.LastStagingID,
+		commit.Rows, commit.Inserted, commit.Updated, commit.CommittedAt)
+	return err
+}
+
+// ListByJob returns the batches jobID committed, by attempt and batch
+func (r *BatchLogRepository) ListByJob(ctx context.Context, jobID uuid.UUID) ([]*models.BatchCommit, error) {
+	var commits []*models.BatchCommit
+	err := sqlx.SelectContext(ctx, r.db.conn(ctx), &commits, `
+		SELECT job_id, attempt, batch_no, first_staging_id, last_staging_id, rows, inserted, updated, committed_at
+		FROM import_batch_commits
+		WHERE job_id = $1
+		ORDER BY attempt, batch_no`, jobID)
+	return commits, err
+}
+
+// NextAttempt returns the attempt the next run of jobID records its batches
+// under, 1 for a job that recorded none
+func (r *BatchLogRepository) NextAttempt(ctx context.Context, jobID uuid.UUID) (int, error) {
+	var attempt int
+	err := sqlx.GetContext(ctx, r.db.conn(ctx), &attempt,
+		"SELECT COALESCE(MAX(attempt), 0) + 1 FROM import_batch_commits WHERE job_id = $1", jobID)
+	return attempt, err
+}
+
+// Totals sums the records the batches of jobID inserted and updated in
+// fromAttempt and the attempts after it
+func (r *BatchLogRepository) Totals(ctx context.Context, jobID uuid.UUID, fromAttempt int) (int, int, error) {
+	var totals struct {
+		Inserted int `db:"inserted"`
+		Updated  int `db:"updated"`
+	}
+	err := sqlx.GetContext(ctx, r.db.conn(ctx), &totals, `
+		SELECT COALESCE(SUM(inserted), 0) AS inserted, COALESCE(SUM(updated), 0) AS updated
+		FROM import_batch_commits
+		WHERE job_id = $1 AND attempt >= $2`, jobID, fromAttempt)
+	return totals.Inserted, totals.Updated, err
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
@@ -31,10 +32,10 @@ func (r *CommentRepository) Create(ctx context.Context, comment *models.Comment)
 	}
 
 	query := `
-		INSERT INTO comments (id, article_id, user_id, body, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO comments (id, article_id, user_id, body, lang, import_job_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
-	_, err := r.db.ExecContext(ctx, query, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.CreatedAt)
+	_, err := r.db.ExecContext(ctx, query, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.Lang, comment.ImportJobID, comment.CreatedAt)
 	return err
 }
 
@@ -44,14 +45,8 @@ func (r *CommentRepository) CreateBatch(ctx context.Context, comments []*models.
 		return 0, nil
 	}
 
-	tx, err := r.db.BeginTx(ctx)
-	if err != nil {
-		return 0, err
-	}
-	defer tx.Rollback()
-
 	valueStrings := make([]string, 0, len(comments))
-	valueArgs := make([]interface{}, 0, len(comments)*5)
+	valueArgs := make([]interface{}, 0, len(comments)*7)
 
 	for i, comment := range comments {
 		if comment.ID == uuid.Nil {
@@ -61,30 +56,31 @@ func (r *CommentRepository) CreateBatch(ctx context.Context, comments []*models.
 			comment.CreatedAt = time.Now().UTC()
 		}
 
-		base := i * 5
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)",
-			base+1, base+2, base+3, base+4, base+5))
-		valueArgs = append(valueArgs, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.CreatedAt)
+		base := i * 7
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7))
+		valueArgs = append(valueArgs, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.Lang, comment.ImportJobID, comment.CreatedAt)
 	}
 
+	// An import without language detection keeps the stored language, and a
+	// write outside an import the job that last imported the comment
 	query := fmt.Sprintf(`
-		INSERT INTO comments (id, article_id, user_id, body, created_at)
+		INSERT INTO comments (id, article_id, user_id, body, lang, import_job_id, created_at)
 		VALUES %s
 		ON CONFLICT (id) DO UPDATE SET
 			article_id = EXCLUDED.article_id,
 			user_id = EXCLUDED.user_id,
-			body = EXCLUDED.body
+			body = EXCLUDED.body,
+			lang = COALESCE(EXCLUDED.lang, comments.lang),
+			import_job_id = COALESCE(EXCLUDED.import_job_id, comments.import_job_id)
 	`, strings.Join(valueStrings, ","))
 
-	result, err := tx.ExecContext(ctx, query, valueArgs...)
+	// Inside a caller's transaction the batch commits with it
+	result, err := r.db.conn(ctx).ExecContext(ctx, query, valueArgs...)
 	if err != nil {
 		return 0, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, err
-	}
-
 	affected, _ := result.RowsAffected()
 	return int(affected), nil
 }
@@ -101,7 +97,7 @@ func (r *CommentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 
 // GetAll retrieves all comments with optional filters
 func (r *CommentRepository) GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.Comment, error) {
-	query, args := r.buildSelectQuery(filters)
+	query, args := r.buildSelectQuery(filters, "created_at ASC, id ASC")
 	var comments []*models.Comment
 	err := r.db.SelectContext(ctx, &comments, query, args...)
 	return comments, err
@@ -109,9 +105,20 @@ func (r *CommentRepository) GetAll(ctx context.Context, filters *models.ExportFi
 
 // GetAllWithCursor streams comments using a cursor for memory efficiency
 func (r *CommentRepository) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Comment) error) error {
-	query, args := r.buildSelectQuery(filters)
+	query, args := r.buildSelectQuery(filters, "created_at ASC, id ASC")
+	return r.streamQuery(ctx, query, args, batchSize, callback)
+}
+
+// GetAllByArticleWithCursor streams comments like GetAllWithCursor, ordered
+// by article and then creation so each article's comments are adjacent
+func (r *CommentRepository) GetAllByArticleWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.Comment) error) error {
+	query, args := r.buildSelectQuery(filters, "article_id ASC, created_at ASC, id ASC")
+	return r.streamQuery(ctx, query, args, batchSize, callback)
+}
 
-	rows, err := r.db.QueryxContext(ctx, query, args...)
+// streamQuery runs query and passes the rows to callback in batches
+func (r *CommentRepository) streamQuery(ctx context.Context, query string, args []interface{}, batchSize int, callback func([]*models.Comment) error) error {
+	rows, err := r.db.conn(ctx).QueryxContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -146,10 +153,10 @@ func (r *CommentRepository) GetAllWithCursor(ctx context.Context, filters *model
 func (r *CommentRepository) Update(ctx context.Context, comment *models.Comment) error {
 	query := `
 		UPDATE comments 
-		SET article_id = $2, user_id = $3, body = $4
+		SET article_id = $2, user_id = $3, body = $4, lang = $5, import_job_id = $6
 		WHERE id = $1
 	`
-	_, err := r.db.ExecContext(ctx, query, comment.ID, comment.ArticleID, comment.UserID, comment.Body)
+	_, err := r.db.ExecContext(ctx, query, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.Lang, comment.ImportJobID)
 	return err
 }
 
@@ -163,14 +170,16 @@ func (r *CommentRepository) Upsert(ctx context.Context, comment *models.Comment)
 	}
 
 	query := `
-		INSERT INTO comments (id, article_id, user_id, body, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO comments (id, article_id, user_id, body, lang, import_job_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (id) DO UPDATE SET
 			article_id = EXCLUDED.article_id,
 			user_id = EXCLUDED.user_id,
-			body = EXCLUDED.body
+			body = EXCLUDED.body,
+			lang = COALESCE(EXCLUDED.lang, comments.lang),
+			import_job_id = COALESCE(EXCLUDED.import_job_id, comments.import_job_id)
 	`
-	_, err := r.db.ExecContext(ctx, query, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.CreatedAt)
+	_, err := r.db.ExecContext(ctx, query, comment.ID, comment.ArticleID, comment.UserID, comment.Body, comment.Lang, comment.ImportJobID, comment.CreatedAt)
 	return err
 }
 
@@ -196,6 +205,12 @@ func (r *CommentRepository) Exists(ctx context.Context, id uuid.UUID) (bool, err
 	return exists, err
 }
 
+// ExistingIDs returns which of ids are comments, keyed by their canonical
+// text form. Malformed IDs are never found.
+func (r *CommentRepository) ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.db, "SELECT id::text FROM comments WHERE id IN (?)", parseIDs(ids))
+}
+
 // Count returns the number of comments matching the filters
 func (r *CommentRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
 	query := "SELECT COUNT(*) FROM comments"
@@ -211,6 +226,14 @@ func (r *CommentRepository) Count(ctx context.Context, filters *models.ExportFil
 			conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)+1))
 			args = append(args, *filters.UserID)
 		}
+		if filters.Lang != nil {
+			conditions = append(conditions, fmt.Sprintf("lang = $%d", len(args)+1))
+			args = append(args, *filters.Lang)
+		}
+		if filters.ImportedByJob != nil {
+			conditions = append(conditions, fmt.Sprintf("import_job_id = $%d", len(args)+1))
+			args = append(args, *filters.ImportedByJob)
+		}
 		if filters.CreatedAfter != nil {
 			conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)+1))
 			args = append(args, *filters.CreatedAfter)
@@ -219,6 +242,14 @@ func (r *CommentRepository) Count(ctx context.Context, filters *models.ExportFil
 			conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)+1))
 			args = append(args, *filters.CreatedBefore)
 		}
+		if filters.UpdatedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at > $%d", len(args)+1))
+			args = append(args, *filters.UpdatedAfter)
+		}
+		if filters.UpdatedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at <= $%d", len(args)+1))
+			args = append(args, *filters.UpdatedBefore)
+		}
 	}
 
 	if len(conditions) > 0 {
@@ -226,11 +257,11 @@ func (r *CommentRepository) Count(ctx context.Context, filters *models.ExportFil
 	}
 
 	var count int64
-	err := r.db.GetContext(ctx, &count, query, args...)
+	err := sqlx.GetContext(ctx, r.db.conn(ctx), &count, query, args...)
 	return count, err
 }
 
-func (r *CommentRepository) buildSelectQuery(filters *models.ExportFilters) (string, []interface{}) {
+func (r *CommentRepository) buildSelectQuery(filters *models.ExportFilters, orderBy string) (string, []interface{}) {
 	query := "SELECT * FROM comments"
 	args := []interface{}{}
 	conditions := []string{}
@@ -244,6 +275,14 @@ func (r *CommentRepository) buildSelectQuery(filters *models.ExportFilters) (str
 			conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)+1))
 			args = append(args, *filters.UserID)
 		}
+		if filters.Lang != nil {
+			conditions = append(conditions, fmt.Sprintf("lang = $%d", len(args)+1))
+			args = append(args, *filters.Lang)
+		}
+		if filters.ImportedByJob != nil {
+			conditions = append(conditions, fmt.Sprintf("import_job_id = $%d", len(args)+1))
+			args = append(args, *filters.ImportedByJob)
+		}
 		if filters.CreatedAfter != nil {
 			conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)+1))
 			args = append(args, *filters.CreatedAfter)
@@ -252,13 +291,21 @@ func (r *CommentRepository) buildSelectQuery(filters *models.ExportFilters) (str
 			conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)+1))
 			args = append(args, *filters.CreatedBefore)
 		}
+		if filters.UpdatedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at > $%d", len(args)+1))
+			args = append(args, *filters.UpdatedAfter)
+		}
+		if filters.UpdatedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at <= $%d", len(args)+1))
+			args = append(args, *filters.UpdatedBefore)
+		}
 	}
 
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY created_at ASC"
+	query += " ORDER BY " + orderBy
 
 	return query, args
 }
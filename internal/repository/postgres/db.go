@@ -2,17 +2,25 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
+// defaultMaxStatementBytes bounds the text in one multi-row INSERT when no
+// limit is configured
+const defaultMaxStatementBytes = 16 << 20
+
 // DB wraps sqlx.DB with additional functionality
 type DB struct {
 	*sqlx.DB
+	maxStatementBytes int
 }
 
 // NewConnection creates a new database connection
@@ -36,7 +44,33 @@ func NewConnection(cfg config.DatabaseConfig) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{DB: db}, nil
+	return &DB{DB: db, maxStatementBytes: cfg.MaxStatementBytes}, nil
+}
+
+// chunkBySize splits n rows into consecutive [start, end) ranges whose
+// sizes add up to at most the statement limit, so a few very large rows
+// don't make one huge INSERT of a whole batch. A row larger than the limit
+// gets a statement of its own.
+func (db *DB) chunkBySize(n int, size func(i int) int) [][2]int {
+	limit := db.maxStatementBytes
+	if limit <= 0 {
+		limit = defaultMaxStatementBytes
+	}
+
+	var chunks [][2]int
+	start, total := 0, 0
+	for i := 0; i < n; i++ {
+		rowSize := size(i)
+		if i > start && total+rowSize > limit {
+			chunks = append(chunks, [2]int{start, i})
+			start, total = i, 0
+		}
+		total += rowSize
+	}
+	if start < n {
+		chunks = append(chunks, [2]int{start, n})
+	}
+	return chunks
 }
 
 // Close closes the database connection
@@ -49,6 +83,163 @@ func (db *DB) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
 	return db.BeginTxx(ctx, nil)
 }
 
+// txContextKey is the context key for a transaction bound to repository calls
+type txContextKey struct{}
+
+// bindTx returns a context whose repository calls run inside tx
+func bindTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// boundTx returns the transaction bound to ctx, or nil
+func boundTx(ctx context.Context) *sqlx.Tx {
+	tx, _ := ctx.Value(txContextKey{}).(*sqlx.Tx)
+	return tx
+}
+
+// conn returns the transaction bound to ctx, or the pool when there is none
+func (db *DB) conn(ctx context.Context) sqlx.ExtContext {
+	if tx := boundTx(ctx); tx != nil {
+		return tx
+	}
+	return db.DB
+}
+
+// WithTx implements repository.Transactor. fn runs in the transaction
+// already bound to ctx when there is one, which then commits or rolls back
+// with its own caller.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if boundTx(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(bindTx(ctx, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Lock implements repository.Transactor with a transaction-level advisory
+// lock on the hash of key
+func (db *DB) Lock(ctx context.Context, key string) error {
+	tx := boundTx(ctx)
+	if tx == nil {
+		return fmt.Errorf("lock %s: no transaction", key)
+	}
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", key)
+	return err
+}
+
+// BeginSnapshot starts a read-only REPEATABLE READ transaction and returns it
+// along with the timestamp its snapshot was taken at
+func (db *DB) BeginSnapshot(ctx context.Context) (*sqlx.Tx, time.Time, error) {
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// The first statement in the transaction establishes the snapshot
+	var asOf time.Time
+	if err := tx.GetContext(ctx, &asOf, "SELECT clock_timestamp()"); err != nil {
+		tx.Rollback()
+		return nil, time.Time{}, err
+	}
+
+	return tx, asOf.UTC(), nil
+}
+
+// Snapshot implements repository.Snapshotter with a BeginSnapshot
+// transaction bound to the returned context
+func (db *DB) Snapshot(ctx context.Context) (context.Context, time.Time, func(), error) {
+	tx, asOf, err := db.BeginSnapshot(ctx)
+	if err != nil {
+		return ctx, time.Time{}, nil, err
+	}
+	return bindTx(ctx, tx), asOf, func() { tx.Rollback() }, nil
+}
+
+// Now returns the current database time
+func (db *DB) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	err := db.GetContext(ctx, &now, "SELECT clock_timestamp()")
+	return now.UTC(), err
+}
+
+// TableSize implements repository.TableSizer. Rows come from the planner's
+// estimate, counted instead for a table that hasn't been analyzed yet.
+func (db *DB) TableSize(ctx context.Context, resource models.ResourceType) (*models.TableSize, error) {
+	var table string
+	switch resource {
+	case models.ResourceTypeUsers, models.ResourceTypeArticles, models.ResourceTypeComments:
+		table = string(resource)
+	default:
+		return nil, fmt.Errorf("unknown resource type: %s", resource)
+	}
+
+	var size struct {
+		Rows       int64 `db:"rows"`
+		TableBytes int64 `db:"table_bytes"`
+		IndexBytes int64 `db:"index_bytes"`
+	}
+	query := `
+		SELECT reltuples::bigint AS rows,
+		       pg_table_size(oid) AS table_bytes,
+		       pg_indexes_size(oid) AS index_bytes
+		FROM pg_class WHERE oid = $1::regclass
+	`
+	if err := db.GetContext(ctx, &size, query, table); err != nil {
+		return nil, err
+	}
+	if size.Rows < 0 {
+		if err := db.GetContext(ctx, &size.Rows, "SELECT COUNT(*) FROM "+table); err != nil {
+			return nil, err
+		}
+	}
+	return &models.TableSize{Rows: size.Rows, TableBytes: size.TableBytes, IndexBytes: size.IndexBytes}, nil
+}
+
+// existingKeysChunk bounds the bind parameters of one existingKeys query
+const existingKeysChunk = 5000
+
+// existingKeys runs query, an sqlx.In query selecting a single text column,
+// over keys in chunks and returns the values it found
+func existingKeys[K any](ctx context.Context, db *DB, query string, keys []K) (map[string]bool, error) {
+	found := make(map[string]bool)
+	for start := 0; start < len(keys); start += existingKeysChunk {
+		end := min(start+existingKeysChunk, len(keys))
+		q, args, err := sqlx.In(query, keys[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		var values []string
+		if err := sqlx.SelectContext(ctx, db.conn(ctx), &values, db.Rebind(q), args...); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			found[v] = true
+		}
+	}
+	return found, nil
+}
+
+// parseIDs returns the IDs that parse as UUIDs, dropping the rest
+func parseIDs(ids []string) []uuid.UUID {
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if u, err := uuid.Parse(id); err == nil {
+			parsed = append(parsed, u)
+		}
+	}
+	return parsed
+}
+
 // GetStats returns database connection statistics
 func (db *DB) GetStats() DBStats {
 	stats := db.DB.Stats()
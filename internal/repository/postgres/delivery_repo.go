@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// DeliveryRepository implements repository.DeliveryRepository for PostgreSQL
+type DeliveryRepository struct {
+	db *DB
+}
+
+// NewDeliveryRepository creates a new DeliveryRepository
+func NewDeliveryRepository(db *DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+// Create queues a new delivery
+func (r *DeliveryRepository) Create(ctx context.Context, delivery *models.Delivery) error {
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = now
+	}
+	if delivery.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = delivery.CreatedAt
+	}
+	if delivery.Status == "" {
+		delivery.Status = models.DeliveryPending
+	}
+	delivery.UpdatedAt = now
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO deliveries (id, channel, target, event, payload, status, attempts, max_attempts,
+			next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		delivery.ID, delivery.Channel, delivery.Target, delivery.Event, delivery.Payload, delivery.Status,
+		delivery.Attempts, delivery.MaxAttempts, delivery.NextAttemptAt, delivery.CreatedAt, delivery.UpdatedAt)
+	return err
+}
+
+// GetByID returns the delivery with id, or nil if there is none
+func (r *DeliveryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Delivery, error) {
+	var delivery models.Delivery
+	err := r.db.GetContext(ctx, &delivery, "SELECT * FROM deliveries WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &delivery, err
+}
+
+// ClaimDue returns up to limit pending deliveries due by now and leases them
+// until leaseUntil. Rows another instance is claiming are skipped.
+func (r *DeliveryRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.Delivery, error) {
+	var deliveries []*models.Delivery
+	err := r.db.SelectContext(ctx, &deliveries, `
+		UPDATE deliveries SET next_attempt_at = $3, updated_at = $1
+		WHERE id IN (
+			SELECT id FROM deliveries
+			WHERE status = $2 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now, models.DeliveryPending, leaseUntil, limit)
+	return deliveries, err
+}
+
+// MarkDelivered records the attempt that delivered a delivery, unless its
+// lease was lost
+func (r *DeliveryRepository) MarkDelivered(ctx context.Context, id uuid.UUID, lease time.Time, attempts int, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE deliveries SET status = $2, attempts = $3, delivered_at = $4, updated_at = $4
+		WHERE id = $1 AND status = $5 AND next_attempt_at = $6`,
+		id, models.DeliveryDelivered, attempts, at, models.DeliveryPending, lease)
+	return claimHeld(result, err)
+}
+
+// MarkFailed records a failed attempt, scheduling the next one at next or
+// dead-lettering the delivery when next is nil, unless its lease was lost
+func (r *DeliveryRepository) MarkFailed(ctx context.Context, id uuid.UUID, lease time.Time, attempts int, lastError string, next *time.Time) (bool, error) {
+	now := time.Now().UTC()
+	if next == nil {
+		result, err := r.db.ExecContext(ctx, `
+			UPDATE deliveries SET status = $2, attempts = $3, last_error = $4, updated_at = $5
+			WHERE id = $1 AND status = $6 AND next_attempt_at = $7`,
+			id, models.DeliveryDeadLetter, attempts, lastError, now, models.DeliveryPending, lease)
+		return claimHeld(result, err)
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE deliveries SET attempts = $2, last_error = $3, next_attempt_at = $4, updated_at = $5
+		WHERE id = $1 AND status = $6 AND next_attempt_at = $7`,
+		id, attempts, lastError, *next, now, models.DeliveryPending, lease)
+	return claimHeld(result, err)
+}
+
+// ListByStatus returns a page of the deliveries in status, newest first
+func (r *DeliveryRepository) ListByStatus(ctx context.Context, status models.DeliveryStatus, page, perPage int) ([]*models.Delivery, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	var total int64
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM deliveries WHERE status = $1", status); err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []*models.Delivery
+	err := r.db.SelectContext(ctx, &deliveries, `
+		SELECT * FROM deliveries
+		WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`,
+		status, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// Requeue puts a dead-lettered delivery back to pending with no attempts
+// made, returning false when it wasn't dead-lettered
+func (r *DeliveryRepository) Requeue(ctx context.Context, id uuid.UUID, now time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE deliveries SET status = $2, attempts = 0, next_attempt_at = $3, updated_at = $3
+		WHERE id = $1 AND status = $4`,
+		id, models.DeliveryPending, now, models.DeliveryDeadLetter)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// claimHeld reports whether an update guarded by a delivery's lease changed
+// its row
+func claimHeld(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
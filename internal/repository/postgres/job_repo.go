@@ -3,6 +3,8 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,20 +29,25 @@ func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
 	if job.CreatedAt.IsZero() {
 		job.CreatedAt = time.Now().UTC()
 	}
+	if job.TenantID == "" {
+		job.TenantID = models.DefaultTenantID
+	}
 	job.UpdatedAt = time.Now().UTC()
 
 	query := `
 		INSERT INTO jobs (
 			id, type, resource, status, idempotency_key, file_path, file_url,
 			total_records, processed_records, successful_records, failed_records,
-			error_message, started_at, completed_at, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			error_message, started_at, completed_at, created_at, updated_at, tenant_id,
+			parent_job_id, params
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		job.ID, job.Type, job.Resource, job.Status, job.IdempotencyKey,
 		job.FilePath, job.FileURL, job.TotalRecords, job.ProcessedRecords,
 		job.SuccessfulRecords, job.FailedRecords, job.ErrorMessage,
-		job.StartedAt, job.CompletedAt, job.CreatedAt, job.UpdatedAt,
+		job.StartedAt, job.CompletedAt, job.CreatedAt, job.UpdatedAt, job.TenantID,
+		job.ParentJobID, job.Params,
 	)
 	return err
 }
@@ -55,6 +62,19 @@ func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job,
 	return &job, err
 }
 
+// GetFollowUp returns the most recent job of jobType started by the parent job,
+// or nil if there is none
+func (r *JobRepository) GetFollowUp(ctx context.Context, parentID uuid.UUID, jobType models.JobType) (*models.Job, error) {
+	var job models.Job
+	err := r.db.GetContext(ctx, &job,
+		"SELECT * FROM jobs WHERE parent_job_id = $1 AND type = $2 ORDER BY created_at DESC LIMIT 1",
+		parentID, jobType)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &job, err
+}
+
 // GetByIdempotencyKey retrieves a job by idempotency key
 func (r *JobRepository) GetByIdempotencyKey(ctx context.Context, key string) (*models.Job, error) {
 	var job models.Job
@@ -72,13 +92,15 @@ func (r *JobRepository) Update(ctx context.Context, job *models.Job) error {
 		UPDATE jobs SET
 			status = $2, total_records = $3, processed_records = $4,
 			successful_records = $5, failed_records = $6, error_message = $7,
-			started_at = $8, completed_at = $9, updated_at = $10, file_path = $11
+			started_at = $8, completed_at = $9, updated_at = $10, file_path = $11,
+			file_size_bytes = $12, data_as_of = $13
 		WHERE id = $1
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		job.ID, job.Status, job.TotalRecords, job.ProcessedRecords,
 		job.SuccessfulRecords, job.FailedRecords, job.ErrorMessage,
 		job.StartedAt, job.CompletedAt, job.UpdatedAt, job.FilePath,
+		job.FileSizeBytes, job.DataAsOf,
 	)
 	return err
 }
@@ -103,17 +125,62 @@ func (r *JobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, proces
 	return err
 }
 
+// SetDuplicateRecords records how many rows of the job are duplicates
+func (r *JobRepository) SetDuplicateRecords(ctx context.Context, id uuid.UUID, duplicates int) error {
+	query := `UPDATE jobs SET duplicate_records = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, duplicates, time.Now().UTC())
+	return err
+}
+
 // SetStarted sets the job as started
 func (r *JobRepository) SetStarted(ctx context.Context, id uuid.UUID) error {
 	now := time.Now().UTC()
 	query := `
-		UPDATE jobs SET status = $2, started_at = $3, updated_at = $3
+		UPDATE jobs SET status = $2, started_at = $3, heartbeat_at = $3, updated_at = $3
 		WHERE id = $1
 	`
 	_, err := r.db.ExecContext(ctx, query, id, models.JobStatusProcessing, now)
 	return err
 }
 
+// SetWorker records which instance and worker are running the job
+func (r *JobRepository) SetWorker(ctx context.Context, id uuid.UUID, worker models.JobWorker) error {
+	query := `
+		UPDATE jobs SET worker_instance = $2, worker_id = $3, worker_version = $4, updated_at = $5
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, worker.Instance, worker.WorkerID, worker.Version, time.Now().UTC())
+	return err
+}
+
+// SetPhase records the step a processing job has reached
+func (r *JobRepository) SetPhase(ctx context.Context, id uuid.UUID, phase string) error {
+	query := `UPDATE jobs SET phase = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, phase, time.Now().UTC())
+	return err
+}
+
+// SetFilePath records the file a job reads or writes
+func (r *JobRepository) SetFilePath(ctx context.Context, id uuid.UUID, path string) error {
+	query := `UPDATE jobs SET file_path = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, path, time.Now().UTC())
+	return err
+}
+
+// ClearFilePath forgets a job's file once it is removed
+func (r *JobRepository) ClearFilePath(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE jobs SET file_path = NULL, updated_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, time.Now().UTC())
+	return err
+}
+
+// SetSummary records the breakdown of an import's outcome
+func (r *JobRepository) SetSummary(ctx context.Context, id uuid.UUID, summary *models.JobSummary) error {
+	query := `UPDATE jobs SET summary = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, summary, time.Now().UTC())
+	return err
+}
+
 // SetCompleted sets the job as completed
 func (r *JobRepository) SetCompleted(ctx context.Context, id uuid.UUID, successful, failed int) error {
 	now := time.Now().UTC()
@@ -139,6 +206,213 @@ func (r *JobRepository) SetFailed(ctx context.Context, id uuid.UUID, errorMessag
 	return err
 }
 
+// ResetForRetry puts a failed job back to pending for another attempt. The
+// errors and warnings of the failed run are dropped with its counts so the
+// next run reports only its own.
+func (r *JobRepository) ResetForRetry(ctx context.Context, id uuid.UUID, attempts int) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE jobs SET
+			status = $2, attempts = $3, total_records = 0, processed_records = 0,
+			successful_records = 0, failed_records = 0, warning_count = 0,
+			duplicate_records = 0, started_at = NULL, heartbeat_at = NULL, completed_at = NULL,
+			phase = NULL, summary = NULL,
+			updated_at = $4
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, id, models.JobStatusPending, attempts, time.Now().UTC()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM job_errors WHERE job_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM job_warnings WHERE job_id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetDeadLetter parks a job that failed attempts times
+func (r *JobRepository) SetDeadLetter(ctx context.Context, id uuid.UUID, attempts int, errorMessage string) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE jobs SET
+			status = $2, attempts = $3, error_message = $4, completed_at = $5, updated_at = $5
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, models.JobStatusDeadLetter, attempts, errorMessage, now)
+	return err
+}
+
+// SetPaused parks a job the circuit breaker stopped
+func (r *JobRepository) SetPaused(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	query := `
+		UPDATE jobs SET
+			status = $2, error_message = $3, heartbeat_at = NULL, updated_at = $4
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, models.JobStatusPaused, errorMessage, time.Now().UTC())
+	return err
+}
+
+// ListByStatus returns jobs in status with pagination, most recently updated
+// first
+func (r *JobRepository) ListByStatus(ctx context.Context, status models.JobStatus, page, perPage int) ([]*models.Job, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	offset := (page - 1) * perPage
+
+	var total int64
+	err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM jobs WHERE status = $1", status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []*models.Job
+	query := `
+		SELECT * FROM jobs
+		WHERE status = $1
+		ORDER BY updated_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	err = r.db.SelectContext(ctx, &jobs, query, status, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// List returns the jobs matching filter, newest first
+func (r *JobRepository) List(ctx context.Context, filter models.JobListFilter, page, perPage int) ([]*models.Job, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	offset := (page - 1) * perPage
+
+	var conditions []string
+	var args []interface{}
+	if filter.TenantID != "" {
+		args = append(args, filter.TenantID)
+		conditions = append(conditions, fmt.Sprintf("tenant_id = $%d", len(args)))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM jobs "+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []*models.Job
+	query := fmt.Sprintf(`
+		SELECT * FROM jobs
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	err = r.db.SelectContext(ctx, &jobs, query, append(args, perPage, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// Heartbeat records that a worker is still processing the job
+func (r *JobRepository) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE jobs SET heartbeat_at = $2 WHERE id = $1 AND status = $3`
+	_, err := r.db.ExecContext(ctx, query, id, time.Now().UTC(), models.JobStatusProcessing)
+	return err
+}
+
+// ListStale returns up to limit processing jobs whose last heartbeat, or
+// start when they have none, is before staleBefore
+func (r *JobRepository) ListStale(ctx context.Context, staleBefore time.Time, limit int) ([]*models.Job, error) {
+	var jobs []*models.Job
+	query := `
+		SELECT * FROM jobs
+		WHERE status = $1 AND COALESCE(heartbeat_at, started_at, updated_at) < $2
+		ORDER BY COALESCE(heartbeat_at, started_at, updated_at) ASC
+		LIMIT $3
+	`
+	err := r.db.SelectContext(ctx, &jobs, query, models.JobStatusProcessing, staleBefore, limit)
+	return jobs, err
+}
+
+// ListExpiredExports returns up to limit completed exports that still have a
+// file and completed before completedBefore, oldest first
+func (r *JobRepository) ListExpiredExports(ctx context.Context, completedBefore time.Time, limit int) ([]*models.Job, error) {
+	var jobs []*models.Job
+	query := `
+		SELECT * FROM jobs
+		WHERE type = $1 AND status = $2 AND file_path IS NOT NULL AND completed_at < $3
+		ORDER BY completed_at ASC
+		LIMIT $4
+	`
+	err := r.db.SelectContext(ctx, &jobs, query, models.JobTypeExport, models.JobStatusCompleted, completedBefore, limit)
+	return jobs, err
+}
+
+// ClaimStale refreshes the heartbeat of a job that is still processing and
+// stale, reporting whether it did. The check and update are one statement, so
+// when several instances find the same job only one claims it.
+func (r *JobRepository) ClaimStale(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	query := `
+		UPDATE jobs SET heartbeat_at = $2
+		WHERE id = $1 AND status = $3 AND COALESCE(heartbeat_at, started_at, updated_at) < $4
+	`
+	result, err := r.db.ExecContext(ctx, query, id, time.Now().UTC(), models.JobStatusProcessing, staleBefore)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// SetErrorMessage sets only the error message of a job that is still
+// processing, so a cancel or finish written since it was read is kept
+func (r *JobRepository) SetErrorMessage(ctx context.Context, id uuid.UUID, msg string) (bool, error) {
+	query := `UPDATE jobs SET error_message = $2, updated_at = $3 WHERE id = $1 AND status = $4`
+	result, err := r.db.ExecContext(ctx, query, id, msg, time.Now().UTC(), models.JobStatusProcessing)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
 // AddErrors adds job errors in batch
 func (r *JobRepository) AddErrors(ctx context.Context, errors []*models.JobError) error {
 	if len(errors) == 0 {
@@ -213,6 +487,159 @@ func (r *JobRepository) GetErrors(ctx context.Context, jobID uuid.UUID, page, pe
 	return errors, total, nil
 }
 
+// AddWarnings adds job warnings in batch and bumps the job's warning count
+func (r *JobRepository) AddWarnings(ctx context.Context, warnings []*models.JobWarning) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO job_warnings (id, job_id, row_number, record_identifier, field_name, warning_code, warning_message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	counts := make(map[uuid.UUID]int)
+	for _, w := range warnings {
+		if w.ID == uuid.Nil {
+			w.ID = uuid.New()
+		}
+		if w.CreatedAt.IsZero() {
+			w.CreatedAt = time.Now().UTC()
+		}
+		_, err := stmt.ExecContext(ctx, w.ID, w.JobID, w.RowNumber, w.RecordIdentifier, w.FieldName, w.WarningCode, w.WarningMessage, w.CreatedAt)
+		if err != nil {
+			return err
+		}
+		counts[w.JobID]++
+	}
+
+	for jobID, n := range counts {
+		if _, err := tx.ExecContext(ctx, "UPDATE jobs SET warning_count = warning_count + $2 WHERE id = $1", jobID, n); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetWarnings retrieves warnings for a job with pagination
+func (r *JobRepository) GetWarnings(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobWarning, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	offset := (page - 1) * perPage
+
+	var total int64
+	err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM job_warnings WHERE job_id = $1", jobID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var warnings []*models.JobWarning
+	query := `
+		SELECT * FROM job_warnings
+		WHERE job_id = $1
+		ORDER BY row_number ASC
+		LIMIT $2 OFFSET $3
+	`
+	err = r.db.SelectContext(ctx, &warnings, query, jobID, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return warnings, total, nil
+}
+
+// AddLogs stores the log lines captured for a job and how many older lines
+// were dropped
+func (r *JobRepository) AddLogs(ctx context.Context, jobID uuid.UUID, logs []*models.JobLog, dropped int) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(logs) > 0 {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO job_logs (id, job_id, line_number, level, message, fields, logged_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, l := range logs {
+			if l.ID == uuid.Nil {
+				l.ID = uuid.New()
+			}
+			l.JobID = jobID
+			_, err := stmt.ExecContext(ctx, l.ID, l.JobID, l.LineNumber, l.Level, l.Message, l.Fields, l.LoggedAt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE jobs SET log_lines_dropped = $2 WHERE id = $1", jobID, dropped); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLogs retrieves the stored log lines of a job with pagination
+func (r *JobRepository) GetLogs(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	if perPage > 1000 {
+		perPage = 1000
+	}
+
+	offset := (page - 1) * perPage
+
+	var total int64
+	err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM job_logs WHERE job_id = $1", jobID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var logs []*models.JobLog
+	query := `
+		SELECT * FROM job_logs
+		WHERE job_id = $1
+		ORDER BY line_number ASC
+		LIMIT $2 OFFSET $3
+	`
+	err = r.db.SelectContext(ctx, &logs, query, jobID, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
 // GetPendingJobs retrieves pending jobs of a specific type
 func (r *JobRepository) GetPendingJobs(ctx context.Context, jobType models.JobType, limit int) ([]*models.Job, error) {
 	if limit < 1 {
@@ -230,6 +657,52 @@ func (r *JobRepository) GetPendingJobs(ctx context.Context, jobType models.JobTy
 	return jobs, err
 }
 
+// FinishManually moves a pending or processing job to status with reason
+// as its error message, reporting whether the job was still unfinished
+func (r *JobRepository) FinishManually(ctx context.Context, id uuid.UUID, status models.JobStatus, reason string) (bool, error) {
+	now := time.Now().UTC()
+	query := `
+		UPDATE jobs SET
+			status = $2, error_message = $3, completed_at = $4, updated_at = $4
+		WHERE id = $1 AND status IN ($5, $6)
+	`
+	result, err := r.db.ExecContext(ctx, query, id, status, reason, now, models.JobStatusPending, models.JobStatusProcessing)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// AddTimelineEntry records a note or manual status change on a job
+func (r *JobRepository) AddTimelineEntry(ctx context.Context, entry *models.JobTimelineEntry) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	query := `
+		INSERT INTO job_timeline (id, job_id, kind, message, from_status, to_status, author, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.ExecContext(ctx, query, entry.ID, entry.JobID, entry.Kind, entry.Message,
+		entry.FromStatus, entry.ToStatus, entry.Author, entry.CreatedAt)
+	return err
+}
+
+// GetTimeline returns a job's timeline entries, oldest first
+func (r *JobRepository) GetTimeline(ctx context.Context, jobID uuid.UUID) ([]*models.JobTimelineEntry, error) {
+	var entries []*models.JobTimelineEntry
+	query := `
+		SELECT * FROM job_timeline
+		WHERE job_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+	err := r.db.SelectContext(ctx, &entries, query, jobID)
+	return entries, err
+}
+
 // SetTotalRecords sets the total records count for a job
 func (r *JobRepository) SetTotalRecords(ctx context.Context, id uuid.UUID, total int) error {
 	now := time.Now().UTC()
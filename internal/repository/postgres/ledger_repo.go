@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// LedgerRepository implements repository.LedgerRepository for PostgreSQL
+type LedgerRepository struct {
+	db *DB
+}
+
+// NewLedgerRepository creates a new LedgerRepository
+func NewLedgerRepository(db *DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// ledgerRecordChunk bounds the entries written by one INSERT, at five bind
+// parameters each
+const ledgerRecordChunk = 5000
+
+// Hashes returns the content hash recorded under each of keys that has one
+func (r *LedgerRepository) Hashes(ctx context.Context, resource models.ResourceType, keys []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	for start := 0; start < len(keys); start += existingKeysChunk {
+		end := min(start+existingKeysChunk, len(keys))
+		q, args, err := sqlx.In("SELECT natural_key, content_hash FROM import_ledger WHERE resource = ? AND natural_key IN (?)", resource, keys[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		var rows []struct {
+			NaturalKey  string `db:"natural_key"`
+			ContentHash string `db:"content_hash"`
+		}
+		if err := sqlx.SelectContext(ctx, r.db.conn(ctx), &rows, r.db.Rebind(q), args...); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			hashes[row.NaturalKey] = row.ContentHash
+		}
+	}
+	return hashes, nil
+}
+
+// Record stores entries, replacing any recorded under the same resource and
+// natural key. Inside a caller's transaction the entries commit with it.
+func (r *LedgerRepository) Record(ctx context.Context, entries []*models.LedgerEntry) error {
+	for start := 0; start < len(entries); start += ledgerRecordChunk {
+		chunk := entries[start:min(start+ledgerRecordChunk, len(entries))]
+		valueStrings := make([]string, 0, len(chunk))
+		valueArgs := make([]interface{}, 0, len(chunk)*5)
+		for i, entry := range chunk {
+			if entry.RecordedAt.IsZero() {
+				entry.RecordedAt = time.Now().UTC()
+			}
+			base := i * 5
+			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4, base+5))
+			valueArgs = append(valueArgs, entry.Resource, entry.NaturalKey, entry.ContentHash, entry.JobID, entry.RecordedAt)
+		}
+
+		query := `
+			INSERT INTO import_ledger (resource, natural_key, content_hash, job_id, recorded_at)
+			VALUES ` + strings.Join(valueStrings, ",") + `
+			ON CONFLICT (resource, natural_key) DO UPDATE
+			SET content_hash = EXCLUDED.content_hash, job_id = EXCLUDED.job_id, recorded_at = EXCLUDED.recorded_at
+		`
+		if _, err := r.db.conn(ctx).ExecContext(ctx, query, valueArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// ProfileRepository implements repository.ProfileRepository for PostgreSQL
+type ProfileRepository struct {
+	db *DB
+}
+
+// NewProfileRepository creates a new ProfileRepository
+func NewProfileRepository(db *DB) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+// Save stores the profile for a job, replacing any earlier one
+func (r *ProfileRepository) Save(ctx context.Context, profile *models.ImportProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO job_profiles (job_id, profile)
+		VALUES ($1, $2)
+		ON CONFLICT (job_id) DO UPDATE SET profile = EXCLUDED.profile, created_at = NOW()
+	`
+	_, err = r.db.ExecContext(ctx, query, profile.JobID, data)
+	return err
+}
+
+// GetByJobID retrieves the profile for a job
+func (r *ProfileRepository) GetByJobID(ctx context.Context, jobID uuid.UUID) (*models.ImportProfile, error) {
+	var data []byte
+	err := r.db.GetContext(ctx, &data, "SELECT profile FROM job_profiles WHERE job_id = $1", jobID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profile models.ImportProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// QuotaRepository implements repository.QuotaRepository for PostgreSQL
+type QuotaRepository struct {
+	db *DB
+}
+
+// NewQuotaRepository creates a new QuotaRepository
+func NewQuotaRepository(db *DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// GetUsage computes the current quota usage of a tenant from the jobs table
+func (r *QuotaRepository) GetUsage(ctx context.Context, tenantID string, dayStart, monthStart time.Time) (*models.QuotaUsage, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE created_at >= $2) AS jobs_today,
+			COALESCE(SUM(total_records) FILTER (WHERE created_at >= $3), 0) AS rows_this_month,
+			COALESCE(SUM(file_size_bytes) FILTER (WHERE type = 'export' AND file_path IS NOT NULL), 0) AS export_storage_bytes
+		FROM jobs
+		WHERE tenant_id = $1
+	`
+	var usage models.QuotaUsage
+	if err := r.db.GetContext(ctx, &usage, query, tenantID, dayStart, monthStart); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// SigningKeyRepository implements repository.SigningKeyRepository for PostgreSQL
+type SigningKeyRepository struct {
+	db *DB
+}
+
+// NewSigningKeyRepository creates a new SigningKeyRepository
+func NewSigningKeyRepository(db *DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// Create stores a new key
+func (r *SigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now().UTC()
+	}
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO signing_keys (id, secret, created_at) VALUES ($1, $2, $3)",
+		key.ID, key.Secret, key.CreatedAt)
+	return err
+}
+
+// List returns every key, oldest first
+func (r *SigningKeyRepository) List(ctx context.Context) ([]*models.SigningKey, error) {
+	var keys []*models.SigningKey
+	err := r.db.SelectContext(ctx, &keys, "SELECT id, secret, created_at FROM signing_keys ORDER BY created_at ASC, id ASC")
+	return keys, err
+}
+
+// Delete removes a key
+func (r *SigningKeyRepository) Delete(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM signing_keys WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/rohit/bulk-import-export/internal/repository"
 )
 
@@ -60,20 +61,23 @@ func (r *StagingRepository) CreateStagingUsers(ctx context.Context, jobID uuid.U
 	return tx.Commit()
 }
 
-// MarkDuplicateUsersInBatch marks duplicate emails within the same batch
+// MarkDuplicateUsersInBatch marks duplicate emails within the same batch.
+// Rows are ranked per email in a single sorted window pass rather than
+// compared pairwise; the other in-batch checks below work the same way.
 func (r *StagingRepository) MarkDuplicateUsersInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
 	query := `
-		UPDATE staging_users s1
-		SET is_duplicate = true, 
+		UPDATE staging_users s
+		SET is_duplicate = true,
 		    validation_error = 'DUPLICATE_EMAIL',
 		    is_valid = false
-		WHERE job_id = $1
-		AND EXISTS (
-			SELECT 1 FROM staging_users s2
-			WHERE s2.job_id = s1.job_id
-			AND LOWER(s2.email) = LOWER(s1.email)
-			AND s2.staging_id < s1.staging_id
-		)
+		FROM (
+			SELECT staging_id,
+			       ROW_NUMBER() OVER (PARTITION BY LOWER(email) ORDER BY staging_id) AS rn
+			FROM staging_users
+			WHERE job_id = $1 AND email IS NOT NULL
+		) d
+		WHERE s.staging_id = d.staging_id
+		AND d.rn > 1
 	`
 	result, err := r.db.ExecContext(ctx, query, jobID)
 	if err != nil {
@@ -92,6 +96,7 @@ func (r *StagingRepository) MarkDuplicateUsersAgainstExisting(ctx context.Contex
 		    is_valid = false
 		WHERE job_id = $1
 		AND is_valid = true
+		AND processed = false
 		AND EXISTS (
 			SELECT 1 FROM users u WHERE LOWER(u.email) = LOWER(s.email)
 		)
@@ -168,48 +173,66 @@ func (r *StagingRepository) CreateStagingArticles(ctx context.Context, jobID uui
 	}
 	defer tx.Rollback()
 
+	// Bodies can be large, so the batch is split into statements by size
+	size := func(i int) int {
+		n := 0
+		for _, field := range []*string{articles[i].Body, articles[i].Title, articles[i].Tags} {
+			if field != nil {
+				n += len(*field)
+			}
+		}
+		return n
+	}
+	for _, chunk := range r.db.chunkBySize(len(articles), size) {
+		if err := insertStagingArticles(ctx, tx, jobID, articles[chunk[0]:chunk[1]]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertStagingArticles stages articles with one multi-row INSERT
+func insertStagingArticles(ctx context.Context, tx *sqlx.Tx, jobID uuid.UUID, articles []repository.StagingArticle) error {
 	valueStrings := make([]string, 0, len(articles))
-	valueArgs := make([]interface{}, 0, len(articles)*12)
+	valueArgs := make([]interface{}, 0, len(articles)*13)
 
 	for i, article := range articles {
-		base := i * 12
+		base := i * 13
 		valueStrings = append(valueStrings, fmt.Sprintf(
-			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10, base+11, base+12,
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10, base+11, base+12, base+13,
 		))
 		valueArgs = append(valueArgs,
 			jobID, article.RowNumber, article.ID, article.Slug, article.Title, article.Body,
-			article.AuthorID, article.Tags, article.PublishedAt, article.Status, article.ValidationError, article.IsValid,
+			article.AuthorID, article.Tags, article.PublishedAt, article.Status, article.Lang, article.ValidationError, article.IsValid,
 		)
 	}
 
 	query := fmt.Sprintf(`
-		INSERT INTO staging_articles (job_id, row_number, id, slug, title, body, author_id, tags, published_at, status, validation_error, is_valid)
+		INSERT INTO staging_articles (job_id, row_number, id, slug, title, body, author_id, tags, published_at, status, lang, validation_error, is_valid)
 		VALUES %s
 	`, strings.Join(valueStrings, ","))
 
-	_, err = tx.ExecContext(ctx, query, valueArgs...)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	_, err := tx.ExecContext(ctx, query, valueArgs...)
+	return err
 }
 
 // MarkDuplicateArticlesInBatch marks duplicate slugs within the same batch
 func (r *StagingRepository) MarkDuplicateArticlesInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
 	query := `
-		UPDATE staging_articles s1
+		UPDATE staging_articles s
 		SET is_duplicate = true,
 		    validation_error = 'DUPLICATE_SLUG',
 		    is_valid = false
-		WHERE job_id = $1
-		AND EXISTS (
-			SELECT 1 FROM staging_articles s2
-			WHERE s2.job_id = s1.job_id
-			AND LOWER(s2.slug) = LOWER(s1.slug)
-			AND s2.staging_id < s1.staging_id
-		)
+		FROM (
+			SELECT staging_id,
+			       ROW_NUMBER() OVER (PARTITION BY LOWER(slug) ORDER BY staging_id) AS rn
+			FROM staging_articles
+			WHERE job_id = $1 AND slug IS NOT NULL
+		) d
+		WHERE s.staging_id = d.staging_id
+		AND d.rn > 1
 	`
 	result, err := r.db.ExecContext(ctx, query, jobID)
 	if err != nil {
@@ -228,6 +251,7 @@ func (r *StagingRepository) MarkDuplicateArticlesAgainstExisting(ctx context.Con
 		    is_valid = false
 		WHERE job_id = $1
 		AND is_valid = true
+		AND processed = false
 		AND EXISTS (
 			SELECT 1 FROM articles a WHERE LOWER(a.slug) = LOWER(s.slug)
 		)
@@ -249,6 +273,7 @@ func (r *StagingRepository) MarkInvalidAuthorFKArticles(ctx context.Context, job
 		    validation_error = 'INVALID_AUTHOR_FK'
 		WHERE job_id = $1
 		AND is_valid = true
+		AND processed = false
 		AND s.author_id IS NOT NULL
 		AND NOT EXISTS (
 			SELECT 1 FROM users u WHERE u.id::text = s.author_id
@@ -262,6 +287,26 @@ func (r *StagingRepository) MarkInvalidAuthorFKArticles(ctx context.Context, job
 	return int(affected), nil
 }
 
+// MissingArticleAuthors returns the distinct author IDs of valid staged
+// articles that aren't users
+func (r *StagingRepository) MissingArticleAuthors(ctx context.Context, jobID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT DISTINCT s.author_id FROM staging_articles s
+		WHERE s.job_id = $1
+		AND s.is_valid = true
+		AND s.processed = false
+		AND s.author_id IS NOT NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM users u WHERE u.id::text = s.author_id
+		)
+	`
+	var authors []string
+	if err := r.db.SelectContext(ctx, &authors, query, jobID); err != nil {
+		return nil, err
+	}
+	return authors, nil
+}
+
 // GetValidStagingArticles retrieves valid staging articles in batches
 func (r *StagingRepository) GetValidStagingArticles(ctx context.Context, jobID uuid.UUID, batchSize int, callback func([]repository.StagingArticle) error) error {
 	query := `
@@ -326,22 +371,22 @@ func (r *StagingRepository) CreateStagingComments(ctx context.Context, jobID uui
 	defer tx.Rollback()
 
 	valueStrings := make([]string, 0, len(comments))
-	valueArgs := make([]interface{}, 0, len(comments)*9)
+	valueArgs := make([]interface{}, 0, len(comments)*10)
 
 	for i, comment := range comments {
-		base := i * 9
+		base := i * 10
 		valueStrings = append(valueStrings, fmt.Sprintf(
-			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9,
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10,
 		))
 		valueArgs = append(valueArgs,
 			jobID, comment.RowNumber, comment.ID, comment.ArticleID, comment.UserID,
-			comment.Body, comment.CreatedAt, comment.ValidationError, comment.IsValid,
+			comment.Body, comment.CreatedAt, comment.Lang, comment.ValidationError, comment.IsValid,
 		)
 	}
 
 	query := fmt.Sprintf(`
-		INSERT INTO staging_comments (job_id, row_number, id, article_id, user_id, body, created_at, validation_error, is_valid)
+		INSERT INTO staging_comments (job_id, row_number, id, article_id, user_id, body, created_at, lang, validation_error, is_valid)
 		VALUES %s
 	`, strings.Join(valueStrings, ","))
 
@@ -357,17 +402,83 @@ func (r *StagingRepository) CreateStagingComments(ctx context.Context, jobID uui
 func (r *StagingRepository) MarkDuplicateCommentsInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
 	// Comments can have duplicates based on ID only
 	query := `
-		UPDATE staging_comments s1
+		UPDATE staging_comments s
 		SET is_duplicate = true,
 		    validation_error = 'DUPLICATE_ID',
 		    is_valid = false
+		FROM (
+			SELECT staging_id,
+			       ROW_NUMBER() OVER (PARTITION BY id ORDER BY staging_id) AS rn
+			FROM staging_comments
+			WHERE job_id = $1 AND id IS NOT NULL
+		) d
+		WHERE s.staging_id = d.staging_id
+		AND d.rn > 1
+	`
+	result, err := r.db.ExecContext(ctx, query, jobID)
+	if err != nil {
+		return 0, err
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// SetCommentNaturalKeys computes the natural key of each valid staging
+// comment with the comment_natural_key function, so staged rows and stored
+// comments hash the same way
+func (r *StagingRepository) SetCommentNaturalKeys(ctx context.Context, jobID uuid.UUID) error {
+	query := `
+		UPDATE staging_comments
+		SET natural_key = comment_natural_key(article_id::uuid, user_id::uuid, body, created_at::timestamptz)
+		WHERE job_id = $1
+		AND is_valid = true
+	`
+	_, err := r.db.ExecContext(ctx, query, jobID)
+	return err
+}
+
+// MarkDuplicateCommentsByNaturalKeyInBatch marks comments whose natural key
+// repeats an earlier row of the same job
+func (r *StagingRepository) MarkDuplicateCommentsByNaturalKeyInBatch(ctx context.Context, jobID uuid.UUID) (int, error) {
+	query := `
+		UPDATE staging_comments s
+		SET is_duplicate = true,
+		    validation_error = 'DUPLICATE_COMMENT',
+		    is_valid = false
+		FROM (
+			SELECT staging_id,
+			       ROW_NUMBER() OVER (PARTITION BY natural_key ORDER BY staging_id) AS rn
+			FROM staging_comments
+			WHERE job_id = $1 AND natural_key IS NOT NULL
+		) d
+		WHERE s.staging_id = d.staging_id
+		AND d.rn > 1
+	`
+	result, err := r.db.ExecContext(ctx, query, jobID)
+	if err != nil {
+		return 0, err
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// MarkDuplicateCommentsByNaturalKeyAgainstExisting marks comments whose
+// natural key matches a stored comment. A row carrying that comment's own ID
+// is an update and is left alone.
+func (r *StagingRepository) MarkDuplicateCommentsByNaturalKeyAgainstExisting(ctx context.Context, jobID uuid.UUID) (int, error) {
+	query := `
+		UPDATE staging_comments s
+		SET is_duplicate = true,
+		    validation_error = 'DUPLICATE_COMMENT',
+		    is_valid = false
 		WHERE job_id = $1
-		AND s1.id IS NOT NULL
+		AND is_valid = true
+		AND processed = false
+		AND s.natural_key IS NOT NULL
 		AND EXISTS (
-			SELECT 1 FROM staging_comments s2
-			WHERE s2.job_id = s1.job_id
-			AND s2.id = s1.id
-			AND s2.staging_id < s1.staging_id
+			SELECT 1 FROM comments c
+			WHERE comment_natural_key(c.article_id, c.user_id, c.body, c.created_at) = s.natural_key
+			AND (s.id IS NULL OR c.id::text <> s.id)
 		)
 	`
 	result, err := r.db.ExecContext(ctx, query, jobID)
@@ -390,6 +501,7 @@ func (r *StagingRepository) MarkInvalidFKComments(ctx context.Context, jobID uui
 		    END
 		WHERE job_id = $1
 		AND is_valid = true
+		AND processed = false
 		AND (
 		    (s.article_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM articles a WHERE a.id::text = s.article_id))
 		    OR (s.user_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id::text = s.user_id))
@@ -509,6 +621,46 @@ func (r *StagingRepository) MarkCommentsProcessed(ctx context.Context, jobID uui
 	return err
 }
 
+// KeepCommittedUsers implements repository.StagingRepository
+func (r *StagingRepository) KeepCommittedUsers(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	return r.keepCommitted(ctx, "staging_users", jobID, through)
+}
+
+// KeepCommittedArticles implements repository.StagingRepository
+func (r *StagingRepository) KeepCommittedArticles(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	return r.keepCommitted(ctx, "staging_articles", jobID, through)
+}
+
+// KeepCommittedComments implements repository.StagingRepository
+func (r *StagingRepository) KeepCommittedComments(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	return r.keepCommitted(ctx, "staging_comments", jobID, through)
+}
+
+// keepCommitted marks the rows of jobID in table that were written through
+// staging ID through as processed and deletes its other unprocessed rows, in
+// one transaction. Batches are read in staging ID order, so every valid row
+// up to the last committed ID was written.
+func (r *StagingRepository) keepCommitted(ctx context.Context, table string, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	var rows []repository.CommittedRow
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		conn := r.db.conn(ctx)
+		_, err := conn.ExecContext(ctx, `
+			UPDATE `+table+`
+			SET processed = true
+			WHERE job_id = $1 AND staging_id <= $2
+			AND is_valid = true AND is_duplicate = false AND processed = false`, jobID, through)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, "DELETE FROM "+table+" WHERE job_id = $1 AND processed = false", jobID); err != nil {
+			return err
+		}
+		return sqlx.SelectContext(ctx, conn, &rows,
+			"SELECT staging_id, row_number FROM "+table+" WHERE job_id = $1 ORDER BY staging_id", jobID)
+	})
+	return rows, err
+}
+
 // CountStagingUsers counts staging users for a job
 func (r *StagingRepository) CountStagingUsers(ctx context.Context, jobID uuid.UUID) (total, valid, invalid int, err error) {
 	query := `
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// TombstoneRepository implements repository.TombstoneRepository for PostgreSQL
+type TombstoneRepository struct {
+	db *DB
+}
+
+// NewTombstoneRepository creates a new TombstoneRepository
+func NewTombstoneRepository(db *DB) *TombstoneRepository {
+	return &TombstoneRepository{db: db}
+}
+
+// GetDeletedWithCursor streams tombstones for a resource deleted in (from, to]
+func (r *TombstoneRepository) GetDeletedWithCursor(ctx context.Context, resource models.ResourceType, from, to time.Time, batchSize int, callback func([]*models.Tombstone) error) error {
+	query := `
+		SELECT * FROM record_tombstones
+		WHERE resource = $1 AND deleted_at > $2 AND deleted_at <= $3
+		ORDER BY deleted_at ASC, id ASC
+	`
+	rows, err := r.db.conn(ctx).QueryxContext(ctx, query, resource, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	batch := make([]*models.Tombstone, 0, batchSize)
+	for rows.Next() {
+		var tombstone models.Tombstone
+		if err := rows.StructScan(&tombstone); err != nil {
+			return err
+		}
+		batch = append(batch, &tombstone)
+
+		if len(batch) >= batchSize {
+			if err := callback(batch); err != nil {
+				return err
+			}
+			batch = make([]*models.Tombstone, 0, batchSize)
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := callback(batch); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
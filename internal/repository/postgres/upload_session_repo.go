@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// UploadSessionRepository implements repository.UploadSessionRepository for
+// PostgreSQL
+type UploadSessionRepository struct {
+	db *DB
+}
+
+// NewUploadSessionRepository creates a new UploadSessionRepository
+func NewUploadSessionRepository(db *DB) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+// Create stores a new session
+func (r *UploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now().UTC()
+	}
+	if session.Status == "" {
+		session.Status = models.UploadSessionOpen
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO upload_sessions (id, tenant_id, file_name, status, job_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		session.ID, session.TenantID, session.FileName, session.Status, session.JobID, session.CreatedAt, session.ExpiresAt)
+	return err
+}
+
+// GetByID returns the session with id, or nil if there is none
+func (r *UploadSessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := r.db.GetContext(ctx, &session, "SELECT * FROM upload_sessions WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &session, err
+}
+
+// PutPart records a received part, replacing one with the same number
+func (r *UploadSessionRepository) PutPart(ctx context.Context, part *models.UploadPart) error {
+	if part.ReceivedAt.IsZero() {
+		part.ReceivedAt = time.Now().UTC()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO upload_parts (session_id, part_number, size_bytes, sha256, received_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (session_id, part_number) DO UPDATE SET
+			size_bytes = EXCLUDED.size_bytes,
+			sha256 = EXCLUDED.sha256,
+			received_at = EXCLUDED.received_at`,
+		part.SessionID, part.PartNumber, part.SizeBytes, part.SHA256, part.ReceivedAt)
+	return err
+}
+
+// ListParts returns the parts of a session by part number
+func (r *UploadSessionRepository) ListParts(ctx context.Context, sessionID uuid.UUID) ([]*models.UploadPart, error) {
+	var parts []*models.UploadPart
+	err := r.db.SelectContext(ctx, &parts, "SELECT * FROM upload_parts WHERE session_id = $1 ORDER BY part_number", sessionID)
+	return parts, err
+}
+
+// Complete marks an open session completed by jobID, returning false when it
+// was no longer open
+func (r *UploadSessionRepository) Complete(ctx context.Context, id, jobID uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE upload_sessions SET status = $2, job_id = $3 WHERE id = $1 AND status = $4",
+		id, models.UploadSessionCompleted, jobID, models.UploadSessionOpen)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// DeleteExpired removes the sessions that expired before now, with their
+// parts, and returns their IDs
+func (r *UploadSessionRepository) DeleteExpired(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.SelectContext(ctx, &ids, "DELETE FROM upload_sessions WHERE expires_at < $1 RETURNING id", now)
+	return ids, err
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// usageColumns aggregates the jobs table into models.TenantUsage columns
+const usageColumns = `
+	COUNT(*) AS jobs,
+	COUNT(*) FILTER (WHERE type = 'import') AS import_jobs,
+	COUNT(*) FILTER (WHERE type = 'export') AS export_jobs,
+	COUNT(*) FILTER (WHERE status IN ('failed', 'dead_letter')) AS failed_jobs,
+	COALESCE(SUM(successful_records) FILTER (WHERE type = 'import'), 0) AS rows_imported,
+	COALESCE(SUM(successful_records) FILTER (WHERE type = 'export'), 0) AS rows_exported,
+	COALESCE(SUM(failed_records) FILTER (WHERE type = 'import'), 0) AS rows_failed,
+	COALESCE(SUM(file_size_bytes) FILTER (WHERE type = 'export' AND file_path IS NOT NULL), 0) AS bytes_stored
+`
+
+// UsageRepository implements repository.UsageRepository for PostgreSQL
+type UsageRepository struct {
+	db *DB
+}
+
+// NewUsageRepository creates a new UsageRepository
+func NewUsageRepository(db *DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// GetUsage aggregates the jobs created in [from, to) per tenant
+func (r *UsageRepository) GetUsage(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error) {
+	query := `
+		SELECT tenant_id,` + usageColumns + `
+		FROM jobs
+		WHERE created_at >= $1 AND created_at < $2 AND ($3 = '' OR tenant_id = $3)
+		GROUP BY tenant_id
+		ORDER BY tenant_id
+	`
+	usage := []*models.TenantUsage{}
+	if err := r.db.SelectContext(ctx, &usage, query, from, to, tenantID); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// GetRollups sums the daily rollups of the days in [from, to) per tenant
+func (r *UsageRepository) GetRollups(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error) {
+	query := `
+		SELECT tenant_id,
+			SUM(jobs) AS jobs,
+			SUM(import_jobs) AS import_jobs,
+			SUM(export_jobs) AS export_jobs,
+			SUM(failed_jobs) AS failed_jobs,
+			SUM(rows_imported) AS rows_imported,
+			SUM(rows_exported) AS rows_exported,
+			SUM(rows_failed) AS rows_failed,
+			SUM(bytes_stored) AS bytes_stored
+		FROM usage_daily
+		WHERE day >= $1::date AND day < $2::date AND ($3 = '' OR tenant_id = $3)
+		GROUP BY tenant_id
+		ORDER BY tenant_id
+	`
+	usage := []*models.TenantUsage{}
+	if err := r.db.SelectContext(ctx, &usage, query, dateOf(from), dateOf(to), tenantID); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// RolledUpUntil returns the end of the last rolled-up day, or nil if no day
+// has been rolled up yet
+func (r *UsageRepository) RolledUpUntil(ctx context.Context) (*time.Time, error) {
+	var day sql.NullTime
+	if err := r.db.GetContext(ctx, &day, `SELECT MAX(day) FROM usage_rollup_days`); err != nil {
+		return nil, err
+	}
+	if !day.Valid {
+		return nil, nil
+	}
+	until := time.Date(day.Time.Year(), day.Time.Month(), day.Time.Day()+1, 0, 0, 0, 0, time.UTC)
+	return &until, nil
+}
+
+// FirstJobAt returns when the oldest job was created, or nil without jobs
+func (r *UsageRepository) FirstJobAt(ctx context.Context) (*time.Time, error) {
+	var first sql.NullTime
+	if err := r.db.GetContext(ctx, &first, `SELECT MIN(created_at) FROM jobs`); err != nil {
+		return nil, err
+	}
+	if !first.Valid {
+		return nil, nil
+	}
+	return &first.Time, nil
+}
+
+// RollupDay replaces the usage_daily rows of the UTC day starting at day
+// with an aggregate of the jobs created on it
+func (r *UsageRepository) RollupDay(ctx context.Context, day time.Time) error {
+	day = day.UTC()
+	next := day.AddDate(0, 0, 1)
+
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_daily WHERE day = $1::date`, dateOf(day)); err != nil {
+		return err
+	}
+
+	insert := `
+		INSERT INTO usage_daily (day, tenant_id, jobs, import_jobs, export_jobs, failed_jobs,
+			rows_imported, rows_exported, rows_failed, bytes_stored)
+		SELECT $1::date, tenant_id,` + usageColumns + `
+		FROM jobs
+		WHERE created_at >= $2 AND created_at < $3
+		GROUP BY tenant_id
+	`
+	if _, err := tx.ExecContext(ctx, insert, dateOf(day), day, next); err != nil {
+		return err
+	}
+
+	mark := `
+		INSERT INTO usage_rollup_days (day, computed_at) VALUES ($1::date, NOW())
+		ON CONFLICT (day) DO UPDATE SET computed_at = EXCLUDED.computed_at
+	`
+	if _, err := tx.ExecContext(ctx, mark, dateOf(day)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// dateOf formats the UTC date of t, so DATE columns don't depend on the
+// session time zone
+func dateOf(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
@@ -35,30 +36,29 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	}
 
 	query := `
-		INSERT INTO users (id, email, name, role, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (id, email, name, role, active, placeholder, import_job_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	_, err := r.db.ExecContext(ctx, query,
-		user.ID, user.Email, user.Name, user.Role, user.Active, user.CreatedAt, user.UpdatedAt)
+		user.ID, user.Email, user.Name, user.Role, user.Active, user.Placeholder, user.ImportJobID, user.CreatedAt, user.UpdatedAt)
 	return err
 }
 
-// CreateBatch inserts multiple users using COPY
+// CreateBatch inserts multiple users, updating any whose ID already exists
 func (r *UserRepository) CreateBatch(ctx context.Context, users []*models.User) (int, error) {
+	inserted, updated, err := r.UpsertBatch(ctx, users, models.UpsertKeyID)
+	return inserted + updated, err
+}
+
+// CreateMissing inserts the users whose ID and email aren't taken, leaving
+// existing users untouched
+func (r *UserRepository) CreateMissing(ctx context.Context, users []*models.User) (int, error) {
 	if len(users) == 0 {
 		return 0, nil
 	}
 
-	tx, err := r.db.BeginTx(ctx)
-	if err != nil {
-		return 0, err
-	}
-	defer tx.Rollback()
-
-	// Prepare batch insert
 	valueStrings := make([]string, 0, len(users))
-	valueArgs := make([]interface{}, 0, len(users)*7)
-
+	valueArgs := make([]interface{}, 0, len(users)*9)
 	for i, user := range users {
 		if user.ID == uuid.Nil {
 			user.ID = uuid.New()
@@ -70,32 +70,22 @@ func (r *UserRepository) CreateBatch(ctx context.Context, users []*models.User)
 			user.UpdatedAt = time.Now().UTC()
 		}
 
-		base := i * 7
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			base+1, base+2, base+3, base+4, base+5, base+6, base+7))
-		valueArgs = append(valueArgs, user.ID, user.Email, user.Name, user.Role, user.Active, user.CreatedAt, user.UpdatedAt)
+		base := i * 9
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9))
+		valueArgs = append(valueArgs, user.ID, user.Email, user.Name, user.Role, user.Active, user.Placeholder, user.ImportJobID, user.CreatedAt, user.UpdatedAt)
 	}
 
 	query := fmt.Sprintf(`
-		INSERT INTO users (id, email, name, role, active, created_at, updated_at)
+		INSERT INTO users (id, email, name, role, active, placeholder, import_job_id, created_at, updated_at)
 		VALUES %s
-		ON CONFLICT (id) DO UPDATE SET
-			email = EXCLUDED.email,
-			name = EXCLUDED.name,
-			role = EXCLUDED.role,
-			active = EXCLUDED.active,
-			updated_at = EXCLUDED.updated_at
+		ON CONFLICT DO NOTHING
 	`, strings.Join(valueStrings, ","))
 
-	result, err := tx.ExecContext(ctx, query, valueArgs...)
+	result, err := r.db.conn(ctx).ExecContext(ctx, query, valueArgs...)
 	if err != nil {
 		return 0, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return 0, err
-	}
-
 	affected, _ := result.RowsAffected()
 	return int(affected), nil
 }
@@ -122,45 +112,100 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 
 // GetAll retrieves all users with optional filters
 func (r *UserRepository) GetAll(ctx context.Context, filters *models.ExportFilters) ([]*models.User, error) {
-	query, args := r.buildSelectQuery(filters)
 	var users []*models.User
-	err := r.db.SelectContext(ctx, &users, query, args...)
-	return users, err
+	for _, chunk := range cohortChunks(filters) {
+		query, args := r.buildSelectQuery(chunk, "SELECT * FROM users")
+		var chunkUsers []*models.User
+		if err := r.db.SelectContext(ctx, &chunkUsers, query, args...); err != nil {
+			return nil, err
+		}
+		users = append(users, chunkUsers...)
+	}
+	return users, nil
 }
 
 // GetAllWithCursor streams users using a cursor for memory efficiency
 func (r *UserRepository) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.User) error) error {
-	query, args := r.buildSelectQuery(filters)
+	return streamUsers(ctx, r, filters, "SELECT * FROM users", batchSize, callback)
+}
 
-	rows, err := r.db.QueryxContext(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
+// selectUsersWithCounts joins each user's article and comment counts, grouped
+// once per table rather than counted per user
+const selectUsersWithCounts = `SELECT users.*,
+		COALESCE(a.article_count, 0) AS article_count,
+		COALESCE(c.comment_count, 0) AS comment_count
+	FROM users
+	LEFT JOIN (SELECT author_id, COUNT(*) AS article_count FROM articles GROUP BY author_id) a ON a.author_id = users.id
+	LEFT JOIN (SELECT user_id, COUNT(*) AS comment_count FROM comments GROUP BY user_id) c ON c.user_id = users.id`
+
+// GetAllWithCountsWithCursor streams users like GetAllWithCursor, along with
+// the number of articles and comments each has written
+func (r *UserRepository) GetAllWithCountsWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.UserWithCounts) error) error {
+	return streamUsers(ctx, r, filters, selectUsersWithCounts, batchSize, callback)
+}
 
-	batch := make([]*models.User, 0, batchSize)
-	for rows.Next() {
-		var user models.User
-		if err := rows.StructScan(&user); err != nil {
+// streamUsers runs selectFrom with the filters, once per chunk of a cohort,
+// and passes the rows to callback in batches of batchSize
+func streamUsers[T any](ctx context.Context, r *UserRepository, filters *models.ExportFilters, selectFrom string, batchSize int, callback func([]*T) error) error {
+	batch := make([]*T, 0, batchSize)
+	for _, chunk := range cohortChunks(filters) {
+		query, args := r.buildSelectQuery(chunk, selectFrom)
+		rows, err := r.db.conn(ctx).QueryxContext(ctx, query, args...)
+		if err != nil {
 			return err
 		}
-		batch = append(batch, &user)
 
-		if len(batch) >= batchSize {
-			if err := callback(batch); err != nil {
+		for rows.Next() {
+			var row T
+			if err := rows.StructScan(&row); err != nil {
+				rows.Close()
 				return err
 			}
-			batch = make([]*models.User, 0, batchSize)
+			batch = append(batch, &row)
+
+			if len(batch) >= batchSize {
+				if err := callback(batch); err != nil {
+					rows.Close()
+					return err
+				}
+				batch = make([]*T, 0, batchSize)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
 		}
 	}
 
 	if len(batch) > 0 {
-		if err := callback(batch); err != nil {
-			return err
-		}
+		return callback(batch)
 	}
+	return nil
+}
 
-	return rows.Err()
+// cohortChunk bounds the IDs or emails matched by one query of a cohort export
+const cohortChunk = 5000
+
+// cohortChunks splits filters with a long cohort into filters matching at
+// most cohortChunk users each, so no single query carries the whole list.
+// Other filters are returned as they are.
+func cohortChunks(filters *models.ExportFilters) []*models.ExportFilters {
+	if filters == nil || len(filters.IDs)+len(filters.Emails) <= cohortChunk {
+		return []*models.ExportFilters{filters}
+	}
+	var chunks []*models.ExportFilters
+	for start := 0; start < len(filters.IDs); start += cohortChunk {
+		chunk := *filters
+		chunk.IDs, chunk.Emails = filters.IDs[start:min(start+cohortChunk, len(filters.IDs))], nil
+		chunks = append(chunks, &chunk)
+	}
+	for start := 0; start < len(filters.Emails); start += cohortChunk {
+		chunk := *filters
+		chunk.IDs, chunk.Emails = nil, filters.Emails[start:min(start+cohortChunk, len(filters.Emails))]
+		chunks = append(chunks, &chunk)
+	}
+	return chunks
 }
 
 // Update updates an existing user
@@ -168,46 +213,99 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	user.UpdatedAt = time.Now().UTC()
 	query := `
 		UPDATE users 
-		SET email = $2, name = $3, role = $4, active = $5, updated_at = $6
+		SET email = $2, name = $3, role = $4, active = $5, placeholder = $6, import_job_id = $7, updated_at = $8
 		WHERE id = $1
 	`
-	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.Name, user.Role, user.Active, user.UpdatedAt)
+	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.Name, user.Role, user.Active, user.Placeholder, user.ImportJobID, user.UpdatedAt)
 	return err
 }
 
-// Upsert inserts or updates a user
-func (r *UserRepository) Upsert(ctx context.Context, user *models.User) error {
-	if user.ID == uuid.Nil {
-		user.ID = uuid.New()
-	}
-	if user.CreatedAt.IsZero() {
-		user.CreatedAt = time.Now().UTC()
-	}
+// Upsert inserts a user or updates the one matching it on key
+func (r *UserRepository) Upsert(ctx context.Context, user *models.User, key models.UpsertKey) error {
 	user.UpdatedAt = time.Now().UTC()
-
-	query := `
-		INSERT INTO users (id, email, name, role, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (email) DO UPDATE SET
-			name = EXCLUDED.name,
-			role = EXCLUDED.role,
-			active = EXCLUDED.active,
-			updated_at = EXCLUDED.updated_at
-	`
-	_, err := r.db.ExecContext(ctx, query,
-		user.ID, user.Email, user.Name, user.Role, user.Active, user.CreatedAt, user.UpdatedAt)
+	_, _, err := r.UpsertBatch(ctx, []*models.User{user}, key)
 	return err
 }
 
-// UpsertBatch upserts multiple users
-func (r *UserRepository) UpsertBatch(ctx context.Context, users []*models.User) (int, int, error) {
+// userUpsertSet overwrites the columns of a user an upsert matched, all but
+// its identity
+const userUpsertSet = `
+	name = EXCLUDED.name,
+	role = EXCLUDED.role,
+	active = EXCLUDED.active,
+	placeholder = EXCLUDED.placeholder,
+	import_job_id = COALESCE(EXCLUDED.import_job_id, users.import_job_id),
+	updated_at = EXCLUDED.updated_at`
+
+// UpsertBatch upserts users with one multi-row INSERT. Users matched on
+// email take the stored user's ID.
+func (r *UserRepository) UpsertBatch(ctx context.Context, users []*models.User, key models.UpsertKey) (int, int, error) {
 	if len(users) == 0 {
 		return 0, 0, nil
 	}
 
-	// Use CreateBatch which already has ON CONFLICT handling
-	count, err := r.CreateBatch(ctx, users)
-	return count, 0, err // For simplicity, we don't track updates separately
+	// A write outside an import keeps the job that last imported the user
+	var conflict string
+	switch key {
+	case models.UpsertKeyID, "":
+		conflict = "ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email," + userUpsertSet
+	case models.UpsertKeyEmail:
+		conflict = "ON CONFLICT (email) DO UPDATE SET" + userUpsertSet
+	default:
+		return 0, 0, fmt.Errorf("users cannot be upserted on %q", key)
+	}
+
+	valueStrings := make([]string, 0, len(users))
+	valueArgs := make([]interface{}, 0, len(users)*9)
+	for i, user := range users {
+		if user.ID == uuid.Nil {
+			user.ID = uuid.New()
+		}
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = time.Now().UTC()
+		}
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = time.Now().UTC()
+		}
+
+		base := i * 9
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9))
+		valueArgs = append(valueArgs, user.ID, user.Email, user.Name, user.Role, user.Active, user.Placeholder, user.ImportJobID, user.CreatedAt, user.UpdatedAt)
+	}
+
+	// xmax is zero for the rows the statement inserted
+	query := fmt.Sprintf(`
+		INSERT INTO users (id, email, name, role, active, placeholder, import_job_id, created_at, updated_at)
+		VALUES %s
+		%s
+		RETURNING id, email, xmax = 0 AS inserted
+	`, strings.Join(valueStrings, ","), conflict)
+
+	// Inside a caller's transaction the batch commits with it
+	var written []struct {
+		ID       uuid.UUID `db:"id"`
+		Email    string    `db:"email"`
+		Inserted bool      `db:"inserted"`
+	}
+	if err := sqlx.SelectContext(ctx, r.db.conn(ctx), &written, query, valueArgs...); err != nil {
+		return 0, 0, err
+	}
+
+	inserted := 0
+	ids := make(map[string]uuid.UUID, len(written))
+	for _, w := range written {
+		if w.Inserted {
+			inserted++
+		}
+		ids[w.Email] = w.ID
+	}
+	for _, user := range users {
+		if id, ok := ids[user.Email]; ok {
+			user.ID = id
+		}
+	}
+	return inserted, len(written) - inserted, nil
 }
 
 // Delete deletes a user by ID
@@ -237,42 +335,63 @@ func (r *UserRepository) EmailExists(ctx context.Context, email string, excludeI
 	return exists, err
 }
 
-// Count returns the number of users matching the filters
-func (r *UserRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
-	query := "SELECT COUNT(*) FROM users"
-	args := []interface{}{}
-	conditions := []string{}
+// ExistingEmails returns which of the lowercased emails belong to a user
+func (r *UserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.db, "SELECT LOWER(email) FROM users WHERE LOWER(email) IN (?)", emails)
+}
 
-	if filters != nil {
-		if filters.Role != nil {
-			conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)+1))
-			args = append(args, *filters.Role)
-		}
-		if filters.Active != nil {
-			conditions = append(conditions, fmt.Sprintf("active = $%d", len(args)+1))
-			args = append(args, *filters.Active)
+// GetByEmails returns the users with any of emails, keyed by their
+// lowercased email. Inside a caller's transaction it reads what the
+// transaction wrote.
+func (r *UserRepository) GetByEmails(ctx context.Context, emails []string) (map[string]*models.User, error) {
+	result := make(map[string]*models.User)
+	for start := 0; start < len(emails); start += existingKeysChunk {
+		end := min(start+existingKeysChunk, len(emails))
+		query, args, err := sqlx.In("SELECT * FROM users WHERE LOWER(email) IN (?)", emails[start:end])
+		if err != nil {
+			return nil, err
 		}
-		if filters.CreatedAfter != nil {
-			conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)+1))
-			args = append(args, *filters.CreatedAfter)
+
+		var users []*models.User
+		if err := sqlx.SelectContext(ctx, r.db.conn(ctx), &users, r.db.Rebind(query), args...); err != nil {
+			return nil, err
 		}
-		if filters.CreatedBefore != nil {
-			conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)+1))
-			args = append(args, *filters.CreatedBefore)
+		for _, user := range users {
+			result[strings.ToLower(user.Email)] = user
 		}
 	}
+	return result, nil
+}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+// ExistingIDs returns which of ids are users, keyed by their canonical text
+// form. Malformed IDs are never found.
+func (r *UserRepository) ExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.db, "SELECT id::text FROM users WHERE id IN (?)", parseIDs(ids))
+}
+
+// Count returns the number of users matching the filters
+func (r *UserRepository) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
+	var total int64
+	for _, chunk := range cohortChunks(filters) {
+		query, args := r.buildWhere(chunk, "SELECT COUNT(*) FROM users")
+		var count int64
+		if err := sqlx.GetContext(ctx, r.db.conn(ctx), &count, query, args...); err != nil {
+			return 0, err
+		}
+		total += count
 	}
+	return total, nil
+}
 
-	var count int64
-	err := r.db.GetContext(ctx, &count, query, args...)
-	return count, err
+// buildSelectQuery appends the filter conditions to selectFrom, which selects
+// from users, in creation order with the ID breaking ties
+func (r *UserRepository) buildSelectQuery(filters *models.ExportFilters, selectFrom string) (string, []interface{}) {
+	query, args := r.buildWhere(filters, selectFrom)
+	return query + " ORDER BY created_at ASC, id ASC", args
 }
 
-func (r *UserRepository) buildSelectQuery(filters *models.ExportFilters) (string, []interface{}) {
-	query := "SELECT * FROM users"
+// buildWhere appends the filter conditions to query, which selects from users
+func (r *UserRepository) buildWhere(filters *models.ExportFilters, query string) (string, []interface{}) {
 	args := []interface{}{}
 	conditions := []string{}
 
@@ -285,6 +404,14 @@ func (r *UserRepository) buildSelectQuery(filters *models.ExportFilters) (string
 			conditions = append(conditions, fmt.Sprintf("active = $%d", len(args)+1))
 			args = append(args, *filters.Active)
 		}
+		if filters.Placeholder != nil {
+			conditions = append(conditions, fmt.Sprintf("placeholder = $%d", len(args)+1))
+			args = append(args, *filters.Placeholder)
+		}
+		if filters.ImportedByJob != nil {
+			conditions = append(conditions, fmt.Sprintf("import_job_id = $%d", len(args)+1))
+			args = append(args, *filters.ImportedByJob)
+		}
 		if filters.CreatedAfter != nil {
 			conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)+1))
 			args = append(args, *filters.CreatedAfter)
@@ -293,14 +420,31 @@ func (r *UserRepository) buildSelectQuery(filters *models.ExportFilters) (string
 			conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)+1))
 			args = append(args, *filters.CreatedBefore)
 		}
+		if filters.UpdatedAfter != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at > $%d", len(args)+1))
+			args = append(args, *filters.UpdatedAfter)
+		}
+		if filters.UpdatedBefore != nil {
+			conditions = append(conditions, fmt.Sprintf("updated_at <= $%d", len(args)+1))
+			args = append(args, *filters.UpdatedBefore)
+		}
+		if len(filters.IDs) > 0 {
+			ids := make([]string, len(filters.IDs))
+			for i, id := range filters.IDs {
+				ids[i] = id.String()
+			}
+			conditions = append(conditions, fmt.Sprintf("users.id = ANY($%d::uuid[])", len(args)+1))
+			args = append(args, pq.Array(ids))
+		}
+		if len(filters.Emails) > 0 {
+			conditions = append(conditions, fmt.Sprintf("LOWER(users.email) = ANY($%d)", len(args)+1))
+			args = append(args, pq.Array(filters.Emails))
+		}
 	}
 
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
-
-	query += " ORDER BY created_at ASC"
-
 	return query, args
 }
 
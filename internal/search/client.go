@@ -0,0 +1,205 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+)
+
+// defaultArticleMapping is the index body used when SEARCH_MAPPING_FILE is
+// not set. Field names match the JSON form of models.Article.
+const defaultArticleMapping = `{
+  "mappings": {
+    "properties": {
+      "id":           {"type": "keyword"},
+      "slug":         {"type": "keyword"},
+      "title":        {"type": "text"},
+      "body":         {"type": "text"},
+      "author_id":    {"type": "keyword"},
+      "tags":         {"type": "keyword"},
+      "status":       {"type": "keyword"},
+      "published_at": {"type": "date"},
+      "created_at":   {"type": "date"},
+      "updated_at":   {"type": "date"}
+    }
+  }
+}`
+
+// Document is a record to index under ID
+type Document struct {
+	ID   string
+	Body interface{}
+}
+
+// Client indexes documents into Elasticsearch or OpenSearch. Both accept the
+// index and _bulk requests it makes.
+type Client struct {
+	endpoint *url.URL
+	index    string
+	mapping  []byte
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewClient creates a client for the index in cfg, reading the index body
+// from cfg.MappingFile when it is set
+func NewClient(cfg config.SearchConfig) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid search endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Index == "" {
+		return nil, fmt.Errorf("search indexing requires SEARCH_INDEX")
+	}
+
+	mapping := []byte(defaultArticleMapping)
+	if cfg.MappingFile != "" {
+		mapping, err = os.ReadFile(cfg.MappingFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read search mapping: %w", err)
+		}
+		if !json.Valid(mapping) {
+			return nil, fmt.Errorf("search mapping %s is not valid JSON", cfg.MappingFile)
+		}
+	}
+
+	return &Client{
+		endpoint: u,
+		index:    cfg.Index,
+		mapping:  mapping,
+		username: cfg.Username,
+		password: cfg.Password,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Index returns the name of the index documents are written to
+func (c *Client) Index() string {
+	return c.index
+}
+
+// EnsureIndex creates the index with the configured mapping if it does not
+// exist yet. An existing index is left untouched.
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodHead, "/"+url.PathEscape(c.index), "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("check index %s: unexpected status %d", c.index, resp.StatusCode)
+	}
+
+	resp, err = c.do(ctx, http.MethodPut, "/"+url.PathEscape(c.index), "application/json", bytes.NewReader(c.mapping))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 300 {
+		// Another worker may have created it between the two requests
+		if strings.Contains(string(body), "resource_already_exists_exception") {
+			return nil
+		}
+		return fmt.Errorf("create index %s: unexpected status %d: %s", c.index, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// bulkResponse is the part of a _bulk response needed to find failed items
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// BulkIndex writes docs to the index in one _bulk request, replacing any
+// documents with the same IDs. It returns an error naming the first failed
+// document if any of them were rejected.
+func (c *Client) BulkIndex(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, doc := range docs {
+		action := map[string]map[string]string{"index": {"_index": c.index, "_id": doc.ID}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc.Body); err != nil {
+			return fmt.Errorf("encode document %s: %w", doc.ID, err)
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("bulk index: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("bulk index: invalid response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	failed := 0
+	first := ""
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Error == nil {
+				continue
+			}
+			failed++
+			if first == "" {
+				first = fmt.Sprintf("%s: %s: %s", r.ID, r.Error.Type, r.Error.Reason)
+			}
+		}
+	}
+	return fmt.Errorf("bulk index: %d of %d documents failed, first %s", failed, len(docs), first)
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(config.SearchConfig{Endpoint: srv.URL, Index: "articles", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	return client
+}
+
+func TestClient_EnsureIndexCreatesMissingIndex(t *testing.T) {
+	var created string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			created = r.URL.Path + " " + string(body)
+			w.Write([]byte(`{"acknowledged":true}`))
+		}
+	})
+
+	if err := client.EnsureIndex(context.Background()); err != nil {
+		t.Fatalf("EnsureIndex() error: %v", err)
+	}
+	if !strings.HasPrefix(created, "/articles ") || !strings.Contains(created, `"mappings"`) {
+		t.Errorf("EnsureIndex() sent %q, want the default mapping to /articles", created)
+	}
+}
+
+func TestClient_EnsureIndexKeepsExistingIndex(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+	})
+
+	if err := client.EnsureIndex(context.Background()); err != nil {
+		t.Fatalf("EnsureIndex() error: %v", err)
+	}
+}
+
+func TestClient_BulkIndex(t *testing.T) {
+	var lines []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	})
+
+	docs := []Document{
+		{ID: "a1", Body: map[string]string{"title": "First"}},
+		{ID: "a2", Body: map[string]string{"title": "Second"}},
+	}
+	if err := client.BulkIndex(context.Background(), docs); err != nil {
+		t.Fatalf("BulkIndex() error: %v", err)
+	}
+
+	if len(lines) != 4 {
+		t.Fatalf("BulkIndex() sent %d lines, want 4", len(lines))
+	}
+	var action map[string]map[string]string
+	if err := json.Unmarshal([]byte(lines[2]), &action); err != nil {
+		t.Fatalf("invalid action line %q: %v", lines[2], err)
+	}
+	if action["index"]["_id"] != "a2" || action["index"]["_index"] != "articles" {
+		t.Errorf("action = %v, want index a2 into articles", action)
+	}
+	if lines[3] != `{"title":"Second"}` {
+		t.Errorf("document line = %q", lines[3])
+	}
+}
+
+func TestClient_BulkIndexReportsItemErrors(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":true,"items":[
+			{"index":{"_id":"a1","status":201}},
+			{"index":{"_id":"a2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [published_at]"}}}
+		]}`))
+	})
+
+	err := client.BulkIndex(context.Background(), []Document{{ID: "a1", Body: 1}, {ID: "a2", Body: 2}})
+	if err == nil {
+		t.Fatal("BulkIndex() expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "1 of 2") || !strings.Contains(err.Error(), "a2: mapper_parsing_exception") {
+		t.Errorf("BulkIndex() error = %v", err)
+	}
+}
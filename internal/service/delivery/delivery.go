@@ -0,0 +1,273 @@
+// Package deliveryservice queues the notifications the service sends, such
+// as alert webhooks, and retries the ones that fail with exponential backoff
+// until they are delivered or dead-lettered. Every channel shares the queue.
+package deliveryservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	pkglogger "github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Attempt outcomes, as counted in the delivery_attempts_total metric
+const (
+	OutcomeDelivered  = "delivered"
+	OutcomeRetry      = "retry"
+	OutcomeDeadLetter = "dead_letter"
+)
+
+// claimGrace is how long past the time to send its whole batch a claimed
+// delivery is leased, so an instance that dies while sending leaves it to be
+// claimed again
+const claimGrace = time.Minute
+
+// Channel sends a delivery to its target, such as a webhook URL. An error
+// fails the attempt, which is retried with backoff.
+type Channel interface {
+	Send(ctx context.Context, delivery *models.Delivery) error
+}
+
+// Service queues deliveries and sends them through their channels
+type Service struct {
+	repo    repository.DeliveryRepository
+	metrics *metrics.Collector
+	logger  zerolog.Logger
+	// hot samples the per-attempt failures so an outage of a target
+	// doesn't flood the logs
+	hot    zerolog.Logger
+	config config.DeliveryConfig
+
+	mu       sync.RWMutex
+	channels map[string]Channel
+
+	// wake asks Run to send a newly queued delivery without waiting for the
+	// next poll
+	wake chan struct{}
+	// now returns the current time; tests may replace it
+	now func() time.Time
+}
+
+// NewService creates a new delivery service
+func NewService(
+	repo repository.DeliveryRepository,
+	metricsCollector *metrics.Collector,
+	logger zerolog.Logger,
+	cfg config.DeliveryConfig,
+) *Service {
+	return &Service{
+		repo:     repo,
+		metrics:  metricsCollector,
+		logger:   logger,
+		hot:      pkglogger.Hot(logger),
+		config:   cfg,
+		channels: make(map[string]Channel),
+		wake:     make(chan struct{}, 1),
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// RegisterChannel makes channel available to deliveries queued for name
+func (s *Service) RegisterChannel(name string, channel Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[name] = channel
+}
+
+func (s *Service) channel(name string) (Channel, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	channel, ok := s.channels[name]
+	return channel, ok
+}
+
+// Enqueue queues event for channel to send payload, encoded as JSON, to
+// target. It is sent by the next run of the queue on any instance.
+func (s *Service) Enqueue(ctx context.Context, channel, target, event string, payload any) (*models.Delivery, error) {
+	if _, ok := s.channel(channel); !ok {
+		return nil, fmt.Errorf("unknown delivery channel: %s", channel)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", event, err)
+	}
+	delivery := &models.Delivery{
+		ID:          uuid.New(),
+		Channel:     channel,
+		Target:      target,
+		Event:       event,
+		Payload:     data,
+		Status:      models.DeliveryPending,
+		MaxAttempts: s.config.MaxAttempts,
+		CreatedAt:   s.now(),
+	}
+	if err := s.repo.Create(ctx, delivery); err != nil {
+		return nil, fmt.Errorf("failed to queue %s delivery: %w", event, err)
+	}
+
+	s.wakeUp()
+	return delivery, nil
+}
+
+// DeliverDue sends the deliveries due by now, a batch at a time, and
+// returns how many it attempted. A batch is sent one delivery after another,
+// so it is leased for every delivery's timeout; otherwise the last ones
+// could be claimed again by another instance while they wait their turn.
+func (s *Service) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	lease := time.Duration(s.config.BatchSize)*s.config.Timeout + claimGrace
+	for {
+		now := s.now()
+		due, err := s.repo.ClaimDue(ctx, now, now.Add(lease), s.config.BatchSize)
+		if err != nil {
+			return attempted, fmt.Errorf("failed to claim due deliveries: %w", err)
+		}
+		for _, delivery := range due {
+			s.attempt(ctx, delivery)
+		}
+		attempted += len(due)
+		if len(due) < s.config.BatchSize || ctx.Err() != nil {
+			return attempted, nil
+		}
+	}
+}
+
+// attempt sends delivery once and records the outcome: delivered, retried
+// after a backoff or, once its attempts run out, dead-lettered. The outcome
+// is only recorded while the claim's lease, the delivery's next_attempt_at
+// as claimed, still holds.
+func (s *Service) attempt(ctx context.Context, delivery *models.Delivery) {
+	attempts := delivery.Attempts + 1
+	lease := delivery.NextAttemptAt
+	log := s.logger.With().
+		Str("delivery_id", delivery.ID.String()).
+		Str("channel", delivery.Channel).
+		Str("event", delivery.Event).
+		Int("attempt", attempts).
+		Logger()
+
+	start := time.Now()
+	err := fmt.Errorf("unknown delivery channel: %s", delivery.Channel)
+	if channel, ok := s.channel(delivery.Channel); ok {
+		sendCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+		err = channel.Send(sendCtx, delivery)
+		cancel()
+	}
+	duration := time.Since(start).Seconds()
+
+	// The outcome is stored even when ctx ends during the attempt
+	storeCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		now := s.now()
+		if ok, err := s.repo.MarkDelivered(storeCtx, delivery.ID, lease, attempts, now); err != nil {
+			log.Error().Err(err).Msg("Failed to record delivery")
+		} else if !ok {
+			log.Warn().Msg("Delivery lease expired before it was recorded")
+		}
+		s.record(delivery.Channel, OutcomeDelivered, duration)
+		if s.metrics != nil {
+			s.metrics.RecordDeliveryLatency(delivery.Channel, now.Sub(delivery.CreatedAt).Seconds())
+		}
+		return
+	}
+
+	if attempts >= delivery.MaxAttempts {
+		if ok, err := s.repo.MarkFailed(storeCtx, delivery.ID, lease, attempts, err.Error(), nil); err != nil {
+			log.Error().Err(err).Msg("Failed to dead-letter delivery")
+		} else if !ok {
+			log.Warn().Msg("Delivery lease expired before it was dead-lettered")
+		}
+		s.record(delivery.Channel, OutcomeDeadLetter, duration)
+		log.Error().Err(err).Msg("Delivery dead-lettered after its last attempt")
+		return
+	}
+
+	next := s.now().Add(s.Backoff(attempts))
+	if ok, err := s.repo.MarkFailed(storeCtx, delivery.ID, lease, attempts, err.Error(), &next); err != nil {
+		log.Error().Err(err).Msg("Failed to record delivery attempt")
+	} else if !ok {
+		log.Warn().Msg("Delivery lease expired before its attempt was recorded")
+	}
+	s.record(delivery.Channel, OutcomeRetry, duration)
+	s.hot.Warn().Err(err).
+		Str("delivery_id", delivery.ID.String()).
+		Str("channel", delivery.Channel).
+		Int("attempt", attempts).
+		Time("next_attempt_at", next).
+		Msg("Delivery failed; will retry")
+}
+
+// wakeUp asks Run to send due deliveries now, unless it has been asked
+// already
+func (s *Service) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) record(channel, outcome string, duration float64) {
+	if s.metrics != nil {
+		s.metrics.RecordDeliveryAttempt(channel, outcome, duration)
+	}
+}
+
+// Backoff returns the wait after a delivery's attempts-th failed attempt:
+// the base wait, doubled for each failure before it, up to the maximum
+func (s *Service) Backoff(attempts int) time.Duration {
+	wait := s.config.BackoffBase
+	for i := 1; i < attempts && wait < s.config.BackoffMax; i++ {
+		wait *= 2
+	}
+	return min(wait, s.config.BackoffMax)
+}
+
+// Run sends due deliveries now, then every poll interval and whenever a
+// delivery is queued on this instance, until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Delivery run failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// List returns a page of the deliveries in status, newest first, and their
+// total count
+func (s *Service) List(ctx context.Context, status models.DeliveryStatus, page, perPage int) ([]*models.Delivery, int64, error) {
+	return s.repo.ListByStatus(ctx, status, page, perPage)
+}
+
+// Get returns the delivery with id, or nil if there is none
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*models.Delivery, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Requeue gives a dead-lettered delivery a fresh set of attempts, starting
+// now. It returns false when the delivery isn't dead-lettered.
+func (s *Service) Requeue(ctx context.Context, id uuid.UUID) (bool, error) {
+	ok, err := s.repo.Requeue(ctx, id, s.now())
+	if err != nil || !ok {
+		return ok, err
+	}
+	s.wakeUp()
+	return true, nil
+}
@@ -0,0 +1,249 @@
+package deliveryservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/events"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	signingservice "github.com/rohit/bulk-import-export/internal/service/signing"
+	"github.com/rohit/bulk-import-export/pkg/signing"
+	"github.com/rs/zerolog"
+)
+
+func TestService_RetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewDeliveryRepository(memory.NewDB())
+	svc := NewService(repo, nil, zerolog.Nop(), config.DeliveryConfig{
+		MaxAttempts: 3,
+		BackoffBase: time.Minute,
+		BackoffMax:  90 * time.Second,
+		BatchSize:   1,
+		Timeout:     time.Second,
+	})
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	// The webhook fails until it is told to accept
+	var mu sync.Mutex
+	accept := false
+	var received []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		if !accept {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	svc.RegisterChannel(events.ChannelWebhook, events.NewWebhook(nil))
+
+	if _, err := svc.Enqueue(ctx, "pager", "", "test", nil); err == nil {
+		t.Error("Enqueue() to an unregistered channel succeeded, want an error")
+	}
+	job := &models.Job{ID: uuid.New(), Resource: models.ResourceTypeUsers, TenantID: "acme"}
+	events.NewAlertWebhook(server.URL, svc, zerolog.Nop()).OnJobPaused(ctx, job, fmt.Errorf("database is down"))
+	queued, _, _ := repo.ListByStatus(ctx, models.DeliveryPending, 1, 10)
+	if len(queued) != 1 {
+		t.Fatalf("queued = %d deliveries, want the alert", len(queued))
+	}
+	delivery := queued[0]
+
+	// deliver runs the queue and returns the stored delivery
+	deliver := func(wantAttempted int) *models.Delivery {
+		t.Helper()
+		attempted, err := svc.DeliverDue(ctx)
+		if err != nil || attempted != wantAttempted {
+			t.Fatalf("DeliverDue() = %d, %v; want %d attempted", attempted, err, wantAttempted)
+		}
+		stored, _ := repo.GetByID(ctx, delivery.ID)
+		return stored
+	}
+
+	// Failures back off from the base wait, doubling up to the maximum
+	stored := deliver(1)
+	if stored.Status != models.DeliveryPending || stored.Attempts != 1 || !stored.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("after one failure = %+v, want a retry in a minute", stored)
+	}
+	if stored.LastError == nil || *stored.LastError != "webhook returned 503 Service Unavailable" {
+		t.Errorf("last_error = %v", stored.LastError)
+	}
+	deliver(0)
+	now = now.Add(time.Minute)
+	if stored = deliver(1); !stored.NextAttemptAt.Equal(now.Add(90 * time.Second)) {
+		t.Errorf("second retry at %v, want the 90s maximum", stored.NextAttemptAt)
+	}
+	now = now.Add(90 * time.Second)
+	if stored = deliver(1); stored.Status != models.DeliveryDeadLetter || stored.Attempts != 3 {
+		t.Errorf("after the last attempt = %+v, want it dead-lettered", stored)
+	}
+	deliver(0)
+
+	// A requeued delivery starts its attempts again
+	mu.Lock()
+	accept = true
+	mu.Unlock()
+	if ok, err := svc.Requeue(ctx, delivery.ID); !ok || err != nil {
+		t.Fatalf("Requeue() = %v, %v", ok, err)
+	}
+	if ok, _ := svc.Requeue(ctx, delivery.ID); ok {
+		t.Error("Requeue() of a pending delivery succeeded")
+	}
+	if stored = deliver(1); stored.Status != models.DeliveryDelivered || stored.Attempts != 1 || stored.DeliveredAt == nil {
+		t.Errorf("after requeue = %+v, want it delivered at the first attempt", stored)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 4 {
+		t.Fatalf("webhook got %d requests, want 4", len(received))
+	}
+	last := received[3]
+	if last.Header.Get("X-Event") != events.AlertImportPaused || last.Header.Get("X-Delivery-ID") != delivery.ID.String() {
+		t.Errorf("headers = %v", last.Header)
+	}
+	if last.Header.Get(signing.Header) != "" {
+		t.Errorf("webhook without a signer was signed: %v", last.Header)
+	}
+	var alert events.Alert
+	if err := json.Unmarshal(stored.Payload, &alert); err != nil || alert.JobID != job.ID || alert.Error != "database is down" {
+		t.Errorf("payload = %s, %v", stored.Payload, err)
+	}
+}
+
+func TestService_SignsWebhooks(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	signer := signingservice.NewService(memory.NewSigningKeyRepository(db), zerolog.Nop(), config.SigningConfig{Enabled: true, KeyRefresh: time.Hour})
+	if err := signer.Init(ctx); err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+	keyID, _ := signer.ActiveKeyID(ctx)
+	key, _ := signer.Key(ctx, keyID)
+
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	repo := memory.NewDeliveryRepository(db)
+	svc := NewService(repo, nil, zerolog.Nop(), config.DeliveryConfig{MaxAttempts: 1, BatchSize: 1, Timeout: time.Second})
+	svc.RegisterChannel(events.ChannelWebhook, events.NewWebhook(signer))
+	delivery, err := svc.Enqueue(ctx, events.ChannelWebhook, server.URL, "test", map[string]string{"hello": "world"})
+	if err != nil {
+		t.Fatalf("Enqueue() error: %v", err)
+	}
+	if attempted, err := svc.DeliverDue(ctx); attempted != 1 || err != nil {
+		t.Fatalf("DeliverDue() = %d, %v", attempted, err)
+	}
+
+	// The receiver checks the body it got against the named key's secret
+	if got := header.Get(signing.KeyIDHeader); got != keyID {
+		t.Errorf("%s = %q, want %q", signing.KeyIDHeader, got, keyID)
+	}
+	if header.Get("X-Delivery-ID") != delivery.ID.String() {
+		t.Errorf("X-Delivery-ID = %q, want %s", header.Get("X-Delivery-ID"), delivery.ID)
+	}
+	secrets := map[string][]byte{keyID: key.Secret}
+	if err := signing.Verify(header.Get(signing.Header), body, secrets, time.Minute, time.Now()); err != nil {
+		t.Errorf("Verify() error: %v", err)
+	}
+	if err := signing.Verify(header.Get(signing.Header), append(body, ' '), secrets, time.Minute, time.Now()); err == nil {
+		t.Error("Verify() accepted a changed body")
+	}
+}
+
+func TestService_Backoff(t *testing.T) {
+	svc := NewService(nil, nil, zerolog.Nop(), config.DeliveryConfig{BackoffBase: 30 * time.Second, BackoffMax: time.Hour})
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		8:  time.Hour,
+		60: time.Hour,
+	} {
+		if got := svc.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+// slowChannel takes the whole timeout to send each delivery, advancing the
+// clock the services share, and calls during while the send is in progress
+type slowChannel struct {
+	now     *time.Time
+	timeout time.Duration
+	during  func()
+	sent    []uuid.UUID
+}
+
+func (c *slowChannel) Send(ctx context.Context, delivery *models.Delivery) error {
+	*c.now = c.now.Add(c.timeout)
+	c.sent = append(c.sent, delivery.ID)
+	c.during()
+	return nil
+}
+
+func TestService_LeasesWholeBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewDeliveryRepository(memory.NewDB())
+	cfg := config.DeliveryConfig{MaxAttempts: 3, BatchSize: 3, Timeout: time.Minute}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	// Two instances share the queue; the second polls during every send of
+	// the first's batch, which takes three timeouts, longer than one timeout
+	// plus the grace
+	first := NewService(repo, nil, zerolog.Nop(), cfg)
+	second := NewService(repo, nil, zerolog.Nop(), cfg)
+	first.now, second.now = clock, clock
+	var reclaimed int
+	channel := &slowChannel{now: &now, timeout: cfg.Timeout, during: func() {
+		attempted, err := second.DeliverDue(ctx)
+		if err != nil {
+			t.Errorf("DeliverDue() error: %v", err)
+		}
+		reclaimed += attempted
+	}}
+	first.RegisterChannel("slow", channel)
+	second.RegisterChannel("slow", channel)
+
+	for i := 0; i < cfg.BatchSize; i++ {
+		if _, err := first.Enqueue(ctx, "slow", "", "test", nil); err != nil {
+			t.Fatalf("Enqueue() error: %v", err)
+		}
+	}
+	if attempted, err := first.DeliverDue(ctx); attempted != cfg.BatchSize || err != nil {
+		t.Fatalf("DeliverDue() = %d, %v; want the whole batch", attempted, err)
+	}
+	if reclaimed != 0 || len(channel.sent) != cfg.BatchSize {
+		t.Errorf("reclaimed %d deliveries and sent %d, want none reclaimed and each sent once", reclaimed, len(channel.sent))
+	}
+	delivered, _, _ := repo.ListByStatus(ctx, models.DeliveryDelivered, 1, 10)
+	if len(delivered) != cfg.BatchSize {
+		t.Fatalf("delivered = %d, want %d", len(delivered), cfg.BatchSize)
+	}
+
+	// An outcome recorded under a lease that no longer holds is dropped
+	ok, err := repo.MarkFailed(ctx, delivered[0].ID, now, 2, "late", nil)
+	if ok || err != nil {
+		t.Errorf("MarkFailed() with a lost lease = %v, %v; want false", ok, err)
+	}
+	if stored, _ := repo.GetByID(ctx, delivered[0].ID); stored.Status != models.DeliveryDelivered {
+		t.Errorf("status = %s after a late outcome, want delivered", stored.Status)
+	}
+}
@@ -0,0 +1,121 @@
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// The append functions write Avro binary encoding. Avro longs are zig-zag
+// varints, which is what binary.AppendVarint produces.
+
+func appendLong(buf []byte, v int64) []byte {
+	return binary.AppendVarint(buf, v)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendLong(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBool(buf []byte, v bool) []byte {
+	if v {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+func appendUUID(buf []byte, id uuid.UUID) []byte {
+	return appendString(buf, id.String())
+}
+
+func appendTime(buf []byte, t time.Time) []byte {
+	return appendLong(buf, t.UnixMicro())
+}
+
+// appendOptionalString writes a ["null", "string"] union
+func appendOptionalString(buf []byte, s *string) []byte {
+	if s == nil {
+		return appendLong(buf, 0)
+	}
+	return appendString(appendLong(buf, 1), *s)
+}
+
+// appendOptionalUUID writes a ["null", uuid] union
+func appendOptionalUUID(buf []byte, id *uuid.UUID) []byte {
+	if id == nil {
+		return appendLong(buf, 0)
+	}
+	return appendUUID(appendLong(buf, 1), *id)
+}
+
+// appendOptionalTime writes a ["null", timestamp-micros] union
+func appendOptionalTime(buf []byte, t *time.Time) []byte {
+	if t == nil {
+		return appendLong(buf, 0)
+	}
+	return appendTime(appendLong(buf, 1), *t)
+}
+
+// appendStrings writes an array of strings as a single block
+func appendStrings(buf []byte, items []string) []byte {
+	if len(items) > 0 {
+		buf = appendLong(buf, int64(len(items)))
+		for _, item := range items {
+			buf = appendString(buf, item)
+		}
+	}
+	return appendLong(buf, 0)
+}
+
+// AppendUser appends user encoded with the users schema
+func AppendUser(buf []byte, user *models.User) []byte {
+	buf = appendUUID(buf, user.ID)
+	buf = appendString(buf, user.Email)
+	buf = appendString(buf, user.Name)
+	buf = appendString(buf, user.Role)
+	buf = appendBool(buf, user.Active)
+	buf = appendBool(buf, user.Placeholder)
+	buf = appendOptionalUUID(buf, user.ImportJobID)
+	buf = appendTime(buf, user.CreatedAt)
+	return appendTime(buf, user.UpdatedAt)
+}
+
+// AppendArticle appends article encoded with the articles schema. Tags that
+// aren't a JSON array of strings are written as an empty array.
+func AppendArticle(buf []byte, article *models.Article) []byte {
+	var tags []string
+	if len(article.Tags) > 0 {
+		if err := json.Unmarshal(article.Tags, &tags); err != nil {
+			tags = nil
+		}
+	}
+
+	buf = appendUUID(buf, article.ID)
+	buf = appendString(buf, article.Slug)
+	buf = appendString(buf, article.Title)
+	buf = appendString(buf, article.Body)
+	buf = appendUUID(buf, article.AuthorID)
+	buf = appendStrings(buf, tags)
+	buf = appendOptionalTime(buf, article.PublishedAt)
+	buf = appendString(buf, article.Status)
+	buf = appendOptionalString(buf, article.Lang)
+	buf = appendOptionalUUID(buf, article.ImportJobID)
+	buf = appendTime(buf, article.CreatedAt)
+	return appendTime(buf, article.UpdatedAt)
+}
+
+// AppendComment appends comment encoded with the comments schema
+func AppendComment(buf []byte, comment *models.Comment) []byte {
+	buf = appendUUID(buf, comment.ID)
+	buf = appendUUID(buf, comment.ArticleID)
+	buf = appendUUID(buf, comment.UserID)
+	buf = appendString(buf, comment.Body)
+	buf = appendOptionalString(buf, comment.Lang)
+	buf = appendOptionalUUID(buf, comment.ImportJobID)
+	buf = appendTime(buf, comment.CreatedAt)
+	return appendTime(buf, comment.UpdatedAt)
+}
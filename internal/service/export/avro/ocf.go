@@ -0,0 +1,121 @@
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// Codecs for the blocks of an object container file
+const (
+	CodecNull    = "null"
+	CodecDeflate = "deflate"
+)
+
+// defaultBlockRecords is how many records a block holds before it is written
+const defaultBlockRecords = 1000
+
+var magic = []byte{'O', 'b', 'j', 1}
+
+// Writer writes an Avro object container file: a header carrying the schema,
+// then blocks of records, each followed by the file's sync marker
+type Writer struct {
+	w        io.Writer
+	codec    string
+	sync     [16]byte
+	block    []byte
+	count    int
+	maxCount int
+	deflated bytes.Buffer
+	fw       *flate.Writer
+}
+
+// NewWriter writes the container header for schema to w. Records are
+// collected into blocks of blockRecords (0 for the default), compressed with
+// codec.
+func NewWriter(w io.Writer, schema, codec string, blockRecords int) (*Writer, error) {
+	if codec == "" {
+		codec = CodecNull
+	}
+	if codec != CodecNull && codec != CodecDeflate {
+		return nil, fmt.Errorf("unsupported avro codec %q, expected null or deflate", codec)
+	}
+	if blockRecords <= 0 {
+		blockRecords = defaultBlockRecords
+	}
+
+	aw := &Writer{w: w, codec: codec, maxCount: blockRecords}
+	if _, err := rand.Read(aw.sync[:]); err != nil {
+		return nil, err
+	}
+	if codec == CodecDeflate {
+		fw, err := flate.NewWriter(&aw.deflated, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		aw.fw = fw
+	}
+
+	// File metadata is a map of string to bytes, written as one block
+	header := append([]byte(nil), magic...)
+	header = appendLong(header, 2)
+	header = appendString(header, "avro.schema")
+	header = appendString(header, schema)
+	header = appendString(header, "avro.codec")
+	header = appendString(header, codec)
+	header = appendLong(header, 0)
+	header = append(header, aw.sync[:]...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// Append adds a record encoded by appendRecord, such as AppendUser, writing
+// the block once it is full
+func (aw *Writer) Append(appendRecord func([]byte) []byte) error {
+	aw.block = appendRecord(aw.block)
+	aw.count++
+	if aw.count >= aw.maxCount {
+		return aw.Flush()
+	}
+	return nil
+}
+
+// Flush writes the records collected so far as a block
+func (aw *Writer) Flush() error {
+	if aw.count == 0 {
+		return nil
+	}
+
+	data := aw.block
+	if aw.fw != nil {
+		aw.deflated.Reset()
+		aw.fw.Reset(&aw.deflated)
+		if _, err := aw.fw.Write(aw.block); err != nil {
+			return err
+		}
+		if err := aw.fw.Close(); err != nil {
+			return err
+		}
+		data = aw.deflated.Bytes()
+	}
+
+	prefix := appendLong(nil, int64(aw.count))
+	prefix = appendLong(prefix, int64(len(data)))
+	for _, part := range [][]byte{prefix, data, aw.sync[:]} {
+		if _, err := aw.w.Write(part); err != nil {
+			return err
+		}
+	}
+	aw.block = aw.block[:0]
+	aw.count = 0
+	return nil
+}
+
+// Close writes the last block. It doesn't close the underlying writer.
+func (aw *Writer) Close() error {
+	return aw.Flush()
+}
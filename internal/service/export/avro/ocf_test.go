@@ -0,0 +1,185 @@
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// decoder reads the Avro binary encoding back for the tests
+type decoder struct {
+	t *testing.T
+	r *bytes.Reader
+}
+
+func (d *decoder) long() int64 {
+	d.t.Helper()
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.t.Fatalf("read long: %v", err)
+	}
+	return v
+}
+
+func (d *decoder) bytes(n int) []byte {
+	d.t.Helper()
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.t.Fatalf("read %d bytes: %v", n, err)
+	}
+	return buf
+}
+
+func (d *decoder) string() string {
+	d.t.Helper()
+	return string(d.bytes(int(d.long())))
+}
+
+func TestSchema_ValidJSON(t *testing.T) {
+	for _, resource := range []models.ResourceType{models.ResourceTypeUsers, models.ResourceTypeArticles, models.ResourceTypeComments} {
+		schema, err := Schema(resource)
+		if err != nil {
+			t.Fatalf("Schema(%s) error: %v", resource, err)
+		}
+		var parsed struct {
+			Type      string `json:"type"`
+			Namespace string `json:"namespace"`
+		}
+		if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+			t.Fatalf("Schema(%s) is not JSON: %v", resource, err)
+		}
+		if parsed.Type != "record" || parsed.Namespace != Namespace {
+			t.Errorf("Schema(%s) = %+v, want a record in %s", resource, parsed, Namespace)
+		}
+	}
+	if _, err := Schema("widgets"); err == nil {
+		t.Error("Schema(widgets) succeeded, want an error")
+	}
+}
+
+func TestWriter_ContainerFile(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	users := []*models.User{
+		{ID: uuid.New(), Email: "a@example.com", Name: "Ada", Role: "admin", Active: true, CreatedAt: created, UpdatedAt: created},
+		{ID: uuid.New(), Email: "b@example.com", Name: "Bob", Role: "reader", CreatedAt: created, UpdatedAt: created},
+		{ID: uuid.New(), Email: "c@example.com", Name: "Cy", Role: "reader", CreatedAt: created, UpdatedAt: created},
+	}
+
+	for _, codec := range []string{CodecNull, CodecDeflate} {
+		t.Run(codec, func(t *testing.T) {
+			schema, _ := Schema(models.ResourceTypeUsers)
+			var out bytes.Buffer
+			aw, err := NewWriter(&out, schema, codec, 2)
+			if err != nil {
+				t.Fatalf("NewWriter() error: %v", err)
+			}
+			for _, user := range users {
+				if err := aw.Append(func(buf []byte) []byte { return AppendUser(buf, user) }); err != nil {
+					t.Fatalf("Append() error: %v", err)
+				}
+			}
+			if err := aw.Close(); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+
+			d := &decoder{t: t, r: bytes.NewReader(out.Bytes())}
+			if got := d.bytes(4); !bytes.Equal(got, magic) {
+				t.Fatalf("magic = %q, want %q", got, magic)
+			}
+			meta := map[string]string{}
+			for n := d.long(); n != 0; n = d.long() {
+				for i := int64(0); i < n; i++ {
+					meta[d.string()] = d.string()
+				}
+			}
+			if meta["avro.schema"] != schema || meta["avro.codec"] != codec {
+				t.Fatalf("metadata = %v, want the users schema and codec %s", meta, codec)
+			}
+			sync := d.bytes(16)
+
+			// Blocks of 2 and 1 records
+			var records []byte
+			var counts []int64
+			for d.r.Len() > 0 {
+				counts = append(counts, d.long())
+				data := d.bytes(int(d.long()))
+				if codec == CodecDeflate {
+					if data, err = io.ReadAll(flate.NewReader(bytes.NewReader(data))); err != nil {
+						t.Fatalf("inflate block: %v", err)
+					}
+				}
+				records = append(records, data...)
+				if got := d.bytes(16); !bytes.Equal(got, sync) {
+					t.Fatal("block isn't followed by the sync marker")
+				}
+			}
+			if len(counts) != 2 || counts[0] != 2 || counts[1] != 1 {
+				t.Fatalf("block counts = %v, want [2 1]", counts)
+			}
+
+			rd := &decoder{t: t, r: bytes.NewReader(records)}
+			if id := rd.string(); id != users[0].ID.String() {
+				t.Errorf("id = %q, want %q", id, users[0].ID)
+			}
+			if email, name, role := rd.string(), rd.string(), rd.string(); email != "a@example.com" || name != "Ada" || role != "admin" {
+				t.Errorf("fields = %q %q %q, want a@example.com Ada admin", email, name, role)
+			}
+			if active := rd.bytes(1)[0]; active != 1 {
+				t.Errorf("active = %d, want 1", active)
+			}
+			if placeholder := rd.bytes(1)[0]; placeholder != 0 {
+				t.Errorf("placeholder = %d, want 0", placeholder)
+			}
+			if branch := rd.long(); branch != 0 {
+				t.Errorf("import_job_id = branch %d, want null", branch)
+			}
+			if micros := rd.long(); micros != created.UnixMicro() {
+				t.Errorf("created_at = %d, want %d", micros, created.UnixMicro())
+			}
+		})
+	}
+}
+
+func TestNewWriter_RejectsUnknownCodec(t *testing.T) {
+	if _, err := NewWriter(io.Discard, "{}", "snappy", 0); err == nil {
+		t.Error("NewWriter(snappy) succeeded, want an error")
+	}
+}
+
+func TestAppendArticle_OptionalFields(t *testing.T) {
+	lang := "en"
+	published := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	article := &models.Article{
+		ID: uuid.New(), Slug: "s", Title: "T", Body: "B", AuthorID: uuid.New(),
+		Tags: json.RawMessage(`["go","avro"]`), PublishedAt: &published, Status: "published", Lang: &lang,
+	}
+
+	d := &decoder{t: t, r: bytes.NewReader(AppendArticle(nil, article))}
+	for range 4 {
+		d.string()
+	}
+	d.string() // author_id
+	var tags []string
+	for n := d.long(); n != 0; n = d.long() {
+		for i := int64(0); i < n; i++ {
+			tags = append(tags, d.string())
+		}
+	}
+	if len(tags) != 2 || tags[0] != "go" || tags[1] != "avro" {
+		t.Errorf("tags = %v, want [go avro]", tags)
+	}
+	if branch, micros := d.long(), d.long(); branch != 1 || micros != published.UnixMicro() {
+		t.Errorf("published_at = branch %d, %d; want branch 1, %d", branch, micros, published.UnixMicro())
+	}
+	d.string() // status
+	if branch, got := d.long(), d.string(); branch != 1 || got != "en" {
+		t.Errorf("lang = branch %d, %q; want branch 1, en", branch, got)
+	}
+}
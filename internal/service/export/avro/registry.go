@@ -0,0 +1,93 @@
+package avro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// registryContentType is the media type of schema registry requests
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// Registry registers schemas with a Confluent-compatible schema registry
+type Registry struct {
+	endpoint      *url.URL
+	subjectPrefix string
+	username      string
+	password      string
+	client        *http.Client
+}
+
+// NewRegistry creates a client for the registry at endpoint. Schemas are
+// registered under subjectPrefix + resource + "-value", the subject Kafka
+// serializers use for record values.
+func NewRegistry(endpoint, subjectPrefix, username, password string, timeout time.Duration) (*Registry, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid schema registry URL: %q", endpoint)
+	}
+	return &Registry{
+		endpoint:      u,
+		subjectPrefix: subjectPrefix,
+		username:      username,
+		password:      password,
+		client:        &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Subject returns the subject resource's schema is registered under
+func (r *Registry) Subject(resource models.ResourceType) string {
+	return r.subjectPrefix + string(resource) + "-value"
+}
+
+// Register registers resource's schema, returning the subject and the
+// registry's schema ID. Registering an unchanged schema again returns the
+// existing ID.
+func (r *Registry) Register(ctx context.Context, resource models.ResourceType) (string, int, error) {
+	schema, err := Schema(resource)
+	if err != nil {
+		return "", 0, err
+	}
+	subject := r.Subject(resource)
+
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return "", 0, err
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", registryContentType)
+	req.Header.Set("Accept", registryContentType)
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("register schema %s: %w", subject, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", 0, fmt.Errorf("register schema %s: unexpected status %d: %s", subject, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("register schema %s: invalid response: %w", subject, err)
+	}
+	return subject, result.ID, nil
+}
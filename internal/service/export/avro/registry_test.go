@@ -0,0 +1,66 @@
+package avro
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	var gotPath, gotSchema, gotUser string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUser, _, _ = r.BasicAuth()
+		var body struct {
+			Schema string `json:"schema"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		gotSchema = body.Schema
+		w.Header().Set("Content-Type", registryContentType)
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer srv.Close()
+
+	registry, err := NewRegistry(srv.URL+"/", "bulk-export-", "svc", "secret", time.Second)
+	if err != nil {
+		t.Fatalf("NewRegistry() error: %v", err)
+	}
+	subject, id, err := registry.Register(context.Background(), models.ResourceTypeArticles)
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	schema, _ := Schema(models.ResourceTypeArticles)
+	if subject != "bulk-export-articles-value" || id != 42 {
+		t.Errorf("Register() = %q, %d; want bulk-export-articles-value, 42", subject, id)
+	}
+	if gotPath != "/subjects/bulk-export-articles-value/versions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotSchema != schema || gotUser != "svc" {
+		t.Errorf("request schema or auth user not sent (user %q)", gotUser)
+	}
+}
+
+func TestRegistry_RegisterRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error_code":409,"message":"incompatible schema"}`, http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	registry, _ := NewRegistry(srv.URL, "", "", "", time.Second)
+	if _, _, err := registry.Register(context.Background(), models.ResourceTypeUsers); err == nil {
+		t.Error("Register() succeeded on a 409, want an error")
+	}
+}
+
+func TestNewRegistry_InvalidURL(t *testing.T) {
+	if _, err := NewRegistry("not a url", "", "", "", time.Second); err == nil {
+		t.Error("NewRegistry() accepted an invalid URL")
+	}
+}
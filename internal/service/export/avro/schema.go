@@ -0,0 +1,83 @@
+// Package avro writes exported records as Avro object container files and
+// registers their schemas with a Confluent-compatible schema registry
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// Namespace is the Avro namespace of the export record schemas
+const Namespace = "com.bulkimportexport"
+
+// The record schemas follow the JSON exports field for field. IDs are uuid
+// strings and times are timestamp-micros longs.
+var schemas = map[models.ResourceType]string{
+	models.ResourceTypeUsers: `{
+		"type": "record", "name": "User", "namespace": "com.bulkimportexport",
+		"fields": [
+			{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+			{"name": "email", "type": "string"},
+			{"name": "name", "type": "string"},
+			{"name": "role", "type": "string"},
+			{"name": "active", "type": "boolean"},
+			{"name": "placeholder", "type": "boolean", "default": false},
+			{"name": "import_job_id", "type": ["null", {"type": "string", "logicalType": "uuid"}], "default": null},
+			{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+			{"name": "updated_at", "type": {"type": "long", "logicalType": "timestamp-micros"}}
+		]
+	}`,
+	models.ResourceTypeArticles: `{
+		"type": "record", "name": "Article", "namespace": "com.bulkimportexport",
+		"fields": [
+			{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+			{"name": "slug", "type": "string"},
+			{"name": "title", "type": "string"},
+			{"name": "body", "type": "string"},
+			{"name": "author_id", "type": {"type": "string", "logicalType": "uuid"}},
+			{"name": "tags", "type": {"type": "array", "items": "string"}},
+			{"name": "published_at", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}], "default": null},
+			{"name": "status", "type": "string"},
+			{"name": "lang", "type": ["null", "string"], "default": null},
+			{"name": "import_job_id", "type": ["null", {"type": "string", "logicalType": "uuid"}], "default": null},
+			{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+			{"name": "updated_at", "type": {"type": "long", "logicalType": "timestamp-micros"}}
+		]
+	}`,
+	models.ResourceTypeComments: `{
+		"type": "record", "name": "Comment", "namespace": "com.bulkimportexport",
+		"fields": [
+			{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+			{"name": "article_id", "type": {"type": "string", "logicalType": "uuid"}},
+			{"name": "user_id", "type": {"type": "string", "logicalType": "uuid"}},
+			{"name": "body", "type": "string"},
+			{"name": "lang", "type": ["null", "string"], "default": null},
+			{"name": "import_job_id", "type": ["null", {"type": "string", "logicalType": "uuid"}], "default": null},
+			{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+			{"name": "updated_at", "type": {"type": "long", "logicalType": "timestamp-micros"}}
+		]
+	}`,
+}
+
+func init() {
+	// Store the schemas compact, as they are embedded in every file header
+	for resource, schema := range schemas {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(schema)); err != nil {
+			panic(fmt.Sprintf("avro: invalid %s schema: %v", resource, err))
+		}
+		schemas[resource] = buf.String()
+	}
+}
+
+// Schema returns the Avro schema of resource's export records as JSON
+func Schema(resource models.ResourceType) (string, error) {
+	schema, ok := schemas[resource]
+	if !ok {
+		return "", fmt.Errorf("no avro schema for resource %q", resource)
+	}
+	return schema, nil
+}
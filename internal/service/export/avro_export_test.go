@@ -0,0 +1,77 @@
+package exportservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rohit/bulk-import-export/internal/service/export/avro"
+)
+
+func TestProcessAsyncExport_AvroRegistersSchema(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{Email: email, Name: email, Role: "reader"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer srv.Close()
+
+	svc := newTestService(db)
+	svc.config.Load().OutputPath = t.TempDir()
+	svc.config.Load().AvroCodec = avro.CodecDeflate
+	// Compression doesn't apply to avro files
+	svc.config.Load().Compression = string(models.ExportCompressionGzip)
+	registry, err := avro.NewRegistry(srv.URL, "test-", "", "", time.Second)
+	if err != nil {
+		t.Fatalf("NewRegistry() error: %v", err)
+	}
+	svc.registry = registry
+
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: &models.JobParams{Format: FormatAvro}}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessAsyncExport(ctx, job, nil); err != nil {
+		t.Fatalf("ProcessAsyncExport() error: %v", err)
+	}
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.FilePath == nil || !strings.HasSuffix(*stored.FilePath, ".avro") || stored.SuccessfulRecords != 3 {
+		t.Fatalf("job = %+v, want 3 records in a .avro file", stored)
+	}
+	data, err := os.ReadFile(*stored.FilePath)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "Obj\x01") {
+		t.Errorf("export doesn't start with the Avro magic: %q", data[:8])
+	}
+	if ExportContentType(*stored.FilePath) != "application/avro" {
+		t.Errorf("ExportContentType() = %q, want application/avro", ExportContentType(*stored.FilePath))
+	}
+
+	var manifest models.ExportManifest
+	raw, err := os.ReadFile(ManifestPath(*stored.FilePath))
+	if err != nil {
+		t.Fatalf("ReadFile(manifest) error: %v", err)
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatalf("Unmarshal(manifest) error: %v", err)
+	}
+	if manifest.Format != FormatAvro || manifest.SchemaSubject != "test-users-value" || manifest.SchemaID != 7 || manifest.RecordCount != 3 {
+		t.Errorf("manifest = %+v, want avro, test-users-value, id 7, 3 records", manifest)
+	}
+}
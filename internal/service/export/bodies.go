@@ -0,0 +1,109 @@
+package exportservice
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// FormatBodies is the article export format writing a zip of the article
+// metadata as NDJSON and each body as its own markdown file, for CMSs that
+// ingest bodies as files
+const FormatBodies = "bodies"
+
+// Entries of a bodies export
+const (
+	// BodiesMetadataName is the NDJSON file of article metadata
+	BodiesMetadataName = "articles.ndjson"
+	// BodiesDir holds one <slug>.md file per article, or <id>.md when the
+	// slug isn't safe as a file name
+	BodiesDir = "bodies/"
+)
+
+// zipContentType is the Content-Type of zip archives
+const zipContentType = "application/zip"
+
+// bodyFileSlug matches the slugs used as body file names unchanged
+var bodyFileSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// articleMetadata is an article line in a bodies export
+type articleMetadata struct {
+	*models.Article
+	// Body is always empty, so it hides the article's body from the line
+	Body string `json:"body,omitempty"`
+	// BodyFile is the path of the article's body within the zip
+	BodyFile string `json:"body_file"`
+}
+
+// bodyFileName is the zip path article's body is written to
+func bodyFileName(article *models.Article) string {
+	if bodyFileSlug.MatchString(article.Slug) {
+		return BodiesDir + article.Slug + ".md"
+	}
+	return BodiesDir + article.ID.String() + ".md"
+}
+
+// StreamArticleBodies writes articles as a zip with a body file per article
+// and, last, the metadata of all of them in BodiesMetadataName. The metadata
+// is spooled to a temporary file in tmpDir while the bodies are written. It
+// returns the number of articles written.
+func (s *Service) StreamArticleBodies(ctx context.Context, w io.Writer, filters *models.ExportFilters, tmpDir string) (int, error) {
+	meta, err := os.CreateTemp(tmpDir, "bodies-*.ndjson")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create metadata file: %w", err)
+	}
+	defer os.Remove(meta.Name())
+	defer meta.Close()
+
+	zw := zip.NewWriter(w)
+	enc := newRecordEncoder(meta)
+	count := 0
+	order := exportOrder(ctx)
+	err = s.articleRepo.GetAllWithCursor(ctx, filters, s.config.Load().BatchSize, func(articles []*models.Article) error {
+		for _, article := range articles {
+			if err := order.next(article.CreatedAt, article.ID); err != nil {
+				return err
+			}
+			line := articleMetadata{Article: article, BodyFile: bodyFileName(article)}
+			if err := enc.Encode(line); err != nil {
+				if enc.WriteFailed() {
+					return fmt.Errorf("failed to write article metadata: %w", err)
+				}
+				s.logger.Warn().Err(err).Str("article_id", article.ID.String()).Msg("Failed to marshal article")
+				continue
+			}
+			body, err := zw.CreateHeader(&zip.FileHeader{Name: line.BodyFile, Method: zip.Deflate, Modified: article.UpdatedAt})
+			if err != nil {
+				return fmt.Errorf("failed to add article body: %w", err)
+			}
+			if _, err := io.WriteString(body, article.Body); err != nil {
+				return fmt.Errorf("failed to write article body: %w", err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if _, err := meta.Seek(0, io.SeekStart); err != nil {
+		return count, err
+	}
+	entry, err := zw.Create(BodiesMetadataName)
+	if err != nil {
+		return count, fmt.Errorf("failed to add article metadata: %w", err)
+	}
+	if _, err := io.Copy(entry, meta); err != nil {
+		return count, fmt.Errorf("failed to write article metadata: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return count, fmt.Errorf("failed to finish zip: %w", err)
+	}
+	return count, nil
+}
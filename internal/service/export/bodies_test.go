@@ -0,0 +1,103 @@
+package exportservice
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestProcessAsyncExport_BodiesZip(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	author := sampleUser()
+	if err := memory.NewUserRepository(db).Create(ctx, author); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	articles := []*models.Article{
+		{Slug: "first-post", Title: "First", Body: "# First\n\nHello", AuthorID: author.ID, Status: "published", Tags: json.RawMessage(`[]`)},
+		{Slug: "second-post", Title: "Second", Body: "Second body", AuthorID: author.ID, Status: "draft", Tags: json.RawMessage(`[]`)},
+		// Not safe as a file name, so the body is named by ID
+		{Slug: "../Third", Title: "Third", Body: "Third body", AuthorID: author.ID, Status: "draft", Tags: json.RawMessage(`[]`)},
+	}
+	for _, article := range articles {
+		if err := memory.NewArticleRepository(db).Create(ctx, article); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	svc := newTestService(db)
+	svc.config.Load().OutputPath = t.TempDir()
+	// Compression doesn't apply to zips
+	svc.config.Load().Compression = string(models.ExportCompressionGzip)
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeArticles, Status: models.JobStatusPending, Params: &models.JobParams{Format: FormatBodies}}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessAsyncExport(ctx, job, nil); err != nil {
+		t.Fatalf("ProcessAsyncExport() error: %v", err)
+	}
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.FilePath == nil || !strings.HasSuffix(*stored.FilePath, ".zip") || stored.SuccessfulRecords != 3 {
+		t.Fatalf("job = %+v, want 3 records in a .zip file", stored)
+	}
+	if ExportContentType(*stored.FilePath) != "application/zip" {
+		t.Errorf("ExportContentType() = %q, want application/zip", ExportContentType(*stored.FilePath))
+	}
+
+	zr, err := zip.OpenReader(*stored.FilePath)
+	if err != nil {
+		t.Fatalf("OpenReader() error: %v", err)
+	}
+	defer zr.Close()
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	wantFiles := map[string]string{
+		"first-post":  BodiesDir + "first-post.md",
+		"second-post": BodiesDir + "second-post.md",
+		"../Third":    BodiesDir + articles[2].ID.String() + ".md",
+	}
+	bodies := map[string]string{}
+	for _, article := range articles {
+		bodies[article.Slug] = article.Body
+	}
+	lines := 0
+	scanner := bufio.NewScanner(strings.NewReader(files[BodiesMetadataName]))
+	for scanner.Scan() {
+		lines++
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Unmarshal(%q) error: %v", scanner.Text(), err)
+		}
+		slug, _ := line["slug"].(string)
+		if _, ok := line["body"]; ok {
+			t.Errorf("metadata for %s has a body", slug)
+		}
+		if line["body_file"] != wantFiles[slug] {
+			t.Errorf("body_file for %s = %v, want %s", slug, line["body_file"], wantFiles[slug])
+		}
+		if files[wantFiles[slug]] != bodies[slug] {
+			t.Errorf("body file for %s = %q, want %q", slug, files[wantFiles[slug]], bodies[slug])
+		}
+	}
+	if lines != 3 || len(files) != 4 {
+		t.Errorf("zip has %d metadata lines and %d files, want 3 and 4", lines, len(files))
+	}
+}
@@ -0,0 +1,159 @@
+package exportservice
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// FormatBundle is the export format writing users, articles and comments to
+// one zip, all read from the same snapshot so the references between them
+// resolve within the bundle
+const FormatBundle = "bundle"
+
+// bundleResources are the resources of a bundle, in the order they are
+// written: each refers only to the ones before it
+var bundleResources = []models.ResourceType{
+	models.ResourceTypeUsers,
+	models.ResourceTypeArticles,
+	models.ResourceTypeComments,
+}
+
+// BundleEntryName is the NDJSON file of resource within a bundle
+func BundleEntryName(resource models.ResourceType) string {
+	return string(resource) + ".ndjson"
+}
+
+// bundleWriter writes the entries of a bundle and checks the references
+// between them. It keeps the IDs of the users and articles written.
+type bundleWriter struct {
+	s        *Service
+	zw       *zip.Writer
+	users    map[uuid.UUID]struct{}
+	articles map[uuid.UUID]struct{}
+	manifest *models.BundleManifest
+}
+
+// StreamBundle writes users, articles and comments as NDJSON entries of a
+// zip and returns the bundle's part of the manifest. Call it with a snapshot
+// context, or the resources are read at different times and the closure
+// check may find references to records created or deleted in between.
+func (s *Service) StreamBundle(ctx context.Context, w io.Writer) (*models.BundleManifest, error) {
+	b := &bundleWriter{
+		s:        s,
+		zw:       zip.NewWriter(w),
+		users:    make(map[uuid.UUID]struct{}),
+		articles: make(map[uuid.UUID]struct{}),
+		manifest: &models.BundleManifest{Counts: make(map[models.ResourceType]int, len(bundleResources))},
+	}
+	for _, resource := range bundleResources {
+		if err := b.writeEntry(ctx, resource); err != nil {
+			return b.manifest, err
+		}
+	}
+	if err := b.zw.Close(); err != nil {
+		return b.manifest, fmt.Errorf("failed to finish zip: %w", err)
+	}
+
+	closure := &b.manifest.Closure
+	closure.Closed = closure.MissingAuthors == 0 && closure.MissingArticles == 0 && closure.MissingUsers == 0
+	return b.manifest, nil
+}
+
+// bundleRecordCount is the number of records across the entries of a bundle
+func bundleRecordCount(manifest *models.BundleManifest) int {
+	total := 0
+	for _, n := range manifest.Counts {
+		total += n
+	}
+	return total
+}
+
+// writeEntry writes resource to its entry of the bundle
+func (b *bundleWriter) writeEntry(ctx context.Context, resource models.ResourceType) error {
+	entry, err := b.zw.Create(BundleEntryName(resource))
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", resource, err)
+	}
+	// Each entry is ordered on its own
+	if exportOrder(ctx) != nil {
+		ctx = withOrderCheck(ctx)
+	}
+	order := exportOrder(ctx)
+	enc := newRecordEncoder(entry)
+	batchSize := b.s.config.Load().BatchSize
+
+	// write encodes a record, skipping one that can't be marshalled as the
+	// other exports do
+	write := func(record interface{}, id uuid.UUID) (bool, error) {
+		if err := enc.Encode(record); err != nil {
+			if enc.WriteFailed() {
+				return false, fmt.Errorf("failed to write %s data: %w", resource, err)
+			}
+			b.s.logger.Warn().Err(err).Str("resource", string(resource)).Str("id", id.String()).Msg("Failed to marshal record")
+			return false, nil
+		}
+		b.manifest.Counts[resource]++
+		return true, nil
+	}
+
+	closure := &b.manifest.Closure
+	switch resource {
+	case models.ResourceTypeUsers:
+		return b.s.userRepo.GetAllWithCursor(ctx, nil, batchSize, func(users []*models.User) error {
+			for _, user := range users {
+				if err := order.next(user.CreatedAt, user.ID); err != nil {
+					return err
+				}
+				if ok, err := write(user, user.ID); err != nil {
+					return err
+				} else if ok {
+					b.users[user.ID] = struct{}{}
+				}
+			}
+			return nil
+		})
+	case models.ResourceTypeArticles:
+		return b.s.articleRepo.GetAllWithCursor(ctx, nil, batchSize, func(articles []*models.Article) error {
+			for _, article := range articles {
+				if err := order.next(article.CreatedAt, article.ID); err != nil {
+					return err
+				}
+				if ok, err := write(article, article.ID); err != nil {
+					return err
+				} else if ok {
+					b.articles[article.ID] = struct{}{}
+					if _, found := b.users[article.AuthorID]; !found {
+						closure.MissingAuthors++
+					}
+				}
+			}
+			return nil
+		})
+	case models.ResourceTypeComments:
+		return b.s.commentRepo.GetAllWithCursor(ctx, nil, batchSize, func(comments []*models.Comment) error {
+			for _, comment := range comments {
+				if err := order.next(comment.CreatedAt, comment.ID); err != nil {
+					return err
+				}
+				if ok, err := write(comment, comment.ID); err != nil {
+					return err
+				} else if ok {
+					if _, found := b.articles[comment.ArticleID]; !found {
+						closure.MissingArticles++
+					}
+					if _, found := b.users[comment.UserID]; !found {
+						closure.MissingUsers++
+					}
+				}
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown resource type: %s", resource)
+	}
+}
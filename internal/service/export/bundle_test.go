@@ -0,0 +1,134 @@
+package exportservice
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+// hiddenUser leaves a user out of exports, as if it were deleted between
+// reads
+type hiddenUser struct {
+	repository.UserRepository
+	id uuid.UUID
+}
+
+func (h hiddenUser) GetAllWithCursor(ctx context.Context, filters *models.ExportFilters, batchSize int, callback func([]*models.User) error) error {
+	return h.UserRepository.GetAllWithCursor(ctx, filters, batchSize, func(users []*models.User) error {
+		kept := users[:0]
+		for _, user := range users {
+			if user.ID != h.id {
+				kept = append(kept, user)
+			}
+		}
+		return callback(kept)
+	})
+}
+
+func TestProcessAsyncExport_Bundle(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	author := sampleUser()
+	reader := &models.User{Email: "reader@example.com", Name: "Reader", Role: "reader", Active: true}
+	for _, user := range []*models.User{author, reader} {
+		if err := memory.NewUserRepository(db).Create(ctx, user); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	article := &models.Article{Slug: "first-post", Title: "First", Body: "Hello", AuthorID: author.ID, Status: "published", Tags: json.RawMessage(`[]`)}
+	if err := memory.NewArticleRepository(db).Create(ctx, article); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	comments := []*models.Comment{
+		{ArticleID: article.ID, UserID: author.ID, Body: "Nice"},
+		{ArticleID: article.ID, UserID: reader.ID, Body: "Thanks"},
+	}
+	for _, comment := range comments {
+		if err := memory.NewCommentRepository(db).Create(ctx, comment); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	// Bundles read from a snapshot whatever the config, and verify the
+	// count of each resource
+	svc := newTestService(db)
+	svc.config.Load().ConsistentSnapshot = false
+	svc.config.Load().VerifyCount = true
+	svc.config.Load().EnforceOrder = true
+	svc.config.Load().OutputPath = t.TempDir()
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: &models.JobParams{Format: FormatBundle}}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessAsyncExport(ctx, job, nil); err != nil {
+		t.Fatalf("ProcessAsyncExport() error: %v", err)
+	}
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.FilePath == nil || !strings.HasSuffix(*stored.FilePath, ".zip") || stored.SuccessfulRecords != 5 {
+		t.Fatalf("job = %+v, want 5 records in a .zip file", stored)
+	}
+
+	zr, err := zip.OpenReader(*stored.FilePath)
+	if err != nil {
+		t.Fatalf("OpenReader() error: %v", err)
+	}
+	defer zr.Close()
+	lines := map[string]int{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error: %v", f.Name, err)
+		}
+		for sc := bufio.NewScanner(rc); sc.Scan(); {
+			lines[f.Name]++
+		}
+		rc.Close()
+	}
+	want := map[string]int{"users.ndjson": 2, "articles.ndjson": 1, "comments.ndjson": 2}
+	for name, n := range want {
+		if lines[name] != n {
+			t.Errorf("%s has %d lines, want %d", name, lines[name], n)
+		}
+	}
+
+	data, err := os.ReadFile(ManifestPath(*stored.FilePath))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	var manifest models.ExportManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if manifest.Format != FormatBundle || !manifest.Consistent || manifest.Bundle == nil {
+		t.Fatalf("manifest = %+v, want a consistent bundle", manifest)
+	}
+	if manifest.Bundle.Counts[models.ResourceTypeComments] != 2 {
+		t.Errorf("counts = %v, want 2 comments", manifest.Bundle.Counts)
+	}
+	if closure := manifest.Bundle.Closure; !closure.Closed {
+		t.Errorf("closure = %+v, want closed", closure)
+	}
+
+	// A comment by a user left out of the bundle breaks its closure
+	var buf strings.Builder
+	svc.userRepo = hiddenUser{svc.userRepo, reader.ID}
+	bundle, err := svc.StreamBundle(ctx, &buf)
+	if err != nil {
+		t.Fatalf("StreamBundle() error: %v", err)
+	}
+	wantClosure := models.BundleClosure{MissingUsers: 1}
+	if bundle.Closure != wantClosure {
+		t.Errorf("closure = %+v, want %+v", bundle.Closure, wantClosure)
+	}
+}
@@ -0,0 +1,146 @@
+package exportservice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// ParseCohort reads a cohort file: one user ID or email per line, or per row
+// of a CSV whose first column holds them. Blank lines, an "id" or "email"
+// header and repeated values are skipped. Every value must be of the same
+// kind, and there may be at most max of them.
+func ParseCohort(r io.Reader, max int) (ids []uuid.UUID, emails []string, err error) {
+	var values []string
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		value, _, _ := strings.Cut(scanner.Text(), ",")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if line == 1 {
+			value = strings.TrimPrefix(value, "\ufeff")
+			if header := strings.ToLower(value); header == "id" || header == "email" {
+				continue
+			}
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read cohort file: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, nil, fmt.Errorf("cohort file lists no user IDs or emails")
+	}
+	if strings.Contains(values[0], "@") {
+		emails, err = CohortEmails(values, max)
+		return nil, emails, err
+	}
+	ids, err = CohortIDs(values, max)
+	return ids, nil, err
+}
+
+// CohortIDs parses the user IDs of a cohort, dropping repeats
+func CohortIDs(values []string, max int) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool, len(values))
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		id, err := uuid.Parse(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q in cohort", value)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > max {
+		return nil, fmt.Errorf("cohort lists %d user IDs, more than the limit of %d", len(ids), max)
+	}
+	return ids, nil
+}
+
+// CohortEmails lowercases the emails of a cohort, dropping repeats
+func CohortEmails(values []string, max int) ([]string, error) {
+	seen := make(map[string]bool, len(values))
+	emails := make([]string, 0, len(values))
+	for _, value := range values {
+		email := strings.ToLower(strings.TrimSpace(value))
+		if !strings.Contains(email, "@") {
+			return nil, fmt.Errorf("invalid email %q in cohort", value)
+		}
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) > max {
+		return nil, fmt.Errorf("cohort lists %d emails, more than the limit of %d", len(emails), max)
+	}
+	return emails, nil
+}
+
+// CohortKey returns the key a cohort file is stored under
+func CohortKey(id uuid.UUID) string {
+	return path.Join("cohorts", id.String()+".txt")
+}
+
+// SaveCohort moves a cohort larger than EXPORT_COHORT_INLINE_MAX out of
+// filters into storage, so the job's parameters stay small; it is read back
+// when the export runs. Smaller cohorts are left in filters.
+func (s *Service) SaveCohort(ctx context.Context, filters *models.ExportFilters) error {
+	if filters == nil || s.store == nil || len(filters.IDs)+len(filters.Emails) <= s.config.Load().CohortInlineMax {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, id := range filters.IDs {
+		buf.WriteString(id.String())
+		buf.WriteByte('\n')
+	}
+	for _, email := range filters.Emails {
+		buf.WriteString(email)
+		buf.WriteByte('\n')
+	}
+	key := CohortKey(uuid.New())
+	if err := s.store.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "text/plain"); err != nil {
+		return fmt.Errorf("failed to store cohort: %w", err)
+	}
+	filters.IDs, filters.Emails, filters.CohortFile = nil, nil, key
+	return nil
+}
+
+// loadCohort returns filters with a stored cohort read back into IDs or
+// Emails; filters without one are returned as they are
+func (s *Service) loadCohort(ctx context.Context, filters *models.ExportFilters) (*models.ExportFilters, error) {
+	if filters == nil || filters.CohortFile == "" {
+		return filters, nil
+	}
+	if s.store == nil {
+		return nil, fmt.Errorf("cohort %s needs a storage driver", filters.CohortFile)
+	}
+
+	file, err := s.store.Get(ctx, filters.CohortFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cohort: %w", err)
+	}
+	defer file.Close()
+
+	loaded := *filters
+	loaded.CohortFile = ""
+	// The file was checked against the limit when it was uploaded
+	if loaded.IDs, loaded.Emails, err = ParseCohort(file, math.MaxInt); err != nil {
+		return nil, err
+	}
+	return &loaded, nil
+}
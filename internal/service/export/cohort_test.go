@@ -0,0 +1,81 @@
+package exportservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rohit/bulk-import-export/internal/storage"
+)
+
+func TestParseCohort(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		file   string
+		ids    int
+		emails int
+		ok     bool
+	}{
+		{"ids", id.String() + "\n\n" + uuid.NewString() + "\n", 2, 0, true},
+		{"repeated ids", id.String() + "\n" + strings.ToUpper(id.String()) + "\n", 1, 0, true},
+		{"email csv", "email,name\nA@Example.com,A\nb@example.com,B\na@example.com,A\n", 0, 2, true},
+		{"quoted", "\"id\"\n\"" + id.String() + "\"\n", 1, 0, true},
+		{"mixed", id.String() + "\na@example.com\n", 0, 0, false},
+		{"empty", "email\n\n", 0, 0, false},
+		{"over the limit", "a@example.com\nb@example.com\nc@example.com\n", 0, 0, false},
+	}
+	for _, tt := range tests {
+		ids, emails, err := ParseCohort(strings.NewReader(tt.file), 2)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if len(ids) != tt.ids || len(emails) != tt.emails {
+			t.Errorf("%s: got %d ids and %d emails, want %d and %d", tt.name, len(ids), len(emails), tt.ids, tt.emails)
+		}
+	}
+}
+
+func TestProcessAsyncExport_StoredCohort(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	var cohort []string
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{Email: email, Name: email, Role: "reader"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if email != "b@example.com" {
+			cohort = append(cohort, email)
+		}
+	}
+
+	svc := newTestService(db)
+	svc.store = storage.NewLocal(t.TempDir())
+	svc.config.Load().OutputPath = t.TempDir()
+	svc.config.Load().CohortInlineMax = 1
+
+	filters := &models.ExportFilters{Emails: cohort}
+	if err := svc.SaveCohort(ctx, filters); err != nil {
+		t.Fatalf("SaveCohort() error: %v", err)
+	}
+	if filters.CohortFile == "" || filters.Emails != nil {
+		t.Fatalf("filters = %+v, want the cohort moved to storage", filters)
+	}
+
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessAsyncExport(ctx, job, filters); err != nil {
+		t.Fatalf("ProcessAsyncExport() error: %v", err)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.SuccessfulRecords != 2 {
+		t.Errorf("exported %d records, want the 2 users in the cohort", stored.SuccessfulRecords)
+	}
+}
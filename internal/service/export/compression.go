@@ -0,0 +1,171 @@
+package exportservice
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// avroContentType is the Content-Type of Avro object container files
+const avroContentType = "application/avro"
+
+// codec compresses export output
+type codec struct {
+	// encoding is the codec's Content-Encoding token
+	encoding    string
+	extension   string
+	contentType string
+	// minLevel and maxLevel bound the accepted compression levels; 0 always
+	// means the codec's default
+	minLevel, maxLevel int
+	newWriter          func(w io.Writer, level int) (codecWriter, error)
+}
+
+// codecWriter is a compressing writer that can emit what it has so far
+type codecWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// codecs are the available compressions, keyed by name
+var codecs = map[models.ExportCompression]codec{
+	models.ExportCompressionGzip: {
+		encoding:    "gzip",
+		extension:   ".gz",
+		contentType: "application/gzip",
+		minLevel:    gzip.BestSpeed,
+		maxLevel:    gzip.BestCompression,
+		newWriter: func(w io.Writer, level int) (codecWriter, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+	},
+	// zstd takes the levels of the zstd tool, which the encoder maps onto
+	// its own few speeds
+	models.ExportCompressionZstd: {
+		encoding:    "zstd",
+		extension:   ".zst",
+		contentType: "application/zstd",
+		minLevel:    1,
+		maxLevel:    22,
+		newWriter: func(w io.Writer, level int) (codecWriter, error) {
+			speed := zstd.SpeedDefault
+			if level != 0 {
+				speed = zstd.EncoderLevelFromZstd(level)
+			}
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(speed))
+		},
+	},
+}
+
+// ValidateCompression checks that compression is known and level is within
+// its range. Empty and none mean uncompressed and take no level.
+func ValidateCompression(compression models.ExportCompression, level int) error {
+	if compression == "" || compression == models.ExportCompressionNone {
+		if level != 0 {
+			return fmt.Errorf("compression_level requires a compression")
+		}
+		return nil
+	}
+	c, ok := codecs[compression]
+	if !ok {
+		return fmt.Errorf("unsupported compression %q, expected none, gzip or zstd", compression)
+	}
+	if level != 0 && (level < c.minLevel || level > c.maxLevel) {
+		return fmt.Errorf("compression_level for %s must be between %d and %d", compression, c.minLevel, c.maxLevel)
+	}
+	return nil
+}
+
+// CompressionExtension is the suffix added to a file compressed with
+// compression, or "" when it is uncompressed
+func CompressionExtension(compression models.ExportCompression) string {
+	return codecs[compression].extension
+}
+
+// ExportContentType is the Content-Type to serve an export file with
+func ExportContentType(filePath string) string {
+	if strings.HasSuffix(filePath, ".avro") {
+		return avroContentType
+	}
+	if strings.HasSuffix(filePath, ".zip") {
+		return zipContentType
+	}
+	for _, c := range codecs {
+		if strings.HasSuffix(filePath, c.extension) {
+			return c.contentType
+		}
+	}
+	return "application/x-ndjson"
+}
+
+// NegotiateEncoding picks the compression for a streaming response from the
+// client's Accept-Encoding header, preferring the client's order. It returns
+// "" when the client accepts none of the codecs.
+func NegotiateEncoding(acceptEncoding string) (models.ExportCompression, string) {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		token, params, _ := strings.Cut(part, ";")
+		token = strings.ToLower(strings.TrimSpace(token))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight <= 0 {
+				continue
+			}
+		}
+		for name, c := range codecs {
+			if c.encoding == token {
+				return name, c.encoding
+			}
+		}
+	}
+	return "", ""
+}
+
+// CompressedWriter compresses what is written to it into the underlying
+// writer. Flush pushes the data compressed so far through, including to an
+// underlying http.Flusher, so batches still reach streaming clients promptly.
+type CompressedWriter struct {
+	cw      codecWriter
+	flusher http.Flusher
+}
+
+// NewCompressedWriter wraps w with compression at level. For uncompressed
+// output it returns nil, and the caller writes to w directly.
+func NewCompressedWriter(w io.Writer, compression models.ExportCompression, level int) (*CompressedWriter, error) {
+	if compression == "" || compression == models.ExportCompressionNone {
+		return nil, nil
+	}
+	if err := ValidateCompression(compression, level); err != nil {
+		return nil, err
+	}
+	cw, err := codecs[compression].newWriter(w, level)
+	if err != nil {
+		return nil, err
+	}
+	flusher, _ := w.(http.Flusher)
+	return &CompressedWriter{cw: cw, flusher: flusher}, nil
+}
+
+func (c *CompressedWriter) Write(p []byte) (int, error) {
+	return c.cw.Write(p)
+}
+
+// Flush implements http.Flusher
+func (c *CompressedWriter) Flush() {
+	if c.cw.Flush() == nil && c.flusher != nil {
+		c.flusher.Flush()
+	}
+}
+
+// Close writes the end of the compressed stream; it doesn't close the
+// underlying writer
+func (c *CompressedWriter) Close() error {
+	return c.cw.Close()
+}
@@ -0,0 +1,115 @@
+package exportservice
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]models.ExportCompression{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   models.ExportCompressionGzip,
+		"br, GZIP;q=0.8":         models.ExportCompressionGzip,
+		"gzip;q=0, identity":     "",
+		"deflate, gzip ; q=0.5 ": models.ExportCompressionGzip,
+		"zstd, gzip":             models.ExportCompressionZstd,
+		"zstd;q=0, gzip":         models.ExportCompressionGzip,
+	}
+	for header, want := range tests {
+		if got, _ := NegotiateEncoding(header); got != want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	tests := []struct {
+		compression models.ExportCompression
+		level       int
+		ok          bool
+	}{
+		{"", 0, true},
+		{models.ExportCompressionNone, 0, true},
+		{models.ExportCompressionNone, 3, false},
+		{models.ExportCompressionGzip, 0, true},
+		{models.ExportCompressionGzip, 9, true},
+		{models.ExportCompressionGzip, 10, false},
+		{models.ExportCompressionZstd, 0, true},
+		{models.ExportCompressionZstd, 19, true},
+		{models.ExportCompressionZstd, 23, false},
+		{"lz4", 0, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateCompression(tt.compression, tt.level); (err == nil) != tt.ok {
+			t.Errorf("ValidateCompression(%q, %d) error = %v, want ok %v", tt.compression, tt.level, err, tt.ok)
+		}
+	}
+}
+
+func TestProcessAsyncExport_Compressed(t *testing.T) {
+	tests := []struct {
+		compression models.ExportCompression
+		extension   string
+		contentType string
+		reader      func(io.Reader) (io.Reader, error)
+	}{
+		{models.ExportCompressionGzip, ".ndjson.gz", "application/gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{models.ExportCompressionZstd, ".ndjson.zst", "application/zstd", func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) }},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.compression), func(t *testing.T) {
+			db := memory.NewDB()
+			ctx := context.Background()
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				if err := memory.NewUserRepository(db).Create(ctx, &models.User{Email: email, Name: email, Role: "reader"}); err != nil {
+					t.Fatalf("Create() error: %v", err)
+				}
+			}
+
+			svc := newTestService(db)
+			svc.config.Load().OutputPath = t.TempDir()
+			svc.config.Load().Compression = string(tt.compression)
+			jobs := memory.NewJobRepository(db)
+			job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+			if err := jobs.Create(ctx, job); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			if err := svc.ProcessAsyncExport(ctx, job, nil); err != nil {
+				t.Fatalf("ProcessAsyncExport() error: %v", err)
+			}
+
+			stored, _ := jobs.GetByID(ctx, job.ID)
+			if stored.FilePath == nil || !strings.HasSuffix(*stored.FilePath, tt.extension) || stored.SuccessfulRecords != 3 {
+				t.Fatalf("job = %+v, want 3 records in a %s file", stored, tt.extension)
+			}
+			file, err := os.Open(*stored.FilePath)
+			if err != nil {
+				t.Fatalf("Open() error: %v", err)
+			}
+			defer file.Close()
+			zr, err := tt.reader(file)
+			if err != nil {
+				t.Fatalf("reader error: %v", err)
+			}
+			data, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("ReadAll() error: %v", err)
+			}
+			if lines := strings.Count(string(data), "\n"); lines != 3 {
+				t.Errorf("decompressed export has %d lines, want 3", lines)
+			}
+			if ExportContentType(*stored.FilePath) != tt.contentType {
+				t.Errorf("ExportContentType() = %q, want %s", ExportContentType(*stored.FilePath), tt.contentType)
+			}
+		})
+	}
+}
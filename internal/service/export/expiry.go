@@ -0,0 +1,93 @@
+package exportservice
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/storage"
+)
+
+// ErrExportExpired is returned for a completed export whose file was
+// removed once it expired
+var ErrExportExpired = errors.New("export file has expired")
+
+// expiryBatchSize is the most expired exports removed per query
+const expiryBatchSize = 100
+
+// PurgeExpired removes the files of exports that completed more than
+// EXPORT_FILE_EXPIRY_HOURS before now, locally and in remote storage, and
+// clears them from their jobs so they stop counting against the tenant's
+// storage quota. It returns how many exports it removed. A file that fails
+// to be removed is left on its job and tried again by the next purge.
+func (s *Service) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	expiry := s.config.Load().FileExpiry
+	if expiry <= 0 {
+		return 0, nil
+	}
+
+	purged := 0
+	for {
+		jobs, err := s.jobRepo.ListExpiredExports(ctx, now.Add(-expiry), expiryBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		removed := 0
+		for _, job := range jobs {
+			log := s.logger.With().Str("job_id", job.ID.String()).Str("file_path", *job.FilePath).Logger()
+			if err := s.removeExportFiles(ctx, *job.FilePath); err != nil {
+				log.Warn().Err(err).Msg("Failed to remove expired export")
+				continue
+			}
+			if err := s.jobRepo.ClearFilePath(ctx, job.ID); err != nil {
+				return purged, err
+			}
+			removed++
+		}
+		purged += removed
+		// A short page is the last; a page that removed nothing would be
+		// listed again
+		if len(jobs) < expiryBatchSize || removed == 0 {
+			return purged, nil
+		}
+	}
+}
+
+// removeExportFiles removes an export file and its manifest, locally and
+// from remote storage. Files already gone are not an error.
+func (s *Service) removeExportFiles(ctx context.Context, filePath string) error {
+	for _, path := range []string{filePath, ManifestPath(filePath)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if storage.IsRemote(s.store) {
+			if err := s.store.Delete(ctx, storage.ExportKey(path)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunExpiry purges expired exports now and then every configured interval
+// until ctx is done
+func (s *Service) RunExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Load().ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeExpired(ctx, time.Now().UTC())
+		if err != nil {
+			s.logger.Error().Err(err).Int("purged", purged).Msg("Export expiry failed")
+		} else if purged > 0 {
+			s.logger.Info().Int("purged", purged).Msg("Removed expired exports")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
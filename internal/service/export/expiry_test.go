@@ -0,0 +1,58 @@
+package exportservice
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestPurgeExpired_RemovesOldExports(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	if err := memory.NewUserRepository(db).Create(ctx, &models.User{Email: "a@example.com", Name: "A", Role: "reader"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	svc := newTestService(db)
+	svc.config.Load().OutputPath = t.TempDir()
+	svc.config.Load().FileExpiry = time.Hour
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessAsyncExport(ctx, job, nil); err != nil {
+		t.Fatalf("ProcessAsyncExport() error: %v", err)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	filePath := *stored.FilePath
+
+	// Not yet expired
+	if purged, err := svc.PurgeExpired(ctx, time.Now()); err != nil || purged != 0 {
+		t.Fatalf("PurgeExpired() = %d, %v; want 0, nil", purged, err)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Fatalf("export removed before it expired: %v", err)
+	}
+
+	purged, err := svc.PurgeExpired(ctx, time.Now().Add(2*time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeExpired() = %d, %v; want 1, nil", purged, err)
+	}
+	for _, path := range []string{filePath, ManifestPath(filePath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after expiry", path)
+		}
+	}
+	if stored, _ := jobs.GetByID(ctx, job.ID); stored.FilePath != nil {
+		t.Errorf("FilePath = %q after expiry, want nil", *stored.FilePath)
+	}
+	if _, err := svc.GetExportFilePath(ctx, job.ID); !errors.Is(err, ErrExportExpired) {
+		t.Errorf("GetExportFilePath() error = %v, want ErrExportExpired", err)
+	}
+}
@@ -2,69 +2,254 @@ package exportservice
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rohit/bulk-import-export/internal/config"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 	"github.com/rohit/bulk-import-export/internal/metrics"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/service/export/avro"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
+	"github.com/rohit/bulk-import-export/internal/storage"
+	"github.com/rohit/bulk-import-export/pkg/logger"
 	"github.com/rs/zerolog"
 )
 
 // Service handles export operations
 type Service struct {
-	userRepo    *postgres.UserRepository
-	articleRepo *postgres.ArticleRepository
-	commentRepo *postgres.CommentRepository
-	jobRepo     *postgres.JobRepository
-	metrics     *metrics.Collector
-	logger      zerolog.Logger
-	config      config.ExportConfig
+	db            repository.Snapshotter
+	userRepo      repository.UserRepository
+	articleRepo   repository.ArticleRepository
+	commentRepo   repository.CommentRepository
+	tombstoneRepo repository.TombstoneRepository
+	jobRepo       repository.JobRepository
+	store         storage.Driver
+	signedURLTTL  atomic.Int64 // a time.Duration
+	metrics       *metrics.Collector
+	logger        zerolog.Logger
+	config        atomic.Pointer[config.ExportConfig]
+	hooks         hooks.Registry
+	registry      *avro.Registry // nil unless a schema registry is configured
 }
 
 // NewService creates a new export service
 func NewService(
-	userRepo *postgres.UserRepository,
-	articleRepo *postgres.ArticleRepository,
-	commentRepo *postgres.CommentRepository,
-	jobRepo *postgres.JobRepository,
+	db repository.Snapshotter,
+	userRepo repository.UserRepository,
+	articleRepo repository.ArticleRepository,
+	commentRepo repository.CommentRepository,
+	tombstoneRepo repository.TombstoneRepository,
+	jobRepo repository.JobRepository,
+	store storage.Driver,
+	signedURLTTL time.Duration,
 	metrics *metrics.Collector,
 	logger zerolog.Logger,
 	cfg config.ExportConfig,
 ) *Service {
-	return &Service{
-		userRepo:    userRepo,
-		articleRepo: articleRepo,
-		commentRepo: commentRepo,
-		jobRepo:     jobRepo,
-		metrics:     metrics,
-		logger:      logger,
-		config:      cfg,
+	var registry *avro.Registry
+	if cfg.SchemaRegistryURL != "" {
+		var err error
+		registry, err = avro.NewRegistry(cfg.SchemaRegistryURL, cfg.SchemaRegistrySubject, cfg.SchemaRegistryUsername, cfg.SchemaRegistryPassword, cfg.SchemaRegistryTimeout)
+		if err != nil {
+			logger.Error().Err(err).Msg("Avro exports will not register their schema")
+		}
+	}
+
+	s := &Service{
+		db:            db,
+		userRepo:      userRepo,
+		articleRepo:   articleRepo,
+		commentRepo:   commentRepo,
+		tombstoneRepo: tombstoneRepo,
+		jobRepo:       jobRepo,
+		store:         store,
+		metrics:       metrics,
+		logger:        logger,
+		registry:      registry,
+	}
+	s.config.Store(&cfg)
+	s.signedURLTTL.Store(int64(signedURLTTL))
+	return s
+}
+
+// SetConfig replaces the export settings and the lifetime of download links
+// when they are reloaded. The schema registry is fixed when the service is
+// created.
+func (s *Service) SetConfig(cfg config.ExportConfig, signedURLTTL time.Duration) {
+	s.config.Store(&cfg)
+	s.signedURLTTL.Store(int64(signedURLTTL))
+}
+
+// RegisterHooks adds lifecycle hooks that are called for every async and
+// diff export job
+func (s *Service) RegisterHooks(h hooks.Hooks) {
+	s.hooks.Register(h)
+}
+
+// Snapshot pins the point in time an export reflects
+type Snapshot struct {
+	AsOf       time.Time
+	Consistent bool
+	release    func()
+}
+
+// Close ends the snapshot transaction, if one was opened. It may be called
+// more than once.
+func (sn *Snapshot) Close() {
+	if sn.release != nil {
+		sn.release()
+		sn.release = nil
+	}
+}
+
+// BeginSnapshot records the export watermark. When consistent snapshots are
+// enabled it also opens a REPEATABLE READ transaction so every batch reads the
+// same data; the returned context must be passed to the Stream* methods.
+func (s *Service) BeginSnapshot(ctx context.Context) (context.Context, *Snapshot, error) {
+	return s.beginSnapshot(ctx, s.config.Load().ConsistentSnapshot)
+}
+
+// beginSnapshot is BeginSnapshot, opening the transaction when consistent is
+// set
+func (s *Service) beginSnapshot(ctx context.Context, consistent bool) (context.Context, *Snapshot, error) {
+	if !consistent {
+		asOf, err := s.db.Now(ctx)
+		if err != nil {
+			return ctx, nil, fmt.Errorf("failed to read database time: %w", err)
+		}
+		return ctx, &Snapshot{AsOf: asOf}, nil
+	}
+
+	snapCtx, asOf, release, err := s.db.Snapshot(ctx)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("failed to begin snapshot: %w", err)
+	}
+	return snapCtx, &Snapshot{AsOf: asOf, Consistent: true, release: release}, nil
+}
+
+// ManifestPath returns the manifest path for an export file
+func ManifestPath(filePath string) string {
+	return filePath + ".manifest.json"
+}
+
+// lineCounter counts NDJSON records as they are written
+type lineCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (lc *lineCounter) Write(p []byte) (int, error) {
+	n, err := lc.w.Write(p)
+	for _, b := range p[:n] {
+		if b == '\n' {
+			lc.lines++
+		}
+	}
+	return n, err
+}
+
+// recordEncoder writes NDJSON records through a single json.Encoder, which
+// reuses its encoding buffer instead of allocating one per json.Marshal call
+// and appends the newline itself
+type recordEncoder struct {
+	w        io.Writer
+	enc      *json.Encoder
+	writeErr error
+}
+
+func newRecordEncoder(w io.Writer) *recordEncoder {
+	e := &recordEncoder{w: w}
+	e.enc = json.NewEncoder(e)
+	return e
+}
+
+// Encode writes v as one NDJSON line. On a marshal error nothing is written
+// and WriteFailed reports false, so the caller can skip the record.
+func (e *recordEncoder) Encode(v interface{}) error {
+	return e.enc.Encode(v)
+}
+
+// WriteFailed reports whether the underlying writer returned an error
+func (e *recordEncoder) WriteFailed() bool {
+	return e.writeErr != nil
+}
+
+func (e *recordEncoder) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil {
+		e.writeErr = err
+	}
+	return n, err
+}
+
+// flusher is implemented by buffered writers such as the HTTP stream writer
+type flusher interface {
+	Flush()
+}
+
+// flushBatch pushes a finished batch to the client when w buffers output
+func flushBatch(w io.Writer) {
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+}
+
+// exportJobKey is the context key for the ID of the export job a stream
+// writes the file of
+type exportJobKey struct{}
+
+// withExportJob returns a context whose streams write the file of job jobID
+func withExportJob(ctx context.Context, jobID uuid.UUID) context.Context {
+	return context.WithValue(ctx, exportJobKey{}, jobID)
+}
+
+// exportRateKey is the key the rate of a stream is recorded under: its job's
+// ID, or a new one for a streaming export, which has no job
+func exportRateKey(ctx context.Context) string {
+	if jobID, ok := ctx.Value(exportJobKey{}).(uuid.UUID); ok {
+		return jobID.String()
 	}
+	return uuid.NewString()
+}
+
+// CurrentRate returns the rows per second of an export running on this
+// instance
+func (s *Service) CurrentRate(jobID uuid.UUID) (float64, bool) {
+	return s.metrics.JobRate(jobID.String())
 }
 
 // StreamUsers streams users to a writer in NDJSON format
 func (s *Service) StreamUsers(ctx context.Context, w io.Writer, filters *models.ExportFilters) error {
 	startTime := time.Now()
+	hot := logger.Hot(s.logger)
 	recordCount := 0
+	order := exportOrder(ctx)
+	rateKey := exportRateKey(ctx)
+	defer s.metrics.ClearJobRate(rateKey)
 
 	s.metrics.RecordExportJobStarted("users")
 
-	err := s.userRepo.GetAllWithCursor(ctx, filters, s.config.BatchSize, func(users []*models.User) error {
+	enc := newRecordEncoder(w)
+	err := s.userRepo.GetAllWithCursor(ctx, filters, s.config.Load().BatchSize, func(users []*models.User) error {
 		for _, user := range users {
-			data, err := json.Marshal(user)
-			if err != nil {
-				s.logger.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to marshal user")
-				continue
+			if err := order.next(user.CreatedAt, user.ID); err != nil {
+				return err
 			}
-			if _, err := w.Write(append(data, '\n')); err != nil {
-				return fmt.Errorf("failed to write user data: %w", err)
+			if err := enc.Encode(user); err != nil {
+				if enc.WriteFailed() {
+					return fmt.Errorf("failed to write user data: %w", err)
+				}
+				hot.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to marshal user")
+				continue
 			}
 			recordCount++
 		}
@@ -72,9 +257,10 @@ func (s *Service) StreamUsers(ctx context.Context, w io.Writer, filters *models.
 		// Update metrics
 		duration := time.Since(startTime).Seconds()
 		if duration > 0 {
-			s.metrics.RecordExportRate("users", "", float64(recordCount)/duration)
+			s.metrics.RecordExportRate("users", rateKey, float64(recordCount)/duration)
 		}
 
+		flushBatch(w)
 		return nil
 	})
 
@@ -95,31 +281,93 @@ func (s *Service) StreamUsers(ctx context.Context, w io.Writer, filters *models.
 	return err
 }
 
+// StreamUsersWithCounts streams users to a writer in NDJSON format with the
+// number of articles and comments each has written
+func (s *Service) StreamUsersWithCounts(ctx context.Context, w io.Writer, filters *models.ExportFilters) error {
+	startTime := time.Now()
+	hot := logger.Hot(s.logger)
+	recordCount := 0
+	order := exportOrder(ctx)
+	rateKey := exportRateKey(ctx)
+	defer s.metrics.ClearJobRate(rateKey)
+
+	s.metrics.RecordExportJobStarted("users")
+
+	enc := newRecordEncoder(w)
+	err := s.userRepo.GetAllWithCountsWithCursor(ctx, filters, s.config.Load().BatchSize, func(users []*models.UserWithCounts) error {
+		for _, user := range users {
+			if err := order.next(user.CreatedAt, user.ID); err != nil {
+				return err
+			}
+			if err := enc.Encode(user); err != nil {
+				if enc.WriteFailed() {
+					return fmt.Errorf("failed to write user data: %w", err)
+				}
+				hot.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to marshal user")
+				continue
+			}
+			recordCount++
+		}
+
+		duration := time.Since(startTime).Seconds()
+		if duration > 0 {
+			s.metrics.RecordExportRate("users", rateKey, float64(recordCount)/duration)
+		}
+
+		flushBatch(w)
+		return nil
+	})
+
+	duration := time.Since(startTime).Seconds()
+	status := "completed"
+	if err != nil {
+		status = "failed"
+	}
+
+	s.metrics.RecordExportJobCompleted("users", status, duration)
+	s.metrics.RecordExportRecords("users", recordCount)
+
+	s.logger.Info().
+		Int("records", recordCount).
+		Float64("duration_seconds", duration).
+		Msg("User export with counts completed")
+
+	return err
+}
+
 // StreamArticles streams articles to a writer in NDJSON format
 func (s *Service) StreamArticles(ctx context.Context, w io.Writer, filters *models.ExportFilters) error {
 	startTime := time.Now()
+	hot := logger.Hot(s.logger)
 	recordCount := 0
+	order := exportOrder(ctx)
+	rateKey := exportRateKey(ctx)
+	defer s.metrics.ClearJobRate(rateKey)
 
 	s.metrics.RecordExportJobStarted("articles")
 
-	err := s.articleRepo.GetAllWithCursor(ctx, filters, s.config.BatchSize, func(articles []*models.Article) error {
+	enc := newRecordEncoder(w)
+	err := s.articleRepo.GetAllWithCursor(ctx, filters, s.config.Load().BatchSize, func(articles []*models.Article) error {
 		for _, article := range articles {
-			data, err := json.Marshal(article)
-			if err != nil {
-				s.logger.Warn().Err(err).Str("article_id", article.ID.String()).Msg("Failed to marshal article")
-				continue
+			if err := order.next(article.CreatedAt, article.ID); err != nil {
+				return err
 			}
-			if _, err := w.Write(append(data, '\n')); err != nil {
-				return fmt.Errorf("failed to write article data: %w", err)
+			if err := enc.Encode(article); err != nil {
+				if enc.WriteFailed() {
+					return fmt.Errorf("failed to write article data: %w", err)
+				}
+				hot.Warn().Err(err).Str("article_id", article.ID.String()).Msg("Failed to marshal article")
+				continue
 			}
 			recordCount++
 		}
 
 		duration := time.Since(startTime).Seconds()
 		if duration > 0 {
-			s.metrics.RecordExportRate("articles", "", float64(recordCount)/duration)
+			s.metrics.RecordExportRate("articles", rateKey, float64(recordCount)/duration)
 		}
 
+		flushBatch(w)
 		return nil
 	})
 
@@ -143,28 +391,36 @@ func (s *Service) StreamArticles(ctx context.Context, w io.Writer, filters *mode
 // StreamComments streams comments to a writer in NDJSON format
 func (s *Service) StreamComments(ctx context.Context, w io.Writer, filters *models.ExportFilters) error {
 	startTime := time.Now()
+	hot := logger.Hot(s.logger)
 	recordCount := 0
+	order := exportOrder(ctx)
+	rateKey := exportRateKey(ctx)
+	defer s.metrics.ClearJobRate(rateKey)
 
 	s.metrics.RecordExportJobStarted("comments")
 
-	err := s.commentRepo.GetAllWithCursor(ctx, filters, s.config.BatchSize, func(comments []*models.Comment) error {
+	enc := newRecordEncoder(w)
+	err := s.commentRepo.GetAllWithCursor(ctx, filters, s.config.Load().BatchSize, func(comments []*models.Comment) error {
 		for _, comment := range comments {
-			data, err := json.Marshal(comment)
-			if err != nil {
-				s.logger.Warn().Err(err).Str("comment_id", comment.ID.String()).Msg("Failed to marshal comment")
-				continue
+			if err := order.next(comment.CreatedAt, comment.ID); err != nil {
+				return err
 			}
-			if _, err := w.Write(append(data, '\n')); err != nil {
-				return fmt.Errorf("failed to write comment data: %w", err)
+			if err := enc.Encode(comment); err != nil {
+				if enc.WriteFailed() {
+					return fmt.Errorf("failed to write comment data: %w", err)
+				}
+				hot.Warn().Err(err).Str("comment_id", comment.ID.String()).Msg("Failed to marshal comment")
+				continue
 			}
 			recordCount++
 		}
 
 		duration := time.Since(startTime).Seconds()
 		if duration > 0 {
-			s.metrics.RecordExportRate("comments", "", float64(recordCount)/duration)
+			s.metrics.RecordExportRate("comments", rateKey, float64(recordCount)/duration)
 		}
 
+		flushBatch(w)
 		return nil
 	})
 
@@ -185,8 +441,107 @@ func (s *Service) StreamComments(ctx context.Context, w io.Writer, filters *mode
 	return err
 }
 
+// StreamCommentsByArticle streams comments to a writer in NDJSON format as
+// one record per article, with the article's comments embedded in creation
+// order. Articles without a matching comment are left out.
+func (s *Service) StreamCommentsByArticle(ctx context.Context, w io.Writer, filters *models.ExportFilters) error {
+	startTime := time.Now()
+	hot := logger.Hot(s.logger)
+	recordCount := 0
+	commentCount := 0
+	rateKey := exportRateKey(ctx)
+	defer s.metrics.ClearJobRate(rateKey)
+
+	s.metrics.RecordExportJobStarted("comments")
+
+	enc := newRecordEncoder(w)
+	var group *models.ArticleComments
+	writeGroup := func() error {
+		if group == nil {
+			return nil
+		}
+		if err := enc.Encode(group); err != nil {
+			if enc.WriteFailed() {
+				return fmt.Errorf("failed to write comment data: %w", err)
+			}
+			hot.Warn().Err(err).Str("article_id", group.ArticleID.String()).Msg("Failed to marshal article comments")
+			return nil
+		}
+		recordCount++
+		commentCount += len(group.Comments)
+		return nil
+	}
+
+	// Comments arrive grouped by article, so a group is complete once the
+	// next article starts. The last group of a batch may continue in the next.
+	err := s.commentRepo.GetAllByArticleWithCursor(ctx, filters, s.config.Load().BatchSize, func(comments []*models.Comment) error {
+		for _, comment := range comments {
+			if group != nil && group.ArticleID == comment.ArticleID {
+				group.Comments = append(group.Comments, comment)
+				continue
+			}
+			if err := writeGroup(); err != nil {
+				return err
+			}
+			group = &models.ArticleComments{ArticleID: comment.ArticleID, Comments: []*models.Comment{comment}}
+		}
+
+		duration := time.Since(startTime).Seconds()
+		if duration > 0 {
+			s.metrics.RecordExportRate("comments", rateKey, float64(commentCount)/duration)
+		}
+
+		flushBatch(w)
+		return nil
+	})
+	if err == nil {
+		err = writeGroup()
+	}
+
+	duration := time.Since(startTime).Seconds()
+	status := "completed"
+	if err != nil {
+		status = "failed"
+	}
+
+	s.metrics.RecordExportJobCompleted("comments", status, duration)
+	s.metrics.RecordExportRecords("comments", commentCount)
+
+	s.logger.Info().
+		Int("records", recordCount).
+		Int("comments", commentCount).
+		Float64("duration_seconds", duration).
+		Msg("Comment export by article completed")
+
+	return err
+}
+
+// Export job phases, recorded on the job while it is processing
+const (
+	// PhaseStream is writing the records to the output file
+	PhaseStream = "stream"
+	// PhaseStore is writing the manifest and copying the output to storage
+	PhaseStore = "store"
+)
+
+// setPhase records the phase a processing export has reached
+func (s *Service) setPhase(ctx context.Context, job *models.Job, phase string, log zerolog.Logger) {
+	if err := s.jobRepo.SetPhase(ctx, job.ID, phase); err != nil {
+		log.Warn().Err(err).Str("phase", phase).Msg("Failed to record export phase")
+	}
+}
+
+// recordOutput records the output file on the job as soon as it is
+// created, so the export can be followed while it is written
+func (s *Service) recordOutput(ctx context.Context, job *models.Job, path string, log zerolog.Logger) {
+	job.FilePath = &path
+	if err := s.jobRepo.SetFilePath(ctx, job.ID, path); err != nil {
+		log.Warn().Err(err).Str("file_path", path).Msg("Failed to record export file path")
+	}
+}
+
 // ProcessAsyncExport processes an async export job
-func (s *Service) ProcessAsyncExport(ctx context.Context, job *models.Job, filters *models.ExportFilters) error {
+func (s *Service) ProcessAsyncExport(ctx context.Context, job *models.Job, filters *models.ExportFilters) (err error) {
 	log := s.logger.With().
 		Str("job_id", job.ID.String()).
 		Str("resource", string(job.Resource)).
@@ -200,72 +555,387 @@ func (s *Service) ProcessAsyncExport(ctx context.Context, job *models.Job, filte
 		return fmt.Errorf("failed to update job status: %w", err)
 	}
 
-	// Create output file
-	filename := fmt.Sprintf("%s_%s_%d.ndjson", job.Resource, job.ID.String()[:8], time.Now().Unix())
-	filePath := filepath.Join(s.config.OutputPath, filename)
+	s.hooks.OnJobStart(ctx, job)
+	defer func() { s.hooks.OnJobComplete(ctx, job, err) }()
+	s.setPhase(ctx, job, PhaseStream, log)
+
+	// Read back a cohort too large to keep with the job
+	if filters, err = s.loadCohort(ctx, filters); err != nil {
+		s.handleJobFailure(ctx, job.ID, log, err.Error())
+		return err
+	}
 
-	file, err := os.Create(filePath)
+	// Create output file
+	name := string(job.Resource)
+	if job.Params != nil && job.Params.Format == FormatBundle {
+		name = FormatBundle
+	}
+	out, err := s.createOutput(job, fmt.Sprintf("%s_%s_%d", name, job.ID.String()[:8], time.Now().Unix()))
 	if err != nil {
 		s.handleJobFailure(ctx, job.ID, log, "Failed to create output file: "+err.Error())
 		return err
 	}
-	defer file.Close()
+	defer out.file.Close()
+	s.recordOutput(ctx, job, out.path, log)
+	defer func() {
+		if err != nil {
+			s.discardOutput(ctx, job, out, log)
+		}
+	}()
+
+	var groupBy models.ExportGroupBy
+	withCounts := false
+	format := "ndjson"
+	var fields []string
+	var aliases map[string]string
+	if job.Params != nil {
+		groupBy = job.Params.GroupBy
+		withCounts = job.Params.WithCounts
+		fields, aliases = job.Params.Fields, job.Params.FieldAliases
+		if zipOrAvro(job.Params.Format) {
+			format = job.Params.Format
+		}
+	}
+
+	// Pin the watermark before the first row is read. A bundle always reads
+	// its resources from one snapshot, so they refer only to each other.
+	cfg := s.config.Load()
+	snapCtx, snapshot, err := s.beginSnapshot(ctx, cfg.ConsistentSnapshot || format == FormatBundle)
+	if err != nil {
+		s.handleJobFailure(ctx, job.ID, log, err.Error())
+		return err
+	}
+	defer snapshot.Close()
+	snapCtx = withExportJob(snapCtx, job.ID)
+
+	if cfg.EnforceOrder && orderCheckable(filters, groupBy) {
+		snapCtx = withOrderCheck(snapCtx)
+	}
+
+	// Register an Avro schema before writing, so consumers can decode the
+	// file by the ID in its manifest
+	var schemaSubject string
+	var schemaID int
+	if format == FormatAvro && s.registry != nil {
+		schemaSubject, schemaID, err = s.registry.Register(ctx, job.Resource)
+		if err != nil {
+			s.handleJobFailure(ctx, job.ID, log, err.Error())
+			return err
+		}
+	}
 
 	// Stream data to file
+	counter := &lineCounter{w: out.w}
+	recordCount := 0
+	var bundle *models.BundleManifest
 	var exportErr error
-	switch job.Resource {
-	case models.ResourceTypeUsers:
-		exportErr = s.StreamUsers(ctx, file, filters)
-	case models.ResourceTypeArticles:
-		exportErr = s.StreamArticles(ctx, file, filters)
-	case models.ResourceTypeComments:
-		exportErr = s.StreamComments(ctx, file, filters)
+	switch {
+	case format == FormatAvro:
+		recordCount, exportErr = s.StreamAvro(snapCtx, out.w, job.Resource, filters)
+	case format == FormatBodies:
+		recordCount, exportErr = s.StreamArticleBodies(snapCtx, out.w, filters, filepath.Dir(out.path))
+	case format == FormatBundle:
+		bundle, exportErr = s.StreamBundle(snapCtx, out.w)
+		recordCount = bundleRecordCount(bundle)
 	default:
-		exportErr = fmt.Errorf("unknown resource type: %s", job.Resource)
+		exportErr = s.streamNDJSON(snapCtx, newFieldShaper(counter, fields, aliases), job.Resource, filters, groupBy, withCounts)
+		recordCount = counter.lines
 	}
 
+	// Only a consistent snapshot counts the same records the export read.
+	// Grouped exports write a record per article rather than per comment.
+	if exportErr == nil && cfg.VerifyCount && snapshot.Consistent && groupBy != models.ExportGroupByArticle {
+		if bundle != nil {
+			for _, resource := range bundleResources {
+				if exportErr = s.verifyCount(snapCtx, resource, nil, bundle.Counts[resource]); exportErr != nil {
+					break
+				}
+			}
+		} else {
+			exportErr = s.verifyCount(snapCtx, job.Resource, filters, recordCount)
+		}
+	}
+	if exportErr == nil && bundle != nil && !bundle.Closure.Closed {
+		log.Warn().
+			Int("missing_authors", bundle.Closure.MissingAuthors).
+			Int("missing_articles", bundle.Closure.MissingArticles).
+			Int("missing_users", bundle.Closure.MissingUsers).
+			Msg("Export bundle refers to records it doesn't hold")
+	}
+	if exportErr == nil {
+		exportErr = out.finish()
+	}
 	duration := time.Since(startTime).Seconds()
 
 	if exportErr != nil {
+		if models.JobCancelled(ctx) {
+			s.discardCancelled(ctx, job, recordCount, log)
+		}
 		s.handleJobFailure(ctx, job.ID, log, exportErr.Error())
 		return exportErr
 	}
 
-	// Get file stats
-	fileInfo, _ := file.Stat()
-	recordCount := 0
-	if fileInfo != nil {
-		// Estimate records (rough count by file size / avg record size)
-		recordCount = int(fileInfo.Size() / 200) // Approximate
+	if err := s.completeExport(ctx, job, out.file, out.path, &models.ExportManifest{
+		JobID:         job.ID,
+		Resource:      job.Resource,
+		Format:        format,
+		RecordCount:   recordCount,
+		DataAsOf:      snapshot.AsOf,
+		Consistent:    snapshot.Consistent,
+		Filters:       filters,
+		GroupBy:       groupBy,
+		WithCounts:    withCounts,
+		Fields:        fields,
+		FieldAliases:  aliases,
+		Compression:   out.compression,
+		SchemaSubject: schemaSubject,
+		SchemaID:      schemaID,
+		Bundle:        bundle,
+	}, log); err != nil {
+		return err
+	}
+
+	log.Info().
+		Float64("duration_seconds", duration).
+		Str("file_path", out.path).
+		Int("records", recordCount).
+		Msg("Async export completed")
+
+	return nil
+}
+
+// streamNDJSON writes resource as NDJSON records to w
+func (s *Service) streamNDJSON(ctx context.Context, w io.Writer, resource models.ResourceType, filters *models.ExportFilters, groupBy models.ExportGroupBy, withCounts bool) error {
+	switch resource {
+	case models.ResourceTypeUsers:
+		if withCounts {
+			return s.StreamUsersWithCounts(ctx, w, filters)
+		}
+		return s.StreamUsers(ctx, w, filters)
+	case models.ResourceTypeArticles:
+		return s.StreamArticles(ctx, w, filters)
+	case models.ResourceTypeComments:
+		if groupBy == models.ExportGroupByArticle {
+			return s.StreamCommentsByArticle(ctx, w, filters)
+		}
+		return s.StreamComments(ctx, w, filters)
+	default:
+		return fmt.Errorf("unknown resource type: %s", resource)
+	}
+}
+
+// exportOutput is an export file being written, compressed when the job
+// asks for it
+type exportOutput struct {
+	file        *os.File
+	path        string
+	compression models.ExportCompression
+	zw          *CompressedWriter
+	// w is where records are written: zw when compressing, else file
+	w io.Writer
+}
+
+// createOutput creates the output file for job named name plus its
+// extensions. NDJSON is compressed with the job's compression, or
+// EXPORT_COMPRESSION when it has none; Avro files compress their own blocks
+// and bodies and bundle exports are zips.
+func (s *Service) createOutput(job *models.Job, name string) (*exportOutput, error) {
+	cfg := s.config.Load()
+	if job.Params != nil && zipOrAvro(job.Params.Format) {
+		ext := ".avro"
+		if job.Params.Format != FormatAvro {
+			ext = ".zip"
+		}
+		path := filepath.Join(cfg.OutputPath, name+ext)
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return &exportOutput{file: file, path: path, w: file}, nil
+	}
+
+	compression := models.ExportCompression(cfg.Compression)
+	level := cfg.CompressionLevel
+	if job.Params != nil && job.Params.Compression != "" {
+		compression = job.Params.Compression
+		level = job.Params.CompressionLevel
+	}
+	if compression == models.ExportCompressionNone {
+		compression = ""
+	}
+	if err := ValidateCompression(compression, level); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(cfg.OutputPath, name+".ndjson"+CompressionExtension(compression))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
 	}
+	out := &exportOutput{file: file, path: path, compression: compression, w: file}
+	if compression != "" {
+		if out.zw, err = NewCompressedWriter(file, compression, level); err != nil {
+			file.Close()
+			return nil, err
+		}
+		out.w = out.zw
+	}
+	return out, nil
+}
+
+// zipOrAvro reports whether format writes a zip or an Avro file, which
+// aren't NDJSON and aren't compressed further
+func zipOrAvro(format string) bool {
+	return format == FormatAvro || format == FormatBodies || format == FormatBundle
+}
+
+// finish writes the end of the compressed stream, if any
+func (o *exportOutput) finish() error {
+	if o.zw == nil {
+		return nil
+	}
+	if err := o.zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish compressed output: %w", err)
+	}
+	return nil
+}
 
-	// Update job with file path
+// completeExport records the output file on the job, writes its manifest,
+// copies both to remote storage when configured and marks the job completed
+func (s *Service) completeExport(ctx context.Context, job *models.Job, file *os.File, filePath string, manifest *models.ExportManifest, log zerolog.Logger) error {
+	s.setPhase(ctx, job, PhaseStore, log)
 	job.FilePath = &filePath
-	job.TotalRecords = recordCount
-	job.ProcessedRecords = recordCount
-	job.SuccessfulRecords = recordCount
+	job.DataAsOf = &manifest.DataAsOf
+	if fileInfo, _ := file.Stat(); fileInfo != nil {
+		job.FileSizeBytes = fileInfo.Size()
+	}
+
+	manifest.FileName = filepath.Base(filePath)
+	manifest.SizeBytes = job.FileSizeBytes
+	manifest.CreatedAt = time.Now().UTC()
+	if sum, err := fileSHA256(filePath); err == nil {
+		manifest.SHA256 = sum
+	} else {
+		log.Warn().Err(err).Msg("Failed to checksum export file")
+	}
+	if err := writeManifest(ManifestPath(filePath), manifest); err != nil {
+		log.Warn().Err(err).Msg("Failed to write export manifest")
+	}
+
+	if storage.IsRemote(s.store) {
+		if err := storage.PutFile(ctx, s.store, storage.ExportKey(filePath), filePath, ExportContentType(filePath)); err != nil {
+			s.handleJobFailure(ctx, job.ID, log, "Failed to store export file: "+err.Error())
+			return err
+		}
+		manifestPath := ManifestPath(filePath)
+		if err := storage.PutFile(ctx, s.store, storage.ExportKey(manifestPath), manifestPath, "application/json"); err != nil {
+			log.Warn().Err(err).Msg("Failed to store export manifest")
+		}
+	}
+
+	job.TotalRecords = manifest.RecordCount
+	job.ProcessedRecords = manifest.RecordCount
+	job.SuccessfulRecords = manifest.RecordCount
 	if err := s.jobRepo.Update(ctx, job); err != nil {
 		log.Error().Err(err).Msg("Failed to update job with file path")
 	}
 
-	if err := s.jobRepo.SetCompleted(ctx, job.ID, recordCount, 0); err != nil {
+	if err := s.jobRepo.SetCompleted(ctx, job.ID, manifest.RecordCount, 0); err != nil {
 		log.Error().Err(err).Msg("Failed to set job as completed")
 	}
+	return nil
+}
 
-	log.Info().
-		Float64("duration_seconds", duration).
-		Str("file_path", filePath).
-		Int("records", recordCount).
-		Msg("Async export completed")
+// SignedDownloadURL returns a temporary URL for an export file kept in remote
+// storage, or storage.ErrSignedURLUnsupported when files are served locally
+func (s *Service) SignedDownloadURL(ctx context.Context, filePath string) (string, error) {
+	if !storage.IsRemote(s.store) {
+		return "", storage.ErrSignedURLUnsupported
+	}
+	return s.store.SignedURL(ctx, storage.ExportKey(filePath), time.Duration(s.signedURLTTL.Load()))
+}
 
-	return nil
+// fileSHA256 returns the hex SHA-256 digest of the file at path
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeManifest(path string, manifest *models.ExportManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
 }
 
+// handleJobFailure records the job as failed, unless it was cancelled by
+// request and is already recorded as cancelled
 func (s *Service) handleJobFailure(ctx context.Context, jobID uuid.UUID, log zerolog.Logger, errMsg string) {
+	if models.JobCancelled(ctx) {
+		log.Info().Str("error", errMsg).Msg("Export job cancelled")
+		return
+	}
 	log.Error().Str("error", errMsg).Msg("Export job failed")
 	s.jobRepo.SetFailed(ctx, jobID, errMsg)
 }
 
+// discardOutput removes the partial output of an export that failed or was
+// cancelled, with any manifest written for it, and clears it from the job,
+// so a retry doesn't leave it behind or count it against the tenant's quota
+func (s *Service) discardOutput(ctx context.Context, job *models.Job, out *exportOutput, log zerolog.Logger) {
+	// Closing the compressor releases its encoder
+	if out.zw != nil {
+		out.zw.Close()
+	}
+	out.file.Close()
+	for _, path := range []string{out.path, ManifestPath(out.path)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("file_path", path).Msg("Failed to remove export file")
+		}
+	}
+	job.FilePath = nil
+	if err := s.jobRepo.ClearFilePath(context.WithoutCancel(ctx), job.ID); err != nil {
+		log.Warn().Err(err).Msg("Failed to clear export file path")
+	}
+}
+
+// discardCancelled records how many records an export cancelled while it was
+// written had written; its output is removed by discardOutput
+func (s *Service) discardCancelled(ctx context.Context, job *models.Job, records int, log zerolog.Logger) {
+	job.Status = models.JobStatusCancelled
+	if err := s.jobRepo.UpdateProgress(context.WithoutCancel(ctx), job.ID, records, records, 0); err != nil {
+		log.Warn().Err(err).Msg("Failed to record cancelled export progress")
+	}
+}
+
+// Count returns the number of records of resource an export with filters
+// reads
+func (s *Service) Count(ctx context.Context, resource models.ResourceType, filters *models.ExportFilters) (int64, error) {
+	filters, err := s.loadCohort(ctx, filters)
+	if err != nil {
+		return 0, err
+	}
+	switch resource {
+	case models.ResourceTypeUsers:
+		return s.userRepo.Count(ctx, filters)
+	case models.ResourceTypeArticles:
+		return s.articleRepo.Count(ctx, filters)
+	case models.ResourceTypeComments:
+		return s.commentRepo.Count(ctx, filters)
+	default:
+		return 0, fmt.Errorf("unknown resource type: %s", resource)
+	}
+}
+
 // GetExportFilePath returns the file path for a completed export job
 func (s *Service) GetExportFilePath(ctx context.Context, jobID uuid.UUID) (string, error) {
 	job, err := s.jobRepo.GetByID(ctx, jobID)
@@ -279,19 +949,22 @@ func (s *Service) GetExportFilePath(ctx context.Context, jobID uuid.UUID) (strin
 		return "", fmt.Errorf("job not completed")
 	}
 	if job.FilePath == nil {
-		return "", fmt.Errorf("export file not available")
+		return "", ErrExportExpired
 	}
 	return *job.FilePath, nil
 }
 
-// StreamJSON streams data as a JSON array (not NDJSON)
-func (s *Service) StreamJSON(ctx context.Context, w io.Writer, resource models.ResourceType, filters *models.ExportFilters) error {
+// StreamJSON streams data as a JSON array (not NDJSON) and returns the
+// number of records written
+func (s *Service) StreamJSON(ctx context.Context, w io.Writer, resource models.ResourceType, filters *models.ExportFilters) (int, error) {
+	cfg := s.config.Load()
 	// Write opening bracket
 	if _, err := w.Write([]byte("[\n")); err != nil {
-		return err
+		return 0, err
 	}
 
 	first := true
+	count := 0
 
 	writeRecord := func(data []byte) error {
 		if !first {
@@ -303,13 +976,14 @@ func (s *Service) StreamJSON(ctx context.Context, w io.Writer, resource models.R
 		if _, err := w.Write(data); err != nil {
 			return err
 		}
+		count++
 		return nil
 	}
 
 	var err error
 	switch resource {
 	case models.ResourceTypeUsers:
-		err = s.userRepo.GetAllWithCursor(ctx, filters, s.config.BatchSize, func(users []*models.User) error {
+		err = s.userRepo.GetAllWithCursor(ctx, filters, cfg.BatchSize, func(users []*models.User) error {
 			for _, user := range users {
 				data, e := json.Marshal(user)
 				if e != nil {
@@ -319,10 +993,11 @@ func (s *Service) StreamJSON(ctx context.Context, w io.Writer, resource models.R
 					return e
 				}
 			}
+			flushBatch(w)
 			return nil
 		})
 	case models.ResourceTypeArticles:
-		err = s.articleRepo.GetAllWithCursor(ctx, filters, s.config.BatchSize, func(articles []*models.Article) error {
+		err = s.articleRepo.GetAllWithCursor(ctx, filters, cfg.BatchSize, func(articles []*models.Article) error {
 			for _, article := range articles {
 				data, e := json.Marshal(article)
 				if e != nil {
@@ -332,10 +1007,11 @@ func (s *Service) StreamJSON(ctx context.Context, w io.Writer, resource models.R
 					return e
 				}
 			}
+			flushBatch(w)
 			return nil
 		})
 	case models.ResourceTypeComments:
-		err = s.commentRepo.GetAllWithCursor(ctx, filters, s.config.BatchSize, func(comments []*models.Comment) error {
+		err = s.commentRepo.GetAllWithCursor(ctx, filters, cfg.BatchSize, func(comments []*models.Comment) error {
 			for _, comment := range comments {
 				data, e := json.Marshal(comment)
 				if e != nil {
@@ -345,18 +1021,93 @@ func (s *Service) StreamJSON(ctx context.Context, w io.Writer, resource models.R
 					return e
 				}
 			}
+			flushBatch(w)
 			return nil
 		})
 	}
 
 	if err != nil {
-		return err
+		return count, err
 	}
 
 	// Write closing bracket
 	if _, err := w.Write([]byte("\n]")); err != nil {
-		return err
+		return count, err
 	}
 
-	return nil
+	return count, nil
+}
+
+// FormatAvro is the export format writing Avro object container files
+const FormatAvro = "avro"
+
+// StreamAvro writes resource as an Avro object container file with the
+// resource's schema, one block per database batch, and returns the number of
+// records written
+func (s *Service) StreamAvro(ctx context.Context, w io.Writer, resource models.ResourceType, filters *models.ExportFilters) (int, error) {
+	cfg := s.config.Load()
+	schema, err := avro.Schema(resource)
+	if err != nil {
+		return 0, err
+	}
+	aw, err := avro.NewWriter(w, schema, cfg.AvroCodec, cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	order := exportOrder(ctx)
+	count := 0
+	endBatch := func() error {
+		if err := aw.Flush(); err != nil {
+			return err
+		}
+		flushBatch(w)
+		return nil
+	}
+
+	switch resource {
+	case models.ResourceTypeUsers:
+		err = s.userRepo.GetAllWithCursor(ctx, filters, cfg.BatchSize, func(users []*models.User) error {
+			for _, user := range users {
+				if err := order.next(user.CreatedAt, user.ID); err != nil {
+					return err
+				}
+				if err := aw.Append(func(buf []byte) []byte { return avro.AppendUser(buf, user) }); err != nil {
+					return err
+				}
+				count++
+			}
+			return endBatch()
+		})
+	case models.ResourceTypeArticles:
+		err = s.articleRepo.GetAllWithCursor(ctx, filters, cfg.BatchSize, func(articles []*models.Article) error {
+			for _, article := range articles {
+				if err := order.next(article.CreatedAt, article.ID); err != nil {
+					return err
+				}
+				if err := aw.Append(func(buf []byte) []byte { return avro.AppendArticle(buf, article) }); err != nil {
+					return err
+				}
+				count++
+			}
+			return endBatch()
+		})
+	case models.ResourceTypeComments:
+		err = s.commentRepo.GetAllWithCursor(ctx, filters, cfg.BatchSize, func(comments []*models.Comment) error {
+			for _, comment := range comments {
+				if err := order.next(comment.CreatedAt, comment.ID); err != nil {
+					return err
+				}
+				if err := aw.Append(func(buf []byte) []byte { return avro.AppendComment(buf, comment) }); err != nil {
+					return err
+				}
+				count++
+			}
+			return endBatch()
+		})
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, aw.Close()
 }
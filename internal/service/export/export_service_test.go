@@ -0,0 +1,317 @@
+package exportservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func sampleUser() *models.User {
+	return &models.User{
+		ID:        uuid.MustParse("5864905b-ec8c-4fa6-8ba7-545d13f29b4e"),
+		Email:     "user@example.com",
+		Name:      "Test <User> & Co",
+		Role:      "admin",
+		Active:    true,
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRecordEncoder_MatchesMarshal(t *testing.T) {
+	user := sampleUser()
+	want, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := newRecordEncoder(&out).Encode(user); err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+	if out.String() != string(want)+"\n" {
+		t.Errorf("Encode() = %q, want %q", out.String(), string(want)+"\n")
+	}
+}
+
+func TestRecordEncoder_MarshalErrorWritesNothing(t *testing.T) {
+	var out bytes.Buffer
+	enc := newRecordEncoder(&out)
+
+	if err := enc.Encode(map[string]interface{}{"bad": make(chan int)}); err == nil {
+		t.Fatal("Encode() expected marshal error")
+	}
+	if enc.WriteFailed() {
+		t.Error("WriteFailed() = true after a marshal error")
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %q after a marshal error", out.String())
+	}
+}
+
+func BenchmarkEncodeUsers(b *testing.B) {
+	user := sampleUser()
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, _ := json.Marshal(user)
+			io.Discard.Write(append(data, '\n'))
+		}
+	})
+
+	b.Run("encoder", func(b *testing.B) {
+		b.ReportAllocs()
+		enc := newRecordEncoder(io.Discard)
+		for i := 0; i < b.N; i++ {
+			enc.Encode(user)
+		}
+	})
+}
+
+// testMetrics is shared as a collector registers itself once per process
+var testMetrics = metrics.NewCollector()
+
+func newTestService(db *memory.DB) *Service {
+	return NewService(
+		db,
+		memory.NewUserRepository(db),
+		memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db),
+		memory.NewTombstoneRepository(db),
+		memory.NewJobRepository(db),
+		nil,
+		time.Minute,
+		testMetrics,
+		zerolog.Nop(),
+		config.ExportConfig{BatchSize: 2, ConsistentSnapshot: true},
+	)
+}
+
+func TestStreamUsers_FiltersInCreationOrder(t *testing.T) {
+	db := memory.NewDB()
+	users := memory.NewUserRepository(db)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, u := range []struct {
+		email string
+		role  string
+	}{
+		{"c@example.com", "admin"},
+		{"a@example.com", "reader"},
+		{"b@example.com", "admin"},
+		{"d@example.com", "admin"},
+	} {
+		user := &models.User{Email: u.email, Name: u.email, Role: u.role, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := users.Create(ctx, user); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	svc := newTestService(db)
+	snapCtx, snap, err := svc.BeginSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("BeginSnapshot() error: %v", err)
+	}
+	defer snap.Close()
+
+	role := "admin"
+	var out bytes.Buffer
+	if err := svc.StreamUsers(snapCtx, &out, &models.ExportFilters{Role: &role}); err != nil {
+		t.Fatalf("StreamUsers() error: %v", err)
+	}
+
+	var emails []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var user models.User
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			t.Fatalf("Unmarshal(%q) error: %v", line, err)
+		}
+		emails = append(emails, user.Email)
+	}
+	if got := strings.Join(emails, ","); got != "c@example.com,b@example.com,d@example.com" {
+		t.Errorf("exported emails = %s, want the admins oldest first", got)
+	}
+}
+
+func TestStreamArticles_PublicationAndTagFilters(t *testing.T) {
+	db := memory.NewDB()
+	articles := memory.NewArticleRepository(db)
+	ctx := context.Background()
+	author := sampleUser()
+	if err := memory.NewUserRepository(db).Create(ctx, author); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	published := func(day int) *time.Time {
+		at := time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC)
+		return &at
+	}
+	for _, a := range []struct {
+		slug        string
+		tags        string
+		publishedAt *time.Time
+	}{
+		{"early", `["go"]`, published(1)},
+		{"untagged", `[]`, published(10)},
+		{"tagged", `["go","sql"]`, published(15)},
+		{"draft", `["go"]`, nil},
+		{"late", `["sql"]`, published(30)},
+	} {
+		article := &models.Article{Slug: a.slug, Title: a.slug, Body: a.slug, AuthorID: author.ID, Status: "published",
+			Tags: json.RawMessage(a.tags), PublishedAt: a.publishedAt}
+		if err := articles.Create(ctx, article); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	svc := newTestService(db)
+	hasTags, noTags := true, false
+	tests := []struct {
+		name    string
+		filters *models.ExportFilters
+		want    []string
+	}{
+		{"published range", &models.ExportFilters{PublishedAfter: published(5), PublishedBefore: published(20)}, []string{"tagged", "untagged"}},
+		{"published after", &models.ExportFilters{PublishedAfter: published(15)}, []string{"tagged", "late"}},
+		{"has tags", &models.ExportFilters{HasTags: &hasTags, PublishedBefore: published(20)}, []string{"early", "tagged"}},
+		{"no tags", &models.ExportFilters{HasTags: &noTags}, []string{"untagged"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := svc.StreamArticles(ctx, &out, tt.filters); err != nil {
+				t.Fatalf("StreamArticles() error: %v", err)
+			}
+			got := map[string]bool{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				var article models.Article
+				if err := json.Unmarshal([]byte(line), &article); err != nil {
+					t.Fatalf("Unmarshal(%q) error: %v", line, err)
+				}
+				got[article.Slug] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("exported %v, want %v", got, tt.want)
+			}
+			for _, slug := range tt.want {
+				if !got[slug] {
+					t.Errorf("exported %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamCommentsByArticle_GroupsAcrossBatches(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	author := sampleUser()
+	if err := memory.NewUserRepository(db).Create(ctx, author); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	articles := memory.NewArticleRepository(db)
+	var articleIDs []uuid.UUID
+	for _, slug := range []string{"first", "second", "silent"} {
+		article := &models.Article{Slug: slug, Title: slug, Body: slug, AuthorID: author.ID, Status: "published", Tags: json.RawMessage(`[]`)}
+		if err := articles.Create(ctx, article); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		articleIDs = append(articleIDs, article.ID)
+	}
+
+	// Three comments on the first article span the batches of two
+	comments := memory.NewCommentRepository(db)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, articleID := range []uuid.UUID{articleIDs[0], articleIDs[1], articleIDs[0], articleIDs[0]} {
+		comment := &models.Comment{ArticleID: articleID, UserID: author.ID, Body: fmt.Sprintf("comment %d", i), CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := comments.Create(ctx, comment); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := newTestService(db).StreamCommentsByArticle(ctx, &out, nil); err != nil {
+		t.Fatalf("StreamCommentsByArticle() error: %v", err)
+	}
+
+	got := map[uuid.UUID][]string{}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for _, line := range lines {
+		var record models.ArticleComments
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Unmarshal(%q) error: %v", line, err)
+		}
+		for _, comment := range record.Comments {
+			got[record.ArticleID] = append(got[record.ArticleID], comment.Body)
+		}
+	}
+	if len(lines) != 2 {
+		t.Fatalf("exported %d records, want one per commented article: %s", len(lines), out.String())
+	}
+	if b := strings.Join(got[articleIDs[0]], ","); b != "comment 0,comment 2,comment 3" {
+		t.Errorf("first article comments = %s, want all three oldest first", b)
+	}
+	if b := strings.Join(got[articleIDs[1]], ","); b != "comment 1" {
+		t.Errorf("second article comments = %s", b)
+	}
+}
+
+func TestStreamUsersWithCounts(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	users := memory.NewUserRepository(db)
+	writer, reader := sampleUser(), &models.User{Email: "reader@example.com", Name: "Reader", Role: "reader"}
+	for _, user := range []*models.User{writer, reader} {
+		if err := users.Create(ctx, user); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	articles := memory.NewArticleRepository(db)
+	comments := memory.NewCommentRepository(db)
+	for _, slug := range []string{"one", "two"} {
+		article := &models.Article{Slug: slug, Title: slug, Body: slug, AuthorID: writer.ID, Status: "published", Tags: json.RawMessage(`[]`)}
+		if err := articles.Create(ctx, article); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		for _, user := range []*models.User{writer, reader} {
+			if err := comments.Create(ctx, &models.Comment{ArticleID: article.ID, UserID: user.ID, Body: "nice"}); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := newTestService(db).StreamUsersWithCounts(ctx, &out, nil); err != nil {
+		t.Fatalf("StreamUsersWithCounts() error: %v", err)
+	}
+
+	want := map[string][2]int64{"user@example.com": {2, 2}, "reader@example.com": {0, 2}}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("exported %d users, want %d", len(lines), len(want))
+	}
+	for _, line := range lines {
+		var user models.UserWithCounts
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			t.Fatalf("Unmarshal(%q) error: %v", line, err)
+		}
+		if got := [2]int64{user.ArticleCount, user.CommentCount}; got != want[user.Email] {
+			t.Errorf("%s counts = %v, want %v", user.Email, got, want[user.Email])
+		}
+		if !strings.Contains(line, `"article_count":`) || !strings.Contains(line, `"email":`) {
+			t.Errorf("record %s does not carry the user fields alongside the counts", line)
+		}
+	}
+}
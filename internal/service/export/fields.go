@@ -0,0 +1,172 @@
+package exportservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// recordFields are the top-level fields of each resource's export records
+var recordFields = map[models.ResourceType][]string{
+	models.ResourceTypeUsers:    {"id", "email", "name", "role", "active", "placeholder", "import_job_id", "created_at", "updated_at"},
+	models.ResourceTypeArticles: {"id", "slug", "title", "body", "author_id", "tags", "published_at", "status", "lang", "import_job_id", "created_at", "updated_at"},
+	models.ResourceTypeComments: {"id", "article_id", "user_id", "body", "lang", "import_job_id", "created_at", "updated_at"},
+}
+
+// RecordFields returns the top-level fields of the records an export of
+// resource writes, with counts for users or grouped by article for comments
+func RecordFields(resource models.ResourceType, groupBy models.ExportGroupBy, withCounts bool) []string {
+	switch {
+	case groupBy == models.ExportGroupByArticle:
+		return []string{"article_id", "comments"}
+	case withCounts:
+		return append(slices.Clone(recordFields[resource]), "article_count", "comment_count")
+	}
+	return recordFields[resource]
+}
+
+// ValidateFieldShape checks the fields an export keeps and the names it
+// renames them to. Fields and alias keys must be fields of the records,
+// aliases may only rename kept fields, and no two fields may be written
+// under the same name.
+func ValidateFieldShape(resource models.ResourceType, groupBy models.ExportGroupBy, withCounts bool, fields []string, aliases map[string]string) error {
+	known := RecordFields(resource, groupBy, withCounts)
+	for _, field := range fields {
+		if !slices.Contains(known, field) {
+			return fmt.Errorf("unknown field %q; %s records have %v", field, resource, known)
+		}
+	}
+	kept := known
+	if len(fields) > 0 {
+		kept = fields
+	}
+
+	names := make(map[string]string, len(kept))
+	for _, field := range kept {
+		name := field
+		if alias, ok := aliases[field]; ok {
+			name = alias
+		}
+		if other, taken := names[name]; taken && other != field {
+			return fmt.Errorf("fields %s and %s would both be written as %q", other, field, name)
+		}
+		names[name] = field
+	}
+	for field, alias := range aliases {
+		if alias == "" {
+			return fmt.Errorf("field_aliases must not rename %s to an empty name", field)
+		}
+		if !slices.Contains(kept, field) {
+			if slices.Contains(known, field) {
+				return fmt.Errorf("field_aliases renames %s, which fields leaves out", field)
+			}
+			return fmt.Errorf("field_aliases renames unknown field %q; %s records have %v", field, resource, known)
+		}
+	}
+	return nil
+}
+
+// fieldShaper keeps the chosen top-level fields of each NDJSON record
+// written through it, in the order chosen, and renames them by their
+// aliases. Other lines, such as blank keepalives, pass through unchanged.
+type fieldShaper struct {
+	w       io.Writer
+	fields  []string
+	aliases map[string]string
+	pending []byte
+	out     bytes.Buffer
+}
+
+// newFieldShaper returns a writer shaping the records written to w, or w
+// itself when there is nothing to change
+func newFieldShaper(w io.Writer, fields []string, aliases map[string]string) io.Writer {
+	if len(fields) == 0 && len(aliases) == 0 {
+		return w
+	}
+	return &fieldShaper{w: w, fields: fields, aliases: aliases}
+}
+
+// Write shapes each complete line of p, holding back a partial line until
+// the rest of it arrives
+func (f *fieldShaper) Write(p []byte) (int, error) {
+	f.pending = append(f.pending, p...)
+	end := bytes.LastIndexByte(f.pending, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	f.out.Reset()
+	for _, line := range bytes.SplitAfter(f.pending[:end+1], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := f.shape(line); err != nil {
+			return 0, err
+		}
+	}
+	f.pending = append(f.pending[:0], f.pending[end+1:]...)
+	if _, err := f.w.Write(f.out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// shape appends line to f.out with its fields kept and renamed
+func (f *fieldShaper) shape(line []byte) error {
+	record := bytes.TrimSpace(line)
+	if len(record) == 0 || record[0] != '{' {
+		f.out.Write(line)
+		return nil
+	}
+
+	// Read the fields in order, so records without a field list keep the
+	// order they were written in
+	var names []string
+	values := make(map[string]json.RawMessage)
+	dec := json.NewDecoder(bytes.NewReader(record))
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to read export record: %w", err)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read export record: %w", err)
+		}
+		name, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to read export record: %w", err)
+		}
+		names = append(names, name)
+		values[name] = value
+	}
+	if len(f.fields) > 0 {
+		names = f.fields
+	}
+
+	f.out.WriteByte('{')
+	first := true
+	for _, name := range names {
+		value, ok := values[name]
+		if !ok {
+			// Left out of this record, such as an unset omitempty field
+			continue
+		}
+		if !first {
+			f.out.WriteByte(',')
+		}
+		first = false
+		if alias, ok := f.aliases[name]; ok {
+			name = alias
+		}
+		key, _ := json.Marshal(name)
+		f.out.Write(key)
+		f.out.WriteByte(':')
+		f.out.Write(value)
+	}
+	f.out.WriteString("}\n")
+	return nil
+}
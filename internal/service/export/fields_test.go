@@ -0,0 +1,144 @@
+package exportservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestRecordFields_MatchRecords(t *testing.T) {
+	lang, job := "en", uuid.New()
+	now := sampleUser().CreatedAt
+	records := map[models.ResourceType]interface{}{
+		models.ResourceTypeUsers:    &models.User{Placeholder: true, ImportJobID: &job},
+		models.ResourceTypeArticles: &models.Article{Tags: json.RawMessage(`[]`), PublishedAt: &now, Lang: &lang, ImportJobID: &job},
+		models.ResourceTypeComments: &models.Comment{Lang: &lang, ImportJobID: &job},
+	}
+	for resource, record := range records {
+		data, _ := json.Marshal(record)
+		var fields map[string]json.RawMessage
+		json.Unmarshal(data, &fields)
+		for _, field := range RecordFields(resource, "", false) {
+			delete(fields, field)
+		}
+		if len(fields) > 0 {
+			t.Errorf("%s records have fields missing from RecordFields: %v", resource, fields)
+		}
+	}
+}
+
+func TestFieldShaper(t *testing.T) {
+	var out bytes.Buffer
+	w := newFieldShaper(&out, []string{"email", "id", "placeholder"}, map[string]string{"email": "emailAddress"})
+
+	// A record split across writes, a keepalive and a record without the
+	// omitempty placeholder field
+	w.Write([]byte(`{"id":"1","email":"a@example.com",`))
+	w.Write([]byte("\"placeholder\":true,\"name\":\"A\"}\n\n{\"id\":\"2\",\"email\":\"b@example.com\",\"name\":\"B\"}\n"))
+
+	want := `{"emailAddress":"a@example.com","id":"1","placeholder":true}` + "\n\n" +
+		`{"emailAddress":"b@example.com","id":"2"}` + "\n"
+	if out.String() != want {
+		t.Errorf("shaped records = %q, want %q", out.String(), want)
+	}
+
+	// Aliases alone keep every field in its order
+	out.Reset()
+	newFieldShaper(&out, nil, map[string]string{"name": "fullName"}).Write([]byte(`{"id":"1","name":"A","role":"admin"}` + "\n"))
+	if want := `{"id":"1","fullName":"A","role":"admin"}` + "\n"; out.String() != want {
+		t.Errorf("aliased record = %q, want %q", out.String(), want)
+	}
+
+	if w := newFieldShaper(&out, nil, nil); w != &out {
+		t.Error("newFieldShaper() wrapped the writer with nothing to change")
+	}
+}
+
+func TestValidateFieldShape(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		aliases map[string]string
+		ok      bool
+	}{
+		{"aliases alone", nil, map[string]string{"email": "emailAddress"}, true},
+		{"aliases of kept fields", []string{"id", "email"}, map[string]string{"email": "emailAddress"}, true},
+		{"swapped names", []string{"id", "email"}, map[string]string{"id": "email", "email": "id"}, true},
+		{"unknown field", []string{"id", "emailAddress"}, nil, false},
+		{"unknown alias key", nil, map[string]string{"emailAddress": "email"}, false},
+		{"alias of a dropped field", []string{"id"}, map[string]string{"email": "emailAddress"}, false},
+		{"alias clashing with a field", nil, map[string]string{"email": "name"}, false},
+		{"two aliases to one name", nil, map[string]string{"email": "contact", "name": "contact"}, false},
+		{"empty alias", nil, map[string]string{"email": ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFieldShape(models.ResourceTypeUsers, "", false, tt.fields, tt.aliases)
+			if (err == nil) != tt.ok {
+				t.Errorf("ValidateFieldShape() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+
+	if err := ValidateFieldShape(models.ResourceTypeUsers, "", true, []string{"email", "article_count"}, nil); err != nil {
+		t.Errorf("ValidateFieldShape() with counts error: %v", err)
+	}
+	if err := ValidateFieldShape(models.ResourceTypeComments, models.ExportGroupByArticle, false, []string{"comments"}, map[string]string{"article_id": "post"}); err == nil {
+		t.Error("ValidateFieldShape() allowed renaming a field grouped exports leave out")
+	}
+}
+
+func TestProcessAsyncExport_FieldAliases(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	if err := memory.NewUserRepository(db).Create(ctx, sampleUser()); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	svc := newTestService(db)
+	svc.config.Load().OutputPath = t.TempDir()
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: &models.JobParams{
+		Fields:       []string{"id", "email", "name"},
+		FieldAliases: map[string]string{"email": "emailAddress", "name": "fullName"},
+	}}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessAsyncExport(ctx, job, nil); err != nil {
+		t.Fatalf("ProcessAsyncExport() error: %v", err)
+	}
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.FilePath == nil || stored.SuccessfulRecords != 1 {
+		t.Fatalf("job = %+v, want 1 record in a file", stored)
+	}
+	data, err := os.ReadFile(*stored.FilePath)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	want := `{"id":"5864905b-ec8c-4fa6-8ba7-545d13f29b4e","emailAddress":"user@example.com","fullName":"Test \u003cUser\u003e \u0026 Co"}`
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("export = %s, want %s", got, want)
+	}
+
+	manifest, err := os.ReadFile(ManifestPath(*stored.FilePath))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	var m models.ExportManifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if !slices.Equal(m.Fields, []string{"id", "email", "name"}) || m.FieldAliases["email"] != "emailAddress" {
+		t.Errorf("manifest fields %v, aliases %v; want the job's", m.Fields, m.FieldAliases)
+	}
+}
@@ -0,0 +1,77 @@
+package exportservice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// Exports read records in one global order, by creation time and then ID, so
+// every record has a single place in an export. A file split into parts by
+// ranges of this key can't repeat a record across two of them.
+
+// orderCheck fails an export whose records don't follow the ordering key.
+// Each key must come strictly after the last, so a record read twice fails
+// the check too.
+type orderCheck struct {
+	created time.Time
+	id      uuid.UUID
+	started bool
+}
+
+// orderCheckKey is the context key for the orderCheck of an export
+type orderCheckKey struct{}
+
+// withOrderCheck returns a context whose streams check the order of the
+// records they write
+func withOrderCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, orderCheckKey{}, &orderCheck{})
+}
+
+// exportOrder returns the orderCheck of ctx, or nil when its export isn't
+// checked
+func exportOrder(ctx context.Context) *orderCheck {
+	order, _ := ctx.Value(orderCheckKey{}).(*orderCheck)
+	return order
+}
+
+// orderCheckable reports whether an export with filters and groupBy reads
+// its records by the ordering key. Exports grouped by article are ordered by
+// article, and cohorts may be read in chunks each ordered on its own.
+func orderCheckable(filters *models.ExportFilters, groupBy models.ExportGroupBy) bool {
+	if groupBy == models.ExportGroupByArticle {
+		return false
+	}
+	return filters == nil || len(filters.IDs)+len(filters.Emails) == 0
+}
+
+// next records the key of the next record written, failing when it doesn't
+// come after the last. A nil check accepts every record.
+func (o *orderCheck) next(created time.Time, id uuid.UUID) error {
+	if o == nil {
+		return nil
+	}
+	if o.started && !created.After(o.created) && (!created.Equal(o.created) || bytes.Compare(id[:], o.id[:]) <= 0) {
+		return fmt.Errorf("export out of order: record %s created at %s follows record %s created at %s",
+			id, created.Format(time.RFC3339Nano), o.id, o.created.Format(time.RFC3339Nano))
+	}
+	o.created, o.id, o.started = created, id, true
+	return nil
+}
+
+// verifyCount checks an export wrote every record of its snapshot, and no
+// more, by counting them again within the snapshot
+func (s *Service) verifyCount(ctx context.Context, resource models.ResourceType, filters *models.ExportFilters, written int) error {
+	want, err := s.Count(ctx, resource, filters)
+	if err != nil {
+		return fmt.Errorf("failed to verify export count: %w", err)
+	}
+	if int64(written) != want {
+		return fmt.Errorf("export wrote %d records but its snapshot has %d", written, want)
+	}
+	return nil
+}
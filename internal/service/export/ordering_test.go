@@ -0,0 +1,93 @@
+package exportservice
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestOrderCheck(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	low := uuid.MustParse("00000000-0000-4000-8000-000000000001")
+	high := uuid.MustParse("ffffffff-0000-4000-8000-000000000001")
+
+	var none *orderCheck
+	if err := none.next(base, low); err != nil {
+		t.Errorf("nil check next() error: %v", err)
+	}
+
+	order := &orderCheck{}
+	for _, key := range []struct {
+		created time.Time
+		id      uuid.UUID
+	}{{base, high}, {base.Add(time.Second), low}, {base.Add(time.Second), high}} {
+		if err := order.next(key.created, key.id); err != nil {
+			t.Fatalf("next(%s, %s) error: %v", key.created, key.id, err)
+		}
+	}
+	if err := order.next(base.Add(time.Second), high); err == nil {
+		t.Error("next() accepted a record written twice")
+	}
+	if err := order.next(base, high); err == nil {
+		t.Error("next() accepted an older record")
+	}
+}
+
+// miscountedUsers counts one user more than it reads
+type miscountedUsers struct {
+	repository.UserRepository
+}
+
+func (m miscountedUsers) Count(ctx context.Context, filters *models.ExportFilters) (int64, error) {
+	n, err := m.UserRepository.Count(ctx, filters)
+	return n + 1, err
+}
+
+func TestProcessAsyncExport_VerifiesCount(t *testing.T) {
+	db := memory.NewDB()
+	ctx := context.Background()
+	if err := memory.NewUserRepository(db).Create(ctx, sampleUser()); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	jobs := memory.NewJobRepository(db)
+
+	run := func(svc *Service) *models.Job {
+		t.Helper()
+		cfg := svc.config.Load()
+		cfg.OutputPath = t.TempDir()
+		cfg.VerifyCount = true
+		cfg.EnforceOrder = true
+		job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		svc.ProcessAsyncExport(ctx, job, nil)
+		stored, _ := jobs.GetByID(ctx, job.ID)
+		return stored
+	}
+
+	if job := run(newTestService(db)); job.Status != models.JobStatusCompleted {
+		t.Errorf("verified export status = %s, want completed", job.Status)
+	}
+
+	svc := newTestService(db)
+	svc.userRepo = miscountedUsers{svc.userRepo}
+	job := run(svc)
+	if job.Status != models.JobStatusFailed || job.ErrorMessage == nil || !strings.Contains(*job.ErrorMessage, "snapshot has 2") {
+		t.Errorf("miscounted export status = %s, want failed on the count", job.Status)
+	}
+	// The failed run's partial output is removed, so a retry leaves nothing behind
+	if job.FilePath != nil {
+		t.Errorf("failed export file path = %s, want none", *job.FilePath)
+	}
+	if entries, _ := os.ReadDir(svc.config.Load().OutputPath); len(entries) != 0 {
+		t.Errorf("failed export left %d files", len(entries))
+	}
+}
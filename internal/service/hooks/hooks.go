@@ -0,0 +1,100 @@
+// Package hooks lets code that embeds the import and export services react to
+// job lifecycle events, such as invalidating caches or reindexing search,
+// without changing the services themselves.
+package hooks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// Hooks receives job lifecycle events. Hooks run synchronously on the worker
+// processing the job, so slow work should be handed off elsewhere.
+type Hooks interface {
+	// OnJobStart is called once a job has been marked as processing
+	OnJobStart(ctx context.Context, job *models.Job)
+	// OnBatchInserted is called after a batch of imported records is written
+	// to the resource table, with the IDs of the records in the batch
+	OnBatchInserted(ctx context.Context, job *models.Job, resource models.ResourceType, ids []uuid.UUID)
+	// OnJobComplete is called when a job finishes. err is nil when the job
+	// completed and holds the failure otherwise.
+	OnJobComplete(ctx context.Context, job *models.Job, err error)
+	// OnJobPaused is called instead of OnJobComplete when the circuit
+	// breaker pauses an import, with the failure that tripped it
+	OnJobPaused(ctx context.Context, job *models.Job, err error)
+	// OnValidationError is called with the rows an import rejected
+	OnValidationError(ctx context.Context, job *models.Job, errs []*errors.ValidationError)
+}
+
+// Base implements Hooks with no-ops. Embed it to implement only the events
+// you need.
+type Base struct{}
+
+func (Base) OnJobStart(context.Context, *models.Job)                                        {}
+func (Base) OnBatchInserted(context.Context, *models.Job, models.ResourceType, []uuid.UUID) {}
+func (Base) OnJobComplete(context.Context, *models.Job, error)                              {}
+func (Base) OnJobPaused(context.Context, *models.Job, error)                                {}
+func (Base) OnValidationError(context.Context, *models.Job, []*errors.ValidationError)      {}
+
+// Registry holds registered hooks and calls each of them, in registration
+// order, for every event. The zero value is ready to use.
+type Registry struct {
+	mu    sync.RWMutex
+	hooks []Hooks
+}
+
+// Register adds h to the registry
+func (r *Registry) Register(h Hooks) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, h)
+}
+
+func (r *Registry) registered() []Hooks {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.hooks
+}
+
+// OnJobStart calls OnJobStart on every registered hook
+func (r *Registry) OnJobStart(ctx context.Context, job *models.Job) {
+	for _, h := range r.registered() {
+		h.OnJobStart(ctx, job)
+	}
+}
+
+// OnBatchInserted calls OnBatchInserted on every registered hook
+func (r *Registry) OnBatchInserted(ctx context.Context, job *models.Job, resource models.ResourceType, ids []uuid.UUID) {
+	for _, h := range r.registered() {
+		h.OnBatchInserted(ctx, job, resource, ids)
+	}
+}
+
+// OnJobComplete calls OnJobComplete on every registered hook
+func (r *Registry) OnJobComplete(ctx context.Context, job *models.Job, err error) {
+	for _, h := range r.registered() {
+		h.OnJobComplete(ctx, job, err)
+	}
+}
+
+// OnJobPaused calls OnJobPaused on every registered hook
+func (r *Registry) OnJobPaused(ctx context.Context, job *models.Job, err error) {
+	for _, h := range r.registered() {
+		h.OnJobPaused(ctx, job, err)
+	}
+}
+
+// OnValidationError calls OnValidationError on every registered hook. It does
+// nothing when errs is empty.
+func (r *Registry) OnValidationError(ctx context.Context, job *models.Job, errs []*errors.ValidationError) {
+	if len(errs) == 0 {
+		return
+	}
+	for _, h := range r.registered() {
+		h.OnValidationError(ctx, job, errs)
+	}
+}
@@ -0,0 +1,55 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+type recorder struct {
+	Base
+	name   string
+	events *[]string
+}
+
+func (r recorder) OnJobStart(ctx context.Context, job *models.Job) {
+	*r.events = append(*r.events, r.name+":start")
+}
+
+func (r recorder) OnBatchInserted(ctx context.Context, job *models.Job, resource models.ResourceType, ids []uuid.UUID) {
+	*r.events = append(*r.events, fmt.Sprintf("%s:batch:%s:%d", r.name, resource, len(ids)))
+}
+
+func (r recorder) OnValidationError(ctx context.Context, job *models.Job, errs []*errors.ValidationError) {
+	*r.events = append(*r.events, fmt.Sprintf("%s:invalid:%d", r.name, len(errs)))
+}
+
+func TestRegistry_CallsHooksInOrder(t *testing.T) {
+	var events []string
+	var reg Registry
+	reg.Register(recorder{name: "a", events: &events})
+	reg.Register(recorder{name: "b", events: &events})
+
+	ctx := context.Background()
+	job := &models.Job{}
+	reg.OnJobStart(ctx, job)
+	reg.OnBatchInserted(ctx, job, models.ResourceTypeUsers, []uuid.UUID{uuid.New(), uuid.New(), uuid.New()})
+	reg.OnValidationError(ctx, job, nil)
+	reg.OnValidationError(ctx, job, []*errors.ValidationError{{Code: errors.ErrCodeInvalidEmail}})
+	reg.OnJobComplete(ctx, job, nil)
+
+	want := []string{"a:start", "b:start", "a:batch:users:3", "b:batch:users:3", "a:invalid:1", "b:invalid:1"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestRegistry_ZeroValueIsUsable(t *testing.T) {
+	var reg Registry
+	reg.OnJobStart(context.Background(), &models.Job{})
+	reg.OnJobComplete(context.Background(), &models.Job{}, nil)
+}
@@ -0,0 +1,566 @@
+package importservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	"github.com/rohit/bulk-import-export/internal/service/validation"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// PlaceholderEmailDomain is the domain of the emails given to placeholder
+// authors. The .invalid TLD is reserved, so the addresses never deliver.
+const PlaceholderEmailDomain = "placeholder.invalid"
+
+// articleStages implements the pipeline stages for article imports
+type articleStages struct {
+	encoding    parsers.Encoding
+	maxLineSize int
+	flattener   *parsers.Flattener // nil unless the job maps nested fields
+	validator   *validation.ArticleValidator
+	sanitize    bool              // clean bodies before validating them
+	detectLang  bool              // tag rows with the language of their body
+	upsertKey   models.UpsertKey  // id or slug
+	onConflict  models.OnConflict // what rows with another article's slug do
+	// createAuthors creates placeholder users for missing authors; analyze
+	// only counts them
+	createAuthors bool
+	analyze       bool
+	placeholders  int
+	stagingRepo   repository.StagingRepository
+	articleRepo   repository.ArticleRepository
+	userRepo      repository.UserRepository
+	buffer        *memoryBuffer[repository.StagingArticle]
+	log           zerolog.Logger // sampled, for per-row warnings
+}
+
+func (s *Service) processArticlesImport(ctx context.Context, job *models.Job, file *os.File, log zerolog.Logger) error {
+	cfg := s.config.Load()
+	flatten, err := flattener(job)
+	if err != nil {
+		return err
+	}
+	stages := &articleStages{
+		encoding:      s.encoding,
+		maxLineSize:   s.maxLineSize(),
+		flattener:     flatten,
+		validator:     s.validator.Article,
+		sanitize:      job.Params != nil && job.Params.Sanitize,
+		detectLang:    s.detectLang(job),
+		upsertKey:     upsertKey(job),
+		onConflict:    onConflict(job),
+		createAuthors: job.Params != nil && job.Params.CreateMissingAuthors,
+		analyze:       job.Params != nil && job.Params.Analyze,
+		stagingRepo:   s.stagingRepo,
+		articleRepo:   s.articleRepo,
+		userRepo:      s.userRepo,
+		buffer:        newMemoryBuffer[repository.StagingArticle](cfg.FastPathMaxRows, cfg.BatchSize),
+		log:           logger.Hot(log),
+	}
+	return runPipeline(ctx, s, job, file, log, pipeline[models.ArticleImport, repository.StagingArticle]{
+		parser:     stages,
+		normalizer: stages,
+		validator:  stages,
+		stager:     stages,
+		deduper:    stages,
+		batchDedup: stages,
+		fk:         stages,
+		inserter:   stages,
+		analyzer:   stages,
+		ledger:     stages,
+	})
+}
+
+// Parse reads articles with the parser registered for the file's format
+func (a *articleStages) Parse(file *os.File, fn RowFunc[models.ArticleImport]) error {
+	opts := parsers.ParserOptions{Encoding: a.encoding, MaxLineSize: a.maxLineSize, Flattener: a.flattener}
+	return parseRecords(file, models.ResourceTypeArticles, opts, fn)
+}
+
+// Normalize puts the ID in canonical form, lowercases the slug and status,
+// turns spaces in the slug into hyphens and strips invisible characters from
+// the title and tags
+func (a *articleStages) Normalize(jobID uuid.UUID, row int, article *models.ArticleImport) repository.StagingArticle {
+	staged := repository.StagingArticle{JobID: jobID, RowNumber: row}
+	if article == nil {
+		return staged
+	}
+
+	staged.IsValid = true
+	if article.ID != "" {
+		id := canonicalID(&article.ID)
+		staged.ID = &id
+	}
+	if article.Slug != "" {
+		slug := strings.ToLower(strings.TrimSpace(article.Slug))
+		slug = strings.ReplaceAll(slug, " ", "-")
+		staged.Slug = &slug
+	}
+	if article.Title != "" {
+		title := validation.StripInvisible(article.Title)
+		staged.Title = &title
+	}
+	if article.Body != "" {
+		staged.Body = &article.Body
+		if a.detectLang {
+			staged.Lang = bodyLang(article.Body)
+		}
+	}
+	if article.AuthorID != "" {
+		staged.AuthorID = &article.AuthorID
+	}
+	if article.Tags != nil {
+		for i, tag := range article.Tags {
+			article.Tags[i] = validation.StripInvisible(tag)
+		}
+		tagsJSON, _ := json.Marshal(article.Tags)
+		tags := string(tagsJSON)
+		staged.Tags = &tags
+	}
+	if article.PublishedAt != "" {
+		staged.PublishedAt = &article.PublishedAt
+	}
+	if article.Status != "" {
+		status := strings.ToLower(article.Status)
+		staged.Status = &status
+	}
+	return staged
+}
+
+func (a *articleStages) Reject(staged *repository.StagingArticle, verr *errors.ValidationError) {
+	msg := verr.Code + ": " + verr.Message
+	staged.IsValid = false
+	staged.ValidationError = &msg
+}
+
+func (a *articleStages) DedupKey(staged *repository.StagingArticle) string {
+	if staged.Slug == nil {
+		return ""
+	}
+	return *staged.Slug
+}
+
+// Validate sanitizes the body first when the job asked for it, screens it
+// when content screening is configured, then truncates an oversized body when
+// truncation is configured, so the row is kept with warnings instead of
+// rejected. Screening comes before truncation so content past the cut is
+// still screened.
+func (a *articleStages) Validate(row int, article *models.ArticleImport) ([]*errors.ValidationError, []*errors.ValidationError) {
+	var warns []*errors.ValidationError
+	if a.sanitize {
+		if warn := a.validator.SanitizeBody(row, article); warn != nil {
+			warns = append(warns, warn)
+		}
+	}
+	screenErr, warn := a.validator.ScreenBody(row, article)
+	if warn != nil {
+		warns = append(warns, warn)
+	}
+	if warn := a.validator.TruncateBody(row, article); warn != nil {
+		warns = append(warns, warn)
+	}
+	errs := a.validator.ValidateArticleImport(row, article)
+	if screenErr != nil {
+		errs = append(errs, screenErr)
+	}
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	return nil, warns
+}
+
+func (a *articleStages) Stage(ctx context.Context, jobID uuid.UUID, rows []repository.StagingArticle) error {
+	return a.buffer.stage(ctx, jobID, rows, a.stagingRepo.CreateStagingArticles)
+}
+
+func (a *articleStages) Valid(ctx context.Context, jobID uuid.UUID, batchSize int, fn func([]repository.StagingArticle) error) error {
+	if a.buffer.inMemory() {
+		return a.buffer.each(fn)
+	}
+	return a.stagingRepo.GetValidStagingArticles(ctx, jobID, batchSize, fn)
+}
+
+func (a *articleStages) StagingID(sa *repository.StagingArticle) int64 {
+	return sa.StagingID
+}
+
+func (a *articleStages) KeepCommitted(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	rows, err := a.stagingRepo.KeepCommittedArticles(ctx, jobID, through)
+	if len(rows) > 0 {
+		a.buffer.bypass()
+	}
+	return rows, err
+}
+
+func (a *articleStages) Cleanup(ctx context.Context, jobID uuid.UUID) error {
+	a.buffer.reset()
+	return a.stagingRepo.CleanupStagingArticles(ctx, jobID)
+}
+
+// Dedup marks repeated slugs within the file and slugs already taken by
+// another article. Matching on slug, stored slugs are updated instead, and
+// DedupBatch marks the rows under another article's ID as each batch is
+// written. When conflicts overwrite, Insert updates the article with the
+// slug instead.
+func (a *articleStages) Dedup(ctx context.Context, job *models.Job) (int, int, error) {
+	if a.buffer.inMemory() {
+		return a.dedupInMemory(ctx)
+	}
+
+	inBatch, err := a.stagingRepo.MarkDuplicateArticlesInBatch(ctx, job.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkCancelled(ctx); err != nil || a.upsertKey == models.UpsertKeySlug || a.onConflict.Overwrites() {
+		return inBatch, 0, err
+	}
+	existing, err := a.stagingRepo.MarkDuplicateArticlesAgainstExisting(ctx, job.ID)
+	if err != nil {
+		return inBatch, 0, err
+	}
+	return inBatch, existing, nil
+}
+
+// dedupInMemory is Dedup for buffered rows, looking up only the slugs and
+// IDs the file contains
+func (a *articleStages) dedupInMemory(ctx context.Context) (int, int, error) {
+	rows := a.buffer.rows
+	slug := func(sa *repository.StagingArticle) *string { return sa.Slug }
+	inBatch := markRepeats(rows, slug, markArticleDuplicate)
+	existing, err := a.markExisting(ctx, rows)
+	return inBatch, existing, err
+}
+
+// DedupBatch marks the rows whose slug was taken by another article since
+// Dedup ran, or matching on slug, whose new slug comes under a stored
+// article's ID
+func (a *articleStages) DedupBatch(ctx context.Context, rows []repository.StagingArticle) (int, error) {
+	return a.markExisting(ctx, rows)
+}
+
+// markExisting marks the valid rows whose slug belongs to a stored article,
+// unless their ID is a stored article they update. Matching on slug it marks
+// the rows with a new slug whose ID is a stored article instead. IDs are
+// looked up in canonical form, so any spelling of a stored ID matches it. It
+// marks none when conflicts overwrite.
+func (a *articleStages) markExisting(ctx context.Context, rows []repository.StagingArticle) (int, error) {
+	if a.onConflict.Overwrites() {
+		return 0, nil
+	}
+	slug := func(sa *repository.StagingArticle) *string { return sa.Slug }
+	id := func(sa *repository.StagingArticle) *string { return sa.ID }
+	valid := func(sa *repository.StagingArticle) bool { return sa.IsValid }
+
+	slugs, err := a.articleRepo.ExistingSlugs(ctx, collectKeys(rows, valid, slug))
+	if err != nil {
+		return 0, err
+	}
+	ids, err := a.articleRepo.ExistingIDs(ctx, collectKeys(rows, valid, id))
+	if err != nil {
+		return 0, err
+	}
+
+	existing := 0
+	for i := range rows {
+		sa := &rows[i]
+		if !sa.IsValid || sa.Slug == nil {
+			continue
+		}
+		storedID := sa.ID != nil && ids[canonicalID(sa.ID)]
+		if a.upsertKey == models.UpsertKeySlug {
+			if storedID && !slugs[*sa.Slug] {
+				markArticleIDTaken(sa)
+				existing++
+			}
+			continue
+		}
+		if slugs[*sa.Slug] && !storedID {
+			markArticleDuplicate(sa)
+			existing++
+		}
+	}
+	return existing, nil
+}
+
+func markArticleDuplicate(sa *repository.StagingArticle) {
+	code := errors.ErrCodeDuplicateSlug
+	sa.IsDuplicate = true
+	sa.IsValid = false
+	sa.ValidationError = &code
+}
+
+func markArticleIDTaken(sa *repository.StagingArticle) {
+	code := errors.ErrCodeDuplicateID
+	sa.IsDuplicate = true
+	sa.IsValid = false
+	sa.ValidationError = &code
+}
+
+// ResolveFK marks articles whose author_id is not a user. An import
+// creating missing authors first creates a placeholder user for each, so
+// only the articles whose author couldn't be created are marked; an analyze
+// job only counts the placeholders and marks none.
+func (a *articleStages) ResolveFK(ctx context.Context, job *models.Job) (int, error) {
+	if a.createAuthors {
+		missing, err := a.missingAuthors(ctx, job)
+		if err != nil {
+			return 0, err
+		}
+		placeholders := placeholderAuthors(job.ID, missing)
+		if a.analyze {
+			a.placeholders = len(placeholders)
+			return 0, nil
+		}
+		if a.placeholders, err = a.userRepo.CreateMissing(ctx, placeholders); err != nil {
+			return 0, fmt.Errorf("failed to create placeholder authors: %w", err)
+		}
+	}
+
+	if !a.buffer.inMemory() {
+		return a.stagingRepo.MarkInvalidAuthorFKArticles(ctx, job.ID)
+	}
+
+	rows := a.buffer.rows
+	authors, err := a.userRepo.ExistingIDs(ctx, collectKeys(rows, validArticle, articleAuthor))
+	if err != nil {
+		return 0, err
+	}
+
+	invalid := 0
+	for i := range rows {
+		sa := &rows[i]
+		if sa.IsValid && sa.AuthorID != nil && !authors[*sa.AuthorID] {
+			code := "INVALID_AUTHOR_FK"
+			sa.IsValid = false
+			sa.ValidationError = &code
+			invalid++
+		}
+	}
+	return invalid, nil
+}
+
+// Placeholders returns the number of placeholder authors ResolveFK created,
+// or would have created
+func (a *articleStages) Placeholders() int {
+	return a.placeholders
+}
+
+func validArticle(sa *repository.StagingArticle) bool     { return sa.IsValid }
+func articleAuthor(sa *repository.StagingArticle) *string { return sa.AuthorID }
+
+// missingAuthors returns the distinct author IDs of valid rows that aren't
+// users
+func (a *articleStages) missingAuthors(ctx context.Context, job *models.Job) ([]string, error) {
+	if !a.buffer.inMemory() {
+		return a.stagingRepo.MissingArticleAuthors(ctx, job.ID)
+	}
+	authors := collectKeys(a.buffer.rows, validArticle, articleAuthor)
+	existing, err := a.userRepo.ExistingIDs(ctx, authors)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]string, 0, len(authors))
+	for _, id := range authors {
+		if !existing[id] {
+			existing[id] = true
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// placeholderAuthors returns an inactive placeholder user, stamped with
+// jobID, for each author ID. IDs not in canonical form are skipped, as the
+// foreign key check wouldn't match them to the placeholder.
+func placeholderAuthors(jobID uuid.UUID, authorIDs []string) []*models.User {
+	users := make([]*models.User, 0, len(authorIDs))
+	for _, authorID := range authorIDs {
+		id, err := uuid.Parse(authorID)
+		if err != nil || id.String() != authorID {
+			continue
+		}
+		users = append(users, &models.User{
+			ID:          id,
+			Email:       "placeholder-" + authorID + "@" + PlaceholderEmailDomain,
+			Name:        "Placeholder author " + authorID[:8],
+			Role:        "author",
+			Active:      false,
+			Placeholder: true,
+			ImportJobID: provenance(jobID),
+		})
+	}
+	return users
+}
+
+// Insert upserts the valid rows. When conflicts overwrite, a row whose slug
+// belongs to another article takes that article's ID so that it updates it,
+// and in upsert mode keeps its values for the fields it leaves out.
+func (a *articleStages) Insert(ctx context.Context, rows []repository.StagingArticle) ([]uuid.UUID, int, int, error) {
+	var stored map[string]*models.Article
+	if a.onConflict.Overwrites() {
+		slug := func(sa *repository.StagingArticle) *string { return sa.Slug }
+		writable := func(sa *repository.StagingArticle) bool { return sa.IsValid && !sa.IsDuplicate }
+		var err error
+		if stored, err = a.articleRepo.GetBySlugs(ctx, collectKeys(rows, writable, slug)); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	articles := make([]*models.Article, 0, len(rows))
+	for _, sa := range rows {
+		if !sa.IsValid || sa.IsDuplicate {
+			continue
+		}
+		var match *models.Article
+		if sa.Slug != nil {
+			if m := stored[*sa.Slug]; m != nil && conflictsWith(sa.ID, m.ID) {
+				match = m
+			}
+		}
+		if match != nil && a.onConflict == models.OnConflictUpsert {
+			mergeStoredArticle(&sa, match)
+		}
+		article, err := convertStagingToArticle(&sa)
+		if err != nil {
+			a.log.Warn().Err(err).Int("row", sa.RowNumber).Msg("Failed to convert staging article")
+			continue
+		}
+		if match != nil {
+			article.ID = match.ID
+		}
+		articles = append(articles, article)
+	}
+	if len(articles) == 0 {
+		return nil, 0, 0, nil
+	}
+
+	inserted, updated, err := a.articleRepo.UpsertBatch(ctx, articles, a.upsertKey)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	ids := make([]uuid.UUID, len(articles))
+	for i, article := range articles {
+		ids[i] = article.ID
+	}
+	return ids, inserted, updated, nil
+}
+
+// Analyze counts the rows Insert would write as new articles and as updates
+// to the article with the same ID, or matching on slug, the same slug. When
+// conflicts overwrite, a row with a stored slug updates that article.
+func (a *articleStages) Analyze(ctx context.Context, rows []repository.StagingArticle) (int, int, error) {
+	writable := func(sa *repository.StagingArticle) bool { return sa.IsValid && !sa.IsDuplicate }
+	slug := func(sa *repository.StagingArticle) *string { return sa.Slug }
+	if a.upsertKey == models.UpsertKeySlug {
+		return analyzeRows(ctx, rows, writable, slug, a.articleRepo.ExistingSlugs)
+	}
+	id := func(sa *repository.StagingArticle) *string { return sa.ID }
+	if a.onConflict.Overwrites() {
+		return analyzeOverwrites(ctx, rows, writable, slug, id, a.articleRepo.ExistingSlugs, a.articleRepo.ExistingIDs)
+	}
+	return analyzeRows(ctx, rows, writable, id, a.articleRepo.ExistingIDs)
+}
+
+// LedgerEntry keys an article by slug and hashes the values the file gave it
+func (a *articleStages) LedgerEntry(sa *repository.StagingArticle) (string, string, bool) {
+	if !sa.IsValid || sa.IsDuplicate || sa.Slug == nil {
+		return "", "", false
+	}
+	return *sa.Slug, contentHash(sa.ID, sa.Slug, sa.Title, sa.Body, sa.AuthorID, sa.Tags, sa.PublishedAt, sa.Status, sa.Lang), true
+}
+
+// Stored returns which of the slugs belong to a stored article
+func (a *articleStages) Stored(ctx context.Context, slugs []string) (map[string]bool, error) {
+	return a.articleRepo.ExistingSlugs(ctx, slugs)
+}
+
+func (a *articleStages) MarkImported(sa *repository.StagingArticle) {
+	code := errors.ErrCodeAlreadyImported
+	sa.IsValid = false
+	sa.ValidationError = &code
+}
+
+// mergeStoredArticle fills the fields sa leaves out with the values of the
+// stored article it updates. Every row has a status, and published_at goes
+// with it, so neither is taken from the stored article.
+func mergeStoredArticle(sa *repository.StagingArticle, stored *models.Article) {
+	if sa.Title == nil {
+		sa.Title = &stored.Title
+	}
+	if sa.Body == nil {
+		sa.Body = &stored.Body
+	}
+	if sa.AuthorID == nil {
+		authorID := stored.AuthorID.String()
+		sa.AuthorID = &authorID
+	}
+	if sa.Tags == nil && len(stored.Tags) > 0 {
+		tags := string(stored.Tags)
+		sa.Tags = &tags
+	}
+	if sa.Lang == nil {
+		sa.Lang = stored.Lang
+	}
+}
+
+func convertStagingToArticle(sa *repository.StagingArticle) (*models.Article, error) {
+	article := &models.Article{
+		Tags:        json.RawMessage("[]"),
+		ImportJobID: provenance(sa.JobID),
+	}
+
+	if sa.ID != nil && *sa.ID != "" {
+		id, err := uuid.Parse(*sa.ID)
+		if err != nil {
+			return nil, err
+		}
+		article.ID = id
+	} else {
+		article.ID = uuid.New()
+	}
+
+	if sa.Slug != nil {
+		article.Slug = *sa.Slug
+	}
+	if sa.Title != nil {
+		article.Title = *sa.Title
+	}
+	if sa.Body != nil {
+		article.Body = *sa.Body
+	}
+	if sa.AuthorID != nil {
+		authorID, err := uuid.Parse(*sa.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+		article.AuthorID = authorID
+	}
+	if sa.Tags != nil {
+		article.Tags = json.RawMessage(*sa.Tags)
+	}
+	if sa.Status != nil {
+		article.Status = *sa.Status
+	}
+	article.Lang = sa.Lang
+	if sa.PublishedAt != nil {
+		t, err := time.Parse(time.RFC3339, *sa.PublishedAt)
+		if err == nil {
+			article.PublishedAt = &t
+		}
+	}
+
+	article.CreatedAt = time.Now().UTC()
+	article.UpdatedAt = time.Now().UTC()
+
+	return article, nil
+}
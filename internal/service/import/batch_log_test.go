@@ -0,0 +1,135 @@
+package importservice
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestProcessImport_BatchLog(t *testing.T) {
+	users := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+`
+	changed := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"false"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Robert","role":"reader","active":"true"}
+{"email":"dora@example.com","name":"Dora","role":"reader","active":"true"}
+`
+	for _, fastPath := range []int{0, 100} {
+		svc, db := newTestService(t, fastPath)
+		batchLog := memory.NewBatchLogRepository(db)
+		svc.SetBatchLog(batchLog)
+		ctx := context.Background()
+
+		job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+		if job.SuccessfulRecords != 3 || job.Summary != nil {
+			t.Errorf("fastPath=%d: first import successful = %d, summary %+v; want 3 and no summary", fastPath, job.SuccessfulRecords, job.Summary)
+		}
+
+		// Rows with stored IDs are counted as updates
+		job = runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", changed)
+		if job.SuccessfulRecords != 3 || job.Summary.Inserted != 1 || job.Summary.Updated != 2 {
+			t.Errorf("fastPath=%d: second import successful = %d, summary %+v; want 1 inserted and 2 updated", fastPath, job.SuccessfulRecords, job.Summary)
+		}
+		commits, err := batchLog.ListByJob(ctx, job.ID)
+		if err != nil || len(commits) != 2 {
+			t.Fatalf("fastPath=%d: ListByJob() = %d commits, %v; want a batch of 2 and one of 1", fastPath, len(commits), err)
+		}
+		for i, c := range commits {
+			if c.Attempt != 1 || c.BatchNo != i+1 || c.CommittedAt.IsZero() {
+				t.Errorf("fastPath=%d: commit %d = %+v, want batch %d of attempt 1", fastPath, i, c, i+1)
+			}
+			if staged := c.FirstStagingID != nil; staged != (fastPath == 0) || staged && *c.FirstStagingID > *c.LastStagingID {
+				t.Errorf("fastPath=%d: commit %d staging IDs = %v..%v", fastPath, i, c.FirstStagingID, c.LastStagingID)
+			}
+		}
+
+		// Running the job again records its batches under the next attempt,
+		// and counts only those. Dora's email is taken by now.
+		if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", changed), job, "ndjson"); err != nil {
+			t.Fatalf("ProcessImport() error: %v", err)
+		}
+		if inserted, updated, _ := batchLog.Totals(ctx, job.ID, 2); inserted != 0 || updated != 2 {
+			t.Errorf("fastPath=%d: attempt 2 totals = %d inserted, %d updated; want 2 updated", fastPath, inserted, updated)
+		}
+		stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+		if stored.SuccessfulRecords != 2 || stored.Summary.Updated != 2 {
+			t.Errorf("fastPath=%d: rerun successful = %d, summary %+v; want 2 updated", fastPath, stored.SuccessfulRecords, stored.Summary)
+		}
+	}
+}
+
+// failingUsers writes the next ok batches and fails the rest
+type failingUsers struct {
+	repository.UserRepository
+	ok *int
+}
+
+func (f failingUsers) UpsertBatch(ctx context.Context, users []*models.User, key models.UpsertKey) (int, int, error) {
+	if *f.ok == 0 {
+		return 0, 0, stderrors.New("connection reset")
+	}
+	*f.ok--
+	return f.UserRepository.UpsertBatch(ctx, users, key)
+}
+
+func TestProcessImport_RerunSkipsCommittedRows(t *testing.T) {
+	users := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+{"email":"dora@example.com","name":"Dora","role":"reader","active":"true"}
+{"email":"eve@example.com","name":"Eve","role":"reader","active":"true"}
+{"email":"CARL@example.com","name":"Carl again","role":"reader","active":"true"}
+`
+	svc, db := newTestService(t, 0)
+	batchLog := memory.NewBatchLogRepository(db)
+	svc.SetBatchLog(batchLog)
+	ok := 2
+	svc.userRepo = failingUsers{svc.userRepo, &ok}
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(db)
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	// The third batch fails after two have committed
+	if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson"); err == nil {
+		t.Fatal("ProcessImport() error = nil, want the failed batch")
+	}
+	if n, _ := memory.NewUserRepository(db).Count(ctx, nil); n != 4 {
+		t.Fatalf("users after the failed run = %d, want 4", n)
+	}
+
+	// The next run writes only Eve, and the job counts every attempt. Carl
+	// and Dora are stored by now but aren't duplicates of themselves.
+	ok = 100
+	if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson"); err != nil {
+		t.Fatalf("ProcessImport() rerun error: %v", err)
+	}
+	commits, err := batchLog.ListByJob(ctx, job.ID)
+	if err != nil || len(commits) != 3 {
+		t.Fatalf("ListByJob() = %d commits, %v; want 2 of attempt 1 and 1 of attempt 2", len(commits), err)
+	}
+	if last := commits[2]; last.Attempt != 2 || last.Rows != 1 || last.Inserted != 1 {
+		t.Errorf("rerun commit = %+v, want Eve alone under attempt 2", last)
+	}
+	if inserted, updated, _ := batchLog.Totals(ctx, job.ID, 1); inserted != 5 || updated != 0 {
+		t.Errorf("totals = %d inserted, %d updated; want 5 inserted", inserted, updated)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.TotalRecords != 6 || stored.SuccessfulRecords != 5 || stored.FailedRecords != 1 || stored.DuplicateRecords != 1 {
+		t.Errorf("rerun job = %d total, %d successful, %d failed, %d duplicates; want 6, 5, 1, 1",
+			stored.TotalRecords, stored.SuccessfulRecords, stored.FailedRecords, stored.DuplicateRecords)
+	}
+	if n, _ := memory.NewUserRepository(db).Count(ctx, nil); n != 5 {
+		t.Errorf("users after the rerun = %d, want 5", n)
+	}
+	if staged := memory.NewStagingRepository(db).StagingUsers(job.ID); len(staged) != 0 {
+		t.Errorf("staging rows after the rerun = %d, want none", len(staged))
+	}
+}
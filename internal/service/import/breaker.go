@@ -0,0 +1,112 @@
+package importservice
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+)
+
+// ErrPaused is wrapped by the error of an import the circuit breaker paused.
+// The job is left paused for an operator to resume rather than failed.
+var ErrPaused = stderrors.New("import paused by the circuit breaker")
+
+// Circuit breaker states
+const (
+	BreakerDisabled = "disabled"
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+)
+
+// BreakerState is the state of the import circuit breaker of an instance.
+// The breaker opens once Threshold batch inserts in a row have failed, across
+// every import it runs, and closes again on the next batch written.
+type BreakerState struct {
+	State               string
+	ConsecutiveFailures int
+	Threshold           int
+	OpenedAt            *time.Time
+	LastError           string
+	LastFailureAt       *time.Time
+	// Trips counts the times the breaker opened since the instance started
+	Trips int
+}
+
+// breaker counts the batch inserts that failed in a row
+type breaker struct {
+	mu            sync.Mutex
+	failures      int
+	openedAt      *time.Time
+	lastError     string
+	lastFailureAt *time.Time
+	trips         int
+}
+
+// failure records a failed batch insert and reports whether the breaker is
+// open with threshold failures in a row. The failure that opens it counts as
+// a trip.
+func (b *breaker) failure(err error, threshold int) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now().UTC()
+	b.failures++
+	b.lastError = err.Error()
+	b.lastFailureAt = &now
+	if b.failures < threshold {
+		return b.failures, false
+	}
+	if b.openedAt == nil {
+		b.openedAt = &now
+		b.trips++
+	}
+	return b.failures, true
+}
+
+// success records a batch written, closing the breaker
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openedAt = nil
+}
+
+func (b *breaker) state(threshold int) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := BreakerState{
+		State:               BreakerClosed,
+		ConsecutiveFailures: b.failures,
+		Threshold:           threshold,
+		OpenedAt:            b.openedAt,
+		LastError:           b.lastError,
+		LastFailureAt:       b.lastFailureAt,
+		Trips:               b.trips,
+	}
+	switch {
+	case threshold <= 0:
+		state.State = BreakerDisabled
+	case b.openedAt != nil:
+		state.State = BreakerOpen
+	}
+	return state
+}
+
+// Breaker returns the state of the import circuit breaker
+func (s *Service) Breaker() BreakerState {
+	return s.breaker.state(s.config.Load().BreakerThreshold)
+}
+
+// sleepCtx waits for d, returning early with the error of ctx once it is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
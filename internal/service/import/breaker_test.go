@@ -0,0 +1,106 @@
+package importservice
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
+)
+
+// flakyUsers fails the next failures batch writes
+type flakyUsers struct {
+	repository.UserRepository
+	failures *int
+}
+
+func (f flakyUsers) UpsertBatch(ctx context.Context, users []*models.User, key models.UpsertKey) (int, int, error) {
+	if *f.failures > 0 {
+		*f.failures--
+		return 0, 0, stderrors.New("connection refused")
+	}
+	return f.UserRepository.UpsertBatch(ctx, users, key)
+}
+
+// pauses records the jobs paused
+type pauses struct {
+	hooks.Base
+	jobs *[]string
+}
+
+func (p pauses) OnJobPaused(ctx context.Context, job *models.Job, err error) {
+	*p.jobs = append(*p.jobs, job.ID.String())
+}
+
+func TestProcessImport_Breaker(t *testing.T) {
+	users := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+`
+	svc, db := newTestService(t, 0)
+	svc.config.Load().BreakerThreshold = 3
+	failures := 0
+	svc.userRepo = flakyUsers{svc.userRepo, &failures}
+	var paused []string
+	svc.RegisterHooks(pauses{jobs: &paused})
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(db)
+
+	run := func() (*models.Job, error) {
+		t.Helper()
+		job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson")
+		stored, _ := jobs.GetByID(ctx, job.ID)
+		return stored, err
+	}
+
+	// Failures short of the threshold are retried
+	failures = 2
+	if job, err := run(); err != nil || job.Status != models.JobStatusCompleted || job.SuccessfulRecords != 3 {
+		t.Fatalf("flaky import = %v, status %s with %d written; want completed with 3", err, job.Status, job.SuccessfulRecords)
+	}
+	if state := svc.Breaker(); state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Errorf("breaker after recovery = %+v, want closed", state)
+	}
+
+	// Reaching it pauses the job rather than failing it
+	failures = 100
+	job, err := run()
+	if !stderrors.Is(err, ErrPaused) || job.Status != models.JobStatusPaused || job.ErrorMessage == nil {
+		t.Fatalf("failing import = %v, status %s; want paused", err, job.Status)
+	}
+	if len(paused) != 1 || paused[0] != job.ID.String() {
+		t.Errorf("paused hook calls = %v, want the job", paused)
+	}
+	state := svc.Breaker()
+	if state.State != BreakerOpen || state.ConsecutiveFailures != 3 || state.Trips != 1 || state.OpenedAt == nil || state.LastError == "" {
+		t.Errorf("breaker = %+v, want open after 3 failures", state)
+	}
+
+	// While it is open the next failure pauses at once, and a write closes it
+	failures = 1
+	if job, err := run(); !stderrors.Is(err, ErrPaused) || job.Status != models.JobStatusPaused {
+		t.Errorf("import with the breaker open = %v, status %s; want paused", err, job.Status)
+	}
+	if state := svc.Breaker(); state.ConsecutiveFailures != 4 || state.Trips != 1 {
+		t.Errorf("breaker = %+v, want still open after 4 failures", state)
+	}
+	failures = 0
+	if job, err := run(); err != nil || job.Status != models.JobStatusCompleted {
+		t.Fatalf("import = %v, status %s; want completed", err, job.Status)
+	}
+	if state := svc.Breaker(); state.State != BreakerClosed {
+		t.Errorf("breaker after a write = %+v, want closed", state)
+	}
+
+	svc.config.Load().BreakerThreshold = 0
+	if state := svc.Breaker(); state.State != BreakerDisabled {
+		t.Errorf("breaker without a threshold = %+v, want disabled", state)
+	}
+}
@@ -0,0 +1,377 @@
+package importservice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	"github.com/rohit/bulk-import-export/internal/service/validation"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// commentStages implements the pipeline stages for comment imports
+type commentStages struct {
+	encoding    parsers.Encoding
+	maxLineSize int
+	flattener   *parsers.Flattener // nil unless the job maps nested fields
+	validator   *validation.CommentValidator
+	sanitize    bool // clean bodies before validating them
+	detectLang  bool // tag rows with the language of their body
+	stagingRepo repository.StagingRepository
+	commentRepo repository.CommentRepository
+	articleRepo repository.ArticleRepository
+	userRepo    repository.UserRepository
+	buffer      *memoryBuffer[repository.StagingComment]
+	log         zerolog.Logger // sampled, for per-row warnings
+}
+
+func (s *Service) processCommentsImport(ctx context.Context, job *models.Job, file *os.File, log zerolog.Logger) error {
+	cfg := s.config.Load()
+	flatten, err := flattener(job)
+	if err != nil {
+		return err
+	}
+	// Natural keys are computed by comment_natural_key in the database, so
+	// that strategy always goes through the staging tables
+	fastPathRows := cfg.FastPathMaxRows
+	if job.Params != nil && job.Params.CommentDedup == models.CommentDedupNaturalKey {
+		fastPathRows = 0
+	}
+
+	stages := &commentStages{
+		encoding:    s.encoding,
+		maxLineSize: s.maxLineSize(),
+		flattener:   flatten,
+		validator:   s.validator.Comment,
+		sanitize:    job.Params != nil && job.Params.Sanitize,
+		detectLang:  s.detectLang(job),
+		stagingRepo: s.stagingRepo,
+		commentRepo: s.commentRepo,
+		articleRepo: s.articleRepo,
+		userRepo:    s.userRepo,
+		buffer:      newMemoryBuffer[repository.StagingComment](fastPathRows, cfg.BatchSize),
+		log:         logger.Hot(log),
+	}
+	return runPipeline(ctx, s, job, file, log, pipeline[models.CommentImport, repository.StagingComment]{
+		parser:     stages,
+		normalizer: stages,
+		validator:  stages,
+		stager:     stages,
+		deduper:    stages,
+		fk:         stages,
+		inserter:   stages,
+		analyzer:   stages,
+		ledger:     stages,
+	})
+}
+
+// Parse reads comments with the parser registered for the file's format
+func (c *commentStages) Parse(file *os.File, fn RowFunc[models.CommentImport]) error {
+	opts := parsers.ParserOptions{Encoding: c.encoding, MaxLineSize: c.maxLineSize, Flattener: c.flattener}
+	return parseRecords(file, models.ResourceTypeComments, opts, fn)
+}
+
+// Normalize stages the comment's fields as supplied
+func (c *commentStages) Normalize(jobID uuid.UUID, row int, comment *models.CommentImport) repository.StagingComment {
+	staged := repository.StagingComment{JobID: jobID, RowNumber: row}
+	if comment == nil {
+		return staged
+	}
+
+	staged.IsValid = true
+	if comment.ID != "" {
+		staged.ID = &comment.ID
+	}
+	if comment.ArticleID != "" {
+		staged.ArticleID = &comment.ArticleID
+	}
+	if comment.UserID != "" {
+		staged.UserID = &comment.UserID
+	}
+	if comment.Body != "" {
+		staged.Body = &comment.Body
+		if c.detectLang {
+			staged.Lang = bodyLang(comment.Body)
+		}
+	}
+	if comment.CreatedAt != "" {
+		staged.CreatedAt = &comment.CreatedAt
+	}
+	return staged
+}
+
+func (c *commentStages) Reject(staged *repository.StagingComment, verr *errors.ValidationError) {
+	msg := verr.Code + ": " + verr.Message
+	staged.IsValid = false
+	staged.ValidationError = &msg
+}
+
+func (c *commentStages) DedupKey(staged *repository.StagingComment) string {
+	if staged.ID == nil {
+		return ""
+	}
+	return *staged.ID
+}
+
+// Validate sanitizes the body first when the job asked for it, so the
+// cleaned body is what gets checked and stored, then screens it when content
+// screening is configured
+func (c *commentStages) Validate(row int, comment *models.CommentImport) ([]*errors.ValidationError, []*errors.ValidationError) {
+	var warns []*errors.ValidationError
+	if c.sanitize {
+		if warn := c.validator.SanitizeBody(row, comment); warn != nil {
+			warns = append(warns, warn)
+		}
+	}
+	screenErr, warn := c.validator.ScreenBody(row, comment)
+	if warn != nil {
+		warns = append(warns, warn)
+	}
+	errs := c.validator.ValidateCommentImport(row, comment)
+	if screenErr != nil {
+		errs = append(errs, screenErr)
+	}
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	return nil, append(warns, c.validator.WarnCommentImport(row, comment)...)
+}
+
+func (c *commentStages) Stage(ctx context.Context, jobID uuid.UUID, rows []repository.StagingComment) error {
+	return c.buffer.stage(ctx, jobID, rows, c.stagingRepo.CreateStagingComments)
+}
+
+func (c *commentStages) Valid(ctx context.Context, jobID uuid.UUID, batchSize int, fn func([]repository.StagingComment) error) error {
+	if c.buffer.inMemory() {
+		return c.buffer.each(fn)
+	}
+	return c.stagingRepo.GetValidStagingComments(ctx, jobID, batchSize, fn)
+}
+
+func (c *commentStages) StagingID(sc *repository.StagingComment) int64 {
+	return sc.StagingID
+}
+
+func (c *commentStages) KeepCommitted(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	rows, err := c.stagingRepo.KeepCommittedComments(ctx, jobID, through)
+	if len(rows) > 0 {
+		c.buffer.bypass()
+	}
+	return rows, err
+}
+
+func (c *commentStages) Cleanup(ctx context.Context, jobID uuid.UUID) error {
+	c.buffer.reset()
+	return c.stagingRepo.CleanupStagingComments(ctx, jobID)
+}
+
+// Dedup marks repeated IDs within the file. With the natural_key strategy it
+// also marks comments matching an earlier row or a stored comment by content,
+// for sources without stable IDs.
+func (c *commentStages) Dedup(ctx context.Context, job *models.Job) (int, int, error) {
+	if c.buffer.inMemory() {
+		id := func(sc *repository.StagingComment) *string { return sc.ID }
+		return markRepeats(c.buffer.rows, id, markCommentDuplicate), 0, nil
+	}
+
+	inBatch, err := c.stagingRepo.MarkDuplicateCommentsInBatch(ctx, job.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if job.Params == nil || job.Params.CommentDedup != models.CommentDedupNaturalKey {
+		return inBatch, 0, nil
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return inBatch, 0, err
+	}
+
+	if err := c.stagingRepo.SetCommentNaturalKeys(ctx, job.ID); err != nil {
+		return inBatch, 0, fmt.Errorf("failed to compute comment natural keys: %w", err)
+	}
+	byKey, err := c.stagingRepo.MarkDuplicateCommentsByNaturalKeyInBatch(ctx, job.ID)
+	if err != nil {
+		return inBatch, 0, err
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return inBatch + byKey, 0, err
+	}
+	existing, err := c.stagingRepo.MarkDuplicateCommentsByNaturalKeyAgainstExisting(ctx, job.ID)
+	if err != nil {
+		return inBatch + byKey, 0, err
+	}
+	return inBatch + byKey, existing, nil
+}
+
+func markCommentDuplicate(sc *repository.StagingComment) {
+	code := "DUPLICATE_ID"
+	sc.IsDuplicate = true
+	sc.IsValid = false
+	sc.ValidationError = &code
+}
+
+// ResolveFK marks comments whose article_id or user_id doesn't exist
+func (c *commentStages) ResolveFK(ctx context.Context, job *models.Job) (int, error) {
+	if !c.buffer.inMemory() {
+		return c.stagingRepo.MarkInvalidFKComments(ctx, job.ID)
+	}
+
+	rows := c.buffer.rows
+	valid := func(sc *repository.StagingComment) bool { return sc.IsValid }
+	articles, err := c.articleRepo.ExistingIDs(ctx, collectKeys(rows, valid,
+		func(sc *repository.StagingComment) *string { return sc.ArticleID }))
+	if err != nil {
+		return 0, err
+	}
+	users, err := c.userRepo.ExistingIDs(ctx, collectKeys(rows, valid,
+		func(sc *repository.StagingComment) *string { return sc.UserID }))
+	if err != nil {
+		return 0, err
+	}
+
+	invalid := 0
+	for i := range rows {
+		sc := &rows[i]
+		if !sc.IsValid {
+			continue
+		}
+		var code string
+		switch {
+		case sc.ArticleID != nil && !articles[*sc.ArticleID]:
+			code = "INVALID_ARTICLE_FK"
+		case sc.UserID != nil && !users[*sc.UserID]:
+			code = "INVALID_USER_FK"
+		default:
+			continue
+		}
+		sc.IsValid = false
+		sc.ValidationError = &code
+		invalid++
+	}
+	return invalid, nil
+}
+
+// Insert writes comments by ID, overwriting the stored comment with the
+// same ID. Those are looked up first to count them as updates; the batch's
+// transaction holds the import lock, so no other import writes them between.
+func (c *commentStages) Insert(ctx context.Context, rows []repository.StagingComment) ([]uuid.UUID, int, int, error) {
+	comments := make([]*models.Comment, 0, len(rows))
+	for _, sc := range rows {
+		if !sc.IsValid || sc.IsDuplicate {
+			continue
+		}
+		comment, err := convertStagingToComment(&sc)
+		if err != nil {
+			c.log.Warn().Err(err).Int("row", sc.RowNumber).Msg("Failed to convert staging comment")
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	if len(comments) == 0 {
+		return nil, 0, 0, nil
+	}
+
+	var keys []string
+	for _, comment := range comments {
+		if comment.ID != uuid.Nil {
+			keys = append(keys, comment.ID.String())
+		}
+	}
+	existing, err := c.commentRepo.ExistingIDs(ctx, keys)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	count, err := c.commentRepo.CreateBatch(ctx, comments)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	ids := make([]uuid.UUID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	return ids, count - len(existing), len(existing), nil
+}
+
+// Analyze counts the rows Insert would write as new comments and as updates
+// to the comment with the same ID
+func (c *commentStages) Analyze(ctx context.Context, rows []repository.StagingComment) (int, int, error) {
+	writable := func(sc *repository.StagingComment) bool { return sc.IsValid && !sc.IsDuplicate }
+	id := func(sc *repository.StagingComment) *string { return sc.ID }
+	return analyzeRows(ctx, rows, writable, id, c.commentRepo.ExistingIDs)
+}
+
+// LedgerEntry keys a comment by its ID in canonical form and hashes the
+// values the file gave it. Comments without an ID get a new one each time
+// they are imported, so they aren't recorded.
+func (c *commentStages) LedgerEntry(sc *repository.StagingComment) (string, string, bool) {
+	if !sc.IsValid || sc.IsDuplicate || sc.ID == nil {
+		return "", "", false
+	}
+	id, err := uuid.Parse(*sc.ID)
+	if err != nil {
+		return "", "", false
+	}
+	return id.String(), contentHash(id, sc.ArticleID, sc.UserID, sc.Body, sc.CreatedAt, sc.Lang), true
+}
+
+// Stored returns which of the IDs belong to a stored comment
+func (c *commentStages) Stored(ctx context.Context, ids []string) (map[string]bool, error) {
+	return c.commentRepo.ExistingIDs(ctx, ids)
+}
+
+func (c *commentStages) MarkImported(sc *repository.StagingComment) {
+	code := errors.ErrCodeAlreadyImported
+	sc.IsValid = false
+	sc.ValidationError = &code
+}
+
+func convertStagingToComment(sc *repository.StagingComment) (*models.Comment, error) {
+	comment := &models.Comment{ImportJobID: provenance(sc.JobID)}
+
+	if sc.ID != nil && *sc.ID != "" {
+		id, err := uuid.Parse(*sc.ID)
+		if err != nil {
+			return nil, err
+		}
+		comment.ID = id
+	} else {
+		comment.ID = uuid.New()
+	}
+
+	if sc.ArticleID != nil {
+		articleID, err := uuid.Parse(*sc.ArticleID)
+		if err != nil {
+			return nil, err
+		}
+		comment.ArticleID = articleID
+	}
+	if sc.UserID != nil {
+		userID, err := uuid.Parse(*sc.UserID)
+		if err != nil {
+			return nil, err
+		}
+		comment.UserID = userID
+	}
+	if sc.Body != nil {
+		comment.Body = *sc.Body
+	}
+	if sc.CreatedAt != nil {
+		t, err := time.Parse(time.RFC3339, *sc.CreatedAt)
+		if err == nil {
+			comment.CreatedAt = t
+		} else {
+			comment.CreatedAt = time.Now().UTC()
+		}
+	} else {
+		comment.CreatedAt = time.Now().UTC()
+	}
+	comment.Lang = sc.Lang
+
+	return comment, nil
+}
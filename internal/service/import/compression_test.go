@@ -0,0 +1,42 @@
+package importservice
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestProcessImport_Gzip(t *testing.T) {
+	users := `{"email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"email":"bob@example.com","name":"Bob","role":"reader","active":"false"}
+`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(users))
+	zw.Close()
+
+	// Compressed files are found by their magic bytes, with or without .gz
+	for _, name := range []string{"users.ndjson.gz", "users.ndjson"} {
+		svc, db := newTestService(t, 0)
+		file := writeTempFile(t, name, buf.String())
+
+		if rows, err := svc.CountRows(file.Name()); err != nil || rows != 2 {
+			t.Errorf("%s: CountRows() = %d, %v; want 2", name, rows, err)
+		}
+		if detection, err := svc.DetectResource(file.Name()); err != nil || detection.Resource != models.ResourceTypeUsers {
+			t.Errorf("%s: DetectResource() = %+v, %v; want users", name, detection, err)
+		}
+
+		job := runImport(t, svc, db, models.ResourceTypeUsers, name, buf.String())
+		if job.SuccessfulRecords != 2 || job.FailedRecords != 0 {
+			t.Errorf("%s: successful = %d, failed = %d; want 2, 0", name, job.SuccessfulRecords, job.FailedRecords)
+		}
+		if n, _ := memory.NewUserRepository(db).Count(context.Background(), nil); n != 2 {
+			t.Errorf("%s: stored %d users, want 2", name, n)
+		}
+	}
+}
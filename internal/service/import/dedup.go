@@ -0,0 +1,111 @@
+package importservice
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// duplicateTracker estimates how many rows of a file repeat an earlier dedup
+// key while it is still being staged. It is backed by a bloom filter, so its
+// count may slightly overstate the duplicates but its memory stays fixed
+// however large the file is. The staging dedup queries stay authoritative.
+type duplicateTracker struct {
+	filter *bloomFilter
+	count  int
+}
+
+// newDuplicateTracker sizes the filter for expectedRows keys at a 1% false
+// positive rate
+func newDuplicateTracker(expectedRows int) *duplicateTracker {
+	return &duplicateTracker{filter: newBloomFilter(expectedRows, 0.01)}
+}
+
+// Seen records key and reports whether it was probably seen before. Empty
+// keys are never duplicates.
+func (t *duplicateTracker) Seen(key string) bool {
+	if key == "" {
+		return false
+	}
+	if t.filter.AddIfAbsent(key) {
+		return false
+	}
+	t.count++
+	return true
+}
+
+// Count returns the duplicates seen so far
+func (t *duplicateTracker) Count() int {
+	return t.count
+}
+
+// bloomFilter is a fixed-size set membership filter with no false negatives
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes int
+}
+
+func newBloomFilter(expected int, fpRate float64) *bloomFilter {
+	if expected < 1 {
+		expected = 1
+	}
+	m := math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(expected) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	size := uint64(m)
+	return &bloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: k,
+	}
+}
+
+// AddIfAbsent adds key and reports whether it was definitely not present
+func (b *bloomFilter) AddIfAbsent(key string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	// Double hashing: derive k positions from the two halves of one hash
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	added := false
+	for i := 0; i < b.hashes; i++ {
+		pos := (h1 + uint64(i)*h2) % b.size
+		word, bit := pos/64, uint64(1)<<(pos%64)
+		if b.bits[word]&bit == 0 {
+			b.bits[word] |= bit
+			added = true
+		}
+	}
+	return added
+}
+
+// trackDuplicates publishes the running duplicate estimate and fails the
+// import once duplicates pass config.MaxDuplicatePercent of the rows staged,
+// so a file that is mostly repeats is stopped early instead of after a full
+// parse
+func (s *Service) trackDuplicates(ctx context.Context, job *models.Job, totalRows int, tracker *duplicateTracker) error {
+	cfg := s.config.Load()
+	dups := tracker.Count()
+	s.jobRepo.SetDuplicateRecords(ctx, job.ID, dups)
+
+	if cfg.MaxDuplicatePercent <= 0 || totalRows < cfg.DuplicateCheckMinRows {
+		return nil
+	}
+	if dups*100 <= totalRows*cfg.MaxDuplicatePercent {
+		return nil
+	}
+
+	msg := fmt.Sprintf("about %d of the first %d rows are duplicates, above the %d%% limit", dups, totalRows, cfg.MaxDuplicatePercent)
+	s.recordValidationErrors(ctx, job, []*errors.ValidationError{
+		errors.NewValidationError(0, "", "", errors.ErrCodeTooManyDuplicates, msg),
+	})
+	return errors.ErrTooManyDuplicates(msg)
+}
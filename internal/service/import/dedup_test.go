@@ -0,0 +1,41 @@
+package importservice
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDuplicateTracker_CountsRepeats(t *testing.T) {
+	tracker := newDuplicateTracker(1000)
+	for _, key := range []string{"a@x.com", "b@x.com", "a@x.com", "", "", "c@x.com", "b@x.com"} {
+		tracker.Seen(key)
+	}
+	if got := tracker.Count(); got != 2 {
+		t.Errorf("Count() = %d, want 2", got)
+	}
+}
+
+func TestDuplicateTracker_FalsePositiveRate(t *testing.T) {
+	const n = 100000
+	tracker := newDuplicateTracker(n)
+	for i := 0; i < n; i++ {
+		tracker.Seen(fmt.Sprintf("user%d@example.com", i))
+	}
+	// Sized for a 1% false positive rate; allow some slack
+	if got := tracker.Count(); got > n/50 {
+		t.Errorf("Count() = %d for %d distinct keys, want at most %d", got, n, n/50)
+	}
+}
+
+func BenchmarkDuplicateTracker_Seen(b *testing.B) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+	tracker := newDuplicateTracker(b.N)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tracker.Seen(keys[i%len(keys)])
+	}
+}
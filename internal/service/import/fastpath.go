@@ -0,0 +1,109 @@
+package importservice
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// memoryBuffer keeps the staging rows of a small import in memory so dedup
+// and foreign key checks can run against them directly instead of through
+// the staging tables. Once a file grows past limit rows the buffered rows
+// are written out with spill and every later batch goes straight there.
+type memoryBuffer[S any] struct {
+	limit     int
+	batchSize int
+	rows      []S
+	spilled   bool
+}
+
+// newMemoryBuffer returns a buffer holding up to limit rows. A limit of 0
+// turns the fast path off, so every row is staged.
+func newMemoryBuffer[S any](limit, batchSize int) *memoryBuffer[S] {
+	return &memoryBuffer[S]{limit: limit, batchSize: batchSize, spilled: limit <= 0}
+}
+
+// inMemory reports whether all of the file's rows are still held in memory
+func (b *memoryBuffer[S]) inMemory() bool {
+	return !b.spilled
+}
+
+// bypass sends every row to spill, for a file whose earlier rows are in
+// the staging tables already
+func (b *memoryBuffer[S]) bypass() {
+	b.spilled = true
+}
+
+// stage buffers rows, or hands them to spill once the file is too large to
+// keep in memory
+func (b *memoryBuffer[S]) stage(ctx context.Context, jobID uuid.UUID, rows []S, spill func(context.Context, uuid.UUID, []S) error) error {
+	if b.spilled {
+		return spill(ctx, jobID, rows)
+	}
+
+	b.rows = append(b.rows, rows...)
+	if len(b.rows) <= b.limit {
+		return nil
+	}
+
+	b.spilled = true
+	buffered := b.rows
+	b.rows = nil
+	return b.batches(buffered, func(batch []S) error {
+		return spill(ctx, jobID, batch)
+	})
+}
+
+// each calls fn with the buffered rows in batches
+func (b *memoryBuffer[S]) each(fn func([]S) error) error {
+	return b.batches(b.rows, fn)
+}
+
+func (b *memoryBuffer[S]) batches(rows []S, fn func([]S) error) error {
+	size := max(b.batchSize, 1)
+	for start := 0; start < len(rows); start += size {
+		if err := fn(rows[start:min(start+size, len(rows))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memoryBuffer[S]) reset() {
+	b.rows = nil
+}
+
+// markRepeats calls mark on every row whose key repeats an earlier row's,
+// keeping the first occurrence like the staging ROW_NUMBER() checks do.
+// Rows without a key are skipped.
+func markRepeats[S any](rows []S, key func(*S) *string, mark func(*S)) int {
+	seen := make(map[string]bool, len(rows))
+	marked := 0
+	for i := range rows {
+		k := key(&rows[i])
+		if k == nil {
+			continue
+		}
+		if seen[*k] {
+			mark(&rows[i])
+			marked++
+			continue
+		}
+		seen[*k] = true
+	}
+	return marked
+}
+
+// collectKeys returns the non-nil keys of the rows keep accepts
+func collectKeys[S any](rows []S, keep func(*S) bool, key func(*S) *string) []string {
+	keys := make([]string, 0, len(rows))
+	for i := range rows {
+		if !keep(&rows[i]) {
+			continue
+		}
+		if k := key(&rows[i]); k != nil {
+			keys = append(keys, *k)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,70 @@
+package importservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+func TestMemoryBuffer_SpillsPastLimit(t *testing.T) {
+	buf := newMemoryBuffer[int](3, 2)
+	var spilled [][]int
+	spill := func(_ context.Context, _ uuid.UUID, rows []int) error {
+		spilled = append(spilled, append([]int(nil), rows...))
+		return nil
+	}
+
+	ctx := context.Background()
+	if err := buf.stage(ctx, uuid.Nil, []int{1, 2}, spill); err != nil {
+		t.Fatalf("stage() error: %v", err)
+	}
+	if !buf.inMemory() || len(spilled) != 0 {
+		t.Fatalf("buffer spilled under its limit: %v", spilled)
+	}
+
+	if err := buf.stage(ctx, uuid.Nil, []int{3, 4}, spill); err != nil {
+		t.Fatalf("stage() error: %v", err)
+	}
+	if buf.inMemory() || len(buf.rows) != 0 {
+		t.Fatal("buffer still in memory past its limit")
+	}
+	if len(spilled) != 2 || len(spilled[0]) != 2 || len(spilled[1]) != 2 {
+		t.Errorf("spilled = %v, want the buffered rows in batches of 2", spilled)
+	}
+
+	if err := buf.stage(ctx, uuid.Nil, []int{5}, spill); err != nil {
+		t.Fatalf("stage() error: %v", err)
+	}
+	if len(spilled) != 3 {
+		t.Errorf("rows after the spill were not passed through: %v", spilled)
+	}
+}
+
+func TestMemoryBuffer_ZeroLimitAlwaysStages(t *testing.T) {
+	if newMemoryBuffer[int](0, 10).inMemory() {
+		t.Error("inMemory() = true with the fast path off")
+	}
+}
+
+func TestMarkRepeats_KeepsFirstOccurrence(t *testing.T) {
+	a, b := "a@example.com", "b@example.com"
+	rows := []repository.StagingUser{
+		{RowNumber: 1, Email: &a, IsValid: true},
+		{RowNumber: 2, Email: &b, IsValid: true},
+		{RowNumber: 3, Email: &a, IsValid: true},
+		{RowNumber: 4},
+	}
+
+	email := func(su *repository.StagingUser) *string { return su.Email }
+	if marked := markRepeats(rows, email, markUserDuplicate); marked != 1 {
+		t.Fatalf("markRepeats() = %d, want 1", marked)
+	}
+	if rows[0].IsDuplicate || rows[1].IsDuplicate || rows[3].IsDuplicate {
+		t.Errorf("rows = %+v, want only the repeat marked", rows)
+	}
+	if !rows[2].IsDuplicate || rows[2].IsValid || *rows[2].ValidationError != "DUPLICATE_EMAIL" {
+		t.Errorf("third row = %+v, want a DUPLICATE_EMAIL duplicate", rows[2])
+	}
+}
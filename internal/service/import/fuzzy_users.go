@@ -0,0 +1,161 @@
+package importservice
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+const (
+	// fuzzyLocalSimilarity is how alike two email local parts must be, from
+	// 0 to 1, for users with the same name to be probable duplicates
+	fuzzyLocalSimilarity = 0.75
+	// fuzzyMaxCandidates caps the earlier users with the same name each row
+	// is compared with
+	fuzzyMaxCandidates = 20
+)
+
+// fuzzyUser is an earlier row a later one may duplicate
+type fuzzyUser struct {
+	row   int
+	email string
+	local string // canonical local part without separators
+}
+
+// fuzzyUserMatcher finds users probably repeating an earlier row of the
+// file: their emails are the same Gmail mailbox, or their names match and
+// their email local parts are alike. Exact email repeats are left to dedup.
+type fuzzyUserMatcher struct {
+	seen    map[string]bool
+	byEmail map[string]fuzzyUser
+	byName  map[string][]fuzzyUser
+}
+
+func newFuzzyUserMatcher() *fuzzyUserMatcher {
+	return &fuzzyUserMatcher{
+		seen:    make(map[string]bool),
+		byEmail: make(map[string]fuzzyUser),
+		byName:  make(map[string][]fuzzyUser),
+	}
+}
+
+// Match reports the earlier row user probably duplicates, then remembers user
+func (m *fuzzyUserMatcher) Match(row int, user *models.UserImport) (fuzzyUser, bool) {
+	email := strings.ToLower(strings.TrimSpace(user.Email))
+	if m.seen[email] {
+		return fuzzyUser{}, false
+	}
+	m.seen[email] = true
+
+	canonical := canonicalEmail(email)
+	local, _, _ := strings.Cut(canonical, "@")
+	candidate := fuzzyUser{row: row, email: email, local: stripSeparators(local)}
+	name := normalizeName(user.Name)
+
+	match, found := m.byEmail[canonical]
+	if !found && name != "" {
+		for _, earlier := range m.byName[name] {
+			if similarity(earlier.local, candidate.local) >= fuzzyLocalSimilarity {
+				match, found = earlier, true
+				break
+			}
+		}
+	}
+
+	if _, ok := m.byEmail[canonical]; !ok {
+		m.byEmail[canonical] = candidate
+	}
+	if name != "" && len(m.byName[name]) < fuzzyMaxCandidates {
+		m.byName[name] = append(m.byName[name], candidate)
+	}
+	return match, found
+}
+
+// fuzzyDedup checks user against the earlier rows of the file when the job
+// asked for it, returning a warning or, in review mode, an error holding the
+// row back
+func (u *userStages) fuzzyDedup(row int, user *models.UserImport) (errs, warns []*errors.ValidationError) {
+	if u.fuzzy == nil {
+		return nil, nil
+	}
+	match, ok := u.fuzzy.Match(row, user)
+	if !ok {
+		return nil, nil
+	}
+
+	detail := fmt.Sprintf("row %d (%s)", match.row, match.email)
+	if u.fuzzyMode == models.UserFuzzyDedupReview {
+		return []*errors.ValidationError{
+			errors.NewValidationError(row, user.Email, "email", errors.ErrCodeNeedsReview, "Probable duplicate of "+detail+"; held for review"),
+		}, nil
+	}
+	return nil, []*errors.ValidationError{
+		errors.NewValidationError(row, user.Email, "email", errors.WarnCodeProbableDuplicate, "Probable duplicate of "+detail),
+	}
+}
+
+// canonicalEmail maps Gmail addresses to their mailbox: dots and any +tag
+// are dropped from the local part and googlemail.com becomes gmail.com.
+// Other addresses are returned as they are.
+func canonicalEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || (domain != "gmail.com" && domain != "googlemail.com") {
+		return email
+	}
+	local, _, _ = strings.Cut(local, "+")
+	return strings.ReplaceAll(local, ".", "") + "@gmail.com"
+}
+
+// normalizeName lowercases name and sorts its words, so "Smith, John" and
+// "john  smith" compare equal
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slices.Sort(words)
+	return strings.Join(words, " ")
+}
+
+// stripSeparators drops the dots, dashes and underscores people vary
+// between addresses
+func stripSeparators(local string) string {
+	local, _, _ = strings.Cut(local, "+")
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}, local)
+}
+
+// similarity is 1 minus the edit distance between a and b over the longer
+// length
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
@@ -0,0 +1,47 @@
+package importservice
+
+import (
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := map[string]string{
+		"john.smith+news@gmail.com":   "johnsmith@gmail.com",
+		"j.o.h.n@googlemail.com":      "john@gmail.com",
+		"john.smith+news@example.com": "john.smith+news@example.com",
+		"not-an-email":                "not-an-email",
+	}
+	for email, want := range tests {
+		if got := canonicalEmail(email); got != want {
+			t.Errorf("canonicalEmail(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
+
+func TestFuzzyUserMatcher(t *testing.T) {
+	m := newFuzzyUserMatcher()
+	rows := []struct {
+		email, name string
+		wantRow     int // 0 for no match
+	}{
+		{"john.smith@gmail.com", "John Smith", 0},
+		{"johnsmith+work@gmail.com", "J. Smith", 1},  // same Gmail mailbox
+		{"john_smith@example.com", "Smith, John", 1}, // same name, alike local part
+		{"jane.doe@example.com", "John Smith", 0},    // same name, different local part
+		{"john.smith@gmail.com", "John Smith", 0},    // exact repeat, left to dedup
+		{"jdoe@example.com", "Jane Doe", 0},          // different name
+		{"jane.doe1@example.org", "jane   doe", 0},   // same name as row 6, different local part
+		{"jane.doe@example.net", "Doe Jane", 7},      // same name, alike local part to row 7
+	}
+	for i, r := range rows {
+		match, ok := m.Match(i+1, &models.UserImport{Email: r.email, Name: r.name})
+		switch {
+		case r.wantRow == 0 && ok:
+			t.Errorf("row %d (%s) matched row %d, want no match", i+1, r.email, match.row)
+		case r.wantRow != 0 && (!ok || match.row != r.wantRow):
+			t.Errorf("row %d (%s) = row %d, %v; want row %d", i+1, r.email, match.row, ok, r.wantRow)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package importservice
 
 import (
+	"bufio"
+	"bytes"
 	"context"
-	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"mime"
@@ -12,60 +14,172 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rohit/bulk-import-export/internal/config"
 	"github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/langdetect"
 	"github.com/rohit/bulk-import-export/internal/metrics"
 	"github.com/rohit/bulk-import-export/internal/repository"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
 	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
 	"github.com/rohit/bulk-import-export/internal/service/validation"
+	"github.com/rohit/bulk-import-export/internal/storage"
 	"github.com/rs/zerolog"
 )
 
 // Service handles import operations
 type Service struct {
-	userRepo    *postgres.UserRepository
-	articleRepo *postgres.ArticleRepository
-	commentRepo *postgres.CommentRepository
-	jobRepo     *postgres.JobRepository
-	stagingRepo *postgres.StagingRepository
+	userRepo    repository.UserRepository
+	articleRepo repository.ArticleRepository
+	commentRepo repository.CommentRepository
+	jobRepo     repository.JobRepository
+	stagingRepo repository.StagingRepository
+	profileRepo repository.ProfileRepository
+	db          repository.Database
+	store       storage.Driver
 	metrics     *metrics.Collector
 	logger      zerolog.Logger
-	config      config.ImportConfig
+	config      atomic.Pointer[config.ImportConfig]
+	encoding    parsers.Encoding
 	validator   *validation.Validator
-	mu          sync.Mutex
+	hooks       hooks.Registry
+	ledger      repository.LedgerRepository // nil until SetLedger
+	// uploadSessions is nil until SetUploadSessions
+	uploadSessions repository.UploadSessionRepository
+	batchLog       repository.BatchLogRepository // nil until SetBatchLog
+	breaker        breaker
+	mu             sync.Mutex
 }
 
 // NewService creates a new import service
 func NewService(
-	userRepo *postgres.UserRepository,
-	articleRepo *postgres.ArticleRepository,
-	commentRepo *postgres.CommentRepository,
-	jobRepo *postgres.JobRepository,
-	stagingRepo *postgres.StagingRepository,
+	userRepo repository.UserRepository,
+	articleRepo repository.ArticleRepository,
+	commentRepo repository.CommentRepository,
+	jobRepo repository.JobRepository,
+	stagingRepo repository.StagingRepository,
+	profileRepo repository.ProfileRepository,
+	db repository.Database,
+	store storage.Driver,
 	metrics *metrics.Collector,
 	logger zerolog.Logger,
 	cfg config.ImportConfig,
 ) *Service {
-	return &Service{
+	encoding, err := parsers.ParseEncoding(cfg.Encoding)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Falling back to automatic import encoding detection")
+		encoding = parsers.EncodingAuto
+	}
+
+	validator := validation.NewValidator()
+	validator.Article.SetBodyLimit(cfg.ArticleMaxBodyBytes, cfg.ArticleBodyOverflow == "truncate")
+	validator.User.SetDomainPolicy(cfg.EmailAllowDomains, cfg.EmailDenyDomains, cfg.EmailDenyDisposable)
+	screener := validation.NewScreener(validation.NewRegexScanner(cfg.ScreenBannedTerms, cfg.ScreenPII), cfg.ScreenMode)
+	validator.Article.SetScreener(screener)
+	validator.Comment.SetScreener(screener)
+
+	s := &Service{
 		userRepo:    userRepo,
 		articleRepo: articleRepo,
 		commentRepo: commentRepo,
 		jobRepo:     jobRepo,
 		stagingRepo: stagingRepo,
+		profileRepo: profileRepo,
+		db:          db,
+		store:       store,
 		metrics:     metrics,
 		logger:      logger,
-		config:      cfg,
-		validator:   validation.NewValidator(),
+		encoding:    encoding,
+		validator:   validator,
+	}
+	s.config.Store(&cfg)
+	return s
+}
+
+// maxLineSize is the longest NDJSON line an import reads
+func (s *Service) maxLineSize() int {
+	if size := s.config.Load().MaxLineSize; size > 0 {
+		return size
+	}
+	return parsers.DefaultMaxLineSize
+}
+
+// detectLang reports whether an import tags rows with their language,
+// either because the job asked for it or because it is on for every job
+func (s *Service) detectLang(job *models.Job) bool {
+	return s.config.Load().DetectLanguage || (job.Params != nil && job.Params.DetectLang)
+}
+
+// flattener compiles the job's field paths, or returns nil when it has none
+func flattener(job *models.Job) (*parsers.Flattener, error) {
+	if job.Params == nil || len(job.Params.FieldPaths) == 0 {
+		return nil, nil
+	}
+	f, err := parsers.NewFlattener(job.Params.FieldPaths, job.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field paths: %w", err)
+	}
+	return f, nil
+}
+
+// bodyLang returns the detected language of body, or nil when it cannot
+// be told
+func bodyLang(body string) *string {
+	lang := langdetect.Detect(body)
+	if lang == "" {
+		return nil
 	}
+	return &lang
+}
+
+// SetConfig replaces the import settings when they are reloaded. The file
+// encoding and validation policies are fixed when the service is created.
+func (s *Service) SetConfig(cfg config.ImportConfig) {
+	s.config.Store(&cfg)
+}
+
+// provenance returns the import job a staged row is written for as the
+// ImportJobID of its record, nil for a row staged outside a job
+func provenance(jobID uuid.UUID) *uuid.UUID {
+	if jobID == uuid.Nil {
+		return nil
+	}
+	return &jobID
+}
+
+// SetLedger sets the repository of the import ledger, used while
+// IMPORT_LEDGER is on
+func (s *Service) SetLedger(ledger repository.LedgerRepository) {
+	s.ledger = ledger
+}
+
+// SetBatchLog sets the repository imports record each batch they write in.
+// Without it a job's counts are tallied in memory as its batches commit.
+func (s *Service) SetBatchLog(batchLog repository.BatchLogRepository) {
+	s.batchLog = batchLog
+}
+
+// SetScanner screens article and comment bodies with scanner instead of the
+// built-in banned term and personal data patterns, such as to plug in an
+// entity recognizer. The configured screening mode still applies, so with
+// IMPORT_SCREEN_MODE off nothing is screened.
+func (s *Service) SetScanner(scanner validation.Scanner) {
+	screener := validation.NewScreener(scanner, s.config.Load().ScreenMode)
+	s.validator.Article.SetScreener(screener)
+	s.validator.Comment.SetScreener(screener)
+}
+
+// RegisterHooks adds lifecycle hooks that are called for every import job
+func (s *Service) RegisterHooks(h hooks.Hooks) {
+	s.hooks.Register(h)
 }
 
 // ProcessJob processes an import job
-func (s *Service) ProcessJob(ctx context.Context, job *models.Job) error {
+func (s *Service) ProcessJob(ctx context.Context, job *models.Job) (err error) {
 	log := s.logger.With().
 		Str("job_id", job.ID.String()).
 		Str("resource", string(job.Resource)).
@@ -80,6 +194,8 @@ func (s *Service) ProcessJob(ctx context.Context, job *models.Job) error {
 	}
 
 	s.metrics.RecordImportJobStarted(string(job.Resource))
+	s.hooks.OnJobStart(ctx, job)
+	defer func() { s.jobEnded(ctx, job, err) }()
 
 	// Open file
 	filePath := ""
@@ -110,8 +226,8 @@ func (s *Service) ProcessJob(ctx context.Context, job *models.Job) error {
 	duration := time.Since(startTime).Seconds()
 
 	if processErr != nil {
-		s.handleJobFailure(ctx, job, log, processErr.Error())
-		s.metrics.RecordImportJobCompleted(string(job.Resource), "failed", duration)
+		status := s.stopJob(ctx, job, log, processErr)
+		s.metrics.RecordImportJobCompleted(string(job.Resource), status, duration)
 		return processErr
 	}
 
@@ -135,7 +251,7 @@ func (s *Service) ProcessJob(ctx context.Context, job *models.Job) error {
 }
 
 // ProcessImport processes an import job with a provided file
-func (s *Service) ProcessImport(ctx context.Context, file *os.File, job *models.Job, format string) error {
+func (s *Service) ProcessImport(ctx context.Context, file *os.File, job *models.Job, format string) (err error) {
 	log := s.logger.With().
 		Str("job_id", job.ID.String()).
 		Str("resource", string(job.Resource)).
@@ -151,6 +267,8 @@ func (s *Service) ProcessImport(ctx context.Context, file *os.File, job *models.
 	}
 
 	s.metrics.RecordImportJobStarted(string(job.Resource))
+	s.hooks.OnJobStart(ctx, job)
+	defer func() { s.jobEnded(ctx, job, err) }()
 
 	// Process based on resource type
 	var processErr error
@@ -168,8 +286,8 @@ func (s *Service) ProcessImport(ctx context.Context, file *os.File, job *models.
 	duration := time.Since(startTime).Seconds()
 
 	if processErr != nil {
-		s.handleJobFailure(ctx, job, log, processErr.Error())
-		s.metrics.RecordImportJobCompleted(string(job.Resource), "failed", duration)
+		status := s.stopJob(ctx, job, log, processErr)
+		s.metrics.RecordImportJobCompleted(string(job.Resource), status, duration)
 		return processErr
 	}
 
@@ -193,509 +311,231 @@ func (s *Service) ProcessImport(ctx context.Context, file *os.File, job *models.
 	return nil
 }
 
-func (s *Service) processUsersImport(ctx context.Context, job *models.Job, file *os.File, log zerolog.Logger) error {
-	// Detect file format from the actual file path
-	format := parsers.DetectFormat(file.Name())
-
-	// First pass: parse and validate, store in staging
-	stagingBatch := make([]repository.StagingUser, 0, s.config.BatchSize)
-	var validationErrors []*errors.ValidationError
-	totalRows := 0
-	validRows := 0
-	invalidRows := 0
-
-	// Helper function to process a user record
-	processUser := func(row int, user *models.UserImport, parseError bool) error {
-		totalRows++
-
-		stagingUser := repository.StagingUser{
-			JobID:     job.ID,
-			RowNumber: row,
-		}
-
-		if parseError || user == nil {
-			stagingUser.IsValid = false
-			errMsg := errors.ErrCodeFileParseError + ": Invalid record format"
-			stagingUser.ValidationError = &errMsg
-			invalidRows++
-			stagingBatch = append(stagingBatch, stagingUser)
-			return nil
-		}
+// CurrentRate returns the rows per second of an import running on this
+// instance
+func (s *Service) CurrentRate(jobID uuid.UUID) (float64, bool) {
+	return s.metrics.JobRate(jobID.String())
+}
 
-		// Validate user
-		errs := s.validator.User.ValidateUserImport(row, user)
+// ProfileImport gathers per-column statistics for the import file and stores
+// them on the job. It reads the file to the end; callers must rewind it.
+func (s *Service) ProfileImport(ctx context.Context, job *models.Job, file *os.File) error {
+	startTime := time.Now()
+	format := parsers.DetectFormat(file.Name())
 
-		if user.ID != "" {
-			stagingUser.ID = &user.ID
-		}
-		if user.Email != "" {
-			email := strings.ToLower(strings.TrimSpace(user.Email))
-			stagingUser.Email = &email
-		}
-		if user.Name != "" {
-			stagingUser.Name = &user.Name
-		}
-		if user.Role != "" {
-			role := strings.ToLower(user.Role)
-			stagingUser.Role = &role
-		}
-		if user.Active != "" {
-			active := strings.ToLower(user.Active) == "true"
-			stagingUser.Active = &active
+	var profiler *parsers.Profiler
+	var err error
+	switch {
+	case format.IsColumnar():
+		var parser *parsers.ColumnarParser
+		if parser, err = parsers.NewColumnarFileParser(file); err == nil {
+			profiler, err = parsers.ProfileColumnar(parser)
 		}
-		if user.CreatedAt != "" {
-			stagingUser.CreatedAt = &user.CreatedAt
+	case format.IsXLSX():
+		var parser *parsers.XLSXParser
+		if parser, err = parsers.NewXLSXFileParser(file); err == nil {
+			profiler, err = parsers.ProfileXLSX(parser)
 		}
-		if user.UpdatedAt != "" {
-			stagingUser.UpdatedAt = &user.UpdatedAt
+	default:
+		var r io.Reader
+		if r, _, err = parsers.Decompress(file); err != nil {
+			break
 		}
-
-		if len(errs) > 0 {
-			stagingUser.IsValid = false
-			errMsg := errs[0].Code + ": " + errs[0].Message
-			stagingUser.ValidationError = &errMsg
-			validationErrors = append(validationErrors, errs...)
-			invalidRows++
+		if format.IsCSV() {
+			profiler, err = parsers.ProfileCSV(r)
 		} else {
-			stagingUser.IsValid = true
-			validRows++
-		}
-
-		stagingBatch = append(stagingBatch, stagingUser)
-
-		// Batch insert staging records
-		if len(stagingBatch) >= s.config.BatchSize {
-			if err := s.stagingRepo.CreateStagingUsers(ctx, job.ID, stagingBatch); err != nil {
-				return fmt.Errorf("failed to create staging users: %w", err)
-			}
-			stagingBatch = stagingBatch[:0]
-
-			// Update progress
-			s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, validRows, invalidRows)
+			profiler, err = parsers.ProfileNDJSON(r)
 		}
-
-		return nil
 	}
-
-	var err error
-	if format.IsNDJSON() {
-		// Use NDJSON parser
-		ndjsonParser := parsers.NewNDJSONParser(file)
-		err = ndjsonParser.ParseUsers(func(row int, user *models.UserImport, rawJSON string) error {
-			return processUser(row, user, user == nil)
-		})
-	} else {
-		// Use CSV parser (default)
-		csvParser, parserErr := parsers.NewCSVParser(file)
-		if parserErr != nil {
-			return fmt.Errorf("failed to create CSV parser: %w", parserErr)
-		}
-		err = csvParser.ParseUsers(func(row int, user *models.UserImport) error {
-			return processUser(row, user, false)
-		})
-	}
-
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to profile file: %w", err)
 	}
 
-	// Insert remaining staging batch
-	if len(stagingBatch) > 0 {
-		if err := s.stagingRepo.CreateStagingUsers(ctx, job.ID, stagingBatch); err != nil {
-			return fmt.Errorf("failed to create staging users: %w", err)
-		}
+	profile := &models.ImportProfile{
+		JobID:     job.ID,
+		Format:    string(format),
+		Rows:      profiler.Rows(),
+		Columns:   profiler.Columns(),
+		CreatedAt: time.Now().UTC(),
 	}
-
-	// Set total records
-	s.jobRepo.SetTotalRecords(ctx, job.ID, totalRows)
-
-	log.Info().
-		Int("total_rows", totalRows).
-		Int("initial_valid", validRows).
-		Int("initial_invalid", invalidRows).
-		Msg("First pass complete, checking duplicates")
-
-	// Mark duplicates within batch
-	dupInBatch, err := s.stagingRepo.MarkDuplicateUsersInBatch(ctx, job.ID)
-	if err != nil {
-		return fmt.Errorf("failed to mark duplicates in batch: %w", err)
+	if err := s.profileRepo.Save(ctx, profile); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
 	}
 
-	// Mark duplicates against existing data
-	dupAgainstExisting, err := s.stagingRepo.MarkDuplicateUsersAgainstExisting(ctx, job.ID)
-	if err != nil {
-		return fmt.Errorf("failed to mark duplicates against existing: %w", err)
-	}
-
-	invalidRows += dupInBatch + dupAgainstExisting
-	validRows -= dupInBatch + dupAgainstExisting
-
-	log.Info().
-		Int("duplicates_in_batch", dupInBatch).
-		Int("duplicates_existing", dupAgainstExisting).
-		Msg("Duplicate check complete")
-
-	// Second pass: insert valid records to main table
-	successfulInserts := 0
-	err = s.stagingRepo.GetValidStagingUsers(ctx, job.ID, s.config.BatchSize, func(batch []repository.StagingUser) error {
-		users := make([]*models.User, 0, len(batch))
-		for _, su := range batch {
-			if su.IsValid && !su.IsDuplicate {
-				user, err := s.convertStagingToUser(&su)
-				if err != nil {
-					// Log error but continue
-					log.Warn().Err(err).Int("row", su.RowNumber).Msg("Failed to convert staging user")
-					continue
-				}
-				users = append(users, user)
-			}
-		}
-
-		if len(users) > 0 {
-			batchStart := time.Now()
-			count, err := s.userRepo.CreateBatch(ctx, users)
-			if err != nil {
-				return fmt.Errorf("failed to insert users batch: %w", err)
-			}
-			successfulInserts += count
-			s.metrics.RecordImportBatch(string(job.Resource), time.Since(batchStart).Seconds())
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return err
-	}
-
-	// Record validation errors
-	s.recordValidationErrors(ctx, job.ID, string(job.Resource), validationErrors)
-
-	// Cleanup staging table
-	s.stagingRepo.CleanupStagingUsers(ctx, job.ID)
-
-	// Update final counts
-	s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, successfulInserts, totalRows-successfulInserts)
+	s.logger.Info().
+		Str("job_id", job.ID.String()).
+		Int("rows", profile.Rows).
+		Int("columns", len(profile.Columns)).
+		Float64("duration_seconds", time.Since(startTime).Seconds()).
+		Msg("Import profile completed")
 
 	return nil
 }
 
-func (s *Service) processArticlesImport(ctx context.Context, job *models.Job, file *os.File, log zerolog.Logger) error {
-	// Detect file format from the actual file path
-	format := parsers.DetectFormat(file.Name())
-
-	stagingBatch := make([]repository.StagingArticle, 0, s.config.BatchSize)
-	var validationErrors []*errors.ValidationError
-	totalRows := 0
-	validRows := 0
-	invalidRows := 0
-
-	// Helper function to process an article record
-	processArticle := func(row int, article *models.ArticleImport, parseError bool) error {
-		totalRows++
-
-		stagingArticle := repository.StagingArticle{
-			JobID:     job.ID,
-			RowNumber: row,
-		}
-
-		if parseError || article == nil {
-			// Parse error
-			stagingArticle.IsValid = false
-			errMsg := errors.ErrCodeFileParseError + ": Invalid record format"
-			stagingArticle.ValidationError = &errMsg
-			invalidRows++
-			stagingBatch = append(stagingBatch, stagingArticle)
-			return nil
-		}
-
-		// Validate article
-		errs := s.validator.Article.ValidateArticleImport(row, article)
-
-		if article.ID != "" {
-			stagingArticle.ID = &article.ID
-		}
-		if article.Slug != "" {
-			slug := strings.ToLower(strings.TrimSpace(article.Slug))
-			slug = strings.ReplaceAll(slug, " ", "-")
-			stagingArticle.Slug = &slug
-		}
-		if article.Title != "" {
-			stagingArticle.Title = &article.Title
-		}
-		if article.Body != "" {
-			stagingArticle.Body = &article.Body
-		}
-		if article.AuthorID != "" {
-			stagingArticle.AuthorID = &article.AuthorID
-		}
-		if article.Tags != nil {
-			tagsJSON, _ := json.Marshal(article.Tags)
-			tags := string(tagsJSON)
-			stagingArticle.Tags = &tags
-		}
-		if article.PublishedAt != "" {
-			stagingArticle.PublishedAt = &article.PublishedAt
-		}
-		if article.Status != "" {
-			status := strings.ToLower(article.Status)
-			stagingArticle.Status = &status
-		}
-
-		if len(errs) > 0 {
-			stagingArticle.IsValid = false
-			errMsg := errs[0].Code + ": " + errs[0].Message
-			stagingArticle.ValidationError = &errMsg
-			validationErrors = append(validationErrors, errs...)
-			invalidRows++
-		} else {
-			stagingArticle.IsValid = true
-			validRows++
-		}
-
-		stagingBatch = append(stagingBatch, stagingArticle)
-
-		if len(stagingBatch) >= s.config.BatchSize {
-			if err := s.stagingRepo.CreateStagingArticles(ctx, job.ID, stagingBatch); err != nil {
-				return fmt.Errorf("failed to create staging articles: %w", err)
-			}
-			stagingBatch = stagingBatch[:0]
-			s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, validRows, invalidRows)
-		}
-
+// ArchiveUpload copies an uploaded import file to remote storage so it
+// outlives the local upload directory. It is a no-op for local storage.
+func (s *Service) ArchiveUpload(ctx context.Context, filePath string) error {
+	if !storage.IsRemote(s.store) {
 		return nil
 	}
-
-	var err error
-	if format.IsCSV() {
-		// Use CSV parser
-		csvParser, parserErr := parsers.NewCSVParser(file)
-		if parserErr != nil {
-			return fmt.Errorf("failed to create CSV parser: %w", parserErr)
-		}
-		err = csvParser.ParseArticles(func(row int, article *models.ArticleImport) error {
-			return processArticle(row, article, false)
-		})
-	} else {
-		// Use NDJSON parser (default for articles)
-		ndjsonParser := parsers.NewNDJSONParser(file)
-		err = ndjsonParser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string) error {
-			return processArticle(row, article, article == nil)
-		})
+	name, err := filepath.Rel(s.config.Load().UploadPath, filePath)
+	if err != nil || strings.HasPrefix(name, "..") {
+		name = filepath.Base(filePath)
 	}
+	return storage.PutFile(ctx, s.store, storage.UploadKey(name), filePath, "application/octet-stream")
+}
 
+// DetectResource infers the resource of a saved import file from its headers
+func (s *Service) DetectResource(filePath string) (*parsers.ResourceDetection, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
+	defer file.Close()
 
-	// Insert remaining
-	if len(stagingBatch) > 0 {
-		if err := s.stagingRepo.CreateStagingArticles(ctx, job.ID, stagingBatch); err != nil {
-			return fmt.Errorf("failed to create staging articles: %w", err)
+	if parsers.DetectFormat(filePath).IsColumnar() {
+		parser, err := parsers.NewColumnarFileParser(file)
+		if err != nil {
+			return nil, err
 		}
+		return parsers.ScoreResourceFields(parser.Fields()), nil
 	}
-
-	s.jobRepo.SetTotalRecords(ctx, job.ID, totalRows)
-
-	// Mark duplicates
-	dupInBatch, _ := s.stagingRepo.MarkDuplicateArticlesInBatch(ctx, job.ID)
-	dupAgainstExisting, _ := s.stagingRepo.MarkDuplicateArticlesAgainstExisting(ctx, job.ID)
-
-	// Validate foreign keys (author_id must exist in users table)
-	invalidFKs, _ := s.stagingRepo.MarkInvalidAuthorFKArticles(ctx, job.ID)
-
-	log.Info().
-		Int("total_rows", totalRows).
-		Int("duplicates_in_batch", dupInBatch).
-		Int("duplicates_existing", dupAgainstExisting).
-		Int("invalid_author_fks", invalidFKs).
-		Msg("Validation and deduplication complete")
-
-	// Insert valid records
-	successfulInserts := 0
-	err = s.stagingRepo.GetValidStagingArticles(ctx, job.ID, s.config.BatchSize, func(batch []repository.StagingArticle) error {
-		articles := make([]*models.Article, 0, len(batch))
-		for _, sa := range batch {
-			if sa.IsValid && !sa.IsDuplicate {
-				article, err := s.convertStagingToArticle(&sa)
-				if err != nil {
-					continue
-				}
-				articles = append(articles, article)
-			}
-		}
-
-		if len(articles) > 0 {
-			batchStart := time.Now()
-			count, err := s.articleRepo.CreateBatch(ctx, articles)
-			if err != nil {
-				return err
-			}
-			successfulInserts += count
-			s.metrics.RecordImportBatch(string(job.Resource), time.Since(batchStart).Seconds())
+	if parsers.DetectFormat(filePath).IsXLSX() {
+		parser, err := parsers.NewXLSXFileParser(file)
+		if err != nil {
+			return nil, err
 		}
-
-		return nil
-	})
-
+		return parsers.ScoreResourceFields(parser.Fields()), nil
+	}
+	r, _, err := parsers.Decompress(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	s.recordValidationErrors(ctx, job.ID, string(job.Resource), validationErrors)
-	s.stagingRepo.CleanupStagingArticles(ctx, job.ID)
-	s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, successfulInserts, totalRows-successfulInserts)
-
-	return nil
+	return parsers.DetectResource(r, parsers.DetectFormat(filePath))
 }
 
-func (s *Service) processCommentsImport(ctx context.Context, job *models.Job, file *os.File, log zerolog.Logger) error {
-	// Detect file format from the actual file path
-	format := parsers.DetectFormat(file.Name())
-
-	stagingBatch := make([]repository.StagingComment, 0, s.config.BatchSize)
-	var validationErrors []*errors.ValidationError
-	totalRows := 0
-	validRows := 0
-	invalidRows := 0
-
-	// Helper function to process a comment record
-	processComment := func(row int, comment *models.CommentImport, parseError bool) error {
-		totalRows++
-
-		stagingComment := repository.StagingComment{
-			JobID:     job.ID,
-			RowNumber: row,
-		}
-
-		if parseError || comment == nil {
-			stagingComment.IsValid = false
-			errMsg := errors.ErrCodeFileParseError + ": Invalid record format"
-			stagingComment.ValidationError = &errMsg
-			invalidRows++
-			stagingBatch = append(stagingBatch, stagingComment)
-			return nil
-		}
-
-		errs := s.validator.Comment.ValidateCommentImport(row, comment)
+// CountRows returns the number of non-blank data rows in a saved import
+// file, not counting a CSV header. A quoted CSV field spanning lines counts
+// once per line, so this is an upper bound for such files. Avro and Parquet
+// files count their records, and XLSX workbooks the rows with a value of
+// their first sheet. Compressed files are counted as they inflate.
+func (s *Service) CountRows(filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
 
-		if comment.ID != "" {
-			stagingComment.ID = &comment.ID
+	if format := parsers.DetectFormat(filePath); format.IsColumnar() {
+		if parsers.IsCompressed(file) {
+			return 0, fmt.Errorf("gzip-compressed %s files are not supported", format)
 		}
-		if comment.ArticleID != "" {
-			stagingComment.ArticleID = &comment.ArticleID
-		}
-		if comment.UserID != "" {
-			stagingComment.UserID = &comment.UserID
+		info, err := file.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %w", err)
 		}
-		if comment.Body != "" {
-			stagingComment.Body = &comment.Body
+		rows, err := parsers.CountColumnarRows(file, info.Size(), format)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s file: %w", format, err)
 		}
-		if comment.CreatedAt != "" {
-			stagingComment.CreatedAt = &comment.CreatedAt
+		return rows, nil
+	}
+	if parsers.DetectFormat(filePath).IsXLSX() {
+		if parsers.IsCompressed(file) {
+			return 0, fmt.Errorf("gzip-compressed xlsx files are not supported")
 		}
-
-		if len(errs) > 0 {
-			stagingComment.IsValid = false
-			errMsg := errs[0].Code + ": " + errs[0].Message
-			stagingComment.ValidationError = &errMsg
-			validationErrors = append(validationErrors, errs...)
-			invalidRows++
-		} else {
-			stagingComment.IsValid = true
-			validRows++
+		info, err := file.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %w", err)
 		}
-
-		stagingBatch = append(stagingBatch, stagingComment)
-
-		if len(stagingBatch) >= s.config.BatchSize {
-			if err := s.stagingRepo.CreateStagingComments(ctx, job.ID, stagingBatch); err != nil {
-				return err
-			}
-			stagingBatch = stagingBatch[:0]
-			s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, validRows, invalidRows)
+		rows, err := parsers.CountXLSXRows(file, info.Size())
+		if err != nil {
+			return 0, fmt.Errorf("failed to read xlsx file: %w", err)
 		}
-
-		return nil
-	}
-
-	var err error
-	if format.IsCSV() {
-		// Use CSV parser
-		csvParser, parserErr := parsers.NewCSVParser(file)
-		if parserErr != nil {
-			return fmt.Errorf("failed to create CSV parser: %w", parserErr)
-		}
-		err = csvParser.ParseComments(func(row int, comment *models.CommentImport) error {
-			return processComment(row, comment, false)
-		})
-	} else {
-		// Use NDJSON parser (default for comments)
-		ndjsonParser := parsers.NewNDJSONParser(file)
-		err = ndjsonParser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string) error {
-			return processComment(row, comment, comment == nil)
-		})
+		return rows, nil
 	}
 
+	r, _, err := parsers.Decompress(file)
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	if len(stagingBatch) > 0 {
-		if err := s.stagingRepo.CreateStagingComments(ctx, job.ID, stagingBatch); err != nil {
-			return err
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), s.maxLineSize())
+	rows := 0
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			rows++
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
 
-	s.jobRepo.SetTotalRecords(ctx, job.ID, totalRows)
-
-	dupInBatch, _ := s.stagingRepo.MarkDuplicateCommentsInBatch(ctx, job.ID)
+	if parsers.DetectFormat(filePath).IsCSV() && rows > 0 {
+		rows--
+	}
+	return rows, nil
+}
 
-	// Validate foreign keys (article_id and user_id must exist)
-	invalidFKs, _ := s.stagingRepo.MarkInvalidFKComments(ctx, job.ID)
+// GetProfile returns the stored profile for a job, or nil if it was not profiled
+func (s *Service) GetProfile(ctx context.Context, jobID uuid.UUID) (*models.ImportProfile, error) {
+	return s.profileRepo.GetByJobID(ctx, jobID)
+}
 
-	log.Info().
-		Int("total_rows", totalRows).
-		Int("duplicates_in_batch", dupInBatch).
-		Int("invalid_fks", invalidFKs).
-		Msg("Validation and deduplication complete")
-
-	// Insert valid records
-	successfulInserts := 0
-	err = s.stagingRepo.GetValidStagingComments(ctx, job.ID, s.config.BatchSize, func(batch []repository.StagingComment) error {
-		comments := make([]*models.Comment, 0, len(batch))
-		for _, sc := range batch {
-			if sc.IsValid && !sc.IsDuplicate {
-				comment, err := s.convertStagingToComment(&sc)
-				if err != nil {
-					continue
-				}
-				comments = append(comments, comment)
-			}
-		}
+// GetBatchCommits returns the batches a job wrote to the main table, by
+// attempt and batch, or nil when the service has no batch log
+func (s *Service) GetBatchCommits(ctx context.Context, jobID uuid.UUID) ([]*models.BatchCommit, error) {
+	if s.batchLog == nil {
+		return nil, nil
+	}
+	return s.batchLog.ListByJob(ctx, jobID)
+}
 
-		if len(comments) > 0 {
-			batchStart := time.Now()
-			count, err := s.commentRepo.CreateBatch(ctx, comments)
-			if err != nil {
-				return err
-			}
-			successfulInserts += count
-			s.metrics.RecordImportBatch(string(job.Resource), time.Since(batchStart).Seconds())
-		}
+// parseValidationError converts a row the parser rejected into a job error
+// that keeps the raw row text
+func parseValidationError(row int, raw string, parseErr *parsers.ParseError) *errors.ValidationError {
+	verr := errors.NewValidationError(row, "", "", errors.ErrCodeFileParseError, "Invalid record format")
+	if parseErr != nil {
+		verr.Code = parseErr.Code
+		verr.Message = parseErr.Err.Error()
+	}
+	verr.RawData = raw
+	return verr
+}
 
+// checkMinRows rejects a file with fewer data rows than the configured
+// minimum. An empty or header-only file usually means the upstream extract
+// failed, so it shouldn't pass as a successful import.
+func (s *Service) checkMinRows(ctx context.Context, job *models.Job, totalRows int) error {
+	cfg := s.config.Load()
+	if totalRows >= cfg.MinRows {
 		return nil
-	})
-
-	if err != nil {
-		return err
 	}
 
-	s.recordValidationErrors(ctx, job.ID, string(job.Resource), validationErrors)
-	s.stagingRepo.CleanupStagingComments(ctx, job.ID)
-	s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, successfulInserts, totalRows-successfulInserts)
+	msg := fmt.Sprintf("file contains %d data rows, at least %d required", totalRows, cfg.MinRows)
+	s.recordValidationErrors(ctx, job, []*errors.ValidationError{
+		errors.NewValidationError(0, "", "", errors.ErrCodeEmptyFile, msg),
+	})
+	return errors.ErrEmptyFile(msg)
+}
 
-	return nil
+// checkMaxRows handles a file that still had rows, from row on, after
+// MaxRows were read. In truncate mode the rows read are imported and the
+// returned warning says where the file was cut off; otherwise the job fails.
+// Uploads are normally rejected before they are queued, so this catches
+// files that were counted differently or queued under another limit.
+func (s *Service) checkMaxRows(ctx context.Context, job *models.Job, row int) (*errors.ValidationError, error) {
+	cfg := s.config.Load()
+	if cfg.RowLimitMode == "truncate" {
+		msg := fmt.Sprintf("file has more than %d data rows; rows from %d on were not imported", cfg.MaxRows, row)
+		return errors.NewValidationError(row, "", "", errors.WarnCodeRowLimitReached, msg), nil
+	}
+
+	msg := fmt.Sprintf("file has more than %d data rows", cfg.MaxRows)
+	s.recordValidationErrors(ctx, job, []*errors.ValidationError{
+		errors.NewValidationError(row, "", "", errors.ErrCodeRowLimitExceeded, msg),
+	})
+	return nil, errors.ErrRowLimitExceeded(msg)
 }
 
 func (s *Service) handleJobFailure(ctx context.Context, job *models.Job, log zerolog.Logger, errMsg string) {
@@ -703,204 +543,180 @@ func (s *Service) handleJobFailure(ctx context.Context, job *models.Job, log zer
 	s.jobRepo.SetFailed(ctx, job.ID, errMsg)
 }
 
-func (s *Service) recordValidationErrors(ctx context.Context, jobID uuid.UUID, resource string, errs []*errors.ValidationError) {
-	if len(errs) == 0 {
+// stopJob records the job as paused when the circuit breaker stopped it and
+// as failed otherwise, returning the status it recorded. A job cancelled by
+// request is already recorded as cancelled and is left so; one stopped by a
+// shutdown is still recorded as failed.
+func (s *Service) stopJob(ctx context.Context, job *models.Job, log zerolog.Logger, err error) string {
+	if models.JobCancelled(ctx) {
+		log.Info().Str("error", err.Error()).Msg("Import job cancelled")
+		job.Status = models.JobStatusCancelled
+		return string(models.JobStatusCancelled)
+	}
+	// The outcome is recorded even though ctx may be done
+	ctx = context.WithoutCancel(ctx)
+	if !stderrors.Is(err, ErrPaused) {
+		s.handleJobFailure(ctx, job, log, err.Error())
+		return "failed"
+	}
+
+	errMsg := err.Error()
+	log.Error().Str("error", errMsg).Msg("Import job paused by the circuit breaker")
+	if err := s.jobRepo.SetPaused(ctx, job.ID, errMsg); err != nil {
+		log.Error().Err(err).Msg("Failed to pause job")
+	}
+	job.Status = models.JobStatusPaused
+	job.ErrorMessage = &errMsg
+	return string(models.JobStatusPaused)
+}
+
+// jobEnded calls the hooks for a job that stopped running with err: paused
+// when the circuit breaker stopped it, complete otherwise
+func (s *Service) jobEnded(ctx context.Context, job *models.Job, err error) {
+	if stderrors.Is(err, ErrPaused) {
+		s.hooks.OnJobPaused(ctx, job, err)
 		return
 	}
+	s.hooks.OnJobComplete(ctx, job, err)
+}
+
+// recordValidationErrors stores the errors of job, keeping the first
+// IMPORT_MAX_ERRORS_PER_CODE of each code, and returns how many of each code
+// were left out
+func (s *Service) recordValidationErrors(ctx context.Context, job *models.Job, errs []*errors.ValidationError) map[string]int {
+	cfg := s.config.Load()
+	if len(errs) == 0 {
+		return nil
+	}
+	s.hooks.OnValidationError(ctx, job, errs)
 
-	jobErrors := make([]*models.JobError, 0, len(errs))
+	resource := string(job.Resource)
 	for _, e := range errs {
-		jobErrors = append(jobErrors, &models.JobError{
-			JobID:            jobID,
+		s.metrics.RecordImportError(resource, e.Code)
+	}
+
+	kept, suppressed := capErrors(errs, cfg.MaxErrorsPerCode)
+	if suppressed != nil {
+		s.logger.Warn().
+			Str("job_id", job.ID.String()).
+			Int("stored", len(kept)).
+			Int("suppressed", len(errs)-len(kept)).
+			Msg("Stored the first errors of each code only")
+	}
+
+	jobErrors := make([]*models.JobError, 0, len(kept))
+	for _, e := range kept {
+		jobError := &models.JobError{
+			JobID:            job.ID,
 			RowNumber:        e.RowNumber,
 			RecordIdentifier: &e.RecordIdentifier,
 			FieldName:        &e.FieldName,
 			ErrorCode:        e.Code,
 			ErrorMessage:     e.Message,
-		})
-
-		s.metrics.RecordImportError(resource, e.Code)
+		}
+		if e.RawData != "" {
+			jobError.RawData = &e.RawData
+		}
+		jobErrors = append(jobErrors, jobError)
 	}
 
 	// Batch insert errors
-	for i := 0; i < len(jobErrors); i += s.config.BatchSize {
-		end := i + s.config.BatchSize
+	for i := 0; i < len(jobErrors); i += cfg.BatchSize {
+		end := i + cfg.BatchSize
 		if end > len(jobErrors) {
 			end = len(jobErrors)
 		}
 		s.jobRepo.AddErrors(ctx, jobErrors[i:end])
 	}
+	return suppressed
 }
 
-func (s *Service) convertStagingToUser(su *repository.StagingUser) (*models.User, error) {
-	user := &models.User{
-		Active: true,
-	}
-
-	if su.ID != nil && *su.ID != "" {
-		id, err := uuid.Parse(*su.ID)
-		if err != nil {
-			return nil, err
-		}
-		user.ID = id
-	} else {
-		user.ID = uuid.New()
-	}
-
-	if su.Email != nil {
-		user.Email = *su.Email
-	}
-	if su.Name != nil {
-		user.Name = *su.Name
-	}
-	if su.Role != nil {
-		user.Role = *su.Role
+// capErrors returns the first max errors of each code, in order, and the
+// number of each code beyond them, nil when none were. A max of 0 keeps
+// every error.
+func capErrors(errs []*errors.ValidationError, max int) ([]*errors.ValidationError, map[string]int) {
+	if max <= 0 {
+		return errs, nil
 	}
-	if su.Active != nil {
-		user.Active = *su.Active
-	}
-	if su.CreatedAt != nil {
-		t, err := time.Parse(time.RFC3339, *su.CreatedAt)
-		if err == nil {
-			user.CreatedAt = t
-		} else {
-			user.CreatedAt = time.Now().UTC()
+	kept := make([]*errors.ValidationError, 0, len(errs))
+	seen := make(map[string]int)
+	var suppressed map[string]int
+	for _, e := range errs {
+		if seen[e.Code] < max {
+			seen[e.Code]++
+			kept = append(kept, e)
+			continue
 		}
-	} else {
-		user.CreatedAt = time.Now().UTC()
-	}
-	if su.UpdatedAt != nil {
-		t, err := time.Parse(time.RFC3339, *su.UpdatedAt)
-		if err == nil {
-			user.UpdatedAt = t
-		} else {
-			user.UpdatedAt = time.Now().UTC()
+		if suppressed == nil {
+			suppressed = make(map[string]int)
 		}
-	} else {
-		user.UpdatedAt = time.Now().UTC()
+		suppressed[e.Code]++
 	}
-
-	return user, nil
+	return kept, suppressed
 }
 
-func (s *Service) convertStagingToArticle(sa *repository.StagingArticle) (*models.Article, error) {
-	article := &models.Article{
-		Tags: json.RawMessage("[]"),
-	}
-
-	if sa.ID != nil && *sa.ID != "" {
-		id, err := uuid.Parse(*sa.ID)
-		if err != nil {
-			return nil, err
-		}
-		article.ID = id
-	} else {
-		article.ID = uuid.New()
-	}
-
-	if sa.Slug != nil {
-		article.Slug = *sa.Slug
-	}
-	if sa.Title != nil {
-		article.Title = *sa.Title
-	}
-	if sa.Body != nil {
-		article.Body = *sa.Body
-	}
-	if sa.AuthorID != nil {
-		authorID, err := uuid.Parse(*sa.AuthorID)
-		if err != nil {
-			return nil, err
+// recordSummary adds to summary per-domain counts of users rejected by the
+// email domain policy and the number of probable duplicates, and stores it on
+// the job unless it is empty
+func (s *Service) recordSummary(ctx context.Context, job *models.Job, errs, warns []*errors.ValidationError, summary models.JobSummary) {
+	for _, e := range errs {
+		switch e.Code {
+		case errors.ErrCodeDomainNotAllowed:
+			if summary.RejectedDomains == nil {
+				summary.RejectedDomains = make(map[string]int)
+			}
+			summary.RejectedDomains[validation.EmailDomain(e.RecordIdentifier)]++
+		case errors.ErrCodeNeedsReview:
+			summary.ProbableDuplicates++
 		}
-		article.AuthorID = authorID
-	}
-	if sa.Tags != nil {
-		article.Tags = json.RawMessage(*sa.Tags)
-	}
-	if sa.Status != nil {
-		article.Status = *sa.Status
 	}
-	if sa.PublishedAt != nil {
-		t, err := time.Parse(time.RFC3339, *sa.PublishedAt)
-		if err == nil {
-			article.PublishedAt = &t
+	for _, w := range warns {
+		if w.Code == errors.WarnCodeProbableDuplicate {
+			summary.ProbableDuplicates++
 		}
 	}
-
-	article.CreatedAt = time.Now().UTC()
-	article.UpdatedAt = time.Now().UTC()
-
-	return article, nil
-}
-
-func (s *Service) convertStagingToComment(sc *repository.StagingComment) (*models.Comment, error) {
-	comment := &models.Comment{}
-
-	if sc.ID != nil && *sc.ID != "" {
-		id, err := uuid.Parse(*sc.ID)
-		if err != nil {
-			return nil, err
-		}
-		comment.ID = id
-	} else {
-		comment.ID = uuid.New()
+	if summary.RejectedDomains == nil && summary.ProbableDuplicates == 0 && summary.Analysis == nil && summary.SuppressedErrors == nil && summary.PlaceholderAuthors == 0 && summary.AlreadyImported == 0 && summary.Updated == 0 {
+		return
 	}
 
-	if sc.ArticleID != nil {
-		articleID, err := uuid.Parse(*sc.ArticleID)
-		if err != nil {
-			return nil, err
-		}
-		comment.ArticleID = articleID
+	job.Summary = &summary
+	if err := s.jobRepo.SetSummary(ctx, job.ID, job.Summary); err != nil {
+		s.logger.Warn().Err(err).Str("job_id", job.ID.String()).Msg("Failed to store import summary")
 	}
-	if sc.UserID != nil {
-		userID, err := uuid.Parse(*sc.UserID)
-		if err != nil {
-			return nil, err
-		}
-		comment.UserID = userID
-	}
-	if sc.Body != nil {
-		comment.Body = *sc.Body
-	}
-	if sc.CreatedAt != nil {
-		t, err := time.Parse(time.RFC3339, *sc.CreatedAt)
-		if err == nil {
-			comment.CreatedAt = t
-		} else {
-			comment.CreatedAt = time.Now().UTC()
-		}
-	} else {
-		comment.CreatedAt = time.Now().UTC()
-	}
-
-	return comment, nil
 }
 
-// SaveUploadedFile saves an uploaded file to disk
-func (s *Service) SaveUploadedFile(file io.Reader, filename string) (string, error) {
-	// Create unique filename
-	ext := filepath.Ext(filename)
-	uniqueFilename := fmt.Sprintf("%s_%d%s", strings.TrimSuffix(filename, ext), time.Now().UnixNano(), ext)
-	filePath := filepath.Join(s.config.UploadPath, uniqueFilename)
-
-	// Create file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+// recordWarnings stores warnings for rows that were imported with filled-in
+// values
+func (s *Service) recordWarnings(ctx context.Context, jobID uuid.UUID, warns []*errors.ValidationError) {
+	cfg := s.config.Load()
+	if len(warns) == 0 {
+		return
 	}
-	defer dst.Close()
 
-	// Copy content
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %w", err)
+	jobWarnings := make([]*models.JobWarning, 0, len(warns))
+	for _, w := range warns {
+		jobWarnings = append(jobWarnings, &models.JobWarning{
+			JobID:            jobID,
+			RowNumber:        w.RowNumber,
+			RecordIdentifier: &w.RecordIdentifier,
+			FieldName:        &w.FieldName,
+			WarningCode:      w.Code,
+			WarningMessage:   w.Message,
+		})
 	}
 
-	return filePath, nil
+	for i := 0; i < len(jobWarnings); i += cfg.BatchSize {
+		end := i + cfg.BatchSize
+		if end > len(jobWarnings) {
+			end = len(jobWarnings)
+		}
+		s.jobRepo.AddWarnings(ctx, jobWarnings[i:end])
+	}
 }
 
 // DownloadFileFromURL downloads a file from a remote URL and saves it locally
-func (s *Service) DownloadFileFromURL(fileURL string) (string, error) {
+// as the upload of jobID
+func (s *Service) DownloadFileFromURL(jobID uuid.UUID, fileURL string) (string, error) {
 	// Validate URL
 	parsedURL, err := url.Parse(fileURL)
 	if err != nil {
@@ -912,9 +728,12 @@ func (s *Service) DownloadFileFromURL(fileURL string) (string, error) {
 		return "", fmt.Errorf("URL scheme must be http or https")
 	}
 
-	// Create HTTP client with timeout
+	// Create HTTP client with timeout. The file is saved as it was sent, so
+	// a gzipped file stays compressed on disk and counts against the limit
+	// at its compressed size; it is inflated as it is parsed.
 	client := &http.Client{
-		Timeout: 5 * time.Minute, // Allow up to 5 minutes for large files
+		Timeout:   5 * time.Minute, // Allow up to 5 minutes for large files
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableCompression: true},
 	}
 
 	// Make request
@@ -949,7 +768,12 @@ func (s *Service) DownloadFileFromURL(fileURL string) (string, error) {
 	limitedReader := io.LimitReader(resp.Body, maxSize)
 
 	// Save file using existing method
-	return s.SaveUploadedFile(limitedReader, filename)
+	return s.SaveUploadedFile(jobID, limitedReader, filename)
+}
+
+// GetJobWarnings retrieves warnings for a job
+func (s *Service) GetJobWarnings(ctx context.Context, jobID uuid.UUID, page, perPage int) ([]*models.JobWarning, int64, error) {
+	return s.jobRepo.GetWarnings(ctx, jobID, page, perPage)
 }
 
 // GetJobErrors retrieves errors for a job
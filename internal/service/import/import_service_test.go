@@ -0,0 +1,1101 @@
+package importservice
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	stderrors "errors"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rohit/bulk-import-export/internal/service/export/avro"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
+	"github.com/rohit/bulk-import-export/internal/service/validation"
+	"github.com/rs/zerolog"
+)
+
+// testMetrics is shared because the collector registers with the default
+// Prometheus registry
+var testMetrics = metrics.NewCollector()
+
+func newTestService(t *testing.T, fastPathMaxRows int) (*Service, *memory.DB) {
+	t.Helper()
+	db := memory.NewDB()
+	svc := NewService(
+		memory.NewUserRepository(db),
+		memory.NewArticleRepository(db),
+		memory.NewCommentRepository(db),
+		memory.NewJobRepository(db),
+		memory.NewStagingRepository(db),
+		memory.NewProfileRepository(db),
+		db,
+		nil,
+		testMetrics,
+		zerolog.Nop(),
+		config.ImportConfig{BatchSize: 2, DedupExpectedRows: 100, FastPathMaxRows: fastPathMaxRows},
+	)
+	return svc, db
+}
+
+func runImport(t *testing.T, svc *Service, db *memory.DB, resource models.ResourceType, name, content string) *models.Job {
+	t.Helper()
+	ctx := context.Background()
+	job := &models.Job{Type: models.JobTypeImport, Resource: resource, Status: models.JobStatusPending}
+	if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessImport(ctx, writeTempFile(t, name, content), job, "ndjson"); err != nil {
+		t.Fatalf("ProcessImport() error: %v", err)
+	}
+	stored, err := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByID() = %v, %v", stored, err)
+	}
+	return stored
+}
+
+const (
+	annID = "11111111-1111-4111-8111-111111111111"
+	bobID = "22222222-2222-4222-8222-222222222222"
+)
+
+func TestProcessImport_Users(t *testing.T) {
+	users := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Bob","role":"reader","active":"false"}
+{"email":"ANN@example.com","name":"Ann again","role":"reader","active":"true"}
+{"email":"not-an-email","name":"Bad","role":"reader","active":"true"}
+`
+	for _, tt := range []struct {
+		name     string
+		fastPath int
+	}{
+		{name: "staging tables", fastPath: 0},
+		{name: "fast path", fastPath: 100},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, db := newTestService(t, tt.fastPath)
+			job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+
+			if job.Status != models.JobStatusCompleted {
+				t.Fatalf("Status = %s, want completed (error %v)", job.Status, job.ErrorMessage)
+			}
+			if job.TotalRecords != 4 || job.SuccessfulRecords != 2 || job.FailedRecords != 2 || job.DuplicateRecords != 1 {
+				t.Errorf("counts = total %d, successful %d, failed %d, duplicates %d; want 4, 2, 2, 1",
+					job.TotalRecords, job.SuccessfulRecords, job.FailedRecords, job.DuplicateRecords)
+			}
+
+			ann, _ := memory.NewUserRepository(db).GetByID(context.Background(), uuid.MustParse(annID))
+			if ann == nil || ann.Name != "Ann" || !ann.Active {
+				t.Errorf("stored Ann = %+v, want the first row", ann)
+			}
+			if n, _ := memory.NewUserRepository(db).Count(context.Background(), nil); n != 2 {
+				t.Errorf("Count() = %d, want 2", n)
+			}
+		})
+	}
+}
+
+func TestProcessImport_StripsInvisibleCharacters(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx := context.Background()
+	runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", `{"id":"`+annID+`","email":"ann@example.com","name":"\u200b山田 花子\ufeff","role":"author","active":"true"}
+`)
+	job := runImport(t, svc, db, models.ResourceTypeArticles, "articles.ndjson", `{"slug":"first-post","title":"Hello\u200b 世界 🌏","body":"Hello","author_id":"`+annID+`","tags":["\u2060go","旅行"],"status":"draft"}
+`)
+	if job.SuccessfulRecords != 1 {
+		t.Fatalf("SuccessfulRecords = %d, want 1", job.SuccessfulRecords)
+	}
+
+	ann, _ := memory.NewUserRepository(db).GetByID(ctx, uuid.MustParse(annID))
+	if ann == nil || ann.Name != "山田 花子" {
+		t.Errorf("stored name = %+v, want 山田 花子", ann)
+	}
+	article, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "first-post")
+	if article == nil || article.Title != "Hello 世界 🌏" || string(article.Tags) != `["go","旅行"]` {
+		t.Errorf("stored article = %+v, want title and tags without zero-width characters", article)
+	}
+}
+
+func TestProcessImport_UsersAgainstExisting(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx := context.Background()
+	if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "admin"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	// Re-importing Ann by ID updates her; a new user taking her email is a duplicate
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", `{"id":"`+annID+`","email":"ann@example.com","name":"Ann Updated","role":"admin","active":"true"}
+{"email":"Ann@Example.com","name":"Impostor","role":"reader","active":"true"}
+`)
+
+	if job.SuccessfulRecords != 1 || job.DuplicateRecords != 1 {
+		t.Errorf("successful = %d, duplicates = %d; want 1, 1", job.SuccessfulRecords, job.DuplicateRecords)
+	}
+	ann, _ := memory.NewUserRepository(db).GetByID(ctx, uuid.MustParse(annID))
+	if ann == nil || ann.Name != "Ann Updated" {
+		t.Errorf("stored Ann = %+v, want the updated name", ann)
+	}
+}
+
+func TestProcessImport_UsersProvenance(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx := context.Background()
+	users := memory.NewUserRepository(db)
+	if err := users.Create(ctx, &models.User{Email: "cat@example.com", Name: "Cat", Role: "reader"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	// The second import updates Bob, so only Ann is still the first's
+	first := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", `{"id":"`+annID+`","email":"ann@example.com","name":"Ann","role":"admin"}
+{"id":"`+bobID+`","email":"bob@example.com","name":"Bob","role":"reader"}
+`)
+	second := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", `{"id":"`+bobID+`","email":"bob@example.com","name":"Robert","role":"reader"}
+`)
+
+	for _, tt := range []struct {
+		job  *models.Job
+		want string
+	}{
+		{first, annID},
+		{second, bobID},
+	} {
+		got, err := users.GetAll(ctx, &models.ExportFilters{ImportedByJob: &tt.job.ID})
+		if err != nil {
+			t.Fatalf("GetAll() error: %v", err)
+		}
+		if len(got) != 1 || got[0].ID.String() != tt.want || *got[0].ImportJobID != tt.job.ID {
+			t.Errorf("GetAll(imported_by_job %s) = %+v, want only %s", tt.job.ID, got, tt.want)
+		}
+	}
+	cat, _ := users.GetByEmail(ctx, "cat@example.com")
+	if cat.ImportJobID != nil {
+		t.Errorf("Cat's ImportJobID = %v, want nil for a user written outside an import", cat.ImportJobID)
+	}
+}
+
+func TestProcessImport_UsersAnalyze(t *testing.T) {
+	users := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann Updated","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+{"email":"bob@example.com","name":"Bob again","role":"reader","active":"true"}
+{"email":"not-an-email","name":"Bad","role":"reader","active":"true"}
+`
+	for _, fastPath := range []int{0, 100} {
+		svc, db := newTestService(t, fastPath)
+		ctx := context.Background()
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "admin"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+
+		job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: &models.JobParams{Analyze: true}}
+		if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson"); err != nil {
+			t.Fatalf("ProcessImport() error: %v", err)
+		}
+		stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+
+		if stored.SuccessfulRecords != 0 || stored.FailedRecords != 2 {
+			t.Errorf("fast path %d: successful = %d, failed = %d; want 0, 2", fastPath, stored.SuccessfulRecords, stored.FailedRecords)
+		}
+		if stored.Summary == nil || stored.Summary.Analysis == nil {
+			t.Fatalf("fast path %d: Summary = %+v, want an analysis", fastPath, stored.Summary)
+		}
+		analysis := stored.Summary.Analysis
+		if analysis.Inserts != 1 || analysis.Updates != 1 || analysis.Skipped != 2 {
+			t.Errorf("fast path %d: analysis = %+v, want 1 insert, 1 update, 2 skipped", fastPath, analysis)
+		}
+		if analysis.Table == nil || analysis.Table.Rows != 1 || analysis.Estimated == nil || analysis.Estimated.Rows != 2 {
+			t.Errorf("fast path %d: table = %+v, estimated = %+v; want 1 row growing to 2", fastPath, analysis.Table, analysis.Estimated)
+		}
+
+		// Nothing was written
+		ann, _ := memory.NewUserRepository(db).GetByID(ctx, uuid.MustParse(annID))
+		if ann == nil || ann.Name != "Ann" {
+			t.Errorf("fast path %d: stored Ann = %+v, want her unchanged", fastPath, ann)
+		}
+		if n, _ := memory.NewUserRepository(db).Count(ctx, nil); n != 1 {
+			t.Errorf("fast path %d: Count() = %d, want 1", fastPath, n)
+		}
+	}
+}
+
+func TestProcessImport_MaxErrorsPerCode(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	svc.config.Load().MaxErrorsPerCode = 2
+	ctx := context.Background()
+
+	var users strings.Builder
+	for i := 0; i < 5; i++ {
+		users.WriteString(`{"email":"not-an-email","name":"Bad","role":"reader"}` + "\n")
+	}
+	users.WriteString(`{"email":"ann@example.com","name":"Ann","role":"unknown"}` + "\n")
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users.String())
+
+	if job.FailedRecords != 6 {
+		t.Errorf("FailedRecords = %d, want every row", job.FailedRecords)
+	}
+	stored, total, err := memory.NewJobRepository(db).GetErrors(ctx, job.ID, 1, 100)
+	if err != nil || total != 3 {
+		t.Fatalf("GetErrors() = %d errors, %v; want 2 INVALID_EMAIL and 1 INVALID_ROLE", total, err)
+	}
+	if stored[0].RowNumber != 1 || stored[1].RowNumber != 2 {
+		t.Errorf("stored rows %d and %d, want the first two", stored[0].RowNumber, stored[1].RowNumber)
+	}
+	if job.Summary == nil || len(job.Summary.SuppressedErrors) != 1 || job.Summary.SuppressedErrors[errors.ErrCodeInvalidEmail] != 3 {
+		t.Errorf("Summary = %+v, want 3 suppressed %s", job.Summary, errors.ErrCodeInvalidEmail)
+	}
+}
+
+func TestProcessImport_UpperCaseIDsUpdateExisting(t *testing.T) {
+	for name, fastPath := range map[string]int{"staged": 0, "fast path": 100} {
+		t.Run(name, func(t *testing.T) {
+			svc, db := newTestService(t, fastPath)
+			ctx := context.Background()
+			if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author"}); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			articleID := uuid.New()
+			if err := memory.NewArticleRepository(db).Create(ctx, &models.Article{ID: articleID, Slug: "first-post", Title: "First", Body: "Hello", AuthorID: uuid.MustParse(annID), Status: "draft"}); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+
+			// A stored ID spelled in upper case is the stored record, not a
+			// new one taking its email or slug
+			job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", `{"id":"`+strings.ToUpper(annID)+`","email":"ann@example.com","name":"Ann Updated","role":"author","active":"true"}
+`)
+			if job.SuccessfulRecords != 1 || job.DuplicateRecords != 0 {
+				t.Errorf("users: successful = %d, duplicates = %d; want 1, 0", job.SuccessfulRecords, job.DuplicateRecords)
+			}
+			if ann, _ := memory.NewUserRepository(db).GetByID(ctx, uuid.MustParse(annID)); ann == nil || ann.Name != "Ann Updated" {
+				t.Errorf("stored Ann = %+v, want the updated name", ann)
+			}
+
+			job = runImport(t, svc, db, models.ResourceTypeArticles, "articles.ndjson", `{"id":"`+strings.ToUpper(articleID.String())+`","slug":"first-post","title":"First Updated","body":"Hello","author_id":"`+annID+`","status":"draft"}
+`)
+			if job.SuccessfulRecords != 1 || job.DuplicateRecords != 0 {
+				t.Errorf("articles: successful = %d, duplicates = %d; want 1, 0", job.SuccessfulRecords, job.DuplicateRecords)
+			}
+			if a, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "first-post"); a == nil || a.Title != "First Updated" {
+				t.Errorf("stored article = %+v, want the updated title", a)
+			}
+		})
+	}
+}
+
+func TestProcessImport_ArticlesRejectUnknownAuthor(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx := context.Background()
+	if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	job := runImport(t, svc, db, models.ResourceTypeArticles, "articles.ndjson", `{"slug":"first-post","title":"First","body":"Hello","author_id":"`+annID+`","status":"draft"}
+{"slug":"orphan-post","title":"Orphan","body":"Hello","author_id":"`+bobID+`","status":"draft"}
+`)
+
+	if job.SuccessfulRecords != 1 || job.FailedRecords != 1 {
+		t.Errorf("successful = %d, failed = %d; want 1, 1", job.SuccessfulRecords, job.FailedRecords)
+	}
+	if a, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "first-post"); a == nil {
+		t.Error("first-post was not stored")
+	}
+	if a, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "orphan-post"); a != nil {
+		t.Error("orphan-post was stored despite its unknown author")
+	}
+}
+
+func TestProcessImport_ArticlesCreateMissingAuthors(t *testing.T) {
+	const carlID = "33333333-3333-4333-8333-333333333333"
+	articles := `{"slug":"first-post","title":"First","body":"Hello","author_id":"` + annID + `","status":"draft"}
+{"slug":"bob-post","title":"Bob","body":"Hello","author_id":"` + bobID + `","status":"draft"}
+{"slug":"bob-again","title":"Bob again","body":"Hello","author_id":"` + bobID + `","status":"draft"}
+{"slug":"carl-post","title":"Carl","body":"Hello","author_id":"` + carlID + `","status":"draft"}
+`
+	for _, analyze := range []bool{false, true} {
+		for _, fastPath := range []int{0, 100} {
+			svc, db := newTestService(t, fastPath)
+			ctx := context.Background()
+			users := memory.NewUserRepository(db)
+			if err := users.Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author", Active: true}); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+
+			job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeArticles, Status: models.JobStatusPending,
+				Params: &models.JobParams{CreateMissingAuthors: true, Analyze: analyze}}
+			if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			if err := svc.ProcessImport(ctx, writeTempFile(t, "articles.ndjson", articles), job, "ndjson"); err != nil {
+				t.Fatalf("ProcessImport() error: %v", err)
+			}
+			stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+
+			if stored.Summary == nil || stored.Summary.PlaceholderAuthors != 2 {
+				t.Fatalf("analyze=%v fastPath=%d: Summary = %+v, want 2 placeholder authors", analyze, fastPath, stored.Summary)
+			}
+			isPlaceholder := true
+			placeholders, _ := users.GetAll(ctx, &models.ExportFilters{Placeholder: &isPlaceholder})
+			if analyze {
+				if len(placeholders) != 0 || stored.Summary.Analysis == nil || stored.Summary.Analysis.Inserts != 4 {
+					t.Errorf("analyze fastPath=%d: %d placeholders, analysis %+v; want none written and 4 inserts", fastPath, len(placeholders), stored.Summary.Analysis)
+				}
+				continue
+			}
+
+			if stored.SuccessfulRecords != 4 || stored.FailedRecords != 0 {
+				t.Errorf("fastPath=%d: successful = %d, failed = %d; want 4, 0", fastPath, stored.SuccessfulRecords, stored.FailedRecords)
+			}
+			if len(placeholders) != 2 {
+				t.Fatalf("fastPath=%d: GetAll(placeholder) = %d users, want bob and carl", fastPath, len(placeholders))
+			}
+			for _, user := range placeholders {
+				if user.ID.String() != bobID && user.ID.String() != carlID {
+					t.Errorf("fastPath=%d: placeholder %s, want bob or carl", fastPath, user.ID)
+				}
+				if user.Active || user.ImportJobID == nil || *user.ImportJobID != job.ID || !strings.HasSuffix(user.Email, "@"+PlaceholderEmailDomain) {
+					t.Errorf("fastPath=%d: placeholder = %+v, want inactive, stamped with the job and a %s email", fastPath, user, PlaceholderEmailDomain)
+				}
+			}
+			if a, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "bob-again"); a == nil || a.AuthorID.String() != bobID {
+				t.Errorf("fastPath=%d: bob-again = %+v, want it stored under bob", fastPath, a)
+			}
+		}
+	}
+}
+
+func TestProcessImport_UpsertKey(t *testing.T) {
+	users := `{"email":"ann@example.com","name":"Ann Updated","role":"author","active":"true"}
+{"id":"` + bobID + `","email":"robert@example.com","name":"Robert","role":"reader","active":"true"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+`
+	articles := `{"slug":"first-post","title":"First, revised","body":"Hello again","author_id":"` + annID + `","status":"draft"}
+{"slug":"new-post","title":"New","body":"Hello","author_id":"` + annID + `","status":"draft"}
+`
+	for _, fastPath := range []int{0, 100} {
+		svc, db := newTestService(t, fastPath)
+		ctx := context.Background()
+		userRepo := memory.NewUserRepository(db)
+		articleRepo := memory.NewArticleRepository(db)
+		for _, user := range []*models.User{
+			{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author", Active: true},
+			{ID: uuid.MustParse(bobID), Email: "bob@example.com", Name: "Bob", Role: "reader", Active: true},
+		} {
+			if err := userRepo.Create(ctx, user); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+		}
+		first := &models.Article{Slug: "first-post", Title: "First", Body: "Hello", AuthorID: uuid.MustParse(annID), Status: "draft"}
+		if err := articleRepo.Create(ctx, first); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+
+		run := func(resource models.ResourceType, name, content string, key models.UpsertKey) *models.Job {
+			t.Helper()
+			job := &models.Job{Type: models.JobTypeImport, Resource: resource, Status: models.JobStatusPending,
+				Params: &models.JobParams{UpsertKey: key}}
+			if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			if err := svc.ProcessImport(ctx, writeTempFile(t, name, content), job, "ndjson"); err != nil {
+				t.Fatalf("ProcessImport() error: %v", err)
+			}
+			stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+			return stored
+		}
+
+		// Ann is updated under her ID; Bob's ID can't take a new email
+		job := run(models.ResourceTypeUsers, "users.ndjson", users, models.UpsertKeyEmail)
+		if job.SuccessfulRecords != 2 || job.DuplicateRecords != 1 {
+			t.Errorf("fastPath=%d: users successful = %d, duplicates = %d; want 2, 1", fastPath, job.SuccessfulRecords, job.DuplicateRecords)
+		}
+		if ann, _ := userRepo.GetByEmail(ctx, "ann@example.com"); ann == nil || ann.ID.String() != annID || ann.Name != "Ann Updated" {
+			t.Errorf("fastPath=%d: ann = %+v, want her name updated under her ID", fastPath, ann)
+		}
+		if bob, _ := userRepo.GetByID(ctx, uuid.MustParse(bobID)); bob == nil || bob.Email != "bob@example.com" {
+			t.Errorf("fastPath=%d: bob = %+v, want him untouched", fastPath, bob)
+		}
+
+		// Matching on ID, Bob takes the new email and the stored emails are
+		// duplicates
+		job = run(models.ResourceTypeUsers, "users.ndjson", users, "")
+		if job.SuccessfulRecords != 1 || job.DuplicateRecords != 2 {
+			t.Errorf("fastPath=%d: users by id successful = %d, duplicates = %d; want 1, 2", fastPath, job.SuccessfulRecords, job.DuplicateRecords)
+		}
+
+		job = run(models.ResourceTypeArticles, "articles.ndjson", articles, models.UpsertKeySlug)
+		if job.SuccessfulRecords != 2 || job.FailedRecords != 0 {
+			t.Errorf("fastPath=%d: articles successful = %d, failed = %d; want 2, 0", fastPath, job.SuccessfulRecords, job.FailedRecords)
+		}
+		if a, _ := articleRepo.GetBySlug(ctx, "first-post"); a == nil || a.ID != first.ID || a.Title != "First, revised" {
+			t.Errorf("fastPath=%d: first-post = %+v, want it revised under its ID", fastPath, a)
+		}
+	}
+}
+
+func TestProcessImport_OnConflict(t *testing.T) {
+	users := `{"email":"ann@example.com","name":"Ann Updated","role":"author"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+`
+	articles := `{"slug":"first-post","title":"First, revised","body":"Hello again","author_id":"` + annID + `","status":"draft"}
+`
+	for _, fastPath := range []int{0, 100} {
+		for _, mode := range []models.OnConflict{models.OnConflictUpsert, models.OnConflictReplace} {
+			svc, db := newTestService(t, fastPath)
+			ctx := context.Background()
+			userRepo := memory.NewUserRepository(db)
+			articleRepo := memory.NewArticleRepository(db)
+			if err := userRepo.Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author", Active: false}); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			first := &models.Article{Slug: "first-post", Title: "First", Body: "Hello", AuthorID: uuid.MustParse(annID), Tags: []byte(`["go"]`), Status: "draft"}
+			if err := articleRepo.Create(ctx, first); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+
+			run := func(resource models.ResourceType, content string, analyze bool) *models.Job {
+				t.Helper()
+				job := &models.Job{Type: models.JobTypeImport, Resource: resource, Status: models.JobStatusPending,
+					Params: &models.JobParams{OnConflict: mode, Analyze: analyze}}
+				if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+					t.Fatalf("Create() error: %v", err)
+				}
+				if err := svc.ProcessImport(ctx, writeTempFile(t, "import.ndjson", content), job, "ndjson"); err != nil {
+					t.Fatalf("ProcessImport() error: %v", err)
+				}
+				stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+				return stored
+			}
+
+			// The row with Ann's email updates her instead of being a duplicate
+			job := run(models.ResourceTypeUsers, users, true)
+			if a := job.Summary.Analysis; a == nil || a.Inserts != 1 || a.Updates != 1 {
+				t.Errorf("fastPath=%d %s: analysis = %+v, want 1 insert and 1 update", fastPath, mode, a)
+			}
+			job = run(models.ResourceTypeUsers, users, false)
+			if job.SuccessfulRecords != 2 || job.DuplicateRecords != 0 || job.Summary == nil || job.Summary.Inserted != 1 || job.Summary.Updated != 1 {
+				t.Errorf("fastPath=%d %s: users successful = %d, duplicates = %d, summary = %+v; want 1 inserted and 1 updated",
+					fastPath, mode, job.SuccessfulRecords, job.DuplicateRecords, job.Summary)
+			}
+			// Upsert keeps the active flag the row left out; replace resets it
+			ann, _ := userRepo.GetByEmail(ctx, "ann@example.com")
+			if ann == nil || ann.ID.String() != annID || ann.Name != "Ann Updated" || ann.Active != (mode == models.OnConflictReplace) {
+				t.Errorf("fastPath=%d %s: ann = %+v", fastPath, mode, ann)
+			}
+
+			job = run(models.ResourceTypeArticles, articles, false)
+			if job.SuccessfulRecords != 1 || job.Summary == nil || job.Summary.Updated != 1 {
+				t.Errorf("fastPath=%d %s: articles successful = %d, summary = %+v; want 1 updated", fastPath, mode, job.SuccessfulRecords, job.Summary)
+			}
+			wantTags := `["go"]`
+			if mode == models.OnConflictReplace {
+				wantTags = `[]`
+			}
+			if a, _ := articleRepo.GetBySlug(ctx, "first-post"); a == nil || a.ID != first.ID || a.Title != "First, revised" || string(a.Tags) != wantTags {
+				t.Errorf("fastPath=%d %s: first-post = %+v, want it revised under its ID with tags %s", fastPath, mode, a, wantTags)
+			}
+		}
+	}
+}
+
+func TestProcessImport_ArticlesLongBodies(t *testing.T) {
+	articles := `{"slug":"short-post","title":"Short","body":"Hello","author_id":"` + annID + `","status":"draft"}
+{"slug":"long-post","title":"Long","body":"Hello, world","author_id":"` + annID + `","status":"draft"}
+`
+	for _, truncate := range []bool{false, true} {
+		svc, db := newTestService(t, 0)
+		svc.validator.Article.SetBodyLimit(8, truncate)
+		ctx := context.Background()
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+
+		job := runImport(t, svc, db, models.ResourceTypeArticles, "articles.ndjson", articles)
+		long, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "long-post")
+
+		if !truncate {
+			if job.SuccessfulRecords != 1 || job.FailedRecords != 1 || long != nil {
+				t.Errorf("reject: successful = %d, failed = %d, long-post = %v; want 1, 1, nil", job.SuccessfulRecords, job.FailedRecords, long)
+			}
+			continue
+		}
+
+		if job.SuccessfulRecords != 2 || long == nil || long.Body != "Hello, w" {
+			t.Fatalf("truncate: successful = %d, long-post = %+v; want 2 and a body of %q", job.SuccessfulRecords, long, "Hello, w")
+		}
+		warnings, _, err := memory.NewJobRepository(db).GetWarnings(ctx, job.ID, 1, 10)
+		if err != nil || len(warnings) != 1 || warnings[0].WarningCode != errors.WarnCodeBodyTruncated {
+			t.Errorf("GetWarnings() = %+v, %v; want one %s", warnings, err, errors.WarnCodeBodyTruncated)
+		}
+	}
+}
+
+func TestProcessImport_ArticlesSanitize(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx := context.Background()
+	if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeArticles, Status: models.JobStatusPending, Params: &models.JobParams{Sanitize: true}}
+	if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	file := writeTempFile(t, "articles.ndjson", `{"slug":"clean-post","title":"Clean","body":"Hello","author_id":"`+annID+`","status":"draft"}
+{"slug":"dirty-post","title":"Dirty","body":"Hello<script>alert(1)</script>\r\nworld","author_id":"`+annID+`","status":"draft"}
+{"slug":"script-only","title":"Script","body":"<script>alert(1)</script>","author_id":"`+annID+`","status":"draft"}
+`)
+	if err := svc.ProcessImport(ctx, file, job, "ndjson"); err != nil {
+		t.Fatalf("ProcessImport() error: %v", err)
+	}
+
+	dirty, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "dirty-post")
+	if dirty == nil || dirty.Body != "Hello\nworld" {
+		t.Fatalf("dirty-post = %+v, want a body of %q", dirty, "Hello\nworld")
+	}
+	// A body that is nothing but script is empty once sanitized
+	if a, _ := memory.NewArticleRepository(db).GetBySlug(ctx, "script-only"); a != nil {
+		t.Error("script-only was stored with an empty body")
+	}
+	warnings, total, err := memory.NewJobRepository(db).GetWarnings(ctx, job.ID, 1, 10)
+	if err != nil || total != 1 || warnings[0].WarningCode != errors.WarnCodeBodySanitized {
+		t.Errorf("GetWarnings() = %+v, %d, %v; want one %s", warnings, total, err, errors.WarnCodeBodySanitized)
+	}
+}
+
+func TestProcessImport_ArticlesScreen(t *testing.T) {
+	articles := `{"slug":"clean-post","title":"Clean","body":"Hello","author_id":"` + annID + `","status":"draft"}
+{"slug":"card-post","title":"Card","body":"Pay with 4111 1111 1111 1111","author_id":"` + annID + `","status":"draft"}
+{"slug":"rude-post","title":"Rude","body":"Well darn","author_id":"` + annID + `","status":"draft"}
+`
+	for _, mode := range []string{"reject", "redact"} {
+		svc, db := newTestService(t, 0)
+		ctx := context.Background()
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		svc.config.Load().ScreenMode = mode
+		svc.SetScanner(validation.NewRegexScanner([]string{"darn"}, true))
+
+		job := runImport(t, svc, db, models.ResourceTypeArticles, "articles.ndjson", articles)
+		repo := memory.NewArticleRepository(db)
+		jobs := memory.NewJobRepository(db)
+		if mode == "reject" {
+			errs, total, _ := jobs.GetErrors(ctx, job.ID, 1, 10)
+			if job.SuccessfulRecords != 1 || total != 2 || errs[0].ErrorCode != errors.ErrCodePIIDetected || errs[1].ErrorCode != errors.ErrCodeBannedTerm {
+				t.Errorf("reject: %d written with errors %+v; want the clean post and PII_DETECTED, BANNED_TERM", job.SuccessfulRecords, errs)
+			}
+			continue
+		}
+
+		card, _ := repo.GetBySlug(ctx, "card-post")
+		if card == nil || card.Body != "Pay with [REDACTED]" {
+			t.Fatalf("redact: card-post = %+v, want the number redacted", card)
+		}
+		if _, total, _ := jobs.GetWarnings(ctx, job.ID, 1, 10); job.SuccessfulRecords != 3 || total != 2 {
+			t.Errorf("redact: %d written with %d warnings; want 3 with 2 %s", job.SuccessfulRecords, total, errors.WarnCodeContentRedacted)
+		}
+	}
+}
+
+func TestProcessImport_ArticlesDetectLang(t *testing.T) {
+	for _, fastPath := range []int{0, 100} {
+		svc, db := newTestService(t, fastPath)
+		ctx := context.Background()
+		if err := memory.NewUserRepository(db).Create(ctx, &models.User{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "author"}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+
+		job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeArticles, Status: models.JobStatusPending, Params: &models.JobParams{DetectLang: true}}
+		if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		file := writeTempFile(t, "articles.ndjson", `{"slug":"english","title":"En","body":"The quick brown fox jumps over the lazy dog and this is what it does with the rest of the day","author_id":"`+annID+`","status":"draft"}
+{"slug":"spanish","title":"Es","body":"El perro corre por el parque y los niños juegan con la pelota en la tarde","author_id":"`+annID+`","status":"draft"}
+{"slug":"numbers","title":"Num","body":"12345","author_id":"`+annID+`","status":"draft"}
+`)
+		if err := svc.ProcessImport(ctx, file, job, "ndjson"); err != nil {
+			t.Fatalf("ProcessImport() error: %v", err)
+		}
+
+		repo := memory.NewArticleRepository(db)
+		for slug, want := range map[string]string{"english": "en", "spanish": "es", "numbers": ""} {
+			a, _ := repo.GetBySlug(ctx, slug)
+			if a == nil {
+				t.Fatalf("fastPath=%d: %s was not stored", fastPath, slug)
+			}
+			got := ""
+			if a.Lang != nil {
+				got = *a.Lang
+			}
+			if got != want {
+				t.Errorf("fastPath=%d: %s lang = %q, want %q", fastPath, slug, got, want)
+			}
+		}
+
+		lang := "es"
+		articles, err := repo.GetAll(ctx, &models.ExportFilters{Lang: &lang})
+		if err != nil || len(articles) != 1 || articles[0].Slug != "spanish" {
+			t.Errorf("fastPath=%d: GetAll(lang=es) = %d articles, %v; want spanish", fastPath, len(articles), err)
+		}
+	}
+}
+
+func TestProcessImport_AdminRolePolicy(t *testing.T) {
+	users := `{"email":"ann@example.com","name":"Ann","role":"Admin","active":"true"}
+{"email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+`
+	ctx := context.Background()
+	codes := func(db *memory.DB, job *models.Job) (errCodes, warnCodes []string) {
+		t.Helper()
+		jobs := memory.NewJobRepository(db)
+		errs, _, _ := jobs.GetErrors(ctx, job.ID, 1, 10)
+		for _, e := range errs {
+			errCodes = append(errCodes, e.ErrorCode)
+		}
+		warns, _, _ := jobs.GetWarnings(ctx, job.ID, 1, 10)
+		for _, w := range warns {
+			if w.WarningCode == errors.WarnCodeAdminRoleFlagged {
+				warnCodes = append(warnCodes, w.WarningCode)
+			}
+		}
+		return errCodes, warnCodes
+	}
+
+	// Block rejects the admin row and imports the rest
+	svc, db := newTestService(t, 0)
+	svc.config.Load().AdminRolePolicy = AdminRolePolicyBlock
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+	errCodes, _ := codes(db, job)
+	if job.SuccessfulRecords != 1 || job.FailedRecords != 1 || len(errCodes) != 1 || errCodes[0] != errors.ErrCodeRoleNotPermitted {
+		t.Errorf("block: successful = %d, failed = %d, errors = %v; want 1, 1, [%s]", job.SuccessfulRecords, job.FailedRecords, errCodes, errors.ErrCodeRoleNotPermitted)
+	}
+
+	// Flag imports it with a warning
+	svc, db = newTestService(t, 0)
+	svc.config.Load().AdminRolePolicy = AdminRolePolicyFlag
+	job = runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+	errCodes, warnCodes := codes(db, job)
+	if job.SuccessfulRecords != 2 || len(errCodes) != 0 || len(warnCodes) != 1 {
+		t.Errorf("flag: successful = %d, errors = %v, flagged = %d; want 2, none, 1", job.SuccessfulRecords, errCodes, len(warnCodes))
+	}
+
+	// An import allowed to grant admin roles isn't held to the policy
+	svc, db = newTestService(t, 0)
+	svc.config.Load().AdminRolePolicy = AdminRolePolicyBlock
+	job = &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending,
+		Params: &models.JobParams{AllowAdminRoles: true}}
+	if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson"); err != nil {
+		t.Fatalf("ProcessImport() error: %v", err)
+	}
+	stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+	if stored.SuccessfulRecords != 2 {
+		t.Errorf("allowed: successful = %d, want 2", stored.SuccessfulRecords)
+	}
+}
+
+func TestProcessImport_EmailDomainPolicy(t *testing.T) {
+	users := `{"email":"ann@example.com","name":"Ann","role":"reader","active":"true"}
+{"email":"bob@mailinator.com","name":"Bob","role":"reader","active":"true"}
+{"email":"cat@mailinator.com","name":"Cat","role":"reader","active":"true"}
+{"email":"dan@gmail.com","name":"Dan","role":"reader","active":"true"}
+`
+	svc, db := newTestService(t, 0)
+	svc.validator.User.SetDomainPolicy([]string{"example.com"}, nil, true)
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+
+	if job.SuccessfulRecords != 1 || job.FailedRecords != 3 {
+		t.Errorf("successful = %d, failed = %d; want 1, 3", job.SuccessfulRecords, job.FailedRecords)
+	}
+	if job.Summary == nil {
+		t.Fatal("Summary = nil, want rejected domain counts")
+	}
+	want := map[string]int{"mailinator.com": 2, "gmail.com": 1}
+	if !maps.Equal(job.Summary.RejectedDomains, want) {
+		t.Errorf("RejectedDomains = %v, want %v", job.Summary.RejectedDomains, want)
+	}
+}
+
+func TestProcessImport_AvroUsers(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	users := []*models.User{
+		{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "admin", Active: true, CreatedAt: created, UpdatedAt: created},
+		{ID: uuid.MustParse(bobID), Email: "not-an-email", Name: "Bob", Role: "reader", CreatedAt: created, UpdatedAt: created},
+	}
+	schema, _ := avro.Schema(models.ResourceTypeUsers)
+	var buf bytes.Buffer
+	aw, err := avro.NewWriter(&buf, schema, avro.CodecDeflate, 0)
+	if err != nil {
+		t.Fatalf("NewWriter() error: %v", err)
+	}
+	for _, u := range users {
+		aw.Append(func(b []byte) []byte { return avro.AppendUser(b, u) })
+	}
+	aw.Close()
+
+	svc, db := newTestService(t, 0)
+	file := writeTempFile(t, "users.avro", buf.String())
+	if rows, err := svc.CountRows(file.Name()); err != nil || rows != 2 {
+		t.Errorf("CountRows() = %d, %v; want 2", rows, err)
+	}
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.avro", buf.String())
+
+	if job.SuccessfulRecords != 1 || job.FailedRecords != 1 {
+		t.Errorf("successful = %d, failed = %d; want 1, 1", job.SuccessfulRecords, job.FailedRecords)
+	}
+	stored, err := memory.NewUserRepository(db).GetByID(context.Background(), uuid.MustParse(annID))
+	if err != nil || stored == nil || stored.Role != "admin" || !stored.Active || !stored.CreatedAt.Equal(created) {
+		t.Errorf("imported user = %+v, %v; want Ann as an active admin created %v", stored, err, created)
+	}
+}
+
+func TestProcessImport_XLSXUsers(t *testing.T) {
+	// A workbook of inline strings, which needs no shared string table or
+	// styles
+	cell := func(v string) string { return `<c t="inlineStr"><is><t>` + v + `</t></is></c>` }
+	row := func(cells ...string) string {
+		var b strings.Builder
+		for _, c := range cells {
+			b.WriteString(cell(c))
+		}
+		return "<row>" + b.String() + "</row>"
+	}
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Users" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			row("id", "email", "name", "role", "active") +
+			row(annID, "ann@example.com", "Ann", "admin", "true") +
+			row(bobID, "not-an-email", "Bob", "reader", "false") +
+			`</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	svc, db := newTestService(t, 0)
+	file := writeTempFile(t, "users.xlsx", buf.String())
+	if rows, err := svc.CountRows(file.Name()); err != nil || rows != 2 {
+		t.Errorf("CountRows() = %d, %v; want 2", rows, err)
+	}
+	if detection, err := svc.DetectResource(file.Name()); err != nil || detection.Resource != models.ResourceTypeUsers {
+		t.Errorf("DetectResource() = %+v, %v; want users", detection, err)
+	}
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.xlsx", buf.String())
+
+	if job.SuccessfulRecords != 1 || job.FailedRecords != 1 {
+		t.Errorf("successful = %d, failed = %d; want 1, 1", job.SuccessfulRecords, job.FailedRecords)
+	}
+	errs, _, _ := memory.NewJobRepository(db).GetErrors(context.Background(), job.ID, 1, 10)
+	if len(errs) != 1 || errs[0].RowNumber != 3 {
+		t.Errorf("errors = %+v, want one on the sheet's row 3", errs)
+	}
+}
+
+func TestProcessImport_FuzzyDedup(t *testing.T) {
+	users := `{"email":"john.smith@gmail.com","name":"John Smith","role":"reader","active":"true"}
+{"email":"johnsmith+news@gmail.com","name":"John Smith","role":"reader","active":"true"}
+{"email":"jane@example.com","name":"Jane Doe","role":"reader","active":"true"}
+`
+	ctx := context.Background()
+	run := func(mode models.UserFuzzyDedup) (*models.Job, *memory.DB) {
+		t.Helper()
+		svc, db := newTestService(t, 0)
+		job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending,
+			Params: &models.JobParams{FuzzyDedup: mode}}
+		if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson"); err != nil {
+			t.Fatalf("ProcessImport() error: %v", err)
+		}
+		stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+		return stored, db
+	}
+
+	// Warn imports the probable duplicate and flags it
+	job, db := run(models.UserFuzzyDedupWarn)
+	warns, _, _ := memory.NewJobRepository(db).GetWarnings(ctx, job.ID, 1, 10)
+	flagged := 0
+	for _, w := range warns {
+		if w.WarningCode == errors.WarnCodeProbableDuplicate {
+			flagged++
+		}
+	}
+	if job.SuccessfulRecords != 3 || flagged != 1 {
+		t.Errorf("warn: successful = %d, flagged = %d; want 3, 1", job.SuccessfulRecords, flagged)
+	}
+	if job.Summary == nil || job.Summary.ProbableDuplicates != 1 {
+		t.Errorf("warn: summary = %+v, want 1 probable duplicate", job.Summary)
+	}
+
+	// Review holds it back
+	job, db = run(models.UserFuzzyDedupReview)
+	errs, _, _ := memory.NewJobRepository(db).GetErrors(ctx, job.ID, 1, 10)
+	if job.SuccessfulRecords != 2 || len(errs) != 1 || errs[0].ErrorCode != errors.ErrCodeNeedsReview || errs[0].RowNumber != 2 {
+		t.Errorf("review: successful = %d, errors = %+v; want 2 and row 2 NEEDS_REVIEW", job.SuccessfulRecords, errs)
+	}
+
+	// Off by default
+	job, _ = run("")
+	if job.SuccessfulRecords != 3 || job.Summary != nil {
+		t.Errorf("off: successful = %d, summary = %+v; want 3, none", job.SuccessfulRecords, job.Summary)
+	}
+}
+
+func TestProcessImport_RowLimit(t *testing.T) {
+	users := `{"email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+{"email":"cat@example.com","name":"Cat","role":"reader","active":"true"}
+`
+	ctx := context.Background()
+
+	// Truncating imports the rows up to the limit and warns about the rest
+	svc, db := newTestService(t, 0)
+	svc.config.Load().MaxRows = 2
+	svc.config.Load().RowLimitMode = "truncate"
+	job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+	if job.Status != models.JobStatusCompleted || job.TotalRecords != 2 || job.SuccessfulRecords != 2 {
+		t.Errorf("truncate: status = %s, total = %d, successful = %d; want completed, 2, 2", job.Status, job.TotalRecords, job.SuccessfulRecords)
+	}
+	warnings, _, err := memory.NewJobRepository(db).GetWarnings(ctx, job.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("GetWarnings() error: %v", err)
+	}
+	var limitWarnings []*models.JobWarning
+	for _, w := range warnings {
+		if w.WarningCode == errors.WarnCodeRowLimitReached {
+			limitWarnings = append(limitWarnings, w)
+		}
+	}
+	if len(limitWarnings) != 1 || limitWarnings[0].RowNumber != 3 {
+		t.Errorf("%s warnings = %+v, want one at row 3", errors.WarnCodeRowLimitReached, limitWarnings)
+	}
+
+	// Otherwise a file that got past the up front check fails
+	svc, db = newTestService(t, 0)
+	svc.config.Load().MaxRows = 2
+	svc.config.Load().RowLimitMode = "reject"
+	job = &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	jobs := memory.NewJobRepository(db)
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	err = svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", users), job, "ndjson")
+	var appErr *errors.AppError
+	if !stderrors.As(err, &appErr) || appErr.Code != errors.ErrCodeRowLimitExceeded {
+		t.Fatalf("ProcessImport() error = %v, want %s", err, errors.ErrCodeRowLimitExceeded)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusFailed {
+		t.Errorf("reject: status = %s, want failed", stored.Status)
+	}
+	if n, _ := memory.NewUserRepository(db).Count(ctx, nil); n != 0 {
+		t.Errorf("reject: %d users stored, want none", n)
+	}
+}
+
+func TestProcessImport_MinRows(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct{ name, format, content string }{
+		{"users.csv", "csv", "email,name,role,active\n"},
+		{"users.ndjson", "ndjson", ""},
+	} {
+		run := func(minRows int) (*memory.JobRepository, *models.Job, error) {
+			svc, db := newTestService(t, 0)
+			svc.config.Load().MinRows = minRows
+			jobs := memory.NewJobRepository(db)
+			job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+			if err := jobs.Create(ctx, job); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			return jobs, job, svc.ProcessImport(ctx, writeTempFile(t, tc.name, tc.content), job, tc.format)
+		}
+
+		// A header-only file fails with an EMPTY_FILE error
+		jobs, job, err := run(1)
+		var appErr *errors.AppError
+		if !stderrors.As(err, &appErr) || appErr.Code != errors.ErrCodeEmptyFile {
+			t.Fatalf("%s: ProcessImport() error = %v, want %s", tc.format, err, errors.ErrCodeEmptyFile)
+		}
+		stored, _ := jobs.GetByID(ctx, job.ID)
+		if stored.Status != models.JobStatusFailed {
+			t.Errorf("%s: status = %s, want failed", tc.format, stored.Status)
+		}
+		errs, _, _ := jobs.GetErrors(ctx, job.ID, 1, 10)
+		if len(errs) != 1 || errs[0].ErrorCode != errors.ErrCodeEmptyFile {
+			t.Errorf("%s: errors = %+v, want one %s", tc.format, errs, errors.ErrCodeEmptyFile)
+		}
+
+		// IMPORT_MIN_ROWS=0 lets it complete with nothing imported
+		jobs, job, err = run(0)
+		if err != nil {
+			t.Fatalf("%s: min rows 0: ProcessImport() error: %v", tc.format, err)
+		}
+		job, _ = jobs.GetByID(ctx, job.ID)
+		if job.Status != models.JobStatusCompleted || job.TotalRecords != 0 {
+			t.Errorf("%s: min rows 0: status = %s, total = %d; want completed, 0", tc.format, job.Status, job.TotalRecords)
+		}
+	}
+}
+
+// cancelAfterBatch cancels the import once its first batch is written
+type cancelAfterBatch struct {
+	hooks.Base
+	cancel context.CancelFunc
+}
+
+func (h cancelAfterBatch) OnBatchInserted(context.Context, *models.Job, models.ResourceType, []uuid.UUID) {
+	h.cancel()
+}
+
+func TestProcessImport_Cancelled(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.RegisterHooks(cancelAfterBatch{cancel: cancel})
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	jobs := memory.NewJobRepository(db)
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	file := writeTempFile(t, "users.ndjson", `{"email":"a@example.com","name":"A","role":"reader"}
+{"email":"b@example.com","name":"B","role":"reader"}
+{"email":"c@example.com","name":"C","role":"reader"}
+{"email":"d@example.com","name":"D","role":"reader"}
+`)
+
+	// The batch size is 2, so the second batch is never written
+	err := svc.ProcessImport(ctx, file, job, "ndjson")
+	if !stderrors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessImport() error = %v, want context.Canceled", err)
+	}
+	if count, _ := memory.NewUserRepository(db).Count(context.Background(), nil); count != 2 {
+		t.Errorf("users = %d, want the 2 of the first batch", count)
+	}
+	stored, _ := jobs.GetByID(context.Background(), job.ID)
+	if stored.Status != models.JobStatusFailed {
+		t.Errorf("status = %s, want failed", stored.Status)
+	}
+	if staged := memory.NewStagingRepository(db).StagingUsers(job.ID); len(staged) != 0 {
+		t.Errorf("staging rows = %d, want them cleaned up", len(staged))
+	}
+}
+
+// cancelJobAfterBatch cancels the job of an import by request once its
+// first batch is written, as the worker pool does
+type cancelJobAfterBatch struct {
+	hooks.Base
+	jobs   *memory.JobRepository
+	cancel context.CancelCauseFunc
+}
+
+func (h cancelJobAfterBatch) OnBatchInserted(ctx context.Context, job *models.Job, _ models.ResourceType, _ []uuid.UUID) {
+	h.jobs.FinishManually(ctx, job.ID, models.JobStatusCancelled, "wrong file")
+	h.cancel(models.ErrJobCancelled)
+}
+
+func TestProcessImport_CancelledByRequest(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	jobs := memory.NewJobRepository(db)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	svc.RegisterHooks(cancelJobAfterBatch{jobs: jobs, cancel: cancel})
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	file := writeTempFile(t, "users.ndjson", `{"email":"a@example.com","name":"A","role":"reader"}
+{"email":"b@example.com","name":"B","role":"reader"}
+{"email":"c@example.com","name":"C","role":"reader"}
+{"email":"d@example.com","name":"D","role":"reader"}
+{"email":"bad","name":"E","role":"reader"}
+`)
+
+	err := svc.ProcessImport(ctx, file, job, "ndjson")
+	if !stderrors.Is(err, models.ErrJobCancelled) {
+		t.Fatalf("ProcessImport() error = %v, want %v", err, models.ErrJobCancelled)
+	}
+	// The job stays cancelled with the count of the batch written before
+	stored, _ := jobs.GetByID(context.Background(), job.ID)
+	if stored.Status != models.JobStatusCancelled || *stored.ErrorMessage != "wrong file" {
+		t.Errorf("job = %s, %q; want cancelled for the reason given", stored.Status, *stored.ErrorMessage)
+	}
+	if stored.ProcessedRecords != 5 || stored.SuccessfulRecords != 2 || stored.FailedRecords != 1 {
+		t.Errorf("counts = %d processed, %d written, %d failed; want 5, 2, 1", stored.ProcessedRecords, stored.SuccessfulRecords, stored.FailedRecords)
+	}
+	if job.Status != models.JobStatusCancelled {
+		t.Errorf("job status in memory = %s, want cancelled", job.Status)
+	}
+	if staged := memory.NewStagingRepository(db).StagingUsers(job.ID); len(staged) != 0 {
+		t.Errorf("staging rows = %d, want them cleaned up", len(staged))
+	}
+}
+
+// insertAfterBatch stores user once the first batch of an import is written,
+// like a concurrent import would
+type insertAfterBatch struct {
+	hooks.Base
+	users *memory.UserRepository
+	user  *models.User
+}
+
+func (h *insertAfterBatch) OnBatchInserted(ctx context.Context, _ *models.Job, _ models.ResourceType, _ []uuid.UUID) {
+	if h.user != nil {
+		h.users.Create(ctx, h.user)
+		h.user = nil
+	}
+}
+
+func TestProcessImport_UsersConcurrentWriter(t *testing.T) {
+	users := `{"email":"a@example.com","name":"A","role":"reader"}
+{"email":"b@example.com","name":"B","role":"reader"}
+{"email":"c@example.com","name":"C","role":"reader"}
+{"email":"d@example.com","name":"D","role":"reader"}
+`
+	for _, tt := range []struct {
+		name     string
+		fastPath int
+	}{
+		{name: "staging tables", fastPath: 0},
+		{name: "fast path", fastPath: 100},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, db := newTestService(t, tt.fastPath)
+			svc.RegisterHooks(&insertAfterBatch{
+				users: memory.NewUserRepository(db),
+				user:  &models.User{Email: "d@example.com", Name: "Other D", Role: "reader"},
+			})
+
+			// d@example.com is taken after dedup ran, before its batch is written
+			job := runImport(t, svc, db, models.ResourceTypeUsers, "users.ndjson", users)
+			if job.Status != models.JobStatusCompleted {
+				t.Fatalf("status = %s (%v), want completed", job.Status, job.ErrorMessage)
+			}
+			if job.SuccessfulRecords != 3 || job.DuplicateRecords != 1 {
+				t.Errorf("successful = %d, duplicates = %d; want 3 and 1", job.SuccessfulRecords, job.DuplicateRecords)
+			}
+			d, _ := memory.NewUserRepository(db).GetByEmail(context.Background(), "d@example.com")
+			if d == nil || d.Name != "Other D" {
+				t.Errorf("d@example.com = %+v, want the concurrent writer's user", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package importservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// The import ledger records the natural key and content hash of every record
+// an import writes. Rows whose content it already recorded under their key
+// are taken out before staging, as long as the record is still stored, so
+// replaying a file that was imported before writes nothing.
+
+// contentHash hashes the values a staging row gives its record. Values are
+// encoded as a JSON array, so a missing value hashes differently from an
+// empty one.
+func contentHash(values ...any) string {
+	data, _ := json.Marshal(values)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// skipImported marks the rows of batch whose content the ledger recorded for
+// a record that is still stored, and returns how many it marked
+func skipImported[S any](ctx context.Context, s *Service, job *models.Job, l Ledgerer[S], batch []S) (int, error) {
+	keys := make([]string, len(batch))
+	hashes := make([]string, len(batch))
+	lookup := make([]string, 0, len(batch))
+	for i := range batch {
+		key, hash, ok := l.LedgerEntry(&batch[i])
+		if !ok {
+			continue
+		}
+		keys[i], hashes[i] = key, hash
+		lookup = append(lookup, key)
+	}
+	if len(lookup) == 0 {
+		return 0, nil
+	}
+
+	recorded, err := s.ledger.Hashes(ctx, job.Resource, lookup)
+	if err != nil || len(recorded) == 0 {
+		return 0, err
+	}
+	var unchanged []string
+	for i, key := range keys {
+		if key != "" && recorded[key] == hashes[i] {
+			unchanged = append(unchanged, key)
+		}
+	}
+	if len(unchanged) == 0 {
+		return 0, nil
+	}
+
+	// A record deleted since it was imported is imported again
+	stored, err := l.Stored(ctx, unchanged)
+	if err != nil {
+		return 0, err
+	}
+	skipped := 0
+	for i, key := range keys {
+		if key != "" && recorded[key] == hashes[i] && stored[key] {
+			l.MarkImported(&batch[i])
+			skipped++
+		}
+	}
+	return skipped, nil
+}
+
+// recordImported records the rows of batch the import wrote in the ledger
+func recordImported[S any](ctx context.Context, s *Service, job *models.Job, l Ledgerer[S], batch []S) error {
+	entries := make([]*models.LedgerEntry, 0, len(batch))
+	// One statement can't write a key twice, so a repeated key keeps the
+	// last row's content
+	index := make(map[string]int, len(batch))
+	for i := range batch {
+		key, hash, ok := l.LedgerEntry(&batch[i])
+		if !ok {
+			continue
+		}
+		entry := &models.LedgerEntry{Resource: job.Resource, NaturalKey: key, ContentHash: hash, JobID: job.ID}
+		if at, seen := index[key]; seen {
+			entries[at] = entry
+			continue
+		}
+		index[key] = len(entries)
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.ledger.Record(ctx, entries)
+}
@@ -0,0 +1,75 @@
+package importservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestProcessImport_Ledger(t *testing.T) {
+	users := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Bob","role":"reader","active":"true"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+`
+	changed := `{"id":"` + annID + `","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"` + bobID + `","email":"bob@example.com","name":"Robert","role":"reader","active":"true"}
+{"email":"carl@example.com","name":"Carl","role":"reader","active":"true"}
+`
+	for _, fastPath := range []int{0, 100} {
+		svc, db := newTestService(t, fastPath)
+		svc.config.Load().Ledger = true
+		svc.SetLedger(memory.NewLedgerRepository(db))
+		ctx := context.Background()
+
+		run := func(content string, params *models.JobParams) *models.Job {
+			t.Helper()
+			job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: params}
+			if err := memory.NewJobRepository(db).Create(ctx, job); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			if err := svc.ProcessImport(ctx, writeTempFile(t, "users.ndjson", content), job, "ndjson"); err != nil {
+				t.Fatalf("ProcessImport() error: %v", err)
+			}
+			stored, _ := memory.NewJobRepository(db).GetByID(ctx, job.ID)
+			return stored
+		}
+		imported := func(job *models.Job) int {
+			if job.Summary == nil {
+				return 0
+			}
+			return job.Summary.AlreadyImported
+		}
+
+		if job := run(users, nil); job.SuccessfulRecords != 3 || imported(job) != 0 {
+			t.Fatalf("fastPath=%d: first import successful = %d, already imported = %d; want 3, 0", fastPath, job.SuccessfulRecords, imported(job))
+		}
+
+		// Replaying the file writes nothing, without reporting duplicates
+		job := run(users, nil)
+		if job.SuccessfulRecords != 0 || job.FailedRecords != 0 || job.DuplicateRecords != 0 || imported(job) != 3 || !job.Summary.AllAlreadyImported {
+			t.Errorf("fastPath=%d: replay = successful %d, failed %d, duplicates %d, summary %+v; want only 3 already imported",
+				fastPath, job.SuccessfulRecords, job.FailedRecords, job.DuplicateRecords, job.Summary)
+		}
+
+		// A changed row is written, and a deleted record imported again
+		if err := memory.NewUserRepository(db).Delete(ctx, uuid.MustParse(annID)); err != nil {
+			t.Fatalf("Delete() error: %v", err)
+		}
+		job = run(changed, nil)
+		if job.SuccessfulRecords != 2 || imported(job) != 1 || job.Summary.AllAlreadyImported {
+			t.Errorf("fastPath=%d: changed file successful = %d, summary %+v; want 2 written and carl already imported", fastPath, job.SuccessfulRecords, job.Summary)
+		}
+		if bob, _ := memory.NewUserRepository(db).GetByID(ctx, uuid.MustParse(bobID)); bob == nil || bob.Name != "Robert" {
+			t.Errorf("fastPath=%d: bob = %+v, want him renamed", fastPath, bob)
+		}
+
+		// Ignoring the ledger writes the rows with IDs again
+		job = run(changed, &models.JobParams{IgnoreLedger: true})
+		if imported(job) != 0 || job.SuccessfulRecords != 2 {
+			t.Errorf("fastPath=%d: ignoring the ledger successful = %d, already imported = %d; want 2, 0", fastPath, job.SuccessfulRecords, imported(job))
+		}
+	}
+}
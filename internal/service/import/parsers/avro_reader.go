@@ -0,0 +1,508 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// avroMagic starts every Avro object container file
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// maxAvroBlockSize bounds the size a block may claim, so a corrupt file
+// can't cause a huge allocation
+const maxAvroBlockSize = 1 << 30
+
+var errCorruptAvro = errors.New("corrupt avro data")
+
+// avroSchema is a parsed Avro type
+type avroSchema struct {
+	// typ is a primitive type name, or record, enum, array, map, fixed or
+	// union
+	typ      string
+	logical  string
+	fields   []avroField   // record
+	items    *avroSchema   // array
+	values   *avroSchema   // map
+	branches []*avroSchema // union
+	symbols  []string      // enum
+	size     int           // fixed
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseAvroSchema parses a schema, resolving named types through names
+func parseAvroSchema(raw json.RawMessage, namespace string, names map[string]*avroSchema) (*avroSchema, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty avro schema")
+	}
+
+	switch raw[0] {
+	case '"':
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return nil, err
+		}
+		if avroPrimitives[name] {
+			return &avroSchema{typ: name}, nil
+		}
+		if s, ok := names[name]; ok {
+			return s, nil
+		}
+		if s, ok := names[namespace+"."+name]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", name)
+
+	case '[':
+		var branches []json.RawMessage
+		if err := json.Unmarshal(raw, &branches); err != nil {
+			return nil, err
+		}
+		union := &avroSchema{typ: "union"}
+		for _, b := range branches {
+			s, err := parseAvroSchema(b, namespace, names)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, s)
+		}
+		return union, nil
+	}
+
+	var def struct {
+		Type        json.RawMessage `json:"type"`
+		LogicalType string          `json:"logicalType"`
+		Name        string          `json:"name"`
+		Namespace   string          `json:"namespace"`
+		Fields      []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+		Items   json.RawMessage `json:"items"`
+		Values  json.RawMessage `json:"values"`
+		Symbols []string        `json:"symbols"`
+		Size    int             `json:"size"`
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	var typ string
+	if err := json.Unmarshal(def.Type, &typ); err != nil {
+		// A nested type definition, such as {"type": {"type": "array", ...}}
+		return parseAvroSchema(def.Type, namespace, names)
+	}
+
+	s := &avroSchema{typ: typ, logical: def.LogicalType}
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		if def.Namespace != "" {
+			namespace = def.Namespace
+		}
+		fullName := def.Name
+		if !strings.Contains(fullName, ".") && namespace != "" {
+			fullName = namespace + "." + fullName
+		}
+		// Registered before the fields so records can refer to themselves
+		names[fullName] = s
+		names[def.Name] = s
+	}
+
+	switch typ {
+	case "record", "error":
+		s.typ = "record"
+		for _, f := range def.Fields {
+			fs, err := parseAvroSchema(f.Type, namespace, names)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			s.fields = append(s.fields, avroField{name: f.Name, schema: fs})
+		}
+	case "enum":
+		s.symbols = def.Symbols
+	case "fixed":
+		s.size = def.Size
+	case "array":
+		items, err := parseAvroSchema(def.Items, namespace, names)
+		if err != nil {
+			return nil, err
+		}
+		s.items = items
+	case "map":
+		values, err := parseAvroSchema(def.Values, namespace, names)
+		if err != nil {
+			return nil, err
+		}
+		s.values = values
+	default:
+		if !avroPrimitives[typ] {
+			return parseAvroSchema(def.Type, namespace, names)
+		}
+	}
+	return s, nil
+}
+
+// avroDecoder reads values in the Avro binary encoding from a block
+type avroDecoder struct {
+	buf []byte
+}
+
+func (d *avroDecoder) long() (int64, error) {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		return 0, errCorruptAvro
+	}
+	d.buf = d.buf[n:]
+	return v, nil
+}
+
+func (d *avroDecoder) take(n int64) ([]byte, error) {
+	if n < 0 || n > int64(len(d.buf)) {
+		return nil, errCorruptAvro
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+// blockCount reads the item count of an array or map block. A negative
+// count is followed by the block's size in bytes.
+func (d *avroDecoder) blockCount() (int64, error) {
+	count, err := d.long()
+	if err != nil || count >= 0 {
+		return count, err
+	}
+	if _, err := d.long(); err != nil {
+		return 0, err
+	}
+	return -count, nil
+}
+
+// value decodes a value of schema s. Records and maps decode to
+// map[string]interface{}, arrays to []interface{} and timestamps to
+// time.Time.
+func (d *avroDecoder) value(s *avroSchema) (interface{}, error) {
+	switch s.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		switch s.logical {
+		case "date":
+			return epochDate(v), nil
+		case "timestamp-millis", "local-timestamp-millis":
+			return time.UnixMilli(v), nil
+		case "timestamp-micros", "local-timestamp-micros":
+			return time.UnixMicro(v), nil
+		case "timestamp-nanos", "local-timestamp-nanos":
+			return time.Unix(0, v), nil
+		}
+		return v, nil
+	case "float":
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes", "string":
+		n, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "fixed":
+		b, err := d.take(int64(s.size))
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "enum":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return nil, errCorruptAvro
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.branches)) {
+			return nil, errCorruptAvro
+		}
+		return d.value(s.branches[i])
+	case "array":
+		items := []interface{}{}
+		for {
+			count, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return items, nil
+			}
+			for ; count > 0; count-- {
+				item, err := d.value(s.items)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+		}
+	case "map":
+		values := map[string]interface{}{}
+		for {
+			count, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return values, nil
+			}
+			for ; count > 0; count-- {
+				n, err := d.long()
+				if err != nil {
+					return nil, err
+				}
+				key, err := d.take(n)
+				if err != nil {
+					return nil, err
+				}
+				if values[string(key)], err = d.value(s.values); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case "record":
+		rec := make(map[string]interface{}, len(s.fields))
+		for _, f := range s.fields {
+			v, err := d.value(f.schema)
+			if err != nil {
+				return nil, err
+			}
+			rec[f.name] = v
+		}
+		return rec, nil
+	}
+	return nil, fmt.Errorf("unsupported avro type %q", s.typ)
+}
+
+// avroReader reads the records of an Avro object container file
+type avroReader struct {
+	r         *bufio.Reader
+	schema    *avroSchema
+	codec     string
+	sync      []byte
+	block     avroDecoder
+	remaining int64
+}
+
+// newAvroReader reads the container header. The file's schema must be a
+// record.
+func newAvroReader(r io.Reader) (*avroReader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head := make([]byte, len(avroMagic))
+	if _, err := io.ReadFull(br, head); err != nil || !bytes.Equal(head, avroMagic) {
+		return nil, fmt.Errorf("not an avro object container file")
+	}
+
+	meta := map[string][]byte{}
+	for {
+		count, err := binary.ReadVarint(br)
+		if err != nil {
+			return nil, errCorruptAvro
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			count = -count
+			if _, err := binary.ReadVarint(br); err != nil {
+				return nil, errCorruptAvro
+			}
+		}
+		for ; count > 0; count-- {
+			key, err := readAvroBytes(br)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readAvroBytes(br)
+			if err != nil {
+				return nil, err
+			}
+			meta[string(key)] = value
+		}
+	}
+
+	ar := &avroReader{r: br, codec: string(meta["avro.codec"]), sync: make([]byte, 16)}
+	if _, err := io.ReadFull(br, ar.sync); err != nil {
+		return nil, errCorruptAvro
+	}
+	if ar.codec == "" {
+		ar.codec = "null"
+	}
+	if ar.codec != "null" && ar.codec != "deflate" && ar.codec != "snappy" {
+		return nil, fmt.Errorf("unsupported avro codec %q", ar.codec)
+	}
+
+	schema, err := parseAvroSchema(meta["avro.schema"], "", map[string]*avroSchema{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	if schema.typ != "record" {
+		return nil, fmt.Errorf("avro schema must be a record, got %s", schema.typ)
+	}
+	ar.schema = schema
+	return ar, nil
+}
+
+func readAvroBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadVarint(r)
+	if err != nil || n < 0 || n > maxAvroBlockSize {
+		return nil, errCorruptAvro
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, errCorruptAvro
+	}
+	return b, nil
+}
+
+// Fields returns the names of the schema's fields
+func (ar *avroReader) Fields() []string {
+	fields := make([]string, len(ar.schema.fields))
+	for i, f := range ar.schema.fields {
+		fields[i] = f.name
+	}
+	return fields
+}
+
+// Next decodes the next record, reading the next block when the current one
+// is used up
+func (ar *avroReader) Next() (columnRecord, error) {
+	for ar.remaining == 0 {
+		if err := ar.readBlock(); err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := ar.block.value(ar.schema)
+	if err != nil {
+		return nil, err
+	}
+	ar.remaining--
+
+	rec := make(columnRecord, len(ar.schema.fields))
+	for name, value := range v.(map[string]interface{}) {
+		if cv := columnValue(value); cv != nil {
+			rec[strings.ToLower(name)] = cv
+		}
+	}
+	return rec, nil
+}
+
+// readBlock reads and decompresses the next block, returning io.EOF at the
+// end of the file
+func (ar *avroReader) readBlock() error {
+	count, err := binary.ReadVarint(ar.r)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil || count < 0 {
+		return errCorruptAvro
+	}
+	data, err := readAvroBytes(ar.r)
+	if err != nil {
+		return err
+	}
+	sync := make([]byte, len(ar.sync))
+	if _, err := io.ReadFull(ar.r, sync); err != nil || !bytes.Equal(sync, ar.sync) {
+		return fmt.Errorf("%w: block not followed by the sync marker", errCorruptAvro)
+	}
+
+	switch ar.codec {
+	case "deflate":
+		if data, err = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), maxAvroBlockSize)); err != nil {
+			return fmt.Errorf("%w: %v", errCorruptAvro, err)
+		}
+	case "snappy":
+		// The compressed data is followed by the CRC32 of the uncompressed data
+		if len(data) < 4 {
+			return errCorruptAvro
+		}
+		checksum := binary.BigEndian.Uint32(data[len(data)-4:])
+		if data, err = decodeSnappy(data[:len(data)-4]); err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(data) != checksum {
+			return fmt.Errorf("%w: block checksum mismatch", errCorruptAvro)
+		}
+	}
+
+	ar.block = avroDecoder{buf: data}
+	ar.remaining = count
+	return nil
+}
+
+// countRecords adds up the record counts of the remaining blocks without
+// decoding them
+func (ar *avroReader) countRecords() (int, error) {
+	total := 0
+	for {
+		count, err := binary.ReadVarint(ar.r)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil || count < 0 {
+			return 0, errCorruptAvro
+		}
+		size, err := binary.ReadVarint(ar.r)
+		if err != nil || size < 0 {
+			return 0, errCorruptAvro
+		}
+		if _, err := ar.r.Discard(int(size) + len(ar.sync)); err != nil {
+			return 0, errCorruptAvro
+		}
+		total += int(count)
+	}
+}
@@ -0,0 +1,246 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// columnRecord is a decoded Avro or Parquet record keyed by lower-case field
+// name. Values are strings, or string slices for arrays; null fields are
+// absent.
+type columnRecord map[string]interface{}
+
+func (r columnRecord) field(name string) (string, bool) {
+	switch v := r[name].(type) {
+	case string:
+		return v, true
+	case []string:
+		return strings.Join(v, ","), true
+	}
+	return "", false
+}
+
+// list returns array fields as they are, and splits string fields on commas
+// like a CSV column
+func (r columnRecord) list(name string) ([]string, bool) {
+	switch v := r[name].(type) {
+	case []string:
+		return v, true
+	case string:
+		return splitList(v), true
+	}
+	return nil, false
+}
+
+// recordReader reads the records of an Avro or Parquet file in order
+type recordReader interface {
+	// Fields returns the names of the file's top-level fields
+	Fields() []string
+	// Next returns the next record, or io.EOF after the last one
+	Next() (columnRecord, error)
+}
+
+func init() {
+	open := func(file *os.File, opts ParserOptions) (RecordParser, error) {
+		return NewColumnarFileParser(file)
+	}
+	Register(FormatAvro, open, ".avro")
+	Register(FormatParquet, open, ".parquet")
+}
+
+// ColumnarParser parses Avro object container files and Parquet files. Each
+// record's top-level fields map to the import structs by name, the same way
+// CSV columns do; rows are numbered by record from 1.
+type ColumnarParser struct {
+	reader recordReader
+	row    int
+}
+
+// NewColumnarParser creates a parser for an Avro or Parquet file of size
+// bytes. Parquet keeps its metadata at the end of the file, so r must allow
+// random access.
+func NewColumnarParser(r io.ReaderAt, size int64, format FileFormat) (*ColumnarParser, error) {
+	var reader recordReader
+	var err error
+	switch format {
+	case FormatAvro:
+		reader, err = newAvroReader(io.NewSectionReader(r, 0, size))
+	case FormatParquet:
+		reader, err = newParquetReader(r, size)
+	default:
+		return nil, fmt.Errorf("%s is not a columnar format", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %w", format, err)
+	}
+	return &ColumnarParser{reader: reader}, nil
+}
+
+// NewColumnarFileParser creates a parser for file, picking Avro or Parquet
+// from its extension. Compressed files are refused: both formats need
+// random access, and compress their data themselves.
+func NewColumnarFileParser(file *os.File) (*ColumnarParser, error) {
+	format := DetectFormat(file.Name())
+	if IsCompressed(file) {
+		return nil, fmt.Errorf("gzip-compressed %s files are not supported; upload the %s file itself", format, format)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewColumnarParser(file, info.Size(), format)
+}
+
+// CountColumnarRows returns the number of records in an Avro or Parquet file
+// from its block headers or metadata, without decoding the records
+func CountColumnarRows(r io.ReaderAt, size int64, format FileFormat) (int, error) {
+	switch format {
+	case FormatAvro:
+		ar, err := newAvroReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return 0, err
+		}
+		return ar.countRecords()
+	case FormatParquet:
+		return parquetNumRows(r, size)
+	}
+	return 0, fmt.Errorf("%s is not a columnar format", format)
+}
+
+// Fields returns the names of the file's top-level fields
+func (p *ColumnarParser) Fields() []string {
+	return p.reader.Fields()
+}
+
+// TotalLines returns the number of records read so far
+func (p *ColumnarParser) TotalLines() int {
+	return p.row
+}
+
+// Parse streams the records of resource from the file. Every record maps,
+// so parseErr is always nil; an undecodable file ends the parse instead.
+func (p *ColumnarParser) Parse(resource models.ResourceType, fn RecordFunc) error {
+	switch resource {
+	case models.ResourceTypeUsers:
+		return p.ParseUsers(func(row int, user *models.UserImport, raw string, _ *ParseError) error {
+			return fn(row, user, raw, nil)
+		})
+	case models.ResourceTypeArticles:
+		return p.ParseArticles(func(row int, article *models.ArticleImport, raw string, _ *ParseError) error {
+			return fn(row, article, raw, nil)
+		})
+	case models.ResourceTypeComments:
+		return p.ParseComments(func(row int, comment *models.CommentImport, raw string, _ *ParseError) error {
+			return fn(row, comment, raw, nil)
+		})
+	}
+	return unknownResource(resource)
+}
+
+// ParseUsers streams user records from the file
+func (p *ColumnarParser) ParseUsers(callback func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(rec columnRecord, raw string) error {
+		return callback(p.row, mapUser(rec), raw, nil)
+	})
+}
+
+// ParseArticles streams article records from the file
+func (p *ColumnarParser) ParseArticles(callback func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(rec columnRecord, raw string) error {
+		return callback(p.row, mapArticle(rec), raw, nil)
+	})
+}
+
+// ParseComments streams comment records from the file
+func (p *ColumnarParser) ParseComments(callback func(row int, comment *models.CommentImport, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(rec columnRecord, raw string) error {
+		return callback(p.row, mapComment(rec), raw, nil)
+	})
+}
+
+// scan calls fn for each record with the record rendered as JSON, which
+// stands in for the raw line in error reports. A file that can't be decoded
+// ends the scan with an error, as there is no next line to resume from.
+func (p *ColumnarParser) scan(fn func(rec columnRecord, raw string) error) error {
+	for {
+		rec, err := p.reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record %d: %w", p.row+1, err)
+		}
+		p.row++
+
+		raw, _ := json.Marshal(rec)
+		if err := fn(rec, string(raw)); err != nil {
+			return err
+		}
+	}
+}
+
+// columnValue converts a decoded value to what a columnRecord holds: a
+// string, a string slice for arrays of scalars, or nil for null. Null array
+// items are dropped, times are written as RFC 3339 in UTC and other nested
+// values as JSON.
+func columnValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			if item == nil {
+				continue
+			}
+			s, ok := columnValue(item).(string)
+			if !ok {
+				data, _ := json.Marshal(val)
+				return string(data)
+			}
+			items = append(items, s)
+		}
+		return items
+	case map[string]interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return scalarString(val)
+	}
+}
+
+// scalarString renders a decoded scalar value as a string
+func scalarString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case int32:
+		return strconv.FormatInt(int64(val), 10)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// epochDate renders a date stored as days since the Unix epoch
+func epochDate(days int64) string {
+	return time.Unix(days*86400, 0).UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,488 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/service/export/avro"
+)
+
+func TestColumnarParser_AvroRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	published := created.Add(time.Hour)
+	lang := "en"
+	articles := []*models.Article{
+		{ID: uuid.New(), Slug: "first", Title: "First", Body: "Body", AuthorID: uuid.New(), Tags: json.RawMessage(`["go","data"]`),
+			PublishedAt: &published, Status: "published", Lang: &lang, CreatedAt: created, UpdatedAt: created},
+		{ID: uuid.New(), Slug: "second", Title: "Second", Body: "Draft", AuthorID: uuid.New(), Status: "draft", CreatedAt: created, UpdatedAt: created},
+	}
+
+	for _, codec := range []string{avro.CodecNull, avro.CodecDeflate} {
+		t.Run(codec, func(t *testing.T) {
+			schema, _ := avro.Schema(models.ResourceTypeArticles)
+			var buf bytes.Buffer
+			aw, err := avro.NewWriter(&buf, schema, codec, 1)
+			if err != nil {
+				t.Fatalf("NewWriter() error: %v", err)
+			}
+			for _, a := range articles {
+				if err := aw.Append(func(b []byte) []byte { return avro.AppendArticle(b, a) }); err != nil {
+					t.Fatalf("Append() error: %v", err)
+				}
+			}
+			aw.Close()
+
+			p, err := NewColumnarParser(bytes.NewReader(buf.Bytes()), int64(buf.Len()), FormatAvro)
+			if err != nil {
+				t.Fatalf("NewColumnarParser() error: %v", err)
+			}
+			if fields := p.Fields(); len(fields) != 12 || fields[1] != "slug" {
+				t.Errorf("Fields() = %v", fields)
+			}
+
+			var got []*models.ArticleImport
+			var rows []int
+			err = p.ParseArticles(func(row int, article *models.ArticleImport, raw string, parseErr *ParseError) error {
+				rows = append(rows, row)
+				got = append(got, article)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseArticles() error: %v", err)
+			}
+
+			want := &models.ArticleImport{
+				ID: articles[0].ID.String(), Slug: "first", Title: "First", Body: "Body", AuthorID: articles[0].AuthorID.String(),
+				Tags: []string{"go", "data"}, PublishedAt: "2024-03-01T13:30:00Z", Status: "published",
+			}
+			if !reflect.DeepEqual(rows, []int{1, 2}) || len(got) != 2 {
+				t.Fatalf("rows = %v, want [1 2]", rows)
+			}
+			if !reflect.DeepEqual(got[0], want) {
+				t.Errorf("article = %+v, want %+v", got[0], want)
+			}
+			if got[1].PublishedAt != "" || len(got[1].Tags) != 0 {
+				t.Errorf("second article = %+v, want no published_at or tags", got[1])
+			}
+
+			count, err := CountColumnarRows(bytes.NewReader(buf.Bytes()), int64(buf.Len()), FormatAvro)
+			if err != nil || count != 2 {
+				t.Errorf("CountColumnarRows() = %d, %v; want 2", count, err)
+			}
+		})
+	}
+}
+
+func TestColumnarParser_AvroRejectsOtherFiles(t *testing.T) {
+	data := []byte("id,email\n1,a@example.com\n")
+	if _, err := NewColumnarParser(bytes.NewReader(data), int64(len(data)), FormatAvro); err == nil {
+		t.Error("NewColumnarParser() accepted a CSV file as avro")
+	}
+	if _, err := NewColumnarParser(bytes.NewReader(data), int64(len(data)), FormatParquet); err == nil {
+		t.Error("NewColumnarParser() accepted a CSV file as parquet")
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	// "abcd" as a literal, then a copy of 8 bytes from offset 4
+	block := []byte{12, 3 << 2, 'a', 'b', 'c', 'd', 0x01 | (8-4)<<2, 4}
+	got, err := decodeSnappy(block)
+	if err != nil || string(got) != "abcdabcdabcd" {
+		t.Errorf("decodeSnappy() = %q, %v; want abcdabcdabcd", got, err)
+	}
+	if _, err := decodeSnappy([]byte{12, 3 << 2, 'a'}); err == nil {
+		t.Error("decodeSnappy() accepted a truncated literal")
+	}
+}
+
+func TestDecodeHybrid(t *testing.T) {
+	// A run of three 1s, then a bit-packed group of 8 values at width 2
+	data := []byte{3 << 1, 1, 1<<1 | 1, 0b11100100, 0b00011011}
+	got, err := decodeHybrid(data, 2, 11)
+	if err != nil {
+		t.Fatalf("decodeHybrid() error: %v", err)
+	}
+	want := []int{1, 1, 1, 0, 1, 2, 3, 3, 2, 1, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeHybrid() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		v     interface{}
+		scale int
+		want  string
+	}{
+		{int32(12345), 2, "123.45"},
+		{int64(-5), 3, "-0.005"},
+		{[]byte{0xff, 0x85}, 1, "-12.3"},
+		{int64(42), 0, "42"},
+	}
+	for _, tt := range tests {
+		if got := formatDecimal(tt.v, tt.scale); got != tt.want {
+			t.Errorf("formatDecimal(%v, %d) = %q, want %q", tt.v, tt.scale, got, tt.want)
+		}
+	}
+}
+
+// thriftWriter writes the Thrift compact protocol for building test files
+type thriftWriter struct {
+	buf  []byte
+	last int16
+	ids  []int16
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.buf = binary.AppendVarint(w.buf, int64(id))
+	}
+	w.last = id
+}
+
+func (w *thriftWriter) int(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.field(id, thriftBinary)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// begin starts a struct field; list elements pass id 0 to skip the header
+func (w *thriftWriter) begin(id int16) {
+	if id != 0 {
+		w.field(id, thriftStruct)
+	}
+	w.ids = append(w.ids, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.ids[len(w.ids)-1]
+	w.ids = w.ids[:len(w.ids)-1]
+}
+
+func (w *thriftWriter) list(id int16, elemType byte, n int) {
+	w.field(id, thriftList)
+	w.buf = append(w.buf, byte(n)<<4|elemType)
+}
+
+// testParquetColumn is a column of a test file with its pages already
+// encoded
+type testParquetColumn struct {
+	schema    func(w *thriftWriter) // writes the column's schema elements
+	elements  int                   // number of schema elements written
+	physical  int64
+	codec     int64
+	path      []string
+	numValues int
+	dictPage  []byte
+	dataPage  []byte
+}
+
+// writeParquet assembles a single row group file from its columns
+func writeParquet(columns []testParquetColumn, rows int) []byte {
+	file := append([]byte(nil), parquetMagic...)
+	offsets := make([][2]int64, len(columns))
+	for i, col := range columns {
+		offsets[i][0] = -1
+		if col.dictPage != nil {
+			offsets[i][0] = int64(len(file))
+			file = append(file, col.dictPage...)
+		}
+		offsets[i][1] = int64(len(file))
+		file = append(file, col.dataPage...)
+	}
+
+	w := &thriftWriter{}
+	w.int(1, 1)
+	elements := 1
+	for _, col := range columns {
+		elements += col.elements
+	}
+	w.field(2, thriftList)
+	w.buf = append(w.buf, 0xf0|thriftStruct)
+	w.buf = binary.AppendUvarint(w.buf, uint64(elements))
+	w.begin(0)
+	w.binary(4, []byte("schema"))
+	w.int(5, int64(len(columns)))
+	w.end()
+	for _, col := range columns {
+		col.schema(w)
+	}
+	w.int(3, int64(rows))
+
+	w.list(4, thriftStruct, 1)
+	w.begin(0)
+	w.list(1, thriftStruct, len(columns))
+	for i, col := range columns {
+		size := int64(len(col.dictPage) + len(col.dataPage))
+		w.begin(0)
+		w.int(2, offsets[i][1])
+		w.begin(3)
+		w.int(1, col.physical)
+		w.list(2, thriftI32, 1)
+		w.buf = binary.AppendVarint(w.buf, parquetPlain)
+		w.list(3, thriftBinary, len(col.path))
+		for _, p := range col.path {
+			w.buf = binary.AppendUvarint(w.buf, uint64(len(p)))
+			w.buf = append(w.buf, p...)
+		}
+		w.int(4, col.codec)
+		w.int(5, int64(col.numValues))
+		w.int(6, size)
+		w.int(7, size)
+		w.int(9, offsets[i][1])
+		if offsets[i][0] >= 0 {
+			w.int(11, offsets[i][0])
+		}
+		w.end()
+		w.end()
+	}
+	w.int(2, 0)
+	w.int(3, int64(rows))
+	w.end()
+	w.buf = append(w.buf, 0)
+
+	file = append(file, w.buf...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(w.buf)))
+	return append(file, parquetMagic...)
+}
+
+// schemaElement writes one schema element
+func schemaElement(w *thriftWriter, name string, physical, repetition, children, converted int64, logical func(w *thriftWriter)) {
+	w.begin(0)
+	if children == 0 {
+		w.int(1, physical)
+	}
+	w.int(3, repetition)
+	w.binary(4, []byte(name))
+	if children > 0 {
+		w.int(5, children)
+	}
+	if converted >= 0 {
+		w.int(6, converted)
+	}
+	if logical != nil {
+		w.begin(10)
+		logical(w)
+		w.end()
+	}
+	w.end()
+}
+
+// pageHeader encodes a page header followed by its data
+func pageHeader(pageType int64, numValues, uncompressed int, data []byte, encoding int64) []byte {
+	w := &thriftWriter{}
+	w.int(1, pageType)
+	w.int(2, int64(uncompressed))
+	w.int(3, int64(len(data)))
+	if pageType == parquetDictionaryPage {
+		w.begin(7)
+		w.int(1, int64(numValues))
+		w.int(2, parquetPlain)
+		w.end()
+	} else {
+		w.begin(5)
+		w.int(1, int64(numValues))
+		w.int(2, encoding)
+		w.int(3, parquetRLE)
+		w.int(4, parquetRLE)
+		w.end()
+	}
+	w.buf = append(w.buf, 0)
+	return append(w.buf, data...)
+}
+
+// levels encodes levels as RLE runs of one, with the 4-byte length prefix
+// of a version 1 data page
+func levels(values ...int) []byte {
+	var runs []byte
+	for _, v := range values {
+		runs = append(runs, 1<<1, byte(v))
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(runs))), runs...)
+}
+
+func plainStrings(values ...string) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(v)))
+		out = append(out, v...)
+	}
+	return out
+}
+
+// snappyLiteral wraps data in a snappy block holding one literal
+func snappyLiteral(data []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(len(data)))
+	out = append(out, 60<<2, byte(len(data)-1))
+	return append(out, data...)
+}
+
+func testParquetUsers() []byte {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var createdData []byte
+	for i := 0; i < 3; i++ {
+		createdData = binary.LittleEndian.AppendUint64(createdData, uint64(created.Add(time.Duration(i)*time.Hour).UnixMicro()))
+	}
+
+	// email is dictionary-encoded and snappy-compressed, with a null
+	dict := snappyLiteral(plainStrings("ada@example.com"))
+	emailData := append(levels(1, 0, 1), 1, 1<<1|1, 0)
+	emailPage := snappyLiteral(emailData)
+
+	// tags is a standard three-level list: ["go","db"], null, []
+	tagsData := append(levels(0, 1, 0, 0), levels(3, 3, 0, 1)...)
+	tagsData = append(tagsData, plainStrings("go", "db")...)
+
+	columns := []testParquetColumn{
+		{
+			schema: func(w *thriftWriter) {
+				schemaElement(w, "id", parquetByteArray, 0, 0, 0, nil)
+			},
+			elements: 1, physical: parquetByteArray, path: []string{"id"}, numValues: 3,
+			dataPage: pageHeader(parquetDataPage, 3, 0, plainStrings("u1", "u2", "u3"), parquetPlain),
+		},
+		{
+			schema: func(w *thriftWriter) {
+				schemaElement(w, "Email", parquetByteArray, parquetOptional, 0, 0, nil)
+			},
+			elements: 1, physical: parquetByteArray, codec: 1, path: []string{"Email"}, numValues: 3,
+			dictPage: pageHeader(parquetDictionaryPage, 1, 19, dict, 0),
+			dataPage: pageHeader(parquetDataPage, 3, len(emailData), emailPage, parquetRLEDictionary),
+		},
+		{
+			schema: func(w *thriftWriter) {
+				schemaElement(w, "active", parquetBoolean, 0, 0, -1, nil)
+			},
+			elements: 1, physical: parquetBoolean, path: []string{"active"}, numValues: 3,
+			dataPage: pageHeader(parquetDataPage, 3, 0, []byte{0b101}, parquetPlain),
+		},
+		{
+			schema: func(w *thriftWriter) {
+				schemaElement(w, "created_at", parquetInt64, 0, 0, -1, func(w *thriftWriter) {
+					w.begin(8)
+					w.bool(1, true)
+					w.begin(2)
+					w.begin(2)
+					w.end()
+					w.end()
+					w.end()
+				})
+			},
+			elements: 1, physical: parquetInt64, path: []string{"created_at"}, numValues: 3,
+			dataPage: pageHeader(parquetDataPage, 3, 0, createdData, parquetPlain),
+		},
+		{
+			schema: func(w *thriftWriter) {
+				schemaElement(w, "tags", 0, parquetOptional, 1, 3, nil)
+				schemaElement(w, "list", 0, parquetRepeated, 1, -1, nil)
+				schemaElement(w, "element", parquetByteArray, parquetOptional, 0, 0, nil)
+			},
+			elements: 3, physical: parquetByteArray, path: []string{"tags", "list", "element"}, numValues: 4,
+			dataPage: pageHeader(parquetDataPage, 4, 0, tagsData, parquetPlain),
+		},
+	}
+	return writeParquet(columns, 3)
+}
+
+func TestColumnarParser_Parquet(t *testing.T) {
+	data := testParquetUsers()
+	p, err := NewColumnarParser(bytes.NewReader(data), int64(len(data)), FormatParquet)
+	if err != nil {
+		t.Fatalf("NewColumnarParser() error: %v", err)
+	}
+	if fields := p.Fields(); !reflect.DeepEqual(fields, []string{"id", "Email", "active", "created_at", "tags"}) {
+		t.Errorf("Fields() = %v", fields)
+	}
+
+	var records []columnRecord
+	for {
+		rec, err := p.reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		records = append(records, rec)
+	}
+
+	want := []columnRecord{
+		{"id": "u1", "email": "ada@example.com", "active": "true", "created_at": "2024-03-01T12:00:00Z", "tags": []string{"go", "db"}},
+		{"id": "u2", "active": "false", "created_at": "2024-03-01T13:00:00Z"},
+		{"id": "u3", "email": "ada@example.com", "active": "true", "created_at": "2024-03-01T14:00:00Z"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v\nwant %v", records, want)
+	}
+
+	count, err := CountColumnarRows(bytes.NewReader(data), int64(len(data)), FormatParquet)
+	if err != nil || count != 3 {
+		t.Errorf("CountColumnarRows() = %d, %v; want 3", count, err)
+	}
+}
+
+func TestColumnarParser_ParquetUsers(t *testing.T) {
+	data := testParquetUsers()
+	p, err := NewColumnarParser(bytes.NewReader(data), int64(len(data)), FormatParquet)
+	if err != nil {
+		t.Fatalf("NewColumnarParser() error: %v", err)
+	}
+
+	var users []*models.UserImport
+	err = p.ParseUsers(func(row int, user *models.UserImport, raw string, parseErr *ParseError) error {
+		if !json.Valid([]byte(raw)) {
+			t.Errorf("row %d raw = %q, want JSON", row, raw)
+		}
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseUsers() error: %v", err)
+	}
+	want := &models.UserImport{ID: "u1", Email: "ada@example.com", Active: "true", CreatedAt: "2024-03-01T12:00:00Z"}
+	if len(users) != 3 || !reflect.DeepEqual(users[0], want) {
+		t.Fatalf("users[0] = %+v, want %+v", users[0], want)
+	}
+	if users[1].Email != "" {
+		t.Errorf("null email = %q, want empty", users[1].Email)
+	}
+
+	profiler, err := ProfileColumnar(mustColumnar(t, data))
+	if err != nil || profiler.Rows() != 3 {
+		t.Errorf("ProfileColumnar() rows = %d, %v; want 3", profiler.Rows(), err)
+	}
+}
+
+func mustColumnar(t *testing.T, data []byte) *ColumnarParser {
+	t.Helper()
+	p, err := NewColumnarParser(bytes.NewReader(data), int64(len(data)), FormatParquet)
+	if err != nil {
+		t.Fatalf("NewColumnarParser() error: %v", err)
+	}
+	return p
+}
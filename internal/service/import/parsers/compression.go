@@ -0,0 +1,54 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressedExtensions are the extensions of gzip-compressed files. The
+// extension before one names the format, so users.ndjson.gz is NDJSON.
+var compressedExtensions = map[string]bool{".gz": true, ".gzip": true}
+
+// TrimCompressedExt removes a compression extension from filename
+func TrimCompressedExt(filename string) string {
+	if ext := strings.ToLower(filepath.Ext(filename)); compressedExtensions[ext] {
+		return filename[:len(filename)-len(ext)]
+	}
+	return filename
+}
+
+// Decompress returns a reader of r's content, inflating it as it is read
+// when it starts with the gzip magic bytes, whatever the file is named.
+// Concatenated gzip members are read as one stream, as gzip -d does.
+func Decompress(r io.Reader) (io.Reader, bool, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !bytes.Equal(head, gzipMagic) {
+		return br, false, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read gzip file: %w", err)
+	}
+	return zr, true, nil
+}
+
+// IsCompressed reports whether file starts with the gzip magic bytes. It
+// reads from the start of file without moving its offset.
+func IsCompressed(file *os.File) bool {
+	head := make([]byte, len(gzipMagic))
+	n, _ := file.ReadAt(head, 0)
+	return n == len(head) && bytes.Equal(head, gzipMagic)
+}
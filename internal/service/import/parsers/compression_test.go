@@ -0,0 +1,60 @@
+package parsers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	// Concatenated members read as one stream, like gzip -d
+	multi := append(gzipped(t, "id\n1\n"), gzipped(t, "2\n")...)
+	tests := []struct {
+		name       string
+		data       []byte
+		want       string
+		compressed bool
+	}{
+		{"plain", []byte("id\n1\n"), "id\n1\n", false},
+		{"empty", nil, "", false},
+		{"gzip", gzipped(t, "id\n1\n"), "id\n1\n", true},
+		{"members", multi, "id\n1\n2\n", true},
+	}
+	for _, tt := range tests {
+		r, compressed, err := Decompress(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: Decompress() error: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: ReadAll() error: %v", tt.name, err)
+		}
+		if string(got) != tt.want || compressed != tt.compressed {
+			t.Errorf("%s: Decompress() = %q, %v; want %q, %v", tt.name, got, compressed, tt.want, tt.compressed)
+		}
+	}
+
+	// A truncated stream fails as it is read
+	data := gzipped(t, "id\n1\n")
+	r, _, err := Decompress(bytes.NewReader(data[:len(data)-4]))
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("truncated gzip file read without an error")
+	}
+}
@@ -5,26 +5,59 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
+func init() {
+	Register(FormatCSV, func(file *os.File, opts ParserOptions) (RecordParser, error) {
+		r, _, err := Decompress(file)
+		if err != nil {
+			return nil, err
+		}
+		p, err := NewCSVParserWithEncoding(r, opts.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CSV parser: %w", err)
+		}
+		return p, nil
+	}, ".csv")
+}
+
 // CSVParser parses CSV files for user imports
 type CSVParser struct {
 	reader     *csv.Reader
+	raw        *rawRecorder
+	offset     int64
 	headers    []string
 	headerMap  map[string]int
 	lineNumber int
+	// lastErr is why the current row has no record, nil when it parsed
+	lastErr *ParseError
 }
 
-// NewCSVParser creates a new CSV parser from a reader
+// NewCSVParser creates a new CSV parser from a reader. Rows must have as many
+// fields as the header and use well-formed quotes; rows that don't are passed
+// to the callback as parse errors.
 func NewCSVParser(r io.Reader) (*CSVParser, error) {
-	// Wrap in buffered reader for efficiency
-	br := bufio.NewReaderSize(r, 64*1024) // 64KB buffer
+	return NewCSVParserWithEncoding(r, EncodingAuto)
+}
+
+// NewCSVParserWithEncoding creates a CSV parser that transcodes the input
+// from enc to UTF-8 and strips any byte order mark
+func NewCSVParserWithEncoding(r io.Reader, enc Encoding) (*CSVParser, error) {
+	decoded, _, err := NewDecoder(r, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the bytes behind each row so malformed rows can be reported as-is
+	raw := &rawRecorder{r: decoded}
+	br := bufio.NewReaderSize(raw, 64*1024) // 64KB buffer
 	csvReader := csv.NewReader(br)
-	csvReader.FieldsPerRecord = -1 // Allow variable number of fields
-	csvReader.LazyQuotes = true
+	csvReader.FieldsPerRecord = 0 // Every row must match the header
 	csvReader.TrimLeadingSpace = true
 
 	// Read header row
@@ -39,64 +72,113 @@ func NewCSVParser(r io.Reader) (*CSVParser, error) {
 		headerMap[strings.ToLower(strings.TrimSpace(h))] = i
 	}
 
-	return &CSVParser{
+	p := &CSVParser{
 		reader:     csvReader,
+		raw:        raw,
 		headers:    headers,
 		headerMap:  headerMap,
 		lineNumber: 1, // Header is line 1
-	}, nil
+	}
+	p.rawRow()
+	return p, nil
+}
+
+// next reads the next record along with its raw text. A malformed row
+// returns a nil record with lastErr set.
+func (p *CSVParser) next() ([]string, string, error) {
+	record, err := p.reader.Read()
+	if err == io.EOF {
+		return nil, "", err
+	}
+
+	p.lineNumber++
+	p.lastErr = nil
+	raw := p.rawRow()
+
+	if err != nil {
+		parseErr, ok := err.(*csv.ParseError)
+		if !ok {
+			return nil, raw, err
+		}
+		p.lastErr = &ParseError{Code: errors.ErrCodeFileParseError, Err: parseErr.Err}
+		return nil, raw, nil
+	}
+	return record, raw, nil
+}
+
+// rawRow returns the input consumed since the previous call, without the
+// trailing line break
+func (p *CSVParser) rawRow() string {
+	end := p.reader.InputOffset()
+	n := int(end - p.offset)
+	row := string(p.raw.buf[:n])
+	p.raw.buf = p.raw.buf[n:]
+	p.offset = end
+	return strings.TrimRight(row, "\r\n")
+}
+
+// rawRecorder keeps a copy of everything read through it until the parser
+// has consumed it
+type rawRecorder struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (r *rawRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
 }
 
 // ParseUsers streams user records from the CSV file
-func (p *CSVParser) ParseUsers(callback func(row int, user *models.UserImport) error) error {
+func (p *CSVParser) ParseUsers(callback func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error) error {
 	for {
-		record, err := p.reader.Read()
+		record, raw, err := p.next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			// Skip malformed rows but continue processing
-			p.lineNumber++
+			return err
+		}
+		if record == nil {
+			// Pass nil user - the callback should handle parse errors
+			if err := callback(p.lineNumber, nil, raw, p.lastErr); err != nil {
+				return err
+			}
 			continue
 		}
 
-		p.lineNumber++
 		user := p.parseUserRecord(record)
 
-		if err := callback(p.lineNumber, user); err != nil {
+		if err := callback(p.lineNumber, user, raw, nil); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// parseUserRecord converts a CSV record to a UserImport struct
-func (p *CSVParser) parseUserRecord(record []string) *models.UserImport {
-	user := &models.UserImport{}
-
-	if idx, ok := p.headerMap["id"]; ok && idx < len(record) {
-		user.ID = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["email"]; ok && idx < len(record) {
-		user.Email = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["name"]; ok && idx < len(record) {
-		user.Name = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["role"]; ok && idx < len(record) {
-		user.Role = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["active"]; ok && idx < len(record) {
-		user.Active = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["created_at"]; ok && idx < len(record) {
-		user.CreatedAt = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["updated_at"]; ok && idx < len(record) {
-		user.UpdatedAt = strings.TrimSpace(record[idx])
+// Parse streams the records of resource from the CSV file
+func (p *CSVParser) Parse(resource models.ResourceType, fn RecordFunc) error {
+	switch resource {
+	case models.ResourceTypeUsers:
+		return p.ParseUsers(func(row int, user *models.UserImport, raw string, parseErr *ParseError) error {
+			return fn(row, asRecord(user), raw, rowError(parseErr))
+		})
+	case models.ResourceTypeArticles:
+		return p.ParseArticles(func(row int, article *models.ArticleImport, raw string, parseErr *ParseError) error {
+			return fn(row, asRecord(article), raw, rowError(parseErr))
+		})
+	case models.ResourceTypeComments:
+		return p.ParseComments(func(row int, comment *models.CommentImport, raw string, parseErr *ParseError) error {
+			return fn(row, asRecord(comment), raw, rowError(parseErr))
+		})
 	}
+	return unknownResource(resource)
+}
 
-	return user
+// parseUserRecord converts a CSV record to a UserImport struct
+func (p *CSVParser) parseUserRecord(record []string) *models.UserImport {
+	return mapUser(csvRecord{headerMap: p.headerMap, values: record})
 }
 
 // TotalLines returns an estimated total line count (read so far)
@@ -105,22 +187,26 @@ func (p *CSVParser) TotalLines() int {
 }
 
 // ParseArticles streams article records from the CSV file
-func (p *CSVParser) ParseArticles(callback func(row int, article *models.ArticleImport) error) error {
+func (p *CSVParser) ParseArticles(callback func(row int, article *models.ArticleImport, rawLine string, parseErr *ParseError) error) error {
 	for {
-		record, err := p.reader.Read()
+		record, raw, err := p.next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			// Skip malformed rows but continue processing
-			p.lineNumber++
+			return err
+		}
+		if record == nil {
+			// Pass nil article - the callback should handle parse errors
+			if err := callback(p.lineNumber, nil, raw, p.lastErr); err != nil {
+				return err
+			}
 			continue
 		}
 
-		p.lineNumber++
 		article := p.parseArticleRecord(record)
 
-		if err := callback(p.lineNumber, article); err != nil {
+		if err := callback(p.lineNumber, article, raw, nil); err != nil {
 			return err
 		}
 	}
@@ -129,60 +215,30 @@ func (p *CSVParser) ParseArticles(callback func(row int, article *models.Article
 
 // parseArticleRecord converts a CSV record to an ArticleImport struct
 func (p *CSVParser) parseArticleRecord(record []string) *models.ArticleImport {
-	article := &models.ArticleImport{}
-
-	if idx, ok := p.headerMap["id"]; ok && idx < len(record) {
-		article.ID = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["slug"]; ok && idx < len(record) {
-		article.Slug = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["title"]; ok && idx < len(record) {
-		article.Title = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["body"]; ok && idx < len(record) {
-		article.Body = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["author_id"]; ok && idx < len(record) {
-		article.AuthorID = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["tags"]; ok && idx < len(record) {
-		// Parse tags as comma-separated values
-		tagsStr := strings.TrimSpace(record[idx])
-		if tagsStr != "" {
-			article.Tags = strings.Split(tagsStr, ",")
-			for i := range article.Tags {
-				article.Tags[i] = strings.TrimSpace(article.Tags[i])
-			}
-		}
-	}
-	if idx, ok := p.headerMap["published_at"]; ok && idx < len(record) {
-		article.PublishedAt = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["status"]; ok && idx < len(record) {
-		article.Status = strings.TrimSpace(record[idx])
-	}
-
-	return article
+	return mapArticle(csvRecord{headerMap: p.headerMap, values: record})
 }
 
 // ParseComments streams comment records from the CSV file
-func (p *CSVParser) ParseComments(callback func(row int, comment *models.CommentImport) error) error {
+func (p *CSVParser) ParseComments(callback func(row int, comment *models.CommentImport, rawLine string, parseErr *ParseError) error) error {
 	for {
-		record, err := p.reader.Read()
+		record, raw, err := p.next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			// Skip malformed rows but continue processing
-			p.lineNumber++
+			return err
+		}
+		if record == nil {
+			// Pass nil comment - the callback should handle parse errors
+			if err := callback(p.lineNumber, nil, raw, p.lastErr); err != nil {
+				return err
+			}
 			continue
 		}
 
-		p.lineNumber++
 		comment := p.parseCommentRecord(record)
 
-		if err := callback(p.lineNumber, comment); err != nil {
+		if err := callback(p.lineNumber, comment, raw, nil); err != nil {
 			return err
 		}
 	}
@@ -191,23 +247,25 @@ func (p *CSVParser) ParseComments(callback func(row int, comment *models.Comment
 
 // parseCommentRecord converts a CSV record to a CommentImport struct
 func (p *CSVParser) parseCommentRecord(record []string) *models.CommentImport {
-	comment := &models.CommentImport{}
+	return mapComment(csvRecord{headerMap: p.headerMap, values: record})
+}
 
-	if idx, ok := p.headerMap["id"]; ok && idx < len(record) {
-		comment.ID = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["article_id"]; ok && idx < len(record) {
-		comment.ArticleID = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["user_id"]; ok && idx < len(record) {
-		comment.UserID = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["body"]; ok && idx < len(record) {
-		comment.Body = strings.TrimSpace(record[idx])
-	}
-	if idx, ok := p.headerMap["created_at"]; ok && idx < len(record) {
-		comment.CreatedAt = strings.TrimSpace(record[idx])
+// csvRecord looks up the fields of a CSV row by header. Values are trimmed
+// and list fields are comma-separated.
+type csvRecord struct {
+	headerMap map[string]int
+	values    []string
+}
+
+func (r csvRecord) field(name string) (string, bool) {
+	idx, ok := r.headerMap[name]
+	if !ok || idx >= len(r.values) {
+		return "", false
 	}
+	return strings.TrimSpace(r.values[idx]), true
+}
 
-	return comment
+func (r csvRecord) list(name string) ([]string, bool) {
+	value, ok := r.field(name)
+	return splitList(value), ok
 }
@@ -64,7 +64,7 @@ func TestCSVParser_ParseUsers(t *testing.T) {
 
 	var users []*models.UserImport
 
-	err = parser.ParseUsers(func(row int, user *models.UserImport) error {
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
 		users = append(users, user)
 		return nil
 	})
@@ -111,7 +111,7 @@ valid-id,valid@email.com,Valid User,admin,true,2024-01-01T00:00:00Z`
 	}
 
 	var users []*models.UserImport
-	err = parser.ParseUsers(func(row int, user *models.UserImport) error {
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
 		users = append(users, user)
 		return nil
 	})
@@ -143,7 +143,7 @@ func TestCSVParser_CaseInsensitiveHeaders(t *testing.T) {
 	}
 
 	var users []*models.UserImport
-	err = parser.ParseUsers(func(row int, user *models.UserImport) error {
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
 		users = append(users, user)
 		return nil
 	})
@@ -178,7 +178,7 @@ func TestCSVParser_TotalLines(t *testing.T) {
 		t.Errorf("TotalLines() after header = %d, want 1", parser.TotalLines())
 	}
 
-	err = parser.ParseUsers(func(row int, user *models.UserImport) error {
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
 		return nil
 	})
 
@@ -204,7 +204,7 @@ ab123456-1234-5678-90ab-cdef12345678,draft-article,Draft Article,Draft body,27c1
 	}
 
 	var articles []*models.ArticleImport
-	err = parser.ParseArticles(func(row int, article *models.ArticleImport) error {
+	err = parser.ParseArticles(func(row int, article *models.ArticleImport, rawLine string, parseErr *ParseError) error {
 		articles = append(articles, article)
 		return nil
 	})
@@ -249,7 +249,7 @@ cm_456,ab123456-1234-5678-90ab-cdef12345678,27c1d699-7f5c-5823-9feb-b40793961706
 	}
 
 	var comments []*models.CommentImport
-	err = parser.ParseComments(func(row int, comment *models.CommentImport) error {
+	err = parser.ParseComments(func(row int, comment *models.CommentImport, rawLine string, parseErr *ParseError) error {
 		comments = append(comments, comment)
 		return nil
 	})
@@ -278,3 +278,69 @@ cm_456,ab123456-1234-5678-90ab-cdef12345678,27c1d699-7f5c-5823-9feb-b40793961706
 		t.Errorf("Second comment user_id = %s, want 27c1d699-7f5c-5823-9feb-b40793961706", comments[1].UserID)
 	}
 }
+
+func TestCSVParser_ParseUsers_MalformedRows(t *testing.T) {
+	csvData := "id,email,name,role,active\n" +
+		"1,a@example.com,A,admin,true\n" +
+		"2,b@example.com,B\n" +
+		"3,c\"@example.com,C,admin,true\n" +
+		"4,d@example.com,D,reader,false\r\n"
+
+	parser, err := NewCSVParser(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("NewCSVParser() error: %v", err)
+	}
+
+	var emails []string
+	failures := make(map[int]string)
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
+		if user == nil {
+			if parseErr == nil || parseErr.Code != "FILE_PARSE_ERROR" {
+				t.Errorf("row %d: parse error = %v, want FILE_PARSE_ERROR", row, parseErr)
+			}
+			failures[row] = rawLine
+			return nil
+		}
+		emails = append(emails, user.Email)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("ParseUsers() error: %v", err)
+	}
+
+	// Every data row reaches the callback, valid or not
+	if len(emails)+len(failures) != 4 {
+		t.Errorf("callback saw %d rows, want 4", len(emails)+len(failures))
+	}
+	if failures[3] != "2,b@example.com,B" {
+		t.Errorf("wrong field count row raw = %q", failures[3])
+	}
+	if failures[4] != `3,c"@example.com,C,admin,true` {
+		t.Errorf("bare quote row raw = %q", failures[4])
+	}
+	if len(emails) != 2 || emails[1] != "d@example.com" {
+		t.Errorf("ParseUsers() emails = %v, want parsing to continue after malformed rows", emails)
+	}
+}
+
+func BenchmarkCSVParser_ParseUsers(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("id,email,name,role,active,created_at,updated_at\n")
+	for i := 0; i < 1000; i++ {
+		sb.WriteString("5864905b-ec8c-4fa6-8ba7-545d13f29b4e,user@example.com,Bench User,author,true,2024-01-01T00:00:00Z,2024-01-01T00:05:00Z\n")
+	}
+	data := sb.String()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		parser, err := NewCSVParser(strings.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
+			return nil
+		})
+	}
+}
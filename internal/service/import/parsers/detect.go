@@ -0,0 +1,145 @@
+package parsers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+const (
+	// detectSampleLines is the number of NDJSON lines whose keys are inspected
+	detectSampleLines = 20
+	// detectMinConfidence is the score the best match must reach
+	detectMinConfidence = 0.5
+	// detectMinMargin is how far the best match must lead the runner-up
+	detectMinMargin = 0.2
+)
+
+// resourceSignatures lists the fields that identify each resource. Fields
+// shared by several resources (id, created_at, ...) are deliberately left out.
+var resourceSignatures = map[models.ResourceType][]string{
+	models.ResourceTypeUsers:    {"email", "name", "role", "active"},
+	models.ResourceTypeArticles: {"slug", "title", "content", "author_id", "status", "published_at", "tags"},
+	models.ResourceTypeComments: {"article_id", "user_id", "body"},
+}
+
+// ResourceDetection is the result of inferring a resource from file fields
+type ResourceDetection struct {
+	Resource   models.ResourceType             `json:"resource,omitempty"`
+	Confidence float64                         `json:"confidence"`
+	Ambiguous  bool                            `json:"ambiguous"`
+	Scores     map[models.ResourceType]float64 `json:"scores"`
+	Fields     []string                        `json:"fields"`
+}
+
+// DetectResource infers the resource type from CSV headers or NDJSON keys.
+// Ambiguous is set when no resource matches clearly enough to import.
+func DetectResource(r io.Reader, format FileFormat) (*ResourceDetection, error) {
+	var fields []string
+	var err error
+	if format.IsCSV() {
+		fields, err = csvFields(r)
+	} else {
+		fields, err = ndjsonFields(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ScoreResourceFields(fields), nil
+}
+
+// ScoreResourceFields scores each resource by the share of its signature
+// fields present
+func ScoreResourceFields(fields []string) *ResourceDetection {
+	present := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		present[strings.ToLower(strings.TrimSpace(f))] = true
+	}
+
+	detection := &ResourceDetection{
+		Scores: make(map[models.ResourceType]float64, len(resourceSignatures)),
+		Fields: fields,
+	}
+
+	var best, second float64
+	for resource, signature := range resourceSignatures {
+		matched := 0
+		for _, f := range signature {
+			if present[f] {
+				matched++
+			}
+		}
+		score := float64(matched) / float64(len(signature))
+		detection.Scores[resource] = score
+
+		switch {
+		case score > best:
+			second = best
+			best = score
+			detection.Resource = resource
+		case score > second:
+			second = score
+		}
+	}
+
+	detection.Confidence = best
+	if best < detectMinConfidence || best-second < detectMinMargin {
+		detection.Ambiguous = true
+		detection.Resource = ""
+	}
+	return detection
+}
+
+func csvFields(r io.Reader) ([]string, error) {
+	decoded, _, err := NewDecoder(r, EncodingAuto)
+	if err != nil {
+		return nil, err
+	}
+	csvReader := csv.NewReader(decoded)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.TrimLeadingSpace = true
+
+	headers, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV headers: %w", err)
+	}
+	return headers, nil
+}
+
+// ndjsonFields returns the union of keys in the first few parseable lines
+func ndjsonFields(r io.Reader) ([]string, error) {
+	seen := make(map[string]bool)
+	sampled := 0
+
+	err := NewNDJSONParser(r).ParseGeneric(func(row int, data map[string]interface{}, rawJSON string, parseErr *ParseError) error {
+		if data == nil {
+			return nil
+		}
+		for k := range data {
+			seen[k] = true
+		}
+		sampled++
+		if sampled >= detectSampleLines {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if sampled == 0 {
+		return nil, fmt.Errorf("no JSON objects found to detect resource from")
+	}
+
+	fields := make([]string, 0, len(seen))
+	for k := range seen {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
@@ -0,0 +1,70 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestDetectResource(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		format        FileFormat
+		wantResource  models.ResourceType
+		wantAmbiguous bool
+	}{
+		{
+			name:         "users CSV",
+			data:         "id,email,name,role,active,created_at\n1,a@b.com,A,admin,true,\n",
+			format:       FormatCSV,
+			wantResource: models.ResourceTypeUsers,
+		},
+		{
+			name:         "articles NDJSON",
+			data:         `{"id":"1","slug":"a-b","title":"A","body":"x","author_id":"2","status":"draft"}` + "\n",
+			format:       FormatNDJSON,
+			wantResource: models.ResourceTypeArticles,
+		},
+		{
+			name:         "comments NDJSON",
+			data:         `{"id":"1","article_id":"2","user_id":"3","body":"hi"}` + "\n",
+			format:       FormatNDJSON,
+			wantResource: models.ResourceTypeComments,
+		},
+		{
+			name:          "unrelated headers",
+			data:          "sku,price,quantity\n",
+			format:        FormatCSV,
+			wantAmbiguous: true,
+		},
+		{
+			name:          "mixed fields",
+			data:          "email,name,role,active,article_id,user_id,body\n",
+			format:        FormatCSV,
+			wantAmbiguous: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection, err := DetectResource(strings.NewReader(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("DetectResource() unexpected error: %v", err)
+			}
+			if detection.Ambiguous != tt.wantAmbiguous {
+				t.Errorf("Ambiguous = %v, want %v (scores %v)", detection.Ambiguous, tt.wantAmbiguous, detection.Scores)
+			}
+			if detection.Resource != tt.wantResource {
+				t.Errorf("Resource = %q, want %q", detection.Resource, tt.wantResource)
+			}
+		})
+	}
+}
+
+func TestDetectResource_NoObjects(t *testing.T) {
+	if _, err := DetectResource(strings.NewReader("not json\n"), FormatNDJSON); err == nil {
+		t.Error("DetectResource() expected error for file without JSON objects")
+	}
+}
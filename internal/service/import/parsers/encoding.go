@@ -0,0 +1,226 @@
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of an import file
+type Encoding string
+
+const (
+	// EncodingAuto detects the encoding from a byte order mark, falling back
+	// to UTF-16 or Latin-1 when the file is not valid UTF-8
+	EncodingAuto    Encoding = "auto"
+	EncodingUTF8    Encoding = "utf-8"
+	EncodingUTF16LE Encoding = "utf-16le"
+	EncodingUTF16BE Encoding = "utf-16be"
+	EncodingLatin1  Encoding = "latin1"
+)
+
+// detectSampleSize is how much of the file auto detection looks at
+const detectSampleSize = 4096
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// ParseEncoding returns the Encoding for a name such as "UTF-8", "utf16le" or
+// "ISO-8859-1". An empty name means EncodingAuto.
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", "-")) {
+	case "", "auto":
+		return EncodingAuto, nil
+	case "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "utf-16le", "utf16le", "utf-16":
+		return EncodingUTF16LE, nil
+	case "utf-16be", "utf16be":
+		return EncodingUTF16BE, nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		return EncodingLatin1, nil
+	default:
+		return "", fmt.Errorf("unsupported encoding: %q", name)
+	}
+}
+
+// NewDecoder returns a reader that yields r as UTF-8 with any byte order mark
+// removed, along with the encoding that was used
+func NewDecoder(r io.Reader, enc Encoding) (io.Reader, Encoding, error) {
+	br := bufio.NewReaderSize(r, 64*1024) // 64KB buffer
+
+	if enc == EncodingAuto {
+		sample, err := br.Peek(detectSampleSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, "", fmt.Errorf("failed to read file for encoding detection: %w", err)
+		}
+		enc = detectEncoding(sample, err == io.EOF)
+	}
+
+	switch enc {
+	case EncodingUTF8:
+		skipBOM(br, bomUTF8)
+		return br, enc, nil
+	case EncodingUTF16LE:
+		skipBOM(br, bomUTF16LE)
+		return &utf16Reader{r: br, littleEndian: true}, enc, nil
+	case EncodingUTF16BE:
+		skipBOM(br, bomUTF16BE)
+		return &utf16Reader{r: br}, enc, nil
+	case EncodingLatin1:
+		return &latin1Reader{r: br}, enc, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported encoding: %q", enc)
+	}
+}
+
+// detectEncoding guesses the encoding of sample, the start of a file. When
+// complete is false the sample may end part way through a character.
+func detectEncoding(sample []byte, complete bool) Encoding {
+	switch {
+	case hasPrefix(sample, bomUTF8):
+		return EncodingUTF8
+	case hasPrefix(sample, bomUTF16LE):
+		return EncodingUTF16LE
+	case hasPrefix(sample, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+
+	// UTF-16 text in CSV/JSON is mostly ASCII, so every other byte is zero
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	pairs := len(sample) / 2
+	if pairs > 0 && oddZeros > pairs/2 && evenZeros == 0 {
+		return EncodingUTF16LE
+	}
+	if pairs > 0 && evenZeros > pairs/2 && oddZeros == 0 {
+		return EncodingUTF16BE
+	}
+
+	if !complete {
+		// Don't hold a character cut off by the sample boundary against the file
+		end := len(sample)
+		for i := 0; i < utf8.UTFMax && end > 0 && !utf8.RuneStart(sample[end-1]); i++ {
+			end--
+		}
+		if end > 0 {
+			end--
+		}
+		sample = sample[:end]
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+func hasPrefix(b, prefix []byte) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == string(prefix)
+}
+
+func skipBOM(br *bufio.Reader, bom []byte) {
+	if prefix, err := br.Peek(len(bom)); err == nil && hasPrefix(prefix, bom) {
+		br.Discard(len(bom))
+	}
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8
+type utf16Reader struct {
+	r            *bufio.Reader
+	littleEndian bool
+	pending      []byte
+	err          error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.pending) < len(p) && u.err == nil {
+		r, err := u.readRune()
+		if err != nil {
+			u.err = err
+			break
+		}
+		u.pending = utf8.AppendRune(u.pending, r)
+	}
+
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+	if n == 0 && u.err != nil {
+		return 0, u.err
+	}
+	return n, nil
+}
+
+func (u *utf16Reader) readRune() (rune, error) {
+	unit, err := u.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(unit)) {
+		return rune(unit), nil
+	}
+
+	low, err := u.readUnit()
+	if err == io.EOF {
+		return utf8.RuneError, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return utf16.DecodeRune(rune(unit), rune(low)), nil
+}
+
+func (u *utf16Reader) readUnit() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			// A dangling odd byte can't form a character
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if u.littleEndian {
+		return uint16(b[0]) | uint16(b[1])<<8, nil
+	}
+	return uint16(b[0])<<8 | uint16(b[1]), nil
+}
+
+// latin1Reader transcodes ISO-8859-1 to UTF-8. Every byte maps to the code
+// point of the same value.
+type latin1Reader struct {
+	r       *bufio.Reader
+	pending []byte
+	err     error
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	for len(l.pending) < len(p) && l.err == nil {
+		b, err := l.r.ReadByte()
+		if err != nil {
+			l.err = err
+			break
+		}
+		l.pending = utf8.AppendRune(l.pending, rune(b))
+	}
+
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+	if n == 0 && l.err != nil {
+		return 0, l.err
+	}
+	return n, nil
+}
@@ -0,0 +1,130 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func encodeUTF16LE(s string, bom bool) []byte {
+	var b []byte
+	if bom {
+		b = append(b, 0xFF, 0xFE)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+func encodeLatin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func TestCSVParser_Encodings(t *testing.T) {
+	csvData := "id,email,name\n1,jose@example.com,José Müller\n"
+
+	tests := []struct {
+		name  string
+		input []byte
+		enc   Encoding
+	}{
+		{"utf-8", []byte(csvData), EncodingAuto},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, csvData...), EncodingAuto},
+		{"utf-16le bom", encodeUTF16LE(csvData, true), EncodingAuto},
+		{"utf-16le no bom", encodeUTF16LE(csvData, false), EncodingAuto},
+		{"latin1", encodeLatin1(csvData), EncodingAuto},
+		{"latin1 explicit", encodeLatin1(csvData), EncodingLatin1},
+		{"utf-8 bom explicit", append([]byte{0xEF, 0xBB, 0xBF}, csvData...), EncodingUTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewCSVParserWithEncoding(strings.NewReader(string(tt.input)), tt.enc)
+			if err != nil {
+				t.Fatalf("NewCSVParserWithEncoding() error: %v", err)
+			}
+			if parser.headers[0] != "id" {
+				t.Errorf("first header = %q, want id", parser.headers[0])
+			}
+
+			var users []*models.UserImport
+			err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error {
+				if user == nil {
+					t.Fatalf("row %d: unexpected parse error %v", row, parseErr)
+				}
+				users = append(users, user)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseUsers() error: %v", err)
+			}
+			if len(users) != 1 {
+				t.Fatalf("ParseUsers() got %d users, want 1", len(users))
+			}
+			if users[0].ID != "1" || users[0].Name != "José Müller" {
+				t.Errorf("user = {ID: %q, Name: %q}, want {1, José Müller}", users[0].ID, users[0].Name)
+			}
+		})
+	}
+}
+
+func TestNDJSONParser_Encodings(t *testing.T) {
+	ndjson := `{"email":"jose@example.com","name":"José"}` + "\r\n"
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, ndjson...)},
+		{"utf-16le bom", encodeUTF16LE(ndjson, true)},
+		{"latin1", encodeLatin1(ndjson)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewNDJSONParser(strings.NewReader(string(tt.input)))
+
+			var names []string
+			err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error {
+				if user == nil {
+					t.Fatalf("row %d: unexpected parse error %v", row, parseErr)
+				}
+				names = append(names, user.Name)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseUsers() error: %v", err)
+			}
+			if len(names) != 1 || names[0] != "José" {
+				t.Errorf("names = %v, want [José]", names)
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := map[string]Encoding{
+		"":           EncodingAuto,
+		"UTF-8":      EncodingUTF8,
+		"utf16le":    EncodingUTF16LE,
+		"UTF_16BE":   EncodingUTF16BE,
+		"ISO-8859-1": EncodingLatin1,
+	}
+	for name, want := range tests {
+		got, err := ParseEncoding(name)
+		if err != nil || got != want {
+			t.Errorf("ParseEncoding(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseEncoding("ebcdic"); err == nil {
+		t.Error("ParseEncoding(\"ebcdic\") expected error")
+	}
+}
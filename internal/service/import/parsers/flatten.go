@@ -0,0 +1,144 @@
+package parsers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// importFields are the fields of each import struct, as CSV headers and
+// NDJSON keys name them
+var importFields = map[models.ResourceType][]string{
+	models.ResourceTypeUsers:    {"id", "email", "name", "role", "active", "created_at", "updated_at"},
+	models.ResourceTypeArticles: {"id", "slug", "title", "body", "author_id", "tags", "published_at", "status"},
+	models.ResourceTypeComments: {"id", "article_id", "user_id", "body", "created_at"},
+}
+
+// pathStep is one step of a JSON path: an object key, or an array index
+// when key is empty
+type pathStep struct {
+	key   string
+	index int
+}
+
+// flattenRule reads one import field from a JSON path
+type flattenRule struct {
+	field string
+	path  string
+	steps []pathStep
+}
+
+// Flattener maps nested NDJSON documents to the flat import structs. Each
+// rule reads an import field from a JSON path such as user.email or
+// $.emails[0].address; fields without a rule are read from the top-level
+// key of the same name, as without a flattener.
+type Flattener struct {
+	rules []flattenRule
+}
+
+// NewFlattener compiles paths, keyed by import field, for imports of
+// resource. Unknown fields and malformed paths are errors.
+func NewFlattener(paths map[string]string, resource models.ResourceType) (*Flattener, error) {
+	fields, ok := importFields[resource]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource %q", resource)
+	}
+
+	f := &Flattener{}
+	for field, path := range paths {
+		known := false
+		for _, name := range fields {
+			known = known || name == field
+		}
+		if !known {
+			return nil, fmt.Errorf("%s imports have no field %q", resource, field)
+		}
+		steps, err := parsePath(path)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		f.rules = append(f.rules, flattenRule{field: field, path: path, steps: steps})
+	}
+	// Map order is random; report unresolved paths in a stable order
+	sort.Slice(f.rules, func(i, j int) bool { return f.rules[i].field < f.rules[j].field })
+	return f, nil
+}
+
+// parsePath splits a dotted JSON path into steps. A leading $ is optional
+// and keys may be followed by array indexes, as in tags[0].
+func parsePath(path string) ([]pathStep, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if rest == "" {
+		return nil, fmt.Errorf("path %q is empty", path)
+	}
+
+	var steps []pathStep
+	for _, segment := range strings.Split(rest, ".") {
+		key, indexes, _ := strings.Cut(segment, "[")
+		if key == "" && indexes == "" {
+			return nil, fmt.Errorf("path %q has an empty key", path)
+		}
+		if key != "" {
+			steps = append(steps, pathStep{key: key})
+		}
+		if indexes == "" {
+			continue
+		}
+		for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 || !strings.HasSuffix(indexes, "]") {
+				return nil, fmt.Errorf("path %q has a bad array index in %q", path, segment)
+			}
+			steps = append(steps, pathStep{index: n})
+		}
+	}
+	return steps, nil
+}
+
+// apply flattens a decoded document into a record. A path that doesn't
+// lead to a value is an error; one that leads to null leaves the field
+// empty.
+func (f *Flattener) apply(doc interface{}) (columnRecord, error) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("line is not a JSON object")
+	}
+
+	rec := make(columnRecord, len(obj)+len(f.rules))
+	for key, value := range obj {
+		rec[strings.ToLower(key)] = columnValue(value)
+	}
+	for _, rule := range f.rules {
+		value, ok := resolve(obj, rule.steps)
+		if !ok {
+			return nil, fmt.Errorf("path %s for field %s not found", rule.path, rule.field)
+		}
+		rec[rule.field] = columnValue(value)
+	}
+	return rec, nil
+}
+
+// resolve follows steps from v and reports whether they all matched
+func resolve(v interface{}, steps []pathStep) (interface{}, bool) {
+	for _, step := range steps {
+		if step.key != "" {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[step.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		items, ok := v.([]interface{})
+		if !ok || step.index >= len(items) {
+			return nil, false
+		}
+		v = items[step.index]
+	}
+	return v, true
+}
@@ -0,0 +1,116 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestNDJSONParser_Flattener(t *testing.T) {
+	ndjson := `{"id":"u-1","user":{"email":"ann@example.com","profile":{"name":"Ann"}},"meta":{"roles":["admin","author"],"active":true,"joined":1700000000}}
+{"id":"u-2","user":{"profile":{"name":"Bob"}},"meta":{"roles":["reader"],"active":false}}
+{"id":"u-3","user":{"email":null,"profile":{"name":"Cy"}},"meta":{"roles":[],"active":true}}
+["not","an","object"]`
+
+	f, err := NewFlattener(map[string]string{
+		"email":      "user.email",
+		"name":       "$.user.profile.name",
+		"role":       "meta.roles[0]",
+		"active":     "meta.active",
+		"created_at": "meta.joined",
+	}, models.ResourceTypeUsers)
+	if err != nil {
+		t.Fatalf("NewFlattener() error: %v", err)
+	}
+	parser := NewNDJSONParser(strings.NewReader(ndjson))
+	parser.SetFlattener(f)
+
+	var users []*models.UserImport
+	var codes []string
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error {
+		users = append(users, user)
+		code := ""
+		if parseErr != nil {
+			code = parseErr.Code
+		}
+		codes = append(codes, code)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseUsers() error: %v", err)
+	}
+	if len(users) != 4 {
+		t.Fatalf("ParseUsers() got %d rows, want 4", len(users))
+	}
+
+	want := models.UserImport{ID: "u-1", Email: "ann@example.com", Name: "Ann", Role: "admin", Active: "true", CreatedAt: "1700000000"}
+	if users[0] == nil || *users[0] != want {
+		t.Errorf("first user = %+v, want %+v", users[0], want)
+	}
+	// No email key, and an empty roles array, don't resolve
+	if users[1] != nil || codes[1] != errors.ErrCodeUnresolvedPath {
+		t.Errorf("second row = %+v with code %q, want %s", users[1], codes[1], errors.ErrCodeUnresolvedPath)
+	}
+	if users[2] != nil || codes[2] != errors.ErrCodeUnresolvedPath {
+		t.Errorf("third row = %+v with code %q, want %s", users[2], codes[2], errors.ErrCodeUnresolvedPath)
+	}
+	if users[3] != nil || codes[3] != errors.ErrCodeFileParseError {
+		t.Errorf("fourth row = %+v with code %q, want %s", users[3], codes[3], errors.ErrCodeFileParseError)
+	}
+}
+
+func TestNDJSONParser_FlattenerNullAndLists(t *testing.T) {
+	ndjson := `{"post":{"id":"a-1","slug":"hello","labels":["go","json"],"published":null},"title":"Hello"}`
+
+	f, err := NewFlattener(map[string]string{
+		"id":           "post.id",
+		"slug":         "post.slug",
+		"tags":         "post.labels",
+		"published_at": "post.published",
+	}, models.ResourceTypeArticles)
+	if err != nil {
+		t.Fatalf("NewFlattener() error: %v", err)
+	}
+	parser := NewNDJSONParser(strings.NewReader(ndjson))
+	parser.SetFlattener(f)
+
+	var article *models.ArticleImport
+	var rowErr *ParseError
+	if err := parser.ParseArticles(func(row int, a *models.ArticleImport, rawJSON string, parseErr *ParseError) error {
+		article, rowErr = a, parseErr
+		return nil
+	}); err != nil {
+		t.Fatalf("ParseArticles() error: %v", err)
+	}
+	if article == nil {
+		t.Fatalf("ParseArticles() rejected the row: %v", rowErr)
+	}
+	// Unmapped fields are still read from the top level, and null is empty
+	if article.ID != "a-1" || article.Slug != "hello" || article.Title != "Hello" || article.PublishedAt != "" {
+		t.Errorf("article = %+v", article)
+	}
+	if strings.Join(article.Tags, ",") != "go,json" {
+		t.Errorf("tags = %v, want [go json]", article.Tags)
+	}
+}
+
+func TestNewFlattener_RejectsBadRules(t *testing.T) {
+	for _, paths := range []map[string]string{
+		{"password": "user.password"},
+		{"email": ""},
+		{"email": "$"},
+		{"email": "user..email"},
+		{"email": "emails[x]"},
+		{"email": "emails[0"},
+		{"email": "emails[-1]"},
+	} {
+		if _, err := NewFlattener(paths, models.ResourceTypeUsers); err == nil {
+			t.Errorf("NewFlattener(%v) succeeded, want an error", paths)
+		}
+	}
+	if _, err := NewFlattener(map[string]string{"article_id": "a.id"}, models.ResourceTypeComments); err != nil {
+		t.Errorf("NewFlattener() error: %v", err)
+	}
+}
@@ -12,22 +12,26 @@ const (
 	FormatCSV    FileFormat = "csv"
 	FormatNDJSON FileFormat = "ndjson"
 	FormatJSON   FileFormat = "json"
+	// Avro object container files and Parquet files are read by record
+	// rather than by line
+	FormatAvro    FileFormat = "avro"
+	FormatParquet FileFormat = "parquet"
+	// XLSX workbooks are read from their first sheet
+	FormatXLSX FileFormat = "xlsx"
 )
 
-// DetectFormat determines the file format from the filename extension
+// DetectFormat determines the file format from the filename extension, as
+// registered with Register. A .gz or .gzip extension is skipped, so
+// users.csv.gz is CSV.
 func DetectFormat(filename string) FileFormat {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".csv":
-		return FormatCSV
-	case ".ndjson", ".jsonl":
-		return FormatNDJSON
-	case ".json":
-		return FormatJSON
-	default:
+	registryMu.RLock()
+	format, ok := extensions[strings.ToLower(filepath.Ext(TrimCompressedExt(filename)))]
+	registryMu.RUnlock()
+	if !ok {
 		// Default to CSV for backwards compatibility
 		return FormatCSV
 	}
+	return format
 }
 
 // IsCSV returns true if the format is CSV
@@ -39,3 +43,13 @@ func (f FileFormat) IsCSV() bool {
 func (f FileFormat) IsNDJSON() bool {
 	return f == FormatNDJSON || f == FormatJSON
 }
+
+// IsXLSX returns true if the format is an Excel workbook
+func (f FileFormat) IsXLSX() bool {
+	return f == FormatXLSX
+}
+
+// IsColumnar returns true if the format is a binary Avro or Parquet file
+func (f FileFormat) IsColumnar() bool {
+	return f == FormatAvro || f == FormatParquet
+}
@@ -16,6 +16,13 @@ func TestDetectFormat(t *testing.T) {
 		{"articles.NDJSON", FormatNDJSON},
 		{"comments.jsonl", FormatNDJSON},
 		{"data.json", FormatJSON},
+		{"users.avro", FormatAvro},
+		{"part-0.PARQUET", FormatParquet},
+		{"Users.XLSX", FormatXLSX},
+		{"users.csv.gz", FormatCSV},
+		{"articles.NDJSON.GZ", FormatNDJSON},
+		{"comments.jsonl.gzip", FormatNDJSON},
+		{"users.gz", FormatCSV},    // no inner extension defaults to CSV
 		{"noextension", FormatCSV}, // defaults to CSV
 		{"", FormatCSV},            // defaults to CSV
 		{"file.txt", FormatCSV},    // unknown defaults to CSV
@@ -0,0 +1,68 @@
+package parsers
+
+import (
+	"strings"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// fieldRecord is a parsed row whose values are looked up by lower-case field
+// name. CSV rows and Avro and Parquet records are all mapped to the import
+// structs through it.
+type fieldRecord interface {
+	// field returns the named value and whether the row has it
+	field(name string) (string, bool)
+	// list returns the named value as a list of strings
+	list(name string) ([]string, bool)
+}
+
+// splitList splits a comma-separated value, trimming each item
+func splitList(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// mapUser converts a row to a UserImport
+func mapUser(rec fieldRecord) *models.UserImport {
+	user := &models.UserImport{}
+	user.ID, _ = rec.field("id")
+	user.Email, _ = rec.field("email")
+	user.Name, _ = rec.field("name")
+	user.Role, _ = rec.field("role")
+	user.Active, _ = rec.field("active")
+	user.CreatedAt, _ = rec.field("created_at")
+	user.UpdatedAt, _ = rec.field("updated_at")
+	return user
+}
+
+// mapArticle converts a row to an ArticleImport
+func mapArticle(rec fieldRecord) *models.ArticleImport {
+	article := &models.ArticleImport{}
+	article.ID, _ = rec.field("id")
+	article.Slug, _ = rec.field("slug")
+	article.Title, _ = rec.field("title")
+	article.Body, _ = rec.field("body")
+	article.AuthorID, _ = rec.field("author_id")
+	article.Tags, _ = rec.list("tags")
+	article.PublishedAt, _ = rec.field("published_at")
+	article.Status, _ = rec.field("status")
+	return article
+}
+
+// mapComment converts a row to a CommentImport
+func mapComment(rec fieldRecord) *models.CommentImport {
+	comment := &models.CommentImport{}
+	comment.ID, _ = rec.field("id")
+	comment.ArticleID, _ = rec.field("article_id")
+	comment.UserID, _ = rec.field("user_id")
+	comment.Body, _ = rec.field("body")
+	comment.CreatedAt, _ = rec.field("created_at")
+	return comment
+}
@@ -3,139 +3,303 @@ package parsers
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"io"
+	"os"
+	"strings"
 
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
+const (
+	// DefaultMaxLineSize is the longest NDJSON line parsed; longer lines are
+	// skipped and reported as LINE_TOO_LONG
+	DefaultMaxLineSize = 10 * 1024 * 1024
+	// rawPreviewSize bounds the raw text passed on for an oversized line
+	rawPreviewSize = 1024
+)
+
+// ParseError describes a row the parser could not turn into a record
+type ParseError struct {
+	Code string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return e.Code + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func init() {
+	open := func(file *os.File, opts ParserOptions) (RecordParser, error) {
+		r, _, err := Decompress(file)
+		if err != nil {
+			return nil, err
+		}
+		p := NewNDJSONParserWithEncoding(r, opts.Encoding, opts.MaxLineSize)
+		p.SetFlattener(opts.Flattener)
+		return p, nil
+	}
+	Register(FormatNDJSON, open, ".ndjson", ".jsonl")
+	// .json files are read a document per line too
+	Register(FormatJSON, open, ".json")
+}
+
 // NDJSONParser parses NDJSON (newline-delimited JSON) files
 type NDJSONParser struct {
-	scanner    *bufio.Scanner
-	lineNumber int
+	reader      *bufio.Reader
+	maxLineSize int
+	lineNumber  int
+	// lastErr is why the current row has no record, nil when it parsed
+	lastErr   *ParseError
+	err       error
+	flattener *Flattener
 }
 
 // NewNDJSONParser creates a new NDJSON parser from a reader
 func NewNDJSONParser(r io.Reader) *NDJSONParser {
-	scanner := bufio.NewScanner(r)
-	// Increase buffer size for large JSON objects
-	const maxBufferSize = 10 * 1024 * 1024 // 10MB per line max
-	buf := make([]byte, 64*1024)           // 64KB initial
-	scanner.Buffer(buf, maxBufferSize)
+	return NewNDJSONParserSize(r, DefaultMaxLineSize)
+}
+
+// NewNDJSONParserSize creates an NDJSON parser that skips lines longer than
+// maxLineSize bytes instead of aborting the file
+func NewNDJSONParserSize(r io.Reader, maxLineSize int) *NDJSONParser {
+	return NewNDJSONParserWithEncoding(r, EncodingAuto, maxLineSize)
+}
 
-	return &NDJSONParser{
-		scanner:    scanner,
-		lineNumber: 0,
+// NewNDJSONParserWithEncoding creates an NDJSON parser that transcodes the
+// input from enc to UTF-8 and strips any byte order mark. A decoding failure
+// is returned by the first Parse call. A maxLineSize of 0 means
+// DefaultMaxLineSize.
+func NewNDJSONParserWithEncoding(r io.Reader, enc Encoding, maxLineSize int) *NDJSONParser {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
 	}
+	p := &NDJSONParser{maxLineSize: maxLineSize}
+	decoded, _, err := NewDecoder(r, enc)
+	if err != nil {
+		p.err = err
+		decoded = r
+	}
+	p.reader = bufio.NewReaderSize(decoded, 64*1024) // 64KB buffer
+	return p
 }
 
-// ParseArticles streams article records from the NDJSON file
-func (p *NDJSONParser) ParseArticles(callback func(row int, article *models.ArticleImport, rawJSON string) error) error {
-	for p.scanner.Scan() {
-		p.lineNumber++
-		line := p.scanner.Text()
+// SetFlattener makes the parser read nested documents through f's field
+// paths instead of decoding lines straight into the import structs
+func (p *NDJSONParser) SetFlattener(f *Flattener) {
+	p.flattener = f
+}
 
-		if line == "" {
-			continue // Skip empty lines
-		}
+// Parse streams the records of resource from the NDJSON file
+func (p *NDJSONParser) Parse(resource models.ResourceType, fn RecordFunc) error {
+	switch resource {
+	case models.ResourceTypeUsers:
+		return p.ParseUsers(func(row int, user *models.UserImport, raw string, parseErr *ParseError) error {
+			return fn(row, asRecord(user), raw, rowError(parseErr))
+		})
+	case models.ResourceTypeArticles:
+		return p.ParseArticles(func(row int, article *models.ArticleImport, raw string, parseErr *ParseError) error {
+			return fn(row, asRecord(article), raw, rowError(parseErr))
+		})
+	case models.ResourceTypeComments:
+		return p.ParseComments(func(row int, comment *models.CommentImport, raw string, parseErr *ParseError) error {
+			return fn(row, asRecord(comment), raw, rowError(parseErr))
+		})
+	}
+	return unknownResource(resource)
+}
 
-		var article models.ArticleImport
-		if err := json.Unmarshal([]byte(line), &article); err != nil {
-			// Pass nil article with error - the callback should handle parse errors
-			if err := callback(p.lineNumber, nil, line); err != nil {
-				return err
+// ParseArticles streams article records from the NDJSON file
+func (p *NDJSONParser) ParseArticles(callback func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(line string) error {
+		if p.flattener != nil {
+			if rec, ok := p.flatten(line); ok {
+				return callback(p.lineNumber, mapArticle(rec), line, p.lastErr)
 			}
-			continue
+			return callback(p.lineNumber, nil, line, p.lastErr)
 		}
-
-		if err := callback(p.lineNumber, &article, line); err != nil {
-			return err
+		var article models.ArticleImport
+		if p.decode(line, &article) {
+			return callback(p.lineNumber, &article, line, p.lastErr)
 		}
-	}
-
-	return p.scanner.Err()
+		// Pass nil article - the callback should handle parse errors
+		return callback(p.lineNumber, nil, line, p.lastErr)
+	})
 }
 
 // ParseUsers streams user records from the NDJSON file
-func (p *NDJSONParser) ParseUsers(callback func(row int, user *models.UserImport, rawJSON string) error) error {
-	for p.scanner.Scan() {
-		p.lineNumber++
-		line := p.scanner.Text()
-
-		if line == "" {
-			continue // Skip empty lines
+func (p *NDJSONParser) ParseUsers(callback func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(line string) error {
+		if p.flattener != nil {
+			if rec, ok := p.flatten(line); ok {
+				return callback(p.lineNumber, mapUser(rec), line, p.lastErr)
+			}
+			return callback(p.lineNumber, nil, line, p.lastErr)
 		}
-
 		var user models.UserImport
-		if err := json.Unmarshal([]byte(line), &user); err != nil {
-			// Pass nil user with error - the callback should handle parse errors
-			if err := callback(p.lineNumber, nil, line); err != nil {
-				return err
+		if p.decode(line, &user) {
+			return callback(p.lineNumber, &user, line, p.lastErr)
+		}
+		// Pass nil user - the callback should handle parse errors
+		return callback(p.lineNumber, nil, line, p.lastErr)
+	})
+}
+
+// ParseComments streams comment records from the NDJSON file
+func (p *NDJSONParser) ParseComments(callback func(row int, comment *models.CommentImport, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(line string) error {
+		if p.flattener != nil {
+			if rec, ok := p.flatten(line); ok {
+				return callback(p.lineNumber, mapComment(rec), line, p.lastErr)
 			}
-			continue
+			return callback(p.lineNumber, nil, line, p.lastErr)
+		}
+		var comment models.CommentImport
+		if p.decode(line, &comment) {
+			return callback(p.lineNumber, &comment, line, p.lastErr)
 		}
+		// Pass nil comment - the callback should handle parse errors
+		return callback(p.lineNumber, nil, line, p.lastErr)
+	})
+}
 
-		if err := callback(p.lineNumber, &user, line); err != nil {
-			return err
+// TotalLines returns the total lines read so far
+func (p *NDJSONParser) TotalLines() int {
+	return p.lineNumber
+}
+
+// ParseGeneric parses NDJSON into a generic map (for mixed content)
+func (p *NDJSONParser) ParseGeneric(callback func(row int, data map[string]interface{}, rawJSON string, parseErr *ParseError) error) error {
+	return p.scan(func(line string) error {
+		var data map[string]interface{}
+		if p.decode(line, &data) {
+			return callback(p.lineNumber, data, line, p.lastErr)
 		}
-	}
+		return callback(p.lineNumber, nil, line, p.lastErr)
+	})
+}
 
-	return p.scanner.Err()
+// decode unmarshals line into v unless the line was already rejected,
+// recording the failure in lastErr
+func (p *NDJSONParser) decode(line string, v interface{}) bool {
+	if p.lastErr != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(line), v); err != nil {
+		p.lastErr = &ParseError{Code: errors.ErrCodeFileParseError, Err: err}
+		return false
+	}
+	return true
 }
 
-// ParseComments streams comment records from the NDJSON file
-func (p *NDJSONParser) ParseComments(callback func(row int, comment *models.CommentImport, rawJSON string) error) error {
-	for p.scanner.Scan() {
-		p.lineNumber++
-		line := p.scanner.Text()
+// flatten decodes line and resolves the flattener's paths in it unless the
+// line was already rejected, recording the failure in lastErr
+func (p *NDJSONParser) flatten(line string) (columnRecord, bool) {
+	if p.lastErr != nil {
+		return nil, false
+	}
+	// Numbers keep their text rather than going through float64
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		p.lastErr = &ParseError{Code: errors.ErrCodeFileParseError, Err: err}
+		return nil, false
+	}
+	rec, err := p.flattener.apply(doc)
+	if err != nil {
+		code := errors.ErrCodeUnresolvedPath
+		if _, ok := doc.(map[string]interface{}); !ok {
+			code = errors.ErrCodeFileParseError
+		}
+		p.lastErr = &ParseError{Code: code, Err: err}
+		return nil, false
+	}
+	return rec, true
+}
 
-		if line == "" {
-			continue // Skip empty lines
+// scan calls fn for each non-empty line. Oversized lines are consumed and
+// passed on truncated with lastErr set, so one bad line can't end the file.
+func (p *NDJSONParser) scan(fn func(line string) error) error {
+	if p.err != nil {
+		return p.err
+	}
+	for {
+		line, tooLong, err := p.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 
-		var comment models.CommentImport
-		if err := json.Unmarshal([]byte(line), &comment); err != nil {
-			// Pass nil comment with error - the callback should handle parse errors
-			if err := callback(p.lineNumber, nil, line); err != nil {
-				return err
+		p.lineNumber++
+		p.lastErr = nil
+
+		if tooLong {
+			p.lastErr = &ParseError{
+				Code: errors.ErrCodeLineTooLong,
+				Err:  fmt.Errorf("line exceeds maximum length of %d bytes", p.maxLineSize),
 			}
-			continue
+		} else if line == "" {
+			continue // Skip empty lines
 		}
 
-		if err := callback(p.lineNumber, &comment, line); err != nil {
+		if err := fn(line); err != nil {
 			return err
 		}
 	}
-
-	return p.scanner.Err()
 }
 
-// TotalLines returns the total lines read so far
-func (p *NDJSONParser) TotalLines() int {
-	return p.lineNumber
-}
+// readLine returns the next line without its terminator. A line longer than
+// maxLineSize is read to its end but only the first rawPreviewSize bytes are
+// kept.
+func (p *NDJSONParser) readLine() (string, bool, error) {
+	// Room for a trailing "\r\n" on a line of exactly maxLineSize bytes
+	limit := p.maxLineSize + 2
 
-// ParseGeneric parses NDJSON into a generic map (for mixed content)
-func (p *NDJSONParser) ParseGeneric(callback func(row int, data map[string]interface{}, rawJSON string) error) error {
-	for p.scanner.Scan() {
-		p.lineNumber++
-		line := p.scanner.Text()
+	var buf []byte
+	size := 0
+	for {
+		chunk, err := p.reader.ReadSlice('\n')
+		size += len(chunk)
+		if size <= limit {
+			buf = append(buf, chunk...)
+		}
 
-		if line == "" {
+		if err == bufio.ErrBufferFull {
 			continue
 		}
-
-		var data map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &data); err != nil {
-			if err := callback(p.lineNumber, nil, line); err != nil {
-				return err
+		if err == io.EOF {
+			if size == 0 {
+				return "", false, io.EOF
 			}
-			continue
+			break
 		}
-
-		if err := callback(p.lineNumber, data, line); err != nil {
-			return err
+		if err != nil {
+			return "", false, err
 		}
+		break
 	}
 
-	return p.scanner.Err()
+	if size > limit {
+		return string(buf[:min(len(buf), rawPreviewSize)]), true, nil
+	}
+
+	// Drop the newline and an optional carriage return, like bufio.ScanLines
+	if n := len(buf); n > 0 && buf[n-1] == '\n' {
+		buf = buf[:n-1]
+	}
+	if n := len(buf); n > 0 && buf[n-1] == '\r' {
+		buf = buf[:n-1]
+	}
+	if len(buf) > p.maxLineSize {
+		return string(buf[:rawPreviewSize]), true, nil
+	}
+	return string(buf), false, nil
 }
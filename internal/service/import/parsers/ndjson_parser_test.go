@@ -15,7 +15,7 @@ func TestNDJSONParser_ParseArticles(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var articles []*models.ArticleImport
-	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string) error {
+	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error {
 		if article != nil {
 			articles = append(articles, article)
 		}
@@ -52,7 +52,7 @@ func TestNDJSONParser_ParseArticles_InvalidSlug(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var articles []*models.ArticleImport
-	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string) error {
+	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error {
 		if article != nil {
 			articles = append(articles, article)
 		}
@@ -86,7 +86,7 @@ not valid json at all
 	var articles []*models.ArticleImport
 	var parseErrors int
 
-	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string) error {
+	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error {
 		if article == nil {
 			parseErrors++
 		} else {
@@ -122,7 +122,7 @@ func TestNDJSONParser_ParseArticles_EmptyLines(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var articles []*models.ArticleImport
-	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string) error {
+	err := parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error {
 		if article != nil {
 			articles = append(articles, article)
 		}
@@ -146,7 +146,7 @@ func TestNDJSONParser_ParseComments(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var comments []*models.CommentImport
-	err := parser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string) error {
+	err := parser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string, parseErr *ParseError) error {
 		if comment != nil {
 			comments = append(comments, comment)
 		}
@@ -181,7 +181,7 @@ func TestNDJSONParser_ParseComments_MissingBody(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var comments []*models.CommentImport
-	err := parser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string) error {
+	err := parser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string, parseErr *ParseError) error {
 		if comment != nil {
 			comments = append(comments, comment)
 		}
@@ -216,7 +216,7 @@ func TestNDJSONParser_ParseGeneric(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var records []map[string]interface{}
-	err := parser.ParseGeneric(func(row int, data map[string]interface{}, rawJSON string) error {
+	err := parser.ParseGeneric(func(row int, data map[string]interface{}, rawJSON string, parseErr *ParseError) error {
 		if data != nil {
 			records = append(records, data)
 		}
@@ -252,7 +252,7 @@ func TestNDJSONParser_TotalLines(t *testing.T) {
 		t.Errorf("TotalLines() before parsing = %d, want 0", parser.TotalLines())
 	}
 
-	err := parser.ParseGeneric(func(row int, data map[string]interface{}, rawJSON string) error {
+	err := parser.ParseGeneric(func(row int, data map[string]interface{}, rawJSON string, parseErr *ParseError) error {
 		return nil
 	})
 
@@ -274,7 +274,7 @@ func TestNDJSONParser_ParseUsers(t *testing.T) {
 	parser := NewNDJSONParser(reader)
 
 	var users []*models.UserImport
-	err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string) error {
+	err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error {
 		if user != nil {
 			users = append(users, user)
 		}
@@ -320,7 +320,7 @@ func TestNDJSONParser_ParseUsers_MalformedJSON(t *testing.T) {
 	var validUsers int
 	var parseErrors int
 
-	err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string) error {
+	err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error {
 		if user == nil {
 			parseErrors++
 		} else {
@@ -340,3 +340,81 @@ func TestNDJSONParser_ParseUsers_MalformedJSON(t *testing.T) {
 		t.Errorf("ParseUsers() got %d parse errors, want 1", parseErrors)
 	}
 }
+
+func TestNDJSONParser_ParseUsers_LineTooLong(t *testing.T) {
+	long := `{"email":"big@example.com","name":"` + strings.Repeat("x", 200) + `"}`
+	ndjson := `{"email":"a@example.com","name":"A"}` + "\n" + long + "\n" + `{"email":"b@example.com","name":"B"}`
+
+	parser := NewNDJSONParserSize(strings.NewReader(ndjson), 100)
+
+	var emails []string
+	var tooLongRows []int
+	err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error {
+		if user == nil {
+			if parseErr == nil || parseErr.Code != "LINE_TOO_LONG" {
+				t.Errorf("row %d: parse error = %v, want LINE_TOO_LONG", row, parseErr)
+			}
+			tooLongRows = append(tooLongRows, row)
+			return nil
+		}
+		if parseErr != nil {
+			t.Errorf("row %d: parse error = %v, want nil", row, parseErr)
+		}
+		emails = append(emails, user.Email)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("ParseUsers() error: %v", err)
+	}
+	if len(tooLongRows) != 1 || tooLongRows[0] != 2 {
+		t.Errorf("LINE_TOO_LONG rows = %v, want [2]", tooLongRows)
+	}
+	if len(emails) != 2 || emails[1] != "b@example.com" {
+		t.Errorf("ParseUsers() emails = %v, want parsing to continue after the long line", emails)
+	}
+}
+
+func TestNDJSONParser_ParseUsers_CRLF(t *testing.T) {
+	line := `{"email":"a@example.com","name":"A"}`
+	ndjson := line + "\r\n" + line + "\r\n"
+
+	// A line exactly at the limit is accepted even with a CRLF terminator
+	parser := NewNDJSONParserSize(strings.NewReader(ndjson), len(line))
+
+	count := 0
+	err := parser.ParseUsers(func(row int, user *models.UserImport, rawJSON string, parseErr *ParseError) error {
+		if user == nil {
+			t.Errorf("row %d: unexpected parse error %v", row, parseErr)
+			return nil
+		}
+		if rawJSON != line {
+			t.Errorf("row %d: rawJSON = %q, want %q", row, rawJSON, line)
+		}
+		count++
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("ParseUsers() error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("ParseUsers() got %d users, want 2", count)
+	}
+}
+
+func BenchmarkNDJSONParser_ParseArticles(b *testing.B) {
+	line := `{"id":"de9f2098-3528-42a8-bc6a-1f13ee5f6247","title":"Bench Article","slug":"bench-article","body":"` +
+		strings.Repeat("lorem ipsum dolor sit amet ", 40) +
+		`","author_id":"16b0c588-6f4b-4812-8fea-a39692850695","tags":["go","bench"],"status":"published","published_at":"2024-01-15T10:30:00Z"}` + "\n"
+	data := strings.Repeat(line, 1000)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		parser := NewNDJSONParser(strings.NewReader(data))
+		parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string, parseErr *ParseError) error {
+			return nil
+		})
+	}
+}
@@ -0,0 +1,739 @@
+package parsers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// parquetMagic starts and ends every Parquet file
+var parquetMagic = []byte("PAR1")
+
+var errCorruptParquet = errors.New("corrupt parquet data")
+
+// Parquet physical types
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetInt96     = 3
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+	parquetFixedLen  = 7
+)
+
+// Parquet field repetitions
+const (
+	parquetOptional = 1
+	parquetRepeated = 2
+)
+
+// Parquet page types
+const (
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+)
+
+// Parquet encodings
+const (
+	parquetPlain           = 0
+	parquetPlainDictionary = 2
+	parquetRLE             = 3
+	parquetRLEDictionary   = 8
+)
+
+// parquetCodecs names the compression codecs by id, for errors about the
+// ones that aren't supported
+var parquetCodecs = []string{"UNCOMPRESSED", "SNAPPY", "GZIP", "LZO", "BROTLI", "LZ4", "ZSTD", "LZ4_RAW"}
+
+// maxParquetChunkSize bounds the size of a column chunk read into memory, so
+// a corrupt file can't cause a huge allocation
+const maxParquetChunkSize = 1 << 30
+
+// julianUnixEpoch is the Julian day number of 1970-01-01, for INT96
+// timestamps
+const julianUnixEpoch = 2440588
+
+// parquetColumn is a leaf column of the file's schema. Only flat schemas are
+// read: each top-level field is a single column, optionally repeated once,
+// such as a list of strings.
+type parquetColumn struct {
+	name       string
+	physical   int64
+	typeLength int
+	maxDef     int
+	maxRep     int
+	// repDef is the definition level of the repeated node, below which a
+	// repeated column's list is null
+	repDef  int
+	convert func(interface{}) interface{}
+}
+
+// parquetReader reads a Parquet file one row group at a time
+type parquetReader struct {
+	r         io.ReaderAt
+	size      int64
+	columns   []*parquetColumn
+	rowGroups []thriftFields
+	group     int
+	values    [][]interface{}
+	row       int
+	groupRows int
+}
+
+// newParquetReader reads the file's footer metadata
+func newParquetReader(r io.ReaderAt, size int64) (*parquetReader, error) {
+	if size < int64(2*len(parquetMagic)+4) {
+		return nil, fmt.Errorf("not a parquet file")
+	}
+	head := make([]byte, len(parquetMagic))
+	tail := make([]byte, 4+len(parquetMagic))
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head, parquetMagic) || !bytes.Equal(tail[4:], parquetMagic) {
+		return nil, fmt.Errorf("not a parquet file")
+	}
+
+	metaLen := int64(binary.LittleEndian.Uint32(tail))
+	if metaLen > size-int64(len(head)+len(tail)) {
+		return nil, errCorruptParquet
+	}
+	meta := make([]byte, metaLen)
+	if _, err := r.ReadAt(meta, size-int64(len(tail))-metaLen); err != nil {
+		return nil, err
+	}
+	d := &thriftDecoder{buf: meta}
+	fileMeta, err := d.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("invalid parquet metadata: %w", err)
+	}
+
+	columns, err := parquetColumns(fileMeta.list(2))
+	if err != nil {
+		return nil, err
+	}
+	pr := &parquetReader{r: r, size: size, columns: columns}
+	for _, rg := range fileMeta.list(4) {
+		if g, ok := rg.(thriftFields); ok {
+			pr.rowGroups = append(pr.rowGroups, g)
+		}
+	}
+	return pr, nil
+}
+
+// parquetNumRows returns the row count recorded in the file's metadata
+func parquetNumRows(r io.ReaderAt, size int64) (int, error) {
+	pr, err := newParquetReader(r, size)
+	if err != nil {
+		return 0, err
+	}
+	rows := 0
+	for _, g := range pr.rowGroups {
+		rows += int(g.int(3))
+	}
+	return rows, nil
+}
+
+// parquetColumns flattens the schema, which is stored depth first, into its
+// leaf columns
+func parquetColumns(schema []interface{}) ([]*parquetColumn, error) {
+	elems := make([]thriftFields, 0, len(schema))
+	for _, e := range schema {
+		fields, ok := e.(thriftFields)
+		if !ok {
+			return nil, errCorruptParquet
+		}
+		elems = append(elems, fields)
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("parquet file has no schema")
+	}
+
+	var columns []*parquetColumn
+	var walk func(i int, top string, def, rep, repDef int) (int, error)
+	walk = func(i int, top string, def, rep, repDef int) (int, error) {
+		if i >= len(elems) {
+			return 0, errCorruptParquet
+		}
+		e := elems[i]
+		switch e.int(3) {
+		case parquetOptional:
+			def++
+		case parquetRepeated:
+			def++
+			rep++
+			repDef = def
+		}
+		if top == "" {
+			top = e.string(4)
+		}
+
+		children := int(e.int(5))
+		if children == 0 {
+			if rep > 1 {
+				return 0, fmt.Errorf("parquet field %s is nested too deeply", top)
+			}
+			col := &parquetColumn{
+				name:       top,
+				physical:   e.int(1),
+				typeLength: int(e.int(2)),
+				maxDef:     def,
+				maxRep:     rep,
+				repDef:     repDef,
+			}
+			col.convert = parquetConverter(e, col.physical)
+			columns = append(columns, col)
+			return i + 1, nil
+		}
+
+		i++
+		for c := 0; c < children; c++ {
+			var err error
+			if i, err = walk(i, top, def, rep, repDef); err != nil {
+				return 0, err
+			}
+		}
+		return i, nil
+	}
+
+	// The root is a group holding the top-level fields
+	i := 1
+	for c := 0; c < int(elems[0].int(5)); c++ {
+		var err error
+		if i, err = walk(i, "", 0, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, col := range columns {
+		if seen[col.name] {
+			return nil, fmt.Errorf("parquet field %s is a nested group, only flat fields and lists are supported", col.name)
+		}
+		seen[col.name] = true
+	}
+	return columns, nil
+}
+
+// parquetConverter returns the conversion from a column's physical values to
+// its logical type, from the logical type annotation or the older converted
+// type
+func parquetConverter(e thriftFields, physical int64) func(interface{}) interface{} {
+	logical := e.child(10)
+	switch {
+	case logical.has(6) || e.has(6) && e.int(6) == 6: // DATE
+		return func(v interface{}) interface{} {
+			days, _ := v.(int32)
+			return epochDate(int64(days))
+		}
+	case logical.has(8): // TIMESTAMP
+		unit := logical.child(8).child(2)
+		return func(v interface{}) interface{} {
+			n, _ := v.(int64)
+			switch {
+			case unit.has(1):
+				return time.UnixMilli(n)
+			case unit.has(3):
+				return time.Unix(0, n)
+			}
+			return time.UnixMicro(n)
+		}
+	case e.has(6) && e.int(6) == 9: // TIMESTAMP_MILLIS
+		return func(v interface{}) interface{} {
+			n, _ := v.(int64)
+			return time.UnixMilli(n)
+		}
+	case e.has(6) && e.int(6) == 10: // TIMESTAMP_MICROS
+		return func(v interface{}) interface{} {
+			n, _ := v.(int64)
+			return time.UnixMicro(n)
+		}
+	case logical.has(14) && physical == parquetFixedLen: // UUID
+		return func(v interface{}) interface{} {
+			id, err := uuid.FromBytes(v.([]byte))
+			if err != nil {
+				return v
+			}
+			return id.String()
+		}
+	case logical.has(5) || e.has(6) && e.int(6) == 5: // DECIMAL
+		scale := int(e.int(7))
+		if logical.has(5) {
+			scale = int(logical.child(5).int(1))
+		}
+		return func(v interface{}) interface{} {
+			return formatDecimal(v, scale)
+		}
+	case physical == parquetInt96:
+		return func(v interface{}) interface{} {
+			b := v.([]byte)
+			nanos := int64(binary.LittleEndian.Uint64(b))
+			days := int64(binary.LittleEndian.Uint32(b[8:]))
+			return time.Unix((days-julianUnixEpoch)*86400, nanos)
+		}
+	case physical == parquetByteArray || physical == parquetFixedLen:
+		return func(v interface{}) interface{} {
+			return string(v.([]byte))
+		}
+	}
+	return func(v interface{}) interface{} { return v }
+}
+
+// formatDecimal renders an unscaled decimal, stored as an integer or as big
+// endian two's complement bytes, with scale digits after the point
+func formatDecimal(v interface{}, scale int) string {
+	n := new(big.Int)
+	switch val := v.(type) {
+	case int32:
+		n.SetInt64(int64(val))
+	case int64:
+		n.SetInt64(val)
+	case []byte:
+		n.SetBytes(val)
+		if len(val) > 0 && val[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(val)*8)))
+		}
+	}
+	digits := new(big.Int).Abs(n).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if n.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Fields returns the names of the top-level fields
+func (pr *parquetReader) Fields() []string {
+	fields := make([]string, len(pr.columns))
+	for i, col := range pr.columns {
+		fields[i] = col.name
+	}
+	return fields
+}
+
+// Next returns the next row, reading the next row group when the current one
+// is used up
+func (pr *parquetReader) Next() (columnRecord, error) {
+	for pr.row >= pr.groupRows {
+		if pr.group >= len(pr.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := pr.readRowGroup(pr.rowGroups[pr.group]); err != nil {
+			return nil, err
+		}
+		pr.group++
+	}
+
+	rec := make(columnRecord, len(pr.columns))
+	for i, col := range pr.columns {
+		if v := columnValue(pr.values[i][pr.row]); v != nil {
+			rec[strings.ToLower(col.name)] = v
+		}
+	}
+	pr.row++
+	return rec, nil
+}
+
+// readRowGroup decodes every column of a row group
+func (pr *parquetReader) readRowGroup(group thriftFields) error {
+	chunks := group.list(1)
+	if len(chunks) != len(pr.columns) {
+		return fmt.Errorf("%w: row group has %d columns, schema has %d", errCorruptParquet, len(chunks), len(pr.columns))
+	}
+	rows := int(group.int(3))
+
+	values := make([][]interface{}, len(pr.columns))
+	for i, chunk := range chunks {
+		cc, ok := chunk.(thriftFields)
+		if !ok {
+			return errCorruptParquet
+		}
+		if cc.string(1) != "" {
+			return fmt.Errorf("parquet column chunks in other files are not supported")
+		}
+		col, err := pr.readColumnChunk(pr.columns[i], cc.child(3))
+		if err != nil {
+			return fmt.Errorf("column %s: %w", pr.columns[i].name, err)
+		}
+		if len(col) != rows {
+			return fmt.Errorf("%w: column %s has %d rows, row group has %d", errCorruptParquet, pr.columns[i].name, len(col), rows)
+		}
+		values[i] = col
+	}
+
+	pr.values = values
+	pr.row = 0
+	pr.groupRows = rows
+	return nil
+}
+
+// readColumnChunk reads a column chunk's pages and returns a value per row:
+// nil for null, the converted value, or a []interface{} for a repeated
+// column
+func (pr *parquetReader) readColumnChunk(col *parquetColumn, meta thriftFields) ([]interface{}, error) {
+	if meta == nil {
+		return nil, errCorruptParquet
+	}
+	codec := meta.int(4)
+	numValues := meta.int(5)
+	start := meta.int(9)
+	if dict := meta.int(11); meta.has(11) && dict > 0 && dict < start {
+		start = dict
+	}
+	length := meta.int(7)
+	if start < 0 || length < 0 || length > maxParquetChunkSize || start+length > pr.size {
+		return nil, errCorruptParquet
+	}
+	buf := make([]byte, length)
+	if _, err := pr.r.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+
+	var dict []interface{}
+	var rows []interface{}
+	d := &thriftDecoder{buf: buf}
+	for read := int64(0); read < numValues && d.pos < len(buf); {
+		header, err := d.readStruct()
+		if err != nil {
+			return nil, err
+		}
+		size := int(header.int(3))
+		if size < 0 || size > len(buf)-d.pos {
+			return nil, errCorruptParquet
+		}
+		page := buf[d.pos : d.pos+size]
+		d.pos += size
+		uncompressed := int(header.int(2))
+
+		switch header.int(1) {
+		case parquetDictionaryPage:
+			data, err := decompressParquet(page, codec, uncompressed)
+			if err != nil {
+				return nil, err
+			}
+			raw, _, err := decodePlain(data, col, int(header.child(7).int(1)))
+			if err != nil {
+				return nil, err
+			}
+			dict = make([]interface{}, len(raw))
+			for i, v := range raw {
+				dict[i] = col.convert(v)
+			}
+
+		case parquetDataPage:
+			h := header.child(5)
+			data, err := decompressParquet(page, codec, uncompressed)
+			if err != nil {
+				return nil, err
+			}
+			n := int(h.int(1))
+			var repLevels, defLevels []int
+			if col.maxRep > 0 {
+				if repLevels, data, err = readPrefixedLevels(data, col.maxRep, n); err != nil {
+					return nil, err
+				}
+			}
+			if col.maxDef > 0 {
+				if defLevels, data, err = readPrefixedLevels(data, col.maxDef, n); err != nil {
+					return nil, err
+				}
+			}
+			if rows, err = pr.appendPage(rows, col, data, h.int(2), dict, n, repLevels, defLevels); err != nil {
+				return nil, err
+			}
+			read += int64(n)
+
+		case parquetDataPageV2:
+			h := header.child(8)
+			n := int(h.int(1))
+			repLen, defLen := int(h.int(6)), int(h.int(5))
+			if repLen < 0 || defLen < 0 || repLen+defLen > len(page) {
+				return nil, errCorruptParquet
+			}
+			var repLevels, defLevels []int
+			if col.maxRep > 0 {
+				if repLevels, err = decodeHybrid(page[:repLen], bits.Len(uint(col.maxRep)), n); err != nil {
+					return nil, err
+				}
+			}
+			if col.maxDef > 0 {
+				if defLevels, err = decodeHybrid(page[repLen:repLen+defLen], bits.Len(uint(col.maxDef)), n); err != nil {
+					return nil, err
+				}
+			}
+			data := page[repLen+defLen:]
+			if compressed, ok := h.bool(7); !ok || compressed {
+				if data, err = decompressParquet(data, codec, uncompressed-repLen-defLen); err != nil {
+					return nil, err
+				}
+			}
+			if rows, err = pr.appendPage(rows, col, data, h.int(4), dict, n, repLevels, defLevels); err != nil {
+				return nil, err
+			}
+			read += int64(n)
+		}
+	}
+	return rows, nil
+}
+
+// appendPage decodes a data page's values and adds them to rows by their
+// levels. A repetition level of 0 starts a new row.
+func (pr *parquetReader) appendPage(rows []interface{}, col *parquetColumn, data []byte, encoding int64, dict []interface{}, n int, repLevels, defLevels []int) ([]interface{}, error) {
+	present := n
+	if defLevels != nil {
+		present = 0
+		for _, def := range defLevels {
+			if def == col.maxDef {
+				present++
+			}
+		}
+	}
+
+	var values []interface{}
+	switch encoding {
+	case parquetPlain:
+		raw, _, err := decodePlain(data, col, present)
+		if err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, len(raw))
+		for i, v := range raw {
+			values[i] = col.convert(v)
+		}
+	case parquetPlainDictionary, parquetRLEDictionary:
+		if dict == nil || len(data) == 0 {
+			return nil, fmt.Errorf("%w: dictionary page missing", errCorruptParquet)
+		}
+		indexes, err := decodeHybrid(data[1:], int(data[0]), present)
+		if err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, len(indexes))
+		for i, idx := range indexes {
+			if idx < 0 || idx >= len(dict) {
+				return nil, errCorruptParquet
+			}
+			values[i] = dict[idx]
+		}
+	case parquetRLE:
+		if col.physical != parquetBoolean || len(data) < 4 {
+			return nil, fmt.Errorf("unsupported parquet encoding RLE for non-boolean values")
+		}
+		levels, err := decodeHybrid(data[4:], 1, present)
+		if err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, len(levels))
+		for i, v := range levels {
+			values[i] = col.convert(v == 1)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported parquet encoding %d", encoding)
+	}
+
+	next := 0
+	for i := 0; i < n; i++ {
+		var v interface{}
+		def := col.maxDef
+		if defLevels != nil {
+			def = defLevels[i]
+		}
+		if def == col.maxDef {
+			if next >= len(values) {
+				return nil, errCorruptParquet
+			}
+			v = values[next]
+			next++
+		}
+
+		if col.maxRep == 0 {
+			rows = append(rows, v)
+			continue
+		}
+		if repLevels[i] == 0 {
+			if def < col.repDef {
+				rows = append(rows, nil)
+				continue
+			}
+			rows = append(rows, []interface{}{})
+		}
+		if len(rows) == 0 {
+			return nil, errCorruptParquet
+		}
+		if list, ok := rows[len(rows)-1].([]interface{}); ok && def >= col.repDef {
+			rows[len(rows)-1] = append(list, v)
+		}
+	}
+	return rows, nil
+}
+
+// decompressParquet decompresses a page with the column chunk's codec
+func decompressParquet(data []byte, codec int64, size int) ([]byte, error) {
+	switch codec {
+	case 0:
+		return data, nil
+	case 1:
+		return decodeSnappy(data)
+	case 2:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		out := bytes.NewBuffer(make([]byte, 0, max(size, 0)))
+		if _, err := io.Copy(out, io.LimitReader(zr, maxParquetChunkSize)); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	name := fmt.Sprint(codec)
+	if codec >= 0 && codec < int64(len(parquetCodecs)) {
+		name = parquetCodecs[codec]
+	}
+	return nil, fmt.Errorf("unsupported parquet codec %s", name)
+}
+
+// readPrefixedLevels reads levels stored with a 4-byte length in a version 1
+// data page, returning the rest of the page
+func readPrefixedLevels(data []byte, maxLevel, n int) ([]int, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errCorruptParquet
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > len(data)-4 {
+		return nil, nil, errCorruptParquet
+	}
+	levels, err := decodeHybrid(data[4:4+size], bits.Len(uint(maxLevel)), n)
+	return levels, data[4+size:], err
+}
+
+// decodeHybrid decodes n values of the RLE / bit-packing hybrid encoding
+func decodeHybrid(data []byte, bitWidth, n int) ([]int, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, errCorruptParquet
+	}
+	out := make([]int, 0, n)
+	for len(out) < n {
+		header, read := binary.Uvarint(data)
+		if read <= 0 {
+			return nil, errCorruptParquet
+		}
+		data = data[read:]
+
+		if header&1 == 1 {
+			// Bit-packed groups of 8 values, least significant bit first
+			count := int(header>>1) * 8
+			size := int(header>>1) * bitWidth
+			if size > len(data) {
+				return nil, errCorruptParquet
+			}
+			for i := 0; i < count && len(out) < n; i++ {
+				v := 0
+				for b := 0; b < bitWidth; b++ {
+					bit := i*bitWidth + b
+					v |= int(data[bit/8]>>(bit%8)&1) << b
+				}
+				out = append(out, v)
+			}
+			data = data[size:]
+			continue
+		}
+
+		// A run of one value, stored in the fewest whole bytes
+		count := int(header >> 1)
+		width := (bitWidth + 7) / 8
+		if width > len(data) {
+			return nil, errCorruptParquet
+		}
+		v := 0
+		for i := width - 1; i >= 0; i-- {
+			v = v<<8 | int(data[i])
+		}
+		data = data[width:]
+		for i := 0; i < count && len(out) < n; i++ {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// decodePlain decodes n plain-encoded values of col's physical type,
+// returning them with the rest of data
+func decodePlain(data []byte, col *parquetColumn, n int) ([]interface{}, []byte, error) {
+	values := make([]interface{}, 0, n)
+	width := 0
+	switch col.physical {
+	case parquetInt32, parquetFloat:
+		width = 4
+	case parquetInt64, parquetDouble:
+		width = 8
+	case parquetInt96:
+		width = 12
+	case parquetFixedLen:
+		width = col.typeLength
+	case parquetBoolean:
+		if (n+7)/8 > len(data) {
+			return nil, nil, errCorruptParquet
+		}
+		for i := 0; i < n; i++ {
+			values = append(values, data[i/8]>>(i%8)&1 == 1)
+		}
+		return values, data[(n+7)/8:], nil
+	case parquetByteArray:
+		for i := 0; i < n; i++ {
+			if len(data) < 4 {
+				return nil, nil, errCorruptParquet
+			}
+			size := int(binary.LittleEndian.Uint32(data))
+			if size > len(data)-4 {
+				return nil, nil, errCorruptParquet
+			}
+			values = append(values, data[4:4+size])
+			data = data[4+size:]
+		}
+		return values, data, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported parquet type %d", col.physical)
+	}
+
+	if width <= 0 || n*width > len(data) {
+		return nil, nil, errCorruptParquet
+	}
+	for i := 0; i < n; i++ {
+		b := data[i*width : (i+1)*width]
+		switch col.physical {
+		case parquetInt32:
+			values = append(values, int32(binary.LittleEndian.Uint32(b)))
+		case parquetInt64:
+			values = append(values, int64(binary.LittleEndian.Uint64(b)))
+		case parquetFloat:
+			values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case parquetDouble:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		default:
+			values = append(values, b)
+		}
+	}
+	return values, data[n*width:], nil
+}
@@ -0,0 +1,368 @@
+package parsers
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+const (
+	// profileTopValues is the number of most frequent values reported per column
+	profileTopValues = 10
+	// profileCounters bounds the frequent-value counters kept per column
+	profileCounters = 100
+	// profileSketchSize is the number of hashes kept for distinct estimation
+	profileSketchSize = 1024
+)
+
+// profileTimeLayouts are tried when checking whether a value is a timestamp
+var profileTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// Profiler accumulates per-column statistics in a single pass with memory
+// bounded per column, independent of the number of rows
+type Profiler struct {
+	rows    int
+	columns map[string]*columnStats
+	order   []string
+}
+
+// NewProfiler creates an empty profiler
+func NewProfiler() *Profiler {
+	return &Profiler{columns: make(map[string]*columnStats)}
+}
+
+// AddRow records one row; columns absent from the map count as null
+func (p *Profiler) AddRow(values map[string]string) {
+	p.rows++
+	for name, value := range values {
+		p.column(name).add(value)
+	}
+}
+
+// column returns the stats for a column, registering it on first use
+func (p *Profiler) column(name string) *columnStats {
+	col, ok := p.columns[name]
+	if !ok {
+		col = newColumnStats()
+		p.columns[name] = col
+		p.order = append(p.order, name)
+	}
+	return col
+}
+
+// Rows returns the number of rows recorded
+func (p *Profiler) Rows() int {
+	return p.rows
+}
+
+// Columns returns the column profiles in the order columns were first seen
+func (p *Profiler) Columns() []*models.ColumnProfile {
+	result := make([]*models.ColumnProfile, 0, len(p.order))
+	for _, name := range p.order {
+		result = append(result, p.columns[name].profile(name, p.rows))
+	}
+	return result
+}
+
+// ProfileCSV profiles every column of a CSV file; empty cells count as null
+func ProfileCSV(r io.Reader) (*Profiler, error) {
+	decoded, _, err := NewDecoder(r, EncodingAuto)
+	if err != nil {
+		return nil, err
+	}
+	csvReader := csv.NewReader(decoded)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.TrimLeadingSpace = true
+	csvReader.ReuseRecord = true
+
+	headers, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV headers: %w", err)
+	}
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		names[i] = strings.ToLower(strings.TrimSpace(h))
+	}
+
+	// Register every header up front so all-empty columns are still reported
+	p := NewProfiler()
+	for _, name := range names {
+		p.column(name)
+	}
+	row := make(map[string]string, len(names))
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		for k := range row {
+			delete(row, k)
+		}
+		for i, name := range names {
+			if i < len(record) && strings.TrimSpace(record[i]) != "" {
+				row[name] = record[i]
+			}
+		}
+		p.AddRow(row)
+	}
+	return p, nil
+}
+
+// ProfileNDJSON profiles every top-level field of an NDJSON file; missing,
+// null and empty-string fields count as null and malformed lines are skipped
+func ProfileNDJSON(r io.Reader) (*Profiler, error) {
+	p := NewProfiler()
+	err := NewNDJSONParser(r).ParseGeneric(func(row int, data map[string]interface{}, rawJSON string, parseErr *ParseError) error {
+		if data == nil {
+			return nil
+		}
+
+		values := make(map[string]string, len(data))
+		for name, v := range data {
+			if s := profileValue(v); s != "" {
+				values[name] = s
+			}
+		}
+		p.AddRow(values)
+		return nil
+	})
+	return p, err
+}
+
+// ProfileColumnar profiles every top-level field of an Avro or Parquet file;
+// null fields count as null and arrays are profiled as JSON
+func ProfileColumnar(parser *ColumnarParser) (*Profiler, error) {
+	p := NewProfiler()
+	err := parser.scan(func(rec columnRecord, raw string) error {
+		values := make(map[string]string, len(rec))
+		for name, v := range rec {
+			if s := profileValue(v); s != "" {
+				values[name] = s
+			}
+		}
+		p.AddRow(values)
+		return nil
+	})
+	return p, err
+}
+
+// ProfileXLSX profiles every column of a workbook's first sheet; empty
+// cells count as null
+func ProfileXLSX(parser *XLSXParser) (*Profiler, error) {
+	p := NewProfiler()
+	names := make([]string, len(parser.headers))
+	for i, h := range parser.headers {
+		names[i] = strings.ToLower(strings.TrimSpace(h))
+		if names[i] != "" {
+			p.column(names[i])
+		}
+	}
+	row := make(map[string]string, len(names))
+	err := parser.scan(func(rec csvRecord, raw string) error {
+		clear(row)
+		for i, name := range names {
+			if name != "" && i < len(rec.values) && strings.TrimSpace(rec.values[i]) != "" {
+				row[name] = rec.values[i]
+			}
+		}
+		p.AddRow(row)
+		return nil
+	})
+	return p, err
+}
+
+// profileValue renders a decoded JSON value as the string that is profiled
+func profileValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64, bool:
+		return fmt.Sprint(val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+// columnStats tracks one column. Frequent values use the Misra-Gries summary
+// and distinct values a k-minimum-values sketch.
+type columnStats struct {
+	present  int
+	minLen   int
+	maxLen   int
+	counters map[string]int
+	sketch   hashHeap
+	inSketch map[uint64]struct{}
+	minTime  *time.Time
+	maxTime  *time.Time
+	timeSeen int
+}
+
+func newColumnStats() *columnStats {
+	return &columnStats{
+		minLen:   math.MaxInt,
+		counters: make(map[string]int, profileCounters),
+		inSketch: make(map[uint64]struct{}, profileSketchSize),
+	}
+}
+
+func (c *columnStats) add(value string) {
+	c.present++
+
+	n := utf8.RuneCountInString(value)
+	if n < c.minLen {
+		c.minLen = n
+	}
+	if n > c.maxLen {
+		c.maxLen = n
+	}
+
+	c.count(value)
+	c.sketchValue(value)
+
+	if t, ok := parseProfileTime(value); ok {
+		c.timeSeen++
+		if c.minTime == nil || t.Before(*c.minTime) {
+			c.minTime = &t
+		}
+		if c.maxTime == nil || t.After(*c.maxTime) {
+			c.maxTime = &t
+		}
+	}
+}
+
+func (c *columnStats) count(value string) {
+	if _, ok := c.counters[value]; ok || len(c.counters) < profileCounters {
+		c.counters[value]++
+		return
+	}
+	for k := range c.counters {
+		c.counters[k]--
+		if c.counters[k] == 0 {
+			delete(c.counters, k)
+		}
+	}
+}
+
+func (c *columnStats) sketchValue(value string) {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	sum := mix64(h.Sum64())
+
+	if _, ok := c.inSketch[sum]; ok {
+		return
+	}
+	if len(c.sketch) < profileSketchSize {
+		heap.Push(&c.sketch, sum)
+		c.inSketch[sum] = struct{}{}
+		return
+	}
+	if sum < c.sketch[0] {
+		delete(c.inSketch, c.sketch[0])
+		c.sketch[0] = sum
+		heap.Fix(&c.sketch, 0)
+		c.inSketch[sum] = struct{}{}
+	}
+}
+
+// distinct returns the exact count while the sketch is not full and the KMV
+// estimate (k-1)/max-normalised-hash once it is
+func (c *columnStats) distinct() int64 {
+	if len(c.sketch) < profileSketchSize {
+		return int64(len(c.sketch))
+	}
+	kth := float64(c.sketch[0]) / math.MaxUint64
+	if kth == 0 {
+		return int64(len(c.sketch))
+	}
+	return int64(float64(profileSketchSize-1) / kth)
+}
+
+func (c *columnStats) profile(name string, rows int) *models.ColumnProfile {
+	col := &models.ColumnProfile{
+		Name:             name,
+		NullCount:        rows - c.present,
+		DistinctEstimate: c.distinct(),
+		MaxLength:        c.maxLen,
+		MinTimestamp:     c.minTime,
+		MaxTimestamp:     c.maxTime,
+		TimestampCount:   c.timeSeen,
+	}
+	if rows > 0 {
+		col.NullRate = float64(col.NullCount) / float64(rows)
+	}
+	if c.present > 0 {
+		col.MinLength = c.minLen
+	}
+
+	col.TopValues = make([]models.ValueCount, 0, len(c.counters))
+	for value, count := range c.counters {
+		col.TopValues = append(col.TopValues, models.ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(col.TopValues, func(i, j int) bool {
+		if col.TopValues[i].Count != col.TopValues[j].Count {
+			return col.TopValues[i].Count > col.TopValues[j].Count
+		}
+		return col.TopValues[i].Value < col.TopValues[j].Value
+	})
+	if len(col.TopValues) > profileTopValues {
+		col.TopValues = col.TopValues[:profileTopValues]
+	}
+
+	return col
+}
+
+// mix64 is the MurmurHash3 finalizer; FNV alone is too clustered on short,
+// similar keys for the sketch to be accurate
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func parseProfileTime(value string) (time.Time, bool) {
+	// Cheap pre-check so free text doesn't pay for every layout
+	if len(value) < 10 || value[4] != '-' || value[7] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range profileTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// hashHeap is a max-heap of hashes, so the largest kept hash is evicted first
+type hashHeap []uint64
+
+func (h hashHeap) Len() int            { return len(h) }
+func (h hashHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h hashHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hashHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *hashHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,110 @@
+package parsers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProfileCSV(t *testing.T) {
+	csvData := `email,name,role,created_at,notes
+a@example.com,Alice,admin,2024-01-05T10:00:00Z,
+b@example.com,Bob,reader,2024-03-01T00:00:00Z,
+,Carol,reader,2023-12-31T23:59:59Z,
+d@example.com,Dan,reader,not-a-date,
+`
+	profiler, err := ProfileCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ProfileCSV() unexpected error: %v", err)
+	}
+
+	if profiler.Rows() != 4 {
+		t.Errorf("Rows() = %d, want 4", profiler.Rows())
+	}
+
+	columns := profiler.Columns()
+	if len(columns) != 5 {
+		t.Fatalf("Columns() returned %d columns, want 5", len(columns))
+	}
+
+	email := columns[0]
+	if email.Name != "email" {
+		t.Errorf("columns[0].Name = %q, want email", email.Name)
+	}
+	if email.NullCount != 1 || email.NullRate != 0.25 {
+		t.Errorf("email nulls = %d (%.2f), want 1 (0.25)", email.NullCount, email.NullRate)
+	}
+	if email.DistinctEstimate != 3 {
+		t.Errorf("email DistinctEstimate = %d, want 3", email.DistinctEstimate)
+	}
+	if email.MinLength != 13 || email.MaxLength != 13 {
+		t.Errorf("email lengths = %d..%d, want 13..13", email.MinLength, email.MaxLength)
+	}
+
+	role := columns[2]
+	if len(role.TopValues) != 2 || role.TopValues[0].Value != "reader" || role.TopValues[0].Count != 3 {
+		t.Errorf("role TopValues = %+v, want reader:3 first", role.TopValues)
+	}
+
+	createdAt := columns[3]
+	if createdAt.TimestampCount != 3 {
+		t.Errorf("created_at TimestampCount = %d, want 3", createdAt.TimestampCount)
+	}
+	if createdAt.MinTimestamp == nil || createdAt.MinTimestamp.Year() != 2023 {
+		t.Errorf("created_at MinTimestamp = %v, want 2023-12-31", createdAt.MinTimestamp)
+	}
+	if createdAt.MaxTimestamp == nil || createdAt.MaxTimestamp.Month() != 3 {
+		t.Errorf("created_at MaxTimestamp = %v, want 2024-03-01", createdAt.MaxTimestamp)
+	}
+
+	notes := columns[4]
+	if notes.NullRate != 1 || notes.MinLength != 0 || len(notes.TopValues) != 0 {
+		t.Errorf("notes = %+v, want an all-null column", notes)
+	}
+}
+
+func TestProfileNDJSON(t *testing.T) {
+	ndjson := `{"title": "One", "tags": ["go"], "views": 10}
+{"title": "Two", "tags": null}
+not json
+{"title": "", "extra": true}
+`
+	profiler, err := ProfileNDJSON(strings.NewReader(ndjson))
+	if err != nil {
+		t.Fatalf("ProfileNDJSON() unexpected error: %v", err)
+	}
+
+	if profiler.Rows() != 3 {
+		t.Errorf("Rows() = %d, want 3 (malformed line skipped)", profiler.Rows())
+	}
+
+	byName := make(map[string]int)
+	for _, col := range profiler.Columns() {
+		byName[col.Name] = col.NullCount
+	}
+
+	want := map[string]int{"title": 1, "tags": 2, "views": 2, "extra": 2}
+	for name, nulls := range want {
+		got, ok := byName[name]
+		if !ok {
+			t.Errorf("column %q missing from profile", name)
+			continue
+		}
+		if got != nulls {
+			t.Errorf("column %q NullCount = %d, want %d", name, got, nulls)
+		}
+	}
+}
+
+func TestProfiler_DistinctEstimate(t *testing.T) {
+	profiler := NewProfiler()
+	const distinct = 50000
+	for i := 0; i < distinct; i++ {
+		profiler.AddRow(map[string]string{"id": fmt.Sprintf("id-%d", i)})
+	}
+
+	estimate := profiler.Columns()[0].DistinctEstimate
+	if estimate < distinct*8/10 || estimate > distinct*12/10 {
+		t.Errorf("DistinctEstimate = %d, want within 20%% of %d", estimate, distinct)
+	}
+}
@@ -0,0 +1,113 @@
+package parsers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// RecordFunc receives each row a RecordParser reads. record is a
+// *models.UserImport, *models.ArticleImport or *models.CommentImport for the
+// resource being parsed, or nil when the row could not be read, with
+// parseErr saying why when the parser knows.
+type RecordFunc func(row int, record any, raw string, parseErr error) error
+
+// RecordParser reads the records of one import file, whatever its format
+type RecordParser interface {
+	Parse(resource models.ResourceType, fn RecordFunc) error
+}
+
+// ParserOptions are the settings a ParserFactory may apply. Formats ignore
+// those that don't concern them, such as Encoding for binary files.
+type ParserOptions struct {
+	// Encoding is the character encoding of the file; "" detects it
+	Encoding Encoding
+	// MaxLineSize is the longest line of a line-based format; 0 is the
+	// format's default
+	MaxLineSize int
+	// Flattener maps the fields of nested documents, or is nil
+	Flattener *Flattener
+}
+
+// ParserFactory opens a RecordParser on an import file
+type ParserFactory func(file *os.File, opts ParserOptions) (RecordParser, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[FileFormat]ParserFactory)
+	extensions = make(map[string]FileFormat)
+)
+
+// Register makes factory the parser of format for NewRecordParser, and
+// files with one of exts, such as ".csv", files of format for
+// DetectFormat. It panics if format or an extension is already registered,
+// so two formats can't silently claim the same files.
+func Register(format FileFormat, factory ParserFactory, exts ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[format]; ok {
+		panic(fmt.Sprintf("parsers: format %s registered twice", format))
+	}
+	for _, ext := range exts {
+		ext = strings.ToLower(ext)
+		if other, ok := extensions[ext]; ok {
+			panic(fmt.Sprintf("parsers: extension %s registered for %s and %s", ext, other, format))
+		}
+		extensions[ext] = format
+	}
+	registry[format] = factory
+}
+
+// Formats returns the registered formats in name order
+func Formats() []FileFormat {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	formats := make([]FileFormat, 0, len(registry))
+	for format := range registry {
+		formats = append(formats, format)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// NewRecordParser opens file with the parser registered for the format its
+// extension names
+func NewRecordParser(file *os.File, opts ParserOptions) (RecordParser, error) {
+	format := DetectFormat(file.Name())
+	registryMu.RLock()
+	factory, ok := registry[format]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no parser registered for %s files", format)
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = EncodingAuto
+	}
+	return factory(file, opts)
+}
+
+// asRecord returns rec as a RecordFunc record, keeping a nil pointer nil
+func asRecord[T any](rec *T) any {
+	if rec == nil {
+		return nil
+	}
+	return rec
+}
+
+// rowError returns err as an error, keeping a nil *ParseError nil
+func rowError(err *ParseError) error {
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+// unknownResource is the error of a Parse call for a resource the parsers
+// have no records for
+func unknownResource(resource models.ResourceType) error {
+	return fmt.Errorf("unknown resource type: %s", resource)
+}
@@ -0,0 +1,70 @@
+package parsers
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestNewRecordParser_DispatchesByExtension(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"users.csv", "email,name,role\nann@example.com,Ann,admin\n"},
+		{"users.jsonl", `{"email":"ann@example.com","name":"Ann","role":"admin"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			parser, err := NewRecordParser(file, ParserOptions{})
+			if err != nil {
+				t.Fatalf("NewRecordParser() error: %v", err)
+			}
+			var users []*models.UserImport
+			err = parser.Parse(models.ResourceTypeUsers, func(row int, record any, raw string, parseErr error) error {
+				if parseErr != nil {
+					t.Errorf("row %d: %v", row, parseErr)
+				}
+				users = append(users, record.(*models.UserImport))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if len(users) != 1 || users[0].Email != "ann@example.com" {
+				t.Errorf("Parse() got %+v, want Ann", users)
+			}
+		})
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() of a registered format did not panic")
+		}
+	}()
+	Register(FormatCSV, nil)
+}
+
+func TestFormats(t *testing.T) {
+	want := []FileFormat{FormatAvro, FormatCSV, FormatJSON, FormatNDJSON, FormatParquet, FormatXLSX}
+	slices.Sort(want)
+	if got := Formats(); !slices.Equal(got, want) {
+		t.Errorf("Formats() = %v, want %v", got, want)
+	}
+}
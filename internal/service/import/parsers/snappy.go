@@ -0,0 +1,86 @@
+package parsers
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errCorruptSnappy = errors.New("corrupt snappy block")
+
+// maxSnappyBlockSize bounds the decoded size a snappy block may claim, so a
+// corrupt length can't cause a huge allocation
+const maxSnappyBlockSize = 1 << 30
+
+// decodeSnappy decodes a snappy block, the raw format used by both Avro and
+// Parquet for their snappy codec
+func decodeSnappy(src []byte) ([]byte, error) {
+	n, read := binary.Uvarint(src)
+	if read <= 0 || n > maxSnappyBlockSize {
+		return nil, errCorruptSnappy
+	}
+	src = src[read:]
+	dst := make([]byte, 0, n)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 0x03 {
+		case 0x00: // literal
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				size := length - 59
+				if len(src) < size {
+					return nil, errCorruptSnappy
+				}
+				length = 0
+				for i := size - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[size:]
+			}
+			length++
+			if length > len(src) || len(dst)+length > int(n) {
+				return nil, errCorruptSnappy
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 0x01: // copy with a 1-byte offset
+			if len(src) < 2 {
+				return nil, errCorruptSnappy
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 0x02: // copy with a 2-byte offset
+			if len(src) < 3 {
+				return nil, errCorruptSnappy
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 0x03: // copy with a 4-byte offset
+			if len(src) < 5 {
+				return nil, errCorruptSnappy
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || len(dst)+length > int(n) {
+			return nil, errCorruptSnappy
+		}
+		// Copies may overlap what they produce, so go byte by byte
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if len(dst) != int(n) {
+		return nil, errCorruptSnappy
+	}
+	return dst, nil
+}
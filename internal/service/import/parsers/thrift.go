@@ -0,0 +1,220 @@
+package parsers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errCorruptThrift = errors.New("corrupt thrift data")
+
+// Thrift compact protocol type ids
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// maxThriftDepth bounds struct nesting, so corrupt data can't recurse
+// without end
+const maxThriftDepth = 64
+
+// thriftFields is a struct decoded without its IDL: field values keyed by
+// field id. Integers decode to int64, binary to []byte, lists and sets to
+// []interface{} and nested structs to thriftFields; maps are skipped.
+type thriftFields map[int16]interface{}
+
+func (s thriftFields) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftFields) has(id int16) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s thriftFields) bool(id int16) (bool, bool) {
+	v, ok := s[id].(bool)
+	return v, ok
+}
+
+func (s thriftFields) string(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftFields) child(id int16) thriftFields {
+	v, _ := s[id].(thriftFields)
+	return v
+}
+
+func (s thriftFields) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+// thriftDecoder reads the Thrift compact protocol from a buffer
+type thriftDecoder struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+// readStruct decodes a struct, leaving pos just past it
+func (d *thriftDecoder) readStruct() (thriftFields, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxThriftDepth {
+		return nil, errCorruptThrift
+	}
+
+	s := thriftFields{}
+	var id int16
+	for {
+		header, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return s, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+
+		typ := header & 0x0f
+		switch typ {
+		case thriftTrue:
+			s[id] = true
+		case thriftFalse:
+			s[id] = false
+		default:
+			if s[id], err = d.value(typ); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *thriftDecoder) value(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// Booleans in lists take a byte of their own
+		b, err := d.byte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := d.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return d.varint()
+	case thriftDouble:
+		if d.pos+8 > len(d.buf) {
+			return nil, errCorruptThrift
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.buf[d.pos:]))
+		d.pos += 8
+		return v, nil
+	case thriftBinary:
+		n, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(d.buf)-d.pos) {
+			return nil, errCorruptThrift
+		}
+		b := d.buf[d.pos : d.pos+int(n)]
+		d.pos += int(n)
+		return b, nil
+	case thriftList, thriftSet:
+		header, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = d.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > uint64(len(d.buf)-d.pos) {
+			return nil, errCorruptThrift
+		}
+		items := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			item, err := d.value(header & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case thriftMap:
+		size, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		types, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, err := d.value(types >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := d.value(types & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStruct:
+		return d.readStruct()
+	}
+	return nil, errCorruptThrift
+}
+
+func (d *thriftDecoder) byte() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, errCorruptThrift
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *thriftDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, errCorruptThrift
+	}
+	d.pos += n
+	return v, nil
+}
+
+// varint reads a zig-zag encoded integer
+func (d *thriftDecoder) varint() (int64, error) {
+	v, n := binary.Varint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, errCorruptThrift
+	}
+	d.pos += n
+	return v, nil
+}
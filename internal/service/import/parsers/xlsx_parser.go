@@ -0,0 +1,188 @@
+package parsers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func init() {
+	Register(FormatXLSX, func(file *os.File, opts ParserOptions) (RecordParser, error) {
+		return NewXLSXFileParser(file)
+	}, ".xlsx")
+}
+
+// XLSXParser parses the first sheet of an Excel workbook. The first row
+// with a value is the header, and columns map to the import structs by
+// header the way CSV columns do. Rows are numbered as the sheet numbers
+// them, and rows without a value are skipped.
+type XLSXParser struct {
+	sheet     *xlsxSheet
+	headers   []string
+	headerMap map[string]int
+	row       int
+}
+
+// NewXLSXParser creates a parser for the workbook in r, of size bytes.
+// Workbooks are zip files, which keep their directory at the end, so r must
+// allow random access.
+func NewXLSXParser(r io.ReaderAt, size int64) (*XLSXParser, error) {
+	sheet, err := openXLSX(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xlsx file: %w", err)
+	}
+	p := &XLSXParser{sheet: sheet}
+	row, headers, err := p.nextRow()
+	if err == io.EOF {
+		return nil, fmt.Errorf("failed to read XLSX headers: the first sheet is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XLSX headers: %w", err)
+	}
+	p.row = row
+	p.headers = headers
+	p.headerMap = make(map[string]int, len(headers))
+	for i, h := range headers {
+		if name := strings.ToLower(strings.TrimSpace(h)); name != "" {
+			p.headerMap[name] = i
+		}
+	}
+	return p, nil
+}
+
+// NewXLSXFileParser creates a parser for file. Compressed files are
+// refused: a workbook is a zip file already, and needs random access.
+func NewXLSXFileParser(file *os.File) (*XLSXParser, error) {
+	if IsCompressed(file) {
+		return nil, fmt.Errorf("gzip-compressed xlsx files are not supported; upload the xlsx file itself")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewXLSXParser(file, info.Size())
+}
+
+// CountXLSXRows returns the number of rows with a value in the first sheet
+// of a workbook, not counting the header
+func CountXLSXRows(r io.ReaderAt, size int64) (int, error) {
+	p, err := NewXLSXParser(r, size)
+	if err != nil {
+		return 0, err
+	}
+	defer p.sheet.Close()
+	rows := 0
+	for {
+		if _, _, err := p.nextRow(); err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return 0, err
+		}
+		rows++
+	}
+}
+
+// Fields returns the headers of the sheet's columns
+func (p *XLSXParser) Fields() []string {
+	fields := make([]string, 0, len(p.headers))
+	for _, h := range p.headers {
+		if h = strings.TrimSpace(h); h != "" {
+			fields = append(fields, h)
+		}
+	}
+	return fields
+}
+
+// TotalLines returns the number of the last row read
+func (p *XLSXParser) TotalLines() int {
+	return p.row
+}
+
+// Parse streams the records of resource from the sheet. Every row maps, so
+// parseErr is always nil; an unreadable sheet ends the parse instead.
+func (p *XLSXParser) Parse(resource models.ResourceType, fn RecordFunc) error {
+	switch resource {
+	case models.ResourceTypeUsers:
+		return p.ParseUsers(func(row int, user *models.UserImport, raw string, _ *ParseError) error {
+			return fn(row, user, raw, nil)
+		})
+	case models.ResourceTypeArticles:
+		return p.ParseArticles(func(row int, article *models.ArticleImport, raw string, _ *ParseError) error {
+			return fn(row, article, raw, nil)
+		})
+	case models.ResourceTypeComments:
+		return p.ParseComments(func(row int, comment *models.CommentImport, raw string, _ *ParseError) error {
+			return fn(row, comment, raw, nil)
+		})
+	}
+	return unknownResource(resource)
+}
+
+// ParseUsers streams user records from the sheet
+func (p *XLSXParser) ParseUsers(callback func(row int, user *models.UserImport, rawLine string, parseErr *ParseError) error) error {
+	return p.scan(func(rec csvRecord, raw string) error {
+		return callback(p.row, mapUser(rec), raw, nil)
+	})
+}
+
+// ParseArticles streams article records from the sheet
+func (p *XLSXParser) ParseArticles(callback func(row int, article *models.ArticleImport, rawLine string, parseErr *ParseError) error) error {
+	return p.scan(func(rec csvRecord, raw string) error {
+		return callback(p.row, mapArticle(rec), raw, nil)
+	})
+}
+
+// ParseComments streams comment records from the sheet
+func (p *XLSXParser) ParseComments(callback func(row int, comment *models.CommentImport, rawLine string, parseErr *ParseError) error) error {
+	return p.scan(func(rec csvRecord, raw string) error {
+		return callback(p.row, mapComment(rec), raw, nil)
+	})
+}
+
+// scan calls fn for each row with its cells rendered as a CSV line, which
+// stands in for the raw line in error reports, and closes the sheet once it
+// has been read
+func (p *XLSXParser) scan(fn func(rec csvRecord, raw string) error) error {
+	defer p.sheet.Close()
+	for {
+		row, cells, err := p.nextRow()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read row %d: %w", p.row+1, err)
+		}
+		p.row = row
+		if err := fn(csvRecord{headerMap: p.headerMap, values: cells}, xlsxRawRow(cells)); err != nil {
+			return err
+		}
+	}
+}
+
+// nextRow returns the next row with a value
+func (p *XLSXParser) nextRow() (int, []string, error) {
+	for {
+		row, cells, err := p.sheet.next()
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, cell := range cells {
+			if strings.TrimSpace(cell) != "" {
+				return row, cells, nil
+			}
+		}
+	}
+}
+
+// xlsxRawRow renders the cells of a row as a CSV line
+func xlsxRawRow(cells []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(cells)
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
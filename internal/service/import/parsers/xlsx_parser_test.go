@@ -0,0 +1,156 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// writeXLSX builds a workbook whose first sheet has rows, given as the XML
+// of its <row> elements. The second sheet is never read.
+func writeXLSX(t *testing.T, rows string, shared []string, date1904 bool) []byte {
+	t.Helper()
+	var sst strings.Builder
+	for _, s := range shared {
+		sst.WriteString("<si><t>" + s + "</t></si>")
+	}
+	pr := ""
+	if date1904 {
+		pr = `<workbookPr date1904="1"/>`
+	}
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			pr + `<sheets><sheet name="Users" sheetId="1" r:id="rId2"/><sheet name="Notes" sheetId="2" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>notes</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`,
+		"xl/sharedStrings.xml":     `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sst.String() + `</sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd hh:mm"/><numFmt numFmtId="165" formatCode="&quot;day&quot; 0"/></numFmts>` +
+			`<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestXLSXParser_Users(t *testing.T) {
+	rows := `<row r="2"><c r="A2" t="s"><v>0</v></c><c r="B2" t="s"><v>1</v></c><c r="D2" t="s"><v>2</v></c><c r="E2" t="s"><v>3</v></c><c r="F2" t="s"><v>4</v></c></row>` +
+		`<row r="3"><c r="B3" t="s"><v>5</v></c><c r="C3" t="inlineStr"><is><r><t>Ada </t></r><r><t>Lovelace</t></r><rPh><t>ada</t></rPh></is></c>` +
+		`<c r="D3" t="str"><f>LOWER("ADMIN")</f><v>admin</v></c><c r="E3" t="b"><v>1</v></c><c r="F3" s="2"><v>45352.5</v></c></row>` +
+		`<row r="4"><c r="C4" s="0"><v> </v></c></row>` +
+		`<row r="6"><c r="A6"><v>42</v></c><c r="B6" t="inlineStr"><is><t>bob@example.com</t></is></c><c r="E6" t="b"><v>0</v></c><c r="F6" s="1"><v>45352</v></c></row>`
+	shared := []string{"ID", " Email ", "Role", "Active", "created_at", "ada@example.com"}
+	data := writeXLSX(t, rows, shared, false)
+
+	p, err := NewXLSXParser(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewXLSXParser() error: %v", err)
+	}
+	if fields := p.Fields(); !reflect.DeepEqual(fields, []string{"ID", "Email", "Role", "Active", "created_at"}) {
+		t.Errorf("Fields() = %v", fields)
+	}
+
+	var numbers []int
+	var raws []string
+	var users []*models.UserImport
+	err = p.Parse(models.ResourceTypeUsers, func(row int, rec any, raw string, parseErr error) error {
+		if parseErr != nil {
+			t.Errorf("row %d parse error: %v", row, parseErr)
+		}
+		numbers = append(numbers, row)
+		raws = append(raws, raw)
+		users = append(users, rec.(*models.UserImport))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	// The header's blank C column isn't mapped, so Ada's name is dropped
+	want := []*models.UserImport{
+		{Email: "ada@example.com", Role: "admin", Active: "true", CreatedAt: "2024-03-01T12:00:00Z"},
+		{ID: "42", Email: "bob@example.com", Active: "false", CreatedAt: "2024-03-01T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %+v\nwant %+v", users, want)
+	}
+	if !reflect.DeepEqual(numbers, []int{3, 6}) {
+		t.Errorf("row numbers = %v, want the sheet's 3 and 6", numbers)
+	}
+	if raws[0] != ",ada@example.com,Ada Lovelace,admin,true,2024-03-01T12:00:00Z" {
+		t.Errorf("raw = %q", raws[0])
+	}
+
+	count, err := CountXLSXRows(bytes.NewReader(data), int64(len(data)))
+	if err != nil || count != 2 {
+		t.Errorf("CountXLSXRows() = %d, %v; want 2", count, err)
+	}
+}
+
+func TestXLSXParser_Date1904(t *testing.T) {
+	rows := `<row><c t="inlineStr"><is><t>created_at</t></is></c><c t="inlineStr"><is><t>body</t></is></c></row>` +
+		`<row><c s="1"><v>0</v></c><c s="3"><v>7</v></c></row>`
+	data := writeXLSX(t, rows, nil, true)
+	p, err := NewXLSXParser(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewXLSXParser() error: %v", err)
+	}
+	var comments []*models.CommentImport
+	if err := p.ParseComments(func(row int, comment *models.CommentImport, raw string, parseErr *ParseError) error {
+		comments = append(comments, comment)
+		return nil
+	}); err != nil {
+		t.Fatalf("ParseComments() error: %v", err)
+	}
+	// A number format with only quoted letters isn't a date
+	if len(comments) != 1 || comments[0].CreatedAt != "1904-01-01T00:00:00Z" || comments[0].Body != "7" {
+		t.Errorf("comments = %+v", comments)
+	}
+}
+
+func TestXLSXParser_RejectsOtherFiles(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"not a zip":   []byte("id,email\n1,a@example.com\n"),
+		"empty sheet": writeXLSX(t, `<row r="1"><c r="A1" t="inlineStr"><is><t> </t></is></c></row>`, nil, false),
+		"bad string":  writeXLSX(t, `<row><c t="s"><v>3</v></c></row>`, []string{"id"}, false),
+	} {
+		if _, err := NewXLSXParser(bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("%s: NewXLSXParser() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	tests := map[string]bool{
+		"General":           false,
+		"0.00":              false,
+		"0.00E+00":          false,
+		`"Qty" 0`:           false,
+		"[Red]#,##0":        false,
+		`#,##0\ "kg"`:       false,
+		"yyyy-mm-dd":        true,
+		"[$-409]d-mmm-yy":   true,
+		"h:mm AM/PM":        true,
+		`dd/mm/yyyy\ hh:mm`: true,
+	}
+	for code, want := range tests {
+		if got := isDateFormat(code); got != want {
+			t.Errorf("isDateFormat(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package parsers
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An XLSX workbook is a zip of XML parts. xl/workbook.xml lists the sheets,
+// xl/_rels/workbook.xml.rels names the part of each, xl/sharedStrings.xml
+// holds the text cells refer to by index, and xl/styles.xml the number
+// formats that make a number a date.
+
+var errCorruptXLSX = errors.New("corrupt xlsx data")
+
+// xlsxWorkbook is the part of xl/workbook.xml the reader needs
+type xlsxWorkbook struct {
+	Pr struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		// The relationship ID is namespaced, by one of two namespaces
+		// depending on whether the file is transitional or strict
+		Attrs []xml.Attr `xml:",any,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRels is xl/_rels/workbook.xml.rels
+type xlsxRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxStyles is the part of xl/styles.xml the reader needs
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+// xlsxSheet reads the rows of the first sheet of a workbook in order,
+// decoding the sheet's XML as it goes
+type xlsxSheet struct {
+	part       io.ReadCloser
+	dec        *xml.Decoder
+	strings    []string
+	dateStyles []bool
+	date1904   bool
+	lastRow    int
+}
+
+// openXLSX opens the first sheet of the workbook in r, of size bytes
+func openXLSX(r io.ReaderAt, size int64) (*xlsxSheet, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx workbook: %w", err)
+	}
+	parts := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+
+	var wb xlsxWorkbook
+	if err := decodeXLSXPart(parts, "xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	sheetPath, err := firstSheetPath(parts, wb)
+	if err != nil {
+		return nil, err
+	}
+	sheetPart, ok := parts[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("%w: sheet %s is missing", errCorruptXLSX, sheetPath)
+	}
+
+	s := &xlsxSheet{date1904: wb.Pr.Date1904 == "1" || wb.Pr.Date1904 == "true"}
+	if s.strings, err = readSharedStrings(parts); err != nil {
+		return nil, err
+	}
+	if s.dateStyles, err = readDateStyles(parts); err != nil {
+		return nil, err
+	}
+	if s.part, err = sheetPart.Open(); err != nil {
+		return nil, err
+	}
+	s.dec = xml.NewDecoder(s.part)
+	return s, nil
+}
+
+// firstSheetPath resolves the part of the workbook's first sheet through
+// the workbook's relationships
+func firstSheetPath(parts map[string]*zip.File, wb xlsxWorkbook) (string, error) {
+	var rid string
+	for _, attr := range wb.Sheets[0].Attrs {
+		if attr.Name.Local == "id" {
+			rid = attr.Value
+		}
+	}
+	var rels xlsxRels
+	if err := decodeXLSXPart(parts, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Rels {
+		if rel.ID != rid {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return rel.Target[1:], nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("%w: sheet %q has no part", errCorruptXLSX, wb.Sheets[0].Name)
+}
+
+// decodeXLSXPart unmarshals the part name into v
+func decodeXLSXPart(parts map[string]*zip.File, name string, v any) error {
+	f, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("%w: %s is missing", errCorruptXLSX, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", errCorruptXLSX, name, err)
+	}
+	return nil
+}
+
+// readSharedStrings reads the shared string table, which workbooks without
+// text cells may leave out
+func readSharedStrings(parts map[string]*zip.File) ([]string, error) {
+	f, ok := parts["xl/sharedStrings.xml"]
+	if !ok {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var table []string
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: shared strings: %v", errCorruptXLSX, err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "si" {
+			text, err := readXLSXText(dec)
+			if err != nil {
+				return nil, fmt.Errorf("%w: shared strings: %v", errCorruptXLSX, err)
+			}
+			table = append(table, text)
+		}
+	}
+}
+
+// readXLSXText reads the text of a string item up to the end of the element
+// just started: its <t> elements, or those of its rich text runs, leaving
+// out phonetic hints
+func readXLSXText(dec *xml.Decoder) (string, error) {
+	var b strings.Builder
+	depth, inText, inPhonetic := 1, false, 0
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic++
+			}
+		case xml.EndElement:
+			depth--
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic--
+			}
+		case xml.CharData:
+			if inText && inPhonetic == 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// readDateStyles reports for each cell style whether its number format shows
+// a date or time
+func readDateStyles(parts map[string]*zip.File) ([]bool, error) {
+	if _, ok := parts["xl/styles.xml"]; !ok {
+		return nil, nil
+	}
+	var styles xlsxStyles
+	if err := decodeXLSXPart(parts, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+	dates := make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		if code, ok := custom[xf.NumFmtID]; ok {
+			dates[i] = isDateFormat(code)
+		} else {
+			dates[i] = isBuiltinDateFormat(xf.NumFmtID)
+		}
+	}
+	return dates, nil
+}
+
+// isBuiltinDateFormat reports whether a built-in number format, which
+// workbooks refer to by ID alone, shows a date or time
+func isBuiltinDateFormat(id int) bool {
+	return id >= 14 && id <= 22 || id >= 27 && id <= 36 || id >= 45 && id <= 47 || id >= 50 && id <= 58
+}
+
+// isDateFormat reports whether a custom number format code shows a date or
+// time: whether it has a date or time part outside quoted text, escaped
+// characters and bracketed colors or conditions
+func isDateFormat(code string) bool {
+	if strings.EqualFold(code, "General") {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '"':
+			if end := strings.IndexByte(code[i+1:], '"'); end >= 0 {
+				i += end + 1
+			}
+		case '\\', '_', '*':
+			i++
+		case '[':
+			if end := strings.IndexByte(code[i:], ']'); end >= 0 {
+				i += end
+			}
+		case 'y', 'Y', 'm', 'M', 'd', 'D', 'h', 'H', 's', 'S':
+			return true
+		}
+	}
+	return false
+}
+
+// xlsxDate converts a date serial, days since the workbook's epoch with the
+// time of day as the fraction, to a time in UTC. The 1900 date system
+// counts a 29 February 1900 that never was, so its epoch is 30 December
+// 1899 for every date after it.
+func xlsxDate(serial float64, date1904 bool) time.Time {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	ms := math.Round(serial * 24 * 60 * 60 * 1000)
+	return epoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// next returns the number and cell values of the next row, or io.EOF after
+// the last one. Cells left out of the row, as sheets leave out empty ones,
+// are "".
+func (s *xlsxSheet) next() (int, []string, error) {
+	for {
+		tok, err := s.dec.Token()
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", errCorruptXLSX, err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "row" {
+			row := s.lastRow + 1
+			if r := xlsxAttr(start, "r"); r != "" {
+				if row, err = strconv.Atoi(r); err != nil {
+					return 0, nil, fmt.Errorf("%w: row number %q", errCorruptXLSX, r)
+				}
+			}
+			s.lastRow = row
+			cells, err := s.readRow()
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: row %d: %v", errCorruptXLSX, row, err)
+			}
+			return row, cells, nil
+		}
+	}
+}
+
+// readRow reads the cells of the row element just started
+func (s *xlsxSheet) readRow() ([]string, error) {
+	var cells []string
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return cells, nil
+			}
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			col := len(cells)
+			if ref := xlsxAttr(t, "r"); ref != "" {
+				if col, err = xlsxColumn(ref); err != nil {
+					return nil, err
+				}
+			}
+			value, err := s.readCell(t)
+			if err != nil {
+				return nil, err
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			cells[col] = value
+		}
+	}
+}
+
+// readCell reads the cell element just started and returns its value as
+// text. Formulas give their cached value.
+func (s *xlsxSheet) readCell(start xml.StartElement) (string, error) {
+	var value string
+	var inline string
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "v":
+				text, err := readXLSXChars(s.dec)
+				if err != nil {
+					return "", err
+				}
+				value = text
+			case "is":
+				if inline, err = readXLSXText(s.dec); err != nil {
+					return "", err
+				}
+			default:
+				if err := s.dec.Skip(); err != nil {
+					return "", err
+				}
+			}
+		case xml.EndElement:
+			return s.cellValue(start, value, inline)
+		}
+	}
+}
+
+// cellValue converts the raw value of a cell to text by the cell's type
+func (s *xlsxSheet) cellValue(c xml.StartElement, value, inline string) (string, error) {
+	switch xlsxAttr(c, "t") {
+	case "s":
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(s.strings) {
+			return "", fmt.Errorf("shared string %q out of range", value)
+		}
+		return s.strings[i], nil
+	case "inlineStr":
+		return inline, nil
+	case "b":
+		return strconv.FormatBool(value == "1"), nil
+	case "str", "e", "d":
+		return value, nil
+	}
+	if style, err := strconv.Atoi(xlsxAttr(c, "s")); err == nil && style >= 0 && style < len(s.dateStyles) && s.dateStyles[style] {
+		if serial, err := strconv.ParseFloat(value, 64); err == nil {
+			return scalarString(xlsxDate(serial, s.date1904)), nil
+		}
+	}
+	return value, nil
+}
+
+// Close closes the sheet's part
+func (s *xlsxSheet) Close() error {
+	return s.part.Close()
+}
+
+// readXLSXChars reads the text of the element just started
+func readXLSXChars(dec *xml.Decoder) (string, error) {
+	var b strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.EndElement:
+			return b.String(), nil
+		case xml.StartElement:
+			if err := dec.Skip(); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// xlsxAttr returns the value of the attribute name of el, or ""
+func xlsxAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name && attr.Name.Space == "" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// xlsxColumn returns the column index, from 0, of a cell reference like
+// "AB12"
+func xlsxColumn(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+		// XLSX sheets have at most 16384 columns, XFD
+		if col > 16384 {
+			return 0, fmt.Errorf("cell reference %q out of range", ref)
+		}
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("cell reference %q has no column", ref)
+	}
+	return col - 1, nil
+}
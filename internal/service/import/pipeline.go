@@ -0,0 +1,792 @@
+package importservice
+
+import (
+	"cmp"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Pipeline stage names, used as the stage label of import_stage_duration_seconds
+const (
+	StageParse     = "parse"
+	StageNormalize = "normalize"
+	StageValidate  = "validate"
+	StageStage     = "stage"
+	StageDedup     = "dedup"
+	StageResolveFK = "resolve_fk"
+	StageInsert    = "insert"
+	StageAnalyze   = "analyze"
+	StageReport    = "report"
+)
+
+// RowFunc receives each record a Parser reads. rec is nil and parseErr set
+// when the row could not be parsed.
+type RowFunc[R any] func(row int, rec *R, raw string, parseErr *parsers.ParseError) error
+
+// errRowLimitReached stops the parser once a file has MaxRows data rows
+var errRowLimitReached = stderrors.New("row limit reached")
+
+// Parser reads the records of an import file
+type Parser[R any] interface {
+	Parse(file *os.File, fn RowFunc[R]) error
+}
+
+// parseRecords reads the records of resource from file with the parser
+// registered for its format
+func parseRecords[R any](file *os.File, resource models.ResourceType, opts parsers.ParserOptions, fn RowFunc[R]) error {
+	p, err := parsers.NewRecordParser(file, opts)
+	if err != nil {
+		return err
+	}
+	return p.Parse(resource, func(row int, record any, raw string, parseErr error) error {
+		rec, _ := record.(*R)
+		var perr *parsers.ParseError
+		if parseErr != nil && !stderrors.As(parseErr, &perr) {
+			perr = &parsers.ParseError{Code: errors.ErrCodeFileParseError, Err: parseErr}
+		}
+		return fn(row, rec, raw, perr)
+	})
+}
+
+// Normalizer turns a parsed record into its staging row
+type Normalizer[R, S any] interface {
+	// Normalize builds the staging row for rec. rec is nil for a row that
+	// failed to parse.
+	Normalize(jobID uuid.UUID, row int, rec *R) S
+	// Reject marks a staging row invalid with the reason
+	Reject(s *S, verr *errors.ValidationError)
+	// DedupKey is the key duplicates are detected by, or "" if the row has none
+	DedupKey(s *S) string
+}
+
+// Validator checks a parsed record, returning errors that reject it and
+// warnings that don't. It runs before Normalize, so it may fix up rec, such
+// as truncating a field, and warn about it.
+type Validator[R any] interface {
+	Validate(row int, rec *R) (errs, warnings []*errors.ValidationError)
+}
+
+// Stager stores staging rows until the file has been fully read
+type Stager[S any] interface {
+	Stage(ctx context.Context, jobID uuid.UUID, rows []S) error
+	// Valid calls fn with batches of the rows still valid after dedup and
+	// foreign key checks
+	Valid(ctx context.Context, jobID uuid.UUID, batchSize int, fn func([]S) error) error
+	// StagingID returns the ID a row was staged under, or 0 for a row kept
+	// in memory
+	StagingID(s *S) int64
+	// KeepCommitted keeps the staging rows an earlier run of the job wrote
+	// through staging ID through and drops the rest, returning the rows kept
+	KeepCommitted(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error)
+	Cleanup(ctx context.Context, jobID uuid.UUID) error
+}
+
+// Deduper marks staged rows that repeat an earlier row or an existing record
+type Deduper interface {
+	Dedup(ctx context.Context, job *models.Job) (inBatch, existing int, err error)
+}
+
+// BatchDeduper marks the rows of a batch about to be inserted that repeat a
+// record stored since Dedup ran, such as by a concurrent import. It runs in
+// the batch's transaction, once the resource is locked, so no other import
+// can store a clashing record before the batch is written.
+type BatchDeduper[S any] interface {
+	DedupBatch(ctx context.Context, rows []S) (int, error)
+}
+
+// FKResolver marks staged rows that reference records which don't exist
+type FKResolver interface {
+	ResolveFK(ctx context.Context, job *models.Job) (int, error)
+}
+
+// PlaceholderCreator is implemented by FK resolvers that can create
+// placeholder records for missing references instead of rejecting the rows
+type PlaceholderCreator interface {
+	// Placeholders returns how many placeholders ResolveFK created, or would
+	// have created for a job that only analyzes
+	Placeholders() int
+}
+
+// Inserter writes a batch of valid staging rows to the main table, returning
+// the IDs written and how many of them it inserted and updated
+type Inserter[S any] interface {
+	Insert(ctx context.Context, rows []S) (ids []uuid.UUID, inserted, updated int, err error)
+}
+
+// Analyzer sorts a batch of valid staging rows the way Insert would write
+// them, for imports that only analyze
+type Analyzer[S any] interface {
+	// Analyze returns how many of rows would create a record and how many
+	// would overwrite the existing record with their ID
+	Analyze(ctx context.Context, rows []S) (inserts, updates int, err error)
+}
+
+// Ledgerer reads staging rows for the import ledger
+type Ledgerer[S any] interface {
+	// LedgerEntry returns the natural key and content hash of a row the
+	// import would write, or ok false for any other row
+	LedgerEntry(s *S) (key, hash string, ok bool)
+	// Stored returns which of keys belong to a stored record
+	Stored(ctx context.Context, keys []string) (map[string]bool, error)
+	// MarkImported takes a row whose content the ledger recorded out of the
+	// import
+	MarkImported(s *S)
+}
+
+// checkCancelled returns an error once ctx is done, so the import loops stop
+// within a row or batch of a shutdown or cancellation instead of reading the
+// rest of the file. It wraps the cause, models.ErrJobCancelled when the job
+// itself was cancelled.
+func checkCancelled(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("import cancelled: %w", context.Cause(ctx))
+	}
+	return nil
+}
+
+// pipeline is the import of one resource, run by runPipeline as
+// Parse → Validate → Normalize → Stage → Dedup → ResolveFK → Insert → Report,
+// with Analyze in place of Insert for jobs that only analyze. fk may be nil
+// for resources without foreign keys, and batchDedup for resources without
+// a unique key besides their ID. ledger is set to nil when the import ledger
+// is off.
+type pipeline[R, S any] struct {
+	parser     Parser[R]
+	normalizer Normalizer[R, S]
+	validator  Validator[R]
+	stager     Stager[S]
+	deduper    Deduper
+	batchDedup BatchDeduper[S]
+	fk         FKResolver
+	inserter   Inserter[S]
+	analyzer   Analyzer[S]
+	ledger     Ledgerer[S]
+}
+
+// stageTimer accumulates the time spent in each stage. Row stages are
+// interleaved, so their time is summed across rows.
+type stageTimer struct {
+	totals map[string]time.Duration
+	order  []string
+}
+
+func newStageTimer() *stageTimer {
+	return &stageTimer{totals: make(map[string]time.Duration)}
+}
+
+// since adds the time elapsed from start to stage and returns now
+func (t *stageTimer) since(stage string, start time.Time) time.Time {
+	now := time.Now()
+	if _, ok := t.totals[stage]; !ok {
+		t.order = append(t.order, stage)
+	}
+	t.totals[stage] += now.Sub(start)
+	return now
+}
+
+// runPipeline imports file into job.Resource through p
+func runPipeline[R, S any](ctx context.Context, s *Service, job *models.Job, file *os.File, log zerolog.Logger, p pipeline[R, S]) (err error) {
+	// A cancelled import leaves nothing staged; the cleanup must outlive ctx
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			p.stager.Cleanup(context.WithoutCancel(ctx), job.ID)
+		}
+	}()
+
+	timer := newStageTimer()
+	defer func() {
+		ev := log.Info()
+		for _, stage := range timer.order {
+			d := timer.totals[stage].Seconds()
+			s.metrics.RecordImportStage(string(job.Resource), stage, d)
+			ev = ev.Float64(stage+"_seconds", d)
+		}
+		ev.Msg("Import stage timings")
+	}()
+	defer s.metrics.ClearJobRate(job.ID.String())
+
+	// The phase tells monitoring which stage a long import is in; the row
+	// stages run together and are reported as parse
+	setPhase := func(stage string) {
+		if err := s.jobRepo.SetPhase(ctx, job.ID, stage); err != nil {
+			log.Warn().Err(err).Str("phase", stage).Msg("Failed to record import phase")
+		}
+	}
+	setPhase(StageParse)
+
+	// Settings reloaded mid-import apply from the next job on
+	cfg := s.config.Load()
+	if s.ledger == nil || !cfg.Ledger {
+		p.ledger = nil
+	}
+	skipLedgered := p.ledger != nil && (job.Params == nil || !job.Params.IgnoreLedger)
+	committed, fromAttempt, err := keepCommitted(ctx, s, job, p.stager)
+	if err != nil {
+		return err
+	}
+	if len(committed) > 0 {
+		log.Info().Int("rows", len(committed)).Int("from_attempt", fromAttempt).Msg("Skipping rows an earlier run wrote")
+	}
+	stagingBatch := make([]S, 0, cfg.BatchSize)
+	dups := newDuplicateTracker(cfg.DedupExpectedRows)
+	var validationErrors []*errors.ValidationError
+	var warnings []*errors.ValidationError
+	totalRows := 0
+	validRows := 0
+	invalidRows := 0
+	alreadyImported := 0
+
+	// stage writes the staging batch, first taking out the rows whose
+	// content the ledger recorded
+	stage := func() error {
+		if skipLedgered {
+			skipped, err := skipImported(ctx, s, job, p.ledger, stagingBatch)
+			if err != nil {
+				return fmt.Errorf("failed to check the import ledger: %w", err)
+			}
+			validRows -= skipped
+			alreadyImported += skipped
+		}
+		if err := p.stager.Stage(ctx, job.ID, stagingBatch); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", job.Resource, err)
+		}
+		return nil
+	}
+
+	// The parser calls back for each row, so parse time is what is left of
+	// the gaps between rows once the other row stages are taken out
+	mark := time.Now()
+	parseStart := mark
+	limitRow := 0
+	processRow := func(row int, rec *R, raw string, parseErr *parsers.ParseError) error {
+		mark = timer.since(StageParse, mark)
+		if err := checkCancelled(ctx); err != nil {
+			return err
+		}
+		if cfg.MaxRows > 0 && totalRows >= cfg.MaxRows {
+			limitRow = row
+			return errRowLimitReached
+		}
+		totalRows++
+
+		if parseErr != nil || rec == nil {
+			staged := p.normalizer.Normalize(job.ID, row, nil)
+			verr := parseValidationError(row, raw, parseErr)
+			p.normalizer.Reject(&staged, verr)
+			validationErrors = append(validationErrors, verr)
+			invalidRows++
+			stagingBatch = append(stagingBatch, staged)
+			mark = timer.since(StageNormalize, mark)
+			return nil
+		}
+
+		errs, warns := p.validator.Validate(row, rec)
+		mark = timer.since(StageValidate, mark)
+
+		staged := p.normalizer.Normalize(job.ID, row, rec)
+		if committed[row] {
+			// An earlier run wrote the row, so it is counted but not
+			// staged again
+			warnings = append(warnings, warns...)
+			validRows++
+			dups.Seen(p.normalizer.DedupKey(&staged))
+			mark = timer.since(StageNormalize, mark)
+			return nil
+		}
+		if len(errs) > 0 {
+			p.normalizer.Reject(&staged, errs[0])
+			validationErrors = append(validationErrors, errs...)
+			invalidRows++
+		} else {
+			warnings = append(warnings, warns...)
+			validRows++
+		}
+		mark = timer.since(StageNormalize, mark)
+
+		stagingBatch = append(stagingBatch, staged)
+		dups.Seen(p.normalizer.DedupKey(&staged))
+
+		if len(stagingBatch) >= cfg.BatchSize {
+			if err := stage(); err != nil {
+				return err
+			}
+			stagingBatch = stagingBatch[:0]
+
+			s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, validRows, invalidRows)
+			s.recordRate(job, totalRows, parseStart)
+			if err := s.trackDuplicates(ctx, job, totalRows, dups); err != nil {
+				p.stager.Cleanup(ctx, job.ID)
+				return err
+			}
+		}
+		mark = timer.since(StageStage, mark)
+
+		return nil
+	}
+
+	if err := p.parser.Parse(file, processRow); err != nil && !stderrors.Is(err, errRowLimitReached) {
+		return err
+	}
+	mark = timer.since(StageParse, mark)
+
+	if limitRow > 0 {
+		warn, err := s.checkMaxRows(ctx, job, limitRow)
+		if err != nil {
+			p.stager.Cleanup(ctx, job.ID)
+			return err
+		}
+		warnings = append(warnings, warn)
+	}
+
+	if len(stagingBatch) > 0 {
+		if err := stage(); err != nil {
+			return err
+		}
+	}
+	mark = timer.since(StageStage, mark)
+
+	s.jobRepo.SetTotalRecords(ctx, job.ID, totalRows)
+
+	if err := s.checkMinRows(ctx, job, totalRows); err != nil {
+		p.stager.Cleanup(ctx, job.ID)
+		return err
+	}
+
+	log.Info().
+		Int("total_rows", totalRows).
+		Int("initial_valid", validRows).
+		Int("initial_invalid", invalidRows).
+		Msg("First pass complete, checking duplicates")
+
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+	setPhase(StageDedup)
+	dupInBatch, dupAgainstExisting, err := p.deduper.Dedup(ctx, job)
+	if err != nil {
+		return fmt.Errorf("failed to mark duplicates: %w", err)
+	}
+	s.jobRepo.SetDuplicateRecords(ctx, job.ID, dupInBatch+dupAgainstExisting)
+	mark = timer.since(StageDedup, mark)
+
+	invalidFKs, placeholders := 0, 0
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+	if p.fk != nil {
+		setPhase(StageResolveFK)
+		invalidFKs, err = p.fk.ResolveFK(ctx, job)
+		if err != nil {
+			return fmt.Errorf("failed to check foreign keys: %w", err)
+		}
+		if pc, ok := p.fk.(PlaceholderCreator); ok {
+			placeholders = pc.Placeholders()
+		}
+		mark = timer.since(StageResolveFK, mark)
+	}
+
+	log.Info().
+		Int("duplicates_in_batch", dupInBatch).
+		Int("duplicates_existing", dupAgainstExisting).
+		Int("invalid_fks", invalidFKs).
+		Int("placeholders", placeholders).
+		Msg("Validation and deduplication complete")
+
+	// An analyze job stops short of writing and reports what it would write
+	failed := totalRows
+	var analysis *models.ImportAnalysis
+	if job.Params != nil && job.Params.Analyze {
+		setPhase(StageAnalyze)
+		if analysis, err = analyzeImport(ctx, s, job, p, cfg.BatchSize, log); err != nil {
+			return err
+		}
+		analysis.Skipped = totalRows - analysis.Inserts - analysis.Updates - alreadyImported
+		failed = analysis.Skipped
+		mark = timer.since(StageAnalyze, mark)
+
+		log.Info().
+			Int("inserts", analysis.Inserts).
+			Int("updates", analysis.Updates).
+			Int("skipped", analysis.Skipped).
+			Msg("Import analyzed")
+	}
+
+	// Second pass: insert valid records to the main table
+	successfulInserts, inserted, updated := 0, 0, 0
+	if analysis == nil {
+		setPhase(StageInsert)
+		lateDuplicates := 0
+		inserted, updated, lateDuplicates, err = insertValid(ctx, s, job, p, cfg.BatchSize, fromAttempt, log)
+		successfulInserts = inserted + updated
+		if err != nil {
+			if models.JobCancelled(ctx) {
+				// A cancelled job keeps the count of the rows written before
+				// it stopped
+				s.jobRepo.UpdateProgress(context.WithoutCancel(ctx), job.ID, totalRows, successfulInserts, invalidRows)
+			}
+			return err
+		}
+		if lateDuplicates > 0 {
+			s.jobRepo.SetDuplicateRecords(ctx, job.ID, dupInBatch+dupAgainstExisting+lateDuplicates)
+		}
+		failed = totalRows - successfulInserts - alreadyImported
+		mark = timer.since(StageInsert, mark)
+	}
+	allImported := totalRows > 0 && alreadyImported == totalRows
+	if allImported {
+		log.Info().Int("rows", totalRows).Msg("All records already present, nothing written")
+	}
+
+	setPhase(StageReport)
+	suppressed := s.recordValidationErrors(ctx, job, validationErrors)
+	s.recordSummary(ctx, job, validationErrors, warnings, models.JobSummary{
+		Analysis:           analysis,
+		SuppressedErrors:   suppressed,
+		PlaceholderAuthors: placeholders,
+		AlreadyImported:    alreadyImported,
+		AllAlreadyImported: allImported,
+		Inserted:           inserted,
+		Updated:            updated,
+	})
+	s.recordWarnings(ctx, job.ID, warnings)
+	p.stager.Cleanup(ctx, job.ID)
+	s.jobRepo.UpdateProgress(ctx, job.ID, totalRows, successfulInserts, failed)
+	timer.since(StageReport, mark)
+
+	return nil
+}
+
+// insertValid writes the valid staging rows of job to the main table and
+// returns the number of records inserted and updated and of rows found to be
+// duplicates only at insert time, also when it fails part way. Each batch is
+// checked and written in one transaction holding the resource's import lock,
+// which records it in the batch log when the service has one; the counts
+// are then read back from the log, so they are those of the batches that
+// committed, from fromAttempt on when earlier runs wrote rows this one
+// skipped. With the circuit breaker on, a failed batch is retried until the
+// breaker opens, which pauses the import.
+func insertValid[R, S any](ctx context.Context, s *Service, job *models.Job, p pipeline[R, S], batchSize, fromAttempt int, log zerolog.Logger) (inserted, updated, lateDuplicates int, err error) {
+	attempt, batchNo := 0, 0
+	if s.batchLog != nil {
+		if attempt, err = s.batchLog.NextAttempt(ctx, job.ID); err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to read the batch log: %w", err)
+		}
+		if fromAttempt == 0 {
+			fromAttempt = attempt
+		}
+		defer func() {
+			// The log outlives a cancellation, so a cancelled job still
+			// counts what it wrote
+			i, u, terr := s.batchLog.Totals(context.WithoutCancel(ctx), job.ID, fromAttempt)
+			if terr != nil {
+				log.Warn().Err(terr).Msg("Failed to read the batch log, keeping the counts tallied in memory")
+				return
+			}
+			inserted, updated = i, u
+		}()
+	}
+
+	successfulInserts := 0
+	insertStart := time.Now()
+	batchLog := logger.Hot(log)
+	cfg := s.config.Load()
+	err = p.stager.Valid(ctx, job.ID, batchSize, func(batch []S) error {
+		if err := checkCancelled(ctx); err != nil {
+			return err
+		}
+		// A retry starts from the rows as they were before the failed
+		// attempt marked any of them
+		var original []S
+		if cfg.BreakerThreshold > 0 {
+			original = slices.Clone(batch)
+		}
+		batchStart := time.Now()
+		var ids []uuid.UUID
+		var ins, upd, late int
+		for {
+			err := s.inTx(ctx, importLockKey(job.Resource), func(ctx context.Context) error {
+				var err error
+				if p.batchDedup != nil {
+					if late, err = p.batchDedup.DedupBatch(ctx, batch); err != nil {
+						return err
+					}
+				}
+				if ids, ins, upd, err = p.inserter.Insert(ctx, batch); err != nil {
+					return err
+				}
+				if p.ledger != nil {
+					if err := recordImported(ctx, s, job, p.ledger, batch); err != nil {
+						return err
+					}
+				}
+				if s.batchLog == nil || len(ids) == 0 {
+					return nil
+				}
+				return s.batchLog.Record(ctx, batchCommit(job.ID, attempt, batchNo+1, p.stager, batch, ins, upd))
+			})
+			if err == nil || cfg.BreakerThreshold <= 0 {
+				if err != nil {
+					return fmt.Errorf("failed to insert %s batch: %w", job.Resource, err)
+				}
+				s.breaker.success()
+				s.metrics.SetImportBreakerOpen(false)
+				break
+			}
+			if err := checkCancelled(ctx); err != nil {
+				return err
+			}
+			failures, open := s.breaker.failure(err, cfg.BreakerThreshold)
+			if open {
+				s.metrics.SetImportBreakerOpen(true)
+				return fmt.Errorf("%w: %d batch inserts failed in a row, last on %s: %v", ErrPaused, failures, job.Resource, err)
+			}
+			log.Warn().Err(err).
+				Int("consecutive_failures", failures).
+				Int("threshold", cfg.BreakerThreshold).
+				Dur("retry_in", cfg.BreakerRetryDelay).
+				Msg("Import batch insert failed, retrying")
+			if err := sleepCtx(ctx, cfg.BreakerRetryDelay); err != nil {
+				return err
+			}
+			copy(batch, original)
+		}
+		lateDuplicates += late
+		if len(ids) == 0 {
+			return nil
+		}
+		batchNo++
+		inserted += ins
+		updated += upd
+		count := ins + upd
+		successfulInserts += count
+		s.metrics.RecordImportBatch(string(job.Resource), time.Since(batchStart).Seconds())
+		s.recordRate(job, successfulInserts, insertStart)
+		batchLog.Debug().
+			Int("inserted", count).
+			Int("total_inserted", successfulInserts).
+			Dur("duration", time.Since(batchStart)).
+			Msg("Import batch inserted")
+		s.hooks.OnBatchInserted(ctx, job, job.Resource, ids)
+		return nil
+	})
+	return inserted, updated, lateDuplicates, err
+}
+
+// keepCommitted keeps the staging rows earlier runs of job wrote, those up
+// to the last staging ID in its batch log, and drops the rest of what they
+// staged. It returns the row numbers kept and the first attempt whose
+// batches wrote them, or 0 when none were kept. Rows written on the fast
+// path have no staging IDs, so they are written again.
+func keepCommitted[S any](ctx context.Context, s *Service, job *models.Job, stager Stager[S]) (map[int]bool, int, error) {
+	var commits []*models.BatchCommit
+	if s.batchLog != nil {
+		var err error
+		if commits, err = s.batchLog.ListByJob(ctx, job.ID); err != nil {
+			return nil, 0, fmt.Errorf("failed to read the batch log: %w", err)
+		}
+	}
+	var through int64
+	for _, c := range commits {
+		if c.LastStagingID != nil {
+			through = max(through, *c.LastStagingID)
+		}
+	}
+	kept, err := stager.KeepCommitted(ctx, job.ID, through)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to keep committed %s: %w", job.Resource, err)
+	}
+	if len(kept) == 0 {
+		return nil, 0, nil
+	}
+
+	rows := make(map[int]bool, len(kept))
+	for _, k := range kept {
+		rows[k.RowNumber] = true
+	}
+	// kept is in staging ID order, so a batch's rows are found by its first ID
+	fromAttempt := 0
+	for _, c := range commits {
+		if c.FirstStagingID == nil || fromAttempt != 0 && c.Attempt >= fromAttempt {
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(kept, *c.FirstStagingID, func(k repository.CommittedRow, id int64) int {
+			return cmp.Compare(k.StagingID, id)
+		})
+		if i < len(kept) && kept[i].StagingID <= *c.LastStagingID {
+			fromAttempt = c.Attempt
+		}
+	}
+	return rows, fromAttempt, nil
+}
+
+// batchCommit builds the batch log row of batch, bounding its staged rows by
+// their staging IDs
+func batchCommit[S any](jobID uuid.UUID, attempt, batchNo int, stager Stager[S], batch []S, inserted, updated int) *models.BatchCommit {
+	commit := &models.BatchCommit{JobID: jobID, Attempt: attempt, BatchNo: batchNo, Rows: len(batch), Inserted: inserted, Updated: updated}
+	for i := range batch {
+		id := stager.StagingID(&batch[i])
+		if id == 0 {
+			continue
+		}
+		if commit.FirstStagingID == nil || id < *commit.FirstStagingID {
+			commit.FirstStagingID = &id
+		}
+		if commit.LastStagingID == nil || id > *commit.LastStagingID {
+			last := id
+			commit.LastStagingID = &last
+		}
+	}
+	return commit
+}
+
+// recordRate records the rate of job as the rows its current stage has
+// handled since start
+func (s *Service) recordRate(job *models.Job, rows int, start time.Time) {
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		s.metrics.RecordImportRate(string(job.Resource), job.ID.String(), float64(rows)/elapsed)
+	}
+}
+
+// importLockKey is the lock imports of resource take while they write a
+// batch
+func importLockKey(resource models.ResourceType) string {
+	return "import:" + string(resource)
+}
+
+// inTx runs fn in a transaction holding the lock on key, or directly when
+// the service has no database to start one
+func (s *Service) inTx(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	if s.db == nil {
+		return fn(ctx)
+	}
+	return s.db.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.db.Lock(ctx, key); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+// analyzeImport counts the inserts and updates the valid staging rows of job
+// would make, and sizes the table before and after them. A table that can't
+// be sized is left out of the analysis.
+func analyzeImport[R, S any](ctx context.Context, s *Service, job *models.Job, p pipeline[R, S], batchSize int, log zerolog.Logger) (*models.ImportAnalysis, error) {
+	analysis := &models.ImportAnalysis{}
+	err := p.stager.Valid(ctx, job.ID, batchSize, func(batch []S) error {
+		if err := checkCancelled(ctx); err != nil {
+			return err
+		}
+		inserts, updates, err := p.analyzer.Analyze(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to analyze %s batch: %w", job.Resource, err)
+		}
+		analysis.Inserts += inserts
+		analysis.Updates += updates
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.db != nil {
+		table, err := s.db.TableSize(ctx, job.Resource)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to size table for import analysis")
+			return analysis, nil
+		}
+		analysis.Table = table
+		analysis.Estimated = table.Grow(analysis.Inserts)
+	}
+	return analysis, nil
+}
+
+// analyzeRows implements Analyzer for rows that Insert writes when writable,
+// updating the record with their key when one exists. IDs are looked up in
+// their canonical form.
+func analyzeRows[S any](ctx context.Context, rows []S, writable func(*S) bool, key func(*S) *string, existingKeys func(context.Context, []string) (map[string]bool, error)) (int, int, error) {
+	existing, err := existingKeys(ctx, collectKeys(rows, writable, key))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	inserts, updates := 0, 0
+	for i := range rows {
+		if !writable(&rows[i]) {
+			continue
+		}
+		if k := key(&rows[i]); k != nil {
+			canonical := *k
+			if parsed, err := uuid.Parse(canonical); err == nil {
+				canonical = parsed.String()
+			}
+			if existing[canonical] {
+				updates++
+				continue
+			}
+		}
+		inserts++
+	}
+	return inserts, updates, nil
+}
+
+// analyzeOverwrites implements Analyzer for rows that update the record
+// with their natural key when one exists, and otherwise the record with
+// their ID
+func analyzeOverwrites[S any](ctx context.Context, rows []S, writable func(*S) bool, natural, id func(*S) *string, existingNatural, existingIDs func(context.Context, []string) (map[string]bool, error)) (int, int, error) {
+	taken, err := existingNatural(ctx, collectKeys(rows, writable, natural))
+	if err != nil {
+		return 0, 0, err
+	}
+	conflicting := func(row *S) bool {
+		k := natural(row)
+		return writable(row) && k != nil && taken[*k]
+	}
+
+	inserts, updates, err := analyzeRows(ctx, rows, func(row *S) bool { return writable(row) && !conflicting(row) }, id, existingIDs)
+	for i := range rows {
+		if conflicting(&rows[i]) {
+			updates++
+		}
+	}
+	return inserts, updates, err
+}
+
+// conflictsWith reports whether a row with id, nil when it has none,
+// conflicts on its natural key with the stored record storedID rather than
+// being that record
+func conflictsWith(id *string, storedID uuid.UUID) bool {
+	if id == nil {
+		return true
+	}
+	parsed, err := uuid.Parse(*id)
+	return err != nil || parsed != storedID
+}
+
+// upsertKey is the key job matches rows to stored records on
+func upsertKey(job *models.Job) models.UpsertKey {
+	if job.Params != nil && job.Params.UpsertKey != "" {
+		return job.Params.UpsertKey
+	}
+	return models.UpsertKeyID
+}
+
+// onConflict is what job does with rows whose natural key belongs to
+// another stored record
+func onConflict(job *models.Job) models.OnConflict {
+	if job.Params != nil && job.Params.OnConflict != "" {
+		return job.Params.OnConflict
+	}
+	return models.OnConflictSkip
+}
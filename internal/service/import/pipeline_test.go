@@ -0,0 +1,131 @@
+package importservice
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	"github.com/rohit/bulk-import-export/internal/service/validation"
+)
+
+func writeTempFile(t *testing.T, name, content string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestUserStages_ParseReportsBadRows(t *testing.T) {
+	file := writeTempFile(t, "users.ndjson", `{"email":"a@example.com","name":"A","role":"admin"}
+not json
+`)
+	stages := &userStages{encoding: parsers.EncodingAuto}
+
+	var rows []int
+	var failed []int
+	err := stages.Parse(file, func(row int, rec *models.UserImport, raw string, parseErr *parsers.ParseError) error {
+		rows = append(rows, row)
+		if rec == nil || parseErr != nil {
+			failed = append(failed, row)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if len(rows) != 2 || len(failed) != 1 || failed[0] != rows[1] {
+		t.Errorf("Parse() rows = %v, failed = %v, want the second row to fail", rows, failed)
+	}
+}
+
+func TestUserStages_NormalizeAndValidate(t *testing.T) {
+	stages := &userStages{validator: validation.NewUserValidator()}
+	jobID := uuid.New()
+
+	rec := &models.UserImport{Email: "  Ann@Example.COM ", Name: "Ann", Role: "ADMIN", Active: "TRUE"}
+	staged := stages.Normalize(jobID, 3, rec)
+	if !staged.IsValid || staged.JobID != jobID || staged.RowNumber != 3 {
+		t.Fatalf("Normalize() = %+v", staged)
+	}
+	if *staged.Email != "ann@example.com" || *staged.Role != "admin" || !*staged.Active {
+		t.Errorf("Normalize() email=%q role=%q active=%v", *staged.Email, *staged.Role, *staged.Active)
+	}
+	if key := stages.DedupKey(&staged); key != "ann@example.com" {
+		t.Errorf("DedupKey() = %q", key)
+	}
+
+	errs, _ := stages.Validate(4, &models.UserImport{Email: "not-an-email", Name: "Bob", Role: "admin"})
+	if len(errs) == 0 {
+		t.Fatal("Validate() expected errors for a bad email")
+	}
+	stages.Reject(&staged, errs[0])
+	if staged.IsValid || staged.ValidationError == nil {
+		t.Errorf("Reject() left row valid: %+v", staged)
+	}
+}
+
+func TestArticleStages_NormalizeSlug(t *testing.T) {
+	stages := &articleStages{}
+	staged := stages.Normalize(uuid.New(), 1, &models.ArticleImport{Slug: " My First Post ", Status: "Draft"})
+	if *staged.Slug != "my-first-post" || *staged.Status != "draft" {
+		t.Errorf("Normalize() slug=%q status=%q", *staged.Slug, *staged.Status)
+	}
+}
+
+func TestCommentStages_NormalizeUnparsedRow(t *testing.T) {
+	stages := &commentStages{}
+	staged := stages.Normalize(uuid.New(), 7, nil)
+	if staged.IsValid || staged.RowNumber != 7 || stages.DedupKey(&staged) != "" {
+		t.Errorf("Normalize(nil) = %+v, want an invalid row with no dedup key", staged)
+	}
+}
+
+func TestStageTimer_KeepsFirstSeenOrder(t *testing.T) {
+	timer := newStageTimer()
+	start := time.Now().Add(-time.Millisecond)
+	timer.since(StageParse, start)
+	timer.since(StageValidate, start)
+	timer.since(StageParse, start)
+
+	if len(timer.order) != 2 || timer.order[0] != StageParse || timer.order[1] != StageValidate {
+		t.Errorf("order = %v", timer.order)
+	}
+	if timer.totals[StageParse] < 2*time.Millisecond {
+		t.Errorf("parse total = %v, want both calls summed", timer.totals[StageParse])
+	}
+}
+
+func TestCountRows_SkipsHeaderAndBlankLines(t *testing.T) {
+	s := &Service{}
+	s.config.Store(&config.ImportConfig{})
+	csvFile := writeTempFile(t, "users.csv", "email,name\na@example.com,A\n\nb@example.com,B\n")
+	ndjsonFile := writeTempFile(t, "users.ndjson", "{\"email\":\"a@example.com\"}\n  \n{\"email\":\"b@example.com\"}")
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{csvFile.Name(), 2},
+		{ndjsonFile.Name(), 2},
+	} {
+		got, err := s.CountRows(tc.path)
+		if err != nil {
+			t.Fatalf("CountRows(%s) error: %v", tc.path, err)
+		}
+		if got != tc.want {
+			t.Errorf("CountRows(%s) = %d, want %d", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package importservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+)
+
+// Resumable uploads send a file in numbered parts, each of which can be sent
+// again until it arrives. Sessions and the parts received are recorded in
+// the database, so any instance sharing the upload path can take the next
+// part or complete the upload after a restart.
+
+// uploadSessionsDir holds a directory of parts per session, under the upload
+// path
+const uploadSessionsDir = "sessions"
+
+// MaxUploadParts is the highest part number of a resumable upload
+const MaxUploadParts = 10000
+
+// Errors of resumable uploads
+var (
+	// ErrUploadSessionsDisabled is returned while no UploadSessionRepository
+	// is set
+	ErrUploadSessionsDisabled = stderrors.New("resumable uploads are not available")
+	// ErrUploadSessionNotFound is returned for an unknown session, or one
+	// started by another tenant
+	ErrUploadSessionNotFound = stderrors.New("upload session not found")
+	// ErrUploadSessionClosed is returned for a session that was completed
+	// or has expired
+	ErrUploadSessionClosed = stderrors.New("upload session is no longer open")
+	// ErrUploadPartTooLarge is returned for a part over IMPORT_UPLOAD_PART_MAX_MB,
+	// or one taking the upload over MAX_FILE_SIZE_MB
+	ErrUploadPartTooLarge = stderrors.New("upload part too large")
+	// ErrUploadPartEmpty is returned for a part with no data
+	ErrUploadPartEmpty = stderrors.New("upload part is empty")
+	// ErrUploadIncomplete wraps the error of completing a session with
+	// parts missing
+	ErrUploadIncomplete = stderrors.New("upload is incomplete")
+)
+
+// SetUploadSessions sets the repository resumable upload sessions are kept
+// in; without one they are unavailable
+func (s *Service) SetUploadSessions(sessions repository.UploadSessionRepository) {
+	s.uploadSessions = sessions
+}
+
+// sessionDir is the directory the parts of session id are saved in
+func (s *Service) sessionDir(id uuid.UUID) string {
+	return filepath.Join(s.config.Load().UploadPath, uploadSessionsDir, id.String())
+}
+
+// partPath is the file part n of the session in dir is saved as
+func partPath(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d.part", n))
+}
+
+// StartUpload opens a resumable upload of fileName for tenantID. Sessions
+// that have expired are removed first.
+func (s *Service) StartUpload(ctx context.Context, tenantID, fileName string) (*models.UploadSession, error) {
+	if s.uploadSessions == nil {
+		return nil, ErrUploadSessionsDisabled
+	}
+	s.purgeExpiredUploads(ctx)
+
+	now := time.Now().UTC()
+	session := &models.UploadSession{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		FileName:  SanitizeFilename(fileName),
+		Status:    models.UploadSessionOpen,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.config.Load().UploadSessionTTL),
+	}
+	if err := s.uploadSessions.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return session, nil
+}
+
+// purgeExpiredUploads removes expired sessions and their parts. Failures
+// are logged; the sessions are tried again by the next purge.
+func (s *Service) purgeExpiredUploads(ctx context.Context) {
+	ids, err := s.uploadSessions.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to remove expired upload sessions")
+		return
+	}
+	for _, id := range ids {
+		if err := os.RemoveAll(s.sessionDir(id)); err != nil {
+			s.logger.Warn().Err(err).Str("upload_id", id.String()).Msg("Failed to remove expired upload parts")
+		}
+	}
+}
+
+// GetUpload returns tenantID's session id and the parts received for it
+func (s *Service) GetUpload(ctx context.Context, tenantID string, id uuid.UUID) (*models.UploadSession, []*models.UploadPart, error) {
+	if s.uploadSessions == nil {
+		return nil, nil, ErrUploadSessionsDisabled
+	}
+	session, err := s.uploadSessions.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	if session == nil || session.TenantID != tenantID {
+		return nil, nil, ErrUploadSessionNotFound
+	}
+	parts, err := s.uploadSessions.ListParts(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list upload parts: %w", err)
+	}
+	return session, parts, nil
+}
+
+// openSession is GetUpload for a session that must still take parts
+func (s *Service) openSession(ctx context.Context, tenantID string, id uuid.UUID) (*models.UploadSession, []*models.UploadPart, error) {
+	session, parts, err := s.GetUpload(ctx, tenantID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.Status != models.UploadSessionOpen || time.Now().After(session.ExpiresAt) {
+		return session, parts, ErrUploadSessionClosed
+	}
+	return session, parts, nil
+}
+
+// PutUploadPart saves r as part n of tenantID's session id, replacing any
+// part n received before. verify is called once r is read, to check it
+// against a digest the client sent; the part is discarded if it fails.
+func (s *Service) PutUploadPart(ctx context.Context, tenantID string, id uuid.UUID, n int, r io.Reader, verify func() error) (*models.UploadPart, error) {
+	if n < 1 || n > MaxUploadParts {
+		return nil, errors.ErrInvalidRequest(fmt.Sprintf("part number must be between 1 and %d", MaxUploadParts))
+	}
+	_, parts, err := s.openSession(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A part may take the upload up to the file size limit
+	cfg := s.config.Load()
+	limit := int64(cfg.UploadPartMaxMB) * 1024 * 1024
+	remaining := int64(cfg.MaxFileSizeMB) * 1024 * 1024
+	for _, part := range parts {
+		if part.PartNumber != n {
+			remaining -= part.SizeBytes
+		}
+	}
+	limit = min(limit, remaining)
+
+	dir := s.sessionDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create part file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, max(limit, 0)+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save part: %w", err)
+	}
+	if size > limit {
+		return nil, ErrUploadPartTooLarge
+	}
+	if size == 0 {
+		return nil, ErrUploadPartEmpty
+	}
+	if verify != nil {
+		if err := verify(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), partPath(dir, n)); err != nil {
+		return nil, fmt.Errorf("failed to save part: %w", err)
+	}
+	part := &models.UploadPart{
+		SessionID:  id,
+		PartNumber: n,
+		SizeBytes:  size,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+	}
+	if err := s.uploadSessions.PutPart(ctx, part); err != nil {
+		return nil, fmt.Errorf("failed to record part: %w", err)
+	}
+	return part, nil
+}
+
+// OpenUpload returns tenantID's open session id and a reader of its parts
+// in order, failing with ErrUploadIncomplete unless parts 1 to the last
+// received have all arrived
+func (s *Service) OpenUpload(ctx context.Context, tenantID string, id uuid.UUID) (*models.UploadSession, io.ReadCloser, error) {
+	session, parts, err := s.openSession(ctx, tenantID, id)
+	if err != nil {
+		return session, nil, err
+	}
+	if len(parts) == 0 {
+		return session, nil, fmt.Errorf("%w: no parts received", ErrUploadIncomplete)
+	}
+	for i, part := range parts {
+		if part.PartNumber != i+1 {
+			return session, nil, fmt.Errorf("%w: part %d is missing", ErrUploadIncomplete, i+1)
+		}
+	}
+
+	dir := s.sessionDir(id)
+	files := make(partFiles, 0, len(parts))
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		f, err := os.Open(partPath(dir, part.PartNumber))
+		if err != nil {
+			files.Close()
+			return session, nil, fmt.Errorf("failed to open part %d: %w", part.PartNumber, err)
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+	return session, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(readers...), files}, nil
+}
+
+// partFiles closes the part files of an upload being assembled
+type partFiles []*os.File
+
+func (p partFiles) Close() error {
+	for _, f := range p {
+		f.Close()
+	}
+	return nil
+}
+
+// CompleteUpload marks session id completed by jobID and removes its parts.
+// It returns false, keeping the parts, when the session was already
+// completed, such as by a concurrent request.
+func (s *Service) CompleteUpload(ctx context.Context, id, jobID uuid.UUID) (bool, error) {
+	if s.uploadSessions == nil {
+		return false, ErrUploadSessionsDisabled
+	}
+	ok, err := s.uploadSessions.Complete(ctx, id, jobID)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := os.RemoveAll(s.sessionDir(id)); err != nil {
+		s.logger.Warn().Err(err).Str("upload_id", id.String()).Msg("Failed to remove upload parts")
+	}
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package importservice
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestUploadSessions_Expiry(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	cfg := svc.config.Load()
+	cfg.UploadPath = t.TempDir()
+	cfg.MaxFileSizeMB, cfg.UploadPartMaxMB = 1, 1
+	ctx := context.Background()
+
+	if _, err := svc.StartUpload(ctx, "acme", "users.csv"); !errors.Is(err, ErrUploadSessionsDisabled) {
+		t.Fatalf("StartUpload() without a repository error = %v, want ErrUploadSessionsDisabled", err)
+	}
+	svc.SetUploadSessions(memory.NewUploadSessionRepository(db))
+
+	cfg.UploadSessionTTL = time.Millisecond
+	expired, err := svc.StartUpload(ctx, "acme", "users.csv")
+	if err != nil {
+		t.Fatalf("StartUpload() error: %v", err)
+	}
+	if _, err := svc.PutUploadPart(ctx, "acme", expired.ID, 1, strings.NewReader("id\n"), nil); err != nil {
+		t.Fatalf("PutUploadPart() error: %v", err)
+	}
+	if _, _, err := svc.GetUpload(ctx, "other", expired.ID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("GetUpload() by another tenant error = %v, want ErrUploadSessionNotFound", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := svc.PutUploadPart(ctx, "acme", expired.ID, 2, strings.NewReader("x"), nil); !errors.Is(err, ErrUploadSessionClosed) {
+		t.Errorf("PutUploadPart() after expiry error = %v, want ErrUploadSessionClosed", err)
+	}
+
+	// The next session started removes the expired one and its parts
+	cfg.UploadSessionTTL = time.Hour
+	if _, err := svc.StartUpload(ctx, "acme", "users.csv"); err != nil {
+		t.Fatalf("StartUpload() error: %v", err)
+	}
+	if _, _, err := svc.GetUpload(ctx, "acme", expired.ID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("GetUpload() of the expired session error = %v, want ErrUploadSessionNotFound", err)
+	}
+	if _, err := os.Stat(svc.sessionDir(expired.ID)); !os.IsNotExist(err) {
+		t.Errorf("parts of the expired session remain: %v", err)
+	}
+}
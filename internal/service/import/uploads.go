@@ -0,0 +1,162 @@
+package importservice
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+)
+
+// Upload partitioning schemes for UploadPartition
+const (
+	// UploadPartitionDate groups job directories by day: 2024/03/15/<job-id>
+	UploadPartitionDate = "date"
+	// UploadPartitionJob groups job directories by the first two characters
+	// of the job ID: 3f/<job-id>
+	UploadPartitionJob = "job"
+	// UploadPartitionNone puts every job directory in the upload path
+	UploadPartitionNone = "none"
+)
+
+// maxUploadNameLength caps a sanitized upload file name, in bytes
+const maxUploadNameLength = 128
+
+// defaultUploadName names an upload whose client file name has nothing usable
+const defaultUploadName = "upload"
+
+// SanitizeFilename reduces a client supplied file name to a safe base name:
+// any directory part is dropped, characters other than ASCII letters and
+// digits, '.', '-' and '_' become '_', leading dots are removed and the name is cut to
+// maxUploadNameLength keeping its extension
+func SanitizeFilename(name string) string {
+	// Clients may send either separator whatever the server's OS
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name = strings.TrimLeft(b.String(), ".")
+	if strings.Trim(name, "_.") == "" {
+		return defaultUploadName
+	}
+
+	if len(name) > maxUploadNameLength {
+		// users.ndjson.gz keeps both extensions, which name its format
+		inner := parsers.TrimCompressedExt(name)
+		ext := filepath.Ext(inner) + name[len(inner):]
+		if len(ext) > maxUploadNameLength/2 {
+			ext = ""
+		}
+		name = name[:maxUploadNameLength-len(ext)] + ext
+	}
+	return name
+}
+
+// uploadDir returns the directory an upload for jobID is saved in
+func (s *Service) uploadDir(jobID uuid.UUID, now time.Time) string {
+	cfg := s.config.Load()
+	switch cfg.UploadPartition {
+	case UploadPartitionJob:
+		return filepath.Join(cfg.UploadPath, jobID.String()[:2], jobID.String())
+	case UploadPartitionNone:
+		return filepath.Join(cfg.UploadPath, jobID.String())
+	default:
+		return filepath.Join(cfg.UploadPath, now.UTC().Format("2006/01/02"), jobID.String())
+	}
+}
+
+// SaveUploadedFile saves an uploaded file for jobID in a directory of its
+// own, under its sanitized client file name
+func (s *Service) SaveUploadedFile(jobID uuid.UUID, file io.Reader, filename string) (string, error) {
+	dir := s.uploadDir(jobID, time.Now())
+	filePath := filepath.Join(dir, SanitizeFilename(filename))
+
+	// Create file. A cleanup may prune an empty partition between creating
+	// the directory and the file, so that is tried twice.
+	var dst *os.File
+	var err error
+	for try := 0; try < 2; try++ {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create upload directory: %w", err)
+		}
+		if dst, err = os.Create(filePath); !os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		s.RemoveUpload(filePath)
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer dst.Close()
+
+	// Copy content
+	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		s.RemoveUpload(filePath)
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// RemoveUpload deletes a saved upload with its job directory. The directory
+// is first renamed aside so it disappears in one step, then emptied; the
+// partition directories above it are removed once empty. A file outside a
+// job directory, such as one saved before uploads were partitioned, is
+// removed on its own.
+func (s *Service) RemoveUpload(filePath string) error {
+	if filePath == "" {
+		return nil
+	}
+	dir := filepath.Dir(filePath)
+	if _, err := uuid.Parse(filepath.Base(dir)); err != nil || !s.inUploadPath(dir) {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	doomed := filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".deleting")
+	if err := os.Rename(dir, doomed); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
+	if err := os.RemoveAll(doomed); err != nil {
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
+	s.pruneUploadDirs(filepath.Dir(dir))
+	return nil
+}
+
+// inUploadPath reports whether dir is below the upload path
+func (s *Service) inUploadPath(dir string) bool {
+	rel, err := filepath.Rel(s.config.Load().UploadPath, dir)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
+
+// pruneUploadDirs removes dir and its parents up to the upload path while
+// they are empty. os.Remove fails on a directory that isn't, so a partition
+// another upload is being saved to is left alone.
+func (s *Service) pruneUploadDirs(dir string) {
+	for s.inUploadPath(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
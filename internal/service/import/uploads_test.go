@@ -0,0 +1,104 @@
+package importservice
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users.csv", "users.csv"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\ann\Q1 report (final).csv`, "Q1_report__final_.csv"},
+		{".hidden.ndjson", "hidden.ndjson"},
+		{"naïve\x00name.csv", "na_ve_name.csv"},
+		{"..", "upload"},
+		{"", "upload"},
+		{strings.Repeat("a", 300) + ".ndjson", strings.Repeat("a", 121) + ".ndjson"},
+		{strings.Repeat("a", 300) + ".ndjson.gz", strings.Repeat("a", 118) + ".ndjson.gz"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.name); got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSaveUploadedFile_Partitions(t *testing.T) {
+	jobID := uuid.MustParse("3fa85f64-5717-4562-b3fc-2c963f66afa6")
+	for _, partition := range []string{UploadPartitionDate, UploadPartitionJob, UploadPartitionNone} {
+		t.Run(partition, func(t *testing.T) {
+			uploads := t.TempDir()
+			s := &Service{}
+			s.config.Store(&config.ImportConfig{UploadPath: uploads, UploadPartition: partition})
+
+			filePath, err := s.SaveUploadedFile(jobID, strings.NewReader("id\n1\n"), "../my users.csv")
+			if err != nil {
+				t.Fatalf("SaveUploadedFile() error: %v", err)
+			}
+			rel, _ := filepath.Rel(uploads, filePath)
+			parts := strings.Split(filepath.ToSlash(rel), "/")
+			wantDepth := map[string]int{UploadPartitionDate: 5, UploadPartitionJob: 3, UploadPartitionNone: 2}[partition]
+			if len(parts) != wantDepth || parts[len(parts)-2] != jobID.String() || parts[len(parts)-1] != "my_users.csv" {
+				t.Fatalf("upload saved at %s", rel)
+			}
+			if partition == UploadPartitionJob && parts[0] != "3f" {
+				t.Errorf("job partition = %s, want 3f", parts[0])
+			}
+
+			// The job directory and the emptied partitions above it are removed
+			if err := s.RemoveUpload(filePath); err != nil {
+				t.Fatalf("RemoveUpload() error: %v", err)
+			}
+			if entries, _ := os.ReadDir(uploads); len(entries) != 0 {
+				t.Errorf("upload area has %d entries after removal, want none", len(entries))
+			}
+			if err := s.RemoveUpload(filePath); err != nil {
+				t.Errorf("second RemoveUpload() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoveUpload_KeepsOtherJobs(t *testing.T) {
+	uploads := t.TempDir()
+	s := &Service{}
+	s.config.Store(&config.ImportConfig{UploadPath: uploads, UploadPartition: UploadPartitionDate})
+
+	first, err := s.SaveUploadedFile(uuid.New(), strings.NewReader("a"), "users.csv")
+	if err != nil {
+		t.Fatalf("SaveUploadedFile() error: %v", err)
+	}
+	second, err := s.SaveUploadedFile(uuid.New(), strings.NewReader("b"), "users.csv")
+	if err != nil {
+		t.Fatalf("SaveUploadedFile() error: %v", err)
+	}
+
+	if err := s.RemoveUpload(first); err != nil {
+		t.Fatalf("RemoveUpload() error: %v", err)
+	}
+	if _, err := os.Stat(second); err != nil {
+		t.Errorf("other job's upload was removed: %v", err)
+	}
+
+	// A file saved before uploads had job directories is removed on its own
+	flat := filepath.Join(uploads, "legacy_123.csv")
+	os.WriteFile(flat, []byte("a"), 0644)
+	if err := s.RemoveUpload(flat); err != nil {
+		t.Fatalf("RemoveUpload() error: %v", err)
+	}
+	if _, err := os.Stat(flat); !os.IsNotExist(err) {
+		t.Errorf("legacy upload still exists")
+	}
+	if _, err := os.Stat(second); err != nil {
+		t.Errorf("other job's upload was removed with the legacy file: %v", err)
+	}
+}
@@ -0,0 +1,449 @@
+package importservice
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	"github.com/rohit/bulk-import-export/internal/service/validation"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Admin role policies for ImportConfig.AdminRolePolicy, applied to user
+// imports without AllowAdminRoles
+const (
+	AdminRolePolicyAllow = "allow"
+	AdminRolePolicyFlag  = "flag"
+	AdminRolePolicyBlock = "block"
+)
+
+// userStages implements the pipeline stages for user imports
+type userStages struct {
+	encoding    parsers.Encoding
+	maxLineSize int
+	flattener   *parsers.Flattener // nil unless the job maps nested fields
+	validator   *validation.UserValidator
+	adminPolicy string // how admin rows are treated; allow when elevated
+	fuzzyMode   models.UserFuzzyDedup
+	fuzzy       *fuzzyUserMatcher // nil unless the job asked for fuzzy dedup
+	upsertKey   models.UpsertKey  // id or email
+	onConflict  models.OnConflict // what rows with another user's email do
+	stagingRepo repository.StagingRepository
+	userRepo    repository.UserRepository
+	buffer      *memoryBuffer[repository.StagingUser]
+	log         zerolog.Logger // sampled, for per-row warnings
+}
+
+func (s *Service) processUsersImport(ctx context.Context, job *models.Job, file *os.File, log zerolog.Logger) error {
+	cfg := s.config.Load()
+	flatten, err := flattener(job)
+	if err != nil {
+		return err
+	}
+	stages := &userStages{
+		encoding:    s.encoding,
+		maxLineSize: s.maxLineSize(),
+		flattener:   flatten,
+		validator:   s.validator.User,
+		adminPolicy: s.adminRolePolicy(job),
+		upsertKey:   upsertKey(job),
+		onConflict:  onConflict(job),
+		stagingRepo: s.stagingRepo,
+		userRepo:    s.userRepo,
+		buffer:      newMemoryBuffer[repository.StagingUser](cfg.FastPathMaxRows, cfg.BatchSize),
+		log:         logger.Hot(log),
+	}
+	if job.Params != nil && job.Params.FuzzyDedup != "" {
+		stages.fuzzyMode = job.Params.FuzzyDedup
+		stages.fuzzy = newFuzzyUserMatcher()
+	}
+	return runPipeline(ctx, s, job, file, log, pipeline[models.UserImport, repository.StagingUser]{
+		parser:     stages,
+		normalizer: stages,
+		validator:  stages,
+		stager:     stages,
+		deduper:    stages,
+		batchDedup: stages,
+		inserter:   stages,
+		analyzer:   stages,
+		ledger:     stages,
+	})
+}
+
+// Parse reads users with the parser registered for the file's format
+func (u *userStages) Parse(file *os.File, fn RowFunc[models.UserImport]) error {
+	opts := parsers.ParserOptions{Encoding: u.encoding, MaxLineSize: u.maxLineSize, Flattener: u.flattener}
+	return parseRecords(file, models.ResourceTypeUsers, opts, fn)
+}
+
+// Normalize puts the ID in canonical form and lowercases email, role and
+// active so staging dedup and inserts see one spelling, and strips invisible
+// characters from the name
+func (u *userStages) Normalize(jobID uuid.UUID, row int, user *models.UserImport) repository.StagingUser {
+	staged := repository.StagingUser{JobID: jobID, RowNumber: row}
+	if user == nil {
+		return staged
+	}
+
+	staged.IsValid = true
+	if user.ID != "" {
+		id := canonicalID(&user.ID)
+		staged.ID = &id
+	}
+	if user.Email != "" {
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		staged.Email = &email
+	}
+	if user.Name != "" {
+		name := validation.StripInvisible(user.Name)
+		staged.Name = &name
+	}
+	if user.Role != "" {
+		role := strings.ToLower(user.Role)
+		staged.Role = &role
+	}
+	if user.Active != "" {
+		active := strings.ToLower(user.Active) == "true"
+		staged.Active = &active
+	}
+	if user.CreatedAt != "" {
+		staged.CreatedAt = &user.CreatedAt
+	}
+	if user.UpdatedAt != "" {
+		staged.UpdatedAt = &user.UpdatedAt
+	}
+	return staged
+}
+
+func (u *userStages) Reject(staged *repository.StagingUser, verr *errors.ValidationError) {
+	msg := verr.Code + ": " + verr.Message
+	staged.IsValid = false
+	staged.ValidationError = &msg
+}
+
+func (u *userStages) DedupKey(staged *repository.StagingUser) string {
+	if staged.Email == nil {
+		return ""
+	}
+	return *staged.Email
+}
+
+func (u *userStages) Validate(row int, user *models.UserImport) ([]*errors.ValidationError, []*errors.ValidationError) {
+	if errs := u.validator.ValidateUserImport(row, user); len(errs) > 0 {
+		return errs, nil
+	}
+	warns := u.validator.WarnUserImport(row, user)
+
+	if strings.EqualFold(strings.TrimSpace(user.Role), models.UserRoleAdmin) {
+		switch u.adminPolicy {
+		case AdminRolePolicyBlock:
+			return []*errors.ValidationError{
+				errors.NewValidationError(row, user.Email, "role", errors.ErrCodeRoleNotPermitted, "Creating admin users requires an import allowed to grant admin roles"),
+			}, nil
+		case AdminRolePolicyFlag:
+			warns = append(warns, errors.NewValidationError(row, user.Email, "role", errors.WarnCodeAdminRoleFlagged, "Admin user imported by an import not allowed to grant admin roles"))
+		}
+	}
+
+	errs, fuzzyWarns := u.fuzzyDedup(row, user)
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	return nil, append(warns, fuzzyWarns...)
+}
+
+// adminRolePolicy is the admin role policy applying to job: allow for an
+// import allowed to grant admin roles, the configured policy otherwise
+func (s *Service) adminRolePolicy(job *models.Job) string {
+	if job.Params != nil && job.Params.AllowAdminRoles {
+		return AdminRolePolicyAllow
+	}
+	return s.config.Load().AdminRolePolicy
+}
+
+func (u *userStages) Stage(ctx context.Context, jobID uuid.UUID, rows []repository.StagingUser) error {
+	return u.buffer.stage(ctx, jobID, rows, u.stagingRepo.CreateStagingUsers)
+}
+
+func (u *userStages) Valid(ctx context.Context, jobID uuid.UUID, batchSize int, fn func([]repository.StagingUser) error) error {
+	if u.buffer.inMemory() {
+		return u.buffer.each(fn)
+	}
+	return u.stagingRepo.GetValidStagingUsers(ctx, jobID, batchSize, fn)
+}
+
+func (u *userStages) StagingID(su *repository.StagingUser) int64 {
+	return su.StagingID
+}
+
+func (u *userStages) KeepCommitted(ctx context.Context, jobID uuid.UUID, through int64) ([]repository.CommittedRow, error) {
+	rows, err := u.stagingRepo.KeepCommittedUsers(ctx, jobID, through)
+	if len(rows) > 0 {
+		u.buffer.bypass()
+	}
+	return rows, err
+}
+
+func (u *userStages) Cleanup(ctx context.Context, jobID uuid.UUID) error {
+	u.buffer.reset()
+	return u.stagingRepo.CleanupStagingUsers(ctx, jobID)
+}
+
+// Dedup marks repeated emails within the file and emails that already belong
+// to another user. Matching on email, stored emails are updated instead, and
+// DedupBatch marks the rows under another user's ID as each batch is written.
+// When conflicts overwrite, Insert updates the user with the email instead.
+func (u *userStages) Dedup(ctx context.Context, job *models.Job) (int, int, error) {
+	if u.buffer.inMemory() {
+		return u.dedupInMemory(ctx)
+	}
+
+	inBatch, err := u.stagingRepo.MarkDuplicateUsersInBatch(ctx, job.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkCancelled(ctx); err != nil || u.upsertKey == models.UpsertKeyEmail || u.onConflict.Overwrites() {
+		return inBatch, 0, err
+	}
+	existing, err := u.stagingRepo.MarkDuplicateUsersAgainstExisting(ctx, job.ID)
+	if err != nil {
+		return inBatch, 0, err
+	}
+	return inBatch, existing, nil
+}
+
+// dedupInMemory is Dedup for buffered rows, looking up only the emails and
+// IDs the file contains
+func (u *userStages) dedupInMemory(ctx context.Context) (int, int, error) {
+	rows := u.buffer.rows
+	email := func(su *repository.StagingUser) *string { return su.Email }
+	inBatch := markRepeats(rows, email, markUserDuplicate)
+	existing, err := u.markExisting(ctx, rows)
+	return inBatch, existing, err
+}
+
+// DedupBatch marks the rows whose email was taken by another user since
+// Dedup ran, or matching on email, whose new email comes under a stored
+// user's ID
+func (u *userStages) DedupBatch(ctx context.Context, rows []repository.StagingUser) (int, error) {
+	return u.markExisting(ctx, rows)
+}
+
+// markExisting marks the valid rows whose email belongs to a stored user,
+// unless their ID is a stored user they update. Matching on email it marks
+// the rows with a new email whose ID is a stored user instead. IDs are looked
+// up in canonical form, so any spelling of a stored ID matches it. It marks
+// none when conflicts overwrite.
+func (u *userStages) markExisting(ctx context.Context, rows []repository.StagingUser) (int, error) {
+	if u.onConflict.Overwrites() {
+		return 0, nil
+	}
+	email := func(su *repository.StagingUser) *string { return su.Email }
+	id := func(su *repository.StagingUser) *string { return su.ID }
+	valid := func(su *repository.StagingUser) bool { return su.IsValid }
+
+	emails, err := u.userRepo.ExistingEmails(ctx, collectKeys(rows, valid, email))
+	if err != nil {
+		return 0, err
+	}
+	ids, err := u.userRepo.ExistingIDs(ctx, collectKeys(rows, valid, id))
+	if err != nil {
+		return 0, err
+	}
+
+	existing := 0
+	for i := range rows {
+		su := &rows[i]
+		if !su.IsValid || su.Email == nil {
+			continue
+		}
+		storedID := su.ID != nil && ids[canonicalID(su.ID)]
+		if u.upsertKey == models.UpsertKeyEmail {
+			if storedID && !emails[*su.Email] {
+				markUserIDTaken(su)
+				existing++
+			}
+			continue
+		}
+		if emails[*su.Email] && !storedID {
+			markUserDuplicate(su)
+			existing++
+		}
+	}
+	return existing, nil
+}
+
+func markUserDuplicate(su *repository.StagingUser) {
+	code := errors.ErrCodeDuplicateEmail
+	su.IsDuplicate = true
+	su.IsValid = false
+	su.ValidationError = &code
+}
+
+func markUserIDTaken(su *repository.StagingUser) {
+	code := errors.ErrCodeDuplicateID
+	su.IsDuplicate = true
+	su.IsValid = false
+	su.ValidationError = &code
+}
+
+// Insert upserts the valid rows. When conflicts overwrite, a row whose email
+// belongs to another user takes that user's ID so that it updates them, and
+// in upsert mode keeps their values for the fields it leaves out.
+func (u *userStages) Insert(ctx context.Context, rows []repository.StagingUser) ([]uuid.UUID, int, int, error) {
+	var stored map[string]*models.User
+	if u.onConflict.Overwrites() {
+		email := func(su *repository.StagingUser) *string { return su.Email }
+		writable := func(su *repository.StagingUser) bool { return su.IsValid && !su.IsDuplicate }
+		var err error
+		if stored, err = u.userRepo.GetByEmails(ctx, collectKeys(rows, writable, email)); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	users := make([]*models.User, 0, len(rows))
+	for _, su := range rows {
+		if !su.IsValid || su.IsDuplicate {
+			continue
+		}
+		var match *models.User
+		if su.Email != nil {
+			if m := stored[*su.Email]; m != nil && conflictsWith(su.ID, m.ID) {
+				match = m
+			}
+		}
+		if match != nil && u.onConflict == models.OnConflictUpsert {
+			mergeStoredUser(&su, match)
+		}
+		user, err := convertStagingToUser(&su)
+		if err != nil {
+			u.log.Warn().Err(err).Int("row", su.RowNumber).Msg("Failed to convert staging user")
+			continue
+		}
+		if match != nil {
+			user.ID = match.ID
+		}
+		users = append(users, user)
+	}
+	if len(users) == 0 {
+		return nil, 0, 0, nil
+	}
+
+	inserted, updated, err := u.userRepo.UpsertBatch(ctx, users, u.upsertKey)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids, inserted, updated, nil
+}
+
+// Analyze counts the rows Insert would write as new users and as updates
+// to the user with the same ID, or matching on email, the same email. When
+// conflicts overwrite, a row with a stored email updates that user.
+func (u *userStages) Analyze(ctx context.Context, rows []repository.StagingUser) (int, int, error) {
+	writable := func(su *repository.StagingUser) bool { return su.IsValid && !su.IsDuplicate }
+	email := func(su *repository.StagingUser) *string { return su.Email }
+	if u.upsertKey == models.UpsertKeyEmail {
+		return analyzeRows(ctx, rows, writable, email, u.userRepo.ExistingEmails)
+	}
+	id := func(su *repository.StagingUser) *string { return su.ID }
+	if u.onConflict.Overwrites() {
+		return analyzeOverwrites(ctx, rows, writable, email, id, u.userRepo.ExistingEmails, u.userRepo.ExistingIDs)
+	}
+	return analyzeRows(ctx, rows, writable, id, u.userRepo.ExistingIDs)
+}
+
+// LedgerEntry keys a user by email and hashes the values the file gave it
+func (u *userStages) LedgerEntry(su *repository.StagingUser) (string, string, bool) {
+	if !su.IsValid || su.IsDuplicate || su.Email == nil {
+		return "", "", false
+	}
+	return *su.Email, contentHash(su.ID, su.Email, su.Name, su.Role, su.Active, su.CreatedAt, su.UpdatedAt), true
+}
+
+// Stored returns which of the emails belong to a stored user
+func (u *userStages) Stored(ctx context.Context, emails []string) (map[string]bool, error) {
+	return u.userRepo.ExistingEmails(ctx, emails)
+}
+
+func (u *userStages) MarkImported(su *repository.StagingUser) {
+	code := errors.ErrCodeAlreadyImported
+	su.IsValid = false
+	su.ValidationError = &code
+}
+
+// mergeStoredUser fills the fields su leaves out with the values of the
+// stored user it updates
+func mergeStoredUser(su *repository.StagingUser, stored *models.User) {
+	if su.Name == nil {
+		su.Name = &stored.Name
+	}
+	if su.Role == nil {
+		su.Role = &stored.Role
+	}
+	if su.Active == nil {
+		su.Active = &stored.Active
+	}
+}
+
+func convertStagingToUser(su *repository.StagingUser) (*models.User, error) {
+	user := &models.User{
+		Active:      true,
+		ImportJobID: provenance(su.JobID),
+	}
+
+	if su.ID != nil && *su.ID != "" {
+		id, err := uuid.Parse(*su.ID)
+		if err != nil {
+			return nil, err
+		}
+		user.ID = id
+	} else {
+		user.ID = uuid.New()
+	}
+
+	if su.Email != nil {
+		user.Email = *su.Email
+	}
+	if su.Name != nil {
+		user.Name = *su.Name
+	}
+	if su.Role != nil {
+		user.Role = *su.Role
+	}
+	if su.Active != nil {
+		user.Active = *su.Active
+	}
+	if su.CreatedAt != nil {
+		t, err := time.Parse(time.RFC3339, *su.CreatedAt)
+		if err == nil {
+			user.CreatedAt = t
+		} else {
+			user.CreatedAt = time.Now().UTC()
+		}
+	} else {
+		user.CreatedAt = time.Now().UTC()
+	}
+	if su.UpdatedAt != nil {
+		t, err := time.Parse(time.RFC3339, *su.UpdatedAt)
+		if err == nil {
+			user.UpdatedAt = t
+		} else {
+			user.UpdatedAt = time.Now().UTC()
+		}
+	} else {
+		user.UpdatedAt = time.Now().UTC()
+	}
+
+	return user, nil
+}
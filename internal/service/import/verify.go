@@ -0,0 +1,277 @@
+package importservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+)
+
+// verifySampleLimit caps the differences, missing and extra records a
+// VerifyReport lists
+const verifySampleLimit = 100
+
+// verifier compares the rows of an import file, normalized as the import
+// would stage them, with the stored records M of their resource
+type verifier[R, S, M any] struct {
+	parser     Parser[R]
+	normalizer Normalizer[R, S]
+	// keys returns the ID and natural key of a row, "" for those it lacks
+	keys       func(*S) (id, natural string)
+	storedKeys func(*M) (id, natural string)
+	compare    func(*S, *M) []models.FieldDifference
+	// scan calls fn with batches of every stored record
+	scan func(ctx context.Context, fn func([]*M) error) error
+}
+
+// verifyRow is a file row waiting for its stored record
+type verifyRow[S any] struct {
+	staged  S
+	record  models.VerifyRecord
+	matched bool
+}
+
+// Verify compares an import file with the stored records of resource, to
+// audit that an import landed: rows with no stored record are missing, rows
+// whose record has other values differ, and stored records no row matches
+// are extra. Only the fields a row gives are compared, after the import's
+// normalization, so a body the import sanitized or truncated differs.
+func (s *Service) Verify(ctx context.Context, file *os.File, resource models.ResourceType) (*models.VerifyReport, error) {
+	batchSize := s.config.Load().BatchSize
+	all := &models.ExportFilters{}
+
+	switch resource {
+	case models.ResourceTypeUsers:
+		stages := &userStages{encoding: s.encoding, maxLineSize: s.maxLineSize()}
+		return runVerify(ctx, resource, file, verifier[models.UserImport, repository.StagingUser, models.User]{
+			parser:     stages,
+			normalizer: stages,
+			keys: func(su *repository.StagingUser) (string, string) {
+				return canonicalID(su.ID), deref(su.Email)
+			},
+			storedKeys: func(u *models.User) (string, string) { return u.ID.String(), u.Email },
+			compare:    compareUser,
+			scan: func(ctx context.Context, fn func([]*models.User) error) error {
+				return s.userRepo.GetAllWithCursor(ctx, all, batchSize, fn)
+			},
+		})
+	case models.ResourceTypeArticles:
+		stages := &articleStages{encoding: s.encoding, maxLineSize: s.maxLineSize()}
+		return runVerify(ctx, resource, file, verifier[models.ArticleImport, repository.StagingArticle, models.Article]{
+			parser:     stages,
+			normalizer: stages,
+			keys: func(sa *repository.StagingArticle) (string, string) {
+				return canonicalID(sa.ID), deref(sa.Slug)
+			},
+			storedKeys: func(a *models.Article) (string, string) { return a.ID.String(), a.Slug },
+			compare:    compareArticle,
+			scan: func(ctx context.Context, fn func([]*models.Article) error) error {
+				return s.articleRepo.GetAllWithCursor(ctx, all, batchSize, fn)
+			},
+		})
+	case models.ResourceTypeComments:
+		stages := &commentStages{encoding: s.encoding, maxLineSize: s.maxLineSize()}
+		return runVerify(ctx, resource, file, verifier[models.CommentImport, repository.StagingComment, models.Comment]{
+			parser:     stages,
+			normalizer: stages,
+			keys: func(sc *repository.StagingComment) (string, string) {
+				return canonicalID(sc.ID), ""
+			},
+			storedKeys: func(c *models.Comment) (string, string) { return c.ID.String(), "" },
+			compare:    compareComment,
+			scan: func(ctx context.Context, fn func([]*models.Comment) error) error {
+				return s.commentRepo.GetAllWithCursor(ctx, all, batchSize, fn)
+			},
+		})
+	default:
+		return nil, fmt.Errorf("unknown resource type: %s", resource)
+	}
+}
+
+// runVerify reads file through v, then matches each stored record to the
+// row with its ID or, failing that, its natural key
+func runVerify[R, S, M any](ctx context.Context, resource models.ResourceType, file *os.File, v verifier[R, S, M]) (*models.VerifyReport, error) {
+	report := &models.VerifyReport{
+		Resource:       resource,
+		Differences:    []models.RecordDifference{},
+		MissingRecords: []models.VerifyRecord{},
+		ExtraRecords:   []models.VerifyRecord{},
+	}
+
+	var rows []*verifyRow[S]
+	byID := make(map[string]*verifyRow[S])
+	byNatural := make(map[string]*verifyRow[S])
+	err := v.parser.Parse(file, func(row int, rec *R, raw string, parseErr *parsers.ParseError) error {
+		report.FileRecords++
+		if parseErr != nil || rec == nil {
+			report.Unmatchable++
+			return nil
+		}
+
+		r := &verifyRow[S]{staged: v.normalizer.Normalize(uuid.Nil, row, rec)}
+		id, natural := v.keys(&r.staged)
+		index, key := byID, id
+		if id == "" {
+			index, key = byNatural, natural
+		}
+		switch {
+		case key == "":
+			report.Unmatchable++
+		case index[key] != nil:
+			report.Repeated++
+		default:
+			r.record = models.VerifyRecord{Row: row, Key: key}
+			index[key] = r
+			rows = append(rows, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.ErrInvalidRequest("failed to read file: " + err.Error())
+	}
+
+	err = v.scan(ctx, func(batch []*M) error {
+		for _, m := range batch {
+			id, natural := v.storedKeys(m)
+			r := byID[id]
+			if r == nil && natural != "" {
+				r = byNatural[natural]
+			}
+			if r == nil || r.matched {
+				report.Extra++
+				if len(report.ExtraRecords) < verifySampleLimit {
+					report.ExtraRecords = append(report.ExtraRecords, models.VerifyRecord{Key: id})
+				}
+				continue
+			}
+
+			r.matched = true
+			fields := v.compare(&r.staged, m)
+			if len(fields) == 0 {
+				report.Matched++
+				continue
+			}
+			report.Differing++
+			if len(report.Differences) < verifySampleLimit {
+				report.Differences = append(report.Differences, models.RecordDifference{VerifyRecord: r.record, Fields: fields})
+			}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored %s: %w", resource, err)
+	}
+
+	for _, r := range rows {
+		if r.matched {
+			continue
+		}
+		report.Missing++
+		if len(report.MissingRecords) < verifySampleLimit {
+			report.MissingRecords = append(report.MissingRecords, r.record)
+		}
+	}
+	return report, nil
+}
+
+func compareUser(su *repository.StagingUser, u *models.User) []models.FieldDifference {
+	var d fieldDiffs
+	d.text("email", su.Email, u.Email)
+	d.text("name", su.Name, u.Name)
+	d.text("role", su.Role, u.Role)
+	if su.Active != nil {
+		active := strconv.FormatBool(*su.Active)
+		d.text("active", &active, strconv.FormatBool(u.Active))
+	}
+	d.time("created_at", su.CreatedAt, &u.CreatedAt)
+	return d
+}
+
+func compareArticle(sa *repository.StagingArticle, a *models.Article) []models.FieldDifference {
+	var d fieldDiffs
+	d.text("slug", sa.Slug, a.Slug)
+	d.text("title", sa.Title, a.Title)
+	d.text("body", sa.Body, a.Body)
+	d.id("author_id", sa.AuthorID, a.AuthorID)
+	d.text("status", sa.Status, a.Status)
+	d.time("published_at", sa.PublishedAt, a.PublishedAt)
+	if sa.Tags != nil {
+		var file, stored []string
+		json.Unmarshal([]byte(*sa.Tags), &file)
+		json.Unmarshal(a.Tags, &stored)
+		if !slices.Equal(file, stored) {
+			d = append(d, models.FieldDifference{Field: "tags", File: *sa.Tags, Stored: string(a.Tags)})
+		}
+	}
+	return d
+}
+
+func compareComment(sc *repository.StagingComment, c *models.Comment) []models.FieldDifference {
+	var d fieldDiffs
+	d.id("article_id", sc.ArticleID, c.ArticleID)
+	d.id("user_id", sc.UserID, c.UserID)
+	d.text("body", sc.Body, c.Body)
+	d.time("created_at", sc.CreatedAt, &c.CreatedAt)
+	return d
+}
+
+// fieldDiffs collects the fields a row and its stored record disagree on.
+// A field the row leaves out is not compared.
+type fieldDiffs []models.FieldDifference
+
+func (d *fieldDiffs) text(field string, file *string, stored string) {
+	if file != nil && *file != stored {
+		*d = append(*d, models.FieldDifference{Field: field, File: *file, Stored: stored})
+	}
+}
+
+func (d *fieldDiffs) id(field string, file *string, stored uuid.UUID) {
+	if file != nil && canonicalID(file) != stored.String() {
+		*d = append(*d, models.FieldDifference{Field: field, File: *file, Stored: stored.String()})
+	}
+}
+
+// time compares at the microsecond precision of the database; a stored
+// time of nil is the empty string
+func (d *fieldDiffs) time(field string, file *string, stored *time.Time) {
+	if file == nil {
+		return
+	}
+	value := ""
+	if stored != nil {
+		value = stored.UTC().Format(time.RFC3339Nano)
+	}
+	t, err := time.Parse(time.RFC3339, *file)
+	if err == nil && stored != nil && t.Truncate(time.Microsecond).Equal(stored.Truncate(time.Microsecond)) {
+		return
+	}
+	*d = append(*d, models.FieldDifference{Field: field, File: *file, Stored: value})
+}
+
+// canonicalID returns id in the form stored IDs are printed in, or id as it
+// is when it isn't a UUID; "" for no id
+func canonicalID(id *string) string {
+	if id == nil || *id == "" {
+		return ""
+	}
+	if parsed, err := uuid.Parse(*id); err == nil {
+		return parsed.String()
+	}
+	return *id
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
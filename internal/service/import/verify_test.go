@@ -0,0 +1,57 @@
+package importservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+)
+
+func TestVerify_Users(t *testing.T) {
+	svc, db := newTestService(t, 0)
+	ctx := context.Background()
+	users := memory.NewUserRepository(db)
+	for _, u := range []*models.User{
+		{ID: uuid.MustParse(annID), Email: "ann@example.com", Name: "Ann", Role: "admin", Active: true},
+		{ID: uuid.MustParse(bobID), Email: "bob@example.com", Name: "Bob", Role: "reader", Active: true},
+		{Email: "cat@example.com", Name: "Cat", Role: "reader", Active: true},
+		{Email: "eve@example.com", Name: "Eve", Role: "reader", Active: true},
+	} {
+		if err := users.Create(ctx, u); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	// Ann matches by ID and Cat by email; Bob's name differs, Dan was never
+	// stored and Eve isn't in the file
+	file := writeTempFile(t, "users.ndjson", `{"id":"`+annID+`","email":"ann@example.com","name":"Ann","role":"admin","active":"true"}
+{"id":"`+bobID+`","email":"bob@example.com","name":"Robert","role":"reader"}
+{"email":"CAT@example.com","name":"Cat","role":"reader"}
+{"email":"dan@example.com","name":"Dan","role":"reader"}
+{"email":"cat@example.com","name":"Cat again","role":"reader"}
+{"name":"No key","role":"reader"}
+{not json
+`)
+	report, err := svc.Verify(ctx, file, models.ResourceTypeUsers)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+
+	if report.FileRecords != 7 || report.Matched != 2 || report.Differing != 1 || report.Missing != 1 ||
+		report.Extra != 1 || report.Repeated != 1 || report.Unmatchable != 2 {
+		t.Errorf("report = %+v, want 7 rows: 2 matched, 1 differing, 1 missing, 1 extra, 1 repeated, 2 unmatchable", report)
+	}
+	if len(report.Differences) != 1 || report.Differences[0].Row != 2 || len(report.Differences[0].Fields) != 1 ||
+		report.Differences[0].Fields[0] != (models.FieldDifference{Field: "name", File: "Robert", Stored: "Bob"}) {
+		t.Errorf("Differences = %+v, want Bob's name on row 2", report.Differences)
+	}
+	if len(report.MissingRecords) != 1 || report.MissingRecords[0] != (models.VerifyRecord{Row: 4, Key: "dan@example.com"}) {
+		t.Errorf("MissingRecords = %+v, want Dan on row 4", report.MissingRecords)
+	}
+	eve, _ := users.GetByEmail(ctx, "eve@example.com")
+	if len(report.ExtraRecords) != 1 || report.ExtraRecords[0].Key != eve.ID.String() {
+		t.Errorf("ExtraRecords = %+v, want Eve", report.ExtraRecords)
+	}
+}
@@ -0,0 +1,105 @@
+package quotaservice
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rs/zerolog"
+)
+
+// Service enforces per-tenant quotas
+type Service struct {
+	quotaRepo repository.QuotaRepository
+	logger    zerolog.Logger
+	config    atomic.Pointer[config.QuotaConfig]
+}
+
+// NewService creates a new quota service
+func NewService(
+	quotaRepo repository.QuotaRepository,
+	logger zerolog.Logger,
+	cfg config.QuotaConfig,
+) *Service {
+	s := &Service{
+		quotaRepo: quotaRepo,
+		logger:    logger,
+	}
+	s.config.Store(&cfg)
+	return s
+}
+
+// SetConfig replaces the quota settings when they are reloaded
+func (s *Service) SetConfig(cfg config.QuotaConfig) {
+	s.config.Store(&cfg)
+}
+
+// Limits returns the configured quota limits
+func (s *Service) Limits() models.QuotaLimits {
+	cfg := s.config.Load()
+	return models.QuotaLimits{
+		JobsPerDay:         cfg.JobsPerDay,
+		RowsPerMonth:       cfg.RowsPerMonth,
+		ExportStorageBytes: cfg.ExportStorageBytes,
+	}
+}
+
+// Status returns the limits and current usage for a tenant
+func (s *Service) Status(ctx context.Context, tenantID string) (*models.QuotaStatus, error) {
+	dayStart, monthStart := periodStarts(time.Now().UTC())
+
+	usage, err := s.quotaRepo.GetUsage(ctx, tenantID, dayStart, monthStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	status := &models.QuotaStatus{
+		TenantID: tenantID,
+		Enabled:  s.config.Load().Enabled,
+		Limits:   s.Limits(),
+		Usage:    *usage,
+	}
+	status.ResetsAt.Jobs = dayStart.AddDate(0, 0, 1)
+	status.ResetsAt.Rows = monthStart.AddDate(0, 1, 0)
+
+	return status, nil
+}
+
+// CheckJobCreation verifies the tenant may create another job of the given type.
+// It returns an *errors.AppError with code QUOTA_EXCEEDED when a limit is reached.
+func (s *Service) CheckJobCreation(ctx context.Context, tenantID string, jobType models.JobType) error {
+	cfg := s.config.Load()
+	if !cfg.Enabled {
+		return nil
+	}
+
+	status, err := s.Status(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	if cfg.JobsPerDay > 0 && status.Usage.JobsToday >= cfg.JobsPerDay {
+		return errors.ErrQuotaExceeded(fmt.Sprintf("daily job quota of %d reached", cfg.JobsPerDay))
+	}
+	if cfg.RowsPerMonth > 0 && status.Usage.RowsThisMonth >= cfg.RowsPerMonth {
+		return errors.ErrQuotaExceeded(fmt.Sprintf("monthly row quota of %d reached", cfg.RowsPerMonth))
+	}
+	if jobType == models.JobTypeExport && cfg.ExportStorageBytes > 0 &&
+		status.Usage.ExportStorageBytes >= cfg.ExportStorageBytes {
+		return errors.ErrQuotaExceeded(fmt.Sprintf("export storage quota of %d bytes reached", cfg.ExportStorageBytes))
+	}
+
+	return nil
+}
+
+// periodStarts returns the start of the current UTC day and month
+func periodStarts(now time.Time) (time.Time, time.Time) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return dayStart, monthStart
+}
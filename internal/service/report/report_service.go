@@ -0,0 +1,185 @@
+package reportservice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rs/zerolog"
+)
+
+// Service builds per-tenant usage reports for charge-back and keeps the
+// daily usage rollup current
+type Service struct {
+	usageRepo repository.UsageRepository
+	logger    zerolog.Logger
+	config    config.ReportConfig
+}
+
+// NewService creates a new report service
+func NewService(
+	usageRepo repository.UsageRepository,
+	logger zerolog.Logger,
+	cfg config.ReportConfig,
+) *Service {
+	return &Service{
+		usageRepo: usageRepo,
+		logger:    logger,
+		config:    cfg,
+	}
+}
+
+// Usage reports the usage of the jobs created in [from, to), for one tenant
+// or, with an empty tenantID, for every tenant. Whole days that have been
+// rolled up are read from the rollup; the rest is aggregated from the jobs
+// table.
+func (s *Service) Usage(ctx context.Context, from, to time.Time, tenantID string) (*models.UsageReport, error) {
+	from, to = from.UTC(), to.UTC()
+	report := &models.UsageReport{From: from, To: to}
+
+	until, err := s.usageRepo.RolledUpUntil(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rollup progress: %w", err)
+	}
+
+	// Rolled-up days that lie wholly inside the period
+	rollupFrom, rollupTo := ceilDay(from), startOfDay(to)
+	if until == nil {
+		rollupTo = rollupFrom
+	} else if until.Before(rollupTo) {
+		rollupTo = *until
+	}
+
+	var parts [][]*models.TenantUsage
+	if rollupFrom.Before(rollupTo) {
+		rolled, err := s.usageRepo.GetRollups(ctx, rollupFrom, rollupTo, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get usage rollups: %w", err)
+		}
+		head, err := s.live(ctx, from, rollupFrom, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		tail, err := s.live(ctx, rollupTo, to, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, head, rolled, tail)
+		report.RolledUpUntil = &rollupTo
+	} else {
+		usage, err := s.live(ctx, from, to, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, usage)
+	}
+
+	report.Tenants = merge(parts...)
+	report.Totals.TenantID = tenantID
+	for _, usage := range report.Tenants {
+		report.Totals.Add(usage)
+	}
+	return report, nil
+}
+
+func (s *Service) live(ctx context.Context, from, to time.Time, tenantID string) ([]*models.TenantUsage, error) {
+	if !from.Before(to) {
+		return nil, nil
+	}
+	usage, err := s.usageRepo.GetUsage(ctx, from, to, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return usage, nil
+}
+
+// Rollup rolls up every day after the last rolled-up one (or from the first
+// job) that ended at least the configured delay before now. It returns the
+// number of days rolled up.
+func (s *Service) Rollup(ctx context.Context, now time.Time) (int, error) {
+	next, err := s.usageRepo.RolledUpUntil(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rollup progress: %w", err)
+	}
+	if next == nil {
+		first, err := s.usageRepo.FirstJobAt(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get first job: %w", err)
+		}
+		if first == nil {
+			return 0, nil
+		}
+		day := startOfDay(first.UTC())
+		next = &day
+	}
+
+	cutoff := startOfDay(now.UTC().Add(-s.config.RollupDelay))
+	days := 0
+	for day := *next; day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		if err := s.usageRepo.RollupDay(ctx, day); err != nil {
+			return days, fmt.Errorf("failed to roll up %s: %w", day.Format("2006-01-02"), err)
+		}
+		days++
+	}
+	return days, nil
+}
+
+// Run rolls up usage now and then every configured interval until ctx is
+// done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.RollupInterval)
+	defer ticker.Stop()
+
+	for {
+		days, err := s.Rollup(ctx, time.Now())
+		if err != nil {
+			s.logger.Error().Err(err).Int("days", days).Msg("Usage rollup failed")
+		} else if days > 0 {
+			s.logger.Info().Int("days", days).Msg("Rolled up usage")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// merge sums usage lists by tenant, ordered by tenant ID
+func merge(parts ...[]*models.TenantUsage) []*models.TenantUsage {
+	byTenant := make(map[string]*models.TenantUsage)
+	for _, part := range parts {
+		for _, usage := range part {
+			sum, ok := byTenant[usage.TenantID]
+			if !ok {
+				sum = &models.TenantUsage{TenantID: usage.TenantID}
+				byTenant[usage.TenantID] = sum
+			}
+			sum.Add(usage)
+		}
+	}
+
+	merged := make([]*models.TenantUsage, 0, len(byTenant))
+	for _, usage := range byTenant {
+		merged = append(merged, usage)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].TenantID < merged[j].TenantID })
+	return merged
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func ceilDay(t time.Time) time.Time {
+	day := startOfDay(t)
+	if day.Before(t) {
+		return day.AddDate(0, 0, 1)
+	}
+	return day
+}
@@ -0,0 +1,110 @@
+package reportservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func day(d, hour int) time.Time {
+	return time.Date(2024, 3, d, hour, 0, 0, 0, time.UTC)
+}
+
+func newTestService(t *testing.T) (*Service, *memory.DB) {
+	t.Helper()
+	db := memory.NewDB()
+	jobs := memory.NewJobRepository(db)
+	exportPath := "exports/a.ndjson"
+
+	for _, job := range []*models.Job{
+		{TenantID: "acme", Type: models.JobTypeImport, Status: models.JobStatusCompleted, SuccessfulRecords: 90, FailedRecords: 10, CreatedAt: day(1, 10)},
+		{TenantID: "acme", Type: models.JobTypeExport, Status: models.JobStatusCompleted, SuccessfulRecords: 50, FilePath: &exportPath, FileSizeBytes: 2048, CreatedAt: day(2, 12)},
+		{TenantID: "acme", Type: models.JobTypeImport, Status: models.JobStatusFailed, FailedRecords: 5, CreatedAt: day(3, 8)},
+		{TenantID: "globex", Type: models.JobTypeImport, Status: models.JobStatusCompleted, SuccessfulRecords: 7, CreatedAt: day(2, 23)},
+		{TenantID: "globex", Type: models.JobTypeExport, Status: models.JobStatusCompleted, SuccessfulRecords: 7, FileSizeBytes: 512, CreatedAt: day(4, 1)},
+	} {
+		if err := jobs.Create(context.Background(), job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	svc := NewService(memory.NewUsageRepository(db), zerolog.Nop(), config.ReportConfig{RollupDelay: 6 * time.Hour})
+	return svc, db
+}
+
+func TestUsage_AggregatesPerTenant(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	report, err := svc.Usage(context.Background(), day(1, 0), day(4, 0), "")
+	if err != nil {
+		t.Fatalf("Usage() error: %v", err)
+	}
+	if report.RolledUpUntil != nil {
+		t.Errorf("RolledUpUntil = %v, want nil before any rollup", report.RolledUpUntil)
+	}
+
+	want := []*models.TenantUsage{
+		{TenantID: "acme", Jobs: 3, ImportJobs: 2, ExportJobs: 1, FailedJobs: 1, RowsImported: 90, RowsExported: 50, RowsFailed: 15, BytesStored: 2048},
+		{TenantID: "globex", Jobs: 1, ImportJobs: 1, RowsImported: 7},
+	}
+	if !reflect.DeepEqual(report.Tenants, want) {
+		t.Errorf("Tenants = %+v, want %+v", report.Tenants, want)
+	}
+	if report.Totals.Jobs != 4 || report.Totals.RowsImported != 97 {
+		t.Errorf("Totals = %+v, want 4 jobs and 97 rows imported", report.Totals)
+	}
+
+	report, err = svc.Usage(context.Background(), day(1, 0), day(5, 0), "globex")
+	if err != nil {
+		t.Fatalf("Usage() error: %v", err)
+	}
+	if len(report.Tenants) != 1 || report.Tenants[0].ExportJobs != 1 || report.Tenants[0].BytesStored != 0 {
+		t.Errorf("Tenants = %+v, want globex's export without a stored file", report.Tenants)
+	}
+}
+
+func TestRollup_MatchesLiveUsage(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	from, to := day(1, 12), day(5, 0)
+
+	live, err := svc.Usage(ctx, from, to, "")
+	if err != nil {
+		t.Fatalf("Usage() error: %v", err)
+	}
+
+	// Day 4 ended at 00:00 on day 5, less than the delay before 04:00
+	days, err := svc.Rollup(ctx, day(5, 4))
+	if err != nil {
+		t.Fatalf("Rollup() error: %v", err)
+	}
+	if days != 3 {
+		t.Errorf("Rollup() = %d days, want 3", days)
+	}
+
+	rolled, err := svc.Usage(ctx, from, to, "")
+	if err != nil {
+		t.Fatalf("Usage() error: %v", err)
+	}
+	if rolled.RolledUpUntil == nil || !rolled.RolledUpUntil.Equal(day(4, 0)) {
+		t.Errorf("RolledUpUntil = %v, want %v", rolled.RolledUpUntil, day(4, 0))
+	}
+	if !reflect.DeepEqual(rolled.Tenants, live.Tenants) {
+		t.Errorf("Tenants = %+v, want %+v", rolled.Tenants, live.Tenants)
+	}
+
+	// The next run only rolls up the day that has since settled
+	days, err = svc.Rollup(ctx, day(6, 7))
+	if err != nil {
+		t.Fatalf("Rollup() error: %v", err)
+	}
+	if days != 2 {
+		t.Errorf("Rollup() = %d days, want 2", days)
+	}
+}
@@ -0,0 +1,202 @@
+package searchservice
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/internal/search"
+	"github.com/rohit/bulk-import-export/internal/service/hooks"
+	"github.com/rs/zerolog"
+)
+
+// Queue runs index jobs in the background, handing each one's payload, the
+// IDs of the articles to index, to RunIndexJob
+type Queue interface {
+	Submit(job *models.Job, payload any) error
+}
+
+// Service keeps the search index in step with article imports. Registered as
+// an import hook, it collects the IDs of the articles each import writes and,
+// once the import completes, queues an index job that bulk-indexes them.
+type Service struct {
+	hooks.Base
+	client      *search.Client
+	articleRepo repository.ArticleRepository
+	jobRepo     repository.JobRepository
+	logger      zerolog.Logger
+	config      config.SearchConfig
+	queue       Queue
+	mu          sync.Mutex
+	pending     map[uuid.UUID][]uuid.UUID
+}
+
+// NewService creates a new search sync service
+func NewService(
+	client *search.Client,
+	articleRepo repository.ArticleRepository,
+	jobRepo repository.JobRepository,
+	logger zerolog.Logger,
+	cfg config.SearchConfig,
+) *Service {
+	return &Service{
+		client:      client,
+		articleRepo: articleRepo,
+		jobRepo:     jobRepo,
+		logger:      logger,
+		config:      cfg,
+		pending:     make(map[uuid.UUID][]uuid.UUID),
+	}
+}
+
+// SetQueue sets where index jobs are submitted. It must be called before
+// imports run.
+func (s *Service) SetQueue(q Queue) {
+	s.queue = q
+}
+
+// OnBatchInserted implements hooks.Hooks, remembering the articles an import
+// has written
+func (s *Service) OnBatchInserted(ctx context.Context, job *models.Job, resource models.ResourceType, ids []uuid.UUID) {
+	if job.Type != models.JobTypeImport || resource != models.ResourceTypeArticles {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[job.ID] = append(s.pending[job.ID], ids...)
+}
+
+// OnJobComplete implements hooks.Hooks, queuing an index job for the articles
+// written by a completed import
+func (s *Service) OnJobComplete(ctx context.Context, job *models.Job, err error) {
+	s.mu.Lock()
+	ids := s.pending[job.ID]
+	delete(s.pending, job.ID)
+	s.mu.Unlock()
+
+	if err != nil || len(ids) == 0 {
+		return
+	}
+
+	log := s.logger.With().Str("parent_job_id", job.ID.String()).Logger()
+
+	parentID := job.ID
+	indexJob := &models.Job{
+		Type:         models.JobTypeIndex,
+		Resource:     models.ResourceTypeArticles,
+		Status:       models.JobStatusPending,
+		TenantID:     job.TenantID,
+		ParentJobID:  &parentID,
+		TotalRecords: len(ids),
+	}
+	if err := s.jobRepo.Create(ctx, indexJob); err != nil {
+		log.Error().Err(err).Msg("Failed to create search index job")
+		return
+	}
+
+	if s.queue == nil {
+		s.jobRepo.SetFailed(ctx, indexJob.ID, "search index queue is not configured")
+		return
+	}
+	if err := s.queue.Submit(indexJob, ids); err != nil {
+		log.Error().Err(err).Str("job_id", indexJob.ID.String()).Msg("Failed to queue search index job")
+		s.jobRepo.SetFailed(ctx, indexJob.ID, "Failed to queue job: "+err.Error())
+		return
+	}
+
+	log.Info().
+		Str("job_id", indexJob.ID.String()).
+		Int("articles", len(ids)).
+		Msg("Search index job queued")
+}
+
+// RunIndexJob runs an index job queued with the IDs of its articles as its
+// payload. It is the handler of the index job type.
+func (s *Service) RunIndexJob(ctx context.Context, job *models.Job, payload any) error {
+	ids, ok := payload.([]uuid.UUID)
+	if !ok {
+		err := fmt.Errorf("index job payload is %T, not article IDs", payload)
+		s.jobRepo.SetFailed(ctx, job.ID, err.Error())
+		return err
+	}
+	return s.ProcessIndexJob(ctx, job, ids)
+}
+
+// ProcessIndexJob bulk-indexes the given articles in batches of
+// config.BatchSize. Articles deleted since the import are skipped.
+func (s *Service) ProcessIndexJob(ctx context.Context, job *models.Job, ids []uuid.UUID) error {
+	log := s.logger.With().
+		Str("job_id", job.ID.String()).
+		Str("index", s.client.Index()).
+		Logger()
+
+	log.Info().Int("articles", len(ids)).Msg("Starting search index job")
+	startTime := time.Now()
+
+	if err := s.jobRepo.SetStarted(ctx, job.ID); err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+	s.jobRepo.SetTotalRecords(ctx, job.ID, len(ids))
+
+	if err := s.client.EnsureIndex(ctx); err != nil {
+		s.handleJobFailure(ctx, job, log, "Failed to prepare search index: "+err.Error())
+		return err
+	}
+
+	batchSize := s.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	processed, indexed := 0, 0
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		articles, err := s.articleRepo.GetByIDs(ctx, batch)
+		if err != nil {
+			s.handleJobFailure(ctx, job, log, "Failed to load articles: "+err.Error())
+			return err
+		}
+
+		docs := make([]search.Document, 0, len(articles))
+		for _, id := range batch {
+			if article, ok := articles[id]; ok {
+				docs = append(docs, search.Document{ID: id.String(), Body: article})
+			}
+		}
+		if err := s.client.BulkIndex(ctx, docs); err != nil {
+			s.handleJobFailure(ctx, job, log, err.Error())
+			return err
+		}
+
+		processed += len(batch)
+		indexed += len(docs)
+		s.jobRepo.UpdateProgress(ctx, job.ID, processed, indexed, 0)
+	}
+
+	if err := s.jobRepo.SetCompleted(ctx, job.ID, indexed, 0); err != nil {
+		log.Error().Err(err).Msg("Failed to set job as completed")
+	}
+
+	log.Info().
+		Float64("duration_seconds", time.Since(startTime).Seconds()).
+		Int("indexed", indexed).
+		Int("skipped", len(ids)-indexed).
+		Msg("Search index job completed")
+
+	return nil
+}
+
+func (s *Service) handleJobFailure(ctx context.Context, job *models.Job, log zerolog.Logger, errMsg string) {
+	log.Error().Str("error", errMsg).Msg("Search index job failed")
+	s.jobRepo.SetFailed(ctx, job.ID, errMsg)
+}
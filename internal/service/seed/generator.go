@@ -0,0 +1,214 @@
+package seedservice
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// Options sizes the generated rows. Authors and Commented bound how many
+// distinct users write articles and how many articles get comments, which
+// sets the cardinality of the foreign keys.
+type Options struct {
+	Users     int
+	Articles  int
+	Comments  int
+	Authors   int
+	Commented int
+	Tags      int
+	BodyWords int
+	// InvalidPct is the percent of rows that fail validation
+	InvalidPct float64
+	// DuplicatePct is the percent of rows that repeat an earlier row's id
+	DuplicatePct float64
+	Seed         int64
+	// Label names the generated rows: emails are user<n>@<label>.example.com
+	// and slugs <label>-article-<n>. It defaults to "bench".
+	Label string
+}
+
+// Generator derives every id from one seeded source, so users, articles and
+// comments generated together reference each other. The same options give
+// the same rows.
+type Generator struct {
+	opts     Options
+	rnd      *rand.Rand
+	base     time.Time
+	users    []string
+	articles []string
+	words    []string
+}
+
+// NewGenerator creates a generator, filling in the defaults of opts
+func NewGenerator(opts Options) (*Generator, error) {
+	if opts.Users < 0 || opts.Articles < 0 || opts.Comments < 0 {
+		return nil, fmt.Errorf("row counts can't be negative")
+	}
+	if opts.InvalidPct < 0 || opts.InvalidPct > 100 || opts.DuplicatePct < 0 || opts.DuplicatePct > 100 {
+		return nil, fmt.Errorf("percentages must be between 0 and 100")
+	}
+	if opts.Authors <= 0 || opts.Authors > opts.Users {
+		opts.Authors = opts.Users
+	}
+	if opts.Commented <= 0 || opts.Commented > opts.Articles {
+		opts.Commented = opts.Articles
+	}
+	if opts.Tags < 1 {
+		opts.Tags = 1
+	}
+	if opts.Label == "" {
+		opts.Label = "bench"
+	}
+	return &Generator{
+		opts:  opts,
+		rnd:   rand.New(rand.NewSource(opts.Seed)),
+		base:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		words: strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor incididunt ut labore et dolore magna aliqua"),
+	}, nil
+}
+
+// UseUsers makes articles and comments reference ids of users that already
+// exist, for when no users are generated with them
+func (g *Generator) UseUsers(ids []string) {
+	g.users = ids
+}
+
+// UseArticles makes comments reference ids of articles that already exist,
+// for when no articles are generated with them
+func (g *Generator) UseArticles(ids []string) {
+	g.articles = ids
+}
+
+func (g *Generator) id() string {
+	id, _ := uuid.NewRandomFromReader(g.rnd)
+	return id.String()
+}
+
+// pick reports whether a row falls in the given percentage
+func (g *Generator) pick(pct float64) bool {
+	return pct > 0 && g.rnd.Float64()*100 < pct
+}
+
+// rowID returns a new id, or an earlier one for a duplicate row
+func (g *Generator) rowID(earlier []string) string {
+	if len(earlier) > 0 && g.pick(g.opts.DuplicatePct) {
+		return earlier[g.rnd.Intn(len(earlier))]
+	}
+	return g.id()
+}
+
+func (g *Generator) text(words int) string {
+	var b strings.Builder
+	for i := 0; i < words; i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(g.words[g.rnd.Intn(len(g.words))])
+	}
+	return b.String()
+}
+
+// title is the label as it starts a name or title
+func (g *Generator) title() string {
+	return strings.ToUpper(g.opts.Label[:1]) + g.opts.Label[1:]
+}
+
+// WriteUsers writes the users as CSV
+func (g *Generator) WriteUsers(out io.Writer) error {
+	w := bufio.NewWriterSize(out, 1<<20)
+	roles := []string{"admin", "author", "reader"}
+	w.WriteString("id,email,name,role,active,created_at,updated_at\n")
+	g.users = make([]string, 0, g.opts.Authors)
+	for i := 0; i < g.opts.Users; i++ {
+		id := g.rowID(g.users)
+		email := fmt.Sprintf("user%d@%s.example.com", i, g.opts.Label)
+		if g.pick(g.opts.InvalidPct) {
+			email = fmt.Sprintf("user%d-at-%s", i, g.opts.Label)
+		}
+		created := g.base.Add(time.Duration(i) * time.Second)
+		fmt.Fprintf(w, "%s,%s,%s User %d,%s,%t,%s,%s\n",
+			id, email, g.title(), i, roles[i%len(roles)], i%5 != 0,
+			created.Format(time.RFC3339), created.Add(time.Minute).Format(time.RFC3339))
+		if len(g.users) < g.opts.Authors {
+			g.users = append(g.users, id)
+		}
+	}
+	return w.Flush()
+}
+
+// WriteArticles writes the articles as NDJSON. Their authors are the users
+// written before, or those passed to UseUsers.
+func (g *Generator) WriteArticles(out io.Writer) error {
+	if g.opts.Articles > 0 && len(g.users) == 0 {
+		return fmt.Errorf("articles need at least one user")
+	}
+	w := bufio.NewWriterSize(out, 1<<20)
+	statuses := []string{"draft", "published", "archived"}
+	enc := json.NewEncoder(w)
+	g.articles = make([]string, 0, g.opts.Commented)
+	for i := 0; i < g.opts.Articles; i++ {
+		article := models.ArticleImport{
+			ID:       g.rowID(g.articles),
+			Slug:     fmt.Sprintf("%s-article-%d", g.opts.Label, i),
+			Title:    fmt.Sprintf("%s Article %d", g.title(), i),
+			Body:     g.text(g.opts.BodyWords),
+			AuthorID: g.users[g.rnd.Intn(len(g.users))],
+			Tags:     []string{fmt.Sprintf("tag-%d", g.rnd.Intn(g.opts.Tags))},
+			Status:   statuses[i%len(statuses)],
+		}
+		if article.Status == "published" {
+			article.PublishedAt = g.base.Add(time.Duration(i) * time.Second).Format(time.RFC3339)
+		}
+		if g.pick(g.opts.InvalidPct) {
+			article.Slug = "Not A Slug"
+		}
+		if err := enc.Encode(article); err != nil {
+			return err
+		}
+		if len(g.articles) < g.opts.Commented {
+			g.articles = append(g.articles, article.ID)
+		}
+	}
+	return w.Flush()
+}
+
+// WriteComments writes the comments as NDJSON, on the articles and by the
+// users written before or passed to UseArticles and UseUsers
+func (g *Generator) WriteComments(out io.Writer) error {
+	if g.opts.Comments > 0 && len(g.users) == 0 {
+		return fmt.Errorf("comments need at least one user")
+	}
+	if g.opts.Comments > 0 && len(g.articles) == 0 {
+		return fmt.Errorf("comments need at least one article")
+	}
+	w := bufio.NewWriterSize(out, 1<<20)
+	enc := json.NewEncoder(w)
+	var ids []string
+	for i := 0; i < g.opts.Comments; i++ {
+		comment := models.CommentImport{
+			ID:        g.rowID(ids),
+			ArticleID: g.articles[g.rnd.Intn(len(g.articles))],
+			UserID:    g.users[g.rnd.Intn(len(g.users))],
+			Body:      g.text(g.opts.BodyWords/5 + 1),
+			CreatedAt: g.base.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+		}
+		if g.pick(g.opts.InvalidPct) {
+			comment.ArticleID = "not-a-uuid"
+		}
+		if err := enc.Encode(comment); err != nil {
+			return err
+		}
+		// Only a sample of ids is kept as duplicate candidates
+		if len(ids) < 10000 {
+			ids = append(ids, comment.ID)
+		}
+	}
+	return w.Flush()
+}
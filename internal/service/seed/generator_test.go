@@ -0,0 +1,128 @@
+package seedservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func generate(t *testing.T, opts Options) (users, articles, comments string) {
+	t.Helper()
+	g, err := NewGenerator(opts)
+	if err != nil {
+		t.Fatalf("NewGenerator() error: %v", err)
+	}
+	var u, a, c bytes.Buffer
+	if err := g.WriteUsers(&u); err != nil {
+		t.Fatalf("WriteUsers() error: %v", err)
+	}
+	if err := g.WriteArticles(&a); err != nil {
+		t.Fatalf("WriteArticles() error: %v", err)
+	}
+	if err := g.WriteComments(&c); err != nil {
+		t.Fatalf("WriteComments() error: %v", err)
+	}
+	return u.String(), a.String(), c.String()
+}
+
+func TestGenerator_SameSeedSameRows(t *testing.T) {
+	opts := Options{Users: 20, Articles: 10, Comments: 30, InvalidPct: 10, DuplicatePct: 10, Seed: 7}
+	u1, a1, c1 := generate(t, opts)
+	u2, a2, c2 := generate(t, opts)
+	if u1 != u2 || a1 != a2 || c1 != c2 {
+		t.Error("the same options generated different rows")
+	}
+
+	opts.Seed = 8
+	if u3, _, _ := generate(t, opts); u3 == u1 {
+		t.Error("another seed generated the same users")
+	}
+}
+
+func TestGenerator_InvalidAndDuplicateRows(t *testing.T) {
+	users, articles, _ := generate(t, Options{Users: 2000, Articles: 10, InvalidPct: 10, DuplicatePct: 5, Seed: 1, Label: "qa"})
+
+	lines := strings.Split(strings.TrimSpace(users), "\n")[1:]
+	if len(lines) != 2000 {
+		t.Fatalf("users = %d, want 2000", len(lines))
+	}
+	ids := make(map[string]bool)
+	var invalid, duplicates int
+	for _, line := range lines {
+		fields := strings.Split(line, ",")
+		if !strings.Contains(fields[1], "@qa.example.com") {
+			invalid++
+		}
+		if ids[fields[0]] {
+			duplicates++
+		}
+		ids[fields[0]] = true
+	}
+	// 10% and 5% of 2000, give or take the randomness
+	if invalid < 150 || invalid > 250 {
+		t.Errorf("invalid emails = %d, want about 200", invalid)
+	}
+	if duplicates < 60 || duplicates > 140 {
+		t.Errorf("duplicate ids = %d, want about 100", duplicates)
+	}
+
+	var article models.ArticleImport
+	if err := json.Unmarshal([]byte(strings.SplitN(articles, "\n", 2)[0]), &article); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if !ids[article.AuthorID] {
+		t.Errorf("article author %s isn't a generated user", article.AuthorID)
+	}
+}
+
+func TestNewGenerator_RejectsBadOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Users: -1},
+		{Users: 1, InvalidPct: 101},
+		{Users: 1, DuplicatePct: -1},
+	} {
+		if _, err := NewGenerator(opts); err == nil {
+			t.Errorf("NewGenerator(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestService_NewGenerator_UsesExistingRows(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	users := memory.NewUserRepository(db)
+	articles := memory.NewArticleRepository(db)
+	svc := NewService(users, articles, zerolog.Nop())
+
+	// Nothing to reference yet
+	if _, err := svc.NewGenerator(ctx, Options{Articles: 5}); err == nil {
+		t.Fatal("articles without users succeeded, want an error")
+	}
+
+	author := &models.User{ID: uuid.New(), Email: "ann@example.com", Name: "Ann", Role: "author", Active: true}
+	if err := users.Create(ctx, author); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	g, err := svc.NewGenerator(ctx, Options{Articles: 5})
+	if err != nil {
+		t.Fatalf("NewGenerator() error: %v", err)
+	}
+	var out bytes.Buffer
+	if err := g.WriteArticles(&out); err != nil {
+		t.Fatalf("WriteArticles() error: %v", err)
+	}
+	if n := strings.Count(out.String(), author.ID.String()); n != 5 {
+		t.Errorf("articles by the existing user = %d, want 5", n)
+	}
+
+	if _, err := svc.NewGenerator(ctx, Options{Comments: 5}); err == nil {
+		t.Error("comments without articles succeeded, want an error")
+	}
+}
@@ -0,0 +1,120 @@
+// Package seedservice generates synthetic users, articles and comments for
+// load testing the import pipeline
+package seedservice
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rs/zerolog"
+)
+
+// Defaults for the options a seed request doesn't set
+const (
+	defaultAuthors   = 1000
+	defaultTags      = 50
+	defaultBodyWords = 60
+)
+
+// errSampled ends a cursor once enough ids are read
+var errSampled = stderrors.New("sampled")
+
+// Service prepares generators whose rows reference the users and articles
+// already in the database when none are generated with them
+type Service struct {
+	userRepo    repository.UserRepository
+	articleRepo repository.ArticleRepository
+	logger      zerolog.Logger
+}
+
+// NewService creates a new seed service
+func NewService(
+	userRepo repository.UserRepository,
+	articleRepo repository.ArticleRepository,
+	logger zerolog.Logger,
+) *Service {
+	return &Service{
+		userRepo:    userRepo,
+		articleRepo: articleRepo,
+		logger:      logger,
+	}
+}
+
+// NewGenerator creates a generator for opts. Articles generated without
+// users are written by up to Authors existing users, and comments generated
+// without articles go on up to Commented existing articles.
+func (s *Service) NewGenerator(ctx context.Context, opts Options) (*Generator, error) {
+	if opts.Authors <= 0 {
+		opts.Authors = defaultAuthors
+	}
+	if opts.Tags <= 0 {
+		opts.Tags = defaultTags
+	}
+	if opts.BodyWords <= 0 {
+		opts.BodyWords = defaultBodyWords
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if (opts.Articles > 0 || opts.Comments > 0) && opts.Users == 0 {
+		ids, err := s.sampleUsers(ctx, opts.Authors)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("articles and comments need users; generate some or import them first")
+		}
+		g.UseUsers(ids)
+	}
+	if opts.Comments > 0 && opts.Articles == 0 {
+		limit := opts.Commented
+		if limit <= 0 {
+			limit = defaultAuthors
+		}
+		ids, err := s.sampleArticles(ctx, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("comments need articles; generate some or import them first")
+		}
+		g.UseArticles(ids)
+	}
+	return g, nil
+}
+
+// sampleUsers returns the ids of up to limit existing users
+func (s *Service) sampleUsers(ctx context.Context, limit int) ([]string, error) {
+	var ids []string
+	err := s.userRepo.GetAllWithCursor(ctx, &models.ExportFilters{}, limit, func(users []*models.User) error {
+		for _, u := range users {
+			ids = append(ids, u.ID.String())
+		}
+		return errSampled
+	})
+	if err != nil && !stderrors.Is(err, errSampled) {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	return ids, nil
+}
+
+// sampleArticles returns the ids of up to limit existing articles
+func (s *Service) sampleArticles(ctx context.Context, limit int) ([]string, error) {
+	var ids []string
+	err := s.articleRepo.GetAllWithCursor(ctx, &models.ExportFilters{}, limit, func(articles []*models.Article) error {
+		for _, a := range articles {
+			ids = append(ids, a.ID.String())
+		}
+		return errSampled
+	})
+	if err != nil && !stderrors.Is(err, errSampled) {
+		return nil, fmt.Errorf("failed to read articles: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,218 @@
+// Package signingservice manages the keys the service signs export
+// manifests and download URLs with, as described in pkg/signing
+package signingservice
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository"
+	"github.com/rohit/bulk-import-export/pkg/signing"
+	"github.com/rs/zerolog"
+)
+
+// Service signs and verifies with the stored keys. The newest key signs;
+// every stored key verifies, so a rotation doesn't invalidate what was
+// signed before it.
+type Service struct {
+	repo   repository.SigningKeyRepository
+	logger zerolog.Logger
+	config config.SigningConfig
+
+	mu       sync.Mutex
+	ring     *signing.Keyring
+	loadedAt time.Time
+}
+
+// NewService creates a new signing service
+func NewService(repo repository.SigningKeyRepository, logger zerolog.Logger, cfg config.SigningConfig) *Service {
+	return &Service{
+		repo:   repo,
+		logger: logger,
+		config: cfg,
+		ring:   signing.NewKeyring(),
+	}
+}
+
+// Init loads the keys, creating the first one when none is stored
+func (s *Service) Init(ctx context.Context) error {
+	keys, err := s.repo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	if len(keys) == 0 {
+		key, err := s.Rotate(ctx)
+		if err != nil {
+			return err
+		}
+		s.logger.Info().Str("key_id", key.ID).Msg("Created signing key")
+		return nil
+	}
+	s.set(keys)
+	return nil
+}
+
+// keyring returns the keys, reloading them once they are older than
+// KeyRefresh so rotations on other instances are picked up
+func (s *Service) keyring(ctx context.Context) (*signing.Keyring, error) {
+	s.mu.Lock()
+	stale := time.Since(s.loadedAt) >= s.config.KeyRefresh
+	s.mu.Unlock()
+	if stale {
+		keys, err := s.repo.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list signing keys: %w", err)
+		}
+		s.set(keys)
+	}
+	return s.ring, nil
+}
+
+func (s *Service) set(keys []*models.SigningKey) {
+	ring := make([]signing.Key, len(keys))
+	for i, key := range keys {
+		ring[i] = signing.Key{ID: key.ID, Secret: key.Secret, CreatedAt: key.CreatedAt}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ring.Set(ring)
+	s.loadedAt = time.Now()
+}
+
+// Keys returns the stored keys, oldest first
+func (s *Service) Keys(ctx context.Context) ([]*models.SigningKey, error) {
+	return s.repo.List(ctx)
+}
+
+// Key returns the stored key with id
+func (s *Service) Key(ctx context.Context, id string) (*models.SigningKey, error) {
+	keys, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	for _, key := range keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return nil, errors.ErrNotFound("signing key")
+}
+
+// ActiveKeyID returns the ID of the key new signatures are made with
+func (s *Service) ActiveKeyID(ctx context.Context) (string, error) {
+	ring, err := s.keyring(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, _ := ring.Active()
+	return key.ID, nil
+}
+
+// Rotate creates a key, which signs from now on
+func (s *Service) Rotate(ctx context.Context) (*models.SigningKey, error) {
+	key, err := signing.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	stored := &models.SigningKey{ID: key.ID, Secret: key.Secret, CreatedAt: key.CreatedAt}
+	if err := s.repo.Create(ctx, stored); err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	keys, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	s.set(keys)
+	return stored, nil
+}
+
+// Retire deletes a key, so its signatures no longer verify. The active key
+// can't be retired; rotate first.
+func (s *Service) Retire(ctx context.Context, id string) error {
+	active, err := s.ActiveKeyID(ctx)
+	if err != nil {
+		return err
+	}
+	if id == active {
+		return errors.ErrConflict("the active signing key can't be retired; rotate first")
+	}
+
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete signing key: %w", err)
+	}
+	if !deleted {
+		return errors.ErrNotFound("signing key")
+	}
+
+	keys, err := s.repo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	s.set(keys)
+	return nil
+}
+
+// Sign returns the signature of payload, for the signing.Header of a
+// response
+func (s *Service) Sign(ctx context.Context, payload []byte) (string, error) {
+	ring, err := s.keyring(ctx)
+	if err != nil {
+		return "", err
+	}
+	sig, ok := ring.Sign(payload)
+	if !ok {
+		return "", fmt.Errorf("no signing key")
+	}
+	return sig, nil
+}
+
+// SignWithKeyID is Sign that also returns the ID of the key it signed with
+func (s *Service) SignWithKeyID(ctx context.Context, payload []byte) (string, string, error) {
+	ring, err := s.keyring(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	key, ok := ring.Active()
+	if !ok {
+		return "", "", fmt.Errorf("no signing key")
+	}
+	return signing.Sign(key, payload, time.Now()), key.ID, nil
+}
+
+// SignURL returns path signed until the configured URL TTL from now
+func (s *Service) SignURL(ctx context.Context, path string) (string, error) {
+	ring, err := s.keyring(ctx)
+	if err != nil {
+		return "", err
+	}
+	signed, ok := ring.SignURL(path, s.config.URLTTL)
+	if !ok {
+		return "", fmt.Errorf("no signing key")
+	}
+	return signed, nil
+}
+
+// VerifyURL checks a URL signed by SignURL
+func (s *Service) VerifyURL(ctx context.Context, u *url.URL) error {
+	ring, err := s.keyring(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ring.VerifyURL(u); err != nil {
+		return errors.ErrInvalidSignature("invalid download signature: " + err.Error())
+	}
+	return nil
+}
+
+// RequireSignedDownloads reports whether downloads need a signed URL
+func (s *Service) RequireSignedDownloads() bool {
+	return s.config.RequireSignedDownloads
+}
@@ -2,9 +2,11 @@ package validation
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/rohit/bulk-import-export/internal/domain/errors"
@@ -12,13 +14,48 @@ import (
 )
 
 // ArticleValidator validates article data during import
-type ArticleValidator struct{}
+type ArticleValidator struct {
+	maxBodyBytes int  // 0 means unlimited
+	truncateBody bool // cut long bodies to maxBodyBytes instead of rejecting them
+	screener     *Screener
+}
 
 // NewArticleValidator creates a new ArticleValidator
 func NewArticleValidator() *ArticleValidator {
 	return &ArticleValidator{}
 }
 
+// SetBodyLimit caps article bodies at maxBytes (0 means unlimited). Longer
+// bodies are rejected, or cut to the limit by TruncateBody when truncate is
+// set.
+func (v *ArticleValidator) SetBodyLimit(maxBytes int, truncate bool) {
+	v.maxBodyBytes = maxBytes
+	v.truncateBody = truncate
+}
+
+// TruncateBody cuts a body over the limit back to the last whole UTF-8
+// character that fits, when truncation is on, and returns a warning saying
+// so. It returns nil when the body was left alone.
+func (v *ArticleValidator) TruncateBody(row int, article *models.ArticleImport) *errors.ValidationError {
+	if !v.truncateBody || v.maxBodyBytes <= 0 || len(article.Body) <= v.maxBodyBytes {
+		return nil
+	}
+
+	original := len(article.Body)
+	cut := v.maxBodyBytes
+	for cut > 0 && !utf8.RuneStart(article.Body[cut]) {
+		cut--
+	}
+	article.Body = article.Body[:cut]
+
+	identifier := article.Slug
+	if identifier == "" {
+		identifier = article.ID
+	}
+	return errors.NewValidationError(row, identifier, "body", errors.WarnCodeBodyTruncated,
+		fmt.Sprintf("Body of %d bytes truncated to %d bytes", original, cut))
+}
+
 // Kebab-case slug pattern
 var slugRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
 
@@ -30,6 +67,32 @@ func (v *ArticleValidator) IsValidSlug(slug string) bool {
 	return slugRegex.MatchString(slug)
 }
 
+// SanitizeBody cleans the body with SanitizeText and returns a warning
+// naming what changed, or nil if the body was already clean
+func (v *ArticleValidator) SanitizeBody(row int, article *models.ArticleImport) *errors.ValidationError {
+	identifier := article.Slug
+	if identifier == "" {
+		identifier = article.ID
+	}
+	return sanitizeBody(row, identifier, &article.Body)
+}
+
+// SetScreener screens article bodies with screener (nil screens nothing)
+func (v *ArticleValidator) SetScreener(screener *Screener) {
+	v.screener = screener
+}
+
+// ScreenBody screens the body for banned terms and personal data, returning
+// the error rejecting the row or the warning saying what was redacted or
+// flagged
+func (v *ArticleValidator) ScreenBody(row int, article *models.ArticleImport) (*errors.ValidationError, *errors.ValidationError) {
+	identifier := article.Slug
+	if identifier == "" {
+		identifier = article.ID
+	}
+	return v.screener.screenBody(row, identifier, &article.Body)
+}
+
 // ValidateArticleImport validates an article import record
 func (v *ArticleValidator) ValidateArticleImport(row int, article *models.ArticleImport) []*errors.ValidationError {
 	var errs []*errors.ValidationError
@@ -54,16 +117,25 @@ func (v *ArticleValidator) ValidateArticleImport(row int, article *models.Articl
 		errs = append(errs, errors.NewValidationError(row, identifier, "slug", errors.ErrCodeInvalidSlug, "Slug must be at most 255 characters"))
 	}
 
-	// Validate title (required, max 500 chars)
-	if article.Title == "" {
+	// Validate title (required, max 500 chars, as it will be stored)
+	title := StripInvisible(article.Title)
+	if title == "" {
 		errs = append(errs, errors.NewValidationError(row, identifier, "title", errors.ErrCodeMissingField, "Title is required"))
-	} else if len(article.Title) > 500 {
+	} else if hasControlChars(title, false) {
+		errs = append(errs, errors.NewValidationError(row, identifier, "title", errors.ErrCodeInvalidTitle, "Title must not contain control characters"))
+	} else if CharCount(title) > 500 {
 		errs = append(errs, errors.NewValidationError(row, identifier, "title", errors.ErrCodeInvalidTitle, "Title must be at most 500 characters"))
 	}
 
-	// Validate body (required)
+	// Validate body (required, at most the configured size, which is in
+	// bytes, and no control characters besides line breaks and tabs)
 	if article.Body == "" {
 		errs = append(errs, errors.NewValidationError(row, identifier, "body", errors.ErrCodeMissingField, "Body is required"))
+	} else if v.maxBodyBytes > 0 && len(article.Body) > v.maxBodyBytes {
+		errs = append(errs, errors.NewValidationError(row, identifier, "body", errors.ErrCodeBodyTooLong,
+			fmt.Sprintf("Body must be at most %d bytes", v.maxBodyBytes)))
+	} else if hasControlChars(article.Body, true) {
+		errs = append(errs, errors.NewValidationError(row, identifier, "body", errors.ErrCodeInvalidBody, controlCharsInBody))
 	}
 
 	// Validate author_id (required, must be valid UUID)
@@ -105,7 +177,12 @@ func (v *ArticleValidator) ValidateArticleImport(row int, article *models.Articl
 			errs = append(errs, errors.NewValidationError(row, identifier, "tags", errors.ErrCodeInvalidTags, "Maximum 100 tags allowed"))
 		}
 		for _, tag := range article.Tags {
-			if len(tag) > 50 {
+			tag = StripInvisible(tag)
+			if hasControlChars(tag, false) {
+				errs = append(errs, errors.NewValidationError(row, identifier, "tags", errors.ErrCodeInvalidTags, "Tags must not contain control characters"))
+				break
+			}
+			if CharCount(tag) > 50 {
 				errs = append(errs, errors.NewValidationError(row, identifier, "tags", errors.ErrCodeInvalidTags, "Each tag must be at most 50 characters"))
 				break
 			}
@@ -1,8 +1,10 @@
 package validation
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 )
 
@@ -185,6 +187,68 @@ func TestArticleValidator_ValidateArticleImport(t *testing.T) {
 			wantValid:   false,
 			wantErrCode: "INVALID_UUID",
 		},
+		{
+			// 500 CJK characters are 1500 bytes
+			name: "valid CJK title at the character limit with emoji tags",
+			article: &models.ArticleImport{
+				Slug:     "cjk-title",
+				Title:    strings.Repeat("記", 500),
+				Body:     "日本語の本文です。\n\t二行目 🎉",
+				AuthorID: "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+				Tags:     []string{strings.Repeat("タ", 50), "🚀"},
+				Status:   "draft",
+			},
+			wantValid: true,
+		},
+		{
+			name: "CJK title over the character limit",
+			article: &models.ArticleImport{
+				Slug:     "cjk-title",
+				Title:    strings.Repeat("記", 501),
+				Body:     "Content",
+				AuthorID: "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+				Status:   "draft",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_TITLE",
+		},
+		{
+			name: "title with a line break",
+			article: &models.ArticleImport{
+				Slug:     "two-lines",
+				Title:    "First\nSecond",
+				Body:     "Content",
+				AuthorID: "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+				Status:   "draft",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_TITLE",
+		},
+		{
+			name: "tag over the character limit",
+			article: &models.ArticleImport{
+				Slug:     "long-tag",
+				Title:    "Long tag",
+				Body:     "Content",
+				AuthorID: "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+				Tags:     []string{strings.Repeat("タ", 51)},
+				Status:   "draft",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_TAGS",
+		},
+		{
+			name: "body with control characters",
+			article: &models.ArticleImport{
+				Slug:     "bell-body",
+				Title:    "Bell",
+				Body:     "Ding\x07 dong\x00",
+				AuthorID: "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+				Status:   "draft",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_BODY",
+		},
 	}
 
 	for _, tt := range tests {
@@ -250,3 +314,69 @@ func TestArticleValidator_IsValidSlug(t *testing.T) {
 		}
 	}
 }
+
+func TestArticleValidator_BodyLimit(t *testing.T) {
+	newArticle := func(body string) *models.ArticleImport {
+		return &models.ArticleImport{
+			Slug:     "long-read",
+			Title:    "Long Read",
+			Body:     body,
+			AuthorID: "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+			Status:   "draft",
+		}
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		validator := NewArticleValidator()
+		validator.SetBodyLimit(10, false)
+
+		article := newArticle("0123456789a")
+		if warn := validator.TruncateBody(2, article); warn != nil {
+			t.Errorf("TruncateBody() = %v, want nil without truncation", warn)
+		}
+		errs := validator.ValidateArticleImport(2, article)
+		if len(errs) != 1 || errs[0].Code != errors.ErrCodeBodyTooLong {
+			t.Errorf("ValidateArticleImport() = %v, want one %s", errs, errors.ErrCodeBodyTooLong)
+		}
+		if errs := validator.ValidateArticleImport(2, newArticle("0123456789")); len(errs) != 0 {
+			t.Errorf("ValidateArticleImport() = %v for a body at the limit, want none", errs)
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		validator := NewArticleValidator()
+		validator.SetBodyLimit(10, true)
+
+		// "é" takes two bytes, so the cut backs off to keep it whole
+		article := newArticle("012345678é tail")
+		warn := validator.TruncateBody(3, article)
+		if warn == nil || warn.Code != errors.WarnCodeBodyTruncated || warn.RowNumber != 3 {
+			t.Fatalf("TruncateBody() = %v, want a %s warning for row 3", warn, errors.WarnCodeBodyTruncated)
+		}
+		if article.Body != "012345678" {
+			t.Errorf("Body = %q, want %q", article.Body, "012345678")
+		}
+		if errs := validator.ValidateArticleImport(3, article); len(errs) != 0 {
+			t.Errorf("ValidateArticleImport() = %v after truncation, want none", errs)
+		}
+	})
+}
+
+func BenchmarkArticleValidator_ValidateArticleImport(b *testing.B) {
+	validator := NewArticleValidator()
+	article := &models.ArticleImport{
+		ID:          "de9f2098-3528-42a8-bc6a-1f13ee5f6247",
+		Slug:        "bench-article",
+		Title:       "Bench Article",
+		Body:        "Article body content",
+		AuthorID:    "16b0c588-6f4b-4812-8fea-a39692850695",
+		Tags:        []string{"go"},
+		PublishedAt: "2024-01-15T10:30:00Z",
+		Status:      "published",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validator.ValidateArticleImport(i, article)
+	}
+}
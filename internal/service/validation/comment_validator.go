@@ -11,13 +11,33 @@ import (
 )
 
 // CommentValidator validates comment data during import
-type CommentValidator struct{}
+type CommentValidator struct {
+	screener *Screener
+}
 
 // NewCommentValidator creates a new CommentValidator
 func NewCommentValidator() *CommentValidator {
 	return &CommentValidator{}
 }
 
+// SanitizeBody cleans the body with SanitizeText and returns a warning
+// naming what changed, or nil if the body was already clean
+func (v *CommentValidator) SanitizeBody(row int, comment *models.CommentImport) *errors.ValidationError {
+	return sanitizeBody(row, comment.ID, &comment.Body)
+}
+
+// SetScreener screens comment bodies with screener (nil screens nothing)
+func (v *CommentValidator) SetScreener(screener *Screener) {
+	v.screener = screener
+}
+
+// ScreenBody screens the body for banned terms and personal data, returning
+// the error rejecting the row or the warning saying what was redacted or
+// flagged
+func (v *CommentValidator) ScreenBody(row int, comment *models.CommentImport) (*errors.ValidationError, *errors.ValidationError) {
+	return v.screener.screenBody(row, comment.ID, &comment.Body)
+}
+
 // ValidateCommentImport validates a comment import record
 func (v *CommentValidator) ValidateCommentImport(row int, comment *models.CommentImport) []*errors.ValidationError {
 	var errs []*errors.ValidationError
@@ -55,6 +75,8 @@ func (v *CommentValidator) ValidateCommentImport(row int, comment *models.Commen
 		if wordCount > models.MaxCommentWords {
 			errs = append(errs, errors.NewValidationError(row, identifier, "body", errors.ErrCodeBodyTooLong,
 				"Comment body exceeds maximum of 500 words"))
+		} else if hasControlChars(comment.Body, true) {
+			errs = append(errs, errors.NewValidationError(row, identifier, "body", errors.ErrCodeInvalidBody, controlCharsInBody))
 		}
 	}
 
@@ -68,6 +90,18 @@ func (v *CommentValidator) ValidateCommentImport(row int, comment *models.Commen
 	return errs
 }
 
+// WarnCommentImport reports fields of a valid comment that will be filled in
+// on import rather than taken from the file
+func (v *CommentValidator) WarnCommentImport(row int, comment *models.CommentImport) []*errors.ValidationError {
+	var warns []*errors.ValidationError
+
+	if comment.CreatedAt == "" {
+		warns = append(warns, errors.NewValidationError(row, comment.ID, "created_at", errors.WarnCodeCreatedAtDefaulted, "Created at not provided; set to the import time"))
+	}
+
+	return warns
+}
+
 // ConvertToComment converts a validated CommentImport to a Comment model
 func (v *CommentValidator) ConvertToComment(ci *models.CommentImport) (*models.Comment, error) {
 	comment := &models.Comment{
@@ -26,6 +26,17 @@ func TestCommentValidator_ValidateCommentImport(t *testing.T) {
 			},
 			wantValid: true,
 		},
+		{
+			name: "body with control characters",
+			comment: &models.CommentImport{
+				ID:        "27d7a89e-d996-4d21-8a07-a7ac4cda5c0b",
+				ArticleID: "de9f2098-3528-42a8-bc6a-1f13ee5f6247",
+				UserID:    "16b0c588-6f4b-4812-8fea-a39692850695",
+				Body:      "素晴らしい\x1b[31m記事\x1b[0m",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_BODY",
+		},
 		{
 			name: "missing body",
 			comment: &models.CommentImport{
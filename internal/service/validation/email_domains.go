@@ -0,0 +1,73 @@
+package validation
+
+import "strings"
+
+// DisposableDomains are well-known disposable email providers, denied with
+// UserValidator.SetDomainPolicy when denyDisposable is set
+var DisposableDomains = []string{
+	"10minutemail.com",
+	"burnermail.io",
+	"dispostable.com",
+	"emailondeck.com",
+	"fakeinbox.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"guerrillamail.net",
+	"guerrillamailblock.com",
+	"mailcatch.com",
+	"maildrop.cc",
+	"mailinator.com",
+	"mailnesia.com",
+	"mintemail.com",
+	"mohmal.com",
+	"sharklasers.com",
+	"spamgourmet.com",
+	"temp-mail.org",
+	"tempail.com",
+	"tempmail.com",
+	"tempr.email",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+// domainSet matches email domains against a list of domains, each of which
+// also covers its subdomains
+type domainSet map[string]bool
+
+func newDomainSet(domains []string) domainSet {
+	if len(domains) == 0 {
+		return nil
+	}
+	set := make(domainSet, len(domains))
+	for _, d := range domains {
+		if d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), "."); d != "" {
+			set[d] = true
+		}
+	}
+	return set
+}
+
+// match reports whether domain or one of its parent domains is in the set
+func (s domainSet) match(domain string) bool {
+	for domain != "" {
+		if s[domain] {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return false
+		}
+		domain = parent
+	}
+	return false
+}
+
+// EmailDomain returns the lowercased domain of email, or "" if it has none
+func EmailDomain(email string) string {
+	i := strings.LastIndexByte(email, '@')
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[i+1:]))
+}
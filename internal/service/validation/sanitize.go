@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+)
+
+var (
+	// Script elements with their content, and stray script tags left over
+	scriptElementRegex = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>`)
+	scriptTagRegex     = regexp.MustCompile(`(?i)</?script\b[^>]*>`)
+	// Inline event handlers such as onclick="..." inside an HTML tag
+	htmlTagRegex      = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+	eventHandlerRegex = regexp.MustCompile(`(?i)\s+on[a-z]+\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	// Runs of two or more blank lines
+	blankLinesRegex = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+)
+
+// Sanitization steps reported in BODY_SANITIZED warnings
+const (
+	SanitizedInvalidUTF8   = "removed invalid UTF-8"
+	SanitizedScripts       = "removed script"
+	SanitizedControlChars  = "removed control characters"
+	SanitizedMarkdownSpace = "normalized line breaks"
+)
+
+// SanitizeText drops invalid UTF-8, strips script elements and inline event
+// handlers, removes control characters other than line breaks and tabs, and
+// normalizes markdown line breaks: CRLF to LF, runs of blank lines to one,
+// and no leading or trailing blank lines. It returns the cleaned text and the
+// steps that changed it, in that order.
+func SanitizeText(text string) (string, []string) {
+	var steps []string
+	apply := func(step string, fn func(string) string) {
+		if cleaned := fn(text); cleaned != text {
+			text = cleaned
+			steps = append(steps, step)
+		}
+	}
+
+	apply(SanitizedInvalidUTF8, func(s string) string {
+		return strings.ToValidUTF8(s, "")
+	})
+	apply(SanitizedScripts, func(s string) string {
+		s = scriptElementRegex.ReplaceAllString(s, "")
+		s = scriptTagRegex.ReplaceAllString(s, "")
+		return htmlTagRegex.ReplaceAllStringFunc(s, func(tag string) string {
+			return eventHandlerRegex.ReplaceAllString(tag, "")
+		})
+	})
+	apply(SanitizedControlChars, func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+				return -1
+			}
+			return r
+		}, s)
+	})
+	apply(SanitizedMarkdownSpace, func(s string) string {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		s = strings.ReplaceAll(s, "\r", "\n")
+		s = blankLinesRegex.ReplaceAllString(s, "\n\n")
+		return strings.Trim(s, "\n")
+	})
+
+	return text, steps
+}
+
+// sanitizeBody sanitizes *body in place and returns a BODY_SANITIZED warning
+// naming what changed, or nil if it was already clean
+func sanitizeBody(row int, identifier string, body *string) *errors.ValidationError {
+	cleaned, steps := SanitizeText(*body)
+	if len(steps) == 0 {
+		return nil
+	}
+	*body = cleaned
+	return errors.NewValidationError(row, identifier, "body", errors.WarnCodeBodySanitized,
+		"Body sanitized: "+strings.Join(steps, ", "))
+}
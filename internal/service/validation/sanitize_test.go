@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		wantSteps []string
+	}{
+		{
+			name: "clean text is left alone",
+			text: "# Title\n\nSome *markdown* with <b>html</b>.",
+			want: "# Title\n\nSome *markdown* with <b>html</b>.",
+		},
+		{
+			name:      "script elements and stray tags",
+			text:      "Hi<script type=\"text/javascript\">alert(1)</script> there<SCRIPT src=x>",
+			want:      "Hi there",
+			wantSteps: []string{SanitizedScripts},
+		},
+		{
+			name:      "inline event handlers",
+			text:      `<img src="a.png" onerror="alert(1)" alt=x> onload = fine in prose`,
+			want:      `<img src="a.png" alt=x> onload = fine in prose`,
+			wantSteps: []string{SanitizedScripts},
+		},
+		{
+			name:      "control characters",
+			text:      "tab\tkept\x00 null\x1b[0m gone",
+			want:      "tab\tkept null[0m gone",
+			wantSteps: []string{SanitizedControlChars},
+		},
+		{
+			name:      "line breaks",
+			text:      "\r\nfirst\r\n\r\n\r\n  \nsecond\rthird\n\n",
+			want:      "first\n\nsecond\nthird",
+			wantSteps: []string{SanitizedMarkdownSpace},
+		},
+		{
+			name:      "invalid UTF-8",
+			text:      "caf\xc3\xa9 \xff\xfe",
+			want:      "café ",
+			wantSteps: []string{SanitizedInvalidUTF8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, steps := SanitizeText(tt.text)
+			if got != tt.want {
+				t.Errorf("SanitizeText() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(steps, tt.wantSteps) {
+				t.Errorf("SanitizeText() steps = %v, want %v", steps, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestCommentValidator_SanitizeBody(t *testing.T) {
+	validator := NewCommentValidator()
+
+	comment := &models.CommentImport{ID: "c1", Body: "Nice post!<script>steal()</script>\x07"}
+	warn := validator.SanitizeBody(5, comment)
+	if warn == nil || warn.Code != errors.WarnCodeBodySanitized || warn.RowNumber != 5 {
+		t.Fatalf("SanitizeBody() = %v, want a %s warning for row 5", warn, errors.WarnCodeBodySanitized)
+	}
+	if comment.Body != "Nice post!" {
+		t.Errorf("Body = %q, want %q", comment.Body, "Nice post!")
+	}
+	if warn := validator.SanitizeBody(5, comment); warn != nil {
+		t.Errorf("SanitizeBody() = %v for a clean body, want nil", warn)
+	}
+}
@@ -0,0 +1,229 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+)
+
+// Kinds of content a Scanner finds
+const (
+	FindingBannedTerm = "banned_term"
+	FindingCreditCard = "credit_card"
+	FindingSSN        = "ssn"
+)
+
+// Screening modes, saying what happens to a body a Scanner finds something in
+const (
+	ScreenOff = "off"
+	// ScreenReject rejects the row with BANNED_TERM or PII_DETECTED
+	ScreenReject = "reject"
+	// ScreenRedact replaces what was found with Redaction and imports the
+	// row with a CONTENT_REDACTED warning
+	ScreenRedact = "redact"
+	// ScreenFlag imports the row as it is with a CONTENT_FLAGGED warning
+	ScreenFlag = "flag"
+)
+
+// Redaction replaces the content a redacting Screener removes
+const Redaction = "[REDACTED]"
+
+// Finding is a span of text a Scanner matched, in byte offsets
+type Finding struct {
+	Kind  string
+	Start int
+	End   int
+}
+
+// Scanner finds banned terms or personal data in free text. Implementations
+// must be safe for concurrent use.
+type Scanner interface {
+	Scan(text string) []Finding
+}
+
+var (
+	// Runs of 13 to 19 digits, optionally grouped by single spaces or
+	// hyphens, checked for consistent grouping and with the Luhn algorithm
+	// before they count
+	cardRegex = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// US social security numbers, written with hyphens
+	ssnRegex = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+)
+
+// RegexScanner is the built-in Scanner. It matches banned terms as whole
+// words in any case and, with pii set, credit card numbers and US social
+// security numbers.
+type RegexScanner struct {
+	terms *regexp.Regexp // nil without banned terms
+	pii   bool
+}
+
+// NewRegexScanner creates a scanner for terms, and personal data when pii is
+// set
+func NewRegexScanner(terms []string, pii bool) *RegexScanner {
+	s := &RegexScanner{pii: pii}
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+	}
+	if len(quoted) > 0 {
+		s.terms = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return s
+}
+
+// Scan implements Scanner
+func (s *RegexScanner) Scan(text string) []Finding {
+	var findings []Finding
+	if s.terms != nil {
+		for _, m := range s.terms.FindAllStringIndex(text, -1) {
+			findings = append(findings, Finding{Kind: FindingBannedTerm, Start: m[0], End: m[1]})
+		}
+	}
+	if !s.pii {
+		return findings
+	}
+	for _, m := range cardRegex.FindAllStringIndex(text, -1) {
+		if number := text[m[0]:m[1]]; !(strings.Contains(number, " ") && strings.Contains(number, "-")) && luhn(number) {
+			findings = append(findings, Finding{Kind: FindingCreditCard, Start: m[0], End: m[1]})
+		}
+	}
+	for _, m := range ssnRegex.FindAllStringSubmatchIndex(text, -1) {
+		area, group, serial := text[m[2]:m[3]], text[m[4]:m[5]], text[m[6]:m[7]]
+		if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+			continue
+		}
+		findings = append(findings, Finding{Kind: FindingSSN, Start: m[0], End: m[1]})
+	}
+	return findings
+}
+
+// luhn reports whether the digits of number pass the Luhn checksum
+func luhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// Screener screens bodies with a Scanner, rejecting, redacting or flagging
+// the ones it finds something in
+type Screener struct {
+	scanner Scanner
+	mode    string
+}
+
+// NewScreener creates a screener applying mode to what scanner finds. It
+// returns nil, which screens nothing, for ScreenOff or an empty mode.
+func NewScreener(scanner Scanner, mode string) *Screener {
+	if scanner == nil || mode == "" || mode == ScreenOff {
+		return nil
+	}
+	return &Screener{scanner: scanner, mode: mode}
+}
+
+// screenBody scans *body and returns the error rejecting the row, or the
+// warning saying what was redacted or flagged. A redacted body is replaced
+// in place. Neither names the content found, which may be personal data.
+func (s *Screener) screenBody(row int, identifier string, body *string) (*errors.ValidationError, *errors.ValidationError) {
+	if s == nil || *body == "" {
+		return nil, nil
+	}
+	findings := s.scanner.Scan(*body)
+	if len(findings) == 0 {
+		return nil, nil
+	}
+
+	found := describeFindings(findings)
+	switch s.mode {
+	case ScreenRedact:
+		*body = redact(*body, findings)
+		return nil, errors.NewValidationError(row, identifier, "body", errors.WarnCodeContentRedacted, "Redacted "+found)
+	case ScreenFlag:
+		return nil, errors.NewValidationError(row, identifier, "body", errors.WarnCodeContentFlagged, "Body contains "+found)
+	}
+	code := errors.ErrCodePIIDetected
+	for _, f := range findings {
+		if f.Kind == FindingBannedTerm {
+			code = errors.ErrCodeBannedTerm
+			break
+		}
+	}
+	return errors.NewValidationError(row, identifier, "body", code, "Body contains "+found), nil
+}
+
+// findingNames are how findings are counted in messages
+var findingNames = map[string][2]string{
+	FindingBannedTerm: {"banned term", "banned terms"},
+	FindingCreditCard: {"credit card number", "credit card numbers"},
+	FindingSSN:        {"social security number", "social security numbers"},
+}
+
+// describeFindings counts findings by kind, such as "1 banned term and 2
+// credit card numbers", in order of their first appearance
+func describeFindings(findings []Finding) string {
+	counts := make(map[string]int)
+	var kinds []string
+	for _, f := range findings {
+		if counts[f.Kind] == 0 {
+			kinds = append(kinds, f.Kind)
+		}
+		counts[f.Kind]++
+	}
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		names, ok := findingNames[kind]
+		if !ok {
+			names = [2]string{kind, kind}
+		}
+		name := names[0]
+		if counts[kind] > 1 {
+			name = names[1]
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", counts[kind], name))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+// redact replaces the spans of findings in text with Redaction, merging
+// spans that overlap
+func redact(text string, findings []Finding) string {
+	sorted := append([]Finding(nil), findings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var b strings.Builder
+	last := 0
+	for _, f := range sorted {
+		if f.End <= last {
+			continue
+		}
+		if f.Start >= last {
+			b.WriteString(text[last:f.Start])
+			b.WriteString(Redaction)
+		}
+		last = f.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestRegexScanner_Scan(t *testing.T) {
+	scanner := NewRegexScanner([]string{"darn", " heck it ", ""}, true)
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "clean text", text: "A perfectly polite comment, order #4111 shipped."},
+		{name: "banned terms as whole words in any case", text: "Darn it. HECK IT. darned", want: []string{"Darn", "HECK IT"}},
+		{name: "card number", text: "card 4111 1111 1111 1111 exp 12/29", want: []string{"4111 1111 1111 1111"}},
+		{name: "hyphenated card number", text: "5500-0000-0000-0004.", want: []string{"5500-0000-0000-0004"}},
+		{name: "digits failing the Luhn check", text: "tracking 4111111111111112"},
+		{name: "social security number", text: "ssn 123-45-6789 and again 123-45-6789", want: []string{"123-45-6789", "123-45-6789"}},
+		{name: "numbers never issued as SSNs", text: "000-12-3456 666-12-3456 912-34-5678 123-00-4567 123-45-0000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range scanner.Scan(tt.text) {
+				got = append(got, tt.text[f.Start:f.End])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scan() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if findings := NewRegexScanner(nil, false).Scan("123-45-6789"); len(findings) != 0 {
+		t.Errorf("Scan() without pii = %+v, want nothing", findings)
+	}
+}
+
+func TestScreener_Modes(t *testing.T) {
+	scanner := NewRegexScanner([]string{"darn"}, true)
+	const body = "Darn, my card 4111 1111 1111 1111 and ssn 123-45-6789"
+
+	tests := []struct {
+		mode     string
+		wantErr  string
+		wantWarn string
+		wantBody string
+	}{
+		{mode: ScreenReject, wantErr: errors.ErrCodeBannedTerm, wantBody: body},
+		{mode: ScreenRedact, wantWarn: errors.WarnCodeContentRedacted, wantBody: "[REDACTED], my card [REDACTED] and ssn [REDACTED]"},
+		{mode: ScreenFlag, wantWarn: errors.WarnCodeContentFlagged, wantBody: body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			validator := NewCommentValidator()
+			validator.SetScreener(NewScreener(scanner, tt.mode))
+			comment := &models.CommentImport{ID: "c1", Body: body}
+			verr, warn := validator.ScreenBody(3, comment)
+
+			got := verr
+			if tt.wantWarn != "" {
+				got = warn
+			}
+			code := tt.wantErr + tt.wantWarn
+			if got == nil || got.Code != code || got.RowNumber != 3 || got.FieldName != "body" {
+				t.Fatalf("ScreenBody() = %v, %v; want %s for row 3", verr, warn, code)
+			}
+			if want := "1 banned term, 1 credit card number and 1 social security number"; !strings.HasSuffix(got.Message, want) {
+				t.Errorf("message = %q, want it to count %q", got.Message, want)
+			}
+			if strings.Contains(got.Message, "4111") || strings.Contains(got.Message, "Darn") {
+				t.Errorf("message %q repeats the content found", got.Message)
+			}
+			if comment.Body != tt.wantBody {
+				t.Errorf("Body = %q, want %q", comment.Body, tt.wantBody)
+			}
+		})
+	}
+
+	// Personal data alone is rejected as such
+	validator := NewArticleValidator()
+	validator.SetScreener(NewScreener(scanner, ScreenReject))
+	if verr, _ := validator.ScreenBody(1, &models.ArticleImport{Slug: "post", Body: "call 123-45-6789"}); verr == nil || verr.Code != errors.ErrCodePIIDetected || verr.RecordIdentifier != "post" {
+		t.Errorf("ScreenBody() = %v, want %s for post", verr, errors.ErrCodePIIDetected)
+	}
+
+	if NewScreener(scanner, ScreenOff) != nil {
+		t.Error("NewScreener(off) screens bodies")
+	}
+	validator.SetScreener(nil)
+	if verr, warn := validator.ScreenBody(1, &models.ArticleImport{Body: "darn"}); verr != nil || warn != nil {
+		t.Errorf("ScreenBody() without a screener = %v, %v; want nothing", verr, warn)
+	}
+}
+
+func TestRedact_Overlapping(t *testing.T) {
+	text := "abcdefghij"
+	got := redact(text, []Finding{{Start: 6, End: 8}, {Start: 1, End: 4}, {Start: 2, End: 5}})
+	if want := "a[REDACTED]f[REDACTED]ij"; got != want {
+		t.Errorf("redact() = %q, want %q", got, want)
+	}
+}
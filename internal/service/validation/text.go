@@ -0,0 +1,56 @@
+package validation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharCount is the length of s in characters, the unit the VARCHAR limits of
+// the main tables count in, so multi-byte names aren't held to a byte count
+func CharCount(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// invisible reports whether r renders as nothing and only gets in the way:
+// zero-width spaces, word joiners, byte order marks, soft hyphens and
+// bidirectional embeddings and overrides. Zero-width joiners and non-joiners
+// are kept, as emoji sequences and Indic and Persian scripts rely on them.
+func invisible(r rune) bool {
+	switch r {
+	case '\u200B', '\u2060', '\uFEFF', '\u00AD':
+		return true
+	}
+	return r >= '\u202A' && r <= '\u202E'
+}
+
+// StripInvisible removes invisible characters from a single-line field such
+// as a name, title or tag, and trims the whitespace around it. Imports store
+// these fields stripped, and validate them as they will be stored.
+func StripInvisible(s string) string {
+	if strings.IndexFunc(s, invisible) >= 0 {
+		s = strings.Map(func(r rune) rune {
+			if invisible(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	return strings.TrimSpace(s)
+}
+
+// controlCharsInBody is the message bodies with control characters are
+// rejected with
+const controlCharsInBody = "Body must not contain control characters other than line breaks and tabs; import with sanitize=true to strip them"
+
+// hasControlChars reports whether s contains control characters. Multi-line
+// text such as a body may contain line breaks and tabs; single-line fields
+// may not.
+func hasControlChars(s string, multiline bool) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		if multiline && (r == '\n' || r == '\r' || r == '\t') {
+			return false
+		}
+		return unicode.IsControl(r)
+	}) >= 0
+}
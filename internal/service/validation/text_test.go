@@ -0,0 +1,35 @@
+package validation
+
+import "testing"
+
+func TestStripInvisible(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Ada Lovelace", "Ada Lovelace"},
+		{"zero-width space and BOM", "\uFEFFAda\u200B Lovelace", "Ada Lovelace"},
+		{"soft hyphen and word joiner", "Love\u00ADlace\u2060", "Lovelace"},
+		{"bidi override", "\u202EecalevoL\u202C", "ecalevoL"},
+		{"surrounding whitespace", "  山田 太郎 ", "山田 太郎"},
+		// Joiners hold emoji sequences and Persian words together
+		{"emoji joiner kept", "👩\u200D💻", "👩\u200D💻"},
+		{"non-joiner kept", "می\u200Cخواهم", "می\u200Cخواهم"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripInvisible(tt.in); got != tt.want {
+				t.Errorf("StripInvisible(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCharCount(t *testing.T) {
+	for in, want := range map[string]int{"abc": 3, "山田": 2, "🎉🎉": 2, "é": 1} {
+		if got := CharCount(in); got != want {
+			t.Errorf("CharCount(%q) = %d, want %d (%d bytes)", in, got, want, len(in))
+		}
+	}
+}
@@ -11,13 +11,36 @@ import (
 )
 
 // UserValidator validates user data during import
-type UserValidator struct{}
+type UserValidator struct {
+	allowDomains domainSet // nil allows any domain not denied
+	denyDomains  domainSet
+}
 
 // NewUserValidator creates a new UserValidator
 func NewUserValidator() *UserValidator {
 	return &UserValidator{}
 }
 
+// SetDomainPolicy limits the email domains users may have. A domain in deny,
+// or in DisposableDomains when denyDisposable is set, is rejected; when allow
+// is not empty, only its domains are accepted. Each entry also covers its
+// subdomains, and deny wins over allow.
+func (v *UserValidator) SetDomainPolicy(allow, deny []string, denyDisposable bool) {
+	if denyDisposable {
+		deny = append(append([]string(nil), deny...), DisposableDomains...)
+	}
+	v.allowDomains = newDomainSet(allow)
+	v.denyDomains = newDomainSet(deny)
+}
+
+// domainAllowed reports whether the domain policy admits users at domain
+func (v *UserValidator) domainAllowed(domain string) bool {
+	if v.denyDomains.match(domain) {
+		return false
+	}
+	return v.allowDomains == nil || v.allowDomains.match(domain)
+}
+
 // Email regex pattern
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
@@ -41,12 +64,17 @@ func (v *UserValidator) ValidateUserImport(row int, user *models.UserImport) []*
 		errs = append(errs, errors.NewValidationError(row, identifier, "email", errors.ErrCodeMissingField, "Email is required"))
 	} else if !emailRegex.MatchString(user.Email) {
 		errs = append(errs, errors.NewValidationError(row, identifier, "email", errors.ErrCodeInvalidEmail, "Invalid email format"))
+	} else if domain := EmailDomain(user.Email); !v.domainAllowed(domain) {
+		errs = append(errs, errors.NewValidationError(row, identifier, "email", errors.ErrCodeDomainNotAllowed, "Email domain "+domain+" is not allowed"))
 	}
 
-	// Validate name (required, max 255 chars)
-	if user.Name == "" {
+	// Validate name (required, max 255 chars, as it will be stored)
+	name := StripInvisible(user.Name)
+	if name == "" {
 		errs = append(errs, errors.NewValidationError(row, identifier, "name", errors.ErrCodeMissingField, "Name is required"))
-	} else if len(user.Name) > 255 {
+	} else if hasControlChars(name, false) {
+		errs = append(errs, errors.NewValidationError(row, identifier, "name", errors.ErrCodeInvalidName, "Name must not contain control characters"))
+	} else if CharCount(name) > 255 {
 		errs = append(errs, errors.NewValidationError(row, identifier, "name", errors.ErrCodeInvalidName, "Name must be at most 255 characters"))
 	}
 
@@ -82,6 +110,25 @@ func (v *UserValidator) ValidateUserImport(row int, user *models.UserImport) []*
 	return errs
 }
 
+// WarnUserImport reports fields of a valid user that will be filled in on
+// import rather than taken from the file
+func (v *UserValidator) WarnUserImport(row int, user *models.UserImport) []*errors.ValidationError {
+	var warns []*errors.ValidationError
+	identifier := user.Email
+	if identifier == "" && user.ID != "" {
+		identifier = user.ID
+	}
+
+	if user.Active == "" {
+		warns = append(warns, errors.NewValidationError(row, identifier, "active", errors.WarnCodeActiveDefaulted, "Active not provided; defaulted to true"))
+	}
+	if user.CreatedAt == "" {
+		warns = append(warns, errors.NewValidationError(row, identifier, "created_at", errors.WarnCodeCreatedAtDefaulted, "Created at not provided; set to the import time"))
+	}
+
+	return warns
+}
+
 // ConvertToUser converts a validated UserImport to a User model
 func (v *UserValidator) ConvertToUser(ui *models.UserImport) (*models.User, error) {
 	user := &models.User{
@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/rohit/bulk-import-export/internal/domain/models"
@@ -144,6 +145,55 @@ func TestUserValidator_ValidateUserImport(t *testing.T) {
 			wantValid:   false,
 			wantErrCode: "INVALID_EMAIL",
 		},
+		{
+			// 255 CJK characters are 765 bytes
+			name: "valid CJK name at the character limit",
+			user: &models.UserImport{
+				Email: "user@example.com",
+				Name:  strings.Repeat("山", 255),
+				Role:  "author",
+			},
+			wantValid: true,
+		},
+		{
+			name: "valid name with emoji",
+			user: &models.UserImport{
+				Email: "user@example.com",
+				Name:  "Ana 👩\u200d💻 Silva",
+				Role:  "author",
+			},
+			wantValid: true,
+		},
+		{
+			name: "CJK name over the character limit",
+			user: &models.UserImport{
+				Email: "user@example.com",
+				Name:  strings.Repeat("山", 256),
+				Role:  "author",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_NAME",
+		},
+		{
+			name: "name with control characters",
+			user: &models.UserImport{
+				Email: "user@example.com",
+				Name:  "Test\x07User",
+				Role:  "author",
+			},
+			wantValid:   false,
+			wantErrCode: "INVALID_NAME",
+		},
+		{
+			name: "name of only zero-width spaces",
+			user: &models.UserImport{
+				Email: "user@example.com",
+				Name:  "\u200b\u200b",
+				Role:  "author",
+			},
+			wantValid:   false,
+			wantErrCode: "MISSING_FIELD",
+		},
 		{
 			name: "valid email with subdomain",
 			user: &models.UserImport{
@@ -272,3 +322,85 @@ func TestUserValidator_ConvertToUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUserValidator_WarnUserImport(t *testing.T) {
+	validator := NewUserValidator()
+
+	warns := validator.WarnUserImport(2, &models.UserImport{
+		Email: "user@example.com",
+		Name:  "Test User",
+		Role:  "admin",
+	})
+	if len(warns) != 2 {
+		t.Fatalf("WarnUserImport() got %d warnings, want 2", len(warns))
+	}
+	if warns[0].Code != "ACTIVE_DEFAULTED" || warns[0].RowNumber != 2 {
+		t.Errorf("first warning = %s/%d, want ACTIVE_DEFAULTED/2", warns[0].Code, warns[0].RowNumber)
+	}
+
+	warns = validator.WarnUserImport(3, &models.UserImport{
+		Email:     "user@example.com",
+		Active:    "false",
+		CreatedAt: "2024-01-01T00:00:00Z",
+	})
+	if len(warns) != 0 {
+		t.Errorf("WarnUserImport() got %d warnings for a complete user, want 0", len(warns))
+	}
+}
+
+func TestUserValidator_DomainPolicy(t *testing.T) {
+	validator := NewUserValidator()
+	validator.SetDomainPolicy([]string{"Example.com", "corp.io"}, []string{"contractors.example.com"}, true)
+
+	tests := []struct {
+		email   string
+		allowed bool
+	}{
+		{"ann@example.com", true},
+		{"ann@EXAMPLE.COM", true},
+		{"ann@eu.example.com", true},
+		{"ann@corp.io", true},
+		{"ann@contractors.example.com", false},
+		{"ann@notexample.com", false},
+		{"ann@gmail.com", false},
+		{"ann@mailinator.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			errs := validator.ValidateUserImport(1, &models.UserImport{Email: tt.email, Name: "Ann", Role: "reader"})
+			if tt.allowed && len(errs) != 0 {
+				t.Errorf("ValidateUserImport() errors = %v, want none", errs)
+			}
+			if !tt.allowed && (len(errs) != 1 || errs[0].Code != "DOMAIN_NOT_ALLOWED") {
+				t.Errorf("ValidateUserImport() errors = %v, want DOMAIN_NOT_ALLOWED", errs)
+			}
+		})
+	}
+
+	// Without an allow list only denied domains are rejected
+	validator.SetDomainPolicy(nil, nil, true)
+	if errs := validator.ValidateUserImport(1, &models.UserImport{Email: "ann@gmail.com", Name: "Ann", Role: "reader"}); len(errs) != 0 {
+		t.Errorf("ValidateUserImport(gmail.com) errors = %v, want none", errs)
+	}
+	if errs := validator.ValidateUserImport(1, &models.UserImport{Email: "ann@yopmail.com", Name: "Ann", Role: "reader"}); len(errs) != 1 {
+		t.Errorf("ValidateUserImport(yopmail.com) errors = %v, want DOMAIN_NOT_ALLOWED", errs)
+	}
+}
+
+func BenchmarkUserValidator_ValidateUserImport(b *testing.B) {
+	validator := NewUserValidator()
+	user := &models.UserImport{
+		ID:        "5864905b-ec8c-4fa6-8ba7-545d13f29b4e",
+		Email:     "user@example.com",
+		Name:      "Bench User",
+		Role:      "author",
+		Active:    "true",
+		CreatedAt: "2024-01-01T00:00:00Z",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validator.ValidateUserImport(i, user)
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Local stores objects as files under a base directory
+type Local struct {
+	basePath string
+}
+
+// NewLocal creates a local driver rooted at basePath
+func NewLocal(basePath string) *Local {
+	return &Local{basePath: basePath}
+}
+
+// Type implements Driver
+func (l *Local) Type() string {
+	return TypeLocal
+}
+
+// Put implements Driver
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	dst, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temp file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".put-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Get implements Driver
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	src, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("get %s: %w", key, ErrNotFound)
+	}
+	return file, err
+}
+
+// Delete implements Driver
+func (l *Local) Delete(ctx context.Context, key string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL implements Driver; local files are served by the API instead
+func (l *Local) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "", ErrSignedURLUnsupported
+}
+
+// path maps a key to a file path, rejecting keys that escape the base directory
+func (l *Local) path(key string) (string, error) {
+	p := filepath.Join(l.basePath, filepath.FromSlash(key))
+	rel, err := filepath.Rel(l.basePath, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return p, nil
+}
+
+func openFile(filePath string) (*os.File, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// objectStore talks to an S3-compatible XML API using path-style URLs
+// (<endpoint>/<bucket>/<key>). It backs both the S3 and GCS drivers.
+type objectStore struct {
+	driverType string
+	endpoint   *url.URL
+	bucket     string
+	signer     *sigV4
+	client     *http.Client
+}
+
+// NewS3 creates a driver for Amazon S3 or an S3-compatible service
+func NewS3(endpoint, region, bucket, accessKey, secretKey string) (Driver, error) {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 storage requires AWS_BUCKET, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return newObjectStore(TypeS3, endpoint, bucket, newAWSSigner(region, accessKey, secretKey))
+}
+
+// NewGCS creates a driver for Google Cloud Storage using its XML API with an
+// HMAC key, which supports the same signing scheme as S3
+func NewGCS(endpoint, bucket, accessID, secret string) (Driver, error) {
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	if bucket == "" || accessID == "" || secret == "" {
+		return nil, fmt.Errorf("gcs storage requires GCS_BUCKET, GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET")
+	}
+	return newObjectStore(TypeGCS, endpoint, bucket, newGoogleSigner(accessID, secret))
+}
+
+func newObjectStore(driverType, endpoint, bucket string, signer *sigV4) (*objectStore, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s endpoint: %q", driverType, endpoint)
+	}
+	return &objectStore{
+		driverType: driverType,
+		endpoint:   u,
+		bucket:     bucket,
+		signer:     signer,
+		client:     &http.Client{},
+	}, nil
+}
+
+// Type implements Driver
+func (o *objectStore) Type() string {
+	return o.driverType
+}
+
+// Put implements Driver
+func (o *objectStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, o.objectURL(key).String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return o.do(req, "put", key, nil)
+}
+
+// Get implements Driver
+func (o *objectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser
+	if err := o.do(req, "get", key, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Delete implements Driver
+func (o *objectStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, o.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	err = o.do(req, "delete", key, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// SignedURL implements Driver
+func (o *objectStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return o.signer.presign(http.MethodGet, o.objectURL(key), ttl, time.Now()), nil
+}
+
+func (o *objectStore) objectURL(key string) *url.URL {
+	u := *o.endpoint
+	u.Path = u.Path + "/" + o.bucket + "/" + strings.TrimPrefix(key, "/")
+	u.RawPath = uriEncode(u.Path, false)
+	return &u
+}
+
+// do signs and sends req. When body is non-nil the caller takes ownership of
+// the response body; otherwise it is closed here.
+func (o *objectStore) do(req *http.Request, op, key string, body *io.ReadCloser) error {
+	o.signer.signRequest(req, time.Now())
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", op, key, err)
+	}
+	if err := checkResponse(resp, op, key); err != nil {
+		resp.Body.Close()
+		return err
+	}
+	if body != nil {
+		*body = resp.Body
+		return nil
+	}
+	resp.Body.Close()
+	return nil
+}
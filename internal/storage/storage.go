@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+)
+
+// Storage driver types accepted in StorageConfig.Type
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+	TypeAzure = "azure"
+	TypeGCS   = "gcs"
+)
+
+var (
+	// ErrNotFound is returned when an object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrSignedURLUnsupported is returned by drivers that cannot mint URLs
+	ErrSignedURLUnsupported = errors.New("signed URLs not supported by this storage driver")
+)
+
+// Driver stores upload archives and export outputs under slash-separated keys
+type Driver interface {
+	// Type returns the driver type, one of the Type* constants
+	Type() string
+	// Put stores size bytes from r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key; missing objects are not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a temporary URL granting read access to key
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// New creates the driver selected by cfg.Type
+func New(cfg config.StorageConfig) (Driver, error) {
+	switch cfg.Type {
+	case "", TypeLocal:
+		return NewLocal(cfg.LocalPath), nil
+	case TypeS3:
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+	case TypeAzure:
+		return NewAzure(cfg.AzureEndpoint, cfg.AzureAccount, cfg.AzureKey, cfg.AzureContainer)
+	case TypeGCS:
+		return NewGCS(cfg.GCSEndpoint, cfg.GCSBucket, cfg.GCSAccessID, cfg.GCSSecret)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Type)
+	}
+}
+
+// IsRemote reports whether the driver stores objects outside the local disk
+func IsRemote(d Driver) bool {
+	return d != nil && d.Type() != TypeLocal
+}
+
+// UploadKey returns the key an uploaded import file is archived under. name
+// is the file's path within the upload directory, which keeps its job
+// directory in the key.
+func UploadKey(name string) string {
+	return path.Join("uploads", filepath.ToSlash(name))
+}
+
+// ExportKey returns the key an export output file is stored under
+func ExportKey(filePath string) string {
+	return path.Join("exports", filepath.Base(filePath))
+}
+
+// PutFile stores a local file under key
+func PutFile(ctx context.Context, d Driver, key, filePath, contentType string) error {
+	file, err := openFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	return d.Put(ctx, key, file, info.Size(), contentType)
+}
+
+// checkResponse turns a non-2xx response into an error, draining the body
+func checkResponse(resp *http.Response, op, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", op, key, ErrNotFound)
+	}
+	return fmt.Errorf("%s %s: unexpected status %d: %s", op, key, resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,231 @@
+'use strict';
+
+// Job monitoring UI. Everything goes through the public /v1 API.
+
+const REFRESH_MS = 5000;
+const PER_PAGE = 25;
+
+const state = { page: 1, totalPages: 1, selected: null, events: null };
+
+const $ = (id) => document.getElementById(id);
+
+const tenantInput = $('tenant');
+tenantInput.value = localStorage.getItem('tenant') || '';
+tenantInput.addEventListener('change', () => {
+  localStorage.setItem('tenant', tenantInput.value.trim());
+  state.page = 1;
+  loadJobs();
+});
+
+function headers(extra) {
+  const h = Object.assign({}, extra);
+  const tenant = tenantInput.value.trim();
+  if (tenant) h['X-Tenant-ID'] = tenant;
+  return h;
+}
+
+async function api(path, options = {}) {
+  const res = await fetch(path, Object.assign({}, options, { headers: headers(options.headers) }));
+  const body = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(body.error || res.statusText);
+  return body;
+}
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === 'class') node.className = v;
+    else node.setAttribute(k, v);
+  }
+  for (const child of children) {
+    node.append(child instanceof Node ? child : String(child ?? ''));
+  }
+  return node;
+}
+
+function progressBar(progress) {
+  const bar = el('progress', { max: 100 });
+  bar.value = progress.percentage || 0;
+  return el('span', { class: 'progress' }, bar, ` ${Math.floor(progress.percentage || 0)}%`);
+}
+
+// Job list
+
+async function loadJobs() {
+  const params = new URLSearchParams({ page: state.page, per_page: PER_PAGE });
+  if ($('filter-type').value) params.set('type', $('filter-type').value);
+  if ($('filter-status').value) params.set('status', $('filter-status').value);
+
+  let body;
+  try {
+    body = await api(`/v1/jobs?${params}`);
+  } catch (err) {
+    $('jobs').replaceChildren(el('tr', {}, el('td', { colspan: 7, class: 'error' }, err.message)));
+    return;
+  }
+
+  state.totalPages = Math.max(1, body.pagination.total_pages);
+  $('page').textContent = `Page ${state.page} of ${state.totalPages} (${body.pagination.total_jobs} jobs)`;
+  $('prev').disabled = state.page <= 1;
+  $('next').disabled = state.page >= state.totalPages;
+
+  if (body.jobs.length === 0) {
+    $('jobs').replaceChildren(el('tr', {}, el('td', { colspan: 7, class: 'muted' }, 'No jobs')));
+    return;
+  }
+  $('jobs').replaceChildren(...body.jobs.map((job) => {
+    const row = el('tr', { class: job.job_id === state.selected ? 'selected' : '' },
+      el('td', {}, new Date(job.created_at).toLocaleString()),
+      el('td', {}, job.type),
+      el('td', {}, job.resource),
+      el('td', {}, el('span', { class: `status ${job.status}` }, job.status)),
+      el('td', {}, job.phase || ''),
+      el('td', {}, progressBar(job.progress)),
+      el('td', {}, job.progress.failed_records || ''),
+    );
+    row.title = job.error_message || job.job_id;
+    row.addEventListener('click', () => showJob(job));
+    return row;
+  }));
+}
+
+$('refresh').addEventListener('click', loadJobs);
+$('filter-type').addEventListener('change', () => { state.page = 1; loadJobs(); });
+$('filter-status').addEventListener('change', () => { state.page = 1; loadJobs(); });
+$('prev').addEventListener('click', () => { state.page--; loadJobs(); });
+$('next').addEventListener('click', () => { state.page++; loadJobs(); });
+
+// Job detail, kept current by the job's event stream
+
+function showJob(job) {
+  closeJob();
+  state.selected = job.job_id;
+  $('detail').hidden = false;
+  $('detail-title').textContent = `${job.type} ${job.resource} · ${job.job_id}`;
+  $('detail-errors').replaceChildren();
+  renderEvent(job);
+
+  state.events = new EventSource(`/v1/jobs/${job.job_id}/events`);
+  state.events.addEventListener('progress', (e) => renderEvent(JSON.parse(e.data)));
+  state.events.addEventListener('done', (e) => {
+    const event = JSON.parse(e.data);
+    state.events.close();
+    renderEvent(event);
+    loadErrors(event);
+    loadJobs();
+  });
+  loadJobs();
+}
+
+function closeJob() {
+  if (state.events) state.events.close();
+  state.events = null;
+  state.selected = null;
+  $('detail').hidden = true;
+}
+
+$('close').addEventListener('click', () => { closeJob(); loadJobs(); });
+
+function renderEvent(event) {
+  const p = Object.assign({
+    processed_records: 0, total_records: 0, successful_records: 0, failed_records: 0,
+  }, event.progress);
+  $('detail-status').className = `status ${event.status}`;
+  $('detail-status').textContent = event.status;
+  $('detail-phase').textContent = event.phase ? `· ${event.phase}` : '';
+  $('detail-progress').value = p.percentage || 0;
+  $('detail-counts').textContent =
+    `${p.processed_records} of ${p.total_records} processed · ${p.successful_records} succeeded · ${p.failed_records} failed`;
+  $('detail-message').textContent = event.error_message || '';
+
+  const links = [el('a', { href: `/v1/jobs/${event.job_id}/logs`, target: '_blank' }, 'logs')];
+  if (event.type === 'import' || event.type === 'export') {
+    links.push(' · ', el('a', { href: `/v1/${event.type}s/${event.job_id}`, target: '_blank' }, 'status'));
+  }
+  if (event.type === 'export' && event.status === 'completed') {
+    links.push(' · ', el('a', { href: `/v1/exports/${event.job_id}/download` }, 'download'));
+  }
+  $('detail-links').replaceChildren(...links);
+}
+
+// Summarises an import's first page of errors by code
+async function loadErrors(event) {
+  if (event.type !== 'import' || !event.progress.failed_records) return;
+  let body;
+  try {
+    body = await api(`/v1/imports/${event.job_id}/errors?per_page=1000`);
+  } catch (err) {
+    $('detail-errors').replaceChildren(el('p', { class: 'error' }, err.message));
+    return;
+  }
+
+  const byCode = new Map();
+  for (const e of body.errors) {
+    const entry = byCode.get(e.error_code) || { count: 0, example: e };
+    entry.count++;
+    byCode.set(e.error_code, entry);
+  }
+  const rows = [...byCode.entries()]
+    .sort((a, b) => b[1].count - a[1].count)
+    .map(([code, { count, example }]) => el('tr', {},
+      el('td', {}, code),
+      el('td', {}, count),
+      el('td', {}, `row ${example.row_number}${example.field_name ? ` (${example.field_name})` : ''}: ${example.error_message}`),
+    ));
+
+  const total = body.pagination.total_errors;
+  $('detail-errors').replaceChildren(
+    el('h3', {}, `Errors (${total}${total > body.errors.length ? `, first ${body.errors.length} summarised` : ''})`),
+    el('table', {},
+      el('thead', {}, el('tr', {}, el('th', {}, 'Code'), el('th', {}, 'Count'), el('th', {}, 'Example'))),
+      el('tbody', {}, ...rows)),
+  );
+}
+
+// Forms
+
+function formResult(form, text, isError) {
+  const result = form.querySelector('.result');
+  result.className = isError ? 'result error' : 'result';
+  result.textContent = text;
+}
+
+$('import-form').addEventListener('submit', async (e) => {
+  e.preventDefault();
+  const form = e.target;
+  const data = new FormData(form);
+  if (!data.get('resource')) data.delete('resource');
+  formResult(form, 'Uploading…');
+  try {
+    const body = await api('/v1/imports', {
+      method: 'POST',
+      body: data,
+    });
+    formResult(form, `Queued import ${body.job_id}`);
+    form.reset();
+    showJob({ job_id: body.job_id, type: 'import', resource: body.resource, status: body.status, progress: {} });
+  } catch (err) {
+    formResult(form, err.message, true);
+  }
+});
+
+$('export-form').addEventListener('submit', async (e) => {
+  e.preventDefault();
+  const form = e.target;
+  const data = new FormData(form);
+  formResult(form, 'Starting…');
+  try {
+    const body = await api('/v1/exports', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(Object.fromEntries(data)),
+    });
+    formResult(form, `Queued export ${body.job_id}`);
+    showJob({ job_id: body.job_id, type: 'export', resource: body.resource, status: body.status, progress: {} });
+  } catch (err) {
+    formResult(form, err.message, true);
+  }
+});
+
+loadJobs();
+setInterval(() => { if (!document.hidden) loadJobs(); }, REFRESH_MS);
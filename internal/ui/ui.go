@@ -0,0 +1,22 @@
+// Package ui embeds the job monitoring web UI. It is a single page using
+// only the public API, so it needs no build step or server-side state.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// FS returns the UI's files, with index.html at the root
+func FS() http.FileSystem {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		// The directory is embedded above, so this can't happen
+		panic(err)
+	}
+	return http.FS(sub)
+}
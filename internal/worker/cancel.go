@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// Cancel marks a pending or processing job cancelled, with reason as its
+// error message and the counts it had reached, and stops its run on this
+// instance. A queued job is skipped when a worker takes it, and a run on
+// another instance stops at its next heartbeat. It reports whether the job
+// was still unfinished.
+func (p *Pool) Cancel(ctx context.Context, jobID uuid.UUID, reason string) (bool, error) {
+	changed, err := p.jobRepo.FinishManually(ctx, jobID, models.JobStatusCancelled, reason)
+	if err != nil || !changed {
+		return false, err
+	}
+	p.cancelRun(jobID)
+	return true, nil
+}
+
+// jobRun is the registration of one run of a job. Its address tells runs
+// of the same job apart, since a requeued job may start again on this
+// instance before the run it replaced has been released.
+type jobRun struct {
+	cancel context.CancelCauseFunc
+}
+
+// jobContext derives the context of a run of a job, which Cancel cancels
+// with models.ErrJobCancelled, and returns a func releasing it once the run
+// ends. Releasing leaves alone a later run of the job that registered since.
+func (p *Pool) jobContext(ctx context.Context, jobID uuid.UUID) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	run := &jobRun{cancel: cancel}
+	p.cancelMu.Lock()
+	p.cancels[jobID] = run
+	p.cancelMu.Unlock()
+
+	return ctx, func() {
+		p.cancelMu.Lock()
+		if p.cancels[jobID] == run {
+			delete(p.cancels, jobID)
+		}
+		p.cancelMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRun cancels the run of a job on this instance, reporting whether
+// there was one
+func (p *Pool) cancelRun(jobID uuid.UUID) bool {
+	p.cancelMu.Lock()
+	run, ok := p.cancels[jobID]
+	p.cancelMu.Unlock()
+	if ok {
+		run.cancel(models.ErrJobCancelled)
+	}
+	return ok
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_Cancel(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 4, HeartbeatInterval: time.Millisecond})
+
+	start := func() *models.Job {
+		t.Helper()
+		job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if err := jobs.SetStarted(ctx, job.ID); err != nil {
+			t.Fatalf("SetStarted() error: %v", err)
+		}
+		if err := jobs.UpdateProgress(ctx, job.ID, 10, 4, 1); err != nil {
+			t.Fatalf("UpdateProgress() error: %v", err)
+		}
+		return job
+	}
+
+	// A run on this instance is cancelled with the job cancelled as cause
+	job := start()
+	runCtx, release := p.jobContext(ctx, job.ID)
+	defer release()
+	if ok, err := p.Cancel(ctx, job.ID, "wrong file"); !ok || err != nil {
+		t.Fatalf("Cancel() = %v, %v; want true", ok, err)
+	}
+	if !models.JobCancelled(runCtx) {
+		t.Errorf("run context cause = %v, want %v", context.Cause(runCtx), models.ErrJobCancelled)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusCancelled || stored.ErrorMessage == nil || *stored.ErrorMessage != "wrong file" || stored.SuccessfulRecords != 4 {
+		t.Errorf("cancelled job = %s, %v with %d written; want cancelled for the reason with 4 written", stored.Status, stored.ErrorMessage, stored.SuccessfulRecords)
+	}
+	if ok, err := p.Cancel(ctx, job.ID, "again"); ok || err != nil {
+		t.Errorf("second Cancel() = %v, %v; want false", ok, err)
+	}
+
+	// A run whose job another instance cancelled stops at its heartbeat
+	job = start()
+	runCtx, release = p.jobContext(ctx, job.ID)
+	defer release()
+	stop := p.startHeartbeat(runCtx, job.ID)
+	defer stop()
+	if _, err := jobs.FinishManually(ctx, job.ID, models.JobStatusCancelled, "cancelled elsewhere"); err != nil {
+		t.Fatalf("FinishManually() error: %v", err)
+	}
+	select {
+	case <-runCtx.Done():
+		if !models.JobCancelled(runCtx) {
+			t.Errorf("run context cause = %v, want %v", context.Cause(runCtx), models.ErrJobCancelled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("run wasn't cancelled at its heartbeat")
+	}
+
+	// Releasing a run that finished doesn't count as cancelling its job
+	job = start()
+	runCtx, release = p.jobContext(ctx, job.ID)
+	release()
+	if models.JobCancelled(runCtx) || p.cancelRun(job.ID) {
+		t.Error("released run was cancelled")
+	}
+
+	// A run released after its requeued job started again leaves the new
+	// run cancellable
+	job = start()
+	_, releaseOld := p.jobContext(ctx, job.ID)
+	runCtx, release = p.jobContext(ctx, job.ID)
+	defer release()
+	releaseOld()
+	if ok, err := p.Cancel(ctx, job.ID, "wrong file"); !ok || err != nil {
+		t.Fatalf("Cancel() = %v, %v; want true", ok, err)
+	}
+	if !models.JobCancelled(runCtx) {
+		t.Errorf("new run context cause = %v, want %v", context.Cause(runCtx), models.ErrJobCancelled)
+	}
+}
@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// orphanBatchSize caps how many stale jobs one monitor pass recovers
+const orphanBatchSize = 100
+
+// startHeartbeat stamps the job's heartbeat every HeartbeatInterval until
+// the returned func is called, so other instances can tell it is still being
+// processed, and stops the run once the job is found cancelled
+func (p *Pool) startHeartbeat(ctx context.Context, jobID uuid.UUID) func() {
+	if p.cfg.HeartbeatInterval <= 0 {
+		return func() {}
+	}
+
+	p.beatMu.Lock()
+	p.beating[jobID] = true
+	p.beatMu.Unlock()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.jobRepo.Heartbeat(ctx, jobID); err != nil {
+					p.logger.Warn().Err(err).Str("job_id", jobID.String()).Msg("Failed to record job heartbeat")
+				}
+				// A job cancelled through another instance stops here
+				if job, err := p.jobRepo.GetByID(ctx, jobID); err == nil && job != nil && job.Status == models.JobStatusCancelled {
+					p.cancelRun(jobID)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		p.beatMu.Lock()
+		delete(p.beating, jobID)
+		p.beatMu.Unlock()
+	}
+}
+
+// monitorHeartbeats looks for processing jobs whose heartbeat has gone stale
+// and recovers them
+func (p *Pool) monitorHeartbeats(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.quit:
+			return
+		case <-ticker.C:
+			p.recoverOrphans(ctx)
+		}
+	}
+}
+
+// recoverOrphans retries or fails the processing jobs whose worker stopped
+// sending heartbeats, such as jobs left behind by an instance that crashed.
+// It returns how many jobs it recovered.
+func (p *Pool) recoverOrphans(ctx context.Context) int {
+	staleBefore := time.Now().Add(-p.cfg.HeartbeatTimeout)
+	jobs, err := p.jobRepo.ListStale(ctx, staleBefore, orphanBatchSize)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("Failed to list stale jobs")
+		return 0
+	}
+
+	recovered := 0
+	for _, job := range jobs {
+		// A job still running here has a live heartbeat goroutine even if its
+		// writes are failing
+		p.beatMu.Lock()
+		local := p.beating[job.ID]
+		p.beatMu.Unlock()
+		if local {
+			continue
+		}
+
+		// Another instance may have found the same job
+		claimed, err := p.jobRepo.ClaimStale(ctx, job.ID, staleBefore)
+		if err != nil {
+			p.logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to claim stale job")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		p.recoverOrphan(ctx, job)
+		recovered++
+	}
+	return recovered
+}
+
+// recoverOrphan handles an orphaned job like one that failed: it is retried
+// while it has attempts left, dead-lettered once it has none, and failed
+// when it can't be queued from what the job records
+func (p *Pool) recoverOrphan(ctx context.Context, job *models.Job) {
+	lastSeen := job.UpdatedAt
+	if job.HeartbeatAt != nil {
+		lastSeen = *job.HeartbeatAt
+	} else if job.StartedAt != nil {
+		lastSeen = *job.StartedAt
+	}
+	msg := fmt.Sprintf("%s: no heartbeat from its worker since %s", errors.ErrCodeJobOrphaned, lastSeen.UTC().Format(time.RFC3339))
+	log := p.logger.With().Str("job_id", job.ID.String()).Str("type", string(job.Type)).Logger()
+	log.Warn().Time("last_heartbeat", lastSeen).Msg("Recovering orphaned job")
+
+	// Kept on the job while a retry waits, like the error of a failed run
+	job.ErrorMessage = &msg
+	processing, err := p.jobRepo.SetErrorMessage(ctx, job.ID, msg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to record orphaned job")
+	} else if !processing {
+		// Cancelled or finished since it was claimed; that outcome stands
+		log.Info().Msg("Orphaned job finished before it was recovered")
+		return
+	}
+
+	resubmit, err := p.resubmitFunc(job)
+	if err != nil {
+		p.failJob(ctx, job, fmt.Sprintf("%s; %v", msg, err))
+		return
+	}
+	if !p.retryFailedJob(ctx, job, stderrors.New(msg), log, resubmit) {
+		p.failJob(ctx, job, msg)
+	}
+}
@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_RecoverOrphans(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{
+		QueueSize: 4, MaxAttempts: 3, RetryBackoff: time.Hour, HeartbeatTimeout: time.Millisecond,
+	})
+	p.running = true
+
+	start := func(job *models.Job) {
+		t.Helper()
+		job.Status = models.JobStatusPending
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if err := jobs.SetStarted(ctx, job.ID); err != nil {
+			t.Fatalf("SetStarted() error: %v", err)
+		}
+	}
+
+	// An export is retried, an import whose upload is gone can't be
+	export := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Params: &models.JobParams{Format: "ndjson"}}
+	start(export)
+	missing := filepath.Join(t.TempDir(), "gone.csv")
+	imp := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, FilePath: &missing}
+	start(imp)
+	// A job still running here is left alone even with a stale heartbeat
+	local := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers}
+	start(local)
+	p.beating[local.ID] = true
+
+	time.Sleep(5 * time.Millisecond)
+	if n := p.recoverOrphans(ctx); n != 2 {
+		t.Fatalf("recoverOrphans() = %d, want 2", n)
+	}
+
+	stored, _ := jobs.GetByID(ctx, export.ID)
+	if stored.Status != models.JobStatusPending || stored.Attempts != 1 {
+		t.Errorf("orphaned export status = %s, attempts = %d; want pending, 1", stored.Status, stored.Attempts)
+	}
+	if stored.ErrorMessage == nil || !strings.HasPrefix(*stored.ErrorMessage, errors.ErrCodeJobOrphaned) {
+		t.Errorf("orphaned export error = %v, want %s", stored.ErrorMessage, errors.ErrCodeJobOrphaned)
+	}
+
+	stored, _ = jobs.GetByID(ctx, imp.ID)
+	if stored.Status != models.JobStatusFailed {
+		t.Errorf("orphaned import status = %s, want failed", stored.Status)
+	}
+
+	stored, _ = jobs.GetByID(ctx, local.ID)
+	if stored.Status != models.JobStatusProcessing {
+		t.Errorf("local job status = %s, want processing", stored.Status)
+	}
+
+	// Recovered jobs are no longer processing, so they aren't claimed twice
+	if n := p.recoverOrphans(ctx); n != 0 {
+		t.Errorf("second recoverOrphans() = %d, want 0", n)
+	}
+}
+
+func TestPool_RecoverOrphanKeepsLaterFinish(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{
+		QueueSize: 4, MaxAttempts: 3, RetryBackoff: time.Hour, HeartbeatTimeout: time.Millisecond,
+	})
+	p.running = true
+
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: &models.JobParams{Format: "ndjson"}}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := jobs.SetStarted(ctx, job.ID); err != nil {
+		t.Fatalf("SetStarted() error: %v", err)
+	}
+	stale, _ := jobs.GetByID(ctx, job.ID)
+
+	// Cancelled between the claim and the recovery
+	if ok, err := jobs.FinishManually(ctx, job.ID, models.JobStatusCancelled, "cancelled by operator"); err != nil || !ok {
+		t.Fatalf("FinishManually() = %v, %v", ok, err)
+	}
+	p.recoverOrphan(ctx, stale)
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusCancelled || stored.Attempts != 0 {
+		t.Errorf("status = %s, attempts = %d; want cancelled, 0", stored.Status, stored.Attempts)
+	}
+	if stored.ErrorMessage == nil || *stored.ErrorMessage != "cancelled by operator" {
+		t.Errorf("error = %v, want the cancel reason", stored.ErrorMessage)
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rs/zerolog"
+)
+
+// SetIdentity names the instance the pool runs on and its version. They are
+// recorded on every job the pool runs and added to its logs, so must be set
+// before Start.
+func (p *Pool) SetIdentity(instance, version string) {
+	p.instance = instance
+	p.version = version
+	p.logger = p.logger.With().Str("instance", instance).Str("version", version).Logger()
+	if p.metrics != nil {
+		p.metrics.SetInstanceInfo(instance, version)
+	}
+}
+
+// attribute records on job that workerID of this instance is running it.
+// It is best effort: a job whose attribution can't be stored still runs.
+func (p *Pool) attribute(ctx context.Context, job *models.Job, workerID string, logger zerolog.Logger) {
+	worker := models.JobWorker{Instance: p.instance, WorkerID: workerID, Version: p.version}
+	job.WorkerInstance = &worker.Instance
+	job.WorkerID = &worker.WorkerID
+	job.WorkerVersion = &worker.Version
+	if err := p.jobRepo.SetWorker(ctx, job.ID, worker); err != nil {
+		logger.Warn().Err(err).Str("job_id", job.ID.String()).Msg("Failed to record job worker")
+	}
+	if p.metrics != nil {
+		p.metrics.RecordWorkerJob(p.instance, workerID, string(job.Type))
+	}
+}
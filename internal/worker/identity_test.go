@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_Attribute(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	p.SetIdentity("api-7f9c", "v1.4.2")
+
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	p.attribute(ctx, job, "export-1", zerolog.Nop())
+
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	for name, got := range map[string]*string{"instance": stored.WorkerInstance, "worker": stored.WorkerID, "version": stored.WorkerVersion} {
+		if got == nil {
+			t.Fatalf("stored %s is nil", name)
+		}
+	}
+	if *stored.WorkerInstance != "api-7f9c" || *stored.WorkerID != "export-1" || *stored.WorkerVersion != "v1.4.2" {
+		t.Errorf("stored worker = %s/%s/%s, want api-7f9c/export-1/v1.4.2", *stored.WorkerInstance, *stored.WorkerID, *stored.WorkerVersion)
+	}
+	if job.WorkerID == nil || *job.WorkerID != "export-1" {
+		t.Errorf("job.WorkerID = %v, want export-1", job.WorkerID)
+	}
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+)
+
+// SetLogCapture keeps the log lines of each job the pool runs and stores
+// them with the job when it finishes
+func (p *Pool) SetLogCapture(c *logger.Capture) {
+	p.logCapture = c
+}
+
+// captureLogs starts keeping the log lines of job. The returned func stops
+// and stores them, even if the job was cancelled or panicked.
+func (p *Pool) captureLogs(ctx context.Context, job *models.Job) func() {
+	if p.logCapture == nil {
+		return func() {}
+	}
+	jobID := job.ID.String()
+	p.logCapture.Start(jobID)
+	return func() {
+		entries, dropped := p.logCapture.Stop(jobID)
+		logs := make([]*models.JobLog, 0, len(entries))
+		for i, e := range entries {
+			l := &models.JobLog{
+				LineNumber: dropped + i + 1,
+				Level:      e.Level,
+				Message:    e.Message,
+				LoggedAt:   e.Time,
+			}
+			if len(e.Fields) > 0 {
+				fields := string(e.Fields)
+				l.Fields = &fields
+			}
+			logs = append(logs, l)
+		}
+		if err := p.jobRepo.AddLogs(context.WithoutCancel(ctx), job.ID, logs, dropped); err != nil {
+			p.logger.Warn().Err(err).Str("job_id", jobID).Msg("Failed to store job logs")
+		}
+	}
+}
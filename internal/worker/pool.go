@@ -3,16 +3,21 @@ package worker
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
 	"github.com/rohit/bulk-import-export/internal/domain/models"
 	"github.com/rohit/bulk-import-export/internal/metrics"
-	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	"github.com/rohit/bulk-import-export/internal/repository"
 	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
 	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rohit/bulk-import-export/internal/service/import/parsers"
+	"github.com/rohit/bulk-import-export/pkg/logger"
 	"github.com/rs/zerolog"
 )
 
@@ -20,9 +25,16 @@ import (
 type ImportJob struct {
 	Job     *models.Job
 	Source  JobSource
+	Options ImportOptions
 	Cleanup func()
 }
 
+// ImportOptions holds per-request switches for an import job
+type ImportOptions struct {
+	// Profile runs a column profiling pass over the file before importing it
+	Profile bool
+}
+
 // JobSource represents the source of import data
 type JobSource struct {
 	FilePath string
@@ -33,43 +45,68 @@ type JobSource struct {
 type ExportJob struct {
 	Job     *models.Job
 	Filters *models.ExportFilters
+	Diff    *models.DiffRange
 }
 
 // Pool manages a pool of workers for processing jobs
 type Pool struct {
-	importChan chan *ImportJob
-	exportChan chan *ExportJob
 	wg         sync.WaitGroup
 	quit       chan struct{}
 	logger     zerolog.Logger
 	importSvc  *importservice.Service
 	exportSvc  *exportservice.Service
-	jobRepo    *postgres.JobRepository
+	jobRepo    repository.JobRepository
 	metrics    *metrics.Collector
 	cfg        config.WorkerConfig
 	mu         sync.Mutex
 	running    bool
+	runCtx     context.Context
+	importStop []chan struct{} // one per running import worker
+	exportStop []chan struct{} // one per running export worker
+	// Workers started so far, numbering the next one's worker ID
+	importSpawned int
+	exportSpawned int
+	panicMu       sync.Mutex
+	panics        map[uuid.UUID]int
+	beatMu        sync.Mutex
+	beating       map[uuid.UUID]bool
+	waitMu        sync.Mutex
+	waiters       map[uuid.UUID][]chan struct{}
+	cancelMu      sync.Mutex
+	cancels       map[uuid.UUID]*jobRun // runs on this instance, by job
+	imports       *jobQueue[*ImportJob]
+	exports       *jobQueue[*ExportJob]
+	types         map[models.JobType]*jobType // registered with Register
+	logCapture    *logger.Capture
+	instance      string
+	version       string
 }
 
-// NewPool creates a new worker pool
+// NewPool creates a new worker pool. Job types other than imports and
+// exports, such as search index syncs, are added with Register.
 func NewPool(
 	importSvc *importservice.Service,
 	exportSvc *exportservice.Service,
-	jobRepo *postgres.JobRepository,
+	jobRepo repository.JobRepository,
 	metricsCollector *metrics.Collector,
 	logger zerolog.Logger,
 	cfg config.WorkerConfig,
 ) *Pool {
 	return &Pool{
-		importChan: make(chan *ImportJob, cfg.QueueSize),
-		exportChan: make(chan *ExportJob, cfg.QueueSize),
-		quit:       make(chan struct{}),
-		logger:     logger,
-		importSvc:  importSvc,
-		exportSvc:  exportSvc,
-		jobRepo:    jobRepo,
-		metrics:    metricsCollector,
-		cfg:        cfg,
+		quit:      make(chan struct{}),
+		logger:    logger,
+		importSvc: importSvc,
+		exportSvc: exportSvc,
+		jobRepo:   jobRepo,
+		metrics:   metricsCollector,
+		cfg:       cfg,
+		panics:    make(map[uuid.UUID]int),
+		beating:   make(map[uuid.UUID]bool),
+		waiters:   make(map[uuid.UUID][]chan struct{}),
+		cancels:   make(map[uuid.UUID]*jobRun),
+		imports:   newJobQueue[*ImportJob](cfg.ImportWorkers, cfg.QueueSize, cfg.PriorityAging),
+		exports:   newJobQueue[*ExportJob](cfg.ExportWorkers, cfg.QueueSize, cfg.PriorityAging),
+		types:     make(map[models.JobType]*jobType),
 	}
 }
 
@@ -81,24 +118,34 @@ func (p *Pool) Start(ctx context.Context) {
 		return
 	}
 	p.running = true
+	p.runCtx = ctx
+
+	// Start import and export workers
+	p.scale(p.cfg.ImportWorkers, p.cfg.ExportWorkers)
+	importWorkers, exportWorkers := p.cfg.ImportWorkers, p.cfg.ExportWorkers
+	registered := make([]string, 0, len(p.types))
+	for _, t := range p.types {
+		p.startTypeWorkers(t)
+		registered = append(registered, string(t.name))
+	}
 	p.mu.Unlock()
 
-	// Start import workers
-	for i := 0; i < p.cfg.ImportWorkers; i++ {
+	if p.metrics != nil {
 		p.wg.Add(1)
-		go p.importWorker(ctx, i)
+		go p.sampleQueueWait(ctx)
 	}
 
-	// Start export workers
-	for i := 0; i < p.cfg.ExportWorkers; i++ {
+	// Recover jobs left processing by instances that died
+	if p.cfg.HeartbeatInterval > 0 {
 		p.wg.Add(1)
-		go p.exportWorker(ctx, i)
+		go p.monitorHeartbeats(ctx)
 	}
 
 	p.logger.Info().
-		Int("import_workers", p.cfg.ImportWorkers).
-		Int("export_workers", p.cfg.ExportWorkers).
+		Int("import_workers", importWorkers).
+		Int("export_workers", exportWorkers).
 		Int("queue_size", p.cfg.QueueSize).
+		Strs("registered_types", registered).
 		Msg("Worker pool started")
 }
 
@@ -117,29 +164,57 @@ func (p *Pool) Stop() {
 	p.logger.Info().Msg("Worker pool stopped")
 }
 
-// SubmitImportJob submits an import job to the pool
-func (p *Pool) SubmitImportJob(job *models.Job, source JobSource, cleanup func()) error {
-	select {
-	case p.importChan <- &ImportJob{Job: job, Source: source, Cleanup: cleanup}:
-		return nil
-	default:
-		return fmt.Errorf("import job queue is full")
-	}
+// SubmitImportJob submits an import job to the pool at the priority in its
+// params
+func (p *Pool) SubmitImportJob(job *models.Job, source JobSource, opts ImportOptions, cleanup func()) error {
+	return p.queueImport(&ImportJob{Job: job, Source: source, Options: opts, Cleanup: cleanup})
 }
 
-// SubmitExportJob submits an export job to the pool
+// SubmitExportJob submits an export job to the pool at the priority in its
+// params
 func (p *Pool) SubmitExportJob(job *models.Job, filters *models.ExportFilters) error {
-	select {
-	case p.exportChan <- &ExportJob{Job: job, Filters: filters}:
-		return nil
+	return p.queueExport(&ExportJob{Job: job, Filters: filters})
+}
+
+// SubmitExportDiffJob submits a diff export job to the pool
+func (p *Pool) SubmitExportDiffJob(job *models.Job, diff *models.DiffRange) error {
+	return p.queueExport(&ExportJob{Job: job, Diff: diff})
+}
+
+func (p *Pool) queueImport(importJob *ImportJob) error {
+	if err := p.imports.submit(importJob.Job.ID, jobPriority(importJob.Job), importJob); err != nil {
+		return fmt.Errorf("import job %w", err)
+	}
+	return nil
+}
+
+func (p *Pool) queueExport(exportJob *ExportJob) error {
+	if err := p.exports.submit(exportJob.Job.ID, jobPriority(exportJob.Job), exportJob); err != nil {
+		return fmt.Errorf("export job %w", err)
+	}
+	return nil
+}
+
+// QueuePosition reports where a pending import, export or registered job
+// waits in its queue, or false if the job isn't queued in this process
+func (p *Pool) QueuePosition(jobType models.JobType, id uuid.UUID) (QueuePosition, bool) {
+	switch jobType {
+	case models.JobTypeImport:
+		return p.imports.position(id)
+	case models.JobTypeExport:
+		return p.exports.position(id)
 	default:
-		return fmt.Errorf("export job queue is full")
+		if t := p.registered(jobType); t != nil {
+			return t.queue.position(id)
+		}
+		return QueuePosition{}, false
 	}
 }
 
-func (p *Pool) importWorker(ctx context.Context, id int) {
+func (p *Pool) importWorker(ctx context.Context, id int, stop <-chan struct{}) {
 	defer p.wg.Done()
-	logger := p.logger.With().Int("worker_id", id).Str("type", "import").Logger()
+	workerID := fmt.Sprintf("import-%d", id)
+	logger := p.logger.With().Str("worker_id", workerID).Str("type", "import").Logger()
 	logger.Info().Msg("Import worker started")
 
 	for {
@@ -150,15 +225,31 @@ func (p *Pool) importWorker(ctx context.Context, id int) {
 		case <-p.quit:
 			logger.Info().Msg("Import worker stopping")
 			return
-		case job := <-p.importChan:
-			p.processImportJob(ctx, job, logger)
+		case <-stop:
+			logger.Info().Msg("Import worker stopping (pool resized)")
+			return
+		case <-p.imports.ready:
+			job, waited := p.imports.take()
+			logger.Debug().Str("job_id", job.Job.ID.String()).Dur("queue_wait", waited).Msg("Import job taken from queue")
+			if p.finishedWhileQueued(ctx, job.Job, logger) {
+				p.imports.release()
+				if job.Cleanup != nil {
+					job.Cleanup()
+				}
+				continue
+			}
+			p.attribute(ctx, job.Job, workerID, logger)
+			start := time.Now()
+			p.runImportJob(ctx, job, logger)
+			p.imports.finish(time.Since(start))
 		}
 	}
 }
 
-func (p *Pool) exportWorker(ctx context.Context, id int) {
+func (p *Pool) exportWorker(ctx context.Context, id int, stop <-chan struct{}) {
 	defer p.wg.Done()
-	logger := p.logger.With().Int("worker_id", id).Str("type", "export").Logger()
+	workerID := fmt.Sprintf("export-%d", id)
+	logger := p.logger.With().Str("worker_id", workerID).Str("type", "export").Logger()
 	logger.Info().Msg("Export worker started")
 
 	for {
@@ -169,18 +260,62 @@ func (p *Pool) exportWorker(ctx context.Context, id int) {
 		case <-p.quit:
 			logger.Info().Msg("Export worker stopping")
 			return
-		case job := <-p.exportChan:
-			p.processExportJob(ctx, job, logger)
+		case <-stop:
+			logger.Info().Msg("Export worker stopping (pool resized)")
+			return
+		case <-p.exports.ready:
+			job, waited := p.exports.take()
+			logger.Debug().Str("job_id", job.Job.ID.String()).Dur("queue_wait", waited).Msg("Export job taken from queue")
+			if p.finishedWhileQueued(ctx, job.Job, logger) {
+				p.exports.release()
+				continue
+			}
+			p.attribute(ctx, job.Job, workerID, logger)
+			start := time.Now()
+			p.runExportJob(ctx, job, logger)
+			p.exports.finish(time.Since(start))
 		}
 	}
 }
 
-func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logger zerolog.Logger) {
+// queueWaitSampleInterval is how often the longest queue wait is sampled
+const queueWaitSampleInterval = 5 * time.Second
+
+// sampleQueueWait keeps the max queue wait metric current, including while a
+// queue is stuck behind busy workers
+func (p *Pool) sampleQueueWait(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(queueWaitSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		p.metrics.SetQueueMaxWait(string(models.JobTypeImport), p.imports.maxWait().Seconds())
+		p.metrics.SetQueueMaxWait(string(models.JobTypeExport), p.exports.maxWait().Seconds())
+		p.mu.Lock()
+		for name, t := range p.types {
+			p.metrics.SetQueueMaxWait(string(name), t.queue.maxWait().Seconds())
+		}
+		p.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processImportJob runs an import job and returns the error it failed with,
+// or nil once it completed
+func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logger zerolog.Logger) error {
 	job := importJob.Job
 	startTime := time.Now()
+	defer p.captureLogs(ctx, job)()
+	defer p.startHeartbeat(ctx, job.ID)()
+	logger = logger.With().Str("job_id", job.ID.String()).Logger()
 
 	logger.Info().
-		Str("job_id", job.ID.String()).
 		Str("resource", string(job.Resource)).
 		Msg("Processing import job")
 
@@ -190,11 +325,6 @@ func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logge
 		defer p.metrics.SetActiveJobs(models.JobTypeImport, -1)
 	}
 
-	// Cleanup after processing
-	if importJob.Cleanup != nil {
-		defer importJob.Cleanup()
-	}
-
 	// Open the file
 	var file *os.File
 	var err error
@@ -204,14 +334,14 @@ func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logge
 		if err != nil {
 			logger.Error().Err(err).Msg("Failed to open import file")
 			p.failJob(ctx, job, fmt.Sprintf("failed to open file: %v", err))
-			return
+			return err
 		}
 		defer file.Close()
 	} else if importJob.Source.URL != "" {
 		// Download from URL - for now we support local files only
 		logger.Error().Msg("URL imports not yet implemented")
 		p.failJob(ctx, job, "URL imports not yet implemented")
-		return
+		return errors.ErrInvalidRequest("URL imports not yet implemented")
 	}
 
 	// Determine file format from job or detect it
@@ -221,11 +351,25 @@ func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logge
 	} else {
 		// Detect from file path
 		if importJob.Source.FilePath != "" {
-			if len(importJob.Source.FilePath) > 4 && importJob.Source.FilePath[len(importJob.Source.FilePath)-4:] == ".csv" {
-				format = "csv"
-			} else {
-				format = "ndjson"
-			}
+			format = string(parsers.DetectFormat(importJob.Source.FilePath))
+		}
+	}
+
+	// Keep a copy of the upload in remote storage before it is cleaned up
+	if importJob.Source.FilePath != "" {
+		if err := p.importSvc.ArchiveUpload(ctx, importJob.Source.FilePath); err != nil {
+			logger.Warn().Err(err).Msg("Failed to archive import file")
+		}
+	}
+
+	// Profiling is best effort and must not block the import itself
+	if importJob.Options.Profile && file != nil {
+		if err := p.importSvc.ProfileImport(ctx, job, file); err != nil {
+			logger.Warn().Err(err).Msg("Import profiling failed")
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			p.failJob(ctx, job, fmt.Sprintf("failed to rewind file: %v", err))
+			return err
 		}
 	}
 
@@ -238,7 +382,6 @@ func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logge
 
 	duration := time.Since(startTime)
 	logger.Info().
-		Str("job_id", job.ID.String()).
 		Str("status", string(job.Status)).
 		Int64("duration_ms", duration.Milliseconds()).
 		Msg("Import job completed")
@@ -246,19 +389,27 @@ func (p *Pool) processImportJob(ctx context.Context, importJob *ImportJob, logge
 	// Record metrics
 	if p.metrics != nil {
 		status := "success"
-		if job.Status == models.JobStatusFailed {
+		switch job.Status {
+		case models.JobStatusFailed:
 			status = "error"
+		case models.JobStatusPaused, models.JobStatusCancelled:
+			status = string(job.Status)
 		}
 		p.metrics.RecordJobDuration(models.JobTypeImport, status, duration.Seconds())
 	}
+	return err
 }
 
-func (p *Pool) processExportJob(ctx context.Context, exportJob *ExportJob, logger zerolog.Logger) {
+// processExportJob runs an export job and returns the error it failed with,
+// or nil once it completed
+func (p *Pool) processExportJob(ctx context.Context, exportJob *ExportJob, logger zerolog.Logger) error {
 	job := exportJob.Job
 	startTime := time.Now()
+	defer p.captureLogs(ctx, job)()
+	defer p.startHeartbeat(ctx, job.ID)()
+	logger = logger.With().Str("job_id", job.ID.String()).Logger()
 
 	logger.Info().
-		Str("job_id", job.ID.String()).
 		Str("resource", string(job.Resource)).
 		Msg("Processing export job")
 
@@ -269,7 +420,12 @@ func (p *Pool) processExportJob(ctx context.Context, exportJob *ExportJob, logge
 	}
 
 	// Process the export
-	err := p.exportSvc.ProcessAsyncExport(ctx, job, exportJob.Filters)
+	var err error
+	if exportJob.Diff != nil {
+		err = p.exportSvc.ProcessDiffExport(ctx, job, exportJob.Diff)
+	} else {
+		err = p.exportSvc.ProcessAsyncExport(ctx, job, exportJob.Filters)
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("Export processing failed")
 		// Job status is already updated by the service
@@ -277,7 +433,6 @@ func (p *Pool) processExportJob(ctx context.Context, exportJob *ExportJob, logge
 
 	duration := time.Since(startTime)
 	logger.Info().
-		Str("job_id", job.ID.String()).
 		Str("status", string(job.Status)).
 		Int64("duration_ms", duration.Milliseconds()).
 		Msg("Export job completed")
@@ -285,11 +440,15 @@ func (p *Pool) processExportJob(ctx context.Context, exportJob *ExportJob, logge
 	// Record metrics
 	if p.metrics != nil {
 		status := "success"
-		if job.Status == models.JobStatusFailed {
+		switch job.Status {
+		case models.JobStatusFailed:
 			status = "error"
+		case models.JobStatusCancelled:
+			status = string(models.JobStatusCancelled)
 		}
 		p.metrics.RecordJobDuration(models.JobTypeExport, status, duration.Seconds())
 	}
+	return err
 }
 
 func (p *Pool) failJob(ctx context.Context, job *models.Job, errorMsg string) {
@@ -303,12 +462,55 @@ func (p *Pool) failJob(ctx context.Context, job *models.Job, errorMsg string) {
 	}
 }
 
+// finishedWhileQueued reports whether an operator failed or cancelled a job
+// while it waited in the queue, in which case it isn't run. A job that can't
+// be read is run as queued.
+func (p *Pool) finishedWhileQueued(ctx context.Context, job *models.Job, logger zerolog.Logger) bool {
+	stored, err := p.jobRepo.GetByID(ctx, job.ID)
+	if err != nil || stored == nil {
+		return false
+	}
+	if stored.Status != models.JobStatusFailed && stored.Status != models.JobStatusCancelled {
+		return false
+	}
+	logger.Info().Str("job_id", job.ID.String()).Str("status", string(stored.Status)).Msg("Skipping job finished while queued")
+	p.jobRunEnded(job.ID)
+	return true
+}
+
+// QueueStats describes one of the pool's job queues
+type QueueStats struct {
+	Depth    int
+	Capacity int
+	Workers  int
+	// MaxWait is how long the longest waiting job has been queued
+	MaxWait time.Duration
+}
+
+// Queues returns the state of the import and export queues, and of the
+// queues of registered job types
+func (p *Pool) Queues() map[models.JobType]QueueStats {
+	queues := map[models.JobType]QueueStats{
+		models.JobTypeImport: {Depth: p.imports.len(), Capacity: p.imports.size, Workers: p.imports.workerCount(), MaxWait: p.imports.maxWait()},
+		models.JobTypeExport: {Depth: p.exports.len(), Capacity: p.exports.size, Workers: p.exports.workerCount(), MaxWait: p.exports.maxWait()},
+	}
+	p.typeQueues(queues)
+	return queues
+}
+
 // GetQueueStats returns current queue statistics
 func (p *Pool) GetQueueStats() map[string]int {
-	return map[string]int{
-		"import_queue_size": len(p.importChan),
-		"import_queue_cap":  cap(p.importChan),
-		"export_queue_size": len(p.exportChan),
-		"export_queue_cap":  cap(p.exportChan),
+	stats := map[string]int{
+		"import_queue_size": p.imports.len(),
+		"import_queue_cap":  p.imports.size,
+		"export_queue_size": p.exports.len(),
+		"export_queue_cap":  p.exports.size,
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, t := range p.types {
+		stats[string(name)+"_queue_size"] = t.queue.len()
+		stats[string(name)+"_queue_cap"] = t.queue.size
 	}
+	return stats
 }
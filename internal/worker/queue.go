@@ -0,0 +1,223 @@
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+// recentJobs is how many finished jobs the start time estimate averages over
+const recentJobs = 20
+
+// Queue priority levels. A job's level rises by one for each aging interval
+// it waits, up to priorityMax.
+const (
+	priorityLow    = 0
+	priorityNormal = 1
+	priorityHigh   = 2
+	priorityMax    = priorityHigh
+)
+
+// jobPriority returns the queue level of job's requested priority
+func jobPriority(job *models.Job) int {
+	if job.Params == nil {
+		return priorityNormal
+	}
+	switch job.Params.Priority {
+	case models.JobPriorityLow:
+		return priorityLow
+	case models.JobPriorityHigh:
+		return priorityHigh
+	default:
+		return priorityNormal
+	}
+}
+
+// QueuePosition describes where a pending job waits in its queue
+type QueuePosition struct {
+	// Position is 1 for the next job a free worker takes
+	Position  int
+	JobsAhead int
+	// EstimatedWait is how long until a worker picks the job up. Known is
+	// false until a job of the same type has finished, since the estimate
+	// is built from recent job durations.
+	EstimatedWait time.Duration
+	Known         bool
+}
+
+// queuedJob is a job waiting on a jobQueue
+type queuedJob[T any] struct {
+	id       uuid.UUID
+	priority int
+	queuedAt time.Time
+	job      T
+}
+
+// jobQueue holds the jobs waiting for one type of worker and how long recent
+// jobs took. Workers take the job with the highest priority, oldest first
+// within a level; aging raises a waiting job's level so a steady stream of
+// higher priority jobs can't starve it. ready carries one token per queued
+// job for workers to select on.
+type jobQueue[T any] struct {
+	mu        sync.Mutex
+	workers   int
+	size      int
+	aging     time.Duration
+	now       func() time.Time
+	ready     chan struct{}
+	pending   []queuedJob[T]
+	active    int
+	durations [recentJobs]time.Duration
+	finished  int
+}
+
+// newJobQueue creates a queue of at most size jobs for workers workers.
+// aging is the wait that raises a job one priority level; 0 turns aging off.
+func newJobQueue[T any](workers, size int, aging time.Duration) *jobQueue[T] {
+	return &jobQueue[T]{
+		workers: workers,
+		size:    size,
+		aging:   aging,
+		now:     time.Now,
+		ready:   make(chan struct{}, size),
+	}
+}
+
+// submit queues job under id at priority, failing when the queue is full
+func (q *jobQueue[T]) submit(id uuid.UUID, priority int, job T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) >= q.size {
+		return fmt.Errorf("queue is full")
+	}
+	q.pending = append(q.pending, queuedJob[T]{id: id, priority: priority, queuedAt: q.now(), job: job})
+	// There are never more tokens than queued jobs, so this doesn't block
+	q.ready <- struct{}{}
+	return nil
+}
+
+// take removes the next job for a worker that received a ready token and
+// records it as running. It returns how long the job waited.
+func (q *jobQueue[T]) take() (T, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	next := q.order(now)[0]
+	taken := q.pending[next]
+	q.pending = append(q.pending[:next], q.pending[next+1:]...)
+	q.active++
+	return taken.job, now.Sub(taken.queuedAt)
+}
+
+// finish records that a job taken with take ran for d
+func (q *jobQueue[T]) finish(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.active--
+	q.durations[q.finished%recentJobs] = d
+	q.finished++
+}
+
+// release records that a job taken with take was dropped without running
+func (q *jobQueue[T]) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.active--
+}
+
+// level is a queued job's priority after aging
+func (q *jobQueue[T]) level(job queuedJob[T], now time.Time) int {
+	level := job.priority
+	if q.aging > 0 {
+		level += int(now.Sub(job.queuedAt) / q.aging)
+	}
+	return min(level, priorityMax)
+}
+
+// order returns the indexes of pending in the order workers take them
+func (q *jobQueue[T]) order(now time.Time) []int {
+	order := make([]int, len(q.pending))
+	levels := make([]int, len(q.pending))
+	for i, job := range q.pending {
+		order[i] = i
+		levels[i] = q.level(job, now)
+	}
+	// pending is in arrival order, so a stable sort keeps each level FIFO
+	sort.SliceStable(order, func(a, b int) bool {
+		return levels[order[a]] > levels[order[b]]
+	})
+	return order
+}
+
+// maxWait returns how long the longest waiting job has been queued
+func (q *jobQueue[T]) maxWait() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return 0
+	}
+	// Jobs are appended as they arrive, so the first has waited longest
+	return q.now().Sub(q.pending[0].queuedAt)
+}
+
+// setWorkers records how many workers take from the queue
+func (q *jobQueue[T]) setWorkers(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.workers = n
+}
+
+// workerCount returns how many workers take from the queue
+func (q *jobQueue[T]) workerCount() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.workers
+}
+
+// len returns the number of queued jobs
+func (q *jobQueue[T]) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// position reports where id waits, or false if it isn't queued
+func (q *jobQueue[T]) position(id uuid.UUID) (QueuePosition, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ahead := -1
+	for i, index := range q.order(q.now()) {
+		if q.pending[index].id == id {
+			ahead = i
+			break
+		}
+	}
+	if ahead < 0 {
+		return QueuePosition{}, false
+	}
+
+	pos := QueuePosition{Position: ahead + 1, JobsAhead: ahead}
+	n := min(q.finished, recentJobs)
+	if n == 0 || q.workers <= 0 {
+		return pos, true
+	}
+
+	var total time.Duration
+	for _, d := range q.durations[:n] {
+		total += d
+	}
+	avg := total / time.Duration(n)
+
+	// The job starts once enough running and earlier jobs have finished to
+	// free a worker; the workers finish about one job per avg/workers
+	if waitFor := q.active + ahead - q.workers + 1; waitFor > 0 {
+		pos.EstimatedWait = avg * time.Duration(waitFor) / time.Duration(q.workers)
+	}
+	pos.Known = true
+	return pos, true
+}
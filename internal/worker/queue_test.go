@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestJobQueue_PositionAndEstimate(t *testing.T) {
+	q := newJobQueue[uuid.UUID](2, 10, 0)
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	for _, id := range ids {
+		if err := q.submit(id, priorityNormal, id); err != nil {
+			t.Fatalf("submit() error: %v", err)
+		}
+	}
+
+	pos, ok := q.position(ids[3])
+	if !ok || pos.Position != 4 || pos.JobsAhead != 3 || pos.Known {
+		t.Fatalf("position() = %+v, %v before any job finished", pos, ok)
+	}
+
+	// Both workers take a job; one finishes after 10s and takes the next
+	q.take()
+	q.take()
+	q.finish(10 * time.Second)
+	q.take()
+
+	pos, ok = q.position(ids[3])
+	if !ok || pos.Position != 1 || !pos.Known {
+		t.Fatalf("position() = %+v, %v", pos, ok)
+	}
+	// Two jobs are running on two workers, so one must finish first
+	if pos.EstimatedWait != 5*time.Second {
+		t.Errorf("EstimatedWait = %v, want 5s", pos.EstimatedWait)
+	}
+
+	if _, ok := q.position(ids[0]); ok {
+		t.Error("position() found a started job")
+	}
+}
+
+func TestJobQueue_FullQueueRejects(t *testing.T) {
+	q := newJobQueue[uuid.UUID](1, 1, 0)
+	if err := q.submit(uuid.New(), priorityNormal, uuid.Nil); err != nil {
+		t.Fatalf("submit() error: %v", err)
+	}
+	id := uuid.New()
+	if err := q.submit(id, priorityNormal, id); err == nil {
+		t.Fatal("submit() error = nil, want the queue to be full")
+	}
+	if _, ok := q.position(id); ok {
+		t.Error("position() found a job that was never queued")
+	}
+	if len(q.ready) != 1 {
+		t.Errorf("ready tokens = %d, want one per queued job", len(q.ready))
+	}
+}
+
+func TestJobQueue_PriorityAging(t *testing.T) {
+	// A low priority job competes with a steady stream of normal ones
+	for aging, want := range map[time.Duration]string{time.Minute: "low", 0: "n3"} {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		now := start
+		q := newJobQueue[string](1, 10, aging)
+		q.now = func() time.Time { return now }
+
+		at := func(d time.Duration) { now = start.Add(d) }
+		take := func() string {
+			job, _ := q.take()
+			return job
+		}
+
+		q.submit(uuid.New(), priorityLow, "low")
+		at(10 * time.Second)
+		q.submit(uuid.New(), priorityNormal, "n1")
+		at(20 * time.Second)
+		if got := take(); got != "n1" {
+			t.Fatalf("aging %v: take() = %s, want n1", aging, got)
+		}
+		at(40 * time.Second)
+		q.submit(uuid.New(), priorityNormal, "n2")
+		at(50 * time.Second)
+		if got := take(); got != "n2" {
+			t.Fatalf("aging %v: take() = %s, want n2", aging, got)
+		}
+
+		// After a minute the low job has aged to normal and, having waited
+		// longest, goes first
+		at(70 * time.Second)
+		q.submit(uuid.New(), priorityNormal, "n3")
+		if got := q.maxWait(); got != 70*time.Second {
+			t.Errorf("aging %v: maxWait() = %v, want 70s", aging, got)
+		}
+		if got := take(); got != want {
+			t.Errorf("aging %v: take() = %s, want %s", aging, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	"github.com/rs/zerolog"
+)
+
+// runImportJob processes an import job, recovering from a panic so the
+// worker survives it. The upload is kept while the job is queued for retry,
+// dead-lettered or paused. A cancelled job isn't retried.
+func (p *Pool) runImportJob(ctx context.Context, importJob *ImportJob, logger zerolog.Logger) {
+	defer p.jobRunEnded(importJob.Job.ID)
+	jobCtx, release := p.jobContext(ctx, importJob.Job.ID)
+	defer release()
+	retried := false
+	defer func() {
+		if !retried && importJob.Cleanup != nil {
+			importJob.Cleanup()
+		}
+	}()
+	if p.cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				retried = p.handlePanic(ctx, importJob.Job, r, debug.Stack(), logger, func() error {
+					return p.queueImport(importJob)
+				})
+			}
+		}()
+	}
+
+	err := p.processImportJob(jobCtx, importJob, logger)
+	p.forgetPanics(importJob.Job.ID)
+	if stderrors.Is(err, importservice.ErrPaused) {
+		// The job waits for an operator to resume it
+		retried = true
+		return
+	}
+	if err != nil && !models.JobCancelled(jobCtx) {
+		retried = p.retryFailedJob(ctx, importJob.Job, err, logger, func() error {
+			return p.queueImport(importJob)
+		})
+	}
+}
+
+// RunImportJob processes an import job on the calling goroutine, for callers
+// that wait for the result. A sync job isn't retried after a panic; it fails
+// with the panic recorded as a job error.
+func (p *Pool) RunImportJob(ctx context.Context, job *models.Job, source JobSource, opts ImportOptions, cleanup func()) {
+	logger := p.logger.With().Str("worker_id", "sync").Str("type", "import").Bool("sync", true).Logger()
+	defer p.jobRunEnded(job.ID)
+	jobCtx, release := p.jobContext(ctx, job.ID)
+	defer release()
+	if cleanup != nil {
+		// A paused job keeps its upload to be resumed from
+		defer func() {
+			if job.Status != models.JobStatusPaused {
+				cleanup()
+			}
+		}()
+	}
+	defer p.recoverSyncJob(ctx, job, logger)
+
+	p.attribute(ctx, job, "sync", logger)
+	p.processImportJob(jobCtx, &ImportJob{Job: job, Source: source, Options: opts}, logger)
+}
+
+// RunExportJob processes an export job on the calling goroutine, for exports
+// small enough to answer within the request. Like RunImportJob it doesn't
+// retry the job after a panic or failure.
+func (p *Pool) RunExportJob(ctx context.Context, job *models.Job, filters *models.ExportFilters) {
+	logger := p.logger.With().Str("worker_id", "sync").Str("type", "export").Bool("sync", true).Logger()
+	defer p.jobRunEnded(job.ID)
+	jobCtx, release := p.jobContext(ctx, job.ID)
+	defer release()
+	defer p.recoverSyncJob(ctx, job, logger)
+
+	p.attribute(ctx, job, "sync", logger)
+	p.processExportJob(jobCtx, &ExportJob{Job: job, Filters: filters}, logger)
+}
+
+// recoverSyncJob fails a job run on the calling goroutine that panicked,
+// recording the panic as a job error. It must be deferred.
+func (p *Pool) recoverSyncJob(ctx context.Context, job *models.Job, logger zerolog.Logger) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	msg := fmt.Sprintf("panic: %v", r)
+	trace := string(debug.Stack())
+	if err := p.jobRepo.AddErrors(ctx, []*models.JobError{{
+		JobID:        job.ID,
+		ErrorCode:    errors.ErrCodePanic,
+		ErrorMessage: msg,
+		RawData:      &trace,
+	}}); err != nil {
+		logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to record job panic")
+	}
+	logger.Error().Str("job_id", job.ID.String()).Str("panic", fmt.Sprint(r)).Str("stack", trace).Msg("Job panicked")
+	p.failJob(ctx, job, fmt.Sprintf("%s: %s", errors.ErrCodePanic, msg))
+}
+
+// runExportJob processes an export job, recovering from a panic so the
+// worker survives it. A cancelled job isn't retried.
+func (p *Pool) runExportJob(ctx context.Context, exportJob *ExportJob, logger zerolog.Logger) {
+	defer p.jobRunEnded(exportJob.Job.ID)
+	jobCtx, release := p.jobContext(ctx, exportJob.Job.ID)
+	defer release()
+	if p.cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				p.handlePanic(ctx, exportJob.Job, r, debug.Stack(), logger, func() error {
+					return p.queueExport(exportJob)
+				})
+			}
+		}()
+	}
+
+	err := p.processExportJob(jobCtx, exportJob, logger)
+	p.forgetPanics(exportJob.Job.ID)
+	if err != nil && !models.JobCancelled(jobCtx) {
+		p.retryFailedJob(ctx, exportJob.Job, err, logger, func() error {
+			return p.queueExport(exportJob)
+		})
+	}
+}
+
+// handlePanic records a job panic with its stack in the job's errors, then
+// retries the job or, once it has panicked MaxJobPanics times, quarantines it
+// as a poison job. It reports whether the job was queued again.
+func (p *Pool) handlePanic(ctx context.Context, job *models.Job, value interface{}, stack []byte, logger zerolog.Logger, retry func() error) bool {
+	msg := fmt.Sprintf("panic: %v", value)
+	trace := string(stack)
+	if err := p.jobRepo.AddErrors(ctx, []*models.JobError{{
+		JobID:        job.ID,
+		ErrorCode:    errors.ErrCodePanic,
+		ErrorMessage: msg,
+		RawData:      &trace,
+	}}); err != nil {
+		logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to record job panic")
+	}
+
+	count := p.recordPanic(job.ID)
+	logger.Error().
+		Str("job_id", job.ID.String()).
+		Int("panics", count).
+		Str("panic", fmt.Sprint(value)).
+		Str("stack", trace).
+		Msg("Job panicked")
+
+	if count < p.cfg.MaxJobPanics {
+		job.Status = models.JobStatusPending
+		if err := p.jobRepo.UpdateStatus(ctx, job.ID, models.JobStatusPending); err != nil {
+			logger.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to reset job status")
+		}
+		if err := retry(); err == nil {
+			logger.Warn().Str("job_id", job.ID.String()).Msg("Retrying job after panic")
+			return true
+		}
+		p.forgetPanics(job.ID)
+		p.failJob(ctx, job, fmt.Sprintf("%s: %s (retry queue full)", errors.ErrCodePanic, msg))
+		return false
+	}
+
+	p.forgetPanics(job.ID)
+	p.failJob(ctx, job, fmt.Sprintf("%s: job quarantined after %d panics; last %s", errors.ErrCodeJobQuarantined, count, msg))
+	return false
+}
+func (p *Pool) recordPanic(jobID uuid.UUID) int {
+	p.panicMu.Lock()
+	defer p.panicMu.Unlock()
+	p.panics[jobID]++
+	return p.panics[jobID]
+}
+
+func (p *Pool) forgetPanics(jobID uuid.UUID) {
+	p.panicMu.Lock()
+	defer p.panicMu.Unlock()
+	delete(p.panics, jobID)
+}
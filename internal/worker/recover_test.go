@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+// The pool has no export service, so every export it runs panics
+func newPanickingPool(t *testing.T) (*Pool, *memory.JobRepository) {
+	t.Helper()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{
+		QueueSize: 4, ExportWorkers: 1, MaxAttempts: 3, RetryBackoff: time.Hour,
+		RecoverPanics: true, MaxJobPanics: 2,
+	})
+	p.running = true
+	return p, jobs
+}
+
+func createProcessingExport(t *testing.T, jobs *memory.JobRepository) *models.Job {
+	t.Helper()
+	ctx := context.Background()
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, Params: &models.JobParams{Format: "ndjson"}}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := jobs.SetStarted(ctx, job.ID); err != nil {
+		t.Fatalf("SetStarted() error: %v", err)
+	}
+	return job
+}
+
+func panicErrors(t *testing.T, jobs *memory.JobRepository, job *models.Job) int {
+	t.Helper()
+	errs, _, err := jobs.GetErrors(context.Background(), job.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("GetErrors() error: %v", err)
+	}
+	n := 0
+	for _, e := range errs {
+		if e.ErrorCode == errors.ErrCodePanic && e.RawData != nil && *e.RawData != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPool_HandlePanic(t *testing.T) {
+	ctx := context.Background()
+	p, jobs := newPanickingPool(t)
+	job := createProcessingExport(t, jobs)
+	exportJob := &ExportJob{Job: job}
+
+	// The first panic is recorded with its stack and the job queued again
+	p.runExportJob(ctx, exportJob, zerolog.Nop())
+	if n := panicErrors(t, jobs, job); n != 1 {
+		t.Fatalf("panic errors after first run = %d, want 1", n)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusPending {
+		t.Errorf("status after first panic = %s, want pending", stored.Status)
+	}
+	if _, queued := p.QueuePosition(models.JobTypeExport, job.ID); !queued {
+		t.Error("job was not requeued after its first panic")
+	}
+
+	// Reaching MaxJobPanics quarantines it
+	queued, _ := p.exports.take()
+	p.runExportJob(ctx, queued, zerolog.Nop())
+	if n := panicErrors(t, jobs, job); n != 2 {
+		t.Errorf("panic errors after second run = %d, want 2", n)
+	}
+	stored, _ = jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusFailed || stored.ErrorMessage == nil || !strings.HasPrefix(*stored.ErrorMessage, errors.ErrCodeJobQuarantined) {
+		t.Errorf("after second panic status = %s, error = %v; want failed with %s", stored.Status, stored.ErrorMessage, errors.ErrCodeJobQuarantined)
+	}
+	if _, queued := p.QueuePosition(models.JobTypeExport, job.ID); queued {
+		t.Error("quarantined job is still queued")
+	}
+	if n := len(p.panics); n != 0 {
+		t.Errorf("panic counts kept for %d jobs, want 0", n)
+	}
+}
+
+func TestPool_RecoverSyncJob(t *testing.T) {
+	ctx := context.Background()
+	p, jobs := newPanickingPool(t)
+	job := createProcessingExport(t, jobs)
+
+	// A sync job fails on its first panic instead of being retried
+	p.RunExportJob(ctx, job, nil)
+	if n := panicErrors(t, jobs, job); n != 1 {
+		t.Errorf("panic errors = %d, want 1", n)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusFailed || stored.ErrorMessage == nil || !strings.HasPrefix(*stored.ErrorMessage, errors.ErrCodePanic) {
+		t.Errorf("status = %s, error = %v; want failed with %s", stored.Status, stored.ErrorMessage, errors.ErrCodePanic)
+	}
+	if _, queued := p.QueuePosition(models.JobTypeExport, job.ID); queued {
+		t.Error("sync job was queued after its panic")
+	}
+}
@@ -0,0 +1,241 @@
+package worker
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rs/zerolog"
+)
+
+// Job types other than imports and exports, such as search index syncs,
+// register a handler with the pool, which runs them on a queue and workers of
+// their own with the same priority, cancellation, heartbeat, retry and panic
+// handling.
+
+var (
+	// ErrJobTypeRegistered is returned when registering a job type the pool
+	// already runs
+	ErrJobTypeRegistered = stderrors.New("job type is already registered")
+	// ErrUnknownJobType is returned when submitting a job of a type that
+	// wasn't registered
+	ErrUnknownJobType = stderrors.New("job type is not registered")
+)
+
+// JobHandler runs a job of a registered type with the payload it was
+// submitted with. Like the import and export services it records the job's
+// outcome itself, and returns the error the job failed with.
+type JobHandler func(ctx context.Context, job *models.Job, payload any) error
+
+// JobTypeConfig describes how the pool runs a registered job type
+type JobTypeConfig struct {
+	Handler JobHandler
+	// Workers is how many of the type's jobs run at once, at least 1
+	Workers int
+	// QueueSize caps the jobs waiting to run; 0 uses WORKER_QUEUE_SIZE
+	QueueSize int
+	// Retry retries a failed job with backoff, dead-lettering it after
+	// WORKER_MAX_ATTEMPTS, as imports and exports are
+	Retry bool
+	// Payload rebuilds a job's payload from what the job records, so it can
+	// be requeued from dead letters or recovered from an instance that died.
+	// Without it such jobs fail instead.
+	Payload func(job *models.Job) (any, error)
+}
+
+// TaskJob is a job of a registered type waiting on its queue
+type TaskJob struct {
+	Job     *models.Job
+	Payload any
+}
+
+// jobType is a registered job type and the workers running it
+type jobType struct {
+	name    models.JobType
+	cfg     JobTypeConfig
+	queue   *jobQueue[*TaskJob]
+	stop    []chan struct{}
+	spawned int
+}
+
+// Register adds a job type the pool runs with cfg. Registering a type again,
+// or one of the built-in types, fails. Types registered after Start start
+// their workers at once.
+func (p *Pool) Register(name models.JobType, cfg JobTypeConfig) error {
+	if cfg.Handler == nil {
+		return fmt.Errorf("job type %s has no handler", name)
+	}
+	if cfg.Workers < 1 {
+		return fmt.Errorf("job type %s needs at least one worker", name)
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = p.cfg.QueueSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch name {
+	case models.JobTypeImport, models.JobTypeExport:
+		return fmt.Errorf("%s: %w", name, ErrJobTypeRegistered)
+	}
+	if _, ok := p.types[name]; ok {
+		return fmt.Errorf("%s: %w", name, ErrJobTypeRegistered)
+	}
+	t := &jobType{name: name, cfg: cfg, queue: newJobQueue[*TaskJob](cfg.Workers, cfg.QueueSize, p.cfg.PriorityAging)}
+	p.types[name] = t
+	if p.running {
+		p.startTypeWorkers(t)
+	}
+	return nil
+}
+
+// RegisteredTypes returns the registered job types, sorted
+func (p *Pool) RegisteredTypes() []models.JobType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]models.JobType, 0, len(p.types))
+	for name := range p.types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// registered returns the registered type name, or nil
+func (p *Pool) registered(name models.JobType) *jobType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.types[name]
+}
+
+// Submit queues a job of a registered type, to be run with payload at the
+// priority in its params
+func (p *Pool) Submit(job *models.Job, payload any) error {
+	t := p.registered(job.Type)
+	if t == nil {
+		return fmt.Errorf("%s: %w", job.Type, ErrUnknownJobType)
+	}
+	return p.queueTask(t, &TaskJob{Job: job, Payload: payload})
+}
+
+func (p *Pool) queueTask(t *jobType, task *TaskJob) error {
+	if err := t.queue.submit(task.Job.ID, jobPriority(task.Job), task); err != nil {
+		return fmt.Errorf("%s job %w", t.name, err)
+	}
+	return nil
+}
+
+// startTypeWorkers starts the workers of t. p.mu is held and the pool is
+// running.
+func (p *Pool) startTypeWorkers(t *jobType) {
+	for len(t.stop) < t.cfg.Workers {
+		stop := make(chan struct{})
+		t.stop = append(t.stop, stop)
+		p.wg.Add(1)
+		go p.taskWorker(p.runCtx, t, t.spawned, stop)
+		t.spawned++
+	}
+}
+
+func (p *Pool) taskWorker(ctx context.Context, t *jobType, id int, stop <-chan struct{}) {
+	defer p.wg.Done()
+	workerID := fmt.Sprintf("%s-%d", t.name, id)
+	logger := p.logger.With().Str("worker_id", workerID).Str("type", string(t.name)).Logger()
+	logger.Info().Msg("Worker started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("Worker stopping (context cancelled)")
+			return
+		case <-p.quit:
+			logger.Info().Msg("Worker stopping")
+			return
+		case <-stop:
+			logger.Info().Msg("Worker stopping")
+			return
+		case <-t.queue.ready:
+			task, waited := t.queue.take()
+			logger.Debug().Str("job_id", task.Job.ID.String()).Dur("queue_wait", waited).Msg("Job taken from queue")
+			if p.finishedWhileQueued(ctx, task.Job, logger) {
+				t.queue.release()
+				continue
+			}
+			p.attribute(ctx, task.Job, workerID, logger)
+			start := time.Now()
+			p.runTaskJob(ctx, t, task, logger)
+			t.queue.finish(time.Since(start))
+		}
+	}
+}
+
+// runTaskJob runs a job of a registered type, recovering from a panic so the
+// worker survives it. A cancelled job isn't retried.
+func (p *Pool) runTaskJob(ctx context.Context, t *jobType, task *TaskJob, logger zerolog.Logger) {
+	defer p.jobRunEnded(task.Job.ID)
+	jobCtx, release := p.jobContext(ctx, task.Job.ID)
+	defer release()
+	if p.cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				p.handlePanic(ctx, task.Job, r, debug.Stack(), logger, func() error {
+					return p.queueTask(t, task)
+				})
+			}
+		}()
+	}
+
+	err := p.processTaskJob(jobCtx, t, task, logger)
+	p.forgetPanics(task.Job.ID)
+	if err != nil && t.cfg.Retry && !models.JobCancelled(jobCtx) {
+		p.retryFailedJob(ctx, task.Job, err, logger, func() error {
+			return p.queueTask(t, task)
+		})
+	}
+}
+
+// processTaskJob runs a job of a registered type and returns the error it
+// failed with
+func (p *Pool) processTaskJob(ctx context.Context, t *jobType, task *TaskJob, logger zerolog.Logger) error {
+	job := task.Job
+	startTime := time.Now()
+	defer p.captureLogs(ctx, job)()
+	defer p.startHeartbeat(ctx, job.ID)()
+	logger = logger.With().Str("job_id", job.ID.String()).Logger()
+	logger.Info().Msg("Processing job")
+
+	if p.metrics != nil {
+		p.metrics.SetActiveJobs(job.Type, 1)
+		defer p.metrics.SetActiveJobs(job.Type, -1)
+	}
+
+	err := t.cfg.Handler(ctx, job, task.Payload)
+	status := "success"
+	if err != nil {
+		logger.Error().Err(err).Msg("Job processing failed")
+		status = "error"
+	}
+
+	duration := time.Since(startTime)
+	logger.Info().
+		Str("status", string(job.Status)).
+		Int64("duration_ms", duration.Milliseconds()).
+		Msg("Job completed")
+	if p.metrics != nil {
+		p.metrics.RecordJobDuration(job.Type, status, duration.Seconds())
+	}
+	return err
+}
+
+// typeQueues adds the queues of the registered types to queues
+func (p *Pool) typeQueues(queues map[models.JobType]QueueStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, t := range p.types {
+		queues[name] = QueueStats{Depth: t.queue.len(), Capacity: t.queue.size, Workers: t.queue.workerCount(), MaxWait: t.queue.maxWait()}
+	}
+}
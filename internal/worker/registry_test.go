@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_RegisteredJobType(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 4, MaxAttempts: 2, RetryBackoff: time.Millisecond})
+
+	const purge models.JobType = "purge"
+	ran := make(chan string, 4)
+	handler := func(ctx context.Context, job *models.Job, payload any) error {
+		ran <- payload.(string)
+		if payload == "flaky" {
+			return fmt.Errorf("connection refused")
+		}
+		job.Status = models.JobStatusCompleted
+		return jobs.Update(ctx, job)
+	}
+	if err := p.Register(purge, JobTypeConfig{Handler: handler, Workers: 1, Retry: true}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := p.Register(purge, JobTypeConfig{Handler: handler, Workers: 1}); !errors.Is(err, ErrJobTypeRegistered) {
+		t.Errorf("Register() again error = %v, want ErrJobTypeRegistered", err)
+	}
+	if err := p.Register(models.JobTypeImport, JobTypeConfig{Handler: handler, Workers: 1}); !errors.Is(err, ErrJobTypeRegistered) {
+		t.Errorf("Register(import) error = %v, want ErrJobTypeRegistered", err)
+	}
+	if err := p.Submit(&models.Job{Type: "reindex"}, nil); !errors.Is(err, ErrUnknownJobType) {
+		t.Errorf("Submit() of an unregistered type error = %v, want ErrUnknownJobType", err)
+	}
+
+	newJob := func() *models.Job {
+		job := &models.Job{Type: purge, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		return job
+	}
+	job := newJob()
+	if err := p.Submit(job, "old-users"); err != nil {
+		t.Fatalf("Submit() error: %v", err)
+	}
+	if pos, ok := p.QueuePosition(purge, job.ID); !ok || pos.Position != 1 {
+		t.Errorf("QueuePosition() = %+v, %v; want first in the queue", pos, ok)
+	}
+	if q := p.Queues()[purge]; q.Depth != 1 || q.Capacity != 4 || q.Workers != 1 {
+		t.Errorf("queue = %+v, want one of 4 queued for 1 worker", q)
+	}
+
+	// Queued jobs run once the pool starts
+	p.Start(ctx)
+	defer p.Stop()
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-ran:
+			if got != want {
+				t.Errorf("ran %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q did not run", want)
+		}
+	}
+	expect("old-users")
+	waitForStatus(t, jobs, job, models.JobStatusCompleted)
+
+	// A failing job is retried, then dead-lettered, and can't be requeued
+	// without a payload loader
+	flaky := newJob()
+	if err := p.Submit(flaky, "flaky"); err != nil {
+		t.Fatalf("Submit() error: %v", err)
+	}
+	expect("flaky")
+	expect("flaky")
+	stored := waitForStatus(t, jobs, flaky, models.JobStatusDeadLetter)
+	if err := p.Requeue(ctx, stored); !errors.Is(err, ErrNotRequeueable) {
+		t.Errorf("Requeue() error = %v, want ErrNotRequeueable", err)
+	}
+}
+
+// waitForStatus polls until job has status, returning the stored job
+func waitForStatus(t *testing.T, jobs *memory.JobRepository, job *models.Job, status models.JobStatus) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		stored, _ := jobs.GetByID(context.Background(), job.ID)
+		if stored != nil && stored.Status == status {
+			return stored
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job status = %+v, want %s", stored, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
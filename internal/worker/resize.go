@@ -0,0 +1,57 @@
+package worker
+
+// Resize changes how many import and export workers run. Added workers
+// start at once; removed ones stop when they next wait for a job, so jobs
+// already running finish. Before Start it only sets the counts Start uses.
+func (p *Pool) Resize(importWorkers, exportWorkers int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running {
+		p.cfg.ImportWorkers, p.cfg.ExportWorkers = importWorkers, exportWorkers
+		p.imports.setWorkers(importWorkers)
+		p.exports.setWorkers(exportWorkers)
+		return
+	}
+
+	before := [2]int{len(p.importStop), len(p.exportStop)}
+	p.scale(importWorkers, exportWorkers)
+	p.logger.Info().
+		Int("import_workers", importWorkers).
+		Int("export_workers", exportWorkers).
+		Ints("previous", before[:]).
+		Msg("Worker pool resized")
+}
+
+// scale starts or stops workers until importWorkers and exportWorkers run.
+// p.mu is held and the pool is running.
+func (p *Pool) scale(importWorkers, exportWorkers int) {
+	for len(p.importStop) < importWorkers {
+		stop := make(chan struct{})
+		p.importStop = append(p.importStop, stop)
+		p.wg.Add(1)
+		go p.importWorker(p.runCtx, p.importSpawned, stop)
+		p.importSpawned++
+	}
+	for len(p.importStop) > importWorkers {
+		last := len(p.importStop) - 1
+		close(p.importStop[last])
+		p.importStop = p.importStop[:last]
+	}
+
+	for len(p.exportStop) < exportWorkers {
+		stop := make(chan struct{})
+		p.exportStop = append(p.exportStop, stop)
+		p.wg.Add(1)
+		go p.exportWorker(p.runCtx, p.exportSpawned, stop)
+		p.exportSpawned++
+	}
+	for len(p.exportStop) > exportWorkers {
+		last := len(p.exportStop) - 1
+		close(p.exportStop[last])
+		p.exportStop = p.exportStop[:last]
+	}
+
+	p.cfg.ImportWorkers, p.cfg.ExportWorkers = importWorkers, exportWorkers
+	p.imports.setWorkers(importWorkers)
+	p.exports.setWorkers(exportWorkers)
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_Resize(t *testing.T) {
+	p := NewPool(nil, nil, memory.NewJobRepository(memory.NewDB()), nil, zerolog.Nop(),
+		config.WorkerConfig{ImportWorkers: 1, ExportWorkers: 1, QueueSize: 1})
+
+	// Before Start only the counts change
+	p.Resize(2, 1)
+	if len(p.importStop) != 0 {
+		t.Fatalf("workers started before Start: %d", len(p.importStop))
+	}
+
+	p.Start(context.Background())
+	defer p.Stop()
+	if len(p.importStop) != 2 || len(p.exportStop) != 1 {
+		t.Fatalf("workers = %d/%d, want 2/1", len(p.importStop), len(p.exportStop))
+	}
+
+	p.Resize(4, 0)
+	queues := p.Queues()
+	if len(p.importStop) != 4 || len(p.exportStop) != 0 {
+		t.Errorf("workers = %d/%d, want 4/0", len(p.importStop), len(p.exportStop))
+	}
+	if queues[models.JobTypeImport].Workers != 4 || queues[models.JobTypeExport].Workers != 0 {
+		t.Errorf("queue workers = %d/%d, want 4/0", queues[models.JobTypeImport].Workers, queues[models.JobTypeExport].Workers)
+	}
+
+	// Stop waits for every worker, so a stopped worker that didn't exit
+	// would hang here
+	p.Resize(1, 1)
+	if p.importSpawned != 4 || p.exportSpawned != 2 {
+		t.Errorf("spawned = %d/%d, want 4/2 with no worker ID reused", p.importSpawned, p.exportSpawned)
+	}
+}
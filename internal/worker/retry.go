@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rs/zerolog"
+)
+
+var (
+	// ErrUploadMissing is returned when a dead-lettered import is requeued,
+	// or a paused one resumed, after its upload was removed
+	ErrUploadMissing = stderrors.New("import file is no longer available")
+	// ErrNotRequeueable is returned for jobs other than imports, exports and
+	// registered types that can rebuild their payload
+	ErrNotRequeueable = stderrors.New("only import, export and registered jobs with a payload loader can be requeued")
+)
+
+// retryFailedJob decides what happens to a job that failed with err. A job
+// rejected for its input fails for good. Any other failure is retried after
+// a backoff until the job has failed MaxAttempts times, when it moves to
+// dead_letter. It reports whether the job was kept for a retry or requeue.
+func (p *Pool) retryFailedJob(ctx context.Context, job *models.Job, err error, logger zerolog.Logger, resubmit func() error) bool {
+	if p.cfg.MaxAttempts <= 1 || isPermanent(err) {
+		return false
+	}
+
+	attempts := job.Attempts + 1
+	failure := err.Error()
+	log := logger.With().Str("job_id", job.ID.String()).Int("attempts", attempts).Logger()
+	if attempts >= p.cfg.MaxAttempts {
+		p.deadLetter(ctx, job, attempts, failure, log)
+		return true
+	}
+
+	job.Status = models.JobStatusPending
+	job.Attempts = attempts
+	if err := p.jobRepo.ResetForRetry(ctx, job.ID, attempts); err != nil {
+		log.Error().Err(err).Msg("Failed to reset job for retry")
+		return false
+	}
+
+	if p.metrics != nil {
+		p.metrics.RecordJobRetry(string(job.Type), "retried")
+	}
+	delay := p.retryBackoff(attempts)
+	log.Warn().Str("error", failure).Dur("backoff", delay).Msg("Retrying failed job")
+	time.AfterFunc(delay, func() {
+		if !p.isRunning() {
+			return
+		}
+		if err := resubmit(); err != nil {
+			p.deadLetter(ctx, job, attempts, fmt.Sprintf("%s (retry queue full)", failure), log)
+		}
+	})
+	return true
+}
+
+// deadLetter parks a job that used up its attempts
+func (p *Pool) deadLetter(ctx context.Context, job *models.Job, attempts int, errorMsg string, logger zerolog.Logger) {
+	job.Status = models.JobStatusDeadLetter
+	job.Attempts = attempts
+	job.ErrorMessage = &errorMsg
+	if err := p.jobRepo.SetDeadLetter(ctx, job.ID, attempts, errorMsg); err != nil {
+		logger.Error().Err(err).Msg("Failed to dead-letter job")
+		return
+	}
+	if p.metrics != nil {
+		p.metrics.RecordJobRetry(string(job.Type), string(models.JobStatusDeadLetter))
+	}
+	logger.Error().Str("error", errorMsg).Msg("Job moved to dead letters")
+}
+
+// retryBackoff is the wait before retrying a job that has failed attempts
+// times: RetryBackoff, doubled for each earlier failure, capped at
+// RetryMaxBackoff
+func (p *Pool) retryBackoff(attempts int) time.Duration {
+	delay := p.cfg.RetryBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if p.cfg.RetryMaxBackoff > 0 && delay >= p.cfg.RetryMaxBackoff {
+			return p.cfg.RetryMaxBackoff
+		}
+	}
+	return delay
+}
+
+// isPermanent reports whether err rejects the job's input, such as an empty
+// file, so running it again would fail the same way
+func isPermanent(err error) bool {
+	var appErr *errors.AppError
+	return stderrors.As(err, &appErr) && appErr.StatusCode >= 400 && appErr.StatusCode < 500
+}
+
+func (p *Pool) isRunning() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// Requeue queues a dead-lettered import, export or registered job again with
+// a fresh set of attempts. An import needs its upload to still be on disk.
+func (p *Pool) Requeue(ctx context.Context, job *models.Job) error {
+	attempts, errorMsg := job.Attempts, jobError(job)
+	return p.requeue(ctx, job, 0, func() error {
+		job.Status = models.JobStatusDeadLetter
+		job.Attempts = attempts
+		return p.jobRepo.SetDeadLetter(ctx, job.ID, attempts, errorMsg)
+	})
+}
+
+// Resume queues an import the circuit breaker paused again, keeping its
+// attempts. It reads its upload from the start but skips the staged rows
+// the batches before the pause wrote.
+func (p *Pool) Resume(ctx context.Context, job *models.Job) error {
+	errorMsg := jobError(job)
+	return p.requeue(ctx, job, job.Attempts, func() error {
+		job.Status = models.JobStatusPaused
+		return p.jobRepo.SetPaused(ctx, job.ID, errorMsg)
+	})
+}
+
+// requeue resets job to pending with attempts and queues it again. When it
+// can't be queued, restore puts the job back where it was so it can be
+// queued later.
+func (p *Pool) requeue(ctx context.Context, job *models.Job, attempts int, restore func() error) error {
+	submit, err := p.resubmitFunc(job)
+	if err != nil {
+		return err
+	}
+
+	if err := p.jobRepo.ResetForRetry(ctx, job.ID, attempts); err != nil {
+		return err
+	}
+	job.Status = models.JobStatusPending
+	job.Attempts = attempts
+	if err := submit(); err != nil {
+		if restoreErr := restore(); restoreErr != nil {
+			p.logger.Error().Err(restoreErr).Str("job_id", job.ID.String()).Msg("Failed to restore job")
+		}
+		return err
+	}
+	return nil
+}
+
+func jobError(job *models.Job) string {
+	if job.ErrorMessage == nil {
+		return ""
+	}
+	return *job.ErrorMessage
+}
+
+// resubmitFunc returns a func that queues job again from what the job
+// records, for jobs whose in-process queue entry is gone
+func (p *Pool) resubmitFunc(job *models.Job) (func() error, error) {
+	switch job.Type {
+	case models.JobTypeImport:
+		if job.FilePath == nil || *job.FilePath == "" {
+			return nil, ErrUploadMissing
+		}
+		filePath := *job.FilePath
+		if _, err := os.Stat(filePath); err != nil {
+			return nil, ErrUploadMissing
+		}
+		opts := ImportOptions{Profile: job.Params != nil && job.Params.Profile}
+		return func() error {
+			return p.SubmitImportJob(job, JobSource{FilePath: filePath}, opts, func() { p.importSvc.RemoveUpload(filePath) })
+		}, nil
+	case models.JobTypeExport:
+		var params models.JobParams
+		if job.Params != nil {
+			params = *job.Params
+		}
+		return func() error {
+			if params.Diff != nil {
+				return p.SubmitExportDiffJob(job, params.Diff)
+			}
+			return p.SubmitExportJob(job, params.Filters)
+		}, nil
+	default:
+		t := p.registered(job.Type)
+		if t == nil || t.cfg.Payload == nil {
+			return nil, ErrNotRequeueable
+		}
+		payload, err := t.cfg.Payload(job)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			return p.queueTask(t, &TaskJob{Job: job, Payload: payload})
+		}, nil
+	}
+}
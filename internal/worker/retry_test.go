@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_RetryBackoff(t *testing.T) {
+	p := &Pool{cfg: config.WorkerConfig{RetryBackoff: 10 * time.Second, RetryMaxBackoff: 35 * time.Second}}
+	for attempts, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 35 * time.Second, 8: 35 * time.Second} {
+		if got := p.retryBackoff(attempts); got != want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestPool_RetryFailedJob(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1, MaxAttempts: 3, RetryBackoff: time.Millisecond})
+	p.running = true
+
+	job := &models.Job{Type: models.JobTypeExport, Resource: models.ResourceTypeUsers, Status: models.JobStatusProcessing}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := jobs.AddErrors(ctx, []*models.JobError{{JobID: job.ID, ErrorCode: errors.ErrCodeInternalError, ErrorMessage: "boom"}}); err != nil {
+		t.Fatalf("AddErrors() error: %v", err)
+	}
+
+	resubmitted := make(chan struct{}, 1)
+	resubmit := func() error {
+		resubmitted <- struct{}{}
+		return nil
+	}
+	failure := fmt.Errorf("connection refused")
+
+	// A rejected input fails for good
+	if p.retryFailedJob(ctx, job, errors.ErrEmptyFile("no rows"), zerolog.Nop(), resubmit) {
+		t.Fatal("retryFailedJob() kept a job that failed on its input")
+	}
+
+	if !p.retryFailedJob(ctx, job, failure, zerolog.Nop(), resubmit) {
+		t.Fatal("retryFailedJob() did not retry the first failure")
+	}
+	select {
+	case <-resubmitted:
+	case <-time.After(time.Second):
+		t.Fatal("job was not resubmitted after the backoff")
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusPending || stored.Attempts != 1 {
+		t.Errorf("after first failure status = %s, attempts = %d; want pending, 1", stored.Status, stored.Attempts)
+	}
+	if _, total, _ := jobs.GetErrors(ctx, job.ID, 1, 10); total != 0 {
+		t.Errorf("errors of the failed run were kept: %d", total)
+	}
+
+	p.retryFailedJob(ctx, job, failure, zerolog.Nop(), resubmit)
+	<-resubmitted
+	if !p.retryFailedJob(ctx, job, failure, zerolog.Nop(), resubmit) {
+		t.Fatal("retryFailedJob() did not keep the dead-lettered job")
+	}
+	stored, _ = jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusDeadLetter || stored.Attempts != 3 || stored.ErrorMessage == nil || *stored.ErrorMessage != failure.Error() {
+		t.Errorf("after last failure job = %+v, want dead_letter after 3 attempts", stored)
+	}
+
+	// Requeueing starts the attempts over and queues the export again
+	if err := p.Requeue(ctx, stored); err != nil {
+		t.Fatalf("Requeue() error: %v", err)
+	}
+	stored, _ = jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusPending || stored.Attempts != 0 || p.exports.len() != 1 {
+		t.Errorf("after requeue status = %s, attempts = %d, queued = %d", stored.Status, stored.Attempts, p.exports.len())
+	}
+
+	// An upload that is gone can't be imported again
+	imp := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusDeadLetter}
+	missing := t.TempDir() + "/gone.csv"
+	imp.FilePath = &missing
+	if err := p.Requeue(ctx, imp); err != ErrUploadMissing {
+		t.Errorf("Requeue() of a removed upload = %v, want ErrUploadMissing", err)
+	}
+}
+
+func TestPool_Resume(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1, MaxAttempts: 3})
+
+	upload := t.TempDir() + "/users.csv"
+	if err := os.WriteFile(upload, []byte("email\nann@example.com\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	paused := func() *models.Job {
+		t.Helper()
+		job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending, FilePath: &upload, Attempts: 1}
+		if err := jobs.Create(ctx, job); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		if err := jobs.SetPaused(ctx, job.ID, "import paused by the circuit breaker"); err != nil {
+			t.Fatalf("SetPaused() error: %v", err)
+		}
+		stored, _ := jobs.GetByID(ctx, job.ID)
+		return stored
+	}
+
+	// Resuming keeps the attempts and queues the import again
+	job := paused()
+	if err := p.Resume(ctx, job); err != nil {
+		t.Fatalf("Resume() error: %v", err)
+	}
+	stored, _ := jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusPending || stored.Attempts != 1 || p.imports.len() != 1 {
+		t.Errorf("after resume status = %s, attempts = %d, queued = %d", stored.Status, stored.Attempts, p.imports.len())
+	}
+
+	// A job that can't be queued stays paused
+	job = paused()
+	if err := p.Resume(ctx, job); err == nil {
+		t.Fatal("Resume() with a full queue succeeded")
+	}
+	stored, _ = jobs.GetByID(ctx, job.ID)
+	if stored.Status != models.JobStatusPaused || stored.ErrorMessage == nil {
+		t.Errorf("after a failed resume job = %+v, want paused", stored)
+	}
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"github.com/google/uuid"
+)
+
+// WatchJob returns a channel that is closed when a run of the job ends on
+// this instance, whether it completed, failed or was queued for retry, and
+// a func that stops watching. Runs on other instances aren't seen, so
+// callers still read the job now and then.
+func (p *Pool) WatchJob(jobID uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	p.waitMu.Lock()
+	p.waiters[jobID] = append(p.waiters[jobID], ch)
+	p.waitMu.Unlock()
+
+	stop := func() {
+		p.waitMu.Lock()
+		defer p.waitMu.Unlock()
+		waiting := p.waiters[jobID]
+		for i, w := range waiting {
+			if w == ch {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		if len(waiting) == 0 {
+			delete(p.waiters, jobID)
+		} else {
+			p.waiters[jobID] = waiting
+		}
+	}
+	return ch, stop
+}
+
+// jobRunEnded wakes the watchers of a job once its run has stored its
+// outcome
+func (p *Pool) jobRunEnded(jobID uuid.UUID) {
+	p.waitMu.Lock()
+	waiting := p.waiters[jobID]
+	delete(p.waiters, jobID)
+	p.waitMu.Unlock()
+
+	for _, ch := range waiting {
+		close(ch)
+	}
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/repository/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestPool_WatchJob(t *testing.T) {
+	p := NewPool(nil, nil, memory.NewJobRepository(memory.NewDB()), nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+	jobID, other := uuid.New(), uuid.New()
+
+	first, stopFirst := p.WatchJob(jobID)
+	defer stopFirst()
+	second, stopSecond := p.WatchJob(jobID)
+	stopped, stop := p.WatchJob(jobID)
+	stop()
+
+	p.jobRunEnded(other)
+	select {
+	case <-first:
+		t.Fatal("watcher woke for another job")
+	default:
+	}
+
+	p.jobRunEnded(jobID)
+	for name, ch := range map[string]<-chan struct{}{"first": first, "second": second} {
+		select {
+		case <-ch:
+		default:
+			t.Errorf("%s watcher didn't wake", name)
+		}
+	}
+	select {
+	case <-stopped:
+		t.Error("stopped watcher woke")
+	default:
+	}
+
+	// Stopping after the wake is harmless
+	stopSecond()
+	if len(p.waiters) != 0 {
+		t.Errorf("waiters = %d, want none left", len(p.waiters))
+	}
+}
+
+func TestPool_FinishedWhileQueued(t *testing.T) {
+	ctx := context.Background()
+	jobs := memory.NewJobRepository(memory.NewDB())
+	p := NewPool(nil, nil, jobs, nil, zerolog.Nop(), config.WorkerConfig{QueueSize: 1})
+
+	job := &models.Job{Type: models.JobTypeImport, Resource: models.ResourceTypeUsers, Status: models.JobStatusPending}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if p.finishedWhileQueued(ctx, job, zerolog.Nop()) {
+		t.Error("a pending job was skipped")
+	}
+	if p.finishedWhileQueued(ctx, &models.Job{ID: uuid.New()}, zerolog.Nop()) {
+		t.Error("a job that can't be read was skipped")
+	}
+
+	ended, stop := p.WatchJob(job.ID)
+	defer stop()
+	if _, err := jobs.FinishManually(ctx, job.ID, models.JobStatusCancelled, "duplicate upload"); err != nil {
+		t.Fatalf("FinishManually() error: %v", err)
+	}
+	if !p.finishedWhileQueued(ctx, job, zerolog.Nop()) {
+		t.Error("a job cancelled while queued was run")
+	}
+	select {
+	case <-ended:
+	default:
+		t.Error("watchers of the skipped job weren't woken")
+	}
+}
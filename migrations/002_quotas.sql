@@ -0,0 +1,10 @@
+-- 002_quotas.sql
+-- Per-tenant quota tracking for jobs
+
+-- Tenant that created the job (derived from X-Tenant-ID / X-API-Key)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+
+-- Size of the export file produced by the job, used for storage quotas
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS file_size_bytes BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_created_at ON jobs(tenant_id, created_at);
@@ -0,0 +1,4 @@
+-- 003_export_watermark.sql
+-- Point in time an export's data is consistent as of
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS data_as_of TIMESTAMP WITH TIME ZONE;
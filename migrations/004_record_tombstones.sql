@@ -0,0 +1,29 @@
+-- 004_record_tombstones.sql
+-- Tombstones for deleted records so exports can emit deletions in diffs
+
+CREATE TABLE IF NOT EXISTS record_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    resource VARCHAR(50) NOT NULL CHECK (resource IN ('users', 'articles', 'comments')),
+    record_id UUID NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_tombstones_resource_deleted_at ON record_tombstones(resource, deleted_at);
+
+-- Function to record a tombstone for the deleted row
+CREATE OR REPLACE FUNCTION record_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO record_tombstones (resource, record_id) VALUES (TG_ARGV[0], OLD.id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_tombstone AFTER DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION record_tombstone('users');
+
+CREATE TRIGGER articles_tombstone AFTER DELETE ON articles
+    FOR EACH ROW EXECUTE FUNCTION record_tombstone('articles');
+
+CREATE TRIGGER comments_tombstone AFTER DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION record_tombstone('comments');
@@ -0,0 +1,6 @@
+-- Column statistics gathered by the optional import profiling pass
+CREATE TABLE IF NOT EXISTS job_profiles (
+    job_id UUID PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    profile JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
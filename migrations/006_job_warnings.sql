@@ -0,0 +1,15 @@
+-- Non-fatal conditions noticed while importing a row, kept apart from job_errors
+CREATE TABLE IF NOT EXISTS job_warnings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    record_identifier VARCHAR(255),
+    field_name VARCHAR(255),
+    warning_code VARCHAR(100) NOT NULL,
+    warning_message TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_warnings_row_number ON job_warnings(job_id, row_number);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS warning_count INTEGER NOT NULL DEFAULT 0;
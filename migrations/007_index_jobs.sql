@@ -0,0 +1,7 @@
+-- Search index syncs run as follow-up jobs of the import that produced them
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_type_check CHECK (type IN ('import', 'export', 'index'));
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS parent_job_id UUID REFERENCES jobs(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_parent_job_id ON jobs(parent_job_id);
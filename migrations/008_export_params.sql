@@ -0,0 +1,2 @@
+-- The request an export job was created from, so it can be re-run
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS export_params JSONB;
@@ -0,0 +1,10 @@
+-- The request every job was created from, replacing the export-only column
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS params JSONB;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'jobs' AND column_name = 'export_params') THEN
+        UPDATE jobs SET params = export_params WHERE params IS NULL AND export_params IS NOT NULL;
+        ALTER TABLE jobs DROP COLUMN export_params;
+    END IF;
+END $$;
@@ -0,0 +1,22 @@
+-- Natural key for comment imports that dedup by content instead of ID: the
+-- article, the author, the body with case and whitespace normalised, and
+-- created_at to the second. Missing created_at hashes as an empty string.
+CREATE OR REPLACE FUNCTION comment_natural_key(article_id UUID, user_id UUID, body TEXT, created_at TIMESTAMPTZ)
+RETURNS TEXT
+LANGUAGE SQL
+IMMUTABLE
+AS $$
+    SELECT md5(
+        article_id::text || '|' ||
+        user_id::text || '|' ||
+        lower(btrim(regexp_replace(body, '\s+', ' ', 'g'))) || '|' ||
+        COALESCE(floor(extract(epoch FROM created_at))::bigint::text, '')
+    )
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_comments_natural_key
+    ON comments (comment_natural_key(article_id, user_id, body, created_at));
+
+ALTER TABLE staging_comments ADD COLUMN IF NOT EXISTS natural_key VARCHAR(32);
+
+CREATE INDEX IF NOT EXISTS idx_staging_comments_natural_key ON staging_comments(job_id, natural_key);
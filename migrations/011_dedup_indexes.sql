@@ -0,0 +1,10 @@
+-- Expression indexes for the case-insensitive duplicate checks. The plain
+-- email and slug indexes can't serve LOWER(...) lookups, so checking staged
+-- rows against the main tables fell back to a sequential scan per row.
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
+CREATE INDEX IF NOT EXISTS idx_articles_slug_lower ON articles (LOWER(slug));
+
+-- Staging dedup groups rows of one job by key in staging_id order
+CREATE INDEX IF NOT EXISTS idx_staging_users_email_lower ON staging_users (job_id, LOWER(email), staging_id);
+CREATE INDEX IF NOT EXISTS idx_staging_articles_slug_lower ON staging_articles (job_id, LOWER(slug), staging_id);
+CREATE INDEX IF NOT EXISTS idx_staging_comments_id ON staging_comments (job_id, id, staging_id);
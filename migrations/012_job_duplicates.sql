@@ -0,0 +1,3 @@
+-- Duplicate rows found by an import, estimated while the file is staged and
+-- exact once staging dedup has run
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS duplicate_records INTEGER NOT NULL DEFAULT 0;
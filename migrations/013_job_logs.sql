@@ -0,0 +1,16 @@
+-- Log lines written while a job ran, kept so the job's owner can read the
+-- pipeline's diagnostics without access to the server logs
+CREATE TABLE IF NOT EXISTS job_logs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    line_number INTEGER NOT NULL,
+    level VARCHAR(10) NOT NULL,
+    message TEXT NOT NULL,
+    fields JSONB,
+    logged_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_logs_line_number ON job_logs(job_id, line_number);
+
+-- Older lines dropped to keep within the per-job limit
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS log_lines_dropped INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,23 @@
+-- 014_usage_rollups.sql
+-- Daily per-tenant job usage for GET /v1/reports/usage, so long report
+-- periods don't aggregate the jobs table row by row
+
+CREATE TABLE IF NOT EXISTS usage_daily (
+    day DATE NOT NULL,
+    tenant_id VARCHAR(255) NOT NULL,
+    jobs BIGINT NOT NULL DEFAULT 0,
+    import_jobs BIGINT NOT NULL DEFAULT 0,
+    export_jobs BIGINT NOT NULL DEFAULT 0,
+    failed_jobs BIGINT NOT NULL DEFAULT 0,
+    rows_imported BIGINT NOT NULL DEFAULT 0,
+    rows_exported BIGINT NOT NULL DEFAULT 0,
+    rows_failed BIGINT NOT NULL DEFAULT 0,
+    bytes_stored BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, tenant_id)
+);
+
+-- Days that have been rolled up, including days without any jobs
+CREATE TABLE IF NOT EXISTS usage_rollup_days (
+    day DATE PRIMARY KEY,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
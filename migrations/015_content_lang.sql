@@ -0,0 +1,11 @@
+-- 015_content_lang.sql
+-- Detected language (ISO 639-1) of article and comment bodies, set by
+-- imports run with language detection and filterable on export
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS lang VARCHAR(8);
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS lang VARCHAR(8);
+ALTER TABLE staging_articles ADD COLUMN IF NOT EXISTS lang VARCHAR(8);
+ALTER TABLE staging_comments ADD COLUMN IF NOT EXISTS lang VARCHAR(8);
+
+CREATE INDEX IF NOT EXISTS idx_articles_lang ON articles(lang);
+CREATE INDEX IF NOT EXISTS idx_comments_lang ON comments(lang);
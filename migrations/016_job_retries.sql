@@ -0,0 +1,9 @@
+-- Failed jobs are retried with backoff; attempts counts the failed runs and a
+-- job that fails every allowed attempt is parked as dead_letter
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+    CHECK (status IN ('pending', 'processing', 'completed', 'failed', 'cancelled', 'dead_letter'));
+
+CREATE INDEX IF NOT EXISTS idx_jobs_dead_letter ON jobs(updated_at) WHERE status = 'dead_letter';
@@ -0,0 +1,5 @@
+-- Workers stamp heartbeat_at while a job is processing; a job whose
+-- heartbeat goes stale was orphaned by a worker that died
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_processing_heartbeat ON jobs(heartbeat_at) WHERE status = 'processing';
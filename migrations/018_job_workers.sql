@@ -0,0 +1,5 @@
+-- Which instance, worker and build last ran each job, for tracing problems
+-- across replicas
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS worker_instance VARCHAR(255);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS worker_id VARCHAR(100);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS worker_version VARCHAR(100);
@@ -0,0 +1,3 @@
+-- The step a processing job is in, such as parse or insert for an import,
+-- for monitoring long running jobs
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS phase VARCHAR(50);
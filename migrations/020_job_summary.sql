@@ -0,0 +1,3 @@
+-- Breakdowns of an import's outcome, such as the rows rejected per email
+-- domain
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS summary JSONB;
@@ -0,0 +1,14 @@
+-- Notes operators add to a job and their manual changes of its status, such
+-- as failing a stuck job, with the reason
+CREATE TABLE IF NOT EXISTS job_timeline (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    author VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_timeline_job_id ON job_timeline(job_id, created_at);
@@ -0,0 +1,11 @@
+-- 022_import_provenance.sql
+-- The import job that last created or updated each record, filterable on
+-- export. There is no foreign key, so the ID outlives the job's retention.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS import_job_id UUID;
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS import_job_id UUID;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS import_job_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_users_import_job_id ON users(import_job_id);
+CREATE INDEX IF NOT EXISTS idx_articles_import_job_id ON articles(import_job_id);
+CREATE INDEX IF NOT EXISTS idx_comments_import_job_id ON comments(import_job_id);
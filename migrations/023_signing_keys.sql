@@ -0,0 +1,8 @@
+-- 023_signing_keys.sql
+-- HMAC keys export manifests and download URLs are signed with. The newest
+-- key signs; deleting a key retires it, so its signatures stop verifying.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    secret BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
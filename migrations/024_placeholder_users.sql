@@ -0,0 +1,6 @@
+-- 024_placeholder_users.sql
+-- Users created by article imports with create_missing_authors for authors
+-- that didn't exist. Importing the real user clears the flag.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS placeholder BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_placeholder ON users(placeholder) WHERE placeholder;
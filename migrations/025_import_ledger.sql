@@ -0,0 +1,12 @@
+-- 025_import_ledger.sql
+-- The content each import last wrote under a record's natural key, so a
+-- replayed file whose rows are all recorded writes nothing. There is no
+-- foreign key, so the job ID outlives the job's retention.
+CREATE TABLE IF NOT EXISTS import_ledger (
+    resource VARCHAR(50) NOT NULL,
+    natural_key TEXT NOT NULL,
+    content_hash VARCHAR(64) NOT NULL,
+    job_id UUID NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (resource, natural_key)
+);
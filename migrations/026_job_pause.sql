@@ -0,0 +1,8 @@
+-- 026_job_pause.sql
+-- Imports whose batch inserts keep failing are paused by the circuit breaker
+-- instead of failing, and wait for an operator to resume them
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+    CHECK (status IN ('pending', 'processing', 'completed', 'failed', 'cancelled', 'dead_letter', 'paused'));
+
+CREATE INDEX IF NOT EXISTS idx_jobs_paused ON jobs(updated_at) WHERE status = 'paused';
@@ -0,0 +1,24 @@
+-- 027_upload_sessions.sql
+-- Resumable uploads: a session per file and a row per part received. The
+-- parts themselves are kept under UPLOAD_PATH until the session is completed
+-- or expires.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL DEFAULT '',
+    file_name TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'completed')),
+    job_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+
+CREATE TABLE IF NOT EXISTS upload_parts (
+    session_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    part_number INTEGER NOT NULL CHECK (part_number > 0),
+    size_bytes BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, part_number)
+);
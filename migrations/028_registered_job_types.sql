@@ -0,0 +1,5 @@
+-- 028_registered_job_types.sql
+-- Job types registered with the worker pool are named by the code that
+-- registers them, so the type is only checked for a lowercase identifier
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_type_check CHECK (type ~ '^[a-z][a-z0-9_]*$');
@@ -0,0 +1,18 @@
+-- 029_import_batch_commits.sql
+-- A row per batch an import wrote to the main table, written in the batch's
+-- transaction so the counts it holds are exactly what committed, even when
+-- the process dies before the job's totals are updated. staging_id bounds
+-- the staged rows of the batch; they are NULL for rows the fast path kept in
+-- memory.
+CREATE TABLE IF NOT EXISTS import_batch_commits (
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    batch_no INTEGER NOT NULL,
+    first_staging_id BIGINT,
+    last_staging_id BIGINT,
+    rows INTEGER NOT NULL,
+    inserted INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    committed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (job_id, attempt, batch_no)
+);
@@ -0,0 +1,23 @@
+-- 030_deliveries.sql
+-- The queue of notification deliveries, such as alert webhooks, shared by
+-- every channel. A failed attempt is retried at next_attempt_at with
+-- exponential backoff until max_attempts, after which the delivery is
+-- dead-lettered and kept for an admin to requeue.
+CREATE TABLE IF NOT EXISTS deliveries (
+    id UUID PRIMARY KEY,
+    channel VARCHAR(50) NOT NULL,
+    target TEXT NOT NULL,
+    event VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead_letter')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_due ON deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_deliveries_status ON deliveries(status, created_at DESC);
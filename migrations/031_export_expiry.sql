@@ -0,0 +1,5 @@
+-- 031_export_expiry.sql
+-- Export files are removed once they expire, which releases their tenant's
+-- storage quota
+CREATE INDEX IF NOT EXISTS idx_jobs_export_files ON jobs(completed_at)
+    WHERE type = 'export' AND status = 'completed' AND file_path IS NOT NULL;
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var jobIDKey = []byte(`"job_id":"`)
+
+// Capture keeps the log lines of running jobs so they can be shown to the
+// job's owner. It is a log writer: every line carrying the job_id of a job
+// passed to Start is kept, up to a fixed number of the latest lines per job.
+type Capture struct {
+	mu       sync.Mutex
+	maxLines int
+	jobs     map[string]*capturedJob
+}
+
+type capturedJob struct {
+	lines   [][]byte
+	next    int
+	dropped int
+}
+
+// Entry is one captured log line
+type Entry struct {
+	Time    time.Time
+	Level   string
+	Message string
+	// Fields holds the line's other fields as a JSON object
+	Fields json.RawMessage
+}
+
+// NewCapture returns a capture keeping up to maxLines lines per job
+func NewCapture(maxLines int) *Capture {
+	return &Capture{maxLines: maxLines, jobs: make(map[string]*capturedJob)}
+}
+
+// Start begins keeping the log lines of jobID
+func (c *Capture) Start(jobID string) {
+	if c == nil || c.maxLines <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.jobs[jobID]; !ok {
+		c.jobs[jobID] = &capturedJob{}
+	}
+}
+
+// Stop ends the capture of jobID and returns its lines, oldest first, with
+// the number of older lines dropped to stay within the limit
+func (c *Capture) Stop(jobID string) ([]Entry, int) {
+	if c == nil {
+		return nil, 0
+	}
+	c.mu.Lock()
+	job, ok := c.jobs[jobID]
+	delete(c.jobs, jobID)
+	c.mu.Unlock()
+	if !ok {
+		return nil, 0
+	}
+	return parseLines(job.ordered()), job.dropped
+}
+
+// Entries returns the lines captured so far for jobID, or false if the job
+// isn't being captured
+func (c *Capture) Entries(jobID string) ([]Entry, int, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	job, ok := c.jobs[jobID]
+	var lines [][]byte
+	var dropped int
+	if ok {
+		lines = job.ordered()
+		dropped = job.dropped
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, 0, false
+	}
+	return parseLines(lines), dropped, true
+}
+
+// Write implements io.Writer for a JSON log line
+func (c *Capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.jobs) == 0 {
+		return len(p), nil
+	}
+
+	i := bytes.Index(p, jobIDKey)
+	if i < 0 {
+		return len(p), nil
+	}
+	id := p[i+len(jobIDKey):]
+	end := bytes.IndexByte(id, '"')
+	if end < 0 {
+		return len(p), nil
+	}
+	job, ok := c.jobs[string(id[:end])]
+	if !ok {
+		return len(p), nil
+	}
+
+	line := bytes.Clone(bytes.TrimRight(p, "\n"))
+	if len(job.lines) < c.maxLines {
+		job.lines = append(job.lines, line)
+		return len(p), nil
+	}
+	job.lines[job.next] = line
+	job.next = (job.next + 1) % c.maxLines
+	job.dropped++
+	return len(p), nil
+}
+
+// ordered returns the lines oldest first
+func (j *capturedJob) ordered() [][]byte {
+	lines := make([][]byte, 0, len(j.lines))
+	lines = append(lines, j.lines[j.next:]...)
+	return append(lines, j.lines[:j.next]...)
+}
+
+// parseLines splits JSON log lines into entries. Fields that only matter to
+// operators, such as the caller, are left out.
+func parseLines(lines [][]byte) []Entry {
+	entries := make([]Entry, 0, len(lines))
+	for _, line := range lines {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			continue
+		}
+
+		var entry Entry
+		if raw, ok := fields[zerolog.TimestampFieldName]; ok {
+			var ts string
+			if json.Unmarshal(raw, &ts) == nil {
+				entry.Time, _ = time.Parse(zerolog.TimeFieldFormat, ts)
+			}
+		}
+		if raw, ok := fields[zerolog.LevelFieldName]; ok {
+			json.Unmarshal(raw, &entry.Level)
+		}
+		if raw, ok := fields[zerolog.MessageFieldName]; ok {
+			json.Unmarshal(raw, &entry.Message)
+		}
+		for _, key := range []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.CallerFieldName, "job_id"} {
+			delete(fields, key)
+		}
+		if len(fields) > 0 {
+			entry.Fields, _ = json.Marshal(fields)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCapture_KeepsLatestLinesOfStartedJobs(t *testing.T) {
+	capture := NewCapture(2)
+	log := zerolog.New(capture).With().Timestamp().Logger()
+
+	capture.Start("job-1")
+	log.Info().Str("job_id", "job-2").Msg("other job")
+	log.Info().Msg("no job")
+	for _, msg := range []string{"first", "second", "third"} {
+		log.Warn().Str("job_id", "job-1").Int("rows", 10).Msg(msg)
+	}
+
+	entries, dropped, ok := capture.Entries("job-1")
+	if !ok {
+		t.Fatal("job-1 not being captured")
+	}
+	if dropped != 1 || len(entries) != 2 {
+		t.Fatalf("got %d entries and %d dropped, want 2 and 1", len(entries), dropped)
+	}
+	if entries[0].Message != "second" || entries[1].Message != "third" {
+		t.Errorf("messages = %q, %q, want second, third", entries[0].Message, entries[1].Message)
+	}
+	if entries[0].Level != "warn" || entries[0].Time.IsZero() {
+		t.Errorf("entry = %+v, want warn level and a time", entries[0])
+	}
+	var fields map[string]int
+	if err := json.Unmarshal(entries[0].Fields, &fields); err != nil || fields["rows"] != 10 || len(fields) != 1 {
+		t.Errorf("fields = %s, want only rows", entries[0].Fields)
+	}
+
+	capture.Stop("job-1")
+	if _, _, ok := capture.Entries("job-1"); ok {
+		t.Error("job-1 still captured after Stop")
+	}
+	if _, _, ok := capture.Entries("job-2"); ok {
+		t.Error("job-2 captured without Start")
+	}
+}
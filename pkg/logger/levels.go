@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultComponent names the level used by loggers without a component, and
+// by components without a level of their own
+const DefaultComponent = "default"
+
+// Levels holds the minimum level of each component's logs. Loggers built by
+// Component check it on every event, so a change applies at once.
+type Levels struct {
+	mu         sync.RWMutex
+	def        zerolog.Level
+	components map[string]zerolog.Level
+}
+
+// NewLevels returns levels that log at def unless a component overrides it
+func NewLevels(def zerolog.Level) *Levels {
+	return &Levels{def: def, components: make(map[string]zerolog.Level)}
+}
+
+// Get returns the level a component logs at
+func (l *Levels) Get(component string) zerolog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, ok := l.components[component]; ok {
+		return level
+	}
+	return l.def
+}
+
+// Set changes a component's level, or the default for DefaultComponent
+func (l *Levels) Set(component string, level zerolog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if component == DefaultComponent || component == "" {
+		l.def = level
+		return
+	}
+	l.components[component] = level
+}
+
+// Reset drops a component's own level so it follows the default again
+func (l *Levels) Reset(component string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.components, component)
+}
+
+// All returns the default level and every component override by name
+func (l *Levels) All() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	all := make(map[string]string, len(l.components)+1)
+	all[DefaultComponent] = l.def.String()
+	for component, level := range l.components {
+		all[component] = level.String()
+	}
+	return all
+}
+
+// levelHook discards events below the current level of its component
+type levelHook struct {
+	levels    *Levels
+	component string
+}
+
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level != zerolog.NoLevel && level < h.levels.Get(h.component) {
+		e.Discard()
+	}
+}
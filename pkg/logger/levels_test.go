@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLogs_ComponentLevelsApplyAtRuntime(t *testing.T) {
+	var buf bytes.Buffer
+	logs := &Logs{base: zerolog.New(&buf), levels: NewLevels(zerolog.InfoLevel)}
+	root := logs.Logger()
+	imports := logs.Component("import")
+
+	imports.Debug().Msg("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("debug logged at the info default: %s", buf.String())
+	}
+
+	logs.Levels().Set("import", zerolog.DebugLevel)
+	imports.Debug().Msg("shown")
+	root.Debug().Msg("root hidden")
+	if out := buf.String(); !strings.Contains(out, "shown") || strings.Contains(out, "root hidden") {
+		t.Errorf("output = %q, want only the import debug line", out)
+	}
+	if !strings.Contains(buf.String(), `"component":"import"`) {
+		t.Errorf("output = %q, want the component field", buf.String())
+	}
+
+	buf.Reset()
+	logs.Levels().Reset("import")
+	imports.Debug().Msg("hidden again")
+	if buf.Len() != 0 {
+		t.Errorf("debug logged after reset: %s", buf.String())
+	}
+}
+
+func TestHot_SamplesAfterBurst(t *testing.T) {
+	defer SetSampling(Sampling{})
+	SetSampling(Sampling{Every: 10})
+
+	var buf bytes.Buffer
+	hot := Hot(zerolog.New(&buf))
+	for i := 0; i < 100; i++ {
+		hot.Info().Msg("batch")
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 10 {
+		t.Errorf("logged %d of 100 events, want 10", lines)
+	}
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Sampling thins out log statements on hot paths, such as one per batch or
+// per row of an import. Each period the first Burst events pass, then one in
+// every Every. An Every of 0 or 1 keeps all events.
+type Sampling struct {
+	Burst  uint32
+	Period time.Duration
+	Every  uint32
+}
+
+var hotSampling atomic.Pointer[Sampling]
+
+// SetSampling sets how loggers returned by Hot sample their events
+func SetSampling(s Sampling) {
+	hotSampling.Store(&s)
+}
+
+// Hot returns l sampled for a hot path. Each call starts its own sampler, so
+// call it once per job or loop rather than per event.
+func Hot(l zerolog.Logger) zerolog.Logger {
+	s := hotSampling.Load()
+	if s == nil || s.Every <= 1 {
+		return l
+	}
+	return l.Sample(&zerolog.BurstSampler{
+		Burst:       s.Burst,
+		Period:      s.Period,
+		NextSampler: &zerolog.BasicSampler{N: s.Every},
+	})
+}
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Config selects where logs are written and at what levels
+type Config struct {
+	// Outputs is any of console, json, file and syslog; console and json
+	// write to stdout
+	Outputs       []string
+	FilePath      string
+	SyslogNetwork string // empty for the local syslog daemon
+	SyslogAddr    string
+	SyslogTag     string
+	// Level is the default level; ComponentLevels overrides it per component
+	Level           string
+	ComponentLevels map[string]string
+	Sampling        Sampling
+	// JobLogLines is how many log lines are kept per running job for its
+	// owner to read; 0 turns job log capture off
+	JobLogLines int
+}
+
+// Logs is the configured root logger and the levels of its components
+type Logs struct {
+	base    zerolog.Logger
+	levels  *Levels
+	capture *Capture
+	closers []io.Closer
+}
+
+// Configure builds the root logger from cfg
+func Configure(cfg Config) (*Logs, error) {
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	// Levels are applied per component by a hook, so nothing is dropped
+	// before the hook sees it
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	def, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	levels := NewLevels(def)
+	for component, name := range cfg.ComponentLevels {
+		level, err := parseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", component, err)
+		}
+		levels.Set(component, level)
+	}
+
+	logs := &Logs{levels: levels}
+	writers := make([]io.Writer, 0, len(cfg.Outputs))
+	for _, output := range cfg.Outputs {
+		w, err := logs.open(strings.TrimSpace(output), cfg)
+		if err != nil {
+			logs.Close()
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if len(writers) == 0 {
+		writers = append(writers, consoleWriter())
+	}
+	if cfg.JobLogLines > 0 {
+		logs.capture = NewCapture(cfg.JobLogLines)
+		writers = append(writers, logs.capture)
+	}
+
+	SetSampling(cfg.Sampling)
+	logs.base = zerolog.New(zerolog.MultiLevelWriter(writers...)).
+		With().
+		Timestamp().
+		Caller().
+		Logger()
+	return logs, nil
+}
+
+func (l *Logs) open(output string, cfg Config) (io.Writer, error) {
+	switch output {
+	case "console":
+		return consoleWriter(), nil
+	case "json":
+		return os.Stdout, nil
+	case "file":
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("log output file needs a file path")
+		}
+		if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		f, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		l.closers = append(l.closers, f)
+		return f, nil
+	case "syslog":
+		w, err := syslog.Dial(cfg.SyslogNetwork, cfg.SyslogAddr, syslog.LOG_INFO|syslog.LOG_DAEMON, cfg.SyslogTag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		l.closers = append(l.closers, w)
+		return zerolog.SyslogLevelWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown log output %q, expected console, json, file or syslog", output)
+	}
+}
+
+func consoleWriter() io.Writer {
+	return zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+}
+
+func parseLevel(name string) (zerolog.Level, error) {
+	if name == "" {
+		return zerolog.InfoLevel, nil
+	}
+	level, err := zerolog.ParseLevel(strings.ToLower(name))
+	if err != nil {
+		return zerolog.NoLevel, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
+
+// ParseLevel parses a level name such as debug or warn
+func ParseLevel(name string) (zerolog.Level, error) {
+	if name == "" {
+		return zerolog.NoLevel, fmt.Errorf("log level is required")
+	}
+	return parseLevel(name)
+}
+
+// Logger returns the root logger, which logs at the default level
+func (l *Logs) Logger() zerolog.Logger {
+	return l.base.Hook(levelHook{levels: l.levels, component: DefaultComponent})
+}
+
+// Component returns a logger tagged with component that logs at the
+// component's level
+func (l *Logs) Component(component string) zerolog.Logger {
+	return l.base.With().Str("component", component).Logger().
+		Hook(levelHook{levels: l.levels, component: component})
+}
+
+// Levels returns the levels the loggers check, for changing them at runtime
+func (l *Logs) Levels() *Levels {
+	return l.levels
+}
+
+// Capture returns the job log capture, or nil when it is off
+func (l *Logs) Capture() *Capture {
+	return l.capture
+}
+
+// Close closes the log file and syslog connection, if any
+func (l *Logs) Close() error {
+	var first error
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,88 @@
+package signing
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Keyring holds the keys a service signs and verifies with. The newest key
+// signs; every key verifies. The zero value holds no keys and is ready to
+// use.
+type Keyring struct {
+	mu     sync.RWMutex
+	active Key
+	keys   map[string][]byte
+}
+
+// NewKeyring returns a keyring holding keys
+func NewKeyring(keys ...Key) *Keyring {
+	r := &Keyring{}
+	r.Set(keys)
+	return r
+}
+
+// Set replaces the keys of the keyring
+func (r *Keyring) Set(keys []Key) {
+	var active Key
+	secrets := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		secrets[key.ID] = key.Secret
+		if active.ID == "" || key.CreatedAt.After(active.CreatedAt) {
+			active = key
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = active
+	r.keys = secrets
+}
+
+// Active returns the key new signatures are made with, false when the
+// keyring is empty
+func (r *Keyring) Active() (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active, r.active.ID != ""
+}
+
+// Secrets returns the secrets of the keyring by key ID
+func (r *Keyring) Secrets() map[string][]byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	secrets := make(map[string][]byte, len(r.keys))
+	for id, secret := range r.keys {
+		secrets[id] = secret
+	}
+	return secrets
+}
+
+// Sign signs payload with the active key, false when the keyring is empty
+func (r *Keyring) Sign(payload []byte) (string, bool) {
+	key, ok := r.Active()
+	if !ok {
+		return "", false
+	}
+	return Sign(key, payload, time.Now()), true
+}
+
+// Verify checks a signature made by Sign with any key of the keyring
+func (r *Keyring) Verify(signature string, payload []byte, maxAge time.Duration) error {
+	return Verify(signature, payload, r.Secrets(), maxAge, time.Now())
+}
+
+// SignURL signs path with the active key until ttl from now, false when the
+// keyring is empty
+func (r *Keyring) SignURL(path string, ttl time.Duration) (string, bool) {
+	key, ok := r.Active()
+	if !ok {
+		return "", false
+	}
+	return SignURL(key, path, time.Now().Add(ttl)), true
+}
+
+// VerifyURL checks a URL signed by SignURL with any key of the keyring
+func (r *Keyring) VerifyURL(u *url.URL) error {
+	return VerifyURL(u, r.Secrets(), time.Now())
+}
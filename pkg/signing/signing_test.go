@@ -0,0 +1,79 @@
+package signing
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	key := Key{ID: "k1", Secret: []byte("secret")}
+	at := time.Unix(1700000000, 0)
+	payload := []byte(`{"job_id":"1"}`)
+	sig := Sign(key, payload, at)
+	secrets := map[string][]byte{"k1": []byte("secret")}
+
+	tests := []struct {
+		name      string
+		signature string
+		payload   []byte
+		secrets   map[string][]byte
+		now       time.Time
+		want      error
+	}{
+		{"valid", sig, payload, secrets, at.Add(time.Minute), nil},
+		{"tampered payload", sig, []byte(`{"job_id":"2"}`), secrets, at, ErrMismatch},
+		{"other secret", sig, payload, map[string][]byte{"k1": []byte("other")}, at, ErrMismatch},
+		{"retired key", sig, payload, map[string][]byte{"k2": []byte("secret")}, at, ErrUnknownKey},
+		{"too old", sig, payload, secrets, at.Add(time.Hour), ErrExpired},
+		{"malformed", "v1=abc", payload, secrets, at, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.signature, tt.payload, tt.secrets, 5*time.Minute, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	key := Key{ID: "k1", Secret: []byte("secret")}
+	expires := time.Unix(1700000000, 0)
+	signed := SignURL(key, "/v1/exports/abc/download", expires)
+	secrets := map[string][]byte{"k1": []byte("secret")}
+
+	u, _ := url.Parse(signed + "&regenerate=true")
+	if err := VerifyURL(u, secrets, expires.Add(-time.Second)); err != nil {
+		t.Errorf("VerifyURL() = %v, want nil", err)
+	}
+	if err := VerifyURL(u, secrets, expires.Add(time.Second)); !errors.Is(err, ErrExpired) {
+		t.Errorf("VerifyURL() after expiry = %v, want ErrExpired", err)
+	}
+
+	other, _ := url.Parse(signed)
+	other.Path = "/v1/exports/def/download"
+	if err := VerifyURL(other, secrets, expires); !errors.Is(err, ErrMismatch) {
+		t.Errorf("VerifyURL() of another path = %v, want ErrMismatch", err)
+	}
+}
+
+func TestKeyring_SignsWithNewestKey(t *testing.T) {
+	old := Key{ID: "old", Secret: []byte("a"), CreatedAt: time.Unix(1, 0)}
+	ring := NewKeyring(old)
+	sig, _ := ring.Sign([]byte("payload"))
+
+	// After a rotation the new key signs, and the old signature still verifies
+	ring.Set([]Key{old, {ID: "new", Secret: []byte("b"), CreatedAt: time.Unix(2, 0)}})
+	if active, _ := ring.Active(); active.ID != "new" {
+		t.Errorf("Active() = %s, want new", active.ID)
+	}
+	if err := ring.Verify(sig, []byte("payload"), 0); err != nil {
+		t.Errorf("Verify() of the old key's signature = %v, want nil", err)
+	}
+
+	if _, ok := (&Keyring{}).Sign([]byte("payload")); ok {
+		t.Error("Sign() with an empty keyring succeeded")
+	}
+}
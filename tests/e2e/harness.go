@@ -0,0 +1,527 @@
+//go:build e2e
+
+// Package e2e runs the API end to end against PostgreSQL in a throwaway
+// container. Build with -tags e2e; Docker must be available.
+//
+// Scenario tests live in this package. Each gets a fresh server and empty
+// database from New and drives it through the helpers:
+//
+//	h := New(t)
+//	job := h.Import(models.ResourceTypeUsers, "users.csv", csv, nil)
+//	h.WaitForJob(job.JobID)
+//	errs := h.ImportErrors(job.JobID)
+//	users := h.Export(models.ResourceTypeUsers, nil)
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohit/bulk-import-export/internal/api"
+	"github.com/rohit/bulk-import-export/internal/api/handlers"
+	"github.com/rohit/bulk-import-export/internal/api/middleware"
+	"github.com/rohit/bulk-import-export/internal/config"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+	"github.com/rohit/bulk-import-export/internal/metrics"
+	"github.com/rohit/bulk-import-export/internal/repository/postgres"
+	exportservice "github.com/rohit/bulk-import-export/internal/service/export"
+	importservice "github.com/rohit/bulk-import-export/internal/service/import"
+	quotaservice "github.com/rohit/bulk-import-export/internal/service/quota"
+	reportservice "github.com/rohit/bulk-import-export/internal/service/report"
+	seedservice "github.com/rohit/bulk-import-export/internal/service/seed"
+	"github.com/rohit/bulk-import-export/internal/storage"
+	"github.com/rohit/bulk-import-export/internal/worker"
+	"github.com/rohit/bulk-import-export/pkg/logger"
+	"github.com/rs/zerolog"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	postgresImage = "postgres:16-alpine"
+	dbName        = "bulk_import_export"
+	dbUser        = "postgres"
+	dbPassword    = "postgres"
+
+	// JobTimeout is how long WaitForJob waits for a job to finish
+	JobTimeout = 60 * time.Second
+)
+
+// The container is shared by every test in the binary; New empties the
+// database between tests
+var (
+	container    *tcpostgres.PostgresContainer
+	containerCfg config.DatabaseConfig
+	containerErr error
+
+	// The collector registers with the default Prometheus registry, so
+	// there can only be one
+	collector = sync.OnceValue(metrics.NewCollector)
+)
+
+// Main starts the PostgreSQL container, runs the migrations and the tests,
+// then removes the container. The package's TestMain calls it. When the
+// container can't be started the tests are skipped, not failed.
+func Main(m *testing.M) int {
+	ctx := context.Background()
+	containerErr = startPostgres(ctx)
+	defer func() {
+		if container != nil {
+			if err := testcontainers.TerminateContainer(container); err != nil {
+				fmt.Fprintf(os.Stderr, "e2e: failed to terminate postgres container: %v\n", err)
+			}
+		}
+	}()
+	return m.Run()
+}
+
+func startPostgres(ctx context.Context) error {
+	ctr, err := tcpostgres.Run(ctx, postgresImage,
+		tcpostgres.WithDatabase(dbName),
+		tcpostgres.WithUsername(dbUser),
+		tcpostgres.WithPassword(dbPassword),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(60*time.Second),
+		),
+	)
+	if ctr != nil {
+		container = ctr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start postgres container: %w", err)
+	}
+
+	host, err := ctr.Host(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get container host: %w", err)
+	}
+	port, err := ctr.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return fmt.Errorf("failed to get container port: %w", err)
+	}
+	containerCfg = config.DatabaseConfig{
+		Host:         host,
+		Port:         int(port.Num()),
+		User:         dbUser,
+		Password:     dbPassword,
+		Name:         dbName,
+		SSLMode:      "disable",
+		MaxOpenConns: 10,
+		MaxIdleConns: 5,
+	}
+
+	db, err := postgres.NewConnection(containerCfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return migrate(ctx, db)
+}
+
+// migrate applies migrations/*.sql in file name order, as make migrate does
+func migrate(ctx context.Context, db *postgres.DB) error {
+	_, file, _, _ := runtime.Caller(0)
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(file), "..", "..", "migrations", "*.sql"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no migrations found")
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		sql, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, string(sql)); err != nil {
+			return fmt.Errorf("migration %s failed: %w", filepath.Base(f), err)
+		}
+	}
+	return nil
+}
+
+// Option adjusts the configuration of a Harness before it starts
+type Option func(cfg *config.Config)
+
+// Harness is an API server with running workers, backed by an empty database
+type Harness struct {
+	t *testing.T
+
+	// Server serves the router; requests can also be made to Server.URL
+	Server *httptest.Server
+	// DB is the container database, for seeding and assertions
+	DB     *postgres.DB
+	Config *config.Config
+	Jobs   *postgres.JobRepository
+}
+
+// New empties the database and starts a server and worker pool, which are
+// stopped when the test ends. The test is skipped when Docker isn't
+// available.
+func New(t *testing.T, opts ...Option) *Harness {
+	t.Helper()
+	if containerErr != nil {
+		t.Skipf("postgres container unavailable: %v", containerErr)
+	}
+	if container == nil {
+		t.Fatal("e2e.Main was not called from TestMain")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("UPLOAD_PATH", filepath.Join(dir, "uploads"))
+	t.Setenv("EXPORT_PATH", filepath.Join(dir, "exports"))
+	t.Setenv("STORAGE_PATH", filepath.Join(dir, "storage"))
+	// Queue every async export, so they go through the worker pool
+	t.Setenv("EXPORT_INLINE_MAX_ROWS", "0")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error: %v", err)
+	}
+	cfg.App.Env = "test"
+	cfg.Database = containerCfg
+	cfg.Storage.Type = "local"
+	cfg.Quota.Enabled = false
+	cfg.Search.Enabled = false
+	cfg.Events.Driver = ""
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	db, err := postgres.NewConnection(cfg.Database)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := reset(context.Background(), db); err != nil {
+		t.Fatalf("failed to reset database: %v", err)
+	}
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		t.Fatalf("failed to initialize storage: %v", err)
+	}
+
+	log := zerolog.New(zerolog.NewTestWriter(t)).Level(zerolog.WarnLevel)
+	if testing.Verbose() {
+		log = log.Level(zerolog.InfoLevel)
+	}
+
+	userRepo := postgres.NewUserRepository(db)
+	articleRepo := postgres.NewArticleRepository(db)
+	commentRepo := postgres.NewCommentRepository(db)
+	jobRepo := postgres.NewJobRepository(db)
+
+	importSvc := importservice.NewService(
+		userRepo,
+		articleRepo,
+		commentRepo,
+		jobRepo,
+		postgres.NewStagingRepository(db),
+		postgres.NewProfileRepository(db),
+		db,
+		store,
+		collector(),
+		log,
+		cfg.Import,
+	)
+	importSvc.SetLedger(postgres.NewLedgerRepository(db))
+	importSvc.SetUploadSessions(postgres.NewUploadSessionRepository(db))
+	importSvc.SetBatchLog(postgres.NewBatchLogRepository(db))
+	exportSvc := exportservice.NewService(
+		db,
+		userRepo,
+		articleRepo,
+		commentRepo,
+		postgres.NewTombstoneRepository(db),
+		jobRepo,
+		store,
+		cfg.Storage.SignedURLTTL,
+		collector(),
+		log,
+		cfg.Export,
+	)
+	quotaSvc := quotaservice.NewService(postgres.NewQuotaRepository(db), log, cfg.Quota)
+	reportSvc := reportservice.NewService(postgres.NewUsageRepository(db), log, cfg.Report)
+	seedSvc := seedservice.NewService(userRepo, articleRepo, log)
+
+	pool := worker.NewPool(importSvc, exportSvc, jobRepo, collector(), log, cfg.Worker)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+
+	levels := logger.NewLevels(zerolog.InfoLevel)
+	router := api.NewRouter(
+		db.DB,
+		importSvc,
+		exportSvc,
+		quotaSvc,
+		reportSvc,
+		seedSvc,
+		nil,
+		nil,
+		jobRepo,
+		postgres.NewIdempotencyRepository(db),
+		pool,
+		nil,
+		collector(),
+		levels,
+		nil,
+		log,
+		cfg,
+	)
+	server := httptest.NewServer(router.Engine())
+
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		pool.Stop()
+	})
+
+	return &Harness{t: t, Server: server, DB: db, Config: cfg, Jobs: jobRepo}
+}
+
+// reset truncates every table the migrations created
+func reset(ctx context.Context, db *postgres.DB) error {
+	var tables []string
+	if err := db.SelectContext(ctx, &tables, "SELECT tablename FROM pg_tables WHERE schemaname = 'public'"); err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE")
+	return err
+}
+
+// Do sends a request to the server. A non-nil body is sent as JSON unless it
+// is an io.Reader.
+func (h *Harness) Do(method, path string, body interface{}, header http.Header) *http.Response {
+	h.t.Helper()
+
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatalf("failed to encode request body: %v", err)
+		}
+		r = bytes.NewReader(data)
+		if header == nil {
+			header = http.Header{}
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+path, r)
+	if err != nil {
+		h.t.Fatalf("failed to build request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	h.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// DecodeJSON checks the response status and decodes its body into v
+func (h *Harness) DecodeJSON(resp *http.Response, wantStatus int, v interface{}) {
+	h.t.Helper()
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != wantStatus {
+		h.t.Fatalf("%s %s status = %d, want %d; body %s",
+			resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, wantStatus, data)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		h.t.Fatalf("failed to decode response %s: %v", data, err)
+	}
+}
+
+// Import uploads content as filename to POST /v1/imports. form adds fields
+// such as "sync" or "comment_dedup" to the multipart request.
+func (h *Harness) Import(resource models.ResourceType, filename, content string, form map[string]string) handlers.CreateImportResponse {
+	h.t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("resource", string(resource)); err != nil {
+		h.t.Fatal(err)
+	}
+	for k, v := range form {
+		if err := mw.WriteField(k, v); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if _, err := io.WriteString(part, content); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		h.t.Fatal(err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", mw.FormDataContentType())
+	header.Set(middleware.IdempotencyKeyHeader, uuid.NewString())
+	resp := h.Do(http.MethodPost, "/v1/imports", &body, header)
+
+	var created handlers.CreateImportResponse
+	wantStatus := http.StatusAccepted
+	if form["sync"] == "true" {
+		wantStatus = http.StatusOK
+	}
+	h.DecodeJSON(resp, wantStatus, &created)
+	return created
+}
+
+// WaitForJob polls the job until it completes or fails and returns it
+func (h *Harness) WaitForJob(jobID string) *models.Job {
+	h.t.Helper()
+
+	id, err := uuid.Parse(jobID)
+	if err != nil {
+		h.t.Fatalf("invalid job ID %q: %v", jobID, err)
+	}
+	deadline := time.Now().Add(JobTimeout)
+	for {
+		job, err := h.Jobs.GetByID(context.Background(), id)
+		if err != nil {
+			h.t.Fatalf("failed to get job %s: %v", jobID, err)
+		}
+		if job != nil && (job.Status == models.JobStatusCompleted || job.Status == models.JobStatusFailed || job.Status == models.JobStatusDeadLetter) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("job %s did not finish within %s", jobID, JobTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// ImportStatus returns GET /v1/imports/:job_id
+func (h *Harness) ImportStatus(jobID string) handlers.GetImportStatusResponse {
+	h.t.Helper()
+	var status handlers.GetImportStatusResponse
+	h.DecodeJSON(h.Do(http.MethodGet, "/v1/imports/"+jobID, nil, nil), http.StatusOK, &status)
+	return status
+}
+
+// ImportErrors returns every error of an import, reading all pages
+func (h *Harness) ImportErrors(jobID string) []handlers.JobErrorItem {
+	h.t.Helper()
+
+	var errs []handlers.JobErrorItem
+	for page := 1; ; page++ {
+		var resp handlers.GetImportErrorsResponse
+		path := fmt.Sprintf("/v1/imports/%s/errors?page=%d&per_page=1000", jobID, page)
+		h.DecodeJSON(h.Do(http.MethodGet, path, nil, nil), http.StatusOK, &resp)
+		errs = append(errs, resp.Errors...)
+		if len(resp.Errors) == 0 || page >= resp.Pagination.TotalPages {
+			return errs
+		}
+	}
+}
+
+// Export streams GET /v1/exports as NDJSON and returns one raw record per
+// line. query adds filters such as "role" or "created_after".
+func (h *Harness) Export(resource models.ResourceType, query url.Values) []json.RawMessage {
+	h.t.Helper()
+
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("resource", string(resource))
+	resp := h.Do(http.MethodGet, "/v1/exports?"+q.Encode(), nil, nil)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("failed to read export: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("export status = %d; body %s", resp.StatusCode, data)
+	}
+	if status := resp.Trailer.Get("X-Export-Status"); status != handlers.StreamStatusComplete {
+		h.t.Fatalf("export trailer status = %q, want %q", status, handlers.StreamStatusComplete)
+	}
+
+	var records []json.RawMessage
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			records = append(records, json.RawMessage(line))
+		}
+	}
+	return records
+}
+
+// AsyncExport creates an export job with POST /v1/exports, waits for it and
+// returns the downloaded file's records
+func (h *Harness) AsyncExport(req handlers.CreateAsyncExportRequest) (*models.Job, []json.RawMessage) {
+	h.t.Helper()
+
+	var created handlers.CreateAsyncExportResponse
+	h.DecodeJSON(h.Do(http.MethodPost, "/v1/exports", req, nil), http.StatusAccepted, &created)
+
+	job := h.WaitForJob(created.JobID)
+	if job.Status != models.JobStatusCompleted {
+		h.t.Fatalf("export job %s status = %s, error %v", created.JobID, job.Status, job.ErrorMessage)
+	}
+
+	resp := h.Do(http.MethodGet, "/v1/exports/"+created.JobID+"/download", nil, nil)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("failed to read download: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("download status = %d; body %s", resp.StatusCode, data)
+	}
+
+	var records []json.RawMessage
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			records = append(records, json.RawMessage(line))
+		}
+	}
+	return job, records
+}
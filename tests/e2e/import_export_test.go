@@ -0,0 +1,115 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/rohit/bulk-import-export/internal/api/handlers"
+	domainerrors "github.com/rohit/bulk-import-export/internal/domain/errors"
+	"github.com/rohit/bulk-import-export/internal/domain/models"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(Main(m))
+}
+
+const (
+	annID     = "11111111-1111-4111-8111-111111111111"
+	bobID     = "22222222-2222-4222-8222-222222222222"
+	articleID = "33333333-3333-4333-8333-333333333333"
+)
+
+const usersCSV = `id,email,name,role,active,created_at,updated_at
+` + annID + `,ann@example.com,Ann,admin,true,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z
+` + bobID + `,bob@example.com,Bob,author,true,2024-01-02T00:00:00Z,2024-01-02T00:00:00Z
+,ANN@example.com,Ann Again,reader,true,2024-01-03T00:00:00Z,2024-01-03T00:00:00Z
+,not-an-email,Nobody,reader,true,2024-01-04T00:00:00Z,2024-01-04T00:00:00Z
+`
+
+func TestUsers_ImportStatusErrorsExport(t *testing.T) {
+	h := New(t)
+
+	created := h.Import(models.ResourceTypeUsers, "users.csv", usersCSV, nil)
+	job := h.WaitForJob(created.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("import status = %s, error %v", job.Status, job.ErrorMessage)
+	}
+
+	status := h.ImportStatus(created.JobID)
+	if p := status.Progress; p.TotalRecords != 4 || p.SuccessfulRecords != 2 || p.FailedRecords != 2 || p.DuplicateRecords != 1 {
+		t.Errorf("progress = %+v, want 4 rows with 2 imported and 1 duplicate", p)
+	}
+
+	errs := h.ImportErrors(created.JobID)
+	if len(errs) != 1 || errs[0].ErrorCode != domainerrors.ErrCodeInvalidEmail || errs[0].RowNumber != 5 {
+		t.Errorf("errors = %+v, want one INVALID_EMAIL on row 5", errs)
+	}
+
+	records := h.Export(models.ResourceTypeUsers, nil)
+	if got := emails(t, records); len(got) != 2 || got[0] != "ann@example.com" || got[1] != "bob@example.com" {
+		t.Errorf("exported emails = %v, want Ann then Bob", got)
+	}
+
+	admins := h.Export(models.ResourceTypeUsers, url.Values{"role": {"admin"}})
+	if got := emails(t, admins); len(got) != 1 || got[0] != "ann@example.com" {
+		t.Errorf("exported admins = %v, want only Ann", got)
+	}
+}
+
+func TestArticlesAndComments_ImportThenAsyncExport(t *testing.T) {
+	h := New(t)
+
+	users := h.Import(models.ResourceTypeUsers, "users.csv", usersCSV, nil)
+	if job := h.WaitForJob(users.JobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("user import status = %s", job.Status)
+	}
+
+	articles := h.Import(models.ResourceTypeArticles, "articles.ndjson",
+		`{"id":"`+articleID+`","slug":"hello-world","title":"Hello","body":"First post","author_id":"`+bobID+`","status":"draft"}
+{"slug":"orphan","title":"Orphan","body":"No author","author_id":"44444444-4444-4444-8444-444444444444","status":"draft"}
+`, nil)
+	job := h.WaitForJob(articles.JobID)
+	if job.Status != models.JobStatusCompleted || job.SuccessfulRecords != 1 || job.FailedRecords != 1 {
+		t.Fatalf("article import = %s with %d imported, %d failed; want completed, 1, 1",
+			job.Status, job.SuccessfulRecords, job.FailedRecords)
+	}
+
+	comments := h.Import(models.ResourceTypeComments, "comments.ndjson",
+		`{"article_id":"`+articleID+`","user_id":"`+annID+`","body":"Nice post","created_at":"2024-02-01T10:00:00Z"}
+{"article_id":"`+articleID+`","user_id":"`+annID+`","body":"  nice   POST ","created_at":"2024-02-01T10:00:00Z"}
+{"article_id":"`+articleID+`","user_id":"`+bobID+`","body":"Thanks!","created_at":"2024-02-01T11:00:00Z"}
+`, map[string]string{"comment_dedup": string(models.CommentDedupNaturalKey)})
+	job = h.WaitForJob(comments.JobID)
+	if job.Status != models.JobStatusCompleted || job.SuccessfulRecords != 2 || job.DuplicateRecords != 1 {
+		t.Fatalf("comment import = %s with %d imported, %d duplicates; want completed, 2, 1",
+			job.Status, job.SuccessfulRecords, job.DuplicateRecords)
+	}
+
+	exportJob, records := h.AsyncExport(handlers.CreateAsyncExportRequest{
+		Resource: string(models.ResourceTypeComments),
+		Filters:  map[string]interface{}{"created_after": "2024-02-01T10:30:00Z"},
+	})
+	if len(records) != 1 {
+		t.Fatalf("exported %d comments, want only Bob's reply", len(records))
+	}
+	if exportJob.DataAsOf == nil {
+		t.Error("export job has no data_as_of watermark")
+	}
+}
+
+func emails(t *testing.T, records []json.RawMessage) []string {
+	t.Helper()
+	var out []string
+	for _, r := range records {
+		var user models.User
+		if err := json.Unmarshal(r, &user); err != nil {
+			t.Fatalf("failed to decode user %s: %v", r, err)
+		}
+		out = append(out, user.Email)
+	}
+	return out
+}
@@ -50,6 +50,7 @@ func TestIntegration_UserImport_WithRealData(t *testing.T) {
 		totalRecords    int
 		validRecords    int
 		invalidRecords  int
+		parseErrors     int
 		invalidEmails   int
 		invalidRoles    int
 		missingFields   int
@@ -59,9 +60,15 @@ func TestIntegration_UserImport_WithRealData(t *testing.T) {
 
 	seenEmails := make(map[string]int)
 
-	err = parser.ParseUsers(func(row int, user *models.UserImport) error {
+	err = parser.ParseUsers(func(row int, user *models.UserImport, rawLine string, parseErr *parsers.ParseError) error {
 		stats.totalRecords++
 
+		// A malformed row comes with a parse error instead of a user
+		if parseErr != nil || user == nil {
+			stats.parseErrors++
+			return nil
+		}
+
 		// Track duplicates
 		if user.Email != "" {
 			seenEmails[strings.ToLower(user.Email)]++
@@ -103,6 +110,7 @@ func TestIntegration_UserImport_WithRealData(t *testing.T) {
 	t.Logf("  Total records: %d", stats.totalRecords)
 	t.Logf("  Valid records: %d", stats.validRecords)
 	t.Logf("  Invalid records: %d", stats.invalidRecords)
+	t.Logf("  Parse errors: %d", stats.parseErrors)
 	t.Logf("  Invalid emails: %d", stats.invalidEmails)
 	t.Logf("  Invalid roles: %d", stats.invalidRoles)
 	t.Logf("  Missing fields: %d", stats.missingFields)
@@ -148,10 +156,10 @@ func TestIntegration_ArticleImport_WithRealData(t *testing.T) {
 		missingFields    int
 	}{}
 
-	err = parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string) error {
+	err = parser.ParseArticles(func(row int, article *models.ArticleImport, rawJSON string, parseErr *parsers.ParseError) error {
 		stats.totalRecords++
 
-		if article == nil {
+		if parseErr != nil || article == nil {
 			stats.parseErrors++
 			return nil
 		}
@@ -228,10 +236,10 @@ func TestIntegration_CommentImport_WithRealData(t *testing.T) {
 		bodyTooLong       int
 	}{}
 
-	err = parser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string) error {
+	err = parser.ParseComments(func(row int, comment *models.CommentImport, rawJSON string, parseErr *parsers.ParseError) error {
 		stats.totalRecords++
 
-		if comment == nil {
+		if parseErr != nil || comment == nil {
 			stats.parseErrors++
 			return nil
 		}